@@ -1,249 +1,51 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
-	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
-	"golang.org/x/net/html"
-)
-
-// Author represents an author with their quote count
-type Author struct {
-	Name       string `json:"name"`
-	QuoteCount int    `json:"quoteCount"`
-	Link       string `json:"link"`
-}
-
-func parseAuthorsFromHTML(htmlContent string) ([]Author, error) {
-	doc, err := html.Parse(strings.NewReader(htmlContent))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %v", err)
-	}
-
-	var authors []Author
-	seenInFile := make(map[string]bool)
-
-	// Pattern to extract quote count from text like "(123)"
-	countRe := regexp.MustCompile(`\((\d+)\)`)
-
-	var traverse func(*html.Node)
-	traverse = func(n *html.Node) {
-		// Look for <div> tags containing author info
-		if n.Type == html.ElementNode && n.Data == "div" {
-			// Check if this div contains an <a> tag with author link
-			var authorLink *html.Node
-			var authorName string
-			var authorHref string
-			var quoteCount int
-
-			// Search for <a> tag inside this div
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if c.Type == html.ElementNode && c.Data == "a" {
-					href := getAttr(c, "href")
-					// Check if it's an author link (not /autor/ but direct author link)
-					if href != "" && !strings.Contains(href, "telf") {
-						authorLink = c
-						authorName = getTextContent(c)
-						authorName = strings.TrimSpace(authorName)
-						authorHref = href
-						break
-					}
-				}
-			}
-
-			// If we found an author link, look for the quote count
-			if authorLink != nil && authorName != "" {
-				// Get all text content from the div
-				divText := getTextContent(n)
-
-				// Extract quote count from pattern like "(123)"
-				matches := countRe.FindStringSubmatch(divText)
-				if len(matches) >= 2 {
-					quoteCount, _ = strconv.Atoi(matches[1])
-				}
-
-				// Build full URL
-				fullLink := authorHref
-				if !strings.HasPrefix(authorHref, "http") {
-					if strings.HasPrefix(authorHref, "/") {
-						fullLink = "https://fraseslibros.com" + authorHref
-					} else {
-						fullLink = "https://fraseslibros.com/" + authorHref
-					}
-				}
-
-				// Filter valid names (at least 3 chars, contains letters)
-				if len(authorName) >= 3 && regexp.MustCompile(`[a-zA-ZÀ-ÿ]`).MatchString(authorName) {
-					key := authorName
-					if !seenInFile[key] {
-						seenInFile[key] = true
-						authors = append(authors, Author{
-							Name:       authorName,
-							QuoteCount: quoteCount,
-							Link:       fullLink,
-						})
-					}
-				}
-			}
-		}
-
-		// Continue traversing
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			traverse(c)
-		}
-	}
-
-	traverse(doc)
-	return authors, nil
-}
-
-func getAttr(n *html.Node, key string) string {
-	for _, attr := range n.Attr {
-		if attr.Key == key {
-			return attr.Val
-		}
-	}
-	return ""
-}
-
-func getTextContent(n *html.Node) string {
-	var text strings.Builder
-	var traverse func(*html.Node)
-	traverse = func(node *html.Node) {
-		if node.Type == html.TextNode {
-			text.WriteString(node.Data)
-		}
-		for c := node.FirstChild; c != nil; c = c.NextSibling {
-			traverse(c)
-		}
-	}
-	traverse(n)
-	return text.String()
-}
-
-func parseAuthorsFromFile(filename string) ([]Author, error) {
-	content, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %v", filename, err)
-	}
-
-	return parseAuthorsFromHTML(string(content))
-}
-
-func parseAllAuthorsFromFolder(folderPath string) ([]Author, error) {
-	var allAuthors []Author
-	globalSeen := make(map[string]bool)
-
-	files, err := filepath.Glob(filepath.Join(folderPath, "*.text"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to list files: %v", err)
-	}
-
-	if len(files) == 0 {
-		return nil, fmt.Errorf("no .text files found in %s", folderPath)
-	}
-
-	fmt.Printf("Processing %d files...\n\n", len(files))
-
-	for _, file := range files {
-		authors, err := parseAuthorsFromFile(file)
-		if err != nil {
-			log.Printf("Error parsing %s: %v", file, err)
-			continue
-		}
-
-		fmt.Printf("File: %s - Found %d authors\n", filepath.Base(file), len(authors))
 
-		for _, author := range authors {
-			key := author.Name
-			// Track globally to avoid duplicates across all files
-			if !globalSeen[key] {
-				globalSeen[key] = true
-				allAuthors = append(allAuthors, author)
-			}
-		}
-	}
-
-	return allAuthors, nil
-}
+	"quotesparser/fetch"
+	"quotesparser/frases"
+	"quotesparser/migrations"
+	"quotesparser/store"
+)
 
-func insertAuthorsToDatabase(authors []Author, dbPath string) error {
+// openDatabase opens database.db and brings its schema up to date
+func openDatabase(dbPath string) (*sql.DB, error) {
 	// Open database with UTF-8 encoding parameters
-	db, err := sql.Open("sqlite3", dbPath+"?charset=utf8&parseTime=true")
+	db, err := sql.Open("sqlite3", store.SQLiteDSN(dbPath))
 	if err != nil {
-		return fmt.Errorf("failed to open database: %v", err)
+		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
-	defer db.Close()
 
 	// Set UTF-8 encoding pragmas
-	_, err = db.Exec("PRAGMA encoding = 'UTF-8'")
-	if err != nil {
-		return fmt.Errorf("failed to set encoding: %v", err)
-	}
-
-	// Drop and recreate table to ensure proper encoding
-	_, err = db.Exec("DROP TABLE IF EXISTS frasesauthors")
-	if err != nil {
-		return fmt.Errorf("failed to drop table: %v", err)
-	}
-
-	// Create table with explicit TEXT type
-	_, err = db.Exec(`
-		CREATE TABLE frasesauthors (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			authorName TEXT NOT NULL,
-			authorLink TEXT NOT NULL,
-			quoteCount INTEGER NOT NULL
-		)
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create table: %v", err)
-	}
-
-	// Begin transaction
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
-	}
-
-	stmt, err := tx.Prepare("INSERT INTO frasesauthors (authorName, authorLink, quoteCount) VALUES (?, ?, ?)")
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to prepare statement: %v", err)
-	}
-	defer stmt.Close()
-
-	inserted := 0
-	for _, author := range authors {
-		if author.Name != "" && author.Link != "" && strings.Contains(author.Link, "fraseslibros.com") {
-			_, err = stmt.Exec(author.Name, author.Link, author.QuoteCount)
-			if err != nil {
-				log.Printf("Warning: failed to insert %s: %v", author.Name, err)
-				continue
-			}
-			inserted++
-		}
+	if _, err := db.Exec("PRAGMA encoding = 'UTF-8'"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set encoding: %v", err)
 	}
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
+	if _, err := migrations.Up(db, 0); err != nil {
+		db.Close()
+		return nil, err
 	}
-
-	fmt.Printf("\n✓ Inserted %d authors into database.db with UTF-8 encoding\n", inserted)
-	return nil
+	return db, nil
 }
 
 func main() {
+	skipQuotes := flag.Bool("skip-quotes", false, "only parse authors, do not crawl their quote pages")
+	flag.Parse()
+
 	folderPath := "fraseslibros"
+	quotesFolder := filepath.Join(folderPath, "quotes")
 	dbPath := "database.db"
 
 	// Check if folder exists
@@ -251,7 +53,7 @@ func main() {
 		log.Fatalf("Folder %s does not exist", folderPath)
 	}
 
-	authors, err := parseAllAuthorsFromFolder(folderPath)
+	authors, err := frases.ParseAuthorsFromFolder(folderPath, 0)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -280,30 +82,35 @@ func main() {
 	fmt.Printf("\nSaved %d authors to %s with proper UTF-8 encoding\n", len(authors), jsonFilePath)
 
 	// Insert into database
-	if err := insertAuthorsToDatabase(authors, dbPath); err != nil {
+	db, err := openDatabase(dbPath)
+	if err != nil {
 		log.Fatalf("Database error: %v", err)
 	}
+	defer db.Close()
 
-	fmt.Println("✓ Database operations completed successfully")
-}
-
-
-/etc/default/paperpi.ini
-
-Yorgolar Rum'du. Pek çok başka gayrimüslim aile gibi varlık vergisinin sillesini fena yemişlerdi. O zamanlar iktidarda Cumhuriyet Halk Partisi vardı. Bu nedenle günahları kadar sevmiyorlardı CHP'yi. Haksız da sayılmazlardı.
-
-─2872 bash /usr/local/bin/paperpi -d
-             └─2874 python3 /usr/local/paperpi/paperpi.py -d
-
-
-			 # Activate the virtual environment
-source /usr/local/paperpi/venv_paperpi/bin/activate
-
-# Uninstall existing Pillow
-sudo pip uninstall pillow -y
+	inserted, updated, err := frases.InsertAuthors(db, authors)
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+	fmt.Printf("\n✓ Inserted %d new and updated %d existing authors in database.db with UTF-8 encoding\n", inserted, updated)
+
+	if !*skipQuotes {
+		// Second stage: visit every author page and collect the actual quotes
+		fmt.Printf("\nCrawling author pages into %s/...\n\n", quotesFolder)
+		ctx, stop := fetch.Interrupted()
+		quotes, err := frases.NewCrawler().CrawlAll(ctx, authors, quotesFolder)
+		stop()
+		// Keep what was crawled before Ctrl+C
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatal(err)
+		}
 
-# Reinstall Pillow (it will recompile with FreeType support)
-sudo pip install --no-cache-dir pillow
+		inserted, updated, err := frases.InsertQuotes(db, quotes)
+		if err != nil {
+			log.Fatalf("Database error: %v", err)
+		}
+		fmt.Printf("\n✓ Inserted %d new and updated %d existing quotes in database.db\n", inserted, updated)
+	}
 
-# Deactivate
-deactivate
\ No newline at end of file
+	fmt.Println("✓ Database operations completed successfully")
+}
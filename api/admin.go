@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"quotesparser/migrations"
+)
+
+// keyNameRe is what the names of keys made by an admin may look like, so the
+// keys file stays one key a line
+var keyNameRe = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+
+// KeyInfo is a key as GET /admin/keys lists it. Its secret is only shown
+// once, by POST /admin/keys.
+type KeyInfo struct {
+	Name   string `json:"name"`
+	Role   Role   `json:"role"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// Schema is the state of the database's migrations
+type Schema struct {
+	Version int             `json:"version"`
+	Latest  int             `json:"latest"`
+	Pending []SchemaVersion `json:"pending"`
+}
+
+// SchemaVersion names a migration
+type SchemaVersion struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+// The admin routes manage the keys and the schema for whoever holds an
+// admin's key
+func (s *Server) adminRoutes() {
+	s.mux.HandleFunc("GET /admin/keys", s.withRole(Admin, s.listKeys))
+	s.mux.HandleFunc("POST /admin/keys", s.withRole(Admin, s.addKey))
+	s.mux.HandleFunc("DELETE /admin/keys/{name}", s.withRole(Admin, s.deleteKey))
+	s.mux.HandleFunc("GET /admin/schema", s.withRole(Admin, s.schema))
+	s.mux.HandleFunc("POST /admin/schema/migrate", s.withRole(Admin, s.migrate))
+}
+
+// GET /admin/keys lists the keys and their roles, without their secrets
+func (s *Server) listKeys(w http.ResponseWriter, r *http.Request, key string) {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+	list := make([]KeyInfo, len(s.keys))
+	for i, k := range s.keys {
+		list[i] = KeyInfo{Name: k.Name, Role: k.Role}
+	}
+	reply(w, list, nil)
+}
+
+// POST /admin/keys makes a key of the given name and role, answering its
+// secret with a 201
+func (s *Server) addKey(w http.ResponseWriter, r *http.Request, key string) {
+	var info KeyInfo
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&info); err != nil {
+		reply(w, nil, badRequest(fmt.Sprintf("bad key: %v", err)))
+		return
+	}
+	if !keyNameRe.MatchString(info.Name) {
+		reply(w, nil, badRequest("name must be 1 to 64 letters, digits or _.@-"))
+		return
+	}
+	if info.Secret != "" {
+		reply(w, nil, badRequest("secrets are made by the server"))
+		return
+	}
+	secret, err := newSecret()
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+
+	err = s.changeKeys(func(keys []Key) ([]Key, error) {
+		for _, k := range keys {
+			if k.Name == info.Name {
+				return nil, conflict(fmt.Sprintf("key %s already exists", info.Name))
+			}
+		}
+		return append(keys, Key{Name: info.Name, Role: info.Role, Secret: secret}), nil
+	})
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	slog.Info("added key", "by", key, "role", info.Role, "name", info.Name)
+
+	info.Secret = secret
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+}
+
+// DELETE /admin/keys/{name} revokes a key, answering a 204. The last admin's
+// key cannot be revoked, which would leave none to make keys.
+func (s *Server) deleteKey(w http.ResponseWriter, r *http.Request, key string) {
+	name := r.PathValue("name")
+	err := s.changeKeys(func(keys []Key) ([]Key, error) {
+		var kept []Key
+		admins := 0
+		for _, k := range keys {
+			if k.Name == name {
+				continue
+			}
+			kept = append(kept, k)
+			if k.Role == Admin {
+				admins++
+			}
+		}
+		if len(kept) == len(keys) {
+			return nil, notFound(fmt.Sprintf("no key %s", name))
+		}
+		if admins == 0 {
+			return nil, conflict("cannot revoke the last admin key")
+		}
+		return kept, nil
+	})
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	slog.Info("revoked key", "by", key, "name", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// changeKeys replaces the keys with what change makes of them, saving them
+// to KeysFile first if set, so a key the file lost is never served
+func (s *Server) changeKeys(change func(keys []Key) ([]Key, error)) error {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	keys, err := change(append([]Key(nil), s.keys...))
+	if err != nil {
+		return err
+	}
+	if s.KeysFile != "" {
+		if err := SaveKeys(s.KeysFile, keys); err != nil {
+			return err
+		}
+	}
+	s.keys = keys
+	return nil
+}
+
+// GET /admin/schema answers the database's schema version and the
+// migrations still to apply
+func (s *Server) schema(w http.ResponseWriter, r *http.Request, key string) {
+	sc, err := s.schemaState()
+	reply(w, sc, err)
+}
+
+// POST /admin/schema/migrate applies the pending migrations and answers the
+// schema as it is then. Rolling back is left to quotes migrate down.
+func (s *Server) migrate(w http.ResponseWriter, r *http.Request, key string) {
+	applied, err := migrations.Up(s.DB, 0)
+	if err != nil {
+		reply(w, nil, fmt.Errorf("failed to migrate: %v", err))
+		return
+	}
+	for _, m := range applied {
+		slog.Info("applied migration", "by", key, "version", m.Version, "name", m.Name)
+	}
+	sc, err := s.schemaState()
+	reply(w, sc, err)
+}
+
+func (s *Server) schemaState() (Schema, error) {
+	var sc Schema
+	var err error
+	if sc.Version, err = migrations.Current(s.DB); err != nil {
+		return sc, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	sc.Latest = migrations.Latest()
+	pending, err := migrations.Pending(s.DB)
+	if err != nil {
+		return sc, fmt.Errorf("failed to read migrations: %v", err)
+	}
+	sc.Pending = []SchemaVersion{}
+	for _, m := range pending {
+		sc.Pending = append(sc.Pending, SchemaVersion{Version: m.Version, Name: m.Name})
+	}
+	return sc, nil
+}
@@ -0,0 +1,1072 @@
+// Package api serves the quotes database over HTTP as JSON, for displays and
+// other clients that should not read the SQLite file themselves. quotes serve
+// runs it.
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"quotesparser/imgcache"
+	"quotesparser/jobs"
+	"quotesparser/origin"
+	"quotesparser/palette"
+	"quotesparser/recrawl"
+	"quotesparser/render"
+	"quotesparser/schema"
+	"quotesparser/store"
+)
+
+const (
+	// DefaultLimit is how many rows list endpoints return without ?limit=
+	DefaultLimit = 20
+	// MaxLimit caps ?limit=
+	MaxLimit = 500
+)
+
+// Quote is a quote as the API returns it
+type Quote struct {
+	ID       int64  `json:"id"`
+	Text     string `json:"text"`
+	Author   string `json:"author,omitempty"`
+	AuthorID int64  `json:"authorId,omitempty"`
+	Book     string `json:"book,omitempty"`
+	BookID   int64  `json:"bookId,omitempty"`
+	Lang     string `json:"lang,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Origin   string `json:"origin,omitempty"` // book, speech, film, song, anonymous or unknown
+	// Confidence, from 0 to 1, is how sure the parser was of the quote;
+	// left out for quotes never scored
+	Confidence float64   `json:"confidence,omitempty"`
+	ViewCount  int       `json:"viewCount"`
+	Theme      *Theme    `json:"theme,omitempty"`
+	Citation   *Citation `json:"citation,omitempty"`
+	// Provenance is left out for quotes saved before it was recorded
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// Provenance is where and when a quote was read, and by which parser. The
+// file it was read from stays private to the server.
+type Provenance struct {
+	SourceURL     string `json:"sourceUrl,omitempty"`
+	FetchedAt     string `json:"fetchedAt,omitempty"`
+	ParserVersion string `json:"parserVersion"` // e.g. 1000kitap@3f2a9c1
+}
+
+// Citation is what quotes books found on OpenLibrary of a quote's book
+type Citation struct {
+	Work          string `json:"work,omitempty"` // OpenLibrary work, e.g. /works/OL20195335W
+	ISBN          string `json:"isbn,omitempty"`
+	Year          int    `json:"year,omitempty"`          // first published
+	OriginalTitle string `json:"originalTitle,omitempty"` // when the book is a translation
+	CoverURL      string `json:"coverUrl,omitempty"`
+}
+
+// Theme colors a card for a quote after its book's cover, or else its
+// author's portrait, as #rrggbb
+type Theme struct {
+	Background string   `json:"background"`
+	Text       string   `json:"text"`
+	Accent     string   `json:"accent"`
+	Palette    []string `json:"palette"` // dominant colors, most common first
+}
+
+// theme returns the Theme of a stored palette, nil when there is none
+func theme(stored string) *Theme {
+	p, err := palette.Parse(stored)
+	if err != nil || len(p) == 0 {
+		return nil
+	}
+	t := p.Theme()
+	return &Theme{
+		Background: palette.Hex(t.Background),
+		Text:       palette.Hex(t.Text),
+		Accent:     palette.Hex(t.Accent),
+		Palette:    strings.Split(p.String(), ","),
+	}
+}
+
+// Author is an author with the number of quotes attributed to them and,
+// once quotes portraits has cached one, their portrait
+type Author struct {
+	ID       int64     `json:"id"`
+	Name     string    `json:"name"`
+	Link     string    `json:"link,omitempty"`
+	Quotes   int       `json:"quotes"`
+	Portrait *Portrait `json:"portrait,omitempty"`
+	// From Wikidata, once quotes authors enrich found the author there
+	WikidataID  string `json:"wikidataId,omitempty"`
+	BirthYear   int    `json:"birthYear,omitempty"` // negative before the common era
+	DeathYear   int    `json:"deathYear,omitempty"`
+	Nationality string `json:"nationality,omitempty"`
+	Bio         string `json:"bio,omitempty"`
+}
+
+// Portrait is where to get an author's portrait and how to credit it
+type Portrait struct {
+	URL                 string `json:"url"` // add ?width= for a smaller variant
+	Widths              []int  `json:"widths"`
+	License             string `json:"license,omitempty"`
+	LicenseURL          string `json:"licenseUrl,omitempty"`
+	Artist              string `json:"artist,omitempty"`
+	Credit              string `json:"credit,omitempty"`
+	DescriptionURL      string `json:"descriptionUrl,omitempty"`
+	AttributionRequired bool   `json:"attributionRequired"`
+	Theme               *Theme `json:"theme,omitempty"`
+}
+
+// Trivia is a question and its answer, with the wrong answers to offer
+// alongside it, a hint, how hard it is and the picture or clip of a picture
+// or audio round
+type Trivia struct {
+	ID           int64    `json:"id"`
+	Category     string   `json:"category"`
+	Question     string   `json:"question"`
+	Answer       string   `json:"answer"`
+	WrongAnswers []string `json:"wrongAnswers,omitempty"`
+	Hint         string   `json:"hint,omitempty"`
+	Difficulty   string   `json:"difficulty,omitempty"` // easy, medium or hard
+	Image        *Media   `json:"image,omitempty"`
+	Audio        *Media   `json:"audio,omitempty"`
+}
+
+// Media is where to get a question's image or clip: from the API once
+// quotes trivia-media cached it, else from the address it was imported with
+type Media struct {
+	URL         string `json:"url"`
+	ContentType string `json:"contentType,omitempty"`
+	SourceURL   string `json:"sourceUrl"`
+}
+
+// media returns the Media of question id's image or clip of kind at
+// sourceURL, cached with contentType when that is set
+func media(id int64, kind string, sourceURL, contentType sql.NullString) *Media {
+	if sourceURL.String == "" {
+		return nil
+	}
+	m := &Media{URL: sourceURL.String, SourceURL: sourceURL.String}
+	if contentType.Valid {
+		m.URL, m.ContentType = fmt.Sprintf("/trivia/%d/%s", id, kind), contentType.String
+	}
+	return m
+}
+
+// FunFact is a fun fact and, for those fetched from the uselessfacts API,
+// where it comes from
+type FunFact struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Source    string `json:"source,omitempty"`
+	SourceURL string `json:"sourceUrl,omitempty"`
+	Lang      string `json:"lang,omitempty"`
+	Permalink string `json:"permalink,omitempty"`
+}
+
+// Server answers the API's requests from a migrated SQLite database. Set its
+// fields before it serves the first request, which mounts the routes.
+type Server struct {
+	DB        *sql.DB
+	Store     store.Store // on DB, for what the stores share
+	Portraits string      // folder quotes portraits caches into
+	Covers    string      // folder quotes covers caches into
+	// TriviaMedia is the folder quotes trivia-media caches into
+	TriviaMedia string
+	Font        *render.Font // draws quote cards; nil answers them with a 503
+	// ReadOnly serves a public instance: reads only, cacheable for an hour,
+	// and random quotes picked without counting views, so DB may be opened
+	// read-only. The curation routes are not mounted.
+	ReadOnly bool
+	// Keys may call the routes their roles allow: curators the curation
+	// routes, which add, edit and delete quotes, and the dashboard under
+	// /admin/ui/, and admins also the admin routes, which manage the keys and
+	// the schema. With none, none of them are mounted.
+	Keys []Key
+	// KeysFile is where the admin routes save the keys they change; with
+	// none, the changes last until the server stops
+	KeysFile string
+	// Shadow optionally mirrors part of the search traffic to another
+	// backend to compare their answers
+	Shadow *Shadow
+	// Usage optionally counts the requests per route, key, language and
+	// source
+	Usage *Tracker
+	// TriviaSessions tracks the balanced trivia sessions of consumers; the
+	// defaults are used when nil
+	TriviaSessions *TriviaSessions
+	// Daily caches the bundles of /daily, which RunDaily pre-generates; the
+	// defaults are used when nil
+	Daily *DailyBundles
+	// Zones configure when the other daily outputs turn over
+	Zones Zones
+
+	mount sync.Once
+	mux   *http.ServeMux
+	picks dailyPicks
+
+	keysMu sync.RWMutex
+	keys   []Key // Keys as the admin routes changed them
+}
+
+// New returns a Server reading db, serving the cached portraits and covers
+// under the given folders
+func New(db *sql.DB, portraits, covers string) *Server {
+	return &Server{DB: db, Store: store.NewSQLite(db, store.Options{}), Portraits: portraits, Covers: covers}
+}
+
+// routes mounts the routes the server's mode allows
+func (s *Server) routes() {
+	s.mux = http.NewServeMux()
+	if s.TriviaSessions == nil {
+		s.TriviaSessions = &TriviaSessions{}
+	}
+	if s.Daily == nil {
+		s.Daily = &DailyBundles{}
+	}
+	s.mux.HandleFunc("GET /quotes", s.quotes)
+	s.mux.HandleFunc("GET /quotes/random", s.randomQuote)
+	s.mux.HandleFunc("GET /quotes/random.png", s.randomCard)
+	s.mux.HandleFunc("GET /quotes/random.svg", s.randomCard)
+	s.mux.HandleFunc("GET /quotes/daily", s.dailyQuote)
+	s.mux.HandleFunc("GET /quotes/daily.png", s.dailyCard)
+	s.mux.HandleFunc("GET /quotes/daily.svg", s.dailyCard)
+	s.mux.HandleFunc("GET /quotes/{id}", s.quote)
+	s.mux.HandleFunc("GET /authors", s.authors)
+	s.mux.HandleFunc("GET /authors/{id}", s.author)
+	s.mux.HandleFunc("GET /authors/{id}/portrait", s.portrait)
+	s.mux.HandleFunc("GET /books/{id}/cover", s.cover)
+	s.mux.HandleFunc("GET /trivia/random", s.randomTrivia)
+	s.mux.HandleFunc("GET /trivia/{id}/image", s.triviaMedia("image"))
+	s.mux.HandleFunc("GET /trivia/{id}/audio", s.triviaMedia("audio"))
+	s.mux.HandleFunc("GET /funfacts/random", s.randomFunFact)
+	s.mux.HandleFunc("GET /daily", s.daily)
+	s.mux.HandleFunc("GET /sync", s.sync)
+	s.plainRoutes()
+	if !s.ReadOnly {
+		s.deviceRoutes()
+		s.reportRoutes()
+	}
+	s.keys = append([]Key(nil), s.Keys...)
+	if !s.ReadOnly && len(s.keys) > 0 {
+		s.curationRoutes()
+		s.adminRoutes()
+		s.jobRoutes()
+		s.frontierRoutes()
+		s.dashboardRoutes()
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mount.Do(s.routes)
+	if s.ReadOnly && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		// Handlers answering something else every time replace this
+		w.Header().Set("Cache-Control", "public, max-age=3600, stale-while-revalidate=86400")
+	}
+	if s.Usage != nil {
+		s.Usage.track(w, r, http.HandlerFunc(s.serve))
+		return
+	}
+	s.serve(w, r)
+}
+
+// serve routes r, mirroring it to the shadow if it wants it
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if key, err := s.key(r); err == nil {
+		// Reads need no key, but those made with one count under its name
+		noteKey(r, key.Name)
+	}
+	if s.Shadow != nil && s.Shadow.wants(r) {
+		rec := &recorder{ResponseWriter: w, code: http.StatusOK}
+		s.mux.ServeHTTP(rec, r)
+		s.Shadow.mirror(r, rec.code, rec.body.Bytes())
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// notFound is a missing row or image, answered with a 404
+type notFound string
+
+func (e notFound) Error() string { return string(e) }
+
+// badRequest is an error in the request, answered with a 400
+type badRequest string
+
+func (e badRequest) Error() string { return string(e) }
+
+// unavailable is a feature the server was started without, answered with a
+// 503
+type unavailable string
+
+func (e unavailable) Error() string { return string(e) }
+
+// unauthorized is a request to a key-only route without a valid key, answered
+// with a 401
+type unauthorized string
+
+func (e unauthorized) Error() string { return string(e) }
+
+// forbidden is a request with a key whose role does not allow it, answered
+// with a 403
+type forbidden string
+
+func (e forbidden) Error() string { return string(e) }
+
+// conflict is a change that would duplicate another row, answered with a 409
+type conflict string
+
+func (e conflict) Error() string { return string(e) }
+
+// unprocessable is a well-formed request that cannot be served as sent,
+// answered with a 422
+type unprocessable string
+
+func (e unprocessable) Error() string { return string(e) }
+
+// reply writes v as JSON, or err with the status it calls for
+func reply(w http.ResponseWriter, v interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err != nil {
+		code, shown := status(err)
+		if code >= 500 {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.WriteHeader(code)
+		v = map[string]string{"error": shown.Error()}
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+// status returns the HTTP status err calls for and the error to show the
+// client: internal errors are logged and hidden
+func status(err error) (int, error) {
+	var missing notFound
+	var bad badRequest
+	var off unavailable
+	var denied unauthorized
+	var refused forbidden
+	var clash conflict
+	var unusable unprocessable
+	switch {
+	case errors.As(err, &missing):
+		return http.StatusNotFound, err
+	case errors.As(err, &bad):
+		return http.StatusBadRequest, err
+	case errors.As(err, &off):
+		return http.StatusServiceUnavailable, err
+	case errors.As(err, &denied):
+		return http.StatusUnauthorized, err
+	case errors.As(err, &refused):
+		return http.StatusForbidden, err
+	case errors.As(err, &clash), errors.Is(err, schema.ErrDuplicate), errors.Is(err, jobs.ErrFinished):
+		return http.StatusConflict, err
+	case errors.As(err, &unusable):
+		return http.StatusUnprocessableEntity, err
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, jobs.ErrNotFound), errors.Is(err, recrawl.ErrNotFound):
+		return http.StatusNotFound, err
+	case errors.Is(err, jobs.ErrCommand):
+		return http.StatusBadRequest, err
+	}
+	slog.Error("internal error", "err", err)
+	return http.StatusInternalServerError, errors.New("internal error")
+}
+
+// intParam reads a non-negative integer query parameter, def when absent
+func intParam(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, badRequest(fmt.Sprintf("%s must be a non-negative number", name))
+	}
+	return n, nil
+}
+
+// page reads ?limit= and ?offset=
+func page(r *http.Request) (limit, offset int, err error) {
+	if limit, err = intParam(r, "limit", DefaultLimit); err != nil {
+		return 0, 0, err
+	}
+	if limit == 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+	offset, err = intParam(r, "offset", 0)
+	return limit, offset, err
+}
+
+// pathID reads the {id} of the route
+func pathID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, notFound("bad id")
+	}
+	return id, nil
+}
+
+const quoteColumns = `q.id, q.text, q.author, q.lang, q.viewCount, q.authorId, q.bookId, COALESCE(c.palette, p.palette), src.name, q.origin, q.confidence,
+	bk.work, bk.isbn, bk.publishYear, bk.originalTitle, bk.coverUrl, q.sourceUrl, q.fetchedAt, q.parserVersion
+	FROM quotes q
+	LEFT JOIN sources src ON src.id = q.sourceId
+	LEFT JOIN books bk ON bk.id = q.bookId
+	LEFT JOIN bookCovers c ON c.bookId = q.bookId AND c.status = 'ok'
+	LEFT JOIN authorPortraits p ON p.authorId = q.authorId AND p.status = 'ok'`
+
+// storeFilter reads ?lang=, ?author=, ?origin=, ?maxChars= and
+// ?minConfidence= into a store.Filter
+func storeFilter(r *http.Request) (store.Filter, error) {
+	maxChars, err := intParam(r, "maxChars", 0)
+	if err != nil {
+		return store.Filter{}, err
+	}
+	var minConfidence float64
+	if v := r.URL.Query().Get("minConfidence"); v != "" {
+		if minConfidence, err = strconv.ParseFloat(v, 64); err != nil || minConfidence < 0 || minConfidence > 1 {
+			return store.Filter{}, badRequest("minConfidence must be a number from 0 to 1")
+		}
+	}
+	var kind origin.Type
+	if v := r.URL.Query().Get("origin"); v != "" {
+		if kind, err = origin.Parse(v); err != nil {
+			return store.Filter{}, badRequest(err.Error())
+		}
+	}
+	return store.Filter{Lang: r.URL.Query().Get("lang"), Author: r.URL.Query().Get("author"), Origin: kind, MaxChars: maxChars, MinConfidence: minConfidence}, nil
+}
+
+// quoteFilter narrows quotes like f does in the stores. The author matches
+// "Author" and "Author - Book" attributions.
+func quoteFilter(f store.Filter) (string, []interface{}) {
+	where := " WHERE 1 = 1"
+	var args []interface{}
+	if f.Lang != "" {
+		where += " AND q.lang = ?"
+		args = append(args, f.Lang)
+	}
+	if f.Author != "" {
+		prefix := f.Author + " - "
+		where += " AND (q.author = ? OR substr(q.author, 1, ?) = ?)"
+		args = append(args, f.Author, utf8.RuneCountInString(prefix), prefix)
+	}
+	if f.Origin != "" {
+		where += " AND q.origin = ?"
+		args = append(args, string(f.Origin))
+	}
+	if f.MaxChars > 0 {
+		where += " AND length(q.text) <= ?"
+		args = append(args, f.MaxChars)
+	}
+	if f.MinConfidence > 0 {
+		where += " AND q.confidence >= ?"
+		args = append(args, f.MinConfidence)
+	}
+	return where, args
+}
+
+func scanQuotes(rows *sql.Rows) ([]Quote, error) {
+	defer rows.Close()
+	quotes := []Quote{}
+	for rows.Next() {
+		var q Quote
+		var author, lang, colors, source, kind sql.NullString
+		var views, authorID, bookID sql.NullInt64
+		var confidence sql.NullFloat64
+		var work, isbn, originalTitle, coverURL sql.NullString
+		var year sql.NullInt64
+		var sourceURL, fetchedAt, parser sql.NullString
+		if err := rows.Scan(&q.ID, &q.Text, &author, &lang, &views, &authorID, &bookID, &colors, &source, &kind, &confidence,
+			&work, &isbn, &year, &originalTitle, &coverURL, &sourceURL, &fetchedAt, &parser); err != nil {
+			return nil, fmt.Errorf("failed to read quotes: %v", err)
+		}
+		if parser.Valid {
+			q.Provenance = &Provenance{SourceURL: sourceURL.String, FetchedAt: fetchedAt.String, ParserVersion: parser.String}
+		}
+		if work.Valid {
+			q.Citation = &Citation{Work: work.String, ISBN: isbn.String, Year: int(year.Int64), OriginalTitle: originalTitle.String, CoverURL: coverURL.String}
+		}
+		q.Author, q.Book = schema.SplitAttribution(author.String)
+		q.Lang, q.Source, q.Origin, q.ViewCount = lang.String, source.String, kind.String, int(views.Int64)
+		q.AuthorID, q.BookID, q.Confidence = authorID.Int64, bookID.Int64, confidence.Float64
+		q.Theme = theme(colors.String)
+		quotes = append(quotes, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read quotes: %v", err)
+	}
+	return quotes, nil
+}
+
+// GET /quotes?author=&lang=&origin=&maxChars=&minConfidence=&limit=&offset=
+func (s *Server) quotes(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := page(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	f, err := storeFilter(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	where, args := quoteFilter(f)
+	rows, err := s.DB.Query("SELECT "+quoteColumns+where+" ORDER BY q.id LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		reply(w, nil, fmt.Errorf("failed to read quotes: %v", err))
+		return
+	}
+	quotes, err := scanQuotes(rows)
+	reply(w, quotes, err)
+}
+
+// findQuote returns the first quote of query, or notFound
+func (s *Server) findQuote(query string, args ...interface{}) (Quote, error) {
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to read quotes: %v", err)
+	}
+	quotes, err := scanQuotes(rows)
+	if err != nil {
+		return Quote{}, err
+	}
+	if len(quotes) == 0 {
+		return Quote{}, notFound("no quote")
+	}
+	return quotes[0], nil
+}
+
+// oneQuote answers r with the first quote of query, or a 404
+func (s *Server) oneQuote(w http.ResponseWriter, r *http.Request, query string, args ...interface{}) {
+	q, err := s.findQuote(query, args...)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	noteQuote(r, q.Lang, q.Source)
+	reply(w, q, nil)
+}
+
+// GET /quotes/random?author=&lang=&origin=&maxChars=&minConfidence= picks one of the least shown quotes and
+// counts the view, so a rotating display goes through them all before
+// repeating one
+func (s *Server) randomQuote(w http.ResponseWriter, r *http.Request) {
+	f, err := storeFilter(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	id, err := s.pickRandom(f)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	s.oneQuote(w, r, "SELECT "+quoteColumns+" WHERE q.id = ?", id)
+}
+
+// pickRandom returns the ID of a quote matching f: one of the least shown,
+// counting the view, or on a ReadOnly server any of them, without writing
+func (s *Server) pickRandom(f store.Filter) (int64, error) {
+	if !s.ReadOnly {
+		q, err := s.Store.RandomQuote(f)
+		if errors.Is(err, store.ErrNotFound) {
+			return 0, notFound("no quote")
+		}
+		return q.ID, err
+	}
+	where, args := quoteFilter(f)
+	var id int64
+	err := s.DB.QueryRow("SELECT q.id FROM quotes q"+where+" ORDER BY RANDOM() LIMIT 1", args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, notFound("no quote")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read quotes: %v", err)
+	}
+	return id, nil
+}
+
+// GET /quotes/daily?author=&lang=&origin=&maxChars=&minConfidence=&tz=&date= answers the quote of the day
+// in the time zone tz (Zones.Quote by default), the same all day
+func (s *Server) dailyQuote(w http.ResponseWriter, r *http.Request) {
+	day, err := store.Day(r.URL.Query().Get("date"), requestTZ(r, s.Zones.Quote))
+	if err != nil {
+		reply(w, nil, badRequest(err.Error()))
+		return
+	}
+	f, err := storeFilter(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	q, err := s.pickDaily(f, day)
+	if errors.Is(err, store.ErrNotFound) {
+		err = notFound("no quote")
+	}
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	s.oneQuote(w, r, "SELECT "+quoteColumns+" WHERE q.id = ?", q.ID)
+}
+
+// GET /quotes/{id}
+func (s *Server) quote(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	s.oneQuote(w, r, "SELECT "+quoteColumns+" WHERE q.id = ?", id)
+}
+
+// GET /quotes/random.png?w=&h=&margin=&size=&minSize=&dither=&theme=&author=&lang=&origin=&maxChars=&minConfidence=
+// draws the next least shown quote as a card for a picture frame, counting
+// the view like /quotes/random. Long quotes shrink from size to minSize to
+// fit; maxChars=fit only picks quotes short enough to fit at minSize.
+// dither=1 reduces the card to black and white for e-ink panels;
+// theme=cover colors it after the book cover or portrait.
+// /quotes/random.svg answers the same card as an SVG.
+func (s *Server) randomCard(w http.ResponseWriter, r *http.Request) {
+	o, f, err := s.cardOptions(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	id, err := s.pickRandom(f)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	s.writeCard(w, r, id, o, time.Time{})
+}
+
+// GET /quotes/daily.png?tz=&date= and the options of /quotes/random.png draw
+// the quote of the day as a card, for displays showing one quote a day: the
+// same all day in the time zone tz (Zones.Display by default), and cacheable
+// until its midnight, so a display refreshing then shows the next one.
+// /quotes/daily.svg answers the same card as an SVG.
+func (s *Server) dailyCard(w http.ResponseWriter, r *http.Request) {
+	day, err := store.Day(r.URL.Query().Get("date"), requestTZ(r, s.Zones.Display))
+	if err != nil {
+		reply(w, nil, badRequest(err.Error()))
+		return
+	}
+	o, f, err := s.cardOptions(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	q, err := s.pickDaily(f, day)
+	if errors.Is(err, store.ErrNotFound) {
+		err = notFound("no quote")
+	}
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	if r.URL.Query().Get("date") == "" {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(time.Until(store.NextMidnight(day)).Seconds())+1))
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+	}
+	s.writeCard(w, r, q.ID, o, day)
+}
+
+// cardOptions reads how a request wants its card drawn, and which quotes
+// it may show
+func (s *Server) cardOptions(r *http.Request) (render.Options, store.Filter, error) {
+	var o render.Options
+	var f store.Filter
+	if s.Font == nil {
+		return o, f, unavailable("no font to draw cards with; start the server with --font")
+	}
+	var err error
+	for _, p := range []struct {
+		name string
+		to   *int
+	}{{"w", &o.Width}, {"h", &o.Height}, {"margin", &o.Margin}} {
+		if *p.to, err = intParam(r, p.name, 0); err != nil {
+			return o, f, err
+		}
+	}
+	for _, p := range []struct {
+		name string
+		to   *float64
+	}{{"size", &o.Size}, {"minSize", &o.MinSize}} {
+		n, err := intParam(r, p.name, 0)
+		if err != nil {
+			return o, f, err
+		}
+		*p.to = float64(n)
+	}
+	o.Dither = r.URL.Query().Get("dither") == "1"
+	if err := o.Check(); err != nil {
+		return o, f, badRequest(err.Error())
+	}
+
+	fit := r.URL.Query().Get("maxChars") == "fit"
+	if fit {
+		// The other filters still apply, with the card's capacity for maxChars
+		r = r.Clone(r.Context())
+		query := r.URL.Query()
+		query.Del("maxChars")
+		r.URL.RawQuery = query.Encode()
+	}
+	if f, err = storeFilter(r); err != nil {
+		return o, f, err
+	}
+	if fit {
+		f.MaxChars = render.Capacity(s.Font, o)
+	}
+	return o, f, nil
+}
+
+// writeCard draws the quote of id with o, as a PNG or for .svg paths an SVG,
+// dated day unless it is zero
+func (s *Server) writeCard(w http.ResponseWriter, r *http.Request, id int64, o render.Options, day time.Time) {
+	full, err := s.findQuote("SELECT "+quoteColumns+" WHERE q.id = ?", id)
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		reply(w, nil, err)
+		return
+	}
+	noteQuote(r, full.Lang, full.Source)
+	if r.URL.Query().Get("theme") == "cover" && full.Theme != nil {
+		if p, err := palette.Parse(strings.Join(full.Theme.Palette, ",")); err == nil {
+			t := p.Theme()
+			o.Theme = &t
+		}
+	}
+
+	card := render.Card{Text: full.Text, Author: full.Author, Book: full.Book, Lang: full.Lang, Date: day}
+	var buf bytes.Buffer
+	if strings.HasSuffix(r.URL.Path, ".svg") {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		err = render.SVG(&buf, s.Font, card, o)
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+		err = render.PNG(&buf, s.Font, card, o)
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		w.Header().Del("Cache-Control")
+		reply(w, nil, err)
+		return
+	}
+	w.Header().Set("X-Quote-Id", strconv.FormatInt(full.ID, 10))
+	w.Write(buf.Bytes())
+}
+
+const authorQuery = `
+	SELECT a.id, a.name, a.link,
+		(SELECT COUNT(*) FROM quotes q WHERE q.authorId = a.id),
+		p.widths, p.license, p.licenseUrl, p.artist, p.credit, p.descriptionUrl, p.attributionRequired, p.palette,
+		a.wikidataId, a.birthYear, a.deathYear, a.nationality, a.bio
+	FROM authors a
+	LEFT JOIN authorPortraits p ON p.authorId = a.id AND p.status = 'ok'`
+
+func scanAuthors(rows *sql.Rows) ([]Author, error) {
+	defer rows.Close()
+	authors := []Author{}
+	for rows.Next() {
+		var a Author
+		var link, widths, license, licenseURL, artist, credit, description, colors sql.NullString
+		var wikidataID, nationality, bio sql.NullString
+		var birth, death sql.NullInt64
+		var attribution sql.NullBool
+		if err := rows.Scan(&a.ID, &a.Name, &link, &a.Quotes,
+			&widths, &license, &licenseURL, &artist, &credit, &description, &attribution, &colors,
+			&wikidataID, &birth, &death, &nationality, &bio); err != nil {
+			return nil, fmt.Errorf("failed to read authors: %v", err)
+		}
+		a.Link = link.String
+		a.WikidataID, a.BirthYear, a.DeathYear = wikidataID.String, int(birth.Int64), int(death.Int64)
+		a.Nationality, a.Bio = nationality.String, bio.String
+		if ws := parseWidths(widths.String); len(ws) > 0 {
+			a.Portrait = &Portrait{
+				URL:                 fmt.Sprintf("/authors/%d/portrait", a.ID),
+				Widths:              ws,
+				License:             license.String,
+				LicenseURL:          licenseURL.String,
+				Artist:              artist.String,
+				Credit:              credit.String,
+				DescriptionURL:      description.String,
+				AttributionRequired: attribution.Bool,
+				Theme:               theme(colors.String),
+			}
+		}
+		authors = append(authors, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read authors: %v", err)
+	}
+	return authors, nil
+}
+
+// GET /authors?limit=&offset=
+func (s *Server) authors(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := page(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	rows, err := s.DB.Query(authorQuery+" ORDER BY a.name LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		reply(w, nil, fmt.Errorf("failed to read authors: %v", err))
+		return
+	}
+	authors, err := scanAuthors(rows)
+	reply(w, authors, err)
+}
+
+// GET /authors/{id}
+func (s *Server) author(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	rows, err := s.DB.Query(authorQuery+" WHERE a.id = ?", id)
+	if err != nil {
+		reply(w, nil, fmt.Errorf("failed to read authors: %v", err))
+		return
+	}
+	authors, err := scanAuthors(rows)
+	if err == nil && len(authors) == 0 {
+		err = notFound("no author")
+	}
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	reply(w, authors[0], nil)
+}
+
+// GET /authors/{id}/portrait?width=
+func (s *Server) portrait(w http.ResponseWriter, r *http.Request) {
+	s.image(w, r, s.Portraits, "SELECT widths FROM authorPortraits WHERE authorId = ? AND status = 'ok'")
+}
+
+// GET /books/{id}/cover?width=
+func (s *Server) cover(w http.ResponseWriter, r *http.Request) {
+	s.image(w, r, s.Covers, "SELECT widths FROM bookCovers WHERE bookId = ? AND status = 'ok'")
+}
+
+// image serves the cached variant of the {id} row's image closest to
+// ?width=: the smallest at least that wide, else the largest. Without
+// ?width= the largest is served.
+func (s *Server) image(w http.ResponseWriter, r *http.Request, dir, query string) {
+	id, err := pathID(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	want, err := intParam(r, "width", 0)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	var widths sql.NullString
+	err = s.DB.QueryRow(query, id).Scan(&widths)
+	if err == sql.ErrNoRows || (err == nil && widths.String == "") {
+		reply(w, nil, notFound("no image"))
+		return
+	}
+	if err != nil {
+		reply(w, nil, fmt.Errorf("failed to read images: %v", err))
+		return
+	}
+
+	path := imgcache.Path(dir, id, pickWidth(parseWidths(widths.String), want))
+	if _, err := os.Stat(path); err != nil {
+		// Recorded but pruned or never copied to this machine
+		reply(w, nil, notFound("no image"))
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeFile(w, r, path)
+}
+
+// parseWidths reads the widths column of authorPortraits and bookCovers,
+// skipping anything that is not a width
+func parseWidths(s string) []int {
+	var widths []int
+	for _, part := range strings.Split(s, ",") {
+		if w, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && w > 0 {
+			widths = append(widths, w)
+		}
+	}
+	return widths
+}
+
+// pickWidth returns the smallest of widths at least want wide, or the
+// largest when none is or want is 0
+func pickWidth(widths []int, want int) int {
+	best, largest := 0, 0
+	for _, w := range widths {
+		largest = max(largest, w)
+		if want > 0 && w >= want && (best == 0 || w < best) {
+			best = w
+		}
+	}
+	if best == 0 {
+		return largest
+	}
+	return best
+}
+
+// GET /trivia/random?category=&mode=&consumer= picks a question uniformly
+// at random, or with mode=balanced spread over the categories and
+// difficulties of the consumer's session: see TriviaSessions. The category
+// may be any spelling of it.
+func (s *Server) randomTrivia(w http.ResponseWriter, r *http.Request) {
+	category, err := s.triviaCategory(r.URL.Query().Get("category"))
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	var t Trivia
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "random":
+		if category != "" {
+			t, err = s.findTrivia(" WHERE t.category = ? ORDER BY RANDOM() LIMIT 1", category)
+		} else {
+			t, err = s.findTrivia(" ORDER BY RANDOM() LIMIT 1")
+		}
+	case "balanced":
+		t, err = s.balancedTrivia(s.consumer(r), category)
+	default:
+		err = badRequest(fmt.Sprintf("unknown mode %q (random, balanced)", mode))
+	}
+	if err == nil {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	reply(w, t, err)
+}
+
+// triviaCategory returns the category questions are filed under for a
+// spelling of it: the spelling itself if questions use it, else its
+// canonical name
+func (s *Server) triviaCategory(spelling string) (string, error) {
+	if spelling == "" {
+		return "", nil
+	}
+	var n int
+	if err := s.DB.QueryRow("SELECT COUNT(*) FROM (SELECT 1 FROM trivia WHERE category = ? LIMIT 1)", spelling).Scan(&n); err != nil {
+		return "", fmt.Errorf("failed to read trivia: %v", err)
+	}
+	if n > 0 {
+		return spelling, nil
+	}
+	categories, err := schema.TriviaCategoryMap(s.DB)
+	if err != nil {
+		return "", err
+	}
+	return categories.Canonical(spelling), nil
+}
+
+// findTrivia returns the first question of the trivia table, aliased t,
+// that the rest of the query picks
+func (s *Server) findTrivia(rest string, args ...interface{}) (Trivia, error) {
+	t, err := scanTrivia(s.DB.QueryRow("SELECT "+triviaColumns+rest, args...))
+	if err == sql.ErrNoRows {
+		return Trivia{}, notFound("no trivia")
+	}
+	return t, err
+}
+
+// triviaColumns selects the columns scanTrivia reads, with the media cached
+// for each question
+const triviaColumns = `t.id, t.category, t.question, t.answer, t.wrongAnswers, t.hint, t.difficulty, t.imageUrl, im.contentType, t.audioUrl, au.contentType
+	FROM trivia t
+	LEFT JOIN triviaMedia im ON im.triviaId = t.id AND im.kind = 'image' AND im.status = 'ok' AND im.sourceUrl = t.imageUrl
+	LEFT JOIN triviaMedia au ON au.triviaId = t.id AND au.kind = 'audio' AND au.status = 'ok' AND au.sourceUrl = t.audioUrl`
+
+// scanTrivia reads a question selected with triviaColumns; sql.ErrNoRows is
+// returned as is
+func scanTrivia(row interface{ Scan(...interface{}) error }) (Trivia, error) {
+	var t Trivia
+	var wrong, hint, difficulty, image, imageType, audio, audioType sql.NullString
+	err := row.Scan(&t.ID, &t.Category, &t.Question, &t.Answer, &wrong, &hint, &difficulty, &image, &imageType, &audio, &audioType)
+	if err == sql.ErrNoRows {
+		return Trivia{}, err
+	}
+	if err != nil {
+		return Trivia{}, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	if t.WrongAnswers, err = store.DecodeAnswers(wrong.String); err != nil {
+		return Trivia{}, err
+	}
+	t.Hint, t.Difficulty = hint.String, difficulty.String
+	t.Image, t.Audio = media(t.ID, "image", image, imageType), media(t.ID, "audio", audio, audioType)
+	return t, nil
+}
+
+// GET /trivia/{id}/image and /trivia/{id}/audio
+func (s *Server) triviaMedia(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			reply(w, nil, err)
+			return
+		}
+		var file string
+		err = s.DB.QueryRow("SELECT file FROM triviaMedia WHERE triviaId = ? AND kind = ? AND status = 'ok'", id, kind).Scan(&file)
+		if err == sql.ErrNoRows {
+			reply(w, nil, notFound("no "+kind))
+			return
+		}
+		if err != nil {
+			reply(w, nil, fmt.Errorf("failed to read trivia media: %v", err))
+			return
+		}
+		path := filepath.Join(s.TriviaMedia, filepath.FromSlash(file))
+		if _, err := os.Stat(path); err != nil {
+			// Recorded but never copied to this machine
+			reply(w, nil, notFound("no "+kind))
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		http.ServeFile(w, r, path)
+	}
+}
+
+// funFactColumns selects the columns scanFunFact reads
+const funFactColumns = "f.id, f.text, f.source, f.sourceUrl, f.lang, f.permalink FROM funFacts f"
+
+// scanFunFact reads a fun fact selected with funFactColumns
+func scanFunFact(row interface{ Scan(...interface{}) error }) (FunFact, error) {
+	var f FunFact
+	var source, sourceURL, lang, permalink sql.NullString
+	err := row.Scan(&f.ID, &f.Text, &source, &sourceURL, &lang, &permalink)
+	f.Source, f.SourceURL, f.Lang, f.Permalink = source.String, sourceURL.String, lang.String, permalink.String
+	return f, err
+}
+
+// GET /funfacts/random
+func (s *Server) randomFunFact(w http.ResponseWriter, r *http.Request) {
+	f, err := scanFunFact(s.DB.QueryRow("SELECT " + funFactColumns + " ORDER BY RANDOM() LIMIT 1"))
+	switch {
+	case err == sql.ErrNoRows:
+		reply(w, nil, notFound("no fun fact"))
+	case err != nil:
+		reply(w, nil, fmt.Errorf("failed to read fun facts: %v", err))
+	default:
+		w.Header().Set("Cache-Control", "no-store")
+		reply(w, f, nil)
+	}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// minSecret is the shortest key secret LoadKeys accepts
+const minSecret = 16
+
+// Role is what a key may do. Each role may do all the ones before it may.
+type Role int
+
+const (
+	Reader  Role = iota // query, counted under the key's name
+	Curator             // also add, edit and delete quotes
+	Admin               // also manage the keys and the schema
+)
+
+var roleNames = []string{"reader", "curator", "admin"}
+
+func (r Role) String() string {
+	if r < 0 || int(r) >= len(roleNames) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// ParseRole reads a role by its name
+func ParseRole(name string) (Role, error) {
+	for i, n := range roleNames {
+		if n == name {
+			return Role(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role %q (want reader, curator or admin)", name)
+}
+
+func (r Role) MarshalText() ([]byte, error) { return []byte(r.String()), nil }
+
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// Key lets whoever holds Secret call the routes its Role allows, logged as
+// Name
+type Key struct {
+	Name   string
+	Role   Role
+	Secret string
+}
+
+// LoadKeys reads a keys file: one "name role secret" a line, with blank
+// lines and # comments ignored. A "name secret" line, as written before keys
+// had roles, is a curator's.
+func LoadKeys(path string) ([]Key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var keys []Key
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		k := Key{Name: fields[0], Role: Curator}
+		switch len(fields) {
+		case 2:
+			k.Secret = fields[1]
+		case 3:
+			if k.Role, err = ParseRole(fields[1]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+			}
+			k.Secret = fields[2]
+		default:
+			return nil, fmt.Errorf("%s:%d: want \"name role secret\"", path, n)
+		}
+		if len(k.Secret) < minSecret {
+			return nil, fmt.Errorf("%s:%d: secret of %s is shorter than %d characters", path, n, k.Name, minSecret)
+		}
+		keys = append(keys, k)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return keys, nil
+}
+
+// SaveKeys writes keys to path as LoadKeys reads them, replacing the file
+// only once it is complete
+func SaveKeys(path string, keys []Key) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	fmt.Fprintln(w, "# name role secret, written by quotes serve")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s %s %s\n", k.Name, k.Role, k.Secret)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+	return nil
+}
+
+// newSecret returns a random secret for a key made by an admin
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to make a secret: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// key returns the key r carries, as "Authorization: Bearer <secret>",
+// "X-API-Key: <secret>" or, from browsers on the dashboard, the password of
+// HTTP basic auth, or unauthorized
+func (s *Server) key(r *http.Request) (Key, error) {
+	secret := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		secret = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	} else if _, password, ok := r.BasicAuth(); ok {
+		secret = password
+	}
+	if secret == "" {
+		return Key{}, unauthorized("missing API key")
+	}
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(k.Secret)) == 1 {
+			return k, nil
+		}
+	}
+	return Key{}, unauthorized("invalid API key")
+}
+
+// withRole answers requests without a valid key with a 401 and those whose
+// key's role is below role with a 403, and passes the others to h with the
+// key's name
+func (s *Server) withRole(role Role, h func(w http.ResponseWriter, r *http.Request, key string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := s.key(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="quotes"`)
+			reply(w, nil, err)
+			return
+		}
+		noteKey(r, key.Name)
+		if key.Role < role {
+			reply(w, nil, forbidden(fmt.Sprintf("%s keys may not do this, it takes a %s key", key.Role, role)))
+			return
+		}
+		h(w, r, key.Name)
+	}
+}
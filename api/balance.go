@@ -0,0 +1,213 @@
+package api
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TriviaSessions remembers what GET /trivia/random?mode=balanced served each
+// consumer, so their session spreads over the categories and difficulties
+// rather than following the table's sizes, and does not repeat a question
+// they were recently asked. It is kept in memory: a restart starts every
+// session over.
+type TriviaSessions struct {
+	// Recent is how many of a consumer's last questions are not asked again
+	// while their category has others; 0 means 100
+	Recent int
+	// Idle ends a session: a consumer asking nothing for this long starts
+	// over; 0 means an hour
+	Idle time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*triviaSession
+	swept    time.Time
+}
+
+type triviaSession struct {
+	categories   map[string]int // questions served per category
+	difficulties map[string]int // and per difficulty, "" for unknown
+	recent       []int64        // IDs of the last questions, oldest first
+	last         time.Time
+}
+
+func (ts *TriviaSessions) recentSize() int {
+	if ts.Recent > 0 {
+		return ts.Recent
+	}
+	return 100
+}
+
+func (ts *TriviaSessions) idle() time.Duration {
+	if ts.Idle > 0 {
+		return ts.Idle
+	}
+	return time.Hour
+}
+
+// session returns a copy of consumer's session, empty when it has none or
+// it ended
+func (ts *TriviaSessions) session(consumer string, now time.Time) triviaSession {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.sweep(now)
+	s, ok := ts.sessions[consumer]
+	if !ok || now.Sub(s.last) > ts.idle() {
+		return triviaSession{categories: map[string]int{}, difficulties: map[string]int{}}
+	}
+	c := triviaSession{
+		categories:   make(map[string]int, len(s.categories)),
+		difficulties: make(map[string]int, len(s.difficulties)),
+		recent:       append([]int64(nil), s.recent...),
+	}
+	for k, v := range s.categories {
+		c.categories[k] = v
+	}
+	for k, v := range s.difficulties {
+		c.difficulties[k] = v
+	}
+	return c
+}
+
+// served records that consumer was asked question id
+func (ts *TriviaSessions) served(consumer string, id int64, category, difficulty string, now time.Time) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.sessions == nil {
+		ts.sessions = make(map[string]*triviaSession)
+	}
+	s, ok := ts.sessions[consumer]
+	if !ok || now.Sub(s.last) > ts.idle() {
+		s = &triviaSession{categories: map[string]int{}, difficulties: map[string]int{}}
+		ts.sessions[consumer] = s
+	}
+	s.categories[category]++
+	s.difficulties[difficulty]++
+	s.recent = append(s.recent, id)
+	if n := ts.recentSize(); len(s.recent) > n {
+		s.recent = append(s.recent[:0], s.recent[len(s.recent)-n:]...)
+	}
+	s.last = now
+}
+
+// sweep forgets the ended sessions, at most once per Idle
+func (ts *TriviaSessions) sweep(now time.Time) {
+	if now.Sub(ts.swept) < ts.idle() {
+		return
+	}
+	for consumer, s := range ts.sessions {
+		if now.Sub(s.last) > ts.idle() {
+			delete(ts.sessions, consumer)
+		}
+	}
+	ts.swept = now
+}
+
+// consumer names who a balanced session is for: ?consumer=, else the API
+// key the request came with, else the client's address
+func (s *Server) consumer(r *http.Request) string {
+	if c := strings.TrimSpace(r.URL.Query().Get("consumer")); c != "" {
+		return "consumer:" + c
+	}
+	if key, err := s.key(r); err == nil {
+		return "key:" + key.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// triviaBucket is the questions of one category and difficulty
+type triviaBucket struct {
+	category, difficulty string
+}
+
+// balancedTrivia picks a question for consumer: from the category they were
+// asked least this session, then the difficulty they were asked least within
+// it, ties broken at random, and not one of their recent questions unless
+// the whole category and difficulty was
+func (s *Server) balancedTrivia(consumer, category string) (Trivia, error) {
+	query := "SELECT category, COALESCE(difficulty, '') FROM trivia"
+	var args []interface{}
+	if category != "" {
+		query += " WHERE category = ?"
+		args = append(args, category)
+	}
+	rows, err := s.DB.Query(query+" GROUP BY 1, 2", args...)
+	if err != nil {
+		return Trivia{}, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	var buckets []triviaBucket
+	for rows.Next() {
+		var b triviaBucket
+		if err := rows.Scan(&b.category, &b.difficulty); err != nil {
+			rows.Close()
+			return Trivia{}, fmt.Errorf("failed to read trivia: %v", err)
+		}
+		buckets = append(buckets, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Trivia{}, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	if len(buckets) == 0 {
+		return Trivia{}, notFound("no trivia")
+	}
+
+	now := time.Now()
+	session := s.TriviaSessions.session(consumer, now)
+	buckets = least(buckets, func(b triviaBucket) int { return session.categories[b.category] })
+	pick := buckets[rand.Intn(len(buckets))].category
+	var levels []triviaBucket
+	for _, b := range buckets {
+		if b.category == pick {
+			levels = append(levels, b)
+		}
+	}
+	levels = least(levels, func(b triviaBucket) int { return session.difficulties[b.difficulty] })
+	b := levels[rand.Intn(len(levels))]
+
+	where := " WHERE t.category = ? AND COALESCE(t.difficulty, '') = ?"
+	args = []interface{}{b.category, b.difficulty}
+	t, err := s.findTrivia(where+notIn("t.id", session.recent, &args)+" ORDER BY RANDOM() LIMIT 1", args...)
+	if _, none := err.(notFound); none && len(session.recent) > 0 {
+		t, err = s.findTrivia(where+" ORDER BY RANDOM() LIMIT 1", b.category, b.difficulty)
+	}
+	if err != nil {
+		return Trivia{}, err
+	}
+	s.TriviaSessions.served(consumer, t.ID, t.Category, b.difficulty, now)
+	return t, nil
+}
+
+// least returns the buckets scoring lowest
+func least(buckets []triviaBucket, score func(triviaBucket) int) []triviaBucket {
+	var out []triviaBucket
+	for _, b := range buckets {
+		switch {
+		case len(out) == 0 || score(b) < score(out[0]):
+			out = []triviaBucket{b}
+		case score(b) == score(out[0]):
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// notIn returns " AND column NOT IN (...)" for ids, adding them to args, or
+// nothing without ids
+func notIn(column string, ids []int64, args *[]interface{}) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	for _, id := range ids {
+		*args = append(*args, id)
+	}
+	return fmt.Sprintf(" AND %s NOT IN (%s)", column, strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "))
+}
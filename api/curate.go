@@ -0,0 +1,225 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"quotesparser/dedup"
+	"quotesparser/origin"
+	"quotesparser/schema"
+)
+
+// QuoteEdit is the body of POST /quotes and PATCH /quotes/{id}. PATCH only
+// changes the fields given. Without an origin, the quote is classified from
+// its attribution, again whenever the author or book change. An empty author,
+// or one such as "Anonymous", makes the quote anonymous.
+type QuoteEdit struct {
+	Text   *string `json:"text"`
+	Author *string `json:"author"`
+	Book   *string `json:"book"`
+	Lang   *string `json:"lang"`
+	Origin *string `json:"origin"`
+}
+
+// The curation routes change quotes for whoever holds a curator's or an
+// admin's key
+func (s *Server) curationRoutes() {
+	s.mux.HandleFunc("POST /quotes", s.withRole(Curator, s.idempotent(s.addQuote)))
+	s.mux.HandleFunc("PATCH /quotes/{id}", s.withRole(Curator, s.idempotent(s.editQuote)))
+	s.mux.HandleFunc("DELETE /quotes/{id}", s.withRole(Curator, s.idempotent(s.deleteQuote)))
+}
+
+// readEdit decodes the body of a curation request
+func readEdit(w http.ResponseWriter, r *http.Request) (QuoteEdit, error) {
+	var e QuoteEdit
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&e); err != nil {
+		return e, badRequest(fmt.Sprintf("bad quote: %v", err))
+	}
+	if e.Text != nil {
+		*e.Text = strings.TrimSpace(*e.Text)
+		if *e.Text == "" {
+			return e, badRequest("text must not be empty")
+		}
+	}
+	if e.Author != nil && origin.IsAnonymousName(*e.Author) {
+		// Proverbs and anonymous quotes have no author
+		*e.Author = ""
+	}
+	if e.Origin != nil {
+		kind, err := origin.Parse(*e.Origin)
+		if err != nil {
+			return e, badRequest(err.Error())
+		}
+		*e.Origin = string(kind)
+	}
+	return e, nil
+}
+
+// POST /quotes adds a quote, answering it with a 201, or with a 200 when the
+// same text is already there
+func (s *Server) addQuote(w http.ResponseWriter, r *http.Request, key string) {
+	e, err := readEdit(w, r)
+	if err == nil && e.Text == nil {
+		err = badRequest("text is required")
+	}
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+
+	var id int64
+	hash := dedup.TextHashIn(*e.Text, value(e.Lang))
+	var dup *schema.DuplicateError
+	if err := schema.CheckUnique(s.DB, hash, 0); errors.As(err, &dup) {
+		s.oneQuote(w, r, "SELECT "+quoteColumns+" WHERE q.id = ?", dup.ID)
+		return
+	} else if err != nil {
+		reply(w, nil, err)
+		return
+	}
+
+	err = s.linked(func(tx *sql.Tx) error {
+		res, err := tx.Exec("INSERT INTO quotes (text, author, lang, viewCount, textHash, origin) VALUES (?, ?, ?, 0, ?, ?)",
+			*e.Text, nullable(schema.JoinAttribution(value(e.Author), value(e.Book))), nullable(value(e.Lang)), hash, nullable(value(e.Origin)))
+		if err != nil {
+			return fmt.Errorf("failed to add quote: %v", err)
+		}
+		id, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	slog.Info("added quote", "by", key, "id", id)
+
+	q, err := s.findQuote("SELECT "+quoteColumns+" WHERE q.id = ?", id)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/quotes/%d", id))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(q)
+}
+
+// PATCH /quotes/{id} changes a quote's text, author, book, language or
+// origin, relinking it to its author and book
+func (s *Server) editQuote(w http.ResponseWriter, r *http.Request, key string) {
+	id, err := pathID(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	e, err := readEdit(w, r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	q, err := s.findQuote("SELECT "+quoteColumns+" WHERE q.id = ?", id)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+
+	text, author, book, lang, kind := q.Text, q.Author, q.Book, q.Lang, q.Origin
+	if e.Text != nil {
+		text = *e.Text
+	}
+	if e.Author != nil {
+		author = strings.TrimSpace(*e.Author)
+	}
+	if e.Book != nil {
+		book = strings.TrimSpace(*e.Book)
+	}
+	if e.Lang != nil {
+		lang = strings.TrimSpace(*e.Lang)
+	}
+	if e.Origin != nil {
+		kind = *e.Origin
+	} else if author != q.Author || book != q.Book {
+		kind = "" // classified again by linked
+	}
+
+	hash := dedup.TextHashIn(text, lang)
+	if err := schema.CheckUnique(s.DB, hash, id); err != nil {
+		reply(w, nil, err)
+		return
+	}
+
+	err = s.linked(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE quotes SET text = ?, author = ?, lang = ?, origin = ?, textHash = ?, authorId = NULL, bookId = NULL WHERE id = ?",
+			text, nullable(schema.JoinAttribution(author, book)), nullable(lang), nullable(kind), hash, id)
+		if err != nil {
+			return fmt.Errorf("failed to edit quote %d: %v", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	slog.Info("edited quote", "by", key, "id", id)
+	s.oneQuote(w, r, "SELECT "+quoteColumns+" WHERE q.id = ?", id)
+}
+
+// DELETE /quotes/{id} removes a quote and its tags
+func (s *Server) deleteQuote(w http.ResponseWriter, r *http.Request, key string) {
+	id, err := pathID(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	res, err := s.DB.Exec("DELETE FROM quotes WHERE id = ?", id)
+	if err != nil {
+		reply(w, nil, fmt.Errorf("failed to delete quote %d: %v", id, err))
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		reply(w, nil, notFound("no quote"))
+		return
+	}
+	slog.Info("deleted quote", "by", key, "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// linked runs change in a transaction, then links the quotes it left
+// without an author to their authors and books like the migrations do
+func (s *Server) linked(change func(tx *sql.Tx) error) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	if err := change(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := schema.Normalize(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.TrimSpace(*s)
+}
+
+// nullable stores empty strings as NULL
+func nullable(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"quotesparser/quiz"
+	"quotesparser/store"
+)
+
+// Bundle is a day's content for client apps in one response: the quote of
+// the day, a fun fact and a trivia question with its options, the same all
+// day on every device
+type Bundle struct {
+	Date    string       `json:"date"`
+	Lang    string       `json:"lang,omitempty"`
+	Quote   *Quote       `json:"quote,omitempty"`
+	FunFact *FunFact     `json:"funFact,omitempty"`
+	Trivia  *DailyTrivia `json:"trivia,omitempty"`
+}
+
+// DailyTrivia is the trivia question of a bundle. Choices are its answer and
+// wrong answers in the order to show them, none when there are too few
+// answers to choose from or it is answered true or false.
+type DailyTrivia struct {
+	Trivia
+	Choices []string `json:"choices,omitempty"`
+}
+
+// Zones are the time zones whose midnight starts a new day for each daily
+// output, when a request names none, so a display, the apps and the pushed
+// quotes can each turn over at their readers' midnight
+type Zones struct {
+	Quote   *time.Location // of /quotes/daily and /plain/daily; the machine's when nil
+	Display *time.Location // of /quotes/daily.png and .svg; the machine's when nil
+	// Push is the time zone of devices registering without one; UTC when
+	// nil
+	Push *time.Location
+}
+
+// requestTZ returns the time zone a request for a daily output asked for, or loc's
+func requestTZ(r *http.Request, loc *time.Location) string {
+	if tz := r.URL.Query().Get("tz"); tz != "" || loc == nil {
+		return tz
+	}
+	return loc.String()
+}
+
+// DailyBundles caches the bundles of /daily. RunDaily pre-generates each
+// day's at midnight; bundles for other time zones and languages are made on
+// their first request and kept while their day lasts.
+type DailyBundles struct {
+	// Location is the time zone whose midnight starts a day, when a request
+	// names none; the machine's when nil
+	Location *time.Location
+	// Langs are the quote languages generated ahead, besides any language
+	Langs []string
+	// Choices is how many options a trivia question gets, its answer
+	// included; 4 when 0
+	Choices int
+
+	mu      sync.Mutex
+	bundles map[string]Bundle // date/lang
+}
+
+// maxBundles bounds the cached bundles, against requests for every language
+const maxBundles = 100
+
+func (d *DailyBundles) location() *time.Location {
+	if d.Location == nil {
+		return time.Local
+	}
+	return d.Location
+}
+
+func (d *DailyBundles) get(date, lang string) (Bundle, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b, ok := d.bundles[date+"/"+lang]
+	return b, ok
+}
+
+// put caches b, first dropping the bundles of days already over everywhere
+func (d *DailyBundles) put(b Bundle, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.bundles == nil {
+		d.bundles = make(map[string]Bundle)
+	}
+	// No time zone is more than 14 hours ahead or 12 behind UTC
+	oldest := now.UTC().Add(-14 * time.Hour).Format(time.DateOnly)
+	newest := now.UTC().Add(14 * time.Hour).Format(time.DateOnly)
+	for key, cached := range d.bundles {
+		if cached.Date < oldest {
+			delete(d.bundles, key)
+		}
+	}
+	if b.Date < oldest || b.Date > newest || len(d.bundles) >= maxBundles {
+		return
+	}
+	d.bundles[b.Date+"/"+b.Lang] = b
+}
+
+// dailyPicks caches the quote of the day per date and filter, so that the
+// daily routes do not hash every quote on each request. A pick is kept for
+// a few minutes only, for quotes added, edited or pinned meanwhile to count.
+type dailyPicks struct {
+	mu    sync.Mutex
+	picks map[string]dailyPick
+}
+
+type dailyPick struct {
+	quote store.Quote
+	until time.Time
+}
+
+const (
+	// dailyPickTTL is how long a pick is kept, as long as the daily routes
+	// let clients cache it
+	dailyPickTTL = 5 * time.Minute
+	// maxPicks bounds the cached picks, against requests for every filter
+	maxPicks = 1000
+)
+
+// pickDaily returns store.DailyQuote(s.Store, f, day), picked at most
+// once every dailyPickTTL. The time zone of day only matters through its
+// date, which is all the pick hashes.
+func (s *Server) pickDaily(f store.Filter, day time.Time) (store.Quote, error) {
+	key := fmt.Sprintf("%s/%+v", day.Format(time.DateOnly), f)
+	now := time.Now()
+	s.picks.mu.Lock()
+	pick, ok := s.picks.picks[key]
+	s.picks.mu.Unlock()
+	if ok && now.Before(pick.until) {
+		return pick.quote, nil
+	}
+
+	q, err := store.DailyQuote(s.Store, f, day)
+	if err != nil {
+		return q, err
+	}
+	s.picks.mu.Lock()
+	defer s.picks.mu.Unlock()
+	if s.picks.picks == nil {
+		s.picks.picks = make(map[string]dailyPick)
+	}
+	for key, pick := range s.picks.picks {
+		if !now.Before(pick.until) {
+			delete(s.picks.picks, key)
+		}
+	}
+	if len(s.picks.picks) < maxPicks {
+		s.picks.picks[key] = dailyPick{q, now.Add(dailyPickTTL)}
+	}
+	return q, nil
+}
+
+// RunDaily generates today's bundles, then each following day's at its
+// midnight, until ctx is done
+func (s *Server) RunDaily(ctx context.Context) {
+	s.mount.Do(s.routes)
+	for {
+		now := time.Now().In(s.Daily.location())
+		for _, lang := range append([]string{""}, s.Daily.Langs...) {
+			b, err := s.dailyBundle(now, lang)
+			if err != nil {
+				slog.Error("failed to generate the daily bundle", "lang", lang, "err", err)
+				continue
+			}
+			s.Daily.put(b, now)
+		}
+
+		timer := time.NewTimer(time.Until(store.NextMidnight(now)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// GET /daily?lang=&tz=&date= answers the day's bundle in the time zone tz
+// (the scheduler's by default), with the quote in lang when given
+func (s *Server) daily(w http.ResponseWriter, r *http.Request) {
+	day, err := store.Day(r.URL.Query().Get("date"), requestTZ(r, s.Daily.location()))
+	if err != nil {
+		reply(w, nil, badRequest(err.Error()))
+		return
+	}
+	lang := r.URL.Query().Get("lang")
+	b, ok := s.Daily.get(day.Format(time.DateOnly), lang)
+	if !ok {
+		if b, err = s.dailyBundle(day, lang); err != nil {
+			reply(w, nil, err)
+			return
+		}
+		s.Daily.put(b, time.Now())
+	}
+	if b.Quote == nil && b.FunFact == nil && b.Trivia == nil {
+		reply(w, nil, notFound("nothing to show today"))
+		return
+	}
+	if b.Quote != nil {
+		noteQuote(r, b.Quote.Lang, b.Quote.Source)
+	}
+	if r.URL.Query().Get("date") == "" {
+		// Until the next midnight of the day's time zone
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(time.Until(store.NextMidnight(day)).Seconds())+1))
+	}
+	reply(w, b, nil)
+}
+
+// dailyBundle picks the content of day: the quote of the day in lang, a fun
+// fact in lang and a trivia question, each hashed with the date like
+// store.DailyQuote. A part the database has nothing for is left out.
+func (s *Server) dailyBundle(day time.Time, lang string) (Bundle, error) {
+	b := Bundle{Date: day.Format(time.DateOnly), Lang: lang}
+
+	q, err := s.pickDaily(store.Filter{Lang: lang}, day)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		return b, err
+	default:
+		quote, err := s.findQuote("SELECT "+quoteColumns+" WHERE q.id = ?", q.ID)
+		if err != nil {
+			return b, err
+		}
+		b.Quote = &quote
+	}
+
+	if b.FunFact, err = s.dailyFunFact(day, lang); err != nil {
+		return b, err
+	}
+	if b.Trivia, err = s.dailyTrivia(day); err != nil {
+		return b, err
+	}
+	return b, nil
+}
+
+// dailyFunFact picks the fun fact of day among those in lang, or any
+func (s *Server) dailyFunFact(day time.Time, lang string) (*FunFact, error) {
+	query, args := "SELECT id, text FROM funFacts", []interface{}{}
+	if lang != "" {
+		query += " WHERE lang = ?"
+		args = append(args, lang)
+	}
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fun facts: %v", err)
+	}
+	var ids, texts []string
+	for rows.Next() {
+		var id, text string
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read fun facts: %v", err)
+		}
+		ids, texts = append(ids, id), append(texts, text)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fun facts: %v", err)
+	}
+	i := store.DailyIndex(day, texts)
+	if i < 0 {
+		return nil, nil
+	}
+
+	f, err := scanFunFact(s.DB.QueryRow("SELECT "+funFactColumns+" WHERE f.id = ?", ids[i]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fun facts: %v", err)
+	}
+	return &f, nil
+}
+
+// dailyTrivia picks the trivia question of day, with its imported wrong
+// answers or ones drawn from the other questions as quotes quiz does
+func (s *Server) dailyTrivia(day time.Time) (*DailyTrivia, error) {
+	pool, err := s.Store.Trivia("", 0)
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]string, len(pool))
+	for i, t := range pool {
+		questions[i] = t.Question
+	}
+	i := store.DailyIndex(day, questions)
+	if i < 0 {
+		return nil, nil
+	}
+
+	t, err := s.findTrivia(" WHERE t.question = ?", pool[i].Question)
+	if err != nil {
+		return nil, err
+	}
+	choices := s.Daily.Choices
+	if choices == 0 {
+		choices = 4
+	}
+	dt := &DailyTrivia{Trivia: t}
+	if q := quiz.Choose(pool[i], pool, choices-1); len(q.Choices) > 0 {
+		dt.Choices, _ = q.Shuffled()
+	}
+	return dt, nil
+}
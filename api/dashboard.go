@@ -0,0 +1,402 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"quotesparser/dedup"
+	"quotesparser/jobs"
+	"quotesparser/schema"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardPages = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"base": filepath.Base,
+	"percent": func(part, whole int) string {
+		if whole == 0 {
+			return "–"
+		}
+		return fmt.Sprintf("%.0f%%", 100*float64(part)/float64(whole))
+	},
+	"fraction": func(f float64) string { return fmt.Sprintf("%.0f%%", 100*f) },
+}).Parse(dashboardHTML))
+
+// unsure is the confidence below which a quote waits in the moderation
+// queue, unless ?below= says otherwise
+const unsure = 0.5
+
+// The dashboard is a few HTML pages under /admin/ui/ for the curation tasks
+// awkward from the command line: the latest imports, the moderation queue,
+// near-duplicate quotes, the health of each source and the running jobs.
+// Browsers give it a
+// curator's or an admin's key as the password of HTTP basic auth.
+func (s *Server) dashboardRoutes() {
+	s.mux.HandleFunc("GET /admin/ui/{$}", s.withPage(s.importsPage))
+	s.mux.HandleFunc("GET /admin/ui/queue", s.withPage(s.queuePage))
+	s.mux.HandleFunc("POST /admin/ui/queue/{id}/approve", s.withPage(s.approveQuote))
+	s.mux.HandleFunc("POST /admin/ui/queue/{id}/delete", s.withPage(s.dropQuote))
+	s.mux.HandleFunc("GET /admin/ui/duplicates", s.withPage(s.duplicatesPage))
+	s.mux.HandleFunc("POST /admin/ui/duplicates/merge", s.withPage(s.mergeDuplicates))
+	s.mux.HandleFunc("GET /admin/ui/sources", s.withPage(s.sourcesPage))
+	s.mux.HandleFunc("GET /admin/ui/jobs", s.withPage(s.jobsPage))
+	s.mux.HandleFunc("POST /admin/ui/jobs/{id}/cancel", s.withPage(s.cancelJobPage))
+}
+
+// csrf refuses forms posted to the dashboard from other sites, which the
+// browser would send with the key it remembers
+var csrf = http.NewCrossOriginProtection()
+
+// withPage is withRole for the dashboard: it asks browsers without a
+// curator's key for one, and answers errors as a page
+func (s *Server) withPage(h func(w http.ResponseWriter, r *http.Request, key string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		key, err := s.key(r)
+		var denied unauthorized
+		switch {
+		case errors.As(err, &denied):
+			w.Header().Set("WWW-Authenticate", `Basic realm="quotes dashboard", charset="UTF-8"`)
+			showError(w, err)
+			return
+		case err != nil:
+			showError(w, err)
+			return
+		}
+		noteKey(r, key.Name)
+		if key.Role < Curator {
+			showError(w, forbidden(fmt.Sprintf("%s keys may not use the dashboard, it takes a curator key", key.Role)))
+			return
+		}
+		if err := csrf.Check(r); err != nil {
+			showError(w, forbidden("forms are only taken from the dashboard itself"))
+			return
+		}
+		h(w, r, key.Name)
+	}
+}
+
+// show answers the dashboard page name with data
+func show(w http.ResponseWriter, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := dashboardPages.ExecuteTemplate(&buf, name, data); err != nil {
+		showError(w, fmt.Errorf("failed to render %s: %v", name, err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// showError answers err as a page, hiding internal errors like reply does
+func showError(w http.ResponseWriter, err error) {
+	code, shown := status(err)
+	var buf bytes.Buffer
+	dashboardPages.ExecuteTemplate(&buf, "error", shown.Error())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+}
+
+// Import is the quotes read from one file by one parser, as far as
+// provenance tells; quotes saved before it was recorded are one import per
+// source
+type Import struct {
+	Source    string
+	File      string
+	Parser    string
+	FetchedAt string
+	Quotes    int
+	Latest    int64 // id of the newest quote
+}
+
+// GET /admin/ui/ lists the latest imports, newest first
+func (s *Server) importsPage(w http.ResponseWriter, r *http.Request, key string) {
+	rows, err := s.DB.Query(`
+		SELECT COALESCE(src.name, ''), COALESCE(q.sourceFile, ''), COALESCE(q.parserVersion, ''), COALESCE(MAX(q.fetchedAt), ''), COUNT(*), MAX(q.id)
+		FROM quotes q LEFT JOIN sources src ON src.id = q.sourceId
+		GROUP BY q.sourceId, q.sourceFile, q.parserVersion
+		ORDER BY MAX(q.id) DESC LIMIT 50`)
+	if err != nil {
+		showError(w, fmt.Errorf("failed to read imports: %v", err))
+		return
+	}
+	defer rows.Close()
+	imports := []Import{}
+	for rows.Next() {
+		var i Import
+		if err := rows.Scan(&i.Source, &i.File, &i.Parser, &i.FetchedAt, &i.Quotes, &i.Latest); err != nil {
+			showError(w, fmt.Errorf("failed to read imports: %v", err))
+			return
+		}
+		imports = append(imports, i)
+	}
+	if err := rows.Err(); err != nil {
+		showError(w, fmt.Errorf("failed to read imports: %v", err))
+		return
+	}
+	show(w, "imports", imports)
+}
+
+// Queued is a quote waiting for a curator: flagged for enrichment, or
+// parsed with little confidence
+type Queued struct {
+	ID         int64
+	Text       string
+	Author     string
+	Source     string
+	SourceURL  string
+	Confidence float64
+	Flagged    bool
+	Unsure     bool // scored below the queue's confidence
+}
+
+// GET /admin/ui/queue?below= lists the quotes to moderate, newest first
+func (s *Server) queuePage(w http.ResponseWriter, r *http.Request, key string) {
+	below := unsure
+	if v := r.URL.Query().Get("below"); v != "" {
+		var err error
+		if below, err = strconv.ParseFloat(v, 64); err != nil || below < 0 || below > 1 {
+			showError(w, badRequest("below must be a number from 0 to 1"))
+			return
+		}
+	}
+	rows, err := s.DB.Query(`
+		SELECT q.id, q.text, COALESCE(q.author, ''), COALESCE(src.name, ''), COALESCE(q.sourceUrl, ''), COALESCE(q.confidence, 0),
+			COALESCE(q.needsEnrichment, 0), COALESCE(q.confidence < @below, 0)
+		FROM quotes q LEFT JOIN sources src ON src.id = q.sourceId
+		WHERE q.needsEnrichment = 1 OR q.confidence < @below
+		ORDER BY q.id DESC LIMIT @limit`, sql.Named("below", below), sql.Named("limit", MaxLimit))
+	if err != nil {
+		showError(w, fmt.Errorf("failed to read the queue: %v", err))
+		return
+	}
+	defer rows.Close()
+	queue := []Queued{}
+	for rows.Next() {
+		var q Queued
+		if err := rows.Scan(&q.ID, &q.Text, &q.Author, &q.Source, &q.SourceURL, &q.Confidence, &q.Flagged, &q.Unsure); err != nil {
+			showError(w, fmt.Errorf("failed to read the queue: %v", err))
+			return
+		}
+		queue = append(queue, q)
+	}
+	if err := rows.Err(); err != nil {
+		showError(w, fmt.Errorf("failed to read the queue: %v", err))
+		return
+	}
+	show(w, "queue", struct {
+		Below  float64
+		Quotes []Queued
+	}{below, queue})
+}
+
+// POST /admin/ui/queue/{id}/approve takes a quote out of the queue: a
+// curator vouching for it makes it as certain as a quote gets
+func (s *Server) approveQuote(w http.ResponseWriter, r *http.Request, key string) {
+	id, err := pathID(r)
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	res, err := s.DB.Exec("UPDATE quotes SET needsEnrichment = 0, confidence = 1 WHERE id = ?", id)
+	if err != nil {
+		showError(w, fmt.Errorf("failed to approve quote %d: %v", id, err))
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		showError(w, notFound("no quote"))
+		return
+	}
+	slog.Info("approved quote", "by", key, "id", id)
+	http.Redirect(w, r, "/admin/ui/queue", http.StatusSeeOther)
+}
+
+// POST /admin/ui/queue/{id}/delete deletes a quote from the queue
+func (s *Server) dropQuote(w http.ResponseWriter, r *http.Request, key string) {
+	id, err := pathID(r)
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	res, err := s.DB.Exec("DELETE FROM quotes WHERE id = ?", id)
+	if err != nil {
+		showError(w, fmt.Errorf("failed to delete quote %d: %v", id, err))
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		showError(w, notFound("no quote"))
+		return
+	}
+	slog.Info("deleted quote", "by", key, "id", id)
+	http.Redirect(w, r, "/admin/ui/queue", http.StatusSeeOther)
+}
+
+// Duplicate is a quote of a near-duplicate group
+type Duplicate struct {
+	ID    interface{}
+	Text  string
+	Score float64 // similarity to the kept quote, 0 for that one
+}
+
+// GET /admin/ui/duplicates?threshold= lists the groups of near-duplicate
+// quotes quotes dedup --fuzzy would merge, each around its oldest quote
+func (s *Server) duplicatesPage(w http.ResponseWriter, r *http.Request, key string) {
+	threshold := 0.75
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		var err error
+		if threshold, err = strconv.ParseFloat(v, 64); err != nil || threshold <= 0 || threshold > 1 {
+			showError(w, badRequest("threshold must be above 0 and at most 1"))
+			return
+		}
+	}
+	found, err := schema.NearDuplicateQuotes(s.DB, threshold, dedup.Jaccard)
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	var groups [][]Duplicate
+	for _, g := range found {
+		if len(groups) == MaxLimit {
+			break
+		}
+		keep, drop := g.IDs()
+		group := []Duplicate{{ID: keep, Text: g.Keep}}
+		for i, id := range drop {
+			group = append(group, Duplicate{ID: id, Text: g.Drop[i], Score: g.Scores[i]})
+		}
+		groups = append(groups, group)
+	}
+	show(w, "duplicates", struct {
+		Threshold float64
+		Total     int
+		Groups    [][]Duplicate
+	}{threshold, len(found), groups})
+}
+
+// POST /admin/ui/duplicates/merge merges the quotes checked in a group into
+// the one kept
+func (s *Server) mergeDuplicates(w http.ResponseWriter, r *http.Request, key string) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := r.ParseForm(); err != nil {
+		showError(w, badRequest(fmt.Sprintf("bad form: %v", err)))
+		return
+	}
+	keep, err := strconv.ParseInt(r.PostForm.Get("keep"), 10, 64)
+	if err != nil {
+		showError(w, badRequest("keep must be a quote id"))
+		return
+	}
+	var drop []int64
+	for _, v := range r.PostForm["drop"] {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			showError(w, badRequest("drop must be quote ids"))
+			return
+		}
+		drop = append(drop, id)
+	}
+	err = s.linked(func(tx *sql.Tx) error {
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM quotes WHERE id = ?", keep).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to read quote %d: %v", keep, err)
+		}
+		if exists == 0 {
+			return notFound("no quote to keep")
+		}
+		return schema.MergeQuotes(tx, keep, drop)
+	})
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	slog.Info("merged quotes", "by", key, "keep", keep, "drop", drop)
+	http.Redirect(w, r, "/admin/ui/duplicates", http.StatusSeeOther)
+}
+
+// SourceHealth is how the quotes of a source fare
+type SourceHealth struct {
+	Name        string
+	Quotes      int
+	Flagged     int     // flagged for enrichment
+	Unsure      int     // below the queue's confidence
+	Unstamped   int     // saved before provenance was recorded
+	Confidence  float64 // mean of the scored quotes, 0 with none
+	LastFetched string
+	Parser      string // newest parser version
+}
+
+// GET /admin/ui/sources tells each source's quotes, how many need a look and
+// when the source was last fetched
+func (s *Server) sourcesPage(w http.ResponseWriter, r *http.Request, key string) {
+	rows, err := s.DB.Query(`
+		SELECT COALESCE(src.name, ''), COUNT(*), COALESCE(SUM(q.needsEnrichment = 1), 0), COALESCE(SUM(q.confidence < ?), 0),
+			COALESCE(SUM(q.parserVersion IS NULL), 0), COALESCE(AVG(q.confidence), 0), COALESCE(MAX(q.fetchedAt), ''),
+			COALESCE((SELECT parserVersion FROM quotes n WHERE n.sourceId IS q.sourceId AND n.parserVersion IS NOT NULL ORDER BY n.fetchedAt DESC, n.id DESC LIMIT 1), '')
+		FROM quotes q LEFT JOIN sources src ON src.id = q.sourceId
+		GROUP BY q.sourceId
+		ORDER BY COUNT(*) DESC`, unsure)
+	if err != nil {
+		showError(w, fmt.Errorf("failed to read sources: %v", err))
+		return
+	}
+	defer rows.Close()
+	sources := []SourceHealth{}
+	for rows.Next() {
+		var h SourceHealth
+		if err := rows.Scan(&h.Name, &h.Quotes, &h.Flagged, &h.Unsure, &h.Unstamped, &h.Confidence, &h.LastFetched, &h.Parser); err != nil {
+			showError(w, fmt.Errorf("failed to read sources: %v", err))
+			return
+		}
+		sources = append(sources, h)
+	}
+	if err := rows.Err(); err != nil {
+		showError(w, fmt.Errorf("failed to read sources: %v", err))
+		return
+	}
+	show(w, "sources", sources)
+}
+
+// Jobs are the latest jobs, and whether one is still going, for the page to
+// refresh until none is
+type Jobs struct {
+	Jobs    []jobs.Job
+	Pending bool
+}
+
+// GET /admin/ui/jobs lists the latest jobs with how far they got
+func (s *Server) jobsPage(w http.ResponseWriter, r *http.Request, key string) {
+	list, err := jobs.List(s.DB, "", 50)
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	page := Jobs{Jobs: list}
+	for _, j := range list {
+		page.Pending = page.Pending || !j.Status.Finished()
+	}
+	show(w, "jobs", page)
+}
+
+// POST /admin/ui/jobs/{id}/cancel cancels a job
+func (s *Server) cancelJobPage(w http.ResponseWriter, r *http.Request, key string) {
+	id, err := pathID(r)
+	if err != nil {
+		showError(w, err)
+		return
+	}
+	if _, err := jobs.Cancel(s.DB, id); err != nil {
+		showError(w, err)
+		return
+	}
+	slog.Info("cancelled job", "by", key, "id", id)
+	http.Redirect(w, r, "/admin/ui/jobs", http.StatusSeeOther)
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// IdempotencyHeader carries a key the client picks for a mutation, such as a
+// UUID, so that retrying it after a lost answer does not apply it twice
+const IdempotencyHeader = "Idempotency-Key"
+
+const (
+	// idempotencyTTL is how long answers are kept for replay
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyStale is how long a request may be served before a retry
+	// takes it for abandoned, by a server stopped mid-request
+	idempotencyStale = time.Minute
+)
+
+// idempotent serves a mutation once per Idempotency-Key and caller, the
+// name of the key withRole authenticated. A retry of the same request is
+// answered as the first was, marked with Idempotent-Replayed; a retry while
+// the first is still served gets a 409 and another request under a used key
+// a 422. Answers with a 5xx, a 401 or a 403 are not kept, so that a retry is
+// served again. Requests without the header are served as they come.
+func (s *Server) idempotent(h func(w http.ResponseWriter, r *http.Request, caller string)) func(w http.ResponseWriter, r *http.Request, caller string) {
+	return func(w http.ResponseWriter, r *http.Request, caller string) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" || caller == "" {
+			h(w, r, caller)
+			return
+		}
+		if len(key) > 255 {
+			reply(w, nil, badRequest(IdempotencyHeader+" must have at most 255 characters"))
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			reply(w, nil, badRequest(fmt.Sprintf("bad body: %v", err)))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are the client's, so two clients may pick the same one
+		sum := sha256.Sum256([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + string(body)))
+		request := hex.EncodeToString(sum[:])
+
+		answer, err := s.claimIdempotencyKey(caller, key, request)
+		if err != nil {
+			reply(w, nil, err)
+			return
+		}
+		if answer != nil {
+			answer.replay(w)
+			return
+		}
+		rec := &recordingWriter{ResponseWriter: w, code: http.StatusOK}
+		h(rec, r, caller)
+		s.keepAnswer(caller, key, rec)
+	}
+}
+
+// keptAnswer is an answer kept for replay
+type keptAnswer struct {
+	status      int
+	contentType string
+	location    string
+	body        []byte
+}
+
+func (a *keptAnswer) replay(w http.ResponseWriter) {
+	if a.contentType != "" {
+		w.Header().Set("Content-Type", a.contentType)
+	}
+	if a.location != "" {
+		w.Header().Set("Location", a.location)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(a.status)
+	w.Write(a.body)
+}
+
+// claimIdempotencyKey records that the request under key is being served,
+// or returns the answer kept for it. Expired keys are forgotten first. The
+// key is claimed by a single INSERT, so that of two requests racing for it
+// one is served and the other gets a 409.
+func (s *Server) claimIdempotencyKey(caller, key, request string) (*keptAnswer, error) {
+	now := time.Now().UTC()
+	if _, err := s.DB.Exec("DELETE FROM idempotencyKeys WHERE createdAt < ?", now.Add(-idempotencyTTL).Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("failed to expire idempotency keys: %v", err)
+	}
+	res, err := s.DB.Exec("INSERT INTO idempotencyKeys (caller, key, request, createdAt) VALUES (?, ?, ?, ?) ON CONFLICT (caller, key) DO NOTHING",
+		caller, key, request, now.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record idempotency key: %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to record idempotency key: %v", err)
+	} else if n == 1 {
+		return nil, nil
+	}
+
+	var was string
+	var status sql.NullInt64
+	var contentType, location sql.NullString
+	var createdAt string
+	var body []byte
+	err = s.DB.QueryRow("SELECT request, status, contentType, location, body, createdAt FROM idempotencyKeys WHERE caller = ? AND key = ?", caller, key).
+		Scan(&was, &status, &contentType, &location, &body, &createdAt)
+	switch {
+	case err == sql.ErrNoRows:
+		// Forgotten after a 5xx since the INSERT; the retry can try again
+		return nil, conflict("the request with this " + IdempotencyHeader + " is still being served")
+	case err != nil:
+		return nil, fmt.Errorf("failed to read idempotency key: %v", err)
+	case was != request:
+		return nil, unprocessable(IdempotencyHeader + " was used for another request")
+	case status.Valid:
+		return &keptAnswer{int(status.Int64), contentType.String, location.String, body}, nil
+	case createdAt > now.Add(-idempotencyStale).Format(time.RFC3339):
+		return nil, conflict("the request with this " + IdempotencyHeader + " is still being served")
+	}
+	// Abandoned: taken over by whichever retry moves it on first
+	res, err = s.DB.Exec("UPDATE idempotencyKeys SET createdAt = ? WHERE caller = ? AND key = ? AND createdAt = ? AND status IS NULL",
+		now.Format(time.RFC3339), caller, key, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record idempotency key: %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to record idempotency key: %v", err)
+	} else if n == 0 {
+		return nil, conflict("the request with this " + IdempotencyHeader + " is still being served")
+	}
+	return nil, nil
+}
+
+// keepAnswer saves the answer to the request under key for replay, or
+// forgets the key when the answer was a 5xx or refused the caller
+func (s *Server) keepAnswer(caller, key string, rec *recordingWriter) {
+	var err error
+	if rec.code >= 500 || rec.code == http.StatusUnauthorized || rec.code == http.StatusForbidden {
+		_, err = s.DB.Exec("DELETE FROM idempotencyKeys WHERE caller = ? AND key = ?", caller, key)
+	} else {
+		_, err = s.DB.Exec("UPDATE idempotencyKeys SET status = ?, contentType = ?, location = ?, body = ? WHERE caller = ? AND key = ?",
+			rec.code, rec.Header().Get("Content-Type"), rec.Header().Get("Location"), rec.body.Bytes(), caller, key)
+	}
+	if err != nil {
+		slog.Error("failed to keep answer", "idempotencyKey", key, "err", err)
+	}
+}
+
+// recordingWriter keeps a copy of the answer a handler writes
+type recordingWriter struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"quotesparser/jobs"
+)
+
+// JobRequest is the body of POST /jobs: a quotes command and its flags and
+// arguments, as on the command line
+type JobRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+}
+
+// The job routes queue long commands for quotes serve to run in the
+// background. Curators may follow and cancel them; as jobs run commands on
+// the server, only admins may submit them.
+func (s *Server) jobRoutes() {
+	s.mux.HandleFunc("GET /jobs", s.withRole(Curator, s.listJobs))
+	s.mux.HandleFunc("GET /jobs/{id}", s.withRole(Curator, s.job))
+	s.mux.HandleFunc("POST /jobs", s.withRole(Admin, s.idempotent(s.submitJob)))
+	s.mux.HandleFunc("POST /jobs/{id}/cancel", s.withRole(Curator, s.idempotent(s.cancelJob)))
+}
+
+// GET /jobs?status=&limit= lists the latest jobs, newest first
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request, key string) {
+	limit, err := intParam(r, "limit", DefaultLimit)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	if limit == 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+	status := jobs.Status(r.URL.Query().Get("status"))
+	switch status {
+	case "", jobs.Queued, jobs.Running, jobs.Done, jobs.Failed, jobs.Cancelled:
+	default:
+		reply(w, nil, badRequest("status must be queued, running, done, failed or cancelled"))
+		return
+	}
+	list, err := jobs.List(s.DB, status, limit)
+	reply(w, list, err)
+}
+
+// GET /jobs/{id} is a job with how far it got
+func (s *Server) job(w http.ResponseWriter, r *http.Request, key string) {
+	id, err := pathID(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	j, err := jobs.Get(s.DB, id)
+	reply(w, j, err)
+}
+
+// POST /jobs queues a command, answering the job with a 201
+func (s *Server) submitJob(w http.ResponseWriter, r *http.Request, key string) {
+	var req JobRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		reply(w, nil, badRequest(fmt.Sprintf("bad job: %v", err)))
+		return
+	}
+	j, err := jobs.Submit(s.DB, req.Command, req.Args, key)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	slog.Info("queued job", "by", key, "id", j.ID, "command", j.Line())
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", j.ID))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(j)
+}
+
+// POST /jobs/{id}/cancel takes a queued job out of the queue or asks a
+// running one to stop
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request, key string) {
+	id, err := pathID(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	j, err := jobs.Cancel(s.DB, id)
+	if err == nil {
+		slog.Info("cancelled job", "by", key, "id", id)
+	}
+	reply(w, j, err)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"quotesparser/locale"
+	"quotesparser/origin"
+	"quotesparser/store"
+)
+
+// Snippet is a quote as the /plain endpoints answer it in JSON: just what a
+// launcher extension shows
+type Snippet struct {
+	Text   string `json:"text"`
+	Author string `json:"author,omitempty"`
+}
+
+// The /plain endpoints answer a quote as text, "text\n— Author, Book\n", for
+// shell one-liners such as curl -s host/plain/random?lang=en, or with .json
+// as a Snippet for Raycast, Alfred and the like. Errors are plain text too.
+func (s *Server) plainRoutes() {
+	s.mux.HandleFunc("GET /plain/random", s.plainRandom)
+	s.mux.HandleFunc("GET /plain/random.json", s.plainRandom)
+	s.mux.HandleFunc("GET /plain/daily", s.plainDaily)
+	s.mux.HandleFunc("GET /plain/daily.json", s.plainDaily)
+}
+
+// GET /plain/random?lang=&author=&origin=&maxChars=&minConfidence= picks a quote like
+// /quotes/random. Every call answers another quote, so nothing
+// may cache it.
+func (s *Server) plainRandom(w http.ResponseWriter, r *http.Request) {
+	f, err := storeFilter(r)
+	if err != nil {
+		plainReply(w, r, store.Quote{}, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	id, err := s.pickRandom(f)
+	if err != nil {
+		plainReply(w, r, store.Quote{}, err)
+		return
+	}
+	q, err := s.findQuote("SELECT "+quoteColumns+" WHERE q.id = ?", id)
+	noteQuote(r, q.Lang, q.Source)
+	plainReply(w, r, store.Quote{ID: q.ID, Text: q.Text, Author: q.Author, Book: q.Book, Lang: q.Lang, Origin: origin.Type(q.Origin)}, err)
+}
+
+// GET /plain/daily?lang=&author=&origin=&maxChars=&minConfidence=&tz=&date= answers the quote of
+// the day, cacheable until the day ends in tz (Zones.Quote by default)
+func (s *Server) plainDaily(w http.ResponseWriter, r *http.Request) {
+	day, err := store.Day(r.URL.Query().Get("date"), requestTZ(r, s.Zones.Quote))
+	if err != nil {
+		plainReply(w, r, store.Quote{}, badRequest(err.Error()))
+		return
+	}
+	f, err := storeFilter(r)
+	if err != nil {
+		plainReply(w, r, store.Quote{}, err)
+		return
+	}
+	q, err := s.pickDaily(f, day)
+	if err == nil {
+		noteQuote(r, q.Lang, "")
+		if r.URL.Query().Get("date") != "" {
+			// A past or future day's quote never changes
+			w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+		} else {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(time.Until(store.NextMidnight(day)).Seconds())))
+		}
+	}
+	plainReply(w, r, q, err)
+}
+
+// plainReply writes q as text or, for the .json routes, as a Snippet
+func plainReply(w http.ResponseWriter, r *http.Request, q store.Quote, err error) {
+	// Launchers and web widgets call these from anywhere
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	asJSON := strings.HasSuffix(r.URL.Path, ".json")
+
+	if errors.Is(err, store.ErrNotFound) {
+		err = notFound("no quote")
+	}
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		if asJSON {
+			reply(w, nil, err)
+			return
+		}
+		code, shown := status(err)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		fmt.Fprintln(w, shown)
+		return
+	}
+
+	by := q.Credit()
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(Snippet{Text: q.Text, Author: by})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, q.Text)
+	fmt.Fprintln(w, locale.Attribution(q.Author, q.Book, q.Lang))
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"quotesparser/push"
+)
+
+// The device routes register phones for the quote of the day, which quotes
+// push sends them. Apps call them without a key, so they are mounted on
+// every instance that writes.
+func (s *Server) deviceRoutes() {
+	s.mux.HandleFunc("PUT /push/devices", s.registerDevice)
+	s.mux.HandleFunc("DELETE /push/devices/{token}", s.unregisterDevice)
+}
+
+// PUT /push/devices registers a push.Device, or changes the language, time
+// zone and delivery time of one already registered, answering it. Devices
+// naming no time zone get Zones.Push.
+func (s *Server) registerDevice(w http.ResponseWriter, r *http.Request) {
+	var d push.Device
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&d); err != nil {
+		reply(w, nil, badRequest(fmt.Sprintf("bad device: %v", err)))
+		return
+	}
+	if d.TimeZone == "" && s.Zones.Push != nil {
+		d.TimeZone = s.Zones.Push.String()
+	}
+	if err := d.Check(); err != nil {
+		reply(w, nil, badRequest(err.Error()))
+		return
+	}
+	if err := push.Register(s.DB, d); err != nil {
+		reply(w, nil, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	reply(w, d, nil)
+}
+
+// DELETE /push/devices/{token} stops the quote of the day for a device
+func (s *Server) unregisterDevice(w http.ResponseWriter, r *http.Request) {
+	found, err := push.Unregister(s.DB, r.PathValue("token"))
+	if err == nil && !found {
+		err = notFound("no such device")
+	}
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
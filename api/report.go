@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"quotesparser/recrawl"
+)
+
+// ReportRequest is the body of POST /quotes/{id}/report, which may be empty
+type ReportRequest struct {
+	Reason string `json:"reason"`
+}
+
+// reportRoutes let readers report quotes they find wrong, which raises the
+// priority of the pages they came from in the recrawl frontier
+func (s *Server) reportRoutes() {
+	s.mux.HandleFunc("POST /quotes/{id}/report", s.reportQuote)
+}
+
+// frontierRoutes show curators the pages due to be crawled again
+func (s *Server) frontierRoutes() {
+	s.mux.HandleFunc("GET /recrawl", s.withRole(Curator, s.frontier))
+}
+
+// POST /quotes/{id}/report records a report, answering a 204
+func (s *Server) reportQuote(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	var req ReportRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		reply(w, nil, badRequest(fmt.Sprintf("bad report: %v", err)))
+		return
+	}
+	if err := recrawl.Report(s.DB, id, req.Reason); err != nil {
+		reply(w, nil, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /recrawl?limit= lists the pages of the frontier, highest priority
+// first, as quotes recrawl score last ranked them
+func (s *Server) frontier(w http.ResponseWriter, r *http.Request, key string) {
+	limit, err := intParam(r, "limit", DefaultLimit)
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+	if limit == 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+	pages, err := recrawl.List(s.DB, limit)
+	reply(w, pages, err)
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxShadowed is how many mirrored requests may be in flight at once; more
+// are skipped rather than queued
+const maxShadowed = 8
+
+// shadowMetrics counts the mirrored requests at /debug/vars
+var shadowMetrics = expvar.NewMap("shadow")
+
+// Shadow mirrors a share of the search traffic, GET /quotes with its
+// filters, to another backend serving the same API, such as one on a new
+// search engine, and logs where its answers differ. Clients are answered by
+// the primary alone, so the shadow can fail or lag without harm.
+type Shadow struct {
+	URL     string  // base address of the other backend
+	Percent float64 // share of requests mirrored, 0..100
+	Client  *http.Client
+	Logf    func(format string, args ...interface{})
+
+	slots chan struct{}
+}
+
+// NewShadow returns a Shadow mirroring percent of the search requests to
+// the backend at url
+func NewShadow(url string, percent float64) (*Shadow, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("shadow address %q is not an http(s) URL", url)
+	}
+	if percent < 0 || percent > 100 {
+		return nil, fmt.Errorf("shadow percentage %g is not between 0 and 100", percent)
+	}
+	return &Shadow{
+		URL:     strings.TrimRight(url, "/"),
+		Percent: percent,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+		Logf:    func(format string, args ...interface{}) { slog.Warn(fmt.Sprintf(format, args...)) },
+		slots:   make(chan struct{}, maxShadowed),
+	}, nil
+}
+
+// wants reports whether to mirror r
+func (sh *Shadow) wants(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.URL.Path == "/quotes" && rand.Float64()*100 < sh.Percent
+}
+
+// mirror sends r to the shadow in the background and compares its answer
+// with the primary's
+func (sh *Shadow) mirror(r *http.Request, code int, body []byte) {
+	select {
+	case sh.slots <- struct{}{}:
+	default:
+		shadowMetrics.Add("skipped", 1)
+		return
+	}
+	uri := r.URL.RequestURI()
+	go func() {
+		defer func() { <-sh.slots }()
+		shadowMetrics.Add("requests", 1)
+
+		resp, err := sh.Client.Get(sh.URL + uri)
+		if err != nil {
+			shadowMetrics.Add("errors", 1)
+			sh.Logf("shadow: GET %s: %v", uri, err)
+			return
+		}
+		defer resp.Body.Close()
+		other, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+		if err != nil {
+			shadowMetrics.Add("errors", 1)
+			sh.Logf("shadow: GET %s: failed to read answer: %v", uri, err)
+			return
+		}
+
+		if diff := diffAnswers(code, body, resp.StatusCode, other); diff != "" {
+			shadowMetrics.Add("diffs", 1)
+			sh.Logf("shadow: GET %s: %s", uri, diff)
+			return
+		}
+		shadowMetrics.Add("matches", 1)
+	}()
+}
+
+// diffAnswers describes how the shadow's answer differs from the primary's,
+// or returns "" when they match. Lists of quotes are compared by ID.
+func diffAnswers(code int, body []byte, otherCode int, other []byte) string {
+	if code != otherCode {
+		return fmt.Sprintf("status %d, shadow answered %d", code, otherCode)
+	}
+	var a, b interface{}
+	if json.Unmarshal(body, &a) != nil || json.Unmarshal(other, &b) != nil {
+		if bytes.Equal(body, other) {
+			return ""
+		}
+		return "bodies differ"
+	}
+	if reflect.DeepEqual(a, b) {
+		return ""
+	}
+
+	ids, otherIDs := listIDs(a), listIDs(b)
+	if ids == nil || otherIDs == nil {
+		return "bodies differ"
+	}
+	var missing, extra []string
+	for id := range ids {
+		if !otherIDs[id] {
+			missing = append(missing, id)
+		}
+	}
+	for id := range otherIDs {
+		if !ids[id] {
+			extra = append(extra, id)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		if len(a.([]interface{})) != len(b.([]interface{})) {
+			return fmt.Sprintf("%d results, shadow answered %d", len(a.([]interface{})), len(b.([]interface{})))
+		}
+		return "same results in another order or with other fields"
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return fmt.Sprintf("%d results, shadow answered %d; missing %v, extra %v",
+		len(a.([]interface{})), len(b.([]interface{})), missing, extra)
+}
+
+// listIDs returns the ids of a JSON list of objects, or nil when v is not
+// one
+func listIDs(v interface{}) map[string]bool {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	ids := make(map[string]bool, len(list))
+	for _, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok || obj["id"] == nil {
+			return nil
+		}
+		ids[fmt.Sprint(obj["id"])] = true
+	}
+	return ids
+}
+
+// recorder keeps a copy of what a handler answers
+type recorder struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	rec.code = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
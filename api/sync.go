@@ -0,0 +1,216 @@
+package api
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// syncPage is how many changes a delta download carries at most
+const syncPage = 1000
+
+// Sync is a download for offline clients. Without a token it is a Snapshot
+// of every quote, trivia question and fun fact, which replaces what the
+// client kept; with one, the rows changed since it was issued and the IDs of
+// those Deleted. Either way the client keeps Token for its next sync, right
+// away while More changes are waiting.
+type Sync struct {
+	Token    string     `json:"token"`
+	Snapshot bool       `json:"snapshot,omitempty"`
+	More     bool       `json:"more,omitempty"`
+	Quotes   []Quote    `json:"quotes"`
+	Trivia   []Trivia   `json:"trivia"`
+	FunFacts []FunFact  `json:"funFacts"`
+	Deleted  *Tombstone `json:"deleted,omitempty"`
+}
+
+// Tombstone lists the rows deleted since a sync token
+type Tombstone struct {
+	Quotes   []int64  `json:"quotes"`
+	Trivia   []int64  `json:"trivia"`
+	FunFacts []string `json:"funFacts"`
+}
+
+// GET /sync?token= answers a snapshot, or the changes since token, gzipped
+// for clients that accept it
+func (s *Server) sync(w http.ResponseWriter, r *http.Request) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		reply(w, nil, fmt.Errorf("failed to begin transaction: %v", err))
+		return
+	}
+	defer tx.Rollback() // reads only
+
+	var latest int64
+	if err := tx.QueryRow("SELECT COALESCE(MAX(seq), 0) FROM syncLog").Scan(&latest); err != nil {
+		reply(w, nil, fmt.Errorf("failed to read the sync log: %v", err))
+		return
+	}
+	var out Sync
+	if token := r.URL.Query().Get("token"); token == "" {
+		out, err = snapshot(tx, latest)
+	} else {
+		since, perr := strconv.ParseInt(token, 10, 64)
+		if perr != nil || since < 0 || since > latest {
+			reply(w, nil, badRequest("unknown sync token; sync again without one"))
+			return
+		}
+		out, err = delta(tx, since, latest)
+	}
+	if err != nil {
+		reply(w, nil, err)
+		return
+	}
+
+	// Every sync answers what changed by then
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		reply(w, out, nil)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	gz := gzip.NewWriter(w)
+	reply(gzipWriter{w, gz}, out, nil)
+	gz.Close()
+}
+
+// snapshot reads every row, as of the sync log's latest change
+func snapshot(tx *sql.Tx, latest int64) (Sync, error) {
+	out := Sync{Token: strconv.FormatInt(latest, 10), Snapshot: true}
+	var err error
+	if out.Quotes, err = syncQuotes(tx, " ORDER BY q.id"); err != nil {
+		return out, err
+	}
+	if out.Trivia, err = syncTrivia(tx, " ORDER BY t.id"); err != nil {
+		return out, err
+	}
+	out.FunFacts, err = syncFunFacts(tx, " ORDER BY f.id")
+	return out, err
+}
+
+// delta reads the rows changed and deleted after change since, up to a page
+// of changes
+func delta(tx *sql.Tx, since, latest int64) (Sync, error) {
+	until := latest
+	err := tx.QueryRow("SELECT seq FROM syncLog WHERE seq > ? ORDER BY seq LIMIT 1 OFFSET ?", since, syncPage-1).Scan(&until)
+	if err != nil && err != sql.ErrNoRows {
+		return Sync{}, fmt.Errorf("failed to read the sync log: %v", err)
+	}
+	out := Sync{Token: strconv.FormatInt(until, 10), More: until < latest}
+
+	changed := func(collection, id string) string {
+		return fmt.Sprintf(" JOIN syncLog l ON l.collection = '%s' AND l.rowId = %s WHERE l.seq > ? AND l.seq <= ? AND l.deleted = 0 ORDER BY l.seq", collection, id)
+	}
+	if out.Quotes, err = syncQuotes(tx, changed("quotes", "q.id"), since, until); err != nil {
+		return out, err
+	}
+	if out.Trivia, err = syncTrivia(tx, changed("trivia", "t.id"), since, until); err != nil {
+		return out, err
+	}
+	if out.FunFacts, err = syncFunFacts(tx, changed("funFacts", "f.id"), since, until); err != nil {
+		return out, err
+	}
+
+	out.Deleted = &Tombstone{Quotes: []int64{}, Trivia: []int64{}, FunFacts: []string{}}
+	rows, err := tx.Query("SELECT collection, rowId FROM syncLog WHERE seq > ? AND seq <= ? AND deleted = 1 ORDER BY seq", since, until)
+	if err != nil {
+		return out, fmt.Errorf("failed to read the sync log: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var collection, id string
+		if err := rows.Scan(&collection, &id); err != nil {
+			return out, fmt.Errorf("failed to read the sync log: %v", err)
+		}
+		switch collection {
+		case "funFacts":
+			out.Deleted.FunFacts = append(out.Deleted.FunFacts, id)
+		case "quotes", "trivia":
+			n, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return out, fmt.Errorf("bad %s id %q in the sync log", collection, id)
+			}
+			if collection == "quotes" {
+				out.Deleted.Quotes = append(out.Deleted.Quotes, n)
+			} else {
+				out.Deleted.Trivia = append(out.Deleted.Trivia, n)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return out, fmt.Errorf("failed to read the sync log: %v", err)
+	}
+	return out, nil
+}
+
+func syncQuotes(tx *sql.Tx, rest string, args ...interface{}) ([]Quote, error) {
+	rows, err := tx.Query("SELECT "+quoteColumns+rest, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quotes: %v", err)
+	}
+	return scanQuotes(rows)
+}
+
+func syncTrivia(tx *sql.Tx, rest string, args ...interface{}) ([]Trivia, error) {
+	rows, err := tx.Query("SELECT "+triviaColumns+rest, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	defer rows.Close()
+	trivia := []Trivia{}
+	for rows.Next() {
+		t, err := scanTrivia(rows)
+		if err != nil {
+			return nil, err
+		}
+		trivia = append(trivia, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	return trivia, nil
+}
+
+func syncFunFacts(tx *sql.Tx, rest string, args ...interface{}) ([]FunFact, error) {
+	rows, err := tx.Query("SELECT "+funFactColumns+rest, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fun facts: %v", err)
+	}
+	defer rows.Close()
+	facts := []FunFact{}
+	for rows.Next() {
+		f, err := scanFunFact(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fun facts: %v", err)
+		}
+		facts = append(facts, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fun facts: %v", err)
+	}
+	return facts, nil
+}
+
+// acceptsGzip reports whether r accepts a gzipped answer
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter writes the body of a response through a gzip.Writer
+type gzipWriter struct {
+	http.ResponseWriter
+	gz io.Writer
+}
+
+func (w gzipWriter) Write(p []byte) (int, error) { return w.gz.Write(p) }
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Usage counts the requests of one day to one route with the same key,
+// language and source
+type Usage struct {
+	Day      string `json:"day"`      // UTC, e.g. 2024-01-31
+	Endpoint string `json:"endpoint"` // route, e.g. GET /quotes/{id}
+	Key      string `json:"key,omitempty"`
+	Lang     string `json:"lang,omitempty"`   // language asked for or answered
+	Source   string `json:"source,omitempty"` // source of the quote answered
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"` // answered with a 4xx or 5xx
+}
+
+// UsageSink stores the usage counts a Tracker collects
+type UsageSink interface {
+	Record(usage []Usage) error
+}
+
+// UsageTable adds usage to the apiUsage table, dropping the days older than
+// Days
+type UsageTable struct {
+	DB   *sql.DB
+	Days int // 0 keeps every day
+}
+
+func (t UsageTable) Record(usage []Usage) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	for _, u := range usage {
+		_, err := tx.Exec(`INSERT INTO apiUsage (day, endpoint, apiKey, lang, source, requests, errors)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(day, endpoint, apiKey, lang, source) DO UPDATE SET
+				requests = requests + excluded.requests, errors = errors + excluded.errors`,
+			u.Day, u.Endpoint, u.Key, u.Lang, u.Source, u.Requests, u.Errors)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record usage: %v", err)
+		}
+	}
+	if t.Days > 0 {
+		oldest := time.Now().UTC().AddDate(0, 0, -t.Days+1).Format("2006-01-02")
+		if _, err := tx.Exec("DELETE FROM apiUsage WHERE day < ?", oldest); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to prune usage: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// UsageLog writes usage as JSON lines, e.g. to a file an analytics pipeline
+// picks up
+type UsageLog struct {
+	W io.Writer
+}
+
+func (l UsageLog) Record(usage []Usage) error {
+	enc := json.NewEncoder(l.W)
+	for _, u := range usage {
+		if err := enc.Encode(u); err != nil {
+			return fmt.Errorf("failed to write usage: %v", err)
+		}
+	}
+	return nil
+}
+
+// Tracker counts requests in memory and hands the counts to Sink when
+// flushed, so serving never waits on the sink
+type Tracker struct {
+	Sink UsageSink
+
+	mu     sync.Mutex
+	counts map[Usage]int // keyed by Usage without its counts
+	errors map[Usage]int
+}
+
+// NewTracker returns a Tracker recording into sink
+func NewTracker(sink UsageSink) *Tracker {
+	return &Tracker{Sink: sink, counts: make(map[Usage]int), errors: make(map[Usage]int)}
+}
+
+// add counts one request
+func (t *Tracker) add(u Usage, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.counts[u]++
+	if failed {
+		t.errors[u]++
+	}
+}
+
+// Flush hands the requests counted since the last flush to the sink. The
+// counts are kept for the next flush when the sink fails.
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	counts, errors := t.counts, t.errors
+	t.counts, t.errors = make(map[Usage]int), make(map[Usage]int)
+	t.mu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	usage := make([]Usage, 0, len(counts))
+	for u, n := range counts {
+		u.Requests, u.Errors = n, errors[u]
+		usage = append(usage, u)
+	}
+	if err := t.Sink.Record(usage); err != nil {
+		t.mu.Lock()
+		for _, u := range usage {
+			n, failed := u.Requests, u.Errors
+			u.Requests, u.Errors = 0, 0
+			t.counts[u] += n
+			t.errors[u] += failed
+		}
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is done, then once more
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := t.Flush(); err != nil {
+				slog.Error("failed to save usage", "err", err)
+			}
+			return
+		}
+		if err := t.Flush(); err != nil {
+			slog.Error("failed to save usage", "err", err)
+		}
+	}
+}
+
+// usageNote is what the handlers learn about a request that the Tracker
+// counts it by
+type usageNote struct {
+	key, lang, source string
+}
+
+type usageNoteKey struct{}
+
+// noteKey records the API key a request came with
+func noteKey(r *http.Request, key string) {
+	if n, ok := r.Context().Value(usageNoteKey{}).(*usageNote); ok {
+		n.key = key
+	}
+}
+
+// noteQuote records the language and source of the quote a request was
+// answered with
+func noteQuote(r *http.Request, lang, source string) {
+	if n, ok := r.Context().Value(usageNoteKey{}).(*usageNote); ok {
+		n.lang, n.source = lang, source
+	}
+}
+
+// statusWriter keeps the status a handler answers with
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// track serves r through next and counts it. Requests matching no route are
+// not counted.
+func (t *Tracker) track(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	note := &usageNote{lang: r.URL.Query().Get("lang")}
+	r = r.WithContext(context.WithValue(r.Context(), usageNoteKey{}, note))
+	sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+	next.ServeHTTP(sw, r)
+	if r.Pattern == "" {
+		return
+	}
+	t.add(Usage{
+		Day:      time.Now().UTC().Format("2006-01-02"),
+		Endpoint: r.Pattern,
+		Key:      note.key,
+		Lang:     note.lang,
+		Source:   note.source,
+	}, sw.code >= 400)
+}
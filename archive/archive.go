@@ -0,0 +1,98 @@
+// Package archive keeps the raw pages downloaded from quote sites gzipped,
+// each under its address and the time it was fetched, rather than as
+// thousands of loose files. An archive is either the rawPages table of the
+// quotes database or a .tar.gz file, and quotes parse --archive reads the
+// pages again from it without downloading them.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Page is a raw page as it was downloaded
+type Page struct {
+	Source    string
+	URL       string // or the name of the file it was kept in, when unknown
+	FetchedAt time.Time
+	Size      int64  // of the page, uncompressed
+	gzipped   []byte // the page
+
+	// The validators the site sent with the page, to ask it whether the
+	// page changed since
+	ETag         string
+	LastModified string
+}
+
+// NewPage compresses body, downloaded from url for source at fetchedAt
+func NewPage(source, url string, fetchedAt time.Time, body []byte) (Page, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return Page{}, fmt.Errorf("failed to compress %s: %v", url, err)
+	}
+	if err := gz.Close(); err != nil {
+		return Page{}, fmt.Errorf("failed to compress %s: %v", url, err)
+	}
+	return Page{Source: source, URL: url, FetchedAt: fetchedAt.UTC().Truncate(time.Second), Size: int64(len(body)), gzipped: buf.Bytes()}, nil
+}
+
+// Body decompresses the page
+func (p Page) Body() ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(p.gzipped))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %v", p.URL, err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %v", p.URL, err)
+	}
+	return body, nil
+}
+
+// Compressed is the size of the page as archived
+func (p Page) Compressed() int64 {
+	return int64(len(p.gzipped))
+}
+
+// Archive keeps pages
+type Archive interface {
+	// Put archives p, unless the archive already has its address fetched at
+	// the same time
+	Put(p Page) error
+	// Walk calls fn with the pages of source, or of every source when it is
+	// empty, in the order they were archived, until fn returns an error
+	Walk(source string, fn func(Page) error) error
+	// Find returns the latest copy of url, if any
+	Find(url string) (Page, bool, error)
+	Close() error
+}
+
+// IsTarball reports whether path names a .tar.gz archive rather than a
+// database
+func IsTarball(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// Latest returns the latest copy of each page of source in a, in the order
+// their addresses were first archived
+func Latest(a Archive, source string) ([]Page, error) {
+	var pages []Page
+	at := map[string]int{}
+	err := a.Walk(source, func(p Page) error {
+		i, ok := at[p.URL]
+		switch {
+		case !ok:
+			at[p.URL] = len(pages)
+			pages = append(pages, p)
+		case !p.FetchedAt.Before(pages[i].FetchedAt):
+			pages[i] = p
+		}
+		return nil
+	})
+	return pages, err
+}
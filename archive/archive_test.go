@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTarball(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pages.tar.gz")
+	first := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	put := func(pages ...Page) {
+		t.Helper()
+		a := OpenTarball(path)
+		for _, p := range pages {
+			if err := a.Put(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page := func(source, url string, at time.Time, body string) Page {
+		t.Helper()
+		p, err := NewPage(source, url, at, []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	put(page("goodreads", "https://www.goodreads.com/quotes?page=1", first, "<p>one</p>"),
+		page("goodreads", "https://www.goodreads.com/quotes?page=2", first, "<p>two</p>"),
+		page("1000kitap", "https://1000kitap.com/kitap/x/alintilar?sayfa=1", first, "<p>bir</p>"))
+	// A later run appends, without archiving the same download twice
+	put(page("goodreads", "https://www.goodreads.com/quotes?page=1", first, "<p>one</p>"),
+		page("goodreads", "https://www.goodreads.com/quotes?page=1", first.Add(time.Hour), "<p>one, again</p>"))
+
+	n := 0
+	if err := OpenTarball(path).Walk("", func(Page) error { n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("archive has %d pages, want 4", n)
+	}
+
+	pages, err := Latest(OpenTarball(path), "goodreads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("latest goodreads pages = %d, want 2", len(pages))
+	}
+	body, err := pages[0].Body()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages[0].URL != "https://www.goodreads.com/quotes?page=1" || !pages[0].FetchedAt.Equal(first.Add(time.Hour)) || string(body) != "<p>one, again</p>" {
+		t.Errorf("latest page 1 = %s at %s: %q, want the copy fetched again", pages[0].URL, pages[0].FetchedAt, body)
+	}
+	if pages[1].Size != int64(len("<p>two</p>")) {
+		t.Errorf("page 2 size = %d", pages[1].Size)
+	}
+}
+
+func TestTarballInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pages.tar.gz")
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	put := func(path string, urls ...string) {
+		t.Helper()
+		a := OpenTarball(path)
+		for _, url := range urls {
+			p, err := NewPage("goodreads", url, at, []byte("<p>"+url+"</p>"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Put(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	walk := func() []string {
+		t.Helper()
+		var urls []string
+		if err := OpenTarball(path).Walk("", func(p Page) error { urls = append(urls, p.URL); return nil }); err != nil {
+			t.Fatal(err)
+		}
+		return urls
+	}
+
+	put(path, "https://www.goodreads.com/quotes?page=1")
+	// A run killed while appending its member leaves part of it
+	other := filepath.Join(dir, "other.tar.gz")
+	put(other, "https://www.goodreads.com/quotes?page=2")
+	member, err := os.ReadFile(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(member[:len(member)/2])
+	f.Close()
+
+	if urls := walk(); len(urls) != 1 || urls[0] != "https://www.goodreads.com/quotes?page=1" {
+		t.Fatalf("walked %v, want the page before the truncated member", urls)
+	}
+	// The next run replaces the truncated member, archiving page 2 again
+	put(path, "https://www.goodreads.com/quotes?page=2", "https://www.goodreads.com/quotes?page=3")
+	if urls := walk(); len(urls) != 3 {
+		t.Errorf("walked %v, want the 3 pages", urls)
+	}
+	parts, _ := filepath.Glob(filepath.Join(dir, "*.part"))
+	if len(parts) != 0 {
+		t.Errorf("runs left %v", parts)
+	}
+}
+
+func TestWalkMissing(t *testing.T) {
+	if err := OpenTarball(filepath.Join(t.TempDir(), "none.tar.gz")).Walk("", func(Page) error {
+		t.Error("walked a page of no archive")
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"quotesparser/fetch"
+)
+
+// Cache is a fetch.Cache over an archive: a client asks the site whether
+// the latest copy of a page changed, and archives the pages downloaded under
+// Source
+type Cache struct {
+	Archive Archive
+	Source  string
+
+	mu        sync.Mutex // tarballs are not safe for concurrent use
+	unchanged atomic.Int64
+}
+
+func (c *Cache) Lookup(url string) (fetch.Cached, bool, error) {
+	c.mu.Lock()
+	p, ok, err := c.Archive.Find(url)
+	c.mu.Unlock()
+	if !ok || err != nil || p.ETag == "" && p.LastModified == "" {
+		// Without validators the site cannot tell whether it changed
+		return fetch.Cached{}, false, err
+	}
+	body, err := p.Body()
+	if err != nil {
+		return fetch.Cached{}, false, err
+	}
+	return fetch.Cached{Body: body, ETag: p.ETag, LastModified: p.LastModified}, true, nil
+}
+
+// Store archives a page downloaded
+func (c *Cache) Store(url string, page fetch.Cached) error {
+	p, err := NewPage(c.Source, url, time.Now(), page.Body)
+	if err != nil {
+		return err
+	}
+	p.ETag, p.LastModified = page.ETag, page.LastModified
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Archive.Put(p)
+}
+
+// Unchanged counts the pages the site said had not changed
+func (c *Cache) Unchanged(url string) {
+	c.unchanged.Add(1)
+}
+
+// Reused is how many pages were not downloaded again as they had not
+// changed
+func (c *Cache) Reused() int64 {
+	return c.unchanged.Load()
+}
@@ -0,0 +1,78 @@
+package archive
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Table archives pages in the rawPages table of a migrated database
+type Table struct {
+	DB *sql.DB
+}
+
+func (t Table) Put(p Page) error {
+	_, err := t.DB.Exec("INSERT OR IGNORE INTO rawPages (source, url, fetchedAt, size, body, etag, lastModified) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		p.Source, p.URL, p.FetchedAt.Format(time.RFC3339), p.Size, p.gzipped, nullIfEmpty(p.ETag), nullIfEmpty(p.LastModified))
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %v", p.URL, err)
+	}
+	return nil
+}
+
+const pageColumns = "source, url, fetchedAt, size, body, COALESCE(etag, ''), COALESCE(lastModified, '')"
+
+func scanPage(rows interface{ Scan(...interface{}) error }) (Page, error) {
+	var p Page
+	var fetchedAt string
+	if err := rows.Scan(&p.Source, &p.URL, &fetchedAt, &p.Size, &p.gzipped, &p.ETag, &p.LastModified); err != nil {
+		return p, err
+	}
+	var err error
+	if p.FetchedAt, err = time.Parse(time.RFC3339, fetchedAt); err != nil {
+		return p, fmt.Errorf("bad fetch time of archived %s: %v", p.URL, err)
+	}
+	return p, nil
+}
+
+func (t Table) Walk(source string, fn func(Page) error) error {
+	rows, err := t.DB.Query("SELECT "+pageColumns+" FROM rawPages WHERE ? = '' OR source = ? ORDER BY id", source, source)
+	if err != nil {
+		return fmt.Errorf("failed to read archived pages: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p, err := scanPage(rows)
+		if err != nil {
+			return fmt.Errorf("failed to read archived pages: %v", err)
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read archived pages: %v", err)
+	}
+	return nil
+}
+
+func (t Table) Find(url string) (Page, bool, error) {
+	p, err := scanPage(t.DB.QueryRow("SELECT "+pageColumns+" FROM rawPages WHERE url = ? ORDER BY fetchedAt DESC, id DESC LIMIT 1", url))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, false, nil
+	}
+	if err != nil {
+		return p, false, fmt.Errorf("failed to read archived %s: %v", url, err)
+	}
+	return p, true, nil
+}
+
+// Close leaves the database open for its owner to close
+func (t Table) Close() error {
+	return nil
+}
+
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
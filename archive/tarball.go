@@ -0,0 +1,312 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Records of the tar headers keeping what a file name cannot, as extended
+// attributes so that tar extracts them quietly
+const (
+	urlRecord          = "SCHILY.xattr.user.quotes.url"
+	sourceRecord       = "SCHILY.xattr.user.quotes.source"
+	etagRecord         = "SCHILY.xattr.user.quotes.etag"
+	lastModifiedRecord = "SCHILY.xattr.user.quotes.lastModified"
+)
+
+// Tarball archives pages in a .tar.gz file, one entry a page under
+// <source>/<address>. Each run archiving into it adds a gzip member, a tar
+// of its own, to its end: tar -xzif extracts them all. A run writes its
+// member next to the archive and appends it on Close, so a run stopped
+// before leaves the archive as it was, or at worst with a truncated last
+// member the next run drops.
+type Tarball struct {
+	Path string
+
+	part   *os.File // the member of this run, until Close appends it
+	gz     *gzip.Writer
+	tw     *tar.Writer
+	good   int64           // where the complete members of the archive end
+	seen   map[string]bool // address and fetch time of the pages archived
+	latest map[string]Page // copy of each address found, once Find reads them
+}
+
+// OpenTarball opens the archive at path, made by the first Put
+func OpenTarball(path string) *Tarball {
+	return &Tarball{Path: path}
+}
+
+func pageKey(url string, fetchedAt time.Time) string {
+	return fetchedAt.UTC().Format(time.RFC3339) + " " + url
+}
+
+func (t *Tarball) Put(p Page) error {
+	if t.tw == nil {
+		// Pages of a truncated member are not seen, as Close drops it
+		t.seen = map[string]bool{}
+		var pending []string
+		good, err := t.walk("", func(p Page) error {
+			pending = append(pending, pageKey(p.URL, p.FetchedAt))
+			return nil
+		}, func() {
+			for _, key := range pending {
+				t.seen[key] = true
+			}
+			pending = pending[:0]
+		})
+		if err != nil {
+			return err
+		}
+		t.good = good
+		if t.part, err = os.CreateTemp(filepath.Dir(t.Path), filepath.Base(t.Path)+".*.part"); err != nil {
+			return fmt.Errorf("failed to open archive: %v", err)
+		}
+		t.gz = gzip.NewWriter(t.part)
+		t.tw = tar.NewWriter(t.gz)
+	}
+	key := pageKey(p.URL, p.FetchedAt)
+	if t.seen[key] {
+		return nil
+	}
+	body, err := p.Body()
+	if err != nil {
+		return err
+	}
+	err = t.tw.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeReg,
+		Name:       entryName(p),
+		Size:       int64(len(body)),
+		Mode:       0644,
+		ModTime:    p.FetchedAt,
+		Format:     tar.FormatPAX,
+		PAXRecords: records(p),
+	})
+	if err == nil {
+		_, err = t.tw.Write(body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %v", p.URL, err)
+	}
+	t.seen[key] = true
+	if t.latest != nil && !p.FetchedAt.Before(t.latest[p.URL].FetchedAt) {
+		t.latest[p.URL] = p
+	}
+	return nil
+}
+
+// records are the PAX records of p's entry
+func records(p Page) map[string]string {
+	r := map[string]string{urlRecord: p.URL, sourceRecord: p.Source}
+	if p.ETag != "" {
+		r[etagRecord] = p.ETag
+	}
+	if p.LastModified != "" {
+		r[lastModifiedRecord] = p.LastModified
+	}
+	return r
+}
+
+// Find reads the whole archive once, then answers from what it found and
+// the pages put since
+func (t *Tarball) Find(url string) (Page, bool, error) {
+	if t.latest == nil {
+		latest := map[string]Page{}
+		err := t.Walk("", func(p Page) error {
+			if !p.FetchedAt.Before(latest[p.URL].FetchedAt) {
+				latest[p.URL] = p
+			}
+			return nil
+		})
+		if err != nil {
+			return Page{}, false, err
+		}
+		t.latest = latest
+	}
+	p, ok := t.latest[url]
+	return p, ok, nil
+}
+
+// entryName is where p extracts to: its address as a path under its source
+func entryName(p Page) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(p.URL, "https://"), "http://")
+	name = strings.NewReplacer("?", "_", "&", "_", "=", "_", ":", "_", "..", "_").Replace(strings.Trim(name, "/"))
+	if len(name) > 200 {
+		name = name[:200]
+	}
+	return p.Source + "/" + name
+}
+
+func (t *Tarball) Walk(source string, fn func(Page) error) error {
+	_, err := t.walk(source, fn, nil)
+	return err
+}
+
+// walk is Walk calling member, when set, after each complete member. It
+// returns where the last complete member ends: a member cut off by a run
+// stopped while appending it is logged and skipped.
+func (t *Tarball) walk(source string, fn func(Page) error, member func()) (int64, error) {
+	f, err := os.Open(t.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer f.Close()
+	// gzip reads a bufio.Reader as it is, so what it holds buffered tells
+	// where in the file a member ends
+	file := &countingReader{r: f}
+	in := bufio.NewReader(file)
+	var good int64
+	truncated := func(err error) (int64, error) {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			slog.Warn("skipping the truncated end of the archive, left by an interrupted run", "archive", t.Path, "at", good)
+			return good, nil
+		}
+		return good, fmt.Errorf("failed to read archive: %v", err)
+	}
+	gz, err := gzip.NewReader(in)
+	for {
+		if errors.Is(err, io.EOF) {
+			return good, nil
+		}
+		if err != nil {
+			return truncated(err)
+		}
+		gz.Multistream(false)
+		if err := t.walkMember(gz, source, fn); err != nil {
+			var read readError
+			if errors.As(err, &read) {
+				return truncated(read.err)
+			}
+			return good, err
+		}
+		if member != nil {
+			member()
+		}
+		good = file.n - int64(in.Buffered())
+		err = gz.Reset(in)
+	}
+}
+
+// readError is a failure to read the archive, rather than of fn
+type readError struct{ err error }
+
+func (e readError) Error() string { return e.err.Error() }
+
+// walkMember calls fn with the pages of source in a gzip member, reading it
+// to its end so that its checksum is verified
+func (t *Tarball) walkMember(gz io.Reader, source string, fn func(Page) error) error {
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return readError{err}
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.PAXRecords[urlRecord] == "" {
+			continue
+		}
+		if source != "" && hdr.PAXRecords[sourceRecord] != source {
+			continue
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			return readError{err}
+		}
+		p, err := NewPage(hdr.PAXRecords[sourceRecord], hdr.PAXRecords[urlRecord], hdr.ModTime, body)
+		if err != nil {
+			return err
+		}
+		p.ETag, p.LastModified = hdr.PAXRecords[etagRecord], hdr.PAXRecords[lastModifiedRecord]
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return readError{err}
+	}
+	return nil
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Close ends the member the pages were put into and appends it to the
+// archive, in place of a truncated member an interrupted run left
+func (t *Tarball) Close() error {
+	if t.tw == nil {
+		return nil
+	}
+	part := t.part.Name()
+	defer os.Remove(part)
+	err := t.tw.Close()
+	if gzErr := t.gz.Close(); err == nil {
+		err = gzErr
+	}
+	if closeErr := t.part.Close(); err == nil {
+		err = closeErr
+	}
+	t.tw = nil
+	if err == nil {
+		err = t.append(part)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %v", err)
+	}
+	return nil
+}
+
+// append adds the member written to part after the complete members of the
+// archive, or makes it the archive when there is none
+func (t *Tarball) append(part string) error {
+	if t.good == 0 {
+		return os.Rename(part, t.Path)
+	}
+	in, err := os.Open(part)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(t.Path, os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := out.Truncate(t.good); err != nil {
+		out.Close()
+		return err
+	}
+	if _, err := out.Seek(t.good, io.SeekStart); err != nil {
+		out.Close()
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
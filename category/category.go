@@ -0,0 +1,144 @@
+// Package category normalizes trivia categories: the same category comes
+// spelled many ways ("Sci", "science", "SCIENCE ", " Geography") from
+// trivia.txt and the Open Trivia Database, and is stored under one
+// canonical name.
+package category
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Aliases map the keys of other spellings to canonical names: abbreviations
+// and the Open Trivia Database's categories, onto those of trivia.txt
+var Aliases = map[string]string{
+	"sci":                                "Science & Nature",
+	"science":                            "Science & Nature",
+	"nature":                             "Science & Nature",
+	"animals":                            "Science & Nature",
+	"science computers":                  "Technology & Video Games",
+	"science gadgets":                    "Technology & Video Games",
+	"tech":                               "Technology & Video Games",
+	"technology":                         "Technology & Video Games",
+	"tech video games":                   "Technology & Video Games",
+	"video games":                        "Technology & Video Games",
+	"entertainment video games":          "Technology & Video Games",
+	"math":                               "Mathematics",
+	"maths":                              "Mathematics",
+	"science mathematics":                "Mathematics",
+	"mathematics geometry":               "Mathematics",
+	"general knowledge":                  "General",
+	"geo":                                "Geography",
+	"history":                            "History & Holidays",
+	"holidays":                           "History & Holidays",
+	"sports":                             "Sports & Leisure",
+	"sport":                              "Sports & Leisure",
+	"leisure":                            "Sports & Leisure",
+	"food":                               "Food & Drink",
+	"drink":                              "Food & Drink",
+	"art":                                "Art & Literature",
+	"literature":                         "Art & Literature",
+	"entertainment books":                "Art & Literature",
+	"entertainment music":                "Music",
+	"mythology":                          "Religion & Mythology",
+	"religion":                           "Religion & Mythology",
+	"entertainment board games":          "Toys & Games",
+	"board games":                        "Toys & Games",
+	"entertainment film":                 "Entertainment",
+	"entertainment television":           "Entertainment",
+	"entertainment musicals theatres":    "Entertainment",
+	"entertainment comics":               "Entertainment",
+	"entertainment japanese anime manga": "Entertainment",
+	"entertainment cartoon animations":   "Entertainment",
+	"celebrities":                        "People & Places",
+	"lang":                               "Language",
+}
+
+// Key is what spellings of one category share: its words in lower case,
+// without punctuation and "and", so "Science & Nature", "science and
+// nature" and "SCIENCE: NATURE " have the same key
+func Key(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	kept := words[:0]
+	for _, w := range words {
+		if w != "and" {
+			kept = append(kept, w)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// Tidy trims a name and collapses the runs of spaces in it
+func Tidy(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Map resolves spellings to canonical names: by an alias, else by a known
+// name with the same key, else the tidied spelling, which is then known.
+// The zero Map is not usable; use New.
+type Map struct {
+	byKey   map[string]string
+	aliases map[string]bool // keys that are aliases rather than names
+}
+
+// New returns a Map of the built-in Aliases, the canonical names and the
+// aliases given (alias spelling -> canonical name), which win over the
+// built-in ones
+func New(names []string, aliases map[string]string) *Map {
+	m := &Map{byKey: make(map[string]string), aliases: make(map[string]bool)}
+	for key, name := range Aliases {
+		m.Alias(key, name)
+	}
+	for _, name := range names {
+		m.Canonical(name)
+	}
+	for alias, name := range aliases {
+		m.Alias(alias, name)
+	}
+	return m
+}
+
+// Alias makes alias a spelling of the canonical name
+func (m *Map) Alias(alias, name string) {
+	key := Key(alias)
+	if key == "" {
+		return
+	}
+	name = Tidy(name)
+	m.byKey[key] = name
+	m.aliases[key] = key != Key(name)
+	if k := Key(name); m.byKey[k] == "" {
+		m.byKey[k] = name
+	}
+}
+
+// Canonical returns the canonical name of a spelling, or "" for a blank one
+func (m *Map) Canonical(spelling string) string {
+	key := Key(spelling)
+	if key == "" {
+		return ""
+	}
+	if name, ok := m.byKey[key]; ok {
+		return name
+	}
+	name := Tidy(spelling)
+	m.byKey[key] = name
+	return name
+}
+
+// AliasesOf returns the alias keys of each canonical name, sorted
+func (m *Map) AliasesOf() map[string][]string {
+	of := make(map[string][]string)
+	for key, name := range m.byKey {
+		if m.aliases[key] {
+			of[name] = append(of[name], key)
+		}
+	}
+	for _, keys := range of {
+		sort.Strings(keys)
+	}
+	return of
+}
@@ -0,0 +1,39 @@
+package category
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	for _, name := range []string{"Science & Nature", "science and nature", " SCIENCE:  NATURE "} {
+		if got := Key(name); got != "science nature" {
+			t.Errorf("Key(%q) = %q", name, got)
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	m := New([]string{"Geography", "History & Holidays"}, map[string]string{"Sci": "Science & Nature", "Pop": "Music"})
+	for spelling, want := range map[string]string{
+		"Sci":                  "Science & Nature",
+		"science":              "Science & Nature",
+		"SCIENCE ":             "Science & Nature",
+		"science & nature":     "Science & Nature",
+		" Geography":           "Geography",
+		"history":              "History & Holidays",
+		"Science: Computers":   "Technology & Video Games",
+		"Entertainment: Music": "Music",
+		"pop":                  "Music",
+		"  Cinema   Trivia ":   "Cinema Trivia",
+		"CINEMA TRIVIA":        "Cinema Trivia",
+		" ":                    "",
+	} {
+		if got := m.Canonical(spelling); got != want {
+			t.Errorf("Canonical(%q) = %q, want %q", spelling, got, want)
+		}
+	}
+	if got := m.AliasesOf()["Music"]; !reflect.DeepEqual(got, []string{"entertainment music", "pop"}) {
+		t.Errorf("aliases of Music = %v", got)
+	}
+}
@@ -0,0 +1,139 @@
+// Package cleanup tidies the text scraped from pages through a chain of
+// named steps, such as "html,control,whitespace,unwrap", so each source
+// chooses what to change. Steps only tidy: quote marks, apostrophes and
+// backslashes inside the text are kept, and the text as scraped is saved
+// next to the cleaned one.
+package cleanup
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Step is one change to the text
+type Step struct {
+	Name string
+	Doc  string
+	fn   func(string) string
+}
+
+var tags = regexp.MustCompile(`<[^>]+>`)
+
+// smartQuotes maps typographic quote marks to plain ones
+var smartQuotes = strings.NewReplacer("‘", "'", "’", "'", "‚", "'", "‛", "'", "“", `"`, "”", `"`, "„", `"`, "‟", `"`)
+
+// wrappers are the pairs of marks a whole quote may be wrapped in
+var wrappers = [][2]string{{`"`, `"`}, {"'", "'"}, {"“", "”"}, {"‘", "’"}, {"«", "»"}, {"„", "“"}, {"”", "”"}}
+
+// Steps are the steps a chain may name, in the order Default applies them
+var Steps = []Step{
+	{"html", "decode entities such as &amp; and drop tags", func(s string) string {
+		return tags.ReplaceAllString(html.UnescapeString(s), "")
+	}},
+	{"control", "turn line breaks and tabs into spaces and drop other control characters", func(s string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r == '\n' || r == '\r' || r == '\t':
+				return ' '
+			case unicode.IsControl(r):
+				return -1
+			}
+			return r
+		}, s)
+	}},
+	{"whitespace", "collapse runs of spaces into one and trim the ends", func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	}},
+	{"unwrap", "drop the quote marks around the whole text, leaving those inside", unwrap},
+	{"smart-quotes", "replace curly quote marks and apostrophes with straight ones", smartQuotes.Replace},
+}
+
+// unwrap drops the pairs of quote marks around the whole of s
+func unwrap(s string) string {
+	for {
+		s = strings.TrimSpace(s)
+		trimmed := s
+		for _, w := range wrappers {
+			if len(s) > len(w[0])+len(w[1]) && strings.HasPrefix(s, w[0]) && strings.HasSuffix(s, w[1]) {
+				inner := s[len(w[0]) : len(s)-len(w[1])]
+				// "A" and "B" is two quotes, not one wrapped
+				if !strings.Contains(inner, w[0]) && !strings.Contains(inner, w[1]) {
+					trimmed = inner
+					break
+				}
+			}
+		}
+		if trimmed == s {
+			return s
+		}
+		s = trimmed
+	}
+}
+
+// Chain is steps applied in order
+type Chain []Step
+
+// Default is the chain of sources that configure none
+var Default = MustParse("html,control,whitespace,unwrap")
+
+// Parse reads a chain from the comma-separated names of its steps; an empty
+// string is a chain that changes nothing
+func Parse(names string) (Chain, error) {
+	var c Chain
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, s := range Steps {
+			if s.Name == name {
+				c = append(c, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown cleanup step %q (steps: %s)", name, Chain(Steps))
+		}
+	}
+	return c, nil
+}
+
+// MustParse is Parse for chains known to be valid
+func MustParse(names string) Chain {
+	c, err := Parse(names)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Clean applies the chain to s
+func (c Chain) Clean(s string) string {
+	s, _ = c.Trace(s)
+	return s
+}
+
+// Trace is Clean that also names the steps that changed s
+func (c Chain) Trace(s string) (string, []string) {
+	var changed []string
+	for _, step := range c {
+		if cleaned := step.fn(s); cleaned != s {
+			changed = append(changed, step.Name)
+			s = cleaned
+		}
+	}
+	return s, changed
+}
+
+func (c Chain) String() string {
+	names := make([]string, len(c))
+	for i, s := range c {
+		names[i] = s.Name
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,51 @@
+package cleanup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	for _, tc := range []struct{ raw, want string }{
+		{`Don't say "never" \o/`, `Don't say "never" \o/`},
+		{"“Seni seviyorum,” dedi,\n\tve bu doğruydu.", "“Seni seviyorum,” dedi, ve bu doğruydu."},
+		{"  “Hayatında ilk kez <b>kendini</b> normal hissetti.”  ", "Hayatında ilk kez kendini normal hissetti."},
+		{`"'Wrapped twice.'"`, "Wrapped twice."},
+		{`"Yes," she said, "no."`, `"Yes," she said, "no."`},
+		{"Rock &amp; roll\x00\x7f ain't noise", "Rock & roll ain't noise"},
+		{"«Az olsun, öz olsun.»", "Az olsun, öz olsun."},
+	} {
+		if got := Default.Clean(tc.raw); got != tc.want {
+			t.Errorf("Clean(%q) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	c, err := Parse(" whitespace, smart-quotes ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.String() != "whitespace,smart-quotes" {
+		t.Errorf("Parse = %s", c)
+	}
+	if got := c.Clean("It’s  “fine”"); got != `It's "fine"` {
+		t.Errorf("Clean = %q", got)
+	}
+	if c, err := Parse(""); err != nil || len(c) != 0 || c.Clean(" <b>x</b> ") != " <b>x</b> " {
+		t.Errorf("the empty chain changed text or failed: %v", err)
+	}
+	if _, err := Parse("html,sanitize"); err == nil {
+		t.Error("Parse accepted an unknown step")
+	}
+}
+
+func TestTrace(t *testing.T) {
+	clean, changed := Default.Trace("  “<i>Az</i> olsun,\töz olsun.”")
+	if clean != "Az olsun, öz olsun." || strings.Join(changed, ",") != "html,control,whitespace,unwrap" {
+		t.Errorf("Trace = %q, %q", clean, changed)
+	}
+	if _, changed := Default.Trace("Az olsun, öz olsun."); changed != nil {
+		t.Errorf("Trace of a clean text = %q", changed)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"quotesparser/archive"
+	"quotesparser/fetch"
+	"quotesparser/meter"
+	"quotesparser/quota"
+	"quotesparser/store"
+)
+
+func runArchive(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes archive add|list [flags]")
+	}
+	switch args[0] {
+	case "add":
+		return runArchiveAdd(args[1:])
+	case "list":
+		return runArchiveList(args[1:])
+	default:
+		return fmt.Errorf("unknown archive action %q (add, list)", args[0])
+	}
+}
+
+// openArchive opens the archive at path: a .tar.gz file, or the rawPages
+// table of the database at any other path
+func openArchive(path string) (archive.Archive, error) {
+	if archive.IsTarball(path) {
+		return archive.OpenTarball(path), nil
+	}
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateDB(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return dbArchive{archive.Table{DB: db}}, nil
+}
+
+// dbArchive is an archive table whose database closes with it
+type dbArchive struct{ archive.Table }
+
+func (a dbArchive) Close() error { return a.DB.Close() }
+
+// runArchiveAdd archives the pages download saved into folders, with the
+// addresses and times it recorded next to them
+func runArchiveAdd(args []string) error {
+	fs := flag.NewFlagSet("archive add", flag.ExitOnError)
+	to := fs.String("to", "database.db", "archive to add the pages to: a .tar.gz file, or the database whose rawPages table keeps them")
+	remove := fs.Bool("remove", false, "delete the pages once archived")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: quotes archive add [flags] <source> <files or folders>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errors.New("archive add needs a source and the pages to archive")
+	}
+	name := fs.Arg(0)
+
+	files, err := expandInputs(fs.Args()[1:], "*")
+	if err != nil {
+		return err
+	}
+	var pages []string
+	for _, file := range files {
+		base := filepath.Base(file)
+		if base == pageURLsFile || strings.HasPrefix(base, ".") {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			pages = append(pages, file)
+		}
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("no pages to archive")
+	}
+
+	a, err := openArchive(*to)
+	if err != nil {
+		return err
+	}
+	m := meter.New(progress, "Archiving", "pages", len(pages))
+	archived := 0
+	for _, file := range pages {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			a.Close()
+			return fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		prov := pageProvenance(file, "")
+		url := prov.URL
+		if url == "" {
+			url = file
+		}
+		fetchedAt, err := time.Parse(time.RFC3339, prov.FetchedAt)
+		if err != nil {
+			fetchedAt = time.Now()
+		}
+		page, err := archive.NewPage(name, url, fetchedAt, body)
+		if err == nil {
+			err = a.Put(page)
+		}
+		if err != nil {
+			a.Close()
+			return err
+		}
+		archived++
+		m.Add(1, int64(len(body)))
+	}
+	m.Finish()
+	if err := a.Close(); err != nil {
+		return err
+	}
+	if *remove {
+		for _, file := range pages {
+			if err := os.Remove(file); err != nil {
+				return fmt.Errorf("failed to remove %s: %v", file, err)
+			}
+		}
+	}
+	fmt.Fprintf(progress, "✓ Archived %d pages of %s into %s\n", archived, name, *to)
+	return nil
+}
+
+// archiveSummary is what an archive keeps of a source
+type archiveSummary struct {
+	pages, urls      int
+	size, compressed int64
+	oldest, latest   time.Time
+	seen             map[string]bool
+}
+
+// runArchiveList tells, for each source, how many pages an archive keeps
+// and how much they take
+func runArchiveList(args []string) error {
+	fs := flag.NewFlagSet("archive list", flag.ExitOnError)
+	from := fs.String("from", "database.db", "archive to list: a .tar.gz file, or the database whose rawPages table keeps the pages")
+	name := fs.String("source", "", "list the pages of this source, one a line")
+	fs.Parse(args)
+
+	a, err := openArchive(*from)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	if *name != "" {
+		return a.Walk(*name, func(p archive.Page) error {
+			fmt.Printf("%s\t%d\t%s\n", p.FetchedAt.Format(time.RFC3339), p.Size, p.URL)
+			return nil
+		})
+	}
+
+	sources := map[string]*archiveSummary{}
+	err = a.Walk("", func(p archive.Page) error {
+		s := sources[p.Source]
+		if s == nil {
+			s = &archiveSummary{oldest: p.FetchedAt, seen: map[string]bool{}}
+			sources[p.Source] = s
+		}
+		s.pages++
+		if !s.seen[p.URL] {
+			s.seen[p.URL] = true
+			s.urls++
+		}
+		s.size += p.Size
+		s.compressed += p.Compressed()
+		if p.FetchedAt.Before(s.oldest) {
+			s.oldest = p.FetchedAt
+		}
+		if p.FetchedAt.After(s.latest) {
+			s.latest = p.FetchedAt
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		fmt.Fprintf(progress, "No pages archived in %s\n", *from)
+		return nil
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := sources[name]
+		fmt.Printf("%-16s %6d pages of %6d addresses  %s, %s gzipped  %s to %s\n", name, s.pages, s.urls,
+			quota.FormatSize(uint64(s.size)), quota.FormatSize(uint64(s.compressed)), s.oldest.Format(time.DateOnly), s.latest.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// archivedPages are the pages parse --archive reads in place of files, by
+// the names parse gives them: the archive's path, # and their address
+type archivedPages struct {
+	path  string
+	pages map[string]archive.Page
+}
+
+// loadArchived reads the latest copy of each page of source from the
+// archive at path, returning the names to parse them by
+func loadArchived(path, source string) (archivedPages, []string, error) {
+	set := archivedPages{path: path, pages: map[string]archive.Page{}}
+	if path == "" {
+		return set, nil, nil
+	}
+	if !archive.IsTarball(path) {
+		if _, err := os.Stat(path); err != nil {
+			return set, nil, fmt.Errorf("no archive at %s", path)
+		}
+	}
+	a, err := openArchive(path)
+	if err != nil {
+		return set, nil, err
+	}
+	defer a.Close()
+	pages, err := archive.Latest(a, source)
+	if err != nil {
+		return set, nil, err
+	}
+	names := make([]string, len(pages))
+	for i, p := range pages {
+		names[i] = path + "#" + p.URL
+		set.pages[names[i]] = p
+	}
+	return set, names, nil
+}
+
+// has reports whether the page named name is archived
+func (s archivedPages) has(name string) bool {
+	_, ok := s.pages[name]
+	return ok
+}
+
+// read returns the page named name, from the archive or its file
+func (s archivedPages) read(name string) ([]byte, error) {
+	if p, ok := s.pages[name]; ok {
+		return p.Body()
+	}
+	return os.ReadFile(name)
+}
+
+// url returns the address of the page named name
+func (s archivedPages) url(name string) string {
+	if p, ok := s.pages[name]; ok {
+		return p.URL
+	}
+	return pageURL(name)
+}
+
+// provenance is pageProvenance for pages that may be archived
+func (s archivedPages) provenance(name, parser string) store.Provenance {
+	if p, ok := s.pages[name]; ok {
+		return store.Provenance{URL: p.URL, File: s.path, FetchedAt: p.FetchedAt.Format(time.RFC3339), Parser: parser}
+	}
+	return pageProvenance(name, parser)
+}
+
+// addArchiveFlag registers the --archive flag of downloaders and returns a
+// function making a client keep the pages it downloads in the archive the
+// flag names, and ask the site only for those changed since. It returns nil
+// without the flag.
+func addArchiveFlag(fs *flag.FlagSet, keeps string) func(client *fetch.Client, source string) (*archive.Cache, error) {
+	path := fs.String("archive", "", "keep the pages gzipped in this archive "+keeps+", and download again only those the site says changed: a .tar.gz file, or a database whose rawPages table keeps them")
+	return func(client *fetch.Client, source string) (*archive.Cache, error) {
+		if *path == "" {
+			return nil, nil
+		}
+		a, err := openArchive(*path)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(progress, "Archiving pages into %s\n", *path)
+		cache := &archive.Cache{Archive: a, Source: source}
+		client.Cache = cache
+		return cache, nil
+	}
+}
+
+// closeArchive closes the archive of cache, if any, after a download that
+// ended with err
+func closeArchive(cache *archive.Cache, err error) error {
+	if cache == nil {
+		return err
+	}
+	if n := cache.Reused(); n > 0 {
+		fmt.Fprintf(progress, "  Unchanged since archived: %d pages\n", n)
+	}
+	if closeErr := cache.Archive.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"quotesparser/archive"
+	"quotesparser/source"
+)
+
+func TestArchive(t *testing.T) {
+	fakeSite(t)
+
+	dir := t.TempDir()
+	pagesDir := filepath.Join(dir, "pages")
+	dbPath := filepath.Join(dir, "database.db")
+	tarball := filepath.Join(dir, "pages.tar.gz")
+	run := func(fn func([]string) error, args ...string) {
+		t.Helper()
+		if err := fn(args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	parsed := func(args ...string) []source.Quote {
+		t.Helper()
+		out := filepath.Join(t.TempDir(), "quotes.json")
+		run(runParse, append([]string{"quotes-example", "--out", out}, args...)...)
+		content, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var quotes []source.Quote
+		if err := json.Unmarshal(content, &quotes); err != nil {
+			t.Fatal(err)
+		}
+		return quotes
+	}
+
+	// Pages downloaded into an archive leave no files
+	run(runDownload, "quotes-example", "--pages", "5", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", pagesDir, "--archive", tarball, "tag/love")
+	if _, err := os.Stat(filepath.Join(pagesDir, "tag_love")); err == nil {
+		t.Error("download --archive saved pages into the folder")
+	}
+	quotes := parsed("--archive", tarball)
+	if len(quotes) != 3 {
+		t.Fatalf("parsed %d quotes from the tarball, want 3", len(quotes))
+	}
+	if q := quotes[2]; q.SourceURL != "https://quotes.example/tag/love?page=2" || q.SourceFile != tarball || q.FetchedAt == "" {
+		t.Errorf("provenance of a quote read from the tarball = %s %s %s", q.SourceURL, q.SourceFile, q.FetchedAt)
+	}
+
+	// Downloading again asks the site whether the pages changed, which
+	// they did not: the archive keeps its copies, and they still parse
+	tarPages := func() (n int) {
+		t.Helper()
+		err := archive.OpenTarball(tarball).Walk("", func(p archive.Page) error {
+			if p.LastModified == "" {
+				t.Errorf("%s archived without its Last-Modified", p.URL)
+			}
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	archived := tarPages()
+	run(runDownload, "quotes-example", "--pages", "5", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", pagesDir, "--archive", tarball, "tag/love")
+	if n := tarPages(); n != archived {
+		t.Errorf("archive has %d pages after downloading unchanged pages again, want %d", n, archived)
+	}
+	if quotes := parsed("--archive", tarball); len(quotes) != 3 {
+		t.Errorf("parsed %d quotes after downloading again, want 3", len(quotes))
+	}
+
+	// Pages downloaded into a folder are archived into the database
+	run(runDownload, "quotes-example", "--pages", "5", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", pagesDir, "tag/love")
+	run(runArchive, "add", "--to", dbPath, "--remove", "quotes-example", filepath.Join(pagesDir, "tag_love"))
+	if _, err := os.Stat(filepath.Join(pagesDir, "tag_love", "page1.html")); err == nil {
+		t.Error("archive add --remove kept the pages")
+	}
+	// Archiving the same downloads again keeps one copy of each
+	run(runDownload, "quotes-example", "--pages", "5", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", pagesDir, "--archive", dbPath, "tag/love")
+	run(runArchive, "list", "--from", dbPath)
+	run(runArchive, "list", "--from", tarball, "--source", "quotes-example")
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var pages, urls int
+	if err := db.QueryRow("SELECT COUNT(*), COUNT(DISTINCT url) FROM rawPages WHERE source = 'quotes-example'").Scan(&pages, &urls); err != nil {
+		t.Fatal(err)
+	}
+	if urls != 2 || pages < 2 || pages > 4 {
+		t.Errorf("database archive has %d pages of %d addresses, want 2 addresses", pages, urls)
+	}
+	if quotes := parsed("--archive", dbPath); len(quotes) != 3 || quotes[0].SourceURL != "https://quotes.example/tag/love" {
+		t.Errorf("parsed %+v from the database, want the 3 quotes once", quotes)
+	}
+}
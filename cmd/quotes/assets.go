@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"log/slog"
+	"time"
+
+	"quotesparser/covers"
+	"quotesparser/fetch"
+	"quotesparser/imgcache"
+	"quotesparser/quota"
+)
+
+// assetsUserAgent identifies the downloader to OpenLibrary and Wikimedia,
+// which both ask clients for a way to contact them
+const assetsUserAgent = "quotesparser-assets/1.0 (book covers and author pictures for a quotes database)"
+
+// runAssets caches the images the enrichment steps found, the covers quotes
+// books saved in books.coverUrl and the pictures quotes authors enrich saved
+// in authors.imageUrl, so a display or web page can show them offline. The
+// cache is shared by address: an image is kept once under its hash however
+// many books or authors point to it, and each address is recorded in assets
+// with the hash of its image. Addresses fetched before, found or not, are
+// skipped unless --refresh.
+func runAssets(args []string) error {
+	fs := flag.NewFlagSet("assets", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose images to cache")
+	dir := fs.String("dir", "assets", "folder to cache images in, as <hash>/<width>.jpg")
+	sizes := fs.String("sizes", joinWidths(covers.DefaultSizes), "widths to cache each image at")
+	limit := fs.Int("limit", 0, "fetch at most this many images (0 = all)")
+	refresh := fs.Bool("refresh", false, "fetch images again, including those missing last time")
+	delay := fs.Duration("delay", 1*time.Second, "pause between images")
+	userAgent := fs.String("user-agent", assetsUserAgent, "user agent; OpenLibrary and Wikimedia ask for one with a way to contact you")
+	guard := addGuardFlags(fs)
+	configure := addFetchFlags(fs)
+	fs.Parse(args)
+
+	widths, err := parseWidths(*sizes)
+	if err != nil {
+		return err
+	}
+	g, err := guard(*dir)
+	if err != nil {
+		return err
+	}
+	client := fetch.NewClient()
+	client.UserAgent = *userAgent
+	if err := configure(client); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	query := "SELECT coverUrl FROM books WHERE coverUrl IS NOT NULL UNION SELECT imageUrl FROM authors WHERE imageUrl IS NOT NULL"
+	if !*refresh {
+		query = "SELECT coverUrl FROM (" + query + ") WHERE coverUrl NOT IN (SELECT url FROM assets)"
+	}
+	query += " ORDER BY 1"
+	if *limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", *limit)
+	}
+	var urls []string
+	rows, err := db.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to read image addresses: %v", err)
+	}
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read image addresses: %v", err)
+		}
+		urls = append(urls, url)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read image addresses: %v", err)
+	}
+
+	fmt.Fprintf(progress, "Caching %d images...\n", len(urls))
+	saved, shared, missing, failed := 0, 0, 0, 0
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+	for i, url := range urls {
+		if i > 0 {
+			fetch.Sleep(ctx, *delay)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		img, err := client.GetContext(ctx, url)
+		if err == nil {
+			if _, _, decodeErr := image.DecodeConfig(bytes.NewReader(img)); decodeErr != nil {
+				err = fmt.Errorf("%w: %v", errNotImage, decodeErr)
+			}
+		}
+		switch {
+		case err == nil:
+			hash, cached, known, err := cacheAsset(db, *dir, img, widths, g)
+			if quota.IsLimit(err) {
+				return err
+			}
+			if err != nil {
+				slog.Error("failed to cache image", "url", url, "err", err)
+				failed++
+				continue
+			}
+			if known {
+				shared++
+			} else {
+				saved++
+			}
+			if err := saveAsset(db, url, hash, cached); err != nil {
+				return err
+			}
+		case errors.Is(err, fetch.ErrNotFound), errors.Is(err, errNotImage):
+			missing++
+			if err := saveAsset(db, url, "", nil); err != nil {
+				return err
+			}
+		case ctx.Err() != nil:
+			// Interrupted mid-download
+		case quota.IsLimit(err):
+			return err
+		default:
+			// Network trouble: leave the image for the next run
+			slog.Error("failed to fetch image", "url", url, "err", err)
+			failed++
+		}
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Caching interrupted; the rest are left for the next run\n")
+	} else {
+		fmt.Fprintf(progress, "\n✓ Caching completed into %s/\n", *dir)
+	}
+	fmt.Fprintf(progress, "  Saved: %d\n", saved)
+	fmt.Fprintf(progress, "  Already cached for another address: %d\n", shared)
+	fmt.Fprintf(progress, "  Missing or not an image: %d\n", missing)
+	fmt.Fprintf(progress, "  Failed: %d\n", failed)
+	return nil
+}
+
+// errNotImage is an address answering with something other than an image
+var errNotImage = errors.New("not an image")
+
+// cacheAsset writes the variants of img under its hash and returns the
+// widths written, or those already cached when another address served the
+// same image before, which it reports
+func cacheAsset(db *sql.DB, dir string, img []byte, widths []int, g *quota.Guard) (string, []int, bool, error) {
+	hash := imgcache.Hash(img)
+	var cached string
+	err := db.QueryRow("SELECT COALESCE(widths, '') FROM assets WHERE hash = ? AND status = 'ok' LIMIT 1", hash).Scan(&cached)
+	switch {
+	case err == nil && cached == "":
+		return hash, nil, true, nil
+	case err == nil:
+		saved, err := parseWidths(cached)
+		return hash, saved, true, err
+	case err != sql.ErrNoRows:
+		return "", nil, false, fmt.Errorf("failed to read assets: %v", err)
+	}
+	_, saved, err := imgcache.SaveHashed(dir, img, widths, g)
+	return hash, saved, false, err
+}
+
+// saveAsset records what an address served; an empty hash records that it
+// served no image
+func saveAsset(db *sql.DB, url, hash string, widths []int) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	var err error
+	if hash == "" {
+		_, err = db.Exec("INSERT OR REPLACE INTO assets (url, status, fetchedAt) VALUES (?, 'none', ?)", url, now)
+	} else {
+		_, err = db.Exec("INSERT OR REPLACE INTO assets (url, status, hash, widths, fetchedAt) VALUES (?, 'ok', ?, ?, ?)",
+			url, hash, joinWidths(widths), now)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save asset: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"quotesparser/imgcache"
+)
+
+func TestAssets(t *testing.T) {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 500, 750)), nil); err != nil {
+		t.Fatal(err)
+	}
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		// The same picture at two addresses
+		case "/covers/1.jpg", "/commons/Sally_Rooney.jpg":
+			w.Write(img.Bytes())
+		case "/commons/Amos_Oz.jpg":
+			w.Write([]byte("<html>File not found</html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	dir := filepath.Join(t.TempDir(), "assets")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO authors (id, name, imageUrl) VALUES (1, 'Sally Rooney', '" + srv.URL + "/commons/Sally_Rooney.jpg'), (2, 'Amos Oz', '" + srv.URL + "/commons/Amos_Oz.jpg')",
+		"INSERT INTO books (id, title, authorId, coverUrl) VALUES (1, 'Normal People', 1, '" + srv.URL + "/covers/1.jpg'), (2, 'Gone', 2, '" + srv.URL + "/covers/2.jpg')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{"--db", dbPath, "--dir", dir, "--delay", "0", "--min-free", "0", "--retries", "0"}
+	if err := runAssets(args); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT url, status, COALESCE(hash, ''), COALESCE(widths, '') FROM assets ORDER BY url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][3]string)
+	for rows.Next() {
+		var url string
+		var a [3]string
+		if err := rows.Scan(&url, &a[0], &a[1], &a[2]); err != nil {
+			t.Fatal(err)
+		}
+		got[url] = a
+	}
+	rows.Close()
+	hash := imgcache.Hash(img.Bytes())
+	for path, want := range map[string][3]string{
+		"/covers/1.jpg":             {"ok", hash, "96,240,480"},
+		"/commons/Sally_Rooney.jpg": {"ok", hash, "96,240,480"},
+		"/commons/Amos_Oz.jpg":      {"none", "", ""},
+		"/covers/2.jpg":             {"none", "", ""},
+	} {
+		if got[srv.URL+path] != want {
+			t.Errorf("asset %s = %q, want %q", path, got[srv.URL+path], want)
+		}
+	}
+	for _, w := range []int{96, 240, 480} {
+		if _, err := os.Stat(imgcache.HashPath(dir, hash, w)); err != nil {
+			t.Errorf("missing %d variant: %v", w, err)
+		}
+	}
+	// One folder for the image both addresses serve
+	if folders, err := os.ReadDir(dir); err != nil || len(folders) != 1 {
+		t.Errorf("cache has %d folders (%v), want 1", len(folders), err)
+	}
+
+	// Addresses fetched once, found or not, are not fetched again
+	atomic.StoreInt32(&requests, 0)
+	if err := runAssets(args); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("second run made %d requests, want none", n)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"quotesparser/fetch"
+	"quotesparser/origin"
+	"quotesparser/quota"
+	"quotesparser/schema"
+	"quotesparser/wikidata"
+)
+
+func runAuthors(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes authors merge|aliases|enrich [flags]")
+	}
+	switch args[0] {
+	case "merge":
+		return runAuthorsMerge(args[1:])
+	case "aliases":
+		return runAuthorsAliases(args[1:])
+	case "enrich":
+		return runAuthorsEnrich(args[1:])
+	default:
+		return fmt.Errorf("unknown authors action %q (merge, aliases, enrich)", args[0])
+	}
+}
+
+// runAuthorsMerge merges the authors written differently across sources,
+// such as "Sabahattin Ali" and "SABAHATTİN ALİ", into their canonical
+// spelling and suggests the pairs too far apart to merge unasked. With
+// --into it merges the authors named on the command line instead.
+func runAuthorsMerge(args []string) error {
+	fs := flag.NewFlagSet("authors merge", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose authors to merge")
+	dryRun := fs.Bool("dry-run", false, "report the merges without changing the database")
+	into := fs.String("into", "", "merge the authors named as arguments into this one, e.g. after a suggestion")
+	threshold := fs.Float64("threshold", 0.85, "similarity from 0 to 1 at which to suggest two names are one author")
+	show := fs.Int("show", 20, "merges and suggestions to print (-1 = all)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: quotes authors merge [flags] [--into <author> <other spellings>]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if (*into == "") != (fs.NArg() == 0) {
+		fs.Usage()
+		return fmt.Errorf("--into and the authors to merge into it go together")
+	}
+	if *threshold <= 0 || *threshold > 1 {
+		return fmt.Errorf("--threshold must be above 0 and at most 1")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	var groups []schema.DuplicateGroup
+	if *into != "" {
+		g, err := schema.MergeAuthorsInto(tx, *into, fs.Args())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		groups = append(groups, g)
+	} else {
+		report, err := schema.MergeAuthors(tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		groups = report.Groups
+	}
+
+	merged := 0
+	for i, g := range groups {
+		merged += len(g.Drop)
+		if *show >= 0 && i >= *show {
+			fmt.Fprintf(progress, "  ... and %d more\n", len(groups)-i)
+			break
+		}
+		fmt.Fprintf(progress, "  %s ← %s\n", g.Keep, strings.Join(g.Drop, ", "))
+	}
+
+	// Suggestions leave out the authors merged in this run
+	var suggestions []schema.AuthorSuggestion
+	if *into == "" {
+		if suggestions, err = schema.SuggestAuthors(tx, *threshold); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if *dryRun {
+		tx.Rollback()
+		fmt.Fprintf(progress, "\n✓ Dry run, nothing changed\n")
+	} else {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		fmt.Fprintf(progress, "\n✓ Author merge completed\n")
+	}
+	fmt.Fprintf(progress, "  %d authors merged into %d\n", merged, len(groups))
+
+	if len(suggestions) > 0 {
+		fmt.Fprintf(progress, "\nMay be the same author; merge with quotes authors merge --into <first> <second>:\n")
+		for i, s := range suggestions {
+			if *show >= 0 && i >= *show {
+				fmt.Fprintf(progress, "  ... and %d more\n", len(suggestions)-i)
+				break
+			}
+			fmt.Fprintf(progress, "  %q %q (%.2f)\n", s.Keep, s.Drop, s.Score)
+		}
+	}
+	return nil
+}
+
+// runAuthorsAliases lists the other spellings merges recorded for authors
+func runAuthorsAliases(args []string) error {
+	fs := flag.NewFlagSet("authors aliases", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose author aliases to list")
+	fs.Parse(args)
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	aliases, err := schema.AuthorAliases(db)
+	if err != nil {
+		return err
+	}
+	for _, a := range aliases {
+		fmt.Printf("%s\t%s\n", a.Author, a.Alias)
+	}
+	fmt.Fprintf(progress, "✓ %d aliases\n", len(aliases))
+	return nil
+}
+
+// runAuthorsEnrich looks the authors of authors and frasesauthors up on
+// Wikidata and saves their birth and death years, nationality and a short
+// bio with them. Lookups are kept in authorLookups by name, found or not, so
+// a name is not looked up again unless --refresh.
+func runAuthorsEnrich(args []string) error {
+	fs := flag.NewFlagSet("authors enrich", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose authors to look up")
+	wikis := fs.String("wikis", "en,tr,es", "Wikipedia languages to find authors' articles in, in order")
+	limit := fs.Int("limit", 0, "look up at most this many authors (0 = all)")
+	refresh := fs.Bool("refresh", false, "look up authors again, including those not found last time")
+	delay := fs.Duration("delay", 1*time.Second, "pause between authors")
+	api := fs.String("api", wikidata.DefaultAPI, "Wikidata api.php address")
+	wikipediaAPI := fs.String("wikipedia-api", wikidata.DefaultWikipediaAPI, "MediaWiki api.php address bios are read from; %s is replaced by the language")
+	userAgent := fs.String("user-agent", wikidata.DefaultUserAgent, "user agent; Wikimedia asks for one with a way to contact you")
+	configure := addFetchFlags(fs)
+	fs.Parse(args)
+
+	f := wikidata.NewFinder()
+	f.API = *api
+	f.WikipediaAPI = *wikipediaAPI
+	f.Wikis = strings.Split(*wikis, ",")
+	f.Client.UserAgent = *userAgent
+	if err := configure(f.Client); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	query := "SELECT name FROM authors UNION SELECT authorName FROM frasesauthors"
+	if !*refresh {
+		query = "SELECT name FROM (" + query + ") WHERE name NOT IN (SELECT name FROM authorLookups)"
+	}
+	query += " ORDER BY 1"
+	var names []string
+	rows, err := db.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to read authors: %v", err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read authors: %v", err)
+		}
+		// Nobody to look up behind a proverb
+		if !origin.IsAnonymousName(name) && (*limit <= 0 || len(names) < *limit) {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read authors: %v", err)
+	}
+
+	fmt.Fprintf(progress, "Looking up %d authors on Wikidata (%s)...\n", len(names), *wikis)
+	found, missing, failed := 0, 0, 0
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+	for i, name := range names {
+		if i > 0 {
+			fetch.Sleep(ctx, *delay)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		p, err := f.Find(name)
+		switch {
+		case err == nil:
+			found++
+			fmt.Fprintf(progress, "  %s: %s (%s)\n", name, p.ID, lifetime(p))
+			if err := saveLookup(db, name, &p); err != nil {
+				return err
+			}
+		case errors.Is(err, wikidata.ErrNotFound):
+			missing++
+			if err := saveLookup(db, name, nil); err != nil {
+				return err
+			}
+		case quota.IsLimit(err):
+			return err
+		default:
+			// Network trouble: leave the author for the next run
+			slog.Error("failed to look up author", "author", name, "err", err)
+			failed++
+		}
+	}
+	filled, err := schema.FillAuthorMetadata(db)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Enrichment interrupted; the rest are left for the next run\n")
+	} else {
+		fmt.Fprintf(progress, "\n✓ Enrichment completed\n")
+	}
+	fmt.Fprintf(progress, "  Found: %d\n", found)
+	fmt.Fprintf(progress, "  Not on Wikidata: %d\n", missing)
+	fmt.Fprintf(progress, "  Failed: %d\n", failed)
+	fmt.Fprintf(progress, "  Authors filled in from earlier lookups: %d\n", filled)
+	return nil
+}
+
+// lifetime describes p briefly, e.g. 1907–1948, Türkiye
+func lifetime(p wikidata.Person) string {
+	var parts []string
+	switch {
+	case p.BirthYear != 0 && p.DeathYear != 0:
+		parts = append(parts, fmt.Sprintf("%d–%d", p.BirthYear, p.DeathYear))
+	case p.BirthYear != 0:
+		parts = append(parts, fmt.Sprintf("b. %d", p.BirthYear))
+	case p.DeathYear != 0:
+		parts = append(parts, fmt.Sprintf("d. %d", p.DeathYear))
+	}
+	if p.Nationality != "" {
+		parts = append(parts, p.Nationality)
+	}
+	if len(parts) == 0 {
+		return "no dates"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// saveLookup records the outcome of looking name up, and what was found on
+// the author of that name; a nil person records that none was found
+func saveLookup(db *sql.DB, name string, p *wikidata.Person) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if p == nil {
+		if _, err := db.Exec("INSERT OR REPLACE INTO authorLookups (name, status, fetchedAt) VALUES (?, 'none', ?)", name, now); err != nil {
+			return fmt.Errorf("failed to save lookup of %s: %v", name, err)
+		}
+		return nil
+	}
+	birth := sql.NullInt64{Int64: int64(p.BirthYear), Valid: p.BirthYear != 0}
+	death := sql.NullInt64{Int64: int64(p.DeathYear), Valid: p.DeathYear != 0}
+	nationality, bio, image := nullIfEmpty(p.Nationality), nullIfEmpty(p.Bio), nullIfEmpty(p.ImageURL)
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO authorLookups (name, status, wikidataId, birthYear, deathYear, nationality, bio, imageUrl, fetchedAt)
+		VALUES (?, 'ok', ?, ?, ?, ?, ?, ?, ?)`, name, p.ID, birth, death, nationality, bio, image, now)
+	if err != nil {
+		return fmt.Errorf("failed to save lookup of %s: %v", name, err)
+	}
+	_, err = db.Exec("UPDATE authors SET wikidataId = ?, birthYear = ?, deathYear = ?, nationality = ?, bio = ?, imageUrl = ? WHERE name = ?",
+		p.ID, birth, death, nationality, bio, image, name)
+	if err != nil {
+		return fmt.Errorf("failed to save metadata of %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"quotesparser/dedup"
+	"quotesparser/schema"
+)
+
+func TestAuthorsMerge(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	insert := func(text, author string) {
+		t.Helper()
+		if _, err := db.Exec("INSERT INTO quotes (text, author, lang, textHash) VALUES (?, ?, 'tr', ?)", text, author, dedup.TextHashIn(text, "tr")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, stmt := range []string{
+		`INSERT INTO authors (id, name) VALUES (1, 'SABAHATTİN ALİ'), (2, 'Sabahattin Ali'), (3, 'Sabahattin Ali '), (4, 'Sabahatin Ali'), (5, 'Oğuz Atay')`,
+		`INSERT INTO books (id, title, authorId) VALUES (1, 'Kürk Mantolu Madonna', 1), (2, 'Kürk Mantolu Madonna', 2)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert("Bir.", "SABAHATTİN ALİ - Kürk Mantolu Madonna")
+	insert("İki.", "Sabahattin Ali - Kürk Mantolu Madonna")
+	insert("Üç.", "Sabahatin Ali")
+	if _, err := db.Exec("UPDATE quotes SET authorId = 1, bookId = 1 WHERE text = 'Bir.'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE quotes SET authorId = 2, bookId = 2 WHERE text = 'İki.'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE quotes SET authorId = 4 WHERE text = 'Üç.'"); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func() []string {
+		t.Helper()
+		rows, err := db.Query("SELECT name FROM authors ORDER BY id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var names []string
+		for rows.Next() {
+			var name string
+			rows.Scan(&name)
+			names = append(names, name)
+		}
+		return names
+	}
+
+	if err := runAuthorsMerge([]string{"--db", dbPath, "--dry-run"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(names()); n != 5 {
+		t.Fatalf("the dry run left %d authors, want 5", n)
+	}
+	if err := runAuthorsMerge([]string{"--db", dbPath}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(), []string{"Sabahattin Ali", "Sabahatin Ali", "Oğuz Atay"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("authors = %q, want %q", got, want)
+	}
+	suggestions, err := schema.SuggestAuthors(db, 0.85)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Keep != "Sabahattin Ali" || suggestions[0].Drop != "Sabahatin Ali" {
+		t.Errorf("suggestions = %+v", suggestions)
+	}
+
+	if err := runAuthorsMerge([]string{"--db", dbPath, "--into", "Sabahattin Ali", "Nobody"}); err == nil {
+		t.Error("merging an unknown author was accepted")
+	}
+	if err := runAuthorsMerge([]string{"--db", dbPath, "--into", "Sabahattin Ali", "Sabahatin Ali"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(), []string{"Sabahattin Ali", "Oğuz Atay"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("authors = %q, want %q", got, want)
+	}
+
+	// A later quote under an alias is linked to the canonical author
+	insert("Dört.", "Sabahatin Ali - Kuyucaklı Yusuf")
+	insert("Beş.", "SABAHATTİN ALİ")
+	if _, err := schema.Normalize(db); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT author, authorId, COALESCE(bookId, 0) FROM quotes ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var author string
+		var authorID, bookID int64
+		rows.Scan(&author, &authorID, &bookID)
+		if authorID != 2 {
+			t.Errorf("%s is credited to author %d, want 2", author, authorID)
+		}
+		got = append(got, author)
+	}
+	want := []string{
+		"Sabahattin Ali - Kürk Mantolu Madonna",
+		"Sabahattin Ali - Kürk Mantolu Madonna",
+		"Sabahattin Ali",
+		"Sabahattin Ali - Kuyucaklı Yusuf",
+		"Sabahattin Ali",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("quotes.author = %q, want %q", got, want)
+	}
+	var books int
+	if err := db.QueryRow("SELECT COUNT(*) FROM books WHERE title = 'Kürk Mantolu Madonna'").Scan(&books); err != nil || books != 1 {
+		t.Errorf("%d copies of the merged book, want 1 (%v)", books, err)
+	}
+
+	aliases, err := schema.AuthorAliases(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 3 {
+		t.Errorf("aliases = %+v, want 3", aliases)
+	}
+}
+
+// fakeWikidata answers the lookups of authors enrich: Aristotle is on
+// English Wikipedia, Sabahattin Ali only on Turkish Wikipedia with no
+// article text, and the Yes of English Wikipedia is a band
+func fakeWikidata(t *testing.T) *httptest.Server {
+	type claims map[string][]map[string]interface{}
+	statement := func(rank string, value interface{}) map[string]interface{} {
+		return map[string]interface{}{"rank": rank, "mainsnak": map[string]interface{}{"datavalue": map[string]interface{}{"value": value}}}
+	}
+	item := func(id string) map[string]string { return map[string]string{"id": id} }
+	date := func(t string) map[string]string { return map[string]string{"time": t} }
+	items := map[string]map[string]interface{}{
+		"enwiki/Aristotle": {"id": "Q868", "sitelinks": map[string]interface{}{"enwiki": map[string]string{"title": "Aristotle"}},
+			"claims": claims{"P31": {statement("normal", item("Q5"))}, "P569": {statement("normal", date("-0384-00-00T00:00:00Z"))},
+				"P570": {statement("normal", date("-0322-00-00T00:00:00Z"))}, "P27": {statement("normal", item("Q41"))}}},
+		"trwiki/Sabahattin Ali": {"id": "Q312739", "sitelinks": map[string]interface{}{"trwiki": map[string]string{"title": "Sabahattin Ali"}},
+			"descriptions": map[string]interface{}{"tr": map[string]string{"value": "Türk yazar"}},
+			"claims": claims{"P31": {statement("normal", item("Q5"))}, "P569": {statement("normal", date("+1907-02-25T00:00:00Z"))},
+				"P570": {statement("normal", date("+1948-04-02T00:00:00Z"))},
+				"P27":  {statement("normal", item("Q12560")), statement("preferred", item("Q43"))},
+				"P18":  {statement("normal", "Sabahattin Ali 1930s.jpg")}}},
+		"enwiki/Yes": {"id": "Q182076", "claims": claims{"P31": {statement("normal", item("Q215380"))}}},
+	}
+	labels := map[string]string{"en/Q41": "Ancient Greece", "tr/Q43": "Türkiye", "tr/Q12560": "Osmanlı İmparatorluğu"}
+	extracts := map[string]string{"en/Aristotle": "Aristotle was an Ancient Greek philosopher\nand polymath. "}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var answer interface{}
+		switch {
+		case r.URL.Path != "/wikidata":
+			lang := strings.Split(r.URL.Path, "/")[1]
+			answer = map[string]interface{}{"query": map[string]interface{}{"pages": []interface{}{
+				map[string]string{"title": q.Get("titles"), "extract": extracts[lang+"/"+q.Get("titles")]},
+			}}}
+		case q.Get("ids") != "":
+			entities := map[string]interface{}{}
+			for _, id := range strings.Split(q.Get("ids"), "|") {
+				lang := q.Get("languages")
+				entities[id] = map[string]interface{}{"id": id, "labels": map[string]interface{}{lang: map[string]string{"value": labels[lang+"/"+id]}}}
+			}
+			answer = map[string]interface{}{"entities": entities}
+		default:
+			e, ok := items[q.Get("sites")+"/"+q.Get("titles")]
+			if !ok {
+				e = map[string]interface{}{"site": q.Get("sites"), "title": q.Get("titles"), "missing": ""}
+			}
+			answer = map[string]interface{}{"entities": map[string]interface{}{"-1": e}}
+		}
+		json.NewEncoder(w).Encode(answer)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAuthorsEnrich(t *testing.T) {
+	srv := fakeWikidata(t)
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO authors (id, name) VALUES (1, 'Aristotle'), (2, 'Yes'), (3, 'Anonymous')",
+		"INSERT INTO frasesauthors (authorName, authorLink, quoteCount) VALUES ('Sabahattin Ali', '/autor/sabahattin-ali', 1)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{"authors", "enrich", "--db", dbPath, "--api", srv.URL + "/wikidata", "--wikipedia-api", srv.URL + "/%s/api.php",
+		"--wikis", "en,tr", "--delay", "0"}
+	if err := runAuthors(args[1:]); err != nil {
+		t.Fatal(err)
+	}
+
+	type metadata struct {
+		ID, Nationality, Bio string
+		Birth, Death         int
+	}
+	read := func(query, name string) metadata {
+		t.Helper()
+		var id, nationality, bio sql.NullString
+		var birth, death sql.NullInt64
+		if err := db.QueryRow(query, name).Scan(&id, &birth, &death, &nationality, &bio); err != nil {
+			t.Fatal(err)
+		}
+		return metadata{id.String, nationality.String, bio.String, int(birth.Int64), int(death.Int64)}
+	}
+	const ofAuthor = "SELECT wikidataId, birthYear, deathYear, nationality, bio FROM authors WHERE name = ?"
+	const ofLookup = "SELECT wikidataId, birthYear, deathYear, nationality, bio FROM authorLookups WHERE name = ?"
+
+	if got, want := read(ofAuthor, "Aristotle"), (metadata{"Q868", "Ancient Greece", "Aristotle was an Ancient Greek philosopher and polymath.", -384, -322}); got != want {
+		t.Errorf("Aristotle = %+v, want %+v", got, want)
+	}
+	// Only the preferred citizenship, and the description for want of text
+	if got, want := read(ofLookup, "Sabahattin Ali"), (metadata{"Q312739", "Türkiye", "Türk yazar", 1907, 1948}); got != want {
+		t.Errorf("lookup of Sabahattin Ali = %+v, want %+v", got, want)
+	}
+	if got := read(ofAuthor, "Yes"); got != (metadata{}) {
+		t.Errorf("the band Yes was taken for the author: %+v", got)
+	}
+	var looked int
+	if err := db.QueryRow("SELECT COUNT(*) FROM authorLookups WHERE name = 'Anonymous'").Scan(&looked); err != nil || looked != 0 {
+		t.Errorf("Anonymous was looked up")
+	}
+
+	// Everyone has been looked up, so a second run asks for nothing and an
+	// author converted since gets the metadata of the earlier lookup
+	if _, err := db.Exec("INSERT INTO authors (id, name) VALUES (4, 'Sabahattin Ali')"); err != nil {
+		t.Fatal(err)
+	}
+	if err := runAuthors(append(args[1:], "--api", "http://127.0.0.1:1/wikidata", "--retries", "0")); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(ofAuthor, "Sabahattin Ali"); got.ID != "Q312739" || got.Birth != 1907 {
+		t.Errorf("Sabahattin Ali = %+v, want the earlier lookup", got)
+	}
+	var image string
+	if err := db.QueryRow("SELECT imageUrl FROM authors WHERE name = 'Sabahattin Ali'").Scan(&image); err != nil ||
+		image != "https://commons.wikimedia.org/wiki/Special:FilePath/Sabahattin_Ali_1930s.jpg?width=1024" {
+		t.Errorf("picture of Sabahattin Ali = %q (%v)", image, err)
+	}
+}
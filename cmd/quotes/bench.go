@@ -0,0 +1,242 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"quotesparser/dedup"
+	"quotesparser/migrations"
+	"quotesparser/store"
+)
+
+func runBench(args []string) error {
+	if len(args) < 1 || args[0] != "import" {
+		return fmt.Errorf("usage: quotes bench import [flags]")
+	}
+	return runBenchImport(args[1:])
+}
+
+type benchCase struct {
+	driver      string
+	journalMode string
+	synchronous string
+	batchSize   int
+	values      int // rows per INSERT statement
+}
+
+type benchResult struct {
+	benchCase
+	elapsed time.Duration
+	rate    float64
+	err     error
+}
+
+// runBenchImport inserts synthetic quotes into scratch databases under every
+// combination of settings and reports rows per second for each
+func runBenchImport(args []string) error {
+	fs := flag.NewFlagSet("bench import", flag.ExitOnError)
+	rows := fs.Int("rows", 100000, "synthetic quotes to insert per run")
+	batches := fs.String("batch", "1,100,1000,10000", "comma separated batch sizes (rows per transaction)")
+	valueLists := fs.String("values", "1,500", "comma separated rows per INSERT statement (1 = prepared single-row inserts)")
+	journals := fs.String("journal", "DELETE,WAL", "comma separated journal modes")
+	syncs := fs.String("sync", "FULL,NORMAL", "comma separated synchronous settings")
+	drivers := fs.String("driver", "sqlite3", "comma separated database/sql drivers")
+	dir := fs.String("dir", "", "folder for the scratch databases (default: system temp dir)")
+	fs.Parse(args)
+
+	batchSizes, err := parseInts(*batches)
+	if err != nil {
+		return fmt.Errorf("--batch: %v", err)
+	}
+	valueSizes, err := parseInts(*valueLists)
+	if err != nil {
+		return fmt.Errorf("--values: %v", err)
+	}
+
+	registered := make(map[string]bool)
+	for _, d := range sql.Drivers() {
+		registered[d] = true
+	}
+
+	var cases []benchCase
+	for _, driver := range splitList(*drivers) {
+		if !registered[driver] {
+			return fmt.Errorf("driver %q is not compiled in (available: %s)", driver, strings.Join(sql.Drivers(), ", "))
+		}
+		for _, journal := range splitList(*journals) {
+			for _, sync := range splitList(*syncs) {
+				for _, batch := range batchSizes {
+					for _, values := range valueSizes {
+						if values > batch {
+							continue
+						}
+						cases = append(cases, benchCase{driver, strings.ToUpper(journal), strings.ToUpper(sync), batch, values})
+					}
+				}
+			}
+		}
+	}
+
+	scratch, err := os.MkdirTemp(*dir, "quotes-bench-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch folder: %v", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	texts := syntheticQuotes(*rows)
+	fmt.Fprintf(progress, "Inserting %d synthetic quotes per run, %d runs\n\n", *rows, len(cases))
+	fmt.Fprintf(progress, "%-8s %-8s %-7s %7s %7s %10s %12s\n", "driver", "journal", "sync", "batch", "values", "time", "rows/sec")
+
+	var results []benchResult
+	for i, c := range cases {
+		path := filepath.Join(scratch, fmt.Sprintf("bench%d.db", i))
+		r := benchResult{benchCase: c}
+		r.elapsed, r.err = benchInsert(c, path, texts)
+		if r.err == nil {
+			r.rate = float64(len(texts)) / r.elapsed.Seconds()
+			fmt.Fprintf(progress, "%-8s %-8s %-7s %7d %7d %10s %12.0f\n", c.driver, c.journalMode, c.synchronous, c.batchSize, c.values, r.elapsed.Round(time.Millisecond), r.rate)
+		} else {
+			fmt.Fprintf(progress, "%-8s %-8s %-7s %7d %7d failed: %v\n", c.driver, c.journalMode, c.synchronous, c.batchSize, c.values, r.err)
+		}
+		results = append(results, r)
+		os.Remove(path)
+		os.Remove(path + "-wal")
+		os.Remove(path + "-shm")
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].rate > results[j].rate })
+	if len(results) == 0 || results[0].err != nil {
+		return fmt.Errorf("no run succeeded")
+	}
+
+	best := results[0]
+	fmt.Fprintf(progress, "\n✓ Recommendation for this machine:\n")
+	fmt.Fprintf(progress, "  PRAGMA journal_mode = %s\n", best.journalMode)
+	fmt.Fprintf(progress, "  PRAGMA synchronous = %s\n", best.synchronous)
+	fmt.Fprintf(progress, "  batch size: %d rows per transaction, %d rows per INSERT (%.0f rows/sec)\n", best.batchSize, best.values, best.rate)
+	if best.synchronous != "FULL" {
+		fmt.Fprintf(progress, "  note: synchronous=%s can lose the last transactions on power loss, but never corrupts the file in WAL mode\n", best.synchronous)
+	}
+	return nil
+}
+
+// benchInsert times inserting texts into a fresh database at path
+func benchInsert(c benchCase, path string, texts []string) (time.Duration, error) {
+	db, err := sql.Open(c.driver, path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, pragma := range []string{
+		"PRAGMA journal_mode = " + c.journalMode,
+		"PRAGMA synchronous = " + c.synchronous,
+	} {
+		if _, err := db.Exec(pragma); err != nil {
+			return 0, fmt.Errorf("%s: %v", pragma, err)
+		}
+	}
+	if _, err := migrations.Up(db, 0); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	for i := 0; i < len(texts); i += c.batchSize {
+		end := i + c.batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return 0, err
+		}
+		if c.values > 1 {
+			rows := make([][]interface{}, 0, end-i)
+			for _, text := range texts[i:end] {
+				rows = append(rows, []interface{}{text, "Bench Author - Bench Book", "en", dedup.TextHash(text)})
+			}
+			b := store.Batch{
+				Insert:   "INSERT INTO quotes (text, author, lang, textHash)",
+				Conflict: "ON CONFLICT(textHash) DO NOTHING",
+				Size:     c.values,
+			}
+			if err := b.Exec(tx, rows); err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			if err := tx.Commit(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		stmt, err := tx.Prepare("INSERT INTO quotes (text, author, lang, viewCount, textHash) VALUES (?, ?, ?, 0, ?) ON CONFLICT(textHash) DO NOTHING")
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		for _, text := range texts[i:end] {
+			if _, err := stmt.Exec(text, "Bench Author - Bench Book", "en", dedup.TextHash(text)); err != nil {
+				stmt.Close()
+				tx.Rollback()
+				return 0, err
+			}
+		}
+		stmt.Close()
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+	return time.Since(start), nil
+}
+
+// syntheticQuotes builds n distinct quote-sized sentences
+func syntheticQuotes(n int) []string {
+	words := strings.Fields("life love time heart world book night light memory silence road river dream hope fear truth freedom city sea letter")
+	rng := rand.New(rand.NewSource(1))
+	texts := make([]string, n)
+	for i := range texts {
+		var b strings.Builder
+		length := 8 + rng.Intn(25)
+		for w := 0; w < length; w++ {
+			if w > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(words[rng.Intn(len(words))])
+		}
+		fmt.Fprintf(&b, " #%d.", i)
+		texts[i] = b.String()
+	}
+	return texts
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func parseInts(s string) ([]int, error) {
+	var out []int
+	for _, part := range splitList(s) {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid number %q", part)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"quotesparser/covers"
+	"quotesparser/fetch"
+	"quotesparser/quota"
+)
+
+// runBooks looks every book up on OpenLibrary and saves its ISBN, year of
+// first publication, original title and cover address with it, so exports
+// can cite it. Books already looked up, found or not, are skipped unless
+// --refresh.
+func runBooks(args []string) error {
+	fs := flag.NewFlagSet("books", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose books to look up")
+	limit := fs.Int("limit", 0, "look up at most this many books (0 = all)")
+	refresh := fs.Bool("refresh", false, "look up books again, including those not found last time")
+	delay := fs.Duration("delay", 1*time.Second, "pause between books")
+	searchURL := fs.String("search-url", covers.DefaultSearchURL, "OpenLibrary search address")
+	coverURL := fs.String("cover-url", covers.DefaultCoverURL, "OpenLibrary cover address; %d is replaced by the cover id")
+	userAgent := fs.String("user-agent", covers.DefaultUserAgent, "user agent; OpenLibrary asks for one with a way to contact you")
+	configure := addFetchFlags(fs)
+	fs.Parse(args)
+
+	f := covers.NewFinder()
+	f.SearchURL = *searchURL
+	f.CoverURL = *coverURL
+	f.Client.UserAgent = *userAgent
+	if err := configure(f.Client); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	query := "SELECT b.id, b.title, COALESCE(a.name, '') FROM books b LEFT JOIN authors a ON a.id = b.authorId"
+	if !*refresh {
+		query += " WHERE b.detailsFetchedAt IS NULL"
+	}
+	query += " ORDER BY b.id"
+	if *limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", *limit)
+	}
+	type book struct {
+		id            int64
+		title, author string
+	}
+	var books []book
+	rows, err := db.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to read books: %v", err)
+	}
+	for rows.Next() {
+		var b book
+		if err := rows.Scan(&b.id, &b.title, &b.author); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read books: %v", err)
+		}
+		books = append(books, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read books: %v", err)
+	}
+
+	fmt.Fprintf(progress, "Looking up %d books on OpenLibrary...\n", len(books))
+	found, missing, failed := 0, 0, 0
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+	for i, b := range books {
+		if i > 0 {
+			fetch.Sleep(ctx, *delay)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		details, err := f.Describe(b.title, b.author)
+		switch {
+		case err == nil:
+			found++
+			fmt.Fprintf(progress, "  %s: %s\n", b.title, describeBook(details))
+			if err := saveBookDetails(db, b.id, &details); err != nil {
+				return err
+			}
+		case errors.Is(err, covers.ErrNoBook):
+			missing++
+			if err := saveBookDetails(db, b.id, nil); err != nil {
+				return err
+			}
+		case quota.IsLimit(err):
+			return err
+		default:
+			// Network trouble: leave the book for the next run
+			slog.Error("failed to look up book", "book", b.title, "err", err)
+			failed++
+		}
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Book lookup interrupted; the rest are left for the next run\n")
+	} else {
+		fmt.Fprintf(progress, "\n✓ Book lookup completed\n")
+	}
+	fmt.Fprintf(progress, "  Found: %d\n", found)
+	fmt.Fprintf(progress, "  Not on OpenLibrary: %d\n", missing)
+	fmt.Fprintf(progress, "  Failed: %d\n", failed)
+	return nil
+}
+
+// describeBook summarizes b, e.g. Normal People, 2018, ISBN 9780571334650
+func describeBook(b covers.Book) string {
+	parts := []string{b.Work}
+	if b.OriginalTitle != "" {
+		parts = append(parts, b.OriginalTitle)
+	}
+	if b.Year != 0 {
+		parts = append(parts, fmt.Sprint(b.Year))
+	}
+	if b.ISBN != "" {
+		parts = append(parts, "ISBN "+b.ISBN)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// saveBookDetails records the outcome of a book's lookup; nil details
+// record that none were found
+func saveBookDetails(db *sql.DB, bookID int64, b *covers.Book) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	var err error
+	if b == nil {
+		_, err = db.Exec("UPDATE books SET detailsFetchedAt = ? WHERE id = ?", now, bookID)
+	} else {
+		_, err = db.Exec(`
+			UPDATE books SET work = ?, isbn = ?, publishYear = ?, originalTitle = ?, coverUrl = ?, detailsFetchedAt = ?
+			WHERE id = ?`,
+			nullIfEmpty(b.Work), nullIfEmpty(b.ISBN), sql.NullInt64{Int64: int64(b.Year), Valid: b.Year != 0},
+			nullIfEmpty(b.OriginalTitle), nullIfEmpty(b.CoverURL), now, bookID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save book details: %v", err)
+	}
+	return nil
+}
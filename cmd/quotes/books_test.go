@@ -0,0 +1,53 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestBooks(t *testing.T) {
+	srv := fakeOpenLibrary(t)
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO authors (id, name) VALUES (1, 'Sally Rooney')",
+		"INSERT INTO books (id, title, authorId) VALUES (1, 'Normal İnsanlar', NULL), (2, 'Unknown Book', 1)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{"--db", dbPath, "--search-url", srv.URL + "/search.json", "--cover-url", srv.URL + "/b/id/%d-L.jpg", "--delay", "0"}
+	if err := runBooks(args); err != nil {
+		t.Fatal(err)
+	}
+
+	var work, isbn, originalTitle, coverURL string
+	var year int
+	err = db.QueryRow("SELECT work, isbn, publishYear, originalTitle, coverUrl FROM books WHERE id = 1").
+		Scan(&work, &isbn, &year, &originalTitle, &coverURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work != "/works/OL2W" || isbn != "9780571334650" || year != 2018 || originalTitle != "Normal People" || coverURL != srv.URL+"/b/id/42-L.jpg" {
+		t.Errorf("book = %s, %s, %d, %s, %s", work, isbn, year, originalTitle, coverURL)
+	}
+
+	var missing, fetchedAt sql.NullString
+	if err := db.QueryRow("SELECT work, detailsFetchedAt FROM books WHERE id = 2").Scan(&missing, &fetchedAt); err != nil {
+		t.Fatal(err)
+	}
+	if missing.Valid || !fetchedAt.Valid {
+		t.Errorf("book not on OpenLibrary = %v, looked up %v", missing, fetchedAt)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"quotesparser/covers"
+	"quotesparser/fetch"
+	"quotesparser/imgcache"
+	"quotesparser/quota"
+)
+
+// runCovers looks every book up on OpenLibrary and caches the covers it
+// finds, recording them in bookCovers. Books already looked up, found or not,
+// are skipped unless --refresh; --prune trims the cache instead.
+func runCovers(args []string) error {
+	fs := flag.NewFlagSet("covers", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose books to look up")
+	dir := fs.String("dir", "images/books", "folder to cache covers in, as <bookId>/<width>.jpg")
+	sizes := fs.String("sizes", joinWidths(covers.DefaultSizes), "widths to cache each cover at")
+	limit := fs.Int("limit", 0, "look up at most this many books (0 = all)")
+	refresh := fs.Bool("refresh", false, "look up books again, including those with no cover last time")
+	delay := fs.Duration("delay", 1*time.Second, "pause between books")
+	searchURL := fs.String("search-url", covers.DefaultSearchURL, "OpenLibrary search address")
+	coverURL := fs.String("cover-url", covers.DefaultCoverURL, "OpenLibrary cover address; %d is replaced by the cover id")
+	userAgent := fs.String("user-agent", covers.DefaultUserAgent, "user agent; OpenLibrary asks for one with a way to contact you")
+	guard := addGuardFlags(fs)
+	configure := addFetchFlags(fs)
+	prune := addPruneFlags(fs)
+	fs.Parse(args)
+
+	widths, err := parseWidths(*sizes)
+	if err != nil {
+		return err
+	}
+
+	g, err := guard(*dir)
+	if err != nil {
+		return err
+	}
+	f := covers.NewFinder()
+	f.SearchURL = *searchURL
+	f.CoverURL = *coverURL
+	f.Client.UserAgent = *userAgent
+	if err := configure(f.Client); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	if pruned, err := prune(db, *dir, coverImages); pruned || err != nil {
+		return err
+	}
+	if n, err := fillPalettes(db, *dir, coverImages); err != nil {
+		return err
+	} else if n > 0 {
+		fmt.Fprintf(progress, "Extracted the palettes of %d cached covers\n", n)
+	}
+
+	query := "SELECT b.id, b.title, COALESCE(a.name, '') FROM books b LEFT JOIN authors a ON a.id = b.authorId"
+	if !*refresh {
+		query += " WHERE b.id NOT IN (SELECT bookId FROM bookCovers)"
+	}
+	query += " ORDER BY b.id"
+	if *limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", *limit)
+	}
+	type book struct {
+		id            int64
+		title, author string
+	}
+	var books []book
+	rows, err := db.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to read books: %v", err)
+	}
+	for rows.Next() {
+		var b book
+		if err := rows.Scan(&b.id, &b.title, &b.author); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read books: %v", err)
+		}
+		books = append(books, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read books: %v", err)
+	}
+
+	fmt.Fprintf(progress, "Looking up %d books on OpenLibrary...\n", len(books))
+	found, missing, failed := 0, 0, 0
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+	for i, b := range books {
+		if i > 0 {
+			fetch.Sleep(ctx, *delay)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		c, saved, err := fetchCover(f, *dir, b.id, b.title, b.author, widths, g)
+		switch {
+		case err == nil:
+			found++
+			fmt.Fprintf(progress, "  %s: cover %d\n", b.title, c.ID)
+			if err := saveCover(db, b.id, &c, saved); err != nil {
+				return err
+			}
+			if err := savePalette(db, *dir, coverImages, b.id, saved); err != nil {
+				return err
+			}
+		case errors.Is(err, covers.ErrNotFound):
+			missing++
+			if err := saveCover(db, b.id, nil, nil); err != nil {
+				return err
+			}
+		case quota.IsLimit(err):
+			return err
+		default:
+			// Network trouble: leave the book for the next run
+			slog.Error("failed to fetch cover", "book", b.title, "err", err)
+			failed++
+		}
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Covers interrupted; the rest are left for the next run\n")
+	} else {
+		fmt.Fprintf(progress, "\n✓ Covers completed\n")
+	}
+	fmt.Fprintf(progress, "  Found: %d\n", found)
+	fmt.Fprintf(progress, "  No cover: %d\n", missing)
+	fmt.Fprintf(progress, "  Failed: %d\n", failed)
+	return nil
+}
+
+var coverImages = imageTable{table: "bookCovers", key: "bookId", parent: "books"}
+
+// fetchCover finds a book's cover and caches its variants
+func fetchCover(f *covers.Finder, dir string, bookID int64, title, author string, widths []int, g *quota.Guard) (covers.Cover, []int, error) {
+	c, err := f.Find(title, author)
+	if err != nil {
+		return c, nil, err
+	}
+	img, err := f.Download(c)
+	if err != nil {
+		return c, nil, err
+	}
+	saved, err := imgcache.Save(dir, bookID, img, widths, g)
+	return c, saved, err
+}
+
+// saveCover records the outcome of a book's lookup; a nil cover records that
+// none was found
+func saveCover(db *sql.DB, bookID int64, c *covers.Cover, widths []int) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	var err error
+	if c == nil {
+		_, err = db.Exec("INSERT OR REPLACE INTO bookCovers (bookId, status, fetchedAt) VALUES (?, 'none', ?)", bookID, now)
+	} else {
+		_, err = db.Exec(`
+			INSERT OR REPLACE INTO bookCovers (bookId, status, coverId, work, sourceUrl, widths, fetchedAt)
+			VALUES (?, 'ok', ?, ?, ?, ?, ?)`,
+			bookID, c.ID, c.Work, c.SourceURL, joinWidths(widths), now)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save cover: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"quotesparser/imgcache"
+)
+
+// fakeOpenLibrary knows Normal People, also by its Turkish title, and has a
+// cover for it only
+func fakeOpenLibrary(t *testing.T) *httptest.Server {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 500, 750)), nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/search.json":
+			var docs []interface{}
+			switch title := r.URL.Query().Get("title"); {
+			case title == "Normal People" && r.URL.Query().Get("author") == "Sally Rooney":
+				docs = append(docs,
+					map[string]interface{}{"key": "/works/OL1W", "title": "Normal People"},
+					map[string]interface{}{"key": "/works/OL2W", "title": "Normal People", "cover_i": 42})
+			case title == "Normal İnsanlar":
+				docs = append(docs, map[string]interface{}{"key": "/works/OL2W", "title": "Normal People", "cover_i": 42,
+					"isbn": []string{"0571334652", "978-0-571-33465-0"}, "first_publish_year": 2018})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"docs": docs})
+		case "/b/id/42-L.jpg":
+			w.Write(img.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCovers(t *testing.T) {
+	srv := fakeOpenLibrary(t)
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	cache := filepath.Join(t.TempDir(), "books")
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO authors (id, name) VALUES (1, 'Sally Rooney')",
+		"INSERT INTO books (id, title, authorId) VALUES (1, 'Normal People', 1), (2, 'Unknown Book', 1)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{"--db", dbPath, "--dir", cache, "--search-url", srv.URL + "/search.json",
+		"--cover-url", srv.URL + "/b/id/%d-L.jpg", "--delay", "0", "--min-free", "0"}
+	if err := runCovers(args); err != nil {
+		t.Fatal(err)
+	}
+
+	var status, work, widths string
+	if err := db.QueryRow("SELECT status, work, widths FROM bookCovers WHERE bookId = 1").Scan(&status, &work, &widths); err != nil {
+		t.Fatal(err)
+	}
+	if status != "ok" || work != "/works/OL2W" || widths != "96,240,480" {
+		t.Errorf("cover = %s, %s, %s", status, work, widths)
+	}
+	for _, w := range []int{96, 240, 480} {
+		if _, err := os.Stat(imgcache.Path(cache, 1, w)); err != nil {
+			t.Errorf("missing %d variant: %v", w, err)
+		}
+	}
+	if err := db.QueryRow("SELECT status FROM bookCovers WHERE bookId = 2").Scan(&status); err != nil || status != "none" {
+		t.Errorf("book without a cover recorded as %q (%v)", status, err)
+	}
+
+	// Covers cached before palettes were recorded get one on the next run
+	var colors string
+	if err := db.QueryRow("SELECT palette FROM bookCovers WHERE bookId = 1").Scan(&colors); err != nil || colors != "#000000" {
+		t.Errorf("palette of a black cover = %q (%v)", colors, err)
+	}
+	if _, err := db.Exec("UPDATE bookCovers SET palette = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCovers([]string{"--db", dbPath, "--dir", cache, "--min-free", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT palette FROM bookCovers WHERE bookId = 1").Scan(&colors); err != nil || colors != "#000000" {
+		t.Errorf("palette was not filled in: %q (%v)", colors, err)
+	}
+
+	// A stray folder from a deleted book goes; the cover of a live book stays
+	// until the cache is over its limit, and is then forgotten too
+	if err := os.MkdirAll(filepath.Join(cache, "9"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCovers([]string{"--db", dbPath, "--dir", cache, "--prune", "--min-free", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "9")); !os.IsNotExist(err) {
+		t.Errorf("orphaned folder was kept: %v", err)
+	}
+	if _, err := os.Stat(imgcache.Path(cache, 1, 96)); err != nil {
+		t.Errorf("live cover was pruned: %v", err)
+	}
+
+	if err := runCovers([]string{"--db", dbPath, "--dir", cache, "--prune", "--max-cache", "1KB", "--min-free", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(imgcache.Path(cache, 1, 96)); !os.IsNotExist(err) {
+		t.Errorf("cover over the cache limit was kept: %v", err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM bookCovers WHERE bookId = 1").Scan(&n); err != nil || n != 0 {
+		t.Errorf("evicted cover still recorded (%d rows, %v)", n, err)
+	}
+}
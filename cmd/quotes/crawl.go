@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"quotesparser/dedup"
+	"quotesparser/fetch"
+	"quotesparser/frases"
+	"quotesparser/kitap"
+	"quotesparser/meter"
+	"quotesparser/pipeline"
+)
+
+func runCrawl(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes crawl <source> [flags] (sources: 1000kitap, fraseslibros)")
+	}
+
+	switch args[0] {
+	case "1000kitap":
+		return runCrawl1000Kitap(args[1:])
+	case "fraseslibros":
+		return runCrawlFrases(args[1:])
+	default:
+		return fmt.Errorf("unknown source %q (sources: 1000kitap, fraseslibros)", args[0])
+	}
+}
+
+// runCrawl1000Kitap downloads, parses and inserts quotes in one pass without
+// keeping the raw pages around
+func runCrawl1000Kitap(args []string) error {
+	fs := flag.NewFlagSet("crawl 1000kitap", flag.ExitOnError)
+	var books, authors stringList
+	fs.Var(&books, "book", "book slug or URL (repeatable)")
+	fs.Var(&authors, "author", "author slug or URL (repeatable)")
+	dbPath := fs.String("db", "database.db", "SQLite database to insert into")
+	pages := fs.Int("pages", 100, "number of quote pages to crawl per book or author")
+	delay := fs.Duration("delay", 1*time.Second, "pause between requests of each fetcher")
+	fetchers := fs.Int("fetchers", 2, "concurrent downloads")
+	maxPages := fs.Int("max-pages", 8, "downloaded pages held in memory at once")
+	maxRows := fs.Int("max-rows", 1000, "parsed quotes held in memory waiting to be inserted")
+	batchSize := fs.Int("batch", 100, "quotes per insert transaction (sent as one multi-row INSERT)")
+	logEvery := fs.Duration("log-interval", 10*time.Second, "how often to log queue depths (0 disables)")
+	langOf := addLangFlag(fs)
+	loadRules := addRulesFlag(fs)
+	configure := addScraperFlags(fs)
+	fs.Parse(args)
+
+	rules, err := loadRules()
+	if err != nil {
+		return err
+	}
+
+	var urls []string
+	authorPage := make(map[string]bool)
+	for _, b := range books {
+		book, err := kitap.ParseBook(b)
+		if err != nil {
+			return err
+		}
+		for p := 1; p <= *pages; p++ {
+			urls = append(urls, book.QuotesURL(p))
+		}
+	}
+	for _, a := range authors {
+		author, err := kitap.ParseAuthor(a)
+		if err != nil {
+			return err
+		}
+		for p := 1; p <= *pages; p++ {
+			authorPage[author.QuotesURL(p)] = true
+			urls = append(urls, author.QuotesURL(p))
+		}
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("at least one --book or --author is required")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	bloomPath := *dbPath + ".bloom"
+	bloom, err := loadQuoteBloom(db, bloomPath)
+	if err != nil {
+		return err
+	}
+
+	client := fetch.NewClient()
+	client.Validate = fetch.CompleteHTML
+	if err := configure(client); err != nil {
+		return err
+	}
+	seen := dedup.New()
+	version := parserVersion("1000kitap")
+	parse := func(p pipeline.Page) ([]kitap.Quote, error) {
+		var quotes []kitap.Quote
+		var err error
+		if authorPage[p.URL] {
+			quotes, err = rules.ParseAuthorQuotes(string(p.Body))
+		} else {
+			quotes, err = rules.ParseQuotes(string(p.Body))
+		}
+
+		// The same quote shows up on both book and author pages
+		unique := quotes[:0]
+		fetchedAt := time.Now().UTC().Format(time.RFC3339)
+		for _, q := range quotes {
+			if seen.Add(q.QuoteText) {
+				q.SourceURL, q.FetchedAt, q.ParserVersion = p.URL, fetchedAt, version
+				unique = append(unique, q)
+			}
+		}
+		return unique, err
+	}
+	skipped := 0
+	insert := func(quotes []kitap.Quote) error {
+		n, err := insertKitapQuotes(db, bloom, quotes, langOf, *batchSize)
+		skipped += n
+		return err
+	}
+
+	cfg := pipeline.Config{
+		Fetchers:    *fetchers,
+		MaxPages:    *maxPages,
+		MaxRows:     *maxRows,
+		BatchSize:   *batchSize,
+		Delay:       *delay,
+		LogInterval: *logEvery,
+	}
+
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+
+	fmt.Fprintf(progress, "Crawling %d pages into %s...\n\n", len(urls), *dbPath)
+	m := meter.New(progress, "Crawling", "pages", len(urls))
+	get := func(url string) ([]byte, error) {
+		body, err := client.Get(url)
+		m.Add(1, int64(len(body)))
+		return body, err
+	}
+	stats, err := pipeline.Run(ctx, cfg, urls, get, parse, insert)
+	m.Finish()
+	if saveErr := bloom.Save(bloomPath); saveErr != nil {
+		slog.Warn("failed to save bloom filter", "err", saveErr)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(progress, "\n✗ Crawl interrupted\n")
+		err = nil
+	} else {
+		fmt.Fprintf(progress, "\n✓ Crawl finished\n")
+	}
+	fmt.Fprintf(progress, "  Fetched: %d pages (%d failed)\n", stats.Fetched, stats.Failed)
+	fmt.Fprintf(progress, "  Inserted: %d quotes (%d already in the database)\n", stats.Inserted-int64(skipped), skipped)
+	fmt.Fprintf(progress, "  Fetchers throttled: %d times\n", stats.Throttled)
+	return err
+}
+
+// runCrawlFrases reads the downloaded author index, crawls every author page
+// and upserts authors and quotes into frasesauthors and frasesquotes
+func runCrawlFrases(args []string) error {
+	fs := flag.NewFlagSet("crawl fraseslibros", flag.ExitOnError)
+	indexDir := fs.String("index", "fraseslibros", "folder of index pages saved by download fraseslibros")
+	cacheDir := fs.String("cache", "", "folder to keep author pages in (default <index>/quotes)")
+	dbPath := fs.String("db", "database.db", "SQLite database to insert into")
+	delay := fs.Duration("delay", 1*time.Second, "pause between author page requests")
+	skipQuotes := fs.Bool("skip-quotes", false, "only insert authors, do not crawl their quote pages")
+	parsers := fs.Int("parsers", 0, "index pages parsed at once; 0 for one per CPU core")
+	guard := addGuardFlags(fs)
+	configure := addScraperFlags(fs)
+	fs.Parse(args)
+
+	if *cacheDir == "" {
+		*cacheDir = filepath.Join(*indexDir, "quotes")
+	}
+
+	authors, err := frases.ParseAuthorsFromFolder(*indexDir, *parsers)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	inserted, updated, err := frases.InsertAuthors(db, authors)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "\n✓ Inserted %d new and updated %d existing authors\n", inserted, updated)
+	if *skipQuotes {
+		return nil
+	}
+
+	g, err := guard(*cacheDir)
+	if err != nil {
+		return err
+	}
+	c := frases.NewCrawler()
+	c.Delay = *delay
+	c.Guard = g
+	if err := configure(c.Client); err != nil {
+		return err
+	}
+
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+
+	fmt.Fprintf(progress, "\nCrawling author pages into %s/...\n\n", *cacheDir)
+	quotes, crawlErr := c.CrawlAll(ctx, authors, *cacheDir)
+
+	// Keep what was crawled before a quota or Ctrl+C stopped the run
+	inserted, updated, err = frases.InsertQuotes(db, quotes)
+	if err != nil {
+		return err
+	}
+	if errors.Is(crawlErr, context.Canceled) {
+		fmt.Fprintf(progress, "\n✗ Crawl interrupted; inserted %d new and updated %d existing quotes\n", inserted, updated)
+		return nil
+	}
+	fmt.Fprintf(progress, "\n✓ Inserted %d new and updated %d existing quotes\n", inserted, updated)
+	return crawlErr
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"quotesparser/quiz"
+	"quotesparser/store"
+)
+
+// runCrossword exports trivia with single-word answers as answer and clue
+// pairs for crossword construction tools
+func runCrossword(args []string) error {
+	fs := flag.NewFlagSet("crossword", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	format := fs.String("format", "clues", "clues (answer<TAB>clue lines) or words (answer;score word list)")
+	minLen := fs.Int("min", 3, "shortest answer, in letters")
+	maxLen := fs.Int("max", 15, "longest answer, in letters; 0 for no limit")
+	alphabet := fs.String("alphabet", "en", "letters answers may use: en, tr, es or the letters themselves")
+	score := fs.Int("score", 50, "score of every word in a word list")
+	category := fs.String("category", "", "only trivia in this category")
+	out := fs.String("out", "", "file to write; standard output by default")
+	fs.Parse(args)
+
+	var write func(io.Writer, []quiz.Clue) error
+	switch *format {
+	case "clues":
+		write = quiz.WriteClues
+	case "words":
+		write = func(w io.Writer, clues []quiz.Clue) error { return quiz.WriteWordList(w, clues, *score) }
+	default:
+		return fmt.Errorf("unknown format %q, want clues or words", *format)
+	}
+	if *minLen < 1 || (*maxLen > 0 && *maxLen < *minLen) {
+		return fmt.Errorf("--min must be at least 1 and at most --max")
+	}
+	if *alphabet == "" {
+		return fmt.Errorf("--alphabet must not be empty")
+	}
+
+	s, err := store.Open(*dsn)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	trivia, err := s.Trivia(*category, 0)
+	if err != nil {
+		return err
+	}
+	clues := quiz.Clues(trivia, quiz.CrosswordOptions{Min: *minLen, Max: *maxLen, Alphabet: *alphabet})
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return fmt.Errorf("failed to create %s: %v", *out, err)
+		}
+		defer w.Close()
+	}
+	bw := bufio.NewWriter(w)
+	if err := write(bw, clues); err != nil {
+		return fmt.Errorf("failed to write clues: %v", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write clues: %v", err)
+	}
+	if *out != "" {
+		fmt.Fprintf(progress, "✓ Exported %d clues of %d trivia questions to %s\n", len(clues), len(trivia), *out)
+	}
+	return nil
+}
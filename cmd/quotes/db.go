@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"quotesparser/dedup"
+	"quotesparser/kitap"
+	"quotesparser/origin"
+	"quotesparser/schema"
+	"quotesparser/store"
+)
+
+// openDB opens the SQLite database the way every ingester does
+func openDB(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", store.SQLiteDSN(dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	if _, err := db.Exec("PRAGMA encoding = 'UTF-8'"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set encoding: %v", err)
+	}
+	return db, nil
+}
+
+// The quote bloom filter is sized for bloomGrowth times the quotes stored,
+// plus bloomHeadroom for a small database, so that it stays near its false
+// positive rate while ingests add quotes until it is next rebuilt
+const (
+	bloomGrowth   = 2
+	bloomHeadroom = 100000
+)
+
+// loadQuoteBloom loads the bloom filter of quote hashes kept next to the
+// database, rebuilding it from the quotes table when it is missing or out of
+// date (another ingester inserted rows since it was saved)
+func loadQuoteBloom(db *sql.DB, path string) (*dedup.Bloom, error) {
+	var rowCount uint64
+	if err := db.QueryRow("SELECT COUNT(*) FROM quotes").Scan(&rowCount); err != nil {
+		return nil, fmt.Errorf("failed to count quotes: %v", err)
+	}
+
+	if b, err := dedup.LoadBloom(path); err == nil && b.Count() == rowCount {
+		return b, nil
+	}
+
+	b := dedup.NewBloom(int(rowCount)*bloomGrowth+bloomHeadroom, 0.01)
+	rows, err := db.Query("SELECT textHash FROM quotes WHERE textHash IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quote hashes: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to read quote hashes: %v", err)
+		}
+		b.Add(hash)
+	}
+	return b, rows.Err()
+}
+
+// insertKitapQuotes upserts 1000kitap quotes into the quotes table, using the
+// "Author - Book" attribution of the existing rows and the language langOf
+// gives each quote. Quotes the bloom filter has seen are confirmed with a
+// lookup and skipped without a write; the rest go in with multi-row inserts
+// of batchSize rows.
+func insertKitapQuotes(db *sql.DB, bloom *dedup.Bloom, quotes []kitap.Quote, langOf func(text, fallback string) string, batchSize int) (skipped int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	var rows [][]interface{}
+	for _, q := range quotes {
+		lang := langOf(q.QuoteText, "tr")
+		hash := dedup.TextHashIn(q.QuoteText, lang)
+		if bloom != nil && bloom.Test(hash) {
+			var exists int
+			err := tx.QueryRow("SELECT COUNT(*) FROM quotes WHERE textHash = ?", hash).Scan(&exists)
+			if err != nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("failed to look up quote: %v", err)
+			}
+			if exists > 0 {
+				skipped++
+				continue
+			}
+		}
+		kind := origin.Classify(origin.Hints{Text: q.QuoteText, Author: q.Author, Book: q.BookName, Source: "1000kitap"})
+		rows = append(rows, []interface{}{q.QuoteText, schema.JoinAttribution(q.Author, q.BookName), lang, hash, string(kind),
+			nullIfEmpty(q.SourceURL), nullIfEmpty(q.SourceFile), nullIfEmpty(q.FetchedAt), nullIfEmpty(q.ParserVersion)})
+	}
+
+	b := store.Batch{
+		Insert:   "INSERT INTO quotes (text, author, lang, textHash, origin, sourceUrl, sourceFile, fetchedAt, parserVersion)",
+		Conflict: "ON CONFLICT(textHash) DO UPDATE SET author = COALESCE(quotes.author, excluded.author), origin = COALESCE(quotes.origin, excluded.origin), " + store.KeepProvenance,
+		Size:     batchSize,
+		Key:      func(row []interface{}) string { return row[3].(string) },
+	}
+	if err := b.Exec(tx, rows); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to insert quotes: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	if bloom != nil {
+		for _, row := range rows {
+			bloom.Add(row[3].(string))
+		}
+	}
+	return skipped, nil
+}
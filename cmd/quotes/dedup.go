@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"quotesparser/dedup"
+	"quotesparser/migrations"
+	"quotesparser/schema"
+)
+
+// runDedup rehashes every quote, trivia question and fun fact with the current
+// normalization and merges the rows that turn out to be duplicates. With
+// --fuzzy it looks for near-duplicates instead, reporting them unless told to
+// --merge. With --fold it also merges the authors whose names only differ
+// in case, accents or punctuation.
+func runDedup(args []string) error {
+	fs := flag.NewFlagSet("dedup", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database to clean")
+	dryRun := fs.Bool("dry-run", false, "report the duplicates without changing the database")
+	show := fs.Int("show", 10, "duplicate groups to print per table (0 = none, -1 = all)")
+	fuzzy := fs.Bool("fuzzy", false, "look for near-duplicates: the same text up to punctuation or a few words")
+	threshold := fs.Float64("threshold", 0.75, "with --fuzzy, similarity from 0 to 1 at which texts are near-duplicates")
+	metricName := fs.String("metric", "jaccard", "with --fuzzy, similarity measure: jaccard (4-character shingles) or levenshtein")
+	merge := fs.Bool("merge", false, "with --fuzzy, merge the near-duplicates instead of only reporting them")
+	fold := fs.Bool("fold", false, "also merge authors whose names only differ in case, accents or punctuation, e.g. Garcia Marquez into García Márquez, as quotes authors merge does")
+	fs.Parse(args)
+
+	metric, err := dedup.ParseMetric(*metricName)
+	if err != nil {
+		return err
+	}
+	if *threshold <= 0 || *threshold > 1 {
+		return fmt.Errorf("--threshold must be above 0 and at most 1")
+	}
+	if *merge && !*fuzzy {
+		return fmt.Errorf("--merge only applies to --fuzzy; exact duplicates are always merged")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *dryRun {
+		// Migrations merge duplicates themselves, so a dry run on an older
+		// schema would either change the database or report nothing
+		pending, err := migrations.Pending(db)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%s has %d pending migrations; run quotes migrate before a dry run", *dbPath, len(pending))
+		}
+	} else if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	// A fuzzy run that does not merge only reports
+	report := *dryRun || (*fuzzy && !*merge)
+
+	fmt.Fprintf(progress, "Looking for duplicates in %s...\n", *dbPath)
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	var reports []schema.DedupReport
+	if *fuzzy {
+		reports, err = schema.FuzzyDedup(tx, *threshold, metric, !report)
+	} else {
+		reports, err = schema.Dedup(tx)
+	}
+	if err == nil && *fold {
+		var authors schema.DedupReport
+		authors, err = schema.MergeAuthors(tx)
+		reports = append(reports, authors)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, r := range reports {
+		fmt.Fprintf(progress, "\n%s: %d rows, %d duplicate groups\n", r.Table, r.Rows, len(r.Groups))
+		for i, g := range r.Groups {
+			if *show >= 0 && i >= *show {
+				fmt.Fprintf(progress, "  ... and %d more\n", len(r.Groups)-i)
+				break
+			}
+			fmt.Fprintf(progress, "  keep: %s\n", g.Keep)
+			for j, text := range g.Drop {
+				if g.Scores != nil {
+					fmt.Fprintf(progress, "  drop: %s (%.2f)\n", text, g.Scores[j])
+				} else {
+					fmt.Fprintf(progress, "  drop: %s\n", text)
+				}
+			}
+		}
+	}
+
+	if report {
+		tx.Rollback()
+		if *dryRun {
+			fmt.Fprintf(progress, "\n✓ Dry run, nothing changed\n")
+		} else {
+			fmt.Fprintf(progress, "\n✓ Near-duplicates reported; run again with --merge to merge them\n")
+			return nil
+		}
+	} else {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		// The bloom filter holds the old hashes; the next crawl rebuilds it
+		if err := os.Remove(*dbPath + ".bloom"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale bloom filter: %v", err)
+		}
+		fmt.Fprintf(progress, "\n✓ Dedup completed\n")
+	}
+	for _, r := range reports {
+		fmt.Fprintf(progress, "  %s: %d merged, %d rehashed\n", r.Table, r.Merged, r.Rehashed)
+	}
+	return nil
+}
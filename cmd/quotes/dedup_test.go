@@ -0,0 +1,231 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"quotesparser/dedup"
+)
+
+func TestDedup(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows written by an ingester that hashed differently
+	for _, stmt := range []string{
+		`INSERT INTO quotes (id, text, author, lang, viewCount, textHash) VALUES
+			(1, 'Hello  world.', NULL, NULL, 2, 'old-1'),
+			(2, '“Hello world.”', 'Amos Oz - Bir Aşk ve Karanlık Hikâyesi', 'tr', 3, 'old-2'),
+			(3, 'Something else.', NULL, 'en', 0, 'old-3')`,
+		`INSERT INTO tags (id, name) VALUES (1, 'love'), (2, 'life')`,
+		`INSERT INTO quoteTags (quoteId, tagId) VALUES (1, 1), (2, 1), (2, 2)`,
+		`INSERT INTO trivia (category, question, answer, viewCount, questionHash) VALUES
+			('science', 'What is H2O?', 'Water', 1, 'old-a'),
+			('chemistry', 'What is  H2O? ', 'Water', 4, 'old-b')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(query string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+
+	if err := runDedup([]string{"--db", dbPath, "--dry-run"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("SELECT COUNT(*) FROM quotes"); n != 3 {
+		t.Fatalf("dry run left %d quotes, want 3", n)
+	}
+
+	if err := runDedup([]string{"--db", dbPath}); err != nil {
+		t.Fatal(err)
+	}
+
+	var author, lang, hash string
+	var views int
+	err = db.QueryRow("SELECT author, lang, viewCount, textHash FROM quotes WHERE id = 1").Scan(&author, &lang, &views, &hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author != "Amos Oz - Bir Aşk ve Karanlık Hikâyesi" || lang != "tr" || views != 5 || hash != dedup.TextHash("hello world.") {
+		t.Errorf("merged quote = %q, %q, %d views, hash %s", author, lang, views, hash)
+	}
+	if n := count("SELECT COUNT(*) FROM quotes"); n != 2 {
+		t.Errorf("%d quotes left, want 2", n)
+	}
+	if n := count("SELECT COUNT(*) FROM quoteTags WHERE quoteId = 1"); n != 2 {
+		t.Errorf("merged quote has %d tags, want 2", n)
+	}
+	if n := count("SELECT COUNT(*) FROM quoteTags WHERE quoteId = 2"); n != 0 {
+		t.Errorf("%d tags still point at the dropped quote", n)
+	}
+	if n := count("SELECT COUNT(*) FROM trivia"); n != 1 {
+		t.Errorf("%d trivia questions left, want 1", n)
+	}
+	if n := count("SELECT viewCount FROM trivia"); n != 5 {
+		t.Errorf("merged question has %d views, want 5", n)
+	}
+
+	// The index now rejects variants whichever ingester inserts them
+	if _, err := db.Exec("INSERT INTO quotes (text, textHash) VALUES (?, ?)", "HELLO WORLD.", dedup.TextHash("HELLO WORLD.")); err == nil {
+		t.Error("a normalized duplicate was inserted")
+	}
+}
+
+func TestFuzzyDedup(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	insert := func(table, text string, columns ...interface{}) {
+		t.Helper()
+		var err error
+		if table == "quotes" {
+			_, err = db.Exec("INSERT INTO quotes (text, viewCount, textHash) VALUES (?, 1, ?)", text, dedup.TextHash(text))
+		} else {
+			_, err = db.Exec("INSERT INTO trivia (category, question, answer, viewCount, questionHash) VALUES ('geo', ?, ?, 1, ?)", text, columns[0], dedup.TextHash(text))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert("quotes", "The only way out is through.")
+	insert("quotes", "The only way out is through...")
+	insert("quotes", "Whatever our souls are made of, his and mine are the same.")
+	insert("quotes", "Whatever our souls are made of, his and mine are alike.")
+	insert("quotes", "Not all those who wander are lost.")
+	// One letter apart, but different questions
+	insert("trivia", "What is the capital of Austria?", "Vienna")
+	insert("trivia", "What is the capital of Australia?", "Canberra")
+	insert("trivia", "What is the capital of Australia", "Canberra")
+
+	count := func(table string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if err := runDedup([]string{"--db", dbPath, "--fuzzy"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("quotes"); n != 5 {
+		t.Fatalf("reporting left %d quotes, want 5", n)
+	}
+
+	if err := runDedup([]string{"--db", dbPath, "--fuzzy", "--merge"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("quotes"); n != 3 {
+		t.Errorf("%d quotes left, want 3", n)
+	}
+	var views int
+	if err := db.QueryRow("SELECT viewCount FROM quotes WHERE text = 'The only way out is through.'").Scan(&views); err != nil {
+		t.Fatal(err)
+	}
+	if views != 2 {
+		t.Errorf("kept quote has %d views, want 2", views)
+	}
+	if n := count("trivia"); n != 2 {
+		t.Errorf("%d trivia questions left, want 2", n)
+	}
+
+	if err := runDedup([]string{"--db", dbPath, "--merge"}); err == nil {
+		t.Error("--merge without --fuzzy was accepted")
+	}
+}
+
+func TestDedupFoldAuthors(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO authors (id, name, link) VALUES
+			(1, 'Gabriel Garcia Marquez', 'https://example.com/ggm'),
+			(2, 'Gabriel García Márquez', NULL),
+			(3, 'GABRIEL GARCIA MARQUEZ', NULL),
+			(4, 'Sabahattin Ali', NULL)`,
+		`INSERT INTO books (id, title, authorId) VALUES (1, 'Cien años de soledad', 1), (2, 'Cien años de soledad', 2), (3, 'El amor en los tiempos del cólera', 3)`,
+		`INSERT INTO quotes (text, author, authorId, bookId, textHash) VALUES
+			('Uno.', 'Gabriel Garcia Marquez - Cien años de soledad', 1, 1, 'h1'),
+			('Dos.', 'Gabriel García Márquez - Cien años de soledad', 2, 2, 'h2'),
+			('Tres.', 'GABRIEL GARCIA MARQUEZ', 3, 3, 'h3')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := runDedup([]string{"--db", dbPath, "--fold"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var authors []string
+	rows, err := db.Query("SELECT name || ' ' || COALESCE(link, '') FROM authors ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var a string
+		rows.Scan(&a)
+		authors = append(authors, a)
+	}
+	rows.Close()
+	if want := []string{"Gabriel García Márquez https://example.com/ggm", "Sabahattin Ali "}; !reflect.DeepEqual(authors, want) {
+		t.Errorf("authors = %q, want %q", authors, want)
+	}
+
+	var quotes []string
+	rows, err = db.Query("SELECT author || ' ' || authorId || ' ' || bookId FROM quotes ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var q string
+		rows.Scan(&q)
+		quotes = append(quotes, q)
+	}
+	rows.Close()
+	want := []string{
+		"Gabriel García Márquez - Cien años de soledad 2 2",
+		"Gabriel García Márquez - Cien años de soledad 2 2",
+		"Gabriel García Márquez 2 3",
+	}
+	if !reflect.DeepEqual(quotes, want) {
+		t.Errorf("quotes = %q, want %q", quotes, want)
+	}
+	var books int
+	if err := db.QueryRow("SELECT COUNT(*) FROM books WHERE authorId = 2").Scan(&books); err != nil || books != 2 {
+		t.Errorf("the kept author has %d books, want 2 (%v)", books, err)
+	}
+}
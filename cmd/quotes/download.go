@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"quotesparser/archive"
+	"quotesparser/fetch"
+	"quotesparser/frases"
+	"quotesparser/kitap"
+	"quotesparser/meter"
+	"quotesparser/quota"
+	"quotesparser/source"
+)
+
+func runDownload(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes download <source> [flags] (sources: %s)", sourceList("1000kitap", "fraseslibros"))
+	}
+
+	switch args[0] {
+	case "1000kitap":
+		return runDownload1000Kitap(args[1:])
+	case "fraseslibros":
+		return runDownloadFrases(args[1:])
+	default:
+		if src, ok := source.Get(args[0]); ok {
+			return runDownloadSource(src, args[1:])
+		}
+		return fmt.Errorf("unknown source %q (sources: %s)", args[0], sourceList("1000kitap", "fraseslibros"))
+	}
+}
+
+func runDownload1000Kitap(args []string) error {
+	fs := flag.NewFlagSet("download 1000kitap", flag.ExitOnError)
+	var books, authors stringList
+	fs.Var(&books, "book", "book slug or URL, e.g. normal-insanlar--182700 (repeatable)")
+	fs.Var(&authors, "author", "author slug or URL, e.g. sally-rooney, to download all their quotes (repeatable)")
+	outDir := fs.String("out", "quoteFiles", "folder to save pages into")
+	pages := fs.Int("pages", 100, "number of quote pages to download per book or author")
+	delay := fs.Duration("delay", 1*time.Second, "pause between requests")
+	resume := fs.Bool("resume", false, "skip pages already saved by an earlier run")
+	guard := addGuardFlags(fs)
+	configure := addScraperFlags(fs)
+	archiveTo := addArchiveFlag(fs, "instead of the folder")
+	fs.Parse(args)
+
+	// Positional arguments are accepted as books too
+	books = append(books, fs.Args()...)
+	if len(books) == 0 && len(authors) == 0 {
+		return fmt.Errorf("at least one --book or --author is required")
+	}
+
+	var targets []kitap.Target
+	for _, b := range books {
+		book, err := kitap.ParseBook(b)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, book)
+	}
+	for _, a := range authors {
+		author, err := kitap.ParseAuthor(a)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, author)
+	}
+
+	g, err := guard(*outDir)
+	if err != nil {
+		return err
+	}
+	client := fetch.NewClient()
+	if err := configure(client); err != nil {
+		return err
+	}
+
+	a, err := archiveTo(client, "1000kitap")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+
+	d := pageDownload{pages: *pages, delay: *delay, resume: *resume, name: "file%d.txt", archive: a,
+		meter: meter.New(progress, "Downloading", "pages", len(targets)**pages)}
+	successCount := 0
+	failCount := 0
+	var stopErr error
+	for _, target := range targets {
+		fmt.Fprintf(progress, "Downloading %s quotes...\n", target)
+		fmt.Fprintf(progress, "URL: %s\n", strings.TrimSuffix(target.QuotesURL(1), "?sayfa=1"))
+		if a == nil {
+			fmt.Fprintf(progress, "Saving to: %s/%s/\n", *outDir, target)
+		}
+		fmt.Fprintln(progress)
+
+		// As a URL, so the adapter cannot take an author for a book
+		url := strings.TrimSuffix(target.QuotesURL(1), "?sayfa=1")
+		success, failed, err := d.run(ctx, kitap.Adapter{}, client, g, url, filepath.Join(*outDir, target.String()))
+		successCount += success
+		failCount += failed
+		if err != nil {
+			stopErr = err
+			break
+		}
+	}
+	d.meter.Finish()
+
+	interrupted := errors.Is(stopErr, context.Canceled)
+	switch {
+	case interrupted:
+		fmt.Fprintf(progress, "\n✗ Download interrupted\n")
+	case stopErr != nil:
+		fmt.Fprintf(progress, "\n✗ Download stopped early\n")
+	default:
+		fmt.Fprintf(progress, "\n✓ Download completed!\n")
+	}
+	fmt.Fprintf(progress, "  Books/authors: %d\n", len(targets))
+	fmt.Fprintf(progress, "  Success: %d pages\n", successCount)
+	fmt.Fprintf(progress, "  Failed: %d pages\n", failCount)
+	if interrupted {
+		return closeArchive(a, nil)
+	}
+	return closeArchive(a, stopErr)
+}
+
+// runDownloadFrases saves the author index pages that crawl fraseslibros
+// reads, or with --quotes the quote pages of the authors for parse
+// fraseslibros
+func runDownloadFrases(args []string) error {
+	fs := flag.NewFlagSet("download fraseslibros", flag.ExitOnError)
+	letters := fs.String("letters", "abcdefghijklmnopqrstuvwxyz", "index letters to download")
+	var authors stringList
+	fs.Var(&authors, "author", "author slug or URL whose quote pages to download instead of the letters' (repeatable; implies --quotes)")
+	quotePages := fs.Bool("quotes", false, "download the quote pages of every author under the letters instead of the index pages")
+	outDir := fs.String("out", "fraseslibros", "folder to save pages into")
+	pages := fs.Int("pages", 20, "maximum index pages per letter, or with --quotes quote pages per letter or author")
+	delay := fs.Duration("delay", 1*time.Second, "pause between letters, or with --quotes between requests")
+	guard := addGuardFlags(fs)
+	configure := addScraperFlags(fs)
+	archiveTo := addArchiveFlag(fs, "too, or with --quotes instead of the folder")
+	fs.Parse(args)
+
+	g, err := guard(*outDir)
+	if err != nil {
+		return err
+	}
+
+	if *quotePages || len(authors) > 0 {
+		client := fetch.NewClient()
+		if err := configure(client); err != nil {
+			return err
+		}
+		targets := authors
+		if len(targets) == 0 {
+			for _, letter := range *letters {
+				targets = append(targets, string(letter))
+			}
+		}
+		a, err := archiveTo(client, "fraseslibros")
+		if err != nil {
+			return err
+		}
+		err = downloadTargets(frases.Adapter{}, client, g, targets, *outDir, pageDownload{pages: *pages, delay: *delay, archive: a})
+		return closeArchive(a, err)
+	}
+
+	c := frases.NewCrawler()
+	c.Guard = g
+	if err := configure(c.Client); err != nil {
+		return err
+	}
+	a, err := archiveTo(c.Client, "fraseslibros")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+
+	total := 0
+	m := meter.New(progress, "Downloading", "letters", len([]rune(*letters)))
+	for i, letter := range *letters {
+		if i > 0 && !fetch.Sleep(ctx, *delay) {
+			break
+		}
+		saved, err := c.DownloadIndex(ctx, string(letter), *pages, *outDir)
+		total += saved
+		m.Add(1, 0)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			if quota.IsLimit(err) {
+				return closeArchive(a, err)
+			}
+			slog.Error("failed to download letter", "letter", string(letter), "err", err)
+		}
+	}
+	m.Finish()
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Interrupted after downloading %d index pages into %s/\n", total, *outDir)
+		return closeArchive(a, nil)
+	}
+	fmt.Fprintf(progress, "\n✓ Downloaded %d index pages into %s/\n", total, *outDir)
+	return closeArchive(a, nil)
+}
+
+// addGuardFlags registers the disk space and quota flags shared by downloaders
+// and returns a constructor for the configured guard
+func addGuardFlags(fs *flag.FlagSet) func(dir string) (*quota.Guard, error) {
+	minFree := fs.String("min-free", "200MB", "abort when free disk space drops below this (0 disables)")
+	maxBytes := fs.String("max-bytes", "0", "stop after writing this much data in one run (0 = unlimited)")
+	maxFiles := fs.Int("max-files", 0, "stop after writing this many files in one run (0 = unlimited)")
+	pause := fs.Bool("pause-when-full", false, "wait for disk space to be freed instead of aborting")
+
+	return func(dir string) (*quota.Guard, error) {
+		free, err := quota.ParseSize(*minFree)
+		if err != nil {
+			return nil, fmt.Errorf("--min-free: %v", err)
+		}
+		limit, err := quota.ParseSize(*maxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("--max-bytes: %v", err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create folder: %v", err)
+		}
+
+		g := &quota.Guard{
+			Dir:      dir,
+			MinFree:  free,
+			MaxBytes: int64(limit),
+			MaxFiles: *maxFiles,
+			Pause:    *pause,
+		}
+		// Refuse to start a crawl that could not write a single page
+		if err := g.Check(0); err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+}
+
+// addScraperFlags is addFetchFlags for the scrapers of quote sites, which
+// also keep to what robots.txt asks of them and pace their requests to each
+// site, across the clients of a run
+func addScraperFlags(fs *flag.FlagSet) func(c *fetch.Client) error {
+	configure := addFetchFlags(fs)
+	agent := fs.String("agent", fetch.DefaultAgent, "name the scrapers go by, in their User-Agent and the robots.txt groups they follow")
+	ignore := fs.Bool("ignore-robots", false, "download the pages robots.txt disallows, without waiting its Crawl-delay between requests")
+	hostDelay := fs.Duration("host-delay", 1*time.Second, "least time between two requests to the same site, raised to the Crawl-delay of its robots.txt")
+	var polite *fetch.Polite
+	return func(c *fetch.Client) error {
+		if err := configure(c); err != nil {
+			return err
+		}
+		c.UserAgent = fetch.UserAgent(*agent)
+		if *ignore && *hostDelay == 0 {
+			return nil
+		}
+		if polite == nil {
+			polite = &fetch.Polite{Agent: *agent, Delay: *hostDelay, IgnoreRobots: *ignore}
+		}
+		c.Polite = polite
+		return nil
+	}
+}
+
+// addFetchFlags registers the retry, cassette and chaos flags shared by
+// downloaders and returns a function that applies them to a client
+func addFetchFlags(fs *flag.FlagSet) func(c *fetch.Client) error {
+	retries := fs.Int("retries", 2, "retries for timeouts, truncated pages, 429 and 5xx responses")
+	backoff := fs.Duration("backoff", 1*time.Second, "wait before the first retry, doubled after each")
+	chaos := fs.Float64("chaos", 0, "debug: break this share of requests (0..1) with timeouts, 500s, truncated and slow responses")
+	chaosSeed := fs.Int64("chaos-seed", 1, "debug: random seed for --chaos, to replay a run")
+	chaosDelay := fs.Duration("chaos-max-delay", 5*time.Second, "debug: longest delay --chaos injects into slow responses")
+	cassettes := fs.String("cassettes", "", "folder of recorded HTTP interactions, one file per site (empty = always use the network)")
+	vcr := fs.String("vcr", "auto", "with --cassettes: replay (offline, fail on unrecorded pages), record (refresh from the network) or auto")
+
+	return func(c *fetch.Client) error {
+		c.Retries = *retries
+		c.Backoff = *backoff
+		if *cassettes != "" {
+			mode, err := fetch.ParseCassetteMode(*vcr)
+			if err != nil {
+				return fmt.Errorf("--vcr: %v", err)
+			}
+			cassette := fetch.NewCassette(*cassettes, mode)
+			cassette.Next = c.HTTP.Transport
+			c.HTTP.Transport = cassette
+		}
+		// Chaos sits above the cassettes so only clean responses are recorded
+		if *chaos > 0 {
+			ch := fetch.NewChaos(*chaos, *chaosSeed)
+			ch.Next = c.HTTP.Transport
+			ch.MaxDelay = *chaosDelay
+			c.HTTP.Transport = ch
+			slog.Warn("chaos mode: breaking requests", "percent", *chaos*100, "seed", *chaosSeed)
+		}
+		return nil
+	}
+}
+
+// runDownloadSource saves the pages of a registered source's targets, as
+// its adapter discovers them
+func runDownloadSource(src source.Adapter, args []string) error {
+	fs := flag.NewFlagSet("download "+src.Name(), flag.ExitOnError)
+	var targets stringList
+	fs.Var(&targets, "target", "slug or URL to download, as the source understands it (repeatable)")
+	outDir := fs.String("out", src.Name(), "folder to save pages into")
+	pages := fs.Int("pages", 100, "maximum pages per target")
+	delay := fs.Duration("delay", 1*time.Second, "pause between requests")
+	guard := addGuardFlags(fs)
+	configure := addScraperFlags(fs)
+	archiveTo := addArchiveFlag(fs, "instead of the folder")
+	fs.Parse(args)
+
+	targets = append(targets, fs.Args()...)
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets given; use --target or list them after the flags")
+	}
+
+	g, err := guard(*outDir)
+	if err != nil {
+		return err
+	}
+	client := fetch.NewClient()
+	if err := configure(client); err != nil {
+		return err
+	}
+
+	a, err := archiveTo(client, src.Name())
+	if err != nil {
+		return err
+	}
+	err = downloadTargets(src, client, g, targets, *outDir, pageDownload{pages: *pages, delay: *delay, archive: a})
+	return closeArchive(a, err)
+}
+
+// downloadTargets saves the pages of each target into its own folder under
+// outDir and reports the totals
+func downloadTargets(src source.Adapter, client *fetch.Client, g *quota.Guard, targets []string, outDir string, d pageDownload) error {
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+
+	d.meter = meter.New(progress, "Downloading", "pages", len(targets)*d.pages)
+	saved, failed := 0, 0
+	for _, target := range targets {
+		s, f, err := d.run(ctx, src, client, g, target, filepath.Join(outDir, targetFolder(target)))
+		saved += s
+		failed += f
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	d.meter.Finish()
+
+	into := outDir + "/"
+	if d.archive != nil {
+		into = "the archive"
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Interrupted after downloading %d pages into %s (%d failed)\n", saved, into, failed)
+		return nil
+	}
+	fmt.Fprintf(progress, "\n✓ Downloaded %d pages into %s (%d failed)\n", saved, into, failed)
+	return nil
+}
+
+// pageURLsFile lists the address of each page saved in a target's folder and
+// when it was downloaded, one "file<TAB>url<TAB>time" a line, for the
+// adapters that parse pages by address and for the provenance of quotes
+const pageURLsFile = "urls.tsv"
+
+// errPageLimit ends a target once its pages are all downloaded
+var errPageLimit = errors.New("page limit reached")
+
+// pageDownload saves the pages an adapter discovers for a target
+type pageDownload struct {
+	pages   int            // at most, per target
+	delay   time.Duration  // between requests
+	resume  bool           // skip pages already saved by an earlier run
+	name    string         // file name of page n; default page<n>.html, or .json for JSON pages
+	meter   *meter.Meter   // counts the pages of every target
+	archive *archive.Cache // the client's, keeping the pages instead of files
+}
+
+// run saves the pages of target into folder. A page that does not exist or
+// has no quotes is not saved, and ends the target if the adapter pages it
+// by number; pages that fail to download are logged and counted. It stops
+// with an error when the guard refuses further writes, or with ctx's error
+// once ctx is done. The pages the target did not need are taken off the
+// meter's total.
+func (d pageDownload) run(ctx context.Context, a source.Adapter, client *fetch.Client, g *quota.Guard, target, folder string) (saved, failed int, err error) {
+	if d.archive == nil {
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			return 0, 0, fmt.Errorf("failed to create folder: %v", err)
+		}
+	}
+	page := 0
+	fetched := false
+	defer func() { d.meter.Grow(page - d.pages) }()
+	err = a.Discover(ctx, client, target, func(url string) (int, error) {
+		if page >= d.pages {
+			return 0, errPageLimit
+		}
+		page++
+		if d.resume && d.name != "" {
+			path := filepath.Join(folder, fmt.Sprintf(d.name, page))
+			if content, err := os.ReadFile(path); err == nil {
+				saved++
+				d.meter.Add(1, 0)
+				quotes, _ := a.Parse(url, content)
+				return len(quotes), nil
+			}
+		}
+
+		if fetched && !fetch.Sleep(ctx, d.delay) {
+			return 0, ctx.Err()
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if err := g.Check(0); err != nil {
+			return 0, err
+		}
+		fetched = true
+		body, err := a.Fetch(ctx, client, url)
+		if source.IsMissing(err) {
+			d.meter.Add(1, 0)
+			return 0, nil
+		}
+		if errors.Is(err, fetch.ErrSourceBlocked) {
+			// Asking on would only draw the ban out
+			return 0, fmt.Errorf("%s: %w", target, err)
+		}
+		if err != nil {
+			slog.Error("failed to download page", "target", target, "page", page, "err", err)
+			failed++
+			d.meter.Add(1, 0)
+			return 1, nil
+		}
+		quotes, err := a.Parse(url, body)
+		if err == nil && len(quotes) == 0 {
+			d.meter.Add(1, int64(len(body)))
+			return 0, nil
+		}
+
+		if err := g.Check(int64(len(body))); err != nil {
+			return 0, err
+		}
+		if d.archive != nil {
+			// The client archived it
+			g.Add(int64(len(body)))
+			d.meter.Printf("[%s] %s page %d archived: %s\n", time.Now().Format("15:04:05"), target, page, url)
+			d.meter.Add(1, int64(len(body)))
+			saved++
+			return max(len(quotes), 1), nil
+		}
+		path := filepath.Join(folder, d.fileName(page, body))
+		if err := quota.WriteFile(path, body); err != nil {
+			return 0, err
+		}
+		g.Add(int64(len(body)))
+		if err := recordPageURL(folder, filepath.Base(path), url); err != nil {
+			return 0, err
+		}
+		d.meter.Printf("[%s] %s page %d downloaded: %s\n", time.Now().Format("15:04:05"), target, page, path)
+		d.meter.Add(1, int64(len(body)))
+		saved++
+		return max(len(quotes), 1), nil
+	})
+	if errors.Is(err, errPageLimit) {
+		err = nil
+	}
+	return saved, failed, err
+}
+
+// fileName returns the name page n is saved as
+func (d pageDownload) fileName(n int, body []byte) string {
+	switch {
+	case d.name != "":
+		return fmt.Sprintf(d.name, n)
+	case bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")):
+		return fmt.Sprintf("page%d.json", n)
+	default:
+		return fmt.Sprintf("page%d.html", n)
+	}
+}
+
+// recordPageURL adds a saved page's address to its folder's pageURLsFile
+func recordPageURL(folder, file, url string) error {
+	f, err := os.OpenFile(filepath.Join(folder, pageURLsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to record page address: %v", err)
+	}
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%s\n", file, url, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to record page address: %v", err)
+	}
+	return f.Close()
+}
+
+// pageURL returns the address a saved page was downloaded from, as recorded
+// next to it, or "" when it was not
+func pageURL(path string) string {
+	url, _ := pageRecord(path)
+	return url
+}
+
+// pageRecord returns the address and download time recorded for a saved
+// page; folders written before the time was recorded have none
+func pageRecord(path string) (url, fetchedAt string) {
+	content, err := os.ReadFile(filepath.Join(filepath.Dir(path), pageURLsFile))
+	if err != nil {
+		return "", ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		// The last entry wins: a page downloaded again replaced the file
+		fields := strings.Split(line, "\t")
+		if len(fields) >= 2 && fields[0] == filepath.Base(path) {
+			url, fetchedAt = fields[1], ""
+			if len(fields) >= 3 {
+				fetchedAt = fields[2]
+			}
+		}
+	}
+	return url, fetchedAt
+}
+
+// targetFolder turns a slug or URL into a folder name
+func targetFolder(target string) string {
+	target = strings.TrimPrefix(strings.TrimPrefix(target, "https://"), "http://")
+	return strings.NewReplacer("/", "_", "?", "_", "&", "_", "=", "_", ":", "_").Replace(strings.Trim(target, "/"))
+}
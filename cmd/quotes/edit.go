@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"quotesparser/dedup"
+	"quotesparser/origin"
+	"quotesparser/schema"
+	"quotesparser/store"
+)
+
+// editEntry is a quote in a file written by edit-export. Hash is of the
+// quote as exported, so edit-import can tell what was edited in the file
+// from what changed in the database since. The provenance fields are there to
+// read; edits to them are not saved.
+type editEntry struct {
+	ID     int64  `json:"id" yaml:"id" toml:"id" jsoncheck:"required"`
+	Hash   string `json:"hash" yaml:"hash" toml:"hash" jsoncheck:"required"`
+	Text   string `json:"text" yaml:"text" toml:"text"`
+	Author string `json:"author,omitempty" yaml:"author,omitempty" toml:"author,omitempty"`
+	Book   string `json:"book,omitempty" yaml:"book,omitempty" toml:"book,omitempty"`
+	Lang   string `json:"lang,omitempty" yaml:"lang,omitempty" toml:"lang,omitempty"`
+	Origin string `json:"origin,omitempty" yaml:"origin,omitempty" toml:"origin,omitempty"`
+
+	SourceURL     string `json:"sourceUrl,omitempty" yaml:"sourceUrl,omitempty" toml:"sourceUrl,omitempty"`
+	FetchedAt     string `json:"fetchedAt,omitempty" yaml:"fetchedAt,omitempty" toml:"fetchedAt,omitempty"`
+	ParserVersion string `json:"parserVersion,omitempty" yaml:"parserVersion,omitempty" toml:"parserVersion,omitempty"`
+}
+
+// hash is the hash of e's fields other than ID, Hash and the provenance
+func (e editEntry) hash() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{e.Text, e.Author, e.Book, e.Lang, e.Origin}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// editHeader opens an exported YAML file
+const editHeader = `# Edit the quotes below and run quotes edit-import on this file. Only the
+# fields you change are saved; leave id and hash as they are, and the
+# sourceUrl, fetchedAt and parserVersion saying where a quote came from.
+# Quotes taken out of the file are left alone.
+`
+
+// runEditExport writes the quotes matching --filter to a YAML, TOML or JSON
+// file for editing by hand, each with the hash edit-import detects changes by
+func runEditExport(args []string) error {
+	fs := flag.NewFlagSet("edit-export", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database to export from")
+	filter := fs.String("filter", "", `quotes to export, e.g. "lang=tr,author=Oğuz Atay" (keys: lang, author, origin, max-chars, min-confidence, limit)`)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: quotes edit-export [flags] <file.yaml, .toml or .json>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("edit-export needs one file to write")
+	}
+	path := fs.Arg(0)
+
+	f, err := parseFilter(*filter)
+	if err != nil {
+		return err
+	}
+	s, err := store.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	quotes, err := s.Quotes(f)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]editEntry, len(quotes))
+	for i, q := range quotes {
+		entries[i] = editEntry{ID: q.ID, Text: q.Text, Author: q.Author, Book: q.Book, Lang: q.Lang, Origin: string(q.Origin),
+			SourceURL: q.Provenance.URL, FetchedAt: q.Provenance.FetchedAt, ParserVersion: q.Provenance.Parser}
+		entries[i].Hash = entries[i].hash()
+	}
+
+	var buf bytes.Buffer
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		buf.WriteString(editHeader + "\n")
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err = enc.Encode(entries)
+	case ".toml":
+		err = toml.NewEncoder(&buf).Encode(struct {
+			Quotes []editEntry `toml:"quotes"`
+		}{entries})
+	case ".json":
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(entries)
+	default:
+		return fmt.Errorf("cannot write %s: the file must end in .yaml, .yml, .toml or .json", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode quotes: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	fmt.Fprintf(progress, "✓ Exported %d quotes to %s\n", len(entries), path)
+	return nil
+}
+
+// parseFilter reads the key=value pairs of --filter
+func parseFilter(s string) (store.Filter, error) {
+	var f store.Filter
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return f, fmt.Errorf("bad filter %q: want key=value", pair)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "lang":
+			f.Lang = value
+		case "author":
+			f.Author = value
+		case "origin":
+			f.Origin, err = origin.Parse(value)
+		case "max-chars":
+			f.MaxChars, err = strconv.Atoi(value)
+		case "min-confidence":
+			f.MinConfidence, err = strconv.ParseFloat(value, 64)
+		case "limit":
+			f.Limit, err = strconv.Atoi(value)
+		default:
+			return f, fmt.Errorf("unknown filter %q (lang, author, origin, max-chars, min-confidence, limit)", key)
+		}
+		if err != nil {
+			return f, fmt.Errorf("bad filter %s: %v", key, err)
+		}
+	}
+	return f, nil
+}
+
+// runEditImport saves the fields edited in files written by edit-export.
+// A quote changed in the database since its export is skipped rather than
+// overwritten, as is one edited into the text of another quote.
+func runEditImport(args []string) error {
+	fs := flag.NewFlagSet("edit-import", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database to save the edits to")
+	dryRun := fs.Bool("dry-run", false, "report the edits without saving them")
+	strict := addStrictFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: quotes edit-import [flags] <files written by edit-export>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("edit-import needs a file to read")
+	}
+
+	var entries []editEntry
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		var file []editEntry
+		if err := strict(path, data, &file); err != nil {
+			return err
+		}
+		entries = append(entries, file...)
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	var updated, unchanged, skipped int
+	for _, e := range entries {
+		fields, err := applyEdit(tx, e)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		switch {
+		case fields == nil:
+			skipped++
+		case len(fields) == 0:
+			unchanged++
+		default:
+			fmt.Fprintf(progress, "  quote %d: %s\n", e.ID, strings.Join(fields, ", "))
+			updated++
+		}
+	}
+	if _, err := schema.Normalize(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if *dryRun {
+		tx.Rollback()
+		fmt.Fprintf(progress, "\n✓ Dry run, nothing changed\n")
+	} else {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		fmt.Fprintf(progress, "\n✓ Edit import completed\n")
+	}
+	fmt.Fprintf(progress, "  %d updated, %d unchanged, %d skipped\n", updated, unchanged, skipped)
+	return nil
+}
+
+// applyEdit saves the fields of e that differ from the quote as exported,
+// returning their names: none when e was not edited, nil when it was
+// skipped
+func applyEdit(tx *sql.Tx, e editEntry) ([]string, error) {
+	if e.hash() == e.Hash {
+		return []string{}, nil
+	}
+	e.Text = strings.TrimSpace(e.Text)
+	e.Author, e.Book, e.Lang = strings.TrimSpace(e.Author), strings.TrimSpace(e.Book), strings.TrimSpace(e.Lang)
+	if origin.IsAnonymousName(e.Author) {
+		// Proverbs and anonymous quotes have no author
+		e.Author = ""
+	}
+	if e.Text == "" {
+		return nil, fmt.Errorf("quote %d: text must not be empty", e.ID)
+	}
+	if e.Origin != "" {
+		kind, err := origin.Parse(e.Origin)
+		if err != nil {
+			return nil, fmt.Errorf("quote %d: %v", e.ID, err)
+		}
+		e.Origin = string(kind)
+	}
+
+	var was editEntry
+	var author string
+	err := tx.QueryRow("SELECT text, COALESCE(author, ''), COALESCE(lang, ''), COALESCE(origin, '') FROM quotes WHERE id = ?", e.ID).
+		Scan(&was.Text, &author, &was.Lang, &was.Origin)
+	if err == sql.ErrNoRows {
+		fmt.Fprintf(progress, "  quote %d: skipped, deleted since the export\n", e.ID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quote %d: %v", e.ID, err)
+	}
+	was.Author, was.Book = schema.SplitAttribution(author)
+	if was.hash() == e.hash() {
+		// Imported before
+		return []string{}, nil
+	}
+	if was.hash() != e.Hash {
+		fmt.Fprintf(progress, "  quote %d: skipped, changed in the database since the export\n", e.ID)
+		return nil, nil
+	}
+
+	var fields, sets []string
+	var args []interface{}
+	set := func(field, column string, value interface{}) {
+		fields = append(fields, field)
+		sets = append(sets, column+" = ?")
+		args = append(args, value)
+	}
+	if e.Text != was.Text {
+		set("text", "text", e.Text)
+	}
+	if e.Author != was.Author || e.Book != was.Book {
+		if e.Author != was.Author {
+			fields = append(fields, "author")
+		}
+		if e.Book != was.Book {
+			fields = append(fields, "book")
+		}
+		sets = append(sets, "author = ?", "authorId = NULL", "bookId = NULL")
+		args = append(args, nullIfEmpty(schema.JoinAttribution(e.Author, e.Book)))
+		if e.Origin == was.Origin && was.Origin != "" {
+			// Classified again by Normalize
+			sets = append(sets, "origin = NULL")
+		}
+	}
+	if e.Lang != was.Lang {
+		set("lang", "lang", nullIfEmpty(e.Lang))
+	}
+	if e.Origin != was.Origin {
+		set("origin", "origin", nullIfEmpty(e.Origin))
+	}
+	if e.Text != was.Text || e.Lang != was.Lang {
+		hash := dedup.TextHashIn(e.Text, e.Lang)
+		err := schema.CheckUnique(tx, hash, e.ID)
+		if errors.Is(err, schema.ErrDuplicate) {
+			fmt.Fprintf(progress, "  quote %d: skipped, %v\n", e.ID, err)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, "textHash = ?")
+		args = append(args, hash)
+	}
+	if len(sets) == 0 {
+		return []string{}, nil
+	}
+
+	args = append(args, e.ID)
+	if _, err := tx.Exec("UPDATE quotes SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...); err != nil {
+		return nil, fmt.Errorf("failed to edit quote %d: %v", e.ID, err)
+	}
+	return fields, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"quotesparser/dedup"
+)
+
+func TestEditRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []struct{ text, author, lang string }{
+		{"Kişi, sevdiği şeye benzer.", "Ahmet Hamdi Tanpınar - Huzur", "tr"},
+		{"Az olsun, öz olsun.", "", "tr"},
+		{"Yaşamak şakaya gelmez.", "Nazım Hikmet", "tr"},
+		{"The only way out is through.", "Robert Frost", "en"},
+	} {
+		_, err := db.Exec("INSERT INTO quotes (text, author, lang, textHash) VALUES (?, ?, ?, ?)", q.text, nullIfEmpty(q.author), q.lang, dedup.TextHashIn(q.text, q.lang))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first import classifies the quotes, so the YAML is exported last
+	for _, file := range []string{"quotes.json", "quotes.toml", "quotes.yaml"} {
+		path := filepath.Join(dir, file)
+		if err := runEditExport([]string{"--db", dbPath, "--filter", "lang=tr", path}); err != nil {
+			t.Fatal(err)
+		}
+		if err := runEditImport([]string{"--db", dbPath, "--strict", path}); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+	if err := runEditExport([]string{"--db", dbPath, "--filter", "colour=red", "x.yaml"}); err == nil {
+		t.Error("an unknown filter was accepted")
+	}
+
+	path := filepath.Join(dir, "quotes.yaml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "Robert Frost") {
+		t.Errorf("the filter was ignored:\n%s", data)
+	}
+	edited := strings.NewReplacer(
+		"Kişi, sevdiği şeye benzer.", "Kişi sevdiği şeye benzer.",
+		"author: Nazım Hikmet", "author: Nazım Hikmet Ran",
+		"text: Az olsun, öz olsun.", "text: Az olsun, öz olsun!",
+	).Replace(string(data))
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Changed in the database after the export, so its edit is skipped
+	if _, err := db.Exec("UPDATE quotes SET lang = 'az' WHERE text = 'Az olsun, öz olsun.'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runEditImport([]string{"--db", dbPath, "--dry-run", path}); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM quotes WHERE text = 'Kişi sevdiği şeye benzer.'").Scan(&n); err != nil || n != 0 {
+		t.Fatalf("the dry run saved an edit (%v)", err)
+	}
+	if err := runEditImport([]string{"--db", dbPath, path}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT text, COALESCE(author, ''), lang, textHash, authorId IS NOT NULL FROM quotes ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var text, author, lang, hash string
+		var linked bool
+		if err := rows.Scan(&text, &author, &lang, &hash, &linked); err != nil {
+			t.Fatal(err)
+		}
+		if hash != dedup.TextHashIn(text, lang) {
+			t.Errorf("%q kept a stale hash", text)
+		}
+		if author != "" && !linked {
+			t.Errorf("%q is not linked to its author", text)
+		}
+		got = append(got, text+" | "+author)
+	}
+	want := []string{
+		"Kişi sevdiği şeye benzer. | Ahmet Hamdi Tanpınar - Huzur",
+		"Az olsun, öz olsun. | ",
+		"Yaşamak şakaya gelmez. | Nazım Hikmet Ran",
+		"The only way out is through. | Robert Frost",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("quotes after the import:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"quotesparser/origin"
+	"quotesparser/quota"
+	"quotesparser/shard"
+	"quotesparser/store"
+)
+
+// exportedQuote is a quote as export writes it, one per NDJSON line or CSV
+// row, in the order of exportColumns
+type exportedQuote struct {
+	ID            int64   `json:"id"`
+	Text          string  `json:"text"`
+	Author        string  `json:"author,omitempty"`
+	Lang          string  `json:"lang,omitempty"`
+	Origin        string  `json:"origin,omitempty"`
+	Source        string  `json:"source,omitempty"`
+	Likes         int     `json:"likes,omitempty"`
+	ViewCount     int     `json:"viewCount"`
+	Confidence    float64 `json:"confidence,omitempty"`
+	SourceURL     string  `json:"sourceUrl,omitempty"`
+	FetchedAt     string  `json:"fetchedAt,omitempty"`
+	ParserVersion string  `json:"parserVersion,omitempty"`
+}
+
+var exportColumns = []string{"id", "text", "author", "lang", "origin", "source", "likes", "viewCount", "confidence", "sourceUrl", "fetchedAt", "parserVersion"}
+
+func (q exportedQuote) row() []string {
+	var confidence string
+	if q.Confidence > 0 {
+		confidence = strconv.FormatFloat(q.Confidence, 'f', -1, 64)
+	}
+	return []string{strconv.FormatInt(q.ID, 10), q.Text, q.Author, q.Lang, q.Origin, q.Source, strconv.Itoa(q.Likes), strconv.Itoa(q.ViewCount),
+		confidence, q.SourceURL, q.FetchedAt, q.ParserVersion}
+}
+
+// runExport writes quotes as NDJSON or CSV for data warehouses and
+// spreadsheets. With --max-size the file is split into numbered parts the
+// loaders accept, listed with their rows in a manifest.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	format := fs.String("format", "ndjson", "ndjson (a JSON object a line) or csv (with a header row)")
+	out := fs.String("out", "", "file to write, quotes.<format> by default; with --max-size, its parts are numbered after it, e.g. quotes-00001.ndjson, next to quotes.manifest.json")
+	maxSize := fs.String("max-size", "0", "largest part, e.g. 100MB for BigQuery uploads; 0 writes a single file")
+	lang := fs.String("lang", "", "only quotes in this language, e.g. tr")
+	author := fs.String("author", "", "only quotes by this author")
+	from := fs.String("origin", "", "only quotes from this kind of work: "+origin.List())
+	minConfidence := fs.Float64("min-confidence", 0, "only quotes the parser was at least this sure of, from 0 to 1")
+	limit := fs.Int("limit", 0, "export at most this many quotes; 0 for all")
+	fs.Parse(args)
+
+	var encode func(exportedQuote) ([]byte, error)
+	var header []byte
+	switch *format {
+	case "ndjson":
+		encode = func(q exportedQuote) ([]byte, error) {
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			err := enc.Encode(q)
+			return buf.Bytes(), err
+		}
+	case "csv":
+		encode = func(q exportedQuote) ([]byte, error) { return csvRecord(q.row()) }
+		var err error
+		if header, err = csvRecord(exportColumns); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q, want ndjson or csv", *format)
+	}
+	size, err := quota.ParseSize(*maxSize)
+	if err != nil {
+		return fmt.Errorf("--max-size: %v", err)
+	}
+	if *out == "" {
+		*out = "quotes." + *format
+	}
+	var kind origin.Type
+	if *from != "" {
+		if kind, err = origin.Parse(*from); err != nil {
+			return err
+		}
+	}
+
+	s, err := store.Open(*dsn)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	quotes, err := s.Quotes(store.Filter{Lang: *lang, Author: *author, Origin: kind, MinConfidence: *minConfidence, Limit: *limit})
+	if err != nil {
+		return err
+	}
+
+	w := shard.Create(*out, *format, int64(size), header)
+	for _, q := range quotes {
+		record, err := encode(exportedQuote{ID: q.ID, Text: q.Text, Author: q.Author, Lang: q.Lang, Origin: string(q.Origin), Source: q.Source, Likes: q.Likes,
+			ViewCount: q.ViewCount, Confidence: q.Confidence, SourceURL: q.Provenance.URL, FetchedAt: q.Provenance.FetchedAt, ParserVersion: q.Provenance.Parser})
+		if err != nil {
+			w.Close()
+			return fmt.Errorf("failed to encode quote %d: %v", q.ID, err)
+		}
+		if err := w.Write(record); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	m, err := w.Close()
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		fmt.Fprintf(progress, "✓ Exported %d quotes to %s\n", m.Rows, *out)
+		return nil
+	}
+	fmt.Fprintf(progress, "✓ Exported %d quotes in %d parts of at most %s, listed in %s\n", m.Rows, len(m.Parts), quota.FormatSize(size), shard.ManifestName(*out))
+	return nil
+}
+
+// csvRecord encodes one CSV row with its line break
+func csvRecord(fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(fields); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"quotesparser/shard"
+	"quotesparser/store"
+)
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "database.db")
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.SaveQuotes([]store.Quote{
+		{Text: "Be here now.", Author: "Ram Dass", Lang: "en", Source: "goodreads", Confidence: 0.9},
+		{Text: "Hayat, sen başka planlar yaparken başına gelenlerdir.", Lang: "tr"},
+		{Text: "Say \"cheese\", then smile.", Lang: "en"},
+	})
+	s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unsharded, NDJSON is one file of a quote a line
+	ndjson := filepath.Join(dir, "quotes.ndjson")
+	if err := runExport([]string{"--db", dbPath, "--out", ndjson}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(ndjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []exportedQuote
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var q exportedQuote
+		if err := json.Unmarshal(sc.Bytes(), &q); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, q)
+	}
+	if len(lines) != 3 || lines[0].Author != "Ram Dass" || lines[0].Source != "goodreads" || lines[0].Confidence != 0.9 {
+		t.Errorf("NDJSON = %+v", lines)
+	}
+
+	// Sharded, every CSV part has the header and the manifest adds up
+	csvPath := filepath.Join(dir, "out", "quotes.csv")
+	os.Mkdir(filepath.Dir(csvPath), 0755)
+	if err := runExport([]string{"--db", dbPath, "--format", "csv", "--out", csvPath, "--max-size", "150"}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(shard.ManifestName(csvPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m shard.Manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Format != "csv" || m.Rows != 3 || len(m.Parts) < 2 {
+		t.Fatalf("manifest = %+v, want 3 rows in several parts", m)
+	}
+	var rows int64
+	for _, p := range m.Parts {
+		f, err := os.Open(filepath.Join(dir, "out", p.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", p.File, err)
+		}
+		if len(records) < 2 || records[0][0] != "id" || int64(len(records)-1) != p.Rows {
+			t.Errorf("%s has %d records, want the header and %d rows", p.File, len(records), p.Rows)
+		}
+		rows += p.Rows
+	}
+	if rows != m.Rows {
+		t.Errorf("parts have %d rows, manifest says %d", rows, m.Rows)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"quotesparser/datafile"
+	"quotesparser/kitap"
+	"quotesparser/langdetect"
+	"quotesparser/quality"
+	"quotesparser/rejects"
+	"quotesparser/source"
+	"quotesparser/store"
+)
+
+// stringList is a flag that can be repeated, e.g. --book a --book b
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// addLangFlag registers --lang and returns the language to store for a
+// quote: the one forced on the command line, or else the detected one,
+// falling back to the source's language when detection cannot tell
+func addLangFlag(fs *flag.FlagSet) func(text, fallback string) string {
+	lang := fs.String("lang", "auto", "language of the quotes: auto detects each one, falling back to the source's; a code such as tr forces it")
+	return func(text, fallback string) string {
+		if *lang != "auto" {
+			return *lang
+		}
+		return langdetect.DetectOr(text, fallback)
+	}
+}
+
+// addRulesFlag registers --rules and returns the 1000kitap extraction rules
+// to parse with: the file's, or kitap.DefaultRules when none is given
+func addRulesFlag(fs *flag.FlagSet) func() (kitap.Rules, error) {
+	path := fs.String("rules", "", "JSON file of 1000kitap selectors (quote, book, author, heading, climb) and cleanup steps (e.g. \"cleanup\": \"html,control,whitespace,unwrap,smart-quotes\") overriding the built-in ones")
+	return func() (kitap.Rules, error) {
+		if *path == "" {
+			return kitap.DefaultRules, nil
+		}
+		return kitap.LoadRules(*path)
+	}
+}
+
+// addPolicyFlag registers --incomplete and returns the policy for records
+// missing fields their source normally has: the one given, or the source's
+// default
+func addPolicyFlag(fs *flag.FlagSet, sourceName string) func() (source.Policy, error) {
+	name := fs.String("incomplete", string(source.PolicyFor(sourceName)), "what to do with records missing fields the source normally has, such as a quote's book: drop, keep (with the fields empty) or enrich (keep and flag for a later lookup)")
+	return func() (source.Policy, error) {
+		p, err := source.ParsePolicy(*name)
+		if err != nil {
+			return "", fmt.Errorf("--incomplete: %v", err)
+		}
+		return p, nil
+	}
+}
+
+// addQualityFlag registers --quality and returns the filter for the quotes
+// of the source named sourceName: the one the file configures for it, or
+// one that keeps every quote without the flag
+func addQualityFlag(fs *flag.FlagSet, sourceName string) func() (quality.Filter, error) {
+	path := fs.String("quality", "", `JSON file of quality filters per source, e.g. {"default": {"minLength": 10, "maxLength": 600, "endPunctuation": true, "blacklist": ["read more"], "maxUpperRatio": 0.5}}`)
+	return func() (quality.Filter, error) {
+		if *path == "" {
+			return quality.Filter{}, nil
+		}
+		c, err := quality.Load(*path)
+		if err != nil {
+			return quality.Filter{}, fmt.Errorf("--quality: %v", err)
+		}
+		return c.For(sourceName), nil
+	}
+}
+
+// filterQuality keeps the quotes of file that pass f, listing the others in
+// rep, and returns them with how many it rejected
+func filterQuality[Q any](f quality.Filter, rep *rejects.Report, file string, quotes []Q, text func(Q) string) ([]Q, int) {
+	kept := quotes[:0]
+	for _, q := range quotes {
+		if v := f.Check(text(q)); !v.OK() {
+			rep.Add(rejects.Reject{Kind: rejects.Quote, File: file, Text: text(q), Reason: v.Reason()})
+			continue
+		}
+		kept = append(kept, q)
+	}
+	return kept, len(quotes) - len(kept)
+}
+
+// addStrictFlag registers --strict and returns the reader of JSON, YAML and
+// TOML inputs, which checks them against the type they fill: with --strict
+// unknown fields are errors rather than warnings
+func addStrictFlag(fs *flag.FlagSet) func(file string, data []byte, v interface{}) error {
+	strict := fs.Bool("strict", false, "reject input with fields it does not have, such as misspelled ones, rather than warn")
+	return func(file string, data []byte, v interface{}) error {
+		return datafile.Unmarshal(file, data, v, *strict)
+	}
+}
+
+// addDryRunFlag registers --dry-run and returns the opener of the store to
+// save into: the one of dsn, or with --dry-run a store.Preview of it, which
+// counts what saving would do without changing the database
+func addDryRunFlag(fs *flag.FlagSet) func(dsn string, opts store.Options) (store.Store, error) {
+	dryRun := fs.Bool("dry-run", false, "parse, dedup and validate, and report what would be inserted, updated or skipped without changing the database")
+	return func(dsn string, opts store.Options) (store.Store, error) {
+		if !*dryRun {
+			return store.OpenWith(dsn, opts)
+		}
+		opts.ReadOnly = true
+		s, err := store.OpenWith(dsn, opts)
+		if err != nil {
+			return nil, err
+		}
+		p, err := store.NewPreview(s)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		return p, nil
+	}
+}
+
+// reportDryRun prints what the saves into s would have done when s is a
+// dry run's store.Preview, and reports whether it was
+func reportDryRun(s store.Store) bool {
+	p, ok := s.(*store.Preview)
+	if !ok {
+		return false
+	}
+	quotes, authors, trivia := p.Changes()
+	fmt.Fprintf(progress, "\n✓ Dry run, nothing changed\n")
+	for _, t := range []struct {
+		name string
+		c    store.Changes
+	}{{"Quotes", quotes}, {"Authors", authors}, {"Trivia", trivia}} {
+		if t.c != (store.Changes{}) {
+			fmt.Fprintf(progress, "  %s: %d to insert, %d to update, %d to skip\n", t.name, t.c.Inserted, t.c.Updated, t.c.Skipped)
+		}
+	}
+	return true
+}
+
+// addReportFlag registers --report and returns the report of the run's
+// rejects, nil without the flag
+func addReportFlag(fs *flag.FlagSet) func() *rejects.Report {
+	path := fs.String("report", "", "JSON file to list the files that failed parsing and the records rejected in, to fix the source data by")
+	return func() *rejects.Report {
+		if *path == "" {
+			return nil
+		}
+		return rejects.New(*path, "quotes "+fs.Name())
+	}
+}
+
+// saveReport saves the report of a run's rejects, if it has one
+func saveReport(r *rejects.Report) error {
+	if r == nil {
+		return nil
+	}
+	if err := r.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "✓ Listed %d rejects in %s\n", r.Len(), r.Path())
+	return nil
+}
+
+// rejectDropped lists in rep a quote of file that p drops for the fields it
+// is missing
+func rejectDropped(rep *rejects.Report, p source.Policy, file, text string, missing []string) {
+	var probe source.Report
+	if keep, _ := probe.Apply(p, missing); !keep {
+		rep.Add(rejects.Reject{Kind: rejects.Quote, File: file, Text: text, Reason: "missing " + strings.Join(missing, ", ")})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"quotesparser/fortune"
+	"quotesparser/origin"
+	"quotesparser/store"
+)
+
+// runFortune exports quotes, trivia or fun facts as a fortune(6) file with
+// its strfile index, so fortune can show them on login
+func runFortune(args []string) error {
+	fs := flag.NewFlagSet("fortune", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	collection := fs.String("collection", "quotes", "what to export: quotes, trivia or funfacts")
+	lang := fs.String("lang", "", "only quotes in this language, e.g. tr")
+	author := fs.String("author", "", "only quotes by this author")
+	from := fs.String("origin", "", "only quotes from this kind of work: "+origin.List())
+	minConfidence := fs.Float64("min-confidence", 0, "only quotes the parser was at least this sure of, from 0 to 1")
+	category := fs.String("category", "", "only trivia in this category")
+	limit := fs.Int("limit", 0, "export at most this many entries; 0 for all")
+	width := fs.Int("width", fortune.DefaultWidth, "wrap entries to this many columns")
+	out := fs.String("out", "quotes", "fortune file to write; the index goes to the same name with .dat")
+	fs.Parse(args)
+
+	var kind origin.Type
+	if *from != "" {
+		var err error
+		if kind, err = origin.Parse(*from); err != nil {
+			return err
+		}
+	}
+
+	var entries []string
+	switch *collection {
+	case "quotes":
+		s, err := store.Open(*dsn)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		quotes, err := s.Quotes(store.Filter{Lang: *lang, Author: *author, Origin: kind, MinConfidence: *minConfidence, Limit: *limit})
+		if err != nil {
+			return err
+		}
+		for _, q := range quotes {
+			entries = append(entries, fortune.Format(q.Text, q.Credit(), *width))
+		}
+	case "trivia":
+		s, err := store.Open(*dsn)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		trivia, err := s.Trivia(*category, *limit)
+		if err != nil {
+			return err
+		}
+		for _, t := range trivia {
+			entries = append(entries, fortune.Format("Q: "+t.Question+"\nA: "+t.Answer, "", *width))
+		}
+	case "funfacts":
+		facts, err := funFactTexts(*dsn, *limit)
+		if err != nil {
+			return err
+		}
+		for _, text := range facts {
+			entries = append(entries, fortune.Format(text, "", *width))
+		}
+	default:
+		return fmt.Errorf("unknown collection %q, want quotes, trivia or funfacts", *collection)
+	}
+
+	n, err := writeFortunes(*out, entries)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "\n✓ Wrote %d fortunes to %s and %s.dat\n", n, *out, *out)
+	fmt.Fprintf(progress, "  Try: fortune %s\n", *out)
+	return nil
+}
+
+// funFactTexts reads fun facts, which only the SQLite database keeps
+func funFactTexts(dsn string, limit int) ([]string, error) {
+	if dsn == "memory:" || strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return nil, errors.New("fun facts are only kept in SQLite")
+	}
+	db, err := openDB(strings.TrimPrefix(dsn, "sqlite:"))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	query := "SELECT text FROM funFacts ORDER BY id"
+	var args []interface{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fun facts: %v", err)
+	}
+	defer rows.Close()
+	var texts []string
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return nil, fmt.Errorf("failed to read fun facts: %v", err)
+		}
+		texts = append(texts, text)
+	}
+	return texts, rows.Err()
+}
+
+// writeFortunes writes entries to path and its index to path.dat, returning
+// how many were written
+func writeFortunes(path string, entries []string) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+	w := fortune.NewWriter(f)
+	for _, e := range entries {
+		if err := w.Add(e); err != nil {
+			return 0, fmt.Errorf("failed to write %s: %v", path, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	dat, err := os.Create(path + ".dat")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s.dat: %v", path, err)
+	}
+	defer dat.Close()
+	if err := w.WriteIndex(dat); err != nil {
+		return 0, fmt.Errorf("failed to write %s.dat: %v", path, err)
+	}
+	if err := dat.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write %s.dat: %v", path, err)
+	}
+	return w.Len(), nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+
+	"quotesparser/imgcache"
+	"quotesparser/palette"
+	"quotesparser/quota"
+)
+
+// parseWidths reads a comma separated list of image widths
+func parseWidths(s string) ([]int, error) {
+	var widths []int
+	for _, part := range strings.Split(s, ",") {
+		w, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("--sizes: bad width %q", part)
+		}
+		widths = append(widths, w)
+	}
+	return widths, nil
+}
+
+// joinWidths is the inverse of parseWidths
+func joinWidths(widths []int) string {
+	list := make([]string, len(widths))
+	for i, w := range widths {
+		list[i] = strconv.Itoa(w)
+	}
+	return strings.Join(list, ",")
+}
+
+// imageTable is a table recording the images cached for the rows of another,
+// e.g. authorPortraits for authors
+type imageTable struct {
+	table  string // e.g. authorPortraits
+	key    string // column referencing the row, e.g. authorId
+	parent string // table the images belong to, e.g. authors
+}
+
+// addPruneFlags registers the cache prune flags shared by portraits and
+// covers and returns a function that prunes when asked to, reporting whether
+// it did
+func addPruneFlags(fs *flag.FlagSet) func(db *sql.DB, dir string, t imageTable) (bool, error) {
+	prune := fs.Bool("prune", false, "instead of fetching, remove the images of deleted rows and trim the cache to --max-cache")
+	maxCache := fs.String("max-cache", "0", "with --prune, evict the images fetched longest ago until the cache fits (0 = no limit)")
+
+	return func(db *sql.DB, dir string, t imageTable) (bool, error) {
+		if !*prune {
+			return false, nil
+		}
+		limit, err := quota.ParseSize(*maxCache)
+		if err != nil {
+			return true, fmt.Errorf("--max-cache: %v", err)
+		}
+		return true, pruneImages(db, dir, t, int64(limit))
+	}
+}
+
+// pruneImages removes cached images whose row is gone or that were not
+// recorded as found, then trims the cache to maxBytes. Evicted rows are
+// forgotten so the next run fetches them again.
+func pruneImages(db *sql.DB, dir string, t imageTable, maxBytes int64) error {
+	live := make(map[int64]bool)
+	rows, err := db.Query(fmt.Sprintf(
+		"SELECT i.%[2]s FROM %[1]s i JOIN %[3]s p ON p.id = i.%[2]s WHERE i.status = 'ok'", t.table, t.key, t.parent))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", t.table, err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read %s: %v", t.table, err)
+		}
+		live[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", t.table, err)
+	}
+
+	report, err := imgcache.Prune(dir, func(id int64) bool { return live[id] }, maxBytes)
+	if err != nil {
+		return err
+	}
+	for _, id := range append(report.Orphans, report.Evicted...) {
+		if _, err := db.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", t.table, t.key), id); err != nil {
+			return fmt.Errorf("failed to forget pruned images: %v", err)
+		}
+	}
+
+	fmt.Fprintf(progress, "✓ Pruned %s\n", dir)
+	fmt.Fprintf(progress, "  Deleted rows: %d\n", len(report.Orphans))
+	fmt.Fprintf(progress, "  Evicted: %d\n", len(report.Evicted))
+	fmt.Fprintf(progress, "  Freed: %s, %s left\n", quota.FormatSize(uint64(report.Freed)), quota.FormatSize(uint64(report.Kept)))
+	return nil
+}
+
+// savePalette records the dominant colors of a row's cached image, read from
+// its smallest variant
+func savePalette(db *sql.DB, dir string, t imageTable, id int64, widths []int) error {
+	if len(widths) == 0 {
+		return nil
+	}
+	img, err := imgcache.Load(dir, id, slices.Min(widths))
+	if err != nil {
+		return err
+	}
+	p := palette.Extract(img, palette.DefaultSize)
+	if _, err := db.Exec(fmt.Sprintf("UPDATE %s SET palette = ? WHERE %s = ?", t.table, t.key), p.String(), id); err != nil {
+		return fmt.Errorf("failed to save palette: %v", err)
+	}
+	return nil
+}
+
+// fillPalettes extracts the palettes of images cached before palettes were
+// recorded, returning how many it filled in. Images missing from the cache
+// are left for --prune.
+func fillPalettes(db *sql.DB, dir string, t imageTable) (int, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s, widths FROM %s WHERE status = 'ok' AND palette IS NULL", t.key, t.table))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", t.table, err)
+	}
+	todo := make(map[int64][]int)
+	for rows.Next() {
+		var id int64
+		var widths sql.NullString
+		if err := rows.Scan(&id, &widths); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read %s: %v", t.table, err)
+		}
+		if ws, err := parseWidths(widths.String); err == nil {
+			todo[id] = ws
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read %s: %v", t.table, err)
+	}
+
+	filled := 0
+	for id, widths := range todo {
+		err := savePalette(db, dir, t, id, widths)
+		switch {
+		case err == nil:
+			filled++
+		case errors.Is(err, fs.ErrNotExist):
+		default:
+			return filled, err
+		}
+	}
+	return filled, nil
+}
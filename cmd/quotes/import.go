@@ -0,0 +1,282 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"quotesparser/csvimport"
+	"quotesparser/kindle"
+	"quotesparser/kitap"
+	"quotesparser/origin"
+	"quotesparser/source"
+	"quotesparser/store"
+)
+
+func runImport(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes import <source> [flags] <json, yaml or toml files> (sources: %s)", sourceList("1000kitap", "csv", "kindle"))
+	}
+
+	switch args[0] {
+	case "1000kitap":
+		return runImport1000Kitap(args[1:])
+	case "csv":
+		return runImportCSV(args[1:])
+	case "kindle":
+		return runImportKindle(args[1:])
+	case "uselessfacts":
+		return fmt.Errorf("fun facts are not quotes: insert the facts download uselessfacts saved with quotes watch or processFunFacts.go")
+	default:
+		if src, ok := source.Get(args[0]); ok {
+			return runImportSource(src, args[1:])
+		}
+		return fmt.Errorf("unknown source %q (sources: %s)", args[0], sourceList("1000kitap", "csv", "kindle"))
+	}
+}
+
+// runImport1000Kitap inserts the JSON written by parse 1000kitap into any
+// store (SQLite file, postgres:// DSN or memory:)
+func runImport1000Kitap(args []string) error {
+	fs := flag.NewFlagSet("import 1000kitap", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	batchSize := fs.Int("batch", store.DefaultBatchSize, "rows per multi-row INSERT")
+	langOf := addLangFlag(fs)
+	openStore := addDryRunFlag(fs)
+	decode := addStrictFlag(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no input files given")
+	}
+
+	s, err := openStore(*dsn, store.Options{BatchSize: *batchSize})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	total, inserted := 0, 0
+	for _, filename := range fs.Args() {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", filename, err)
+		}
+		var quotes []kitap.Quote
+		if err := decode(filename, content, &quotes); err != nil {
+			return err
+		}
+
+		file := pageProvenance(filename, parserVersion("1000kitap"))
+		rows := make([]store.Quote, len(quotes))
+		for i, q := range quotes {
+			prov := stamped(store.Provenance{URL: q.SourceURL, File: q.SourceFile, FetchedAt: q.FetchedAt, Parser: q.ParserVersion}, file)
+			rows[i] = store.Quote{Text: q.QuoteText, Author: q.Author, Book: q.BookName, Lang: langOf(q.QuoteText, "tr"), Enrich: q.Enrich, Source: "1000kitap", Raw: q.RawText, Confidence: q.Confidence, Provenance: prov,
+				Origin: origin.Classify(origin.Hints{Text: q.QuoteText, Author: q.Author, Book: q.BookName, Source: "1000kitap"})}
+		}
+		n, err := s.SaveQuotes(rows)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		total += len(quotes)
+		inserted += n
+	}
+
+	if reportDryRun(s) {
+		return nil
+	}
+	fmt.Fprintf(progress, "✓ Imported %d quotes (%d already in the database)\n", inserted, total-inserted)
+	return nil
+}
+
+// runImportKindle inserts the highlights of Kindle "My Clippings.txt" files.
+// A highlight extended or moved on the device is in the file several times:
+// only the latest of overlapping highlights of a book is kept.
+func runImportKindle(args []string) error {
+	fs := flag.NewFlagSet("import kindle", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	batchSize := fs.Int("batch", store.DefaultBatchSize, "rows per multi-row INSERT")
+	langOf := addLangFlag(fs)
+	openStore := addDryRunFlag(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no clippings files given")
+	}
+
+	s, err := openStore(*dsn, store.Options{BatchSize: *batchSize})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	total, overlapping, inserted := 0, 0, 0
+	for _, filename := range fs.Args() {
+		f, err := os.Open(filename)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", filename, err)
+		}
+		clips, err := kindle.Parse(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		highlights := kindle.Dedup(clips)
+
+		prov := pageProvenance(filename, parserVersion("kindle"))
+		rows := make([]store.Quote, len(highlights))
+		for i, c := range highlights {
+			rows[i] = store.Quote{Text: c.Text, Author: c.Author, Book: c.Title, Lang: langOf(c.Text, ""), Source: "kindle", Provenance: prov,
+				Origin: origin.Classify(origin.Hints{Text: c.Text, Author: c.Author, Book: c.Title, Source: "kindle"})}
+		}
+		n, err := s.SaveQuotes(rows)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		total += len(highlights)
+		overlapping += len(clips) - len(highlights)
+		inserted += n
+	}
+
+	if reportDryRun(s) {
+		return nil
+	}
+	fmt.Fprintf(progress, "✓ Imported %d highlights (%d already in the database, %d overlapping dropped)\n", inserted, total-inserted, overlapping)
+	return nil
+}
+
+// runImportCSV inserts the quotes of CSV files, such as spreadsheet or
+// Notion exports, mapping their columns with --map; columns named like a
+// quote field (Quote, Author, Book...) need no mapping
+func runImportCSV(args []string) error {
+	fs := flag.NewFlagSet("import csv", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	batchSize := fs.Int("batch", store.DefaultBatchSize, "rows per multi-row INSERT")
+	mapping := fs.String("map", "", "columns of the quote fields as field=column,..., the column a header name or 1-based number, e.g. text=Quote,author=col2 (fields: "+strings.Join(csvimport.Fields, ", ")+")")
+	delimiter := fs.String("delimiter", "auto", `field separator: auto, ",", ";" or "tab"`)
+	encoding := fs.String("encoding", "auto", "file encoding: auto, "+strings.Join(csvimport.Encodings, ", "))
+	noHeader := fs.Bool("no-header", false, "the first line is a quote, not column names")
+	sourceName := fs.String("source", "csv", "source to record the quotes under, e.g. goodreads or notion")
+	langOf := addLangFlag(fs)
+	openStore := addDryRunFlag(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no CSV files given")
+	}
+	m, err := csvimport.ParseMapping(*mapping)
+	if err != nil {
+		return err
+	}
+	opts := csvimport.Options{Map: m, NoHeader: *noHeader}
+	switch *delimiter {
+	case "auto":
+	case "tab", `\t`:
+		opts.Comma = '\t'
+	default:
+		if utf8.RuneCountInString(*delimiter) != 1 {
+			return fmt.Errorf("--delimiter must be auto, tab or one character")
+		}
+		opts.Comma, _ = utf8.DecodeRuneInString(*delimiter)
+	}
+
+	s, err := openStore(*dsn, store.Options{BatchSize: *batchSize})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	total, inserted := 0, 0
+	for _, filename := range fs.Args() {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", filename, err)
+		}
+		text, used, err := csvimport.Decode(content, *encoding)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		quotes, err := csvimport.Read(text, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		fmt.Fprintf(progress, "%s: %d quotes (%s)\n", filename, len(quotes), used)
+
+		prov := pageProvenance(filename, parserVersion("csv"))
+		rows := make([]store.Quote, len(quotes))
+		for i, q := range quotes {
+			rows[i] = store.Quote{Text: q.Text, Author: q.Author, Book: q.Book, Lang: langOf(q.Text, q.Lang), Source: *sourceName, Likes: q.Likes, Provenance: prov,
+				Origin: origin.Classify(origin.Hints{Text: q.Text, Author: q.Author, Book: q.Book, Source: *sourceName})}
+		}
+		n, err := s.SaveQuotes(rows)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		total += len(quotes)
+		inserted += n
+	}
+
+	if reportDryRun(s) {
+		return nil
+	}
+	fmt.Fprintf(progress, "✓ Imported %d quotes (%d already in the database)\n", inserted, total-inserted)
+	return nil
+}
+
+// runImportSource inserts the JSON written by parse <source>, or quotes kept
+// by hand in YAML or TOML, into any store
+func runImportSource(src source.Adapter, args []string) error {
+	fs := flag.NewFlagSet("import "+src.Name(), flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	batchSize := fs.Int("batch", store.DefaultBatchSize, "rows per multi-row INSERT")
+	langOf := addLangFlag(fs)
+	openStore := addDryRunFlag(fs)
+	decode := addStrictFlag(fs)
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no input files given")
+	}
+
+	s, err := openStore(*dsn, store.Options{BatchSize: *batchSize})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	total, inserted := 0, 0
+	for _, filename := range fs.Args() {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", filename, err)
+		}
+		var quotes []source.Quote
+		if err := decode(filename, content, &quotes); err != nil {
+			return err
+		}
+
+		file := pageProvenance(filename, parserVersion(src.Name()))
+		rows := make([]store.Quote, len(quotes))
+		for i, q := range quotes {
+			// Block strings of YAML end with a line break
+			q.Text = strings.TrimSpace(q.Text)
+			prov := stamped(store.Provenance{URL: q.SourceURL, File: q.SourceFile, FetchedAt: q.FetchedAt, Parser: q.ParserVersion}, file)
+			rows[i] = store.Quote{Text: q.Text, Author: q.Author, Book: q.Book, Lang: langOf(q.Text, q.Lang), Enrich: q.Enrich, Source: src.Name(), Likes: q.Likes, Confidence: q.Confidence, Provenance: prov,
+				Origin: origin.Classify(origin.Hints{Text: q.Text, Author: q.Author, Book: q.Book, Source: src.Name()})}
+		}
+		n, err := s.SaveQuotes(rows)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		total += len(quotes)
+		inserted += n
+	}
+
+	if reportDryRun(s) {
+		return nil
+	}
+	fmt.Fprintf(progress, "✓ Imported %d quotes (%d already in the database)\n", inserted, total-inserted)
+	return nil
+}
@@ -0,0 +1,676 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"quotesparser/fetch"
+	"quotesparser/kitap"
+	"quotesparser/origin"
+	"quotesparser/rejects"
+	"quotesparser/source"
+	"quotesparser/store"
+)
+
+// fakeSite serves testdata/site/<host>/<page>.html for every request the
+// scrapers make, wherever they point. The page name is the URL path with
+// slashes turned into underscores, plus _<n> for ?sayfa=n or ?page=n, e.g.
+// https://1000kitap.com/kitap/x--1/alintilar?sayfa=2 -> kitap_x--1_alintilar_2.html
+func fakeSite(t *testing.T) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		name := strings.ReplaceAll(strings.Trim(path, "/"), "/", "_")
+		if page := r.URL.Query().Get("sayfa"); page != "" {
+			name += "_" + page
+		} else if page := r.URL.Query().Get("page"); page != "" {
+			name += "_" + page
+		}
+		http.ServeFile(w, r, filepath.Join("testdata", "site", host, name+".html"))
+	}))
+	t.Cleanup(srv.Close)
+
+	// fetch.NewClient leaves the transport unset, so every client the
+	// commands create goes through http.DefaultTransport
+	orig := http.DefaultTransport
+	http.DefaultTransport = rewriteTransport{target: srv.URL[len("http://"):], next: orig}
+	t.Cleanup(func() { http.DefaultTransport = orig })
+}
+
+// rewriteTransport sends requests for any host to the fake site, moving the
+// original host into the path
+type rewriteTransport struct {
+	target string
+	next   http.RoundTripper
+}
+
+func (rt rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Path = "/" + req.URL.Host + req.URL.Path
+	req.URL.Scheme = "http"
+	req.URL.Host = rt.target
+	req.Host = rt.target
+	return rt.next.RoundTrip(req)
+}
+
+func TestDownloadParseImport(t *testing.T) {
+	fakeSite(t)
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "database.db")
+	pagesDir := filepath.Join(dir, "quoteFiles")
+	indexDir := filepath.Join(dir, "fraseslibros")
+	bookJSON := filepath.Join(dir, "book.json")
+	authorJSON := filepath.Join(dir, "author.json")
+
+	run := func(fn func([]string) error, args ...string) {
+		t.Helper()
+		if err := fn(args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	// 1000kitap: download -> parse -> import
+	run(runDownload, "1000kitap", "--book", "normal-insanlar--182700", "--author", "sally-rooney",
+		"--pages", "3", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", pagesDir)
+	run(runParse, "1000kitap", "--out", bookJSON, filepath.Join(pagesDir, "normal-insanlar--182700"))
+	run(runParse, "1000kitap", "--author-pages", "--out", authorJSON, filepath.Join(pagesDir, "sally-rooney"))
+	run(runImport, "1000kitap", "--db", dbPath, bookJSON, authorJSON)
+
+	// fraseslibros: download index -> crawl authors -> insert
+	run(runDownload, "fraseslibros", "--letters", "a", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", indexDir)
+	run(runCrawl, "fraseslibros", "--index", indexDir, "--db", dbPath, "--delay", "0", "--host-delay", "0", "--min-free", "0")
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// Quotes served between the two passes
+	if _, err := db.Exec("UPDATE quotes SET viewCount = 3; UPDATE frasesquotes SET viewCount = 5"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second pass must not duplicate anything, nor reset the views
+	run(runImport, "1000kitap", "--db", dbPath, bookJSON, authorJSON)
+	run(runCrawl, "fraseslibros", "--index", indexDir, "--db", dbPath, "--delay", "0", "--host-delay", "0", "--min-free", "0")
+
+	query := func(q string) []string {
+		t.Helper()
+		rows, err := db.Query(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		defer rows.Close()
+		var got []string
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, s)
+		}
+		sort.Strings(got)
+		return got
+	}
+	expect := func(name string, got, want []string) {
+		t.Helper()
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s:\ngot:\n  %s\nwant:\n  %s", name, strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+		}
+	}
+
+	expect("1000kitap quotes", query("SELECT text || ' | ' || author FROM quotes WHERE lang = 'tr'"), []string{
+		"Bir şeyi sevmek onu anlamaktan daha kolaydır. | Sally Rooney - Arkadaşlarla Sohbetler",
+		"Hayatında ilk kez kendini normal hissetti. | Sally Rooney - Normal İnsanlar",
+		"İnsanlar birbirini gerçekten değiştirebilir. | Sally Rooney - Normal İnsanlar",
+		"“Seni seviyorum,” dedi, ve bu doğruydu. | Sally Rooney - Normal İnsanlar",
+	})
+	expect("1000kitap raw texts", query("SELECT rawText FROM quotes WHERE rawText IS NOT NULL"), []string{
+		"Hayatında ilk kez kendini\n    normal hissetti.",
+	})
+	expect("fraseslibros authors", query("SELECT authorName || ' | ' || authorLink || ' | ' || quoteCount FROM frasesauthors"), []string{
+		"Abel Cutillas | https://fraseslibros.com/abel-cutillas | 0",
+		"Albert Camus | https://fraseslibros.com/albert-camus | 1",
+		"Aldous Huxley | https://fraseslibros.com/aldous-huxley | 2",
+		"Amos Oz | https://fraseslibros.com/amos-oz | 1",
+	})
+	expect("fraseslibros quotes", query(`
+		SELECT a.authorName || ' | ' || q.text || ' | ' || COALESCE(q.bookName, '')
+		FROM frasesquotes q JOIN frasesauthors a ON a.id = q.authorId`), []string{
+		"Albert Camus | En medio del invierno aprendí por fin que había en mí un verano invencible. | El verano",
+		"Aldous Huxley | La experiencia no es lo que te sucede, sino lo que haces con lo que te sucede. | ",
+		"Aldous Huxley | La felicidad nunca es grandiosa. | Un mundo feliz",
+		"Amos Oz | Niño, la vida es una canción que se canta despacio. | Una historia de amor y oscuridad",
+	})
+	expect("view counts", query(`
+		SELECT 'quotes ' || viewCount FROM quotes GROUP BY viewCount
+		UNION SELECT 'frasesquotes ' || viewCount FROM frasesquotes GROUP BY viewCount`), []string{
+		"frasesquotes 5",
+		"quotes 3",
+	})
+
+	// Pages are kept on disk for reparsing
+	for _, p := range []string{
+		filepath.Join(pagesDir, "normal-insanlar--182700", "file2.txt"),
+		filepath.Join(pagesDir, "sally-rooney", "file1.txt"),
+		filepath.Join(indexDir, "a2.text"),
+		filepath.Join(indexDir, "quotes", "aldous-huxley_2.text"),
+	} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("missing downloaded page: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(pagesDir, "normal-insanlar--182700", "file3.txt")); err == nil {
+		t.Errorf("page 3 does not exist on the site but was saved")
+	}
+}
+
+// TestFrasesAdapter runs fraseslibros through the generic parse and import
+// commands, from quote pages its adapter downloaded
+func TestFrasesAdapter(t *testing.T) {
+	fakeSite(t)
+
+	dir := t.TempDir()
+	pagesDir := filepath.Join(dir, "fraseslibros")
+	jsonFile := filepath.Join(dir, "quotes.json")
+	dbPath := filepath.Join(dir, "database.db")
+	run := func(fn func([]string) error, args ...string) {
+		t.Helper()
+		if err := fn(args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	run(runDownload, "fraseslibros", "--quotes", "--letters", "a", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", pagesDir)
+	run(runParse, "fraseslibros", "--out", jsonFile, filepath.Join(pagesDir, "a"))
+	run(runImport, "fraseslibros", "--db", dbPath, jsonFile)
+
+	if url := pageURL(filepath.Join(pagesDir, "a", "page4.html")); url != "https://fraseslibros.com/aldous-huxley/2" {
+		t.Errorf("page 4 was downloaded from %q", url)
+	}
+
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	quotes, err := s.Quotes(store.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, q := range quotes {
+		got = append(got, q.Author+" | "+q.Text+" | "+q.Book)
+	}
+	sort.Strings(got)
+	want := []string{
+		"Albert Camus | En medio del invierno aprendí por fin que había en mí un verano invencible. | El verano",
+		"Aldous Huxley | La experiencia no es lo que te sucede, sino lo que haces con lo que te sucede. | ",
+		"Aldous Huxley | La felicidad nunca es grandiosa. | Un mundo feliz",
+		"Amos Oz | Niño, la vida es una canción que se canta despacio. | Una historia de amor y oscuridad",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported quotes:\n got %q\nwant %q", got, want)
+	}
+}
+
+// exampleSource is a registered source over the fake site's quotes.example
+// pages, standing in for a scaffolded site
+type exampleSource struct{}
+
+func init() {
+	source.Register(exampleSource{})
+}
+
+func (exampleSource) Name() string { return "quotes-example" }
+
+func (exampleSource) PageURL(target string, page int) (string, error) {
+	u := "https://quotes.example/" + strings.Trim(target, "/")
+	if page > 1 {
+		u += fmt.Sprintf("?page=%d", page)
+	}
+	return u, nil
+}
+
+func (exampleSource) Parse(page []byte) ([]source.Quote, error) {
+	return source.Selectors{Quote: "div.quote", Text: ".text", Author: "small.author", Lang: "en"}.Extract(page)
+}
+
+func TestRegisteredSource(t *testing.T) {
+	fakeSite(t)
+
+	dir := t.TempDir()
+	pagesDir := filepath.Join(dir, "pages")
+	jsonFile := filepath.Join(dir, "quotes.json")
+	dbPath := filepath.Join(dir, "database.db")
+	run := func(fn func([]string) error, args ...string) {
+		t.Helper()
+		if err := fn(args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	run(runDownload, "quotes-example", "--pages", "5", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", pagesDir, "tag/love")
+	run(runParse, "quotes-example", "--out", jsonFile, filepath.Join(pagesDir, "tag_love"))
+	run(runImport, "quotes-example", "--db", dbPath, jsonFile)
+
+	if _, err := os.Stat(filepath.Join(pagesDir, "tag_love", "page2.html")); err != nil {
+		t.Errorf("missing page 2: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(pagesDir, "tag_love", "page3.html")); err == nil {
+		t.Errorf("page 3 does not exist on the site but was saved")
+	}
+
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got, err := s.Quotes(store.Filter{Lang: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each quote tells the page it was read from
+	for i, page := range []string{"", "", "?page=2"} {
+		p := got[i].Provenance
+		file := filepath.Join(pagesDir, "tag_love", "page1.html")
+		if page != "" {
+			file = filepath.Join(pagesDir, "tag_love", "page2.html")
+		}
+		if p.URL != "https://quotes.example/tag/love"+page || p.File != file || !strings.HasPrefix(p.Parser, "quotes-example@") {
+			t.Errorf("quote %d provenance = %+v", got[i].ID, p)
+		}
+		if _, err := time.Parse(time.RFC3339, p.FetchedAt); err != nil {
+			t.Errorf("quote %d fetched at %q: %v", got[i].ID, p.FetchedAt, err)
+		}
+		got[i].Provenance = store.Provenance{}
+	}
+	want := []store.Quote{
+		{ID: 1, Text: "“The only way out is through.”", Author: "Robert Frost", Lang: "en", Origin: origin.Unknown, Source: "quotes-example", Confidence: 1},
+		{ID: 2, Text: "Whatever our souls are made of, his and mine are the same.", Author: "Emily Brontë", Lang: "en", Origin: origin.Unknown, Source: "quotes-example", Confidence: 1},
+		{ID: 3, Text: "Love is composed of a single soul inhabiting two bodies.", Author: "Aristotle", Lang: "en", Origin: origin.Unknown, Source: "quotes-example", Confidence: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported quotes:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+// TestImportLang checks that imported quotes get their detected language,
+// the source's when detection cannot tell, and the --lang override
+func TestImportLang(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "quotes.json")
+	content := `[
+		{"text": "El amor es una locura que ni el cura lo cura.", "lang": "en"},
+		{"text": "Bu kapkaranlık dünyada üzerime düşen ışıksın sen.", "lang": "en"},
+		{"text": "Zeze!", "lang": "en"}
+	]`
+	if err := os.WriteFile(jsonFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	langs := func(args ...string) map[string]string {
+		t.Helper()
+		dbPath := filepath.Join(t.TempDir(), "database.db")
+		if err := runImport(append([]string{"quotes-example", "--db", dbPath}, append(args, jsonFile)...)); err != nil {
+			t.Fatal(err)
+		}
+		s, err := store.Open(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		quotes, err := s.Quotes(store.Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, q := range quotes {
+			got[q.Text] = q.Lang
+		}
+		return got
+	}
+
+	want := map[string]string{
+		"El amor es una locura que ni el cura lo cura.":     "es",
+		"Bu kapkaranlık dünyada üzerime düşen ışıksın sen.": "tr",
+		"Zeze!": "en",
+	}
+	if got := langs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("detected languages = %v, want %v", got, want)
+	}
+	for text := range want {
+		want[text] = "pt"
+	}
+	if got := langs("--lang", "pt"); !reflect.DeepEqual(got, want) {
+		t.Errorf("--lang pt gave %v", got)
+	}
+}
+
+// TestImportStrict checks that a misspelled field is a warning, or with
+// --strict an error naming where it is, and a wrong type always an error
+func TestImportStrict(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "quotes.json")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(jsonFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) error {
+		return runImport(append([]string{"quotes-example", "--db", filepath.Join(dir, "database.db"), "--lang", "en"}, append(args, jsonFile)...))
+	}
+
+	write(`[
+		{"text": "Be here now.", "auther": "Ram Dass"}
+	]`)
+	if err := run(); err != nil {
+		t.Errorf("an unknown field failed the import: %v", err)
+	}
+	err := run("--strict")
+	if want := jsonFile + `:2:28: $[0]: unknown field "auther" (did you mean "author"?)`; err == nil || err.Error() != want {
+		t.Errorf("--strict gave %v, want %s", err, want)
+	}
+
+	write(`[{"text": "Be here now.", "likes": "many"}, {"quote": "Less is more."}]`)
+	err = run()
+	if err == nil || !strings.Contains(err.Error(), `$[0].likes: expected integer, got string "many"`) || !strings.Contains(err.Error(), `$[1]: missing required field "text"`) {
+		t.Errorf("wrong types gave %v", err)
+	}
+}
+
+// TestImportYAML checks that quotes kept by hand in YAML and TOML import
+// like those of JSON, multi-line ones included
+func TestImportYAML(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"quotes.yaml": "- text: |\n    Kişi, sevdiği şeye benzer.\n    Ya da benzemek istediğine.\n  author: Ahmet Hamdi Tanpınar\n  lang: tr\n",
+		"quotes.toml": "[[quotes]]\ntext = \"\"\"\nKişi, sevdiği şeye benzer.\nYa da benzemek istediğine.\"\"\"\nlang = \"tr\"\n\n[[quotes]]\ntext = \"Az olsun, öz olsun.\"\nlang = \"tr\"\n",
+	}
+	args := []string{"quotes-example", "--db", filepath.Join(dir, "database.db")}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, path)
+	}
+	if err := runImport(args); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := store.Open(filepath.Join(dir, "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	quotes, err := s.Quotes(store.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, q := range quotes {
+		got = append(got, q.Text+" | "+q.Author)
+	}
+	sort.Strings(got)
+	want := []string{"Az olsun, öz olsun. | ", "Kişi, sevdiği şeye benzer.\nYa da benzemek istediğine. | Ahmet Hamdi Tanpınar"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported %q, want %q", got, want)
+	}
+}
+
+// TestImportDryRun checks that --dry-run counts what an import would do
+// without changing the database, migrated or not
+func TestImportDryRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	first := write("first.json", `[
+		{"text": "Be here now.", "author": "Ram Dass", "lang": "en"},
+		{"text": "Stay hungry, stay foolish.", "author": "Steve Jobs", "lang": "en", "likes": 3}
+	]`)
+	second := write("second.json", `[
+		{"text": "Be here now.", "author": "Ram Dass", "lang": "en"},
+		{"text": "Stay hungry, stay foolish.", "author": "Steve Jobs", "lang": "en", "likes": 9},
+		{"text": "Less is more.", "author": "Mies van der Rohe", "lang": "en"},
+		{"text": "Less is more.", "author": "Mies van der Rohe", "lang": "en"}
+	]`)
+
+	var out strings.Builder
+	progress = &out
+	defer func() { progress = os.Stdout }()
+	dryRun := func(dbPath string) {
+		t.Helper()
+		before, err := os.ReadFile(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Reset()
+		if err := runImport([]string{"quotes-example", "--db", dbPath, "--lang", "en", "--dry-run", second}); err != nil {
+			t.Fatal(err)
+		}
+		if after, _ := os.ReadFile(dbPath); string(after) != string(before) {
+			t.Errorf("dry run changed %s", dbPath)
+		}
+	}
+
+	dbPath := filepath.Join(dir, "database.db")
+	if err := runImport([]string{"quotes-example", "--db", dbPath, "--lang", "en", first}); err != nil {
+		t.Fatal(err)
+	}
+	dryRun(dbPath)
+	if want := "Quotes: 1 to insert, 1 to update, 2 to skip"; !strings.Contains(out.String(), want) {
+		t.Errorf("dry run reported %q, want %q", out.String(), want)
+	}
+
+	// A database from before the migrations is migrated in a copy, where
+	// the quote gets its source
+	legacy := filepath.Join(dir, "legacy.db")
+	db, err := sql.Open("sqlite3", legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE quotes (id INTEGER PRIMARY KEY AUTOINCREMENT, text TEXT NOT NULL, author TEXT, lang TEXT, viewCount INTEGER DEFAULT 0);
+		INSERT INTO quotes (text, author, lang) VALUES ('Be here now.', 'Ram Dass', 'en')`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dryRun(legacy)
+	if want := "Quotes: 2 to insert, 1 to update, 1 to skip"; !strings.Contains(out.String(), want) {
+		t.Errorf("dry run on an old database reported %q, want %q", out.String(), want)
+	}
+}
+
+// TestDownloadUnderChaos breaks half the requests and checks that retries and
+// validation still end with exactly the pages a clean run saves
+func TestDownloadUnderChaos(t *testing.T) {
+	fakeSite(t)
+
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		if err := runDownload(args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	kitapArgs := func(out string) []string {
+		return []string{"1000kitap", "--book", "normal-insanlar--182700", "--author", "sally-rooney",
+			"--pages", "3", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", out}
+	}
+	frasesArgs := func(out string) []string {
+		return []string{"fraseslibros", "--letters", "a", "--delay", "0", "--host-delay", "0", "--min-free", "0",
+			"--out", filepath.Join(out, "fraseslibros")}
+	}
+	chaos := []string{"--chaos", "0.5", "--chaos-seed", "3", "--chaos-max-delay", "10ms", "--retries", "20", "--backoff", "1ms"}
+
+	clean := filepath.Join(dir, "clean")
+	broken := filepath.Join(dir, "chaos")
+	run(kitapArgs(clean)...)
+	run(frasesArgs(clean)...)
+	run(append(kitapArgs(broken), chaos...)...)
+	run(append(frasesArgs(broken), chaos...)...)
+
+	want := readTree(t, clean)
+	got := readTree(t, broken)
+	if len(want) == 0 {
+		t.Fatal("clean run saved nothing")
+	}
+	for name, body := range want {
+		if got[name] != body {
+			t.Errorf("%s differs from the clean download (%d vs %d bytes)", name, len(got[name]), len(body))
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("%s saved only under chaos", name)
+		}
+	}
+
+	// Resuming skips saved pages, so even a fully broken network changes nothing
+	run(append(kitapArgs(broken), "--resume", "--chaos", "1", "--retries", "0")...)
+	for name, body := range readTree(t, broken) {
+		if want[name] != body {
+			t.Errorf("%s changed on resume", name)
+		}
+	}
+}
+
+// TestDownloadReplay records a download into cassettes, then replays it with
+// the network cut off
+func TestDownloadReplay(t *testing.T) {
+	fakeSite(t)
+
+	dir := t.TempDir()
+	cassettes := filepath.Join(dir, "cassettes")
+	run := func(args ...string) {
+		t.Helper()
+		if err := runDownload(args); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	download := func(out, mode string) {
+		t.Helper()
+		run("1000kitap", "--book", "normal-insanlar--182700", "--pages", "3", "--delay", "0", "--host-delay", "0", "--min-free", "0",
+			"--out", out, "--cassettes", cassettes, "--vcr", mode)
+		run("fraseslibros", "--letters", "a", "--delay", "0", "--host-delay", "0", "--min-free", "0",
+			"--out", filepath.Join(out, "fraseslibros"), "--cassettes", cassettes, "--vcr", mode)
+	}
+
+	live := filepath.Join(dir, "live")
+	download(live, "record")
+	for _, name := range []string{"1000kitap.com.jsonl", "fraseslibros.com.jsonl"} {
+		if _, err := os.Stat(filepath.Join(cassettes, name)); err != nil {
+			t.Errorf("missing cassette: %v", err)
+		}
+	}
+
+	orig := http.DefaultTransport
+	http.DefaultTransport = offline{}
+	defer func() { http.DefaultTransport = orig }()
+
+	replayed := filepath.Join(dir, "replayed")
+	download(replayed, "replay")
+	want := readTree(t, live)
+	got := readTree(t, replayed)
+	if len(got) != len(want) {
+		t.Errorf("replay saved %d pages, the live run %d", len(got), len(want))
+	}
+	for name, body := range want {
+		if got[name] != body {
+			t.Errorf("%s differs between the live and replayed runs", name)
+		}
+	}
+
+	// Pages never recorded fail instead of reaching the network
+	client := fetch.NewClient()
+	client.HTTP.Transport = fetch.NewCassette(cassettes, fetch.Replay)
+	if _, err := client.Get("https://1000kitap.com/kitap/unrecorded--1/alintilar?sayfa=1"); !errors.Is(err, fetch.ErrNotRecorded) {
+		t.Errorf("unrecorded page: got %v, want ErrNotRecorded", err)
+	}
+}
+
+// offline is a transport for tests that must not touch the network
+type offline struct{}
+
+func (offline) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("offline: request to %s", req.URL)
+}
+
+// readTree returns the contents of every file under dir by relative path
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = string(body)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// TestParseQuality checks that quotes failing the --quality filter of their
+// source are left out of the output and listed in the --report
+func TestParseQuality(t *testing.T) {
+	dir := t.TempDir()
+	page, err := os.ReadFile("testdata/site/1000kitap.com/kitap_normal-insanlar--182700_alintilar_1.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pagePath := filepath.Join(dir, "file1.txt")
+	qualityPath := filepath.Join(dir, "quality.json")
+	jsonFile := filepath.Join(dir, "quotes.json")
+	reportPath := filepath.Join(dir, "report.json")
+	os.WriteFile(pagePath, page, 0644)
+	os.WriteFile(qualityPath, []byte(`{"default": {"minLength": 100}, "1000kitap": {"minLength": 44, "endPunctuation": true}}`), 0644)
+
+	if err := runParse([]string{"1000kitap", "--quality", qualityPath, "--report", reportPath, "--out", jsonFile, pagePath}); err != nil {
+		t.Fatal(err)
+	}
+
+	var quotes []kitap.Quote
+	if b, err := os.ReadFile(jsonFile); err != nil || json.Unmarshal(b, &quotes) != nil {
+		t.Fatalf("reading %s: %v", jsonFile, err)
+	}
+	if len(quotes) != 1 || quotes[0].QuoteText != "İnsanlar birbirini gerçekten değiştirebilir." {
+		t.Errorf("parsed %+v, want the quote long enough only", quotes)
+	}
+	var report struct{ Rejects []rejects.Reject }
+	if b, err := os.ReadFile(reportPath); err != nil || json.Unmarshal(b, &report) != nil {
+		t.Fatalf("reading %s: %v", reportPath, err)
+	}
+	want := []rejects.Reject{{Kind: rejects.Quote, File: pagePath, Text: "Hayatında ilk kez kendini normal hissetti.", Reason: "quality 0.50: shorter than 44 characters"}}
+	if !reflect.DeepEqual(report.Rejects, want) {
+		t.Errorf("rejects = %+v, want %+v", report.Rejects, want)
+	}
+}
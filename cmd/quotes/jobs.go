@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"quotesparser/jobs"
+)
+
+func runJobs(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes jobs submit|list|cancel [flags]")
+	}
+	switch args[0] {
+	case "submit":
+		return runJobsSubmit(args[1:])
+	case "list":
+		return runJobsList(args[1:])
+	case "cancel":
+		return runJobsCancel(args[1:])
+	default:
+		return fmt.Errorf("unknown jobs action %q (submit, list, cancel)", args[0])
+	}
+}
+
+// runJobsSubmit queues a command for quotes serve to run in the background.
+// Jobs run in the folder the server was started in, so paths are best given
+// in full.
+func runJobsSubmit(args []string) error {
+	fs := flag.NewFlagSet("jobs submit", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose quotes serve runs the job")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: quotes jobs submit [flags] <command> [its flags and arguments]\n\nCommands: %s\n", strings.Join(jobs.Commands, ", "))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("jobs submit needs a command to run")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	by := os.Getenv("USER")
+	j, err := jobs.Submit(db, fs.Arg(0), fs.Args()[1:], by)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d\n", j.ID)
+	fmt.Fprintf(progress, "✓ Queued job %d: %s\n", j.ID, j.Line())
+	return nil
+}
+
+// runJobsList prints the latest jobs with how far they got
+func runJobsList(args []string) error {
+	fs := flag.NewFlagSet("jobs list", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose jobs to list")
+	status := fs.String("status", "", "only list the jobs queued, running, done, failed or cancelled")
+	limit := fs.Int("limit", 20, "how many of the latest jobs to list")
+	fs.Parse(args)
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	list, err := jobs.List(db, jobs.Status(*status), *limit)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(progress, "No jobs")
+		return nil
+	}
+	for _, j := range list {
+		status := string(j.Status)
+		if j.Cancelling {
+			status = "cancelling"
+		}
+		fmt.Printf("%6d  %-10s %4.0f%%  %s\n", j.ID, status, 100*j.Progress, j.Line())
+		if j.Message != "" {
+			fmt.Printf("%6s  %s\n", "", j.Message)
+		}
+	}
+	return nil
+}
+
+// runJobsCancel takes queued jobs out of the queue and stops running ones
+func runJobsCancel(args []string) error {
+	fs := flag.NewFlagSet("jobs cancel", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose jobs to cancel")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: quotes jobs cancel [flags] <job ids>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("jobs cancel needs the id of a job")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	for _, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad job id %q", arg)
+		}
+		j, err := jobs.Cancel(db, id)
+		if err != nil {
+			return err
+		}
+		if j.Status == jobs.Cancelled {
+			fmt.Fprintf(progress, "✓ Cancelled job %d\n", id)
+		} else {
+			fmt.Fprintf(progress, "✓ Asked job %d to stop\n", id)
+		}
+	}
+	return nil
+}
+
+// jobCommand runs a job as this binary does the command, logging as JSON
+// for the runner to follow its progress
+func jobCommand(ctx context.Context, j jobs.Job) *exec.Cmd {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	return exec.CommandContext(ctx, exe, append([]string{"--log-format", "json", j.Command}, j.Args...)...)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"quotesparser/jobs"
+)
+
+func TestJobs(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	if err := runJobs([]string{"submit", "--db", dbPath, "crawl", "--limit", "10"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runJobs([]string{"submit", "--db", dbPath, "serve"}); !errors.Is(err, jobs.ErrCommand) {
+		t.Errorf("submitting serve: %v, want ErrCommand", err)
+	}
+	if err := runJobs([]string{"list", "--db", dbPath}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	j, err := jobs.Get(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != jobs.Queued || j.Line() != "quotes crawl --limit 10" {
+		t.Errorf("job = %s %q, want a queued quotes crawl --limit 10", j.Status, j.Line())
+	}
+
+	if err := runJobs([]string{"cancel", "--db", dbPath, "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := jobs.Get(db, 1); j.Status != jobs.Cancelled || j.FinishedAt == "" {
+		t.Errorf("cancelled job = %s finished at %q", j.Status, j.FinishedAt)
+	}
+	if err := runJobs([]string{"cancel", "--db", dbPath, "1"}); !errors.Is(err, jobs.ErrFinished) {
+		t.Errorf("cancelling again: %v, want ErrFinished", err)
+	}
+	if err := runJobs([]string{"cancel", "--db", dbPath, "7"}); !errors.Is(err, jobs.ErrNotFound) {
+		t.Errorf("cancelling a missing job: %v, want ErrNotFound", err)
+	}
+}
+
+func TestJobRunner(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each command stands for a script the fake quotes runs
+	scripts := map[string]string{
+		"parse": `echo '{"level":"INFO","msg":"parsing","done":3,"total":4,"unit":"pages"}' >&2; echo "Parsed 3 pages"`,
+		"dedup": `echo '{"level":"ERROR","msg":"command failed","err":"no quotes"}' >&2; exit 1`,
+		"crawl": `echo '{"level":"INFO","msg":"crawling","done":1,"total":4,"unit":"pages"}' >&2; sleep 30`,
+		// Lines longer than the runner reads, then more than a pipe holds
+		"export": `for fd in 1 2; do head -c 2000000 /dev/zero | tr '\0' x >&$fd; echo >&$fd; head -c 200000 /dev/zero | tr '\0' '\n' >&$fd; done; echo "Exported"`,
+	}
+	for _, command := range []string{"parse", "dedup", "crawl"} {
+		if _, err := jobs.Submit(db, command, nil, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runner := &jobs.Runner{
+		DB:      db,
+		Workers: 3,
+		Grace:   time.Second,
+		Command: func(ctx context.Context, j jobs.Job) *exec.Cmd {
+			return exec.CommandContext(ctx, "sh", "-c", scripts[j.Command])
+		},
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	ran := make(chan struct{})
+	go func() {
+		runner.Run(ctx, 20*time.Millisecond)
+		close(ran)
+	}()
+
+	wait := func(id int64, want jobs.Status) jobs.Job {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			j, err := jobs.Get(db, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if j.Status == want || time.Now().After(deadline) {
+				return j
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	if j := wait(1, jobs.Done); j.Status != jobs.Done || j.Progress != 1 || j.Message != "Parsed 3 pages" {
+		t.Errorf("parse job = %s %.2f %q, want done with its last line", j.Status, j.Progress, j.Message)
+	}
+	if j := wait(2, jobs.Failed); j.Status != jobs.Failed || j.Message != "no quotes" {
+		t.Errorf("dedup job = %s %q, want failed with its error", j.Status, j.Message)
+	}
+	if j := wait(3, jobs.Running); j.Status != jobs.Running {
+		t.Fatalf("crawl job = %s, want running", j.Status)
+	}
+	if _, err := jobs.Cancel(db, 3); err != nil {
+		t.Fatal(err)
+	}
+	if j := wait(3, jobs.Cancelled); j.Status != jobs.Cancelled || j.Progress != 0.25 {
+		t.Errorf("cancelled crawl job = %s %.2f, want cancelled a quarter through", j.Status, j.Progress)
+	}
+
+	// A job writing a line too long to read is not left blocked on its pipes
+	if _, err := jobs.Submit(db, "export", nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if j := wait(4, jobs.Done); j.Status != jobs.Done {
+		t.Errorf("export job = %s %q, want done", j.Status, j.Message)
+	}
+
+	// A job running when the runner stops waits for the next one
+	if _, err := jobs.Submit(db, "crawl", nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	wait(5, jobs.Running)
+	stop()
+	<-ran
+	if j, _ := jobs.Get(db, 5); j.Status != jobs.Queued {
+		t.Errorf("job running as the runner stopped = %s, want queued again", j.Status)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// progress is where commands say what they are doing and sum up what they
+// did, for whoever watches them; --quiet discards it. Warnings and errors
+// go to the log, on stderr, and what a command is run for, such as the
+// quote qotd prints, to stdout.
+var progress io.Writer = os.Stdout
+
+// logJSON is set by --log-format json, which logs the error a command
+// fails with too
+var logJSON bool
+
+// setupLogging reads the flags given before the command name, sets up the
+// log they ask for and returns the command line left
+func setupLogging(args []string) ([]string, error) {
+	fs := flag.NewFlagSet("quotes", flag.ExitOnError)
+	fs.Usage = usage
+	verbose := fs.Bool("verbose", false, "also log debugging detail")
+	quiet := fs.Bool("quiet", false, "print no progress, only warnings and errors, as for cron")
+	format := fs.String("log-format", "text", "log as text or json lines")
+	fs.Parse(args)
+	if *verbose && *quiet {
+		return nil, errors.New("--verbose and --quiet exclude each other")
+	}
+
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	switch {
+	case *verbose:
+		opts.Level = slog.LevelDebug
+	case *quiet:
+		opts.Level = slog.LevelWarn
+		progress = io.Discard
+	}
+	switch *format {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+	case "json":
+		logJSON = true
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	default:
+		return nil, fmt.Errorf("--log-format must be text or json, not %q", *format)
+	}
+	return fs.Args(), nil
+}
@@ -0,0 +1,110 @@
+// Command quotes collects quotes, fun facts and trivia into database.db.
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"quotesparser/source"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"download", "download raw pages from a quote source", runDownload},
+	{"parse", "parse downloaded pages into a JSON file", runParse},
+	{"archive", "keep downloaded pages gzipped in the database or a .tar.gz file, for parse --archive to read again", runArchive},
+	{"import", "insert parsed JSON into the database", runImport},
+	{"crawl", "download, parse and insert quotes in one bounded pass", runCrawl},
+	{"watch", "insert fun facts and quote pages dropped into a folder as they appear", runWatch},
+	{"edit-export", "write quotes to a YAML, TOML or JSON file for editing by hand", runEditExport},
+	{"edit-import", "save the fields edited in a file written by edit-export", runEditImport},
+	{"normalize", "convert flat tables into authors, books, sources and tags", runNormalize},
+	{"bench", "measure insert throughput on this machine", runBench},
+	{"migrate", "apply or roll back database schema migrations", runMigrate},
+	{"dedup", "merge quotes, trivia and fun facts with the same normalized text, or near-duplicates with --fuzzy", runDedup},
+	{"authors", "merge authors written differently across sources, list their aliases and enrich them from Wikidata", runAuthors},
+	{"opentdb", "import trivia questions from the Open Trivia Database API", runOpenTDB},
+	{"trivia", "report trivia categories with their aliases and file questions under the canonical ones", runTrivia},
+	{"trivia-dups", "find trivia questions asked twice in different words and keep one of each", runTriviaDups},
+	{"relink", "audit author links against a site's new URL structure and rewrite them in bulk", runRelink},
+	{"new-source", "scaffold a package for a new quote site", runNewSource},
+	{"portraits", "cache Wikimedia portraits of authors with their licenses", runPortraits},
+	{"covers", "cache OpenLibrary covers of books", runCovers},
+	{"books", "look books up on OpenLibrary for their ISBN, year, original title and cover, to cite them", runBooks},
+	{"assets", "cache the book covers and author pictures found by books and authors enrich, once per image", runAssets},
+	{"trivia-media", "cache the images and audio clips of picture and audio trivia rounds", runTriviaMedia},
+	{"search", "find quotes by the words of their text or author, accents aside with --fold", runSearch},
+	{"qotd", "print the quote of the day", runQotd},
+	{"schedule", "pin quotes to coming days, such as an author's birthday, for the quote of the day", runSchedule},
+	{"motd", "print a random quote wrapped for a login banner", runMotd},
+	{"quiz", "export trivia as a GIFT or Moodle XML question bank, or a Kahoot or Quizizz spreadsheet", runQuiz},
+	{"crossword", "export single-word trivia answers and their clues for crossword construction tools", runCrossword},
+	{"export", "write quotes as NDJSON or CSV, split into parts under a size with a manifest for BigQuery or Sheets", runExport},
+	{"fortune", "export quotes, trivia or fun facts as a fortune(6) file with its index", runFortune},
+	{"render", "draw a quote as a PNG or SVG card for a picture frame or e-ink display", runRender},
+	{"serve", "serve quotes, authors, trivia and fun facts as a JSON API", runServe},
+	{"recrawl", "rank the pages of reported, popular and doubtful quotes and crawl the most wanted again", runRecrawl},
+	{"jobs", "queue long commands for quotes serve to run in the background, and list or cancel them", runJobs},
+	{"push", "send the quote of the day to registered phones through FCM and APNs", runPush},
+	{"stats", "summarize API usage per route, key, language and source", runStats},
+}
+
+// sourceList names the sources a command accepts: its own plus every
+// registered one
+func sourceList(builtin ...string) string {
+	names := builtin
+	for _, name := range source.Names() {
+		if !slices.Contains(builtin, name) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: quotes [--verbose | --quiet] [--log-format text|json] <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.summary)
+	}
+}
+
+func main() {
+	args, err := setupLogging(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "quotes: %v\n", err)
+		os.Exit(2)
+	}
+	if len(args) < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := args[0]
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(args[1:]); err != nil {
+				if logJSON {
+					slog.Error("command failed", "command", name, "err", err)
+				} else {
+					fmt.Fprintf(os.Stderr, "quotes %s: %v\n", name, err)
+				}
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	if name != "help" {
+		fmt.Fprintf(os.Stderr, "quotes: unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+
+	"quotesparser/migrations"
+)
+
+func runMigrate(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes migrate up|down|status [flags]")
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database to migrate")
+	to := fs.Int("to", 0, "up: stop at this version (default: latest)")
+	steps := fs.Int("steps", 1, "down: number of migrations to roll back")
+	fs.Parse(args[1:])
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(db, *to)
+		for _, m := range applied {
+			fmt.Fprintf(progress, "✓ Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(progress, "Database is up to date")
+		}
+		return nil
+
+	case "down":
+		reverted, err := migrations.Down(db, *steps)
+		for _, m := range reverted {
+			fmt.Fprintf(progress, "✓ Rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		return printMigrationStatus(db)
+
+	default:
+		return fmt.Errorf("unknown migrate action %q (up, down, status)", args[0])
+	}
+}
+
+func printMigrationStatus(db *sql.DB) error {
+	current, err := migrations.Current(db)
+	if err != nil {
+		return err
+	}
+	all, err := migrations.All()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema version: %d (latest %d)\n\n", current, migrations.Latest())
+	for _, m := range all {
+		state := "pending"
+		if m.Version <= current {
+			state = "applied"
+		}
+		fmt.Printf("  %04d_%-24s %s\n", m.Version, m.Name, state)
+	}
+	return nil
+}
+
+// migrateDB brings the database to the latest schema before a command uses it
+func migrateDB(db *sql.DB) error {
+	applied, err := migrations.Up(db, 0)
+	for _, m := range applied {
+		fmt.Fprintf(progress, "✓ Applied migration %04d_%s\n", m.Version, m.Name)
+	}
+	return err
+}
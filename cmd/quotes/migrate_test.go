@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"quotesparser/migrations"
+)
+
+// A database migrated by a newer build is neither migrated nor rolled back
+func TestMigrateNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO schemaMigrations (version, name, appliedAt) VALUES (?, 'from_the_future', '2030-01-01T00:00:00Z')",
+		migrations.Latest()+1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateDB(db); !errors.Is(err, migrations.ErrSchemaMismatch) {
+		t.Errorf("migrating = %v, want ErrSchemaMismatch", err)
+	}
+	if err := runMigrate([]string{"down", "--db", dbPath}); !errors.Is(err, migrations.ErrSchemaMismatch) {
+		t.Errorf("rolling back = %v, want ErrSchemaMismatch", err)
+	}
+	var version int
+	if err := db.QueryRow("SELECT MAX(version) FROM schemaMigrations").Scan(&version); err != nil || version != migrations.Latest()+1 {
+		t.Errorf("version = %d (%v), want it left alone", version, err)
+	}
+}
+
+// Every migration after the baseline rolls back, and applies again after
+func TestMigrateRoundTrip(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	if err := runMigrate([]string{"up", "--db", dbPath}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runMigrate([]string{"down", "--db", dbPath, "--steps", strconv.Itoa(migrations.Latest() - 1)}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var applied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schemaMigrations").Scan(&applied); err != nil || applied != 1 {
+		t.Errorf("%d migrations left applied (%v), want the baseline", applied, err)
+	}
+	if err := runMigrate([]string{"up", "--db", dbPath}); err != nil {
+		t.Fatal(err)
+	}
+	var version int
+	if err := db.QueryRow("SELECT MAX(version) FROM schemaMigrations").Scan(&version); err != nil || version != migrations.Latest() {
+		t.Errorf("version = %d (%v), want %d", version, err, migrations.Latest())
+	}
+}
+
+// Duplicate authors from before the unique keys merge into the first row,
+// taking their quotes and the views of those quotes with them
+func TestMigrateDuplicateAuthors(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := migrations.Up(db, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO frasesauthors (id, authorName, authorLink, quoteCount) VALUES
+			(1, 'Amos Oz', 'https://fraseslibros.com/amos-oz', 1),
+			(2, 'Amos Oz', 'https://fraseslibros.com/amos-oz', 2);
+		INSERT INTO frasesquotes (authorId, text, viewCount) VALUES
+			(1, 'Niño, la vida es una canción que se canta despacio.', 3),
+			(2, 'Niño, la vida es una canción que se canta despacio.', 4),
+			(2, 'Escribo para contar historias.', 5)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT authorId, text, viewCount FROM frasesquotes ORDER BY text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var authorID, views int
+		var text string
+		if err := rows.Scan(&authorID, &text, &views); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, strconv.Itoa(authorID)+" | "+text+" | "+strconv.Itoa(views))
+	}
+	want := []string{
+		"1 | Escribo para contar historias. | 5",
+		"1 | Niño, la vida es una canción que se canta despacio. | 7",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("quotes:\n  %s\nwant:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
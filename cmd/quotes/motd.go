@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"quotesparser/api"
+	"quotesparser/locale"
+	"quotesparser/store"
+	"quotesparser/textshape"
+)
+
+// motdCacheSize is how many recent quotes motd keeps to fall back on
+const motdCacheSize = 20
+
+// runMotd prints a random quote wrapped for a login banner, e.g. from
+// /etc/update-motd.d/50-quote:
+//
+//	#!/bin/sh
+//	exec quotes motd --lang en --api http://quotes.lan:8080
+//
+// Quotes come from the database or, with --api, from quotes serve. Each one
+// shown is cached, and when neither answers one of the cached quotes is
+// shown instead, so a login never waits on or fails with the network.
+func runMotd(args []string) error {
+	fs := flag.NewFlagSet("motd", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	apiURL := fs.String("api", "", "read quotes from quotes serve at this URL instead of --db")
+	lang := fs.String("lang", "", "only quotes in this language, e.g. en")
+	author := fs.String("author", "", "only quotes by this author")
+	maxChars := fs.Int("max-chars", 0, "only quotes up to this many characters, to keep the banner short")
+	minConfidence := fs.Float64("min-confidence", 0, "only quotes the parser was at least this sure of, from 0 to 1")
+	width := fs.Int("max-width", 80, "wrap to this many columns")
+	cache := fs.String("cache", "", "file of recent quotes to fall back on (default in the user cache folder)")
+	timeout := fs.Duration("timeout", 2*time.Second, "give up on --api after this long")
+	fs.Parse(args)
+
+	if *width < 20 {
+		return errors.New("--max-width must be at least 20")
+	}
+	if *cache == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			dir = os.TempDir()
+		}
+		*cache = filepath.Join(dir, "quotes", "motd.json")
+	}
+
+	f := store.Filter{Lang: *lang, Author: *author, MaxChars: *maxChars, MinConfidence: *minConfidence}
+	var q store.Quote
+	var err error
+	if *apiURL != "" {
+		q, err = motdFromAPI(*apiURL, f, *timeout)
+	} else {
+		q, err = motdFromStore(*dsn, f)
+	}
+	if err == nil {
+		saveMotdCache(*cache, q)
+	} else {
+		cached, cerr := motdFromCache(*cache, f)
+		if cerr != nil {
+			return fmt.Errorf("%v, and no cached quote to fall back on", err)
+		}
+		q = cached
+	}
+
+	fmt.Print(formatMotd(q, *width))
+	return nil
+}
+
+// formatMotd wraps a quote for a terminal, indented, with the attribution
+// right-aligned under it
+func formatMotd(q store.Quote, width int) string {
+	const indent = "  "
+	var b strings.Builder
+	b.WriteString("\n")
+	for _, line := range textshape.Wrap(textshape.Hyphenate(q.Text, q.Lang), float64(width-2*len(indent)), textshape.Columns) {
+		b.WriteString(indent + line + "\n")
+	}
+	for _, line := range textshape.Wrap(locale.Attribution(q.Author, q.Book, q.Lang), float64(width-2*len(indent)), textshape.Columns) {
+		pad := width - len(indent) - int(textshape.Columns(line))
+		b.WriteString(strings.Repeat(" ", max(pad, 0)) + line + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// motdFromStore picks one of the least shown quotes, counting the view
+func motdFromStore(dsn string, f store.Filter) (store.Quote, error) {
+	s, err := store.Open(dsn)
+	if err != nil {
+		return store.Quote{}, err
+	}
+	defer s.Close()
+	return s.RandomQuote(f)
+}
+
+// motdFromAPI asks quotes serve for a random quote
+func motdFromAPI(base string, f store.Filter, timeout time.Duration) (store.Quote, error) {
+	v := url.Values{}
+	if f.Lang != "" {
+		v.Set("lang", f.Lang)
+	}
+	if f.Author != "" {
+		v.Set("author", f.Author)
+	}
+	if f.MaxChars > 0 {
+		v.Set("maxChars", strconv.Itoa(f.MaxChars))
+	}
+	if f.MinConfidence > 0 {
+		v.Set("minConfidence", strconv.FormatFloat(f.MinConfidence, 'f', -1, 64))
+	}
+	u := strings.TrimRight(base, "/") + "/quotes/random"
+	if len(v) > 0 {
+		u += "?" + v.Encode()
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(u)
+	if err != nil {
+		return store.Quote{}, fmt.Errorf("failed to reach %s: %v", base, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return store.Quote{}, fmt.Errorf("%s answered %s", u, resp.Status)
+	}
+	var q api.Quote
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&q); err != nil {
+		return store.Quote{}, fmt.Errorf("failed to read quote from %s: %v", base, err)
+	}
+	return store.Quote{ID: q.ID, Text: q.Text, Author: q.Author, Book: q.Book, Lang: q.Lang}, nil
+}
+
+// motdFromCache picks a cached quote matching f
+func motdFromCache(path string, f store.Filter) (store.Quote, error) {
+	var matching []store.Quote
+	for _, q := range loadMotdCache(path) {
+		if (f.Lang == "" || q.Lang == f.Lang) && (f.Author == "" || q.Author == f.Author) &&
+			(f.MaxChars == 0 || utf8.RuneCountInString(q.Text) <= f.MaxChars) {
+			matching = append(matching, q)
+		}
+	}
+	if len(matching) == 0 {
+		return store.Quote{}, store.ErrNotFound
+	}
+	return matching[rand.Intn(len(matching))], nil
+}
+
+func loadMotdCache(path string) []store.Quote {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var quotes []store.Quote
+	json.Unmarshal(data, &quotes)
+	return quotes
+}
+
+// saveMotdCache adds q to the cache, keeping the latest motdCacheSize.
+// Failing to write it is not worth spoiling a login banner over.
+func saveMotdCache(path string, q store.Quote) {
+	quotes := []store.Quote{q}
+	for _, c := range loadMotdCache(path) {
+		if len(quotes) == motdCacheSize {
+			break
+		}
+		if c.Text != q.Text {
+			quotes = append(quotes, c)
+		}
+	}
+	data, err := json.Marshal(quotes)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
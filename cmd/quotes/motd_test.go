@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"quotesparser/store"
+)
+
+func TestFormatMotd(t *testing.T) {
+	got := formatMotd(store.Quote{Text: "Whereof one cannot speak, thereof one must be silent.", Author: "Wittgenstein"}, 40)
+	want := "\n" +
+		"  Whereof one cannot speak, thereof\n" +
+		"  one must be silent.\n" +
+		"                        — Wittgenstein\n" +
+		"\n"
+	if got != want {
+		t.Errorf("formatMotd =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMotdFallsBackToCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/quotes/random" || r.URL.Query().Get("lang") != "en" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"id": 3, "text": "Be here now.", "author": "Ram Dass", "lang": "en"}`))
+	}))
+	cache := filepath.Join(t.TempDir(), "motd.json")
+	f := store.Filter{Lang: "en"}
+
+	q, err := motdFromAPI(srv.URL, f, time.Second)
+	if err != nil || q.Text != "Be here now." || q.Author != "Ram Dass" {
+		t.Fatalf("motdFromAPI = %+v, %v", q, err)
+	}
+	saveMotdCache(cache, q)
+	srv.Close()
+
+	if _, err := motdFromAPI(srv.URL, f, time.Second); err == nil {
+		t.Fatal("want an error from a closed server")
+	}
+	if q, err := motdFromCache(cache, f); err != nil || q.Text != "Be here now." {
+		t.Errorf("motdFromCache = %+v, %v", q, err)
+	}
+	if _, err := motdFromCache(cache, store.Filter{Lang: "tr"}); err == nil {
+		t.Error("a cached English quote was offered for Turkish")
+	}
+
+	for i := 0; i < motdCacheSize+5; i++ {
+		saveMotdCache(cache, store.Quote{Text: strings.Repeat("x", i+1)})
+	}
+	if n := len(loadMotdCache(cache)); n != motdCacheSize {
+		t.Errorf("cache holds %d quotes, want %d", n, motdCacheSize)
+	}
+}
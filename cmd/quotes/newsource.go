@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"quotesparser/source"
+)
+
+// runNewSource scaffolds a source package: the Source implementation, its
+// selector config, a fixture with its expected quotes and a test over the
+// fixtures, and registers the package with the generic commands
+func runNewSource(args []string) error {
+	fs := flag.NewFlagSet("new-source", flag.ExitOnError)
+	siteURL := fs.String("url", "", "address of the site, e.g. https://www.brainyquote.com (required)")
+	lang := fs.String("lang", "en", "language of the site's quotes")
+	dir := fs.String("dir", ".", "root of the quotesparser module")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: quotes new-source --url <site> [flags] <name>")
+	}
+	name := strings.ToLower(fs.Arg(0))
+	if !sourceNameRe.MatchString(name) {
+		return fmt.Errorf("bad source name %q: use lowercase letters, digits and dashes, starting with a letter", name)
+	}
+	pkg := strings.ReplaceAll(name, "-", "")
+	if _, ok := source.Get(name); ok {
+		return fmt.Errorf("%s is already a source", name)
+	}
+	u, err := url.Parse(*siteURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("--url must be an absolute URL like https://example.com")
+	}
+
+	module, err := modulePath(*dir)
+	if err != nil {
+		return err
+	}
+	pkgDir := filepath.Join(*dir, pkg)
+	if _, err := os.Stat(pkgDir); err == nil {
+		return fmt.Errorf("%s already exists", pkgDir)
+	}
+
+	data := scaffold{Name: name, Package: pkg, Module: module, URL: strings.TrimSuffix(u.String(), "/"), Lang: *lang}
+	files := []struct {
+		path string
+		tmpl *template.Template
+	}{
+		{filepath.Join(pkgDir, "source.go"), sourceTmpl},
+		{filepath.Join(pkgDir, "source_test.go"), testTmpl},
+		{filepath.Join(pkgDir, "selectors.json"), selectorsTmpl},
+		{filepath.Join(pkgDir, "testdata", "example.html"), fixtureTmpl},
+		{filepath.Join(pkgDir, "testdata", "example.json"), goldenTmpl},
+	}
+	for _, f := range files {
+		var buf bytes.Buffer
+		if err := f.tmpl.Execute(&buf, data); err != nil {
+			return err
+		}
+		content := buf.Bytes()
+		if strings.HasSuffix(f.path, ".go") {
+			if content, err = format.Source(content); err != nil {
+				return fmt.Errorf("generated %s does not parse: %v", f.path, err)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(f.path, content, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(progress, "  created %s\n", f.path)
+	}
+
+	registry := filepath.Join(*dir, "cmd", "quotes", "sources.go")
+	if err := addSourceImport(registry, module+"/"+pkg); err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "  registered %s in %s\n", pkg, registry)
+
+	fmt.Fprintf(progress, "\n✓ Scaffolded source %s. Next:\n", name)
+	fmt.Fprintf(progress, "  1. quotes download %s --cassettes cassettes <page on the site>\n", name)
+	fmt.Fprintf(progress, "  2. copy a downloaded page to %s and edit selectors.json until it parses\n", filepath.Join(pkgDir, "testdata"))
+	fmt.Fprintf(progress, "  3. go test ./%s -update to record its expected quotes, then check them by hand\n", pkg)
+	return nil
+}
+
+var sourceNameRe = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+type scaffold struct {
+	Name    string // command-line name, e.g. brainy-quote
+	Package string // Go package, e.g. brainyquote
+	Module  string
+	URL     string
+	Lang    string
+}
+
+// modulePath reads the module path from dir/go.mod
+func modulePath(dir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("run from the module root or pass --dir: %v", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module line in %s", filepath.Join(dir, "go.mod"))
+}
+
+// addSourceImport adds a blank import of pkg to the registration file
+func addSourceImport(path, pkg string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	src := string(content)
+	line := fmt.Sprintf("\t_ %q\n", pkg)
+	if strings.Contains(src, line) {
+		return nil
+	}
+
+	if i := strings.Index(src, "\nimport (\n"); i >= 0 {
+		at := i + len("\nimport (\n")
+		src = src[:at] + line + src[at:]
+	} else {
+		src = strings.TrimRight(src, "\n") + "\n\nimport (\n" + line + ")\n"
+	}
+
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %v", path, err)
+	}
+	return os.WriteFile(path, formatted, 0644)
+}
+
+var sourceTmpl = template.Must(template.New("source.go").Parse(`// Package {{.Package}} downloads and parses quotes from {{.URL}}.
+package {{.Package}}
+
+import (
+	_ "embed"
+	"fmt"
+	"net/url"
+
+	"{{.Module}}/source"
+)
+
+// BaseURL is the site's address
+const BaseURL = "{{.URL}}"
+
+//go:embed selectors.json
+var selectorConfig []byte
+
+// selectors say where the site keeps each quote; edit selectors.json to
+// match its markup
+var selectors = mustSelectors(selectorConfig)
+
+func init() {
+	source.Register(Source{})
+}
+
+// Source is {{.Name}} for quotes download, parse and import
+type Source struct{}
+
+func (Source) Name() string { return "{{.Name}}" }
+
+// PageURL accepts a URL or a path on the site, e.g. /quotes/love, and asks
+// for later pages with ?page=n. Change it if the site paginates differently.
+func (Source) PageURL(target string, page int) (string, error) {
+	ref, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("bad target %q: %v", target, err)
+	}
+	base, err := url.Parse(BaseURL + "/")
+	if err != nil {
+		return "", err
+	}
+	u := base.ResolveReference(ref)
+	if page > 1 {
+		q := u.Query()
+		q.Set("page", fmt.Sprint(page))
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+// Parse extracts the quotes the selectors find on a page
+func (Source) Parse(page []byte) ([]source.Quote, error) {
+	return selectors.Extract(page)
+}
+
+func mustSelectors(data []byte) source.Selectors {
+	s, err := source.ParseSelectors(data)
+	if err != nil {
+		panic("{{.Package}}: selectors.json: " + err.Error())
+	}
+	return s
+}
+`))
+
+var testTmpl = template.Must(template.New("source_test.go").Parse(`package {{.Package}}
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"{{.Module}}/source"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected quotes in testdata from the parser")
+
+// TestParse parses every page in testdata and compares it with the quotes in
+// the .json file of the same name
+func TestParse(t *testing.T) {
+	pages, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) == 0 {
+		t.Skip("no pages in testdata")
+	}
+
+	for _, page := range pages {
+		t.Run(filepath.Base(page), func(t *testing.T) {
+			content, err := os.ReadFile(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Source{}.Parse(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := strings.TrimSuffix(page, ".html") + ".json"
+			if *update {
+				out, _ := json.MarshalIndent(got, "", "  ")
+				if err := os.WriteFile(golden, append(out, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			content, err = os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			var want []source.Quote
+			if err := json.Unmarshal(content, &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	first, err := Source{}.PageURL("/quotes/love", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Source{}.PageURL("/quotes/love", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || !strings.HasPrefix(first, BaseURL) {
+		t.Errorf("PageURL = %s, %s", first, second)
+	}
+}
+`))
+
+var selectorsTmpl = template.Must(template.New("selectors.json").Parse(`{
+  "quote": "div.quote",
+  "text": ".text",
+  "author": ".author",
+  "book": ".book",
+  "lang": "{{.Lang}}"
+}
+`))
+
+var fixtureTmpl = template.Must(template.New("example.html").Parse(`<!DOCTYPE html>
+<html>
+<!-- Example page for the scaffolded selectors; replace it with pages saved
+     from {{.URL}} by quotes download {{.Name}} -->
+<body>
+<div class="quote">
+  <span class="text">The only way out is through.</span>
+  <a class="author" href="/authors/robert-frost">Robert Frost</a>
+</div>
+<div class="quote">
+  <span class="text">Whatever our souls are made of, his and mine are the same.</span>
+  <a class="author" href="/authors/emily-bronte">Emily Brontë</a>
+  <a class="book" href="/books/wuthering-heights">Wuthering Heights</a>
+</div>
+</body>
+</html>
+`))
+
+var goldenTmpl = template.Must(template.New("example.json").Parse(`[
+  {
+    "text": "The only way out is through.",
+    "author": "Robert Frost",
+    "lang": "{{.Lang}}",
+    "missing": [
+      "book"
+    ]
+  },
+  {
+    "text": "Whatever our souls are made of, his and mine are the same.",
+    "author": "Emily Brontë",
+    "book": "Wuthering Heights",
+    "lang": "{{.Lang}}"
+  }
+]
+`))
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewSource(t *testing.T) {
+	dir := t.TempDir()
+	registry := filepath.Join(dir, "cmd", "quotes", "sources.go")
+	if err := os.MkdirAll(filepath.Dir(registry), 0755); err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile("sources.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(dir, "go.mod"): "module quotesparser\n\ngo 1.25\n",
+		registry:                     string(original),
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"brainy-quote", "quotefancy"} {
+		if err := runNewSource([]string{"--dir", dir, "--url", "https://example.com/", name}); err != nil {
+			t.Fatalf("new-source %s: %v", name, err)
+		}
+	}
+
+	pkgDir := filepath.Join(dir, "brainyquote")
+	for _, name := range []string{"source.go", "source_test.go", "selectors.json", "testdata/example.html", "testdata/example.json"} {
+		if _, err := os.Stat(filepath.Join(pkgDir, name)); err != nil {
+			t.Errorf("missing scaffold file: %v", err)
+		}
+	}
+	for _, name := range []string{"source.go", "source_test.go"} {
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(pkgDir, name), nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name.Name != "brainyquote" {
+			t.Errorf("%s is package %s", name, f.Name.Name)
+		}
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), registry, nil, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var imports []string
+	for _, imp := range f.Imports {
+		imports = append(imports, imp.Path.Value)
+	}
+	if got := strings.Join(imports, " "); got != `"quotesparser/brainyquote" "quotesparser/goodreads" "quotesparser/quotefancy" "quotesparser/uselessfacts" "quotesparser/wikiquote"` {
+		t.Errorf("sources.go imports %s", got)
+	}
+
+	if err := runNewSource([]string{"--dir", dir, "--url", "https://example.com", "quotefancy"}); err == nil {
+		t.Error("scaffolding an existing source should fail")
+	}
+}
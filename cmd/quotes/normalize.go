@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"quotesparser/schema"
+)
+
+func runNormalize(args []string) error {
+	fs := flag.NewFlagSet("normalize", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database to convert")
+	fs.Parse(args)
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(progress, "Converting flat tables in %s...\n", *dbPath)
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	report, err := schema.Normalize(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	fmt.Fprintf(progress, "\n✓ Normalization completed\n")
+	fmt.Fprintf(progress, "  New authors: %d\n", report.Authors)
+	fmt.Fprintf(progress, "  New books: %d\n", report.Books)
+	fmt.Fprintf(progress, "  Quotes linked: %d\n", report.LinkedQuotes)
+	fmt.Fprintf(progress, "  fraseslibros quotes copied: %d\n", report.FrasesQuotes)
+	fmt.Fprintf(progress, "  Quotes classified by origin: %d\n", report.Classified)
+	return nil
+}
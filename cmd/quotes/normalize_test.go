@@ -0,0 +1,69 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"quotesparser/migrations"
+)
+
+func TestAnonymousQuotes(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := migrations.Up(db, 13); err != nil {
+		t.Fatal(err)
+	}
+	// As an ingester crediting proverbs to whatever the site showed left them
+	for _, stmt := range []string{
+		"INSERT INTO authors (id, name) VALUES (1, 'Anonim'), (2, 'Amos Oz')",
+		"INSERT INTO books (id, title, authorId) VALUES (1, 'Türk Atasözleri', 1)",
+		`INSERT INTO quotes (id, text, author, lang, authorId, bookId, origin) VALUES
+			(1, 'Damlaya damlaya göl olur.', 'Anonim - Türk Atasözleri', 'tr', 1, 1, 'book'),
+			(2, 'Quien mucho abarca, poco aprieta.', 'Refrán', 'es', NULL, NULL, NULL),
+			(3, 'Birinci söz.', 'Amos Oz', 'tr', 2, NULL, 'unknown')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct {
+		id       int64
+		author   sql.NullString
+		authorID sql.NullInt64
+		book     string
+		origin   string
+	}{
+		{1, sql.NullString{String: "- Türk Atasözleri", Valid: true}, sql.NullInt64{}, "Türk Atasözleri", "anonymous"},
+		{2, sql.NullString{}, sql.NullInt64{}, "", "anonymous"},
+		{3, sql.NullString{String: "Amos Oz", Valid: true}, sql.NullInt64{Int64: 2, Valid: true}, "", "unknown"},
+	} {
+		var author sql.NullString
+		var authorID sql.NullInt64
+		var book, kind sql.NullString
+		err := db.QueryRow(`SELECT q.author, q.authorId, b.title, q.origin FROM quotes q
+			LEFT JOIN books b ON b.id = q.bookId WHERE q.id = ?`, want.id).Scan(&author, &authorID, &book, &kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if author != want.author || authorID != want.authorID || book.String != want.book || kind.String != want.origin {
+			t.Errorf("quote %d = %v, %v, book %q, %s; want %v, %v, book %q, %s",
+				want.id, author, authorID, book.String, kind.String, want.author, want.authorID, want.book, want.origin)
+		}
+	}
+
+	var authors, books int
+	if err := db.QueryRow("SELECT (SELECT COUNT(*) FROM authors WHERE name = 'Anonim'), (SELECT COUNT(*) FROM books)").Scan(&authors, &books); err != nil {
+		t.Fatal(err)
+	}
+	if authors != 0 || books != 1 {
+		t.Errorf("%d Anonim authors and %d books left, want none and the book without an author", authors, books)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"quotesparser/fetch"
+	"quotesparser/opentdb"
+	"quotesparser/store"
+)
+
+// runOpenTDB imports trivia questions from the Open Trivia Database API with
+// their wrong answers, category and difficulty, merging them into the trivia
+// table like processTrivia.go does trivia.txt. A session token keeps the API
+// from answering a question twice within the run.
+func runOpenTDB(args []string) error {
+	fs := flag.NewFlagSet("opentdb", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	batchSize := fs.Int("batch", store.DefaultBatchSize, "rows per multi-row INSERT")
+	count := fs.Int("count", 200, "questions to download")
+	category := fs.String("category", "", "Open Trivia DB category, by id or name, e.g. 9 or \"General Knowledge\" (empty = any)")
+	difficulty := fs.String("difficulty", "", "easy, medium or hard (empty = any)")
+	kind := fs.String("type", "", "multiple for multiple choice or boolean for true/false questions (empty = both)")
+	delay := fs.Duration("delay", 5*time.Second, "pause between requests; the API allows one every 5 seconds")
+	list := fs.Bool("categories", false, "list the categories and exit")
+	configure := addFetchFlags(fs)
+	openStore := addDryRunFlag(fs)
+	fs.Parse(args)
+
+	switch *difficulty {
+	case "", "easy", "medium", "hard":
+	default:
+		return fmt.Errorf("--difficulty must be easy, medium or hard, not %q", *difficulty)
+	}
+	switch *kind {
+	case "", "multiple", "boolean":
+	default:
+		return fmt.Errorf("--type must be multiple or boolean, not %q", *kind)
+	}
+
+	client := fetch.NewClient()
+	if err := configure(client); err != nil {
+		return err
+	}
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+
+	q := opentdb.Query{Difficulty: *difficulty, Type: *kind}
+	if *list || (*category != "" && !isNumber(*category)) {
+		categories, err := opentdb.Categories(ctx, client)
+		if err != nil {
+			return fmt.Errorf("failed to list categories: %v", err)
+		}
+		if *list {
+			for _, c := range categories {
+				fmt.Fprintf(progress, "%3d  %s\n", c.ID, c.Name)
+			}
+			return nil
+		}
+		for _, c := range categories {
+			if strings.EqualFold(c.Name, *category) {
+				q.Category = c.ID
+			}
+		}
+		if q.Category == 0 {
+			return fmt.Errorf("unknown category %q; see quotes opentdb --categories", *category)
+		}
+		fetch.Sleep(ctx, *delay)
+	} else if *category != "" {
+		q.Category, _ = strconv.Atoi(*category)
+	}
+
+	s, err := openStore(*dsn, store.Options{BatchSize: *batchSize})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if q.Token, err = opentdb.Token(ctx, client); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(progress, "Downloading %d questions from Open Trivia DB...\n", *count)
+	downloaded, inserted := 0, 0
+	amount := opentdb.MaxAmount
+	for requests := 0; downloaded < *count; requests++ {
+		if requests > 0 {
+			fetch.Sleep(ctx, *delay)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		q.Amount = min(amount, *count-downloaded)
+		questions, err := opentdb.Get(ctx, client, q)
+		if errors.Is(err, opentdb.ErrTooFew) && q.Amount > 1 {
+			// Near the end of what matches: ask for fewer
+			amount = q.Amount / 2
+			continue
+		}
+		if errors.Is(err, opentdb.ErrTooFew) || errors.Is(err, opentdb.ErrExhausted) {
+			fmt.Fprintln(progress, "No more questions match")
+			break
+		}
+		if errors.Is(err, opentdb.ErrRateLimited) {
+			slog.Info("rate limited; waiting")
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+
+		rows := make([]store.Trivia, len(questions))
+		for i, tq := range questions {
+			rows[i] = store.Trivia{Category: tq.Category, Question: tq.Question, Answer: tq.CorrectAnswer, WrongAnswers: tq.IncorrectAnswers, Difficulty: tq.Difficulty}
+		}
+		n, err := s.SaveTrivia(rows)
+		if err != nil {
+			return err
+		}
+		downloaded += len(questions)
+		inserted += n
+		fmt.Fprintf(progress, "  %d/%d questions\n", downloaded, *count)
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Open Trivia DB import interrupted\n")
+	} else {
+		fmt.Fprintf(progress, "\n✓ Open Trivia DB import completed\n")
+	}
+	fmt.Fprintf(progress, "  Downloaded: %d\n", downloaded)
+	if reportDryRun(s) {
+		return nil
+	}
+	fmt.Fprintf(progress, "  New: %d\n", inserted)
+	fmt.Fprintf(progress, "  Already in the database: %d\n", downloaded-inserted)
+	return nil
+}
+
+// isNumber reports whether s is a whole number
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
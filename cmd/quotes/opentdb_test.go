@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"quotesparser/fetch"
+	"quotesparser/opentdb"
+	"quotesparser/store"
+)
+
+func TestOpenTDB(t *testing.T) {
+	dir := t.TempDir()
+	cassettes := filepath.Join(dir, "cassettes")
+	if err := os.MkdirAll(cassettes, 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(cassettes, "opentdb.com.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := json.NewEncoder(f)
+	for url, body := range map[string]string{
+		opentdb.BaseURL + "/api_token.php?command=request": `{"response_code":0,"token":"tok"}`,
+		opentdb.Query{Amount: 3, Difficulty: "easy", Token: "tok"}.URL(): `{"response_code":0,"results":[
+			{"type":"multiple","difficulty":"easy","category":"Geography","question":"What is the capital of France?",
+				"correct_answer":"Paris","incorrect_answers":["Lyon","Nice","Lille"]},
+			{"type":"boolean","difficulty":"easy","category":"Science &amp; Nature","question":"Water boils at 100&deg;C at sea level.",
+				"correct_answer":"True","incorrect_answers":["False"]}]}`,
+		opentdb.Query{Amount: 1, Difficulty: "easy", Token: "tok"}.URL(): `{"response_code":4,"results":[]}`,
+	} {
+		if err := enc.Encode(fetch.Interaction{Method: "GET", URL: url, Status: 200, ContentType: "application/json", Body: body}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	dbPath := filepath.Join(dir, "database.db")
+	err = runOpenTDB([]string{"--db", dbPath, "--count", "3", "--difficulty", "easy", "--delay", "0",
+		"--cassettes", cassettes, "--vcr", "replay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := store.OpenSQLite(dbPath, store.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	trivia, err := s.Trivia("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trivia) != 2 {
+		t.Fatalf("imported %d questions, want 2", len(trivia))
+	}
+	if q := trivia[0]; strings.Join(q.WrongAnswers, ",") != "Lyon,Nice,Lille" {
+		t.Errorf("imported %+v, want its wrong answers", q)
+	}
+	if q := trivia[1]; q.Category != "Science & Nature" || q.Question != "Water boils at 100°C at sea level." || q.Answer != "True" || q.Difficulty != "easy" {
+		t.Errorf("imported %+v", q)
+	}
+}
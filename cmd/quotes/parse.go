@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"quotesparser/confidence"
+	"quotesparser/kitap"
+	"quotesparser/meter"
+	"quotesparser/pipeline"
+	"quotesparser/rejects"
+	"quotesparser/source"
+)
+
+func runParse(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes parse <source> [flags] <files or folders> (sources: %s)", sourceList("1000kitap"))
+	}
+
+	switch args[0] {
+	case "1000kitap":
+		return runParse1000Kitap(args[1:])
+	default:
+		if src, ok := source.Get(args[0]); ok {
+			return runParseSource(src, args[1:])
+		}
+		return fmt.Errorf("unknown source %q (sources: %s)", args[0], sourceList("1000kitap"))
+	}
+}
+
+func runParse1000Kitap(args []string) error {
+	fs := flag.NewFlagSet("parse 1000kitap", flag.ExitOnError)
+	authorPages := fs.Bool("author-pages", false, "inputs are /yazar/<slug>/alintilar pages; fill the author from the page")
+	outFile := fs.String("out", "quotes.json", "JSON file to write the quotes to")
+	parsers := fs.Int("parsers", 0, "files parsed at once; 0 for one per CPU core")
+	archivePath := fs.String("archive", "", "also parse the latest copy of each 1000kitap page in this archive: a .tar.gz file, or a database keeping them")
+	loadRules := addRulesFlag(fs)
+	loadPolicy := addPolicyFlag(fs, "1000kitap")
+	loadQuality := addQualityFlag(fs, "1000kitap")
+	startReport := addReportFlag(fs)
+	fs.Parse(args)
+
+	rules, err := loadRules()
+	if err != nil {
+		return err
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+	filter, err := loadQuality()
+	if err != nil {
+		return err
+	}
+	files, err := expandInputs(fs.Args(), "*.txt")
+	if err != nil {
+		return err
+	}
+	set, archived, err := loadArchived(*archivePath, "1000kitap")
+	if err != nil {
+		return err
+	}
+	files = append(files, archived...)
+	if len(files) == 0 {
+		return fmt.Errorf("no input files given")
+	}
+
+	parseFile, parsePage := rules.ParseQuotesFromFile, rules.ParseQuotes
+	if *authorPages {
+		parseFile, parsePage = rules.ParseAuthorQuotesFromFile, rules.ParseAuthorQuotes
+	}
+	parse := func(name string) ([]kitap.Quote, error) {
+		if !set.has(name) {
+			return parseFile(name)
+		}
+		content, err := set.read(name)
+		if err != nil {
+			return nil, err
+		}
+		return parsePage(string(content))
+	}
+
+	var allQuotes []kitap.Quote
+	version := parserVersion("1000kitap")
+	rep := startReport()
+	var report source.Report
+	lowQuality := 0
+	m := meter.New(progress, "Parsing", "files", len(files))
+	results := pipeline.ParseFiles(files, *parsers, metered(m, parse))
+	m.Finish()
+	for _, parsed := range results {
+		if parsed.Err != nil {
+			slog.Error("failed to parse file", "file", parsed.File, "err", parsed.Err)
+			rep.Add(rejects.Reject{Kind: rejects.File, File: parsed.File, Reason: parsed.Err.Error()})
+			continue
+		}
+		items, rejected := filterQuality(filter, rep, parsed.File, parsed.Items, func(q kitap.Quote) string { return q.QuoteText })
+		lowQuality += rejected
+		prov := set.provenance(parsed.File, version)
+		for i, q := range items {
+			rejectDropped(rep, policy, parsed.File, q.QuoteText, q.Missing)
+			items[i].SourceURL, items[i].SourceFile, items[i].FetchedAt, items[i].ParserVersion = prov.URL, prov.File, prov.FetchedAt, prov.Parser
+		}
+		allQuotes = append(allQuotes, kitap.ApplyPolicy(items, policy, &report)...)
+	}
+
+	if err := writeJSON(*outFile, allQuotes); err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "Parsed %d quotes from %d files. Saved to %s\n", len(allQuotes), len(files), *outFile)
+	fmt.Fprintf(progress, "Incomplete quotes (%s): %s\n", policy, report)
+	if lowQuality > 0 {
+		fmt.Fprintf(progress, "Low quality quotes rejected: %d\n", lowQuality)
+	}
+	conflicts := 0
+	for _, q := range allQuotes {
+		if q.Conflict {
+			conflicts++
+		}
+	}
+	if conflicts > 0 {
+		fmt.Fprintf(progress, "Quotes the page markup and __NEXT_DATA__ disagree on: %d (see their conflicts)\n", conflicts)
+	}
+	return saveReport(rep)
+}
+
+// expandInputs turns a list of files and folders into files, globbing
+// folders with pattern
+func expandInputs(inputs []string, pattern string) ([]string, error) {
+	var files []string
+	for _, in := range inputs {
+		info, err := os.Stat(in)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, in)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(in, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files: %v", err)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// runParseSource parses pages saved by download <source> into a JSON file
+func runParseSource(src source.Adapter, args []string) error {
+	fs := flag.NewFlagSet("parse "+src.Name(), flag.ExitOnError)
+	outFile := fs.String("out", src.Name()+".json", "JSON file to write the quotes to")
+	parsers := fs.Int("parsers", 0, "files parsed at once; 0 for one per CPU core")
+	archivePath := fs.String("archive", "", "also parse the latest copy of each page of the source in this archive: a .tar.gz file, or a database keeping them")
+	loadPolicy := addPolicyFlag(fs, src.Name())
+	loadQuality := addQualityFlag(fs, src.Name())
+	startReport := addReportFlag(fs)
+	fs.Parse(args)
+
+	policy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+	filter, err := loadQuality()
+	if err != nil {
+		return err
+	}
+	files, err := expandInputs(fs.Args(), "page*")
+	if err != nil {
+		return err
+	}
+	set, archived, err := loadArchived(*archivePath, src.Name())
+	if err != nil {
+		return err
+	}
+	files = append(files, archived...)
+	if len(files) == 0 {
+		return fmt.Errorf("no input files given")
+	}
+
+	var allQuotes []source.Quote
+	version := parserVersion(src.Name())
+	rep := startReport()
+	var report source.Report
+	lowQuality := 0
+	m := meter.New(progress, "Parsing", "files", len(files))
+	results := pipeline.ParseFiles(files, *parsers, metered(m, func(filename string) ([]source.Quote, error) {
+		if dumps, ok := src.(source.DumpParser); ok && !set.has(filename) && dumps.IsDump(filename) {
+			return dumps.ParseDump(filename)
+		}
+		content, err := set.read(filename)
+		if err != nil {
+			return nil, readError{err}
+		}
+		return src.Parse(set.url(filename), content)
+	}))
+	m.Finish()
+	for _, parsed := range results {
+		var unreadable readError
+		if errors.As(parsed.Err, &unreadable) {
+			return unreadable.err
+		}
+		if parsed.Err != nil {
+			slog.Error("failed to parse file", "file", parsed.File, "err", parsed.Err)
+			rep.Add(rejects.Reject{Kind: rejects.File, File: parsed.File, Reason: parsed.Err.Error()})
+			continue
+		}
+		items, rejected := filterQuality(filter, rep, parsed.File, parsed.Items, func(q source.Quote) string { return q.Text })
+		lowQuality += rejected
+		prov := set.provenance(parsed.File, version)
+		for i, q := range items {
+			rejectDropped(rep, policy, parsed.File, q.Text, q.Missing)
+			items[i].SourceURL, items[i].SourceFile, items[i].FetchedAt, items[i].ParserVersion = prov.URL, prov.File, prov.FetchedAt, prov.Parser
+			if q.Confidence == 0 {
+				// Parsers that do not score their quotes only tell what
+				// they missed
+				items[i].Confidence = confidence.Signals{Missing: q.Missing}.Score()
+			}
+		}
+		allQuotes = append(allQuotes, report.Filter(policy, items)...)
+	}
+
+	if err := writeJSON(*outFile, allQuotes); err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "Parsed %d quotes from %d files. Saved to %s\n", len(allQuotes), len(files), *outFile)
+	fmt.Fprintf(progress, "Incomplete quotes (%s): %s\n", policy, report)
+	if lowQuality > 0 {
+		fmt.Fprintf(progress, "Low quality quotes rejected: %d\n", lowQuality)
+	}
+	return saveReport(rep)
+}
+
+// metered counts each file parse is done with on m, with its size
+func metered[T any](m *meter.Meter, parse func(file string) ([]T, error)) func(file string) ([]T, error) {
+	return func(file string) ([]T, error) {
+		items, err := parse(file)
+		var size int64
+		if info, statErr := os.Stat(file); statErr == nil {
+			size = info.Size()
+		}
+		m.Add(1, size)
+		return items, err
+	}
+}
+
+// readError is a file that could not be read, which stops a parse rather
+// than being skipped like a page that does not parse
+type readError struct{ err error }
+
+func (e readError) Error() string { return e.err.Error() }
+
+// writeJSON saves v as indented JSON, leaving non-ASCII text readable
+func writeJSON(path string, v interface{}) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer fh.Close()
+
+	enc := json.NewEncoder(fh)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to write JSON: %v", err)
+	}
+	return nil
+}
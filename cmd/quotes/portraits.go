@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"quotesparser/fetch"
+	"quotesparser/imgcache"
+	"quotesparser/portraits"
+	"quotesparser/quota"
+)
+
+// runPortraits looks every author up on Wikipedia and caches the freely
+// licensed portraits it finds, recording their license in authorPortraits.
+// Authors already looked up, found or not, are skipped unless --refresh;
+// --prune trims the cache instead.
+func runPortraits(args []string) error {
+	fs := flag.NewFlagSet("portraits", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose authors to look up")
+	dir := fs.String("dir", "images/authors", "folder to cache portraits in, as <authorId>/<width>.jpg")
+	wikis := fs.String("wikis", "en,tr,es", "Wikipedia languages to look authors up in, in order")
+	sizes := fs.String("sizes", joinWidths(portraits.DefaultSizes), "widths to cache each portrait at")
+	limit := fs.Int("limit", 0, "look up at most this many authors (0 = all)")
+	refresh := fs.Bool("refresh", false, "look up authors again, including those with no portrait last time")
+	delay := fs.Duration("delay", 1*time.Second, "pause between authors")
+	api := fs.String("api", portraits.DefaultAPI, "MediaWiki api.php address; %s is replaced by the language")
+	userAgent := fs.String("user-agent", portraits.DefaultUserAgent, "user agent; Wikimedia asks for one with a way to contact you")
+	guard := addGuardFlags(fs)
+	configure := addFetchFlags(fs)
+	prune := addPruneFlags(fs)
+	fs.Parse(args)
+
+	widths, err := parseWidths(*sizes)
+	if err != nil {
+		return err
+	}
+
+	g, err := guard(*dir)
+	if err != nil {
+		return err
+	}
+	f := portraits.NewFinder()
+	f.API = *api
+	f.Wikis = strings.Split(*wikis, ",")
+	f.Client.UserAgent = *userAgent
+	f.Width = slices.Max(widths)
+	if err := configure(f.Client); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	if pruned, err := prune(db, *dir, portraitImages); pruned || err != nil {
+		return err
+	}
+	if n, err := fillPalettes(db, *dir, portraitImages); err != nil {
+		return err
+	} else if n > 0 {
+		fmt.Fprintf(progress, "Extracted the palettes of %d cached portraits\n", n)
+	}
+
+	query := "SELECT id, name FROM authors"
+	if !*refresh {
+		query += " WHERE id NOT IN (SELECT authorId FROM authorPortraits)"
+	}
+	query += " ORDER BY id"
+	if *limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", *limit)
+	}
+	type author struct {
+		id   int64
+		name string
+	}
+	var authors []author
+	rows, err := db.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to read authors: %v", err)
+	}
+	for rows.Next() {
+		var a author
+		if err := rows.Scan(&a.id, &a.name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read authors: %v", err)
+		}
+		authors = append(authors, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read authors: %v", err)
+	}
+
+	fmt.Fprintf(progress, "Looking up %d authors on Wikipedia (%s)...\n", len(authors), *wikis)
+	found, missing, failed := 0, 0, 0
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+	for i, a := range authors {
+		if i > 0 {
+			fetch.Sleep(ctx, *delay)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		p, saved, err := fetchPortrait(f, *dir, a.id, a.name, widths, g)
+		switch {
+		case err == nil:
+			found++
+			fmt.Fprintf(progress, "  %s: %s (%s)\n", a.name, p.Title, p.License)
+			if err := savePortrait(db, a.id, &p, saved); err != nil {
+				return err
+			}
+			if err := savePalette(db, *dir, portraitImages, a.id, saved); err != nil {
+				return err
+			}
+		case errors.Is(err, portraits.ErrNotFound):
+			missing++
+			if err := savePortrait(db, a.id, nil, nil); err != nil {
+				return err
+			}
+		case quota.IsLimit(err):
+			return err
+		default:
+			// Network trouble: leave the author for the next run
+			slog.Error("failed to fetch portrait", "author", a.name, "err", err)
+			failed++
+		}
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Portraits interrupted; the rest are left for the next run\n")
+	} else {
+		fmt.Fprintf(progress, "\n✓ Portraits completed\n")
+	}
+	fmt.Fprintf(progress, "  Found: %d\n", found)
+	fmt.Fprintf(progress, "  No free portrait: %d\n", missing)
+	fmt.Fprintf(progress, "  Failed: %d\n", failed)
+	return nil
+}
+
+var portraitImages = imageTable{table: "authorPortraits", key: "authorId", parent: "authors"}
+
+// fetchPortrait finds an author's portrait and caches its variants
+func fetchPortrait(f *portraits.Finder, dir string, authorID int64, name string, widths []int, g *quota.Guard) (portraits.Portrait, []int, error) {
+	p, err := f.Find(name)
+	if err != nil {
+		return p, nil, err
+	}
+	img, err := f.Download(p)
+	if err != nil {
+		return p, nil, err
+	}
+	saved, err := imgcache.Save(dir, authorID, img, widths, g)
+	return p, saved, err
+}
+
+// savePortrait records the outcome of an author's lookup; a nil portrait
+// records that none was found
+func savePortrait(db *sql.DB, authorID int64, p *portraits.Portrait, widths []int) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	var err error
+	if p == nil {
+		_, err = db.Exec("INSERT OR REPLACE INTO authorPortraits (authorId, status, fetchedAt) VALUES (?, 'none', ?)", authorID, now)
+	} else {
+		_, err = db.Exec(`
+			INSERT OR REPLACE INTO authorPortraits (authorId, status, title, page, sourceUrl, descriptionUrl,
+				license, licenseUrl, artist, credit, attributionRequired, widths, fetchedAt)
+			VALUES (?, 'ok', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			authorID, p.Title, p.Page, p.SourceURL, p.DescriptionURL,
+			p.License, p.LicenseURL, p.Artist, p.Credit, p.AttributionRequired, joinWidths(widths), now)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save portrait: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"quotesparser/imgcache"
+)
+
+// fakeWikipedia answers the two queries portraits makes: Amos Oz has a
+// portrait on English Wikipedia, Sabahattin Ali only on Turkish Wikipedia,
+// and nobody has one for Anonymous
+func fakeWikipedia(t *testing.T) *httptest.Server {
+	var img bytes.Buffer
+	src := image.NewRGBA(image.Rect(0, 0, 300, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 300; x++ {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	if err := png.Encode(&img, src); err != nil {
+		t.Fatal(err)
+	}
+
+	portraitsByWiki := map[string]map[string]string{
+		"en": {"Amos Oz": "Amos Oz 2005.jpg"},
+		"tr": {"Sabahattin Ali": "Sabahattin Ali.jpg"},
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/thumb/") {
+			w.Write(img.Bytes())
+			return
+		}
+		lang := strings.Split(r.URL.Path, "/")[1]
+		title := r.URL.Query().Get("titles")
+		var answer interface{}
+		if file, ok := strings.CutPrefix(title, "File:"); ok {
+			answer = map[string]interface{}{"query": map[string]interface{}{"pages": []interface{}{
+				map[string]interface{}{"imageinfo": []interface{}{map[string]interface{}{
+					"url":            srv.URL + "/original/" + file,
+					"thumburl":       srv.URL + "/thumb/" + r.URL.Query().Get("iiurlwidth") + "px-" + file,
+					"descriptionurl": "https://commons.wikimedia.org/wiki/File:" + file,
+					"extmetadata": map[string]interface{}{
+						"LicenseShortName":    map[string]string{"value": "CC BY-SA 3.0"},
+						"LicenseUrl":          map[string]string{"value": "https://creativecommons.org/licenses/by-sa/3.0"},
+						"Artist":              map[string]string{"value": `<a href="//commons.wikimedia.org/wiki/User:Someone">Some&nbsp;One</a>`},
+						"AttributionRequired": map[string]string{"value": "true"},
+					},
+				}}},
+			}}}
+		} else {
+			page := map[string]interface{}{"title": title}
+			if file, ok := portraitsByWiki[lang][title]; ok {
+				page["pageimage"] = file
+			} else {
+				page["missing"] = true
+			}
+			answer = map[string]interface{}{"query": map[string]interface{}{"pages": []interface{}{page}}}
+		}
+		json.NewEncoder(w).Encode(answer)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPortraits(t *testing.T) {
+	srv := fakeWikipedia(t)
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	cache := filepath.Join(t.TempDir(), "authors")
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO authors (id, name) VALUES (1, 'Amos Oz'), (2, 'Sabahattin Ali'), (3, 'Anonymous')"); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"--db", dbPath, "--dir", cache, "--api", srv.URL + "/%s/api.php", "--wikis", "en,tr",
+		"--sizes", "64,256,512", "--delay", "0", "--min-free", "0"}
+	if err := runPortraits(args); err != nil {
+		t.Fatal(err)
+	}
+
+	var status, license, artist, widths string
+	var attribution bool
+	err = db.QueryRow("SELECT status, license, artist, attributionRequired, widths FROM authorPortraits WHERE authorId = 1").
+		Scan(&status, &license, &artist, &attribution, &widths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake image is 300 pixels wide, so the 512 variant is not upscaled
+	if status != "ok" || license != "CC BY-SA 3.0" || artist != "Some One" || !attribution || widths != "64,256" {
+		t.Errorf("portrait = %s, %q, %q, %v, %q", status, license, artist, attribution, widths)
+	}
+	f, err := os.Open(imgcache.Path(cache, 1, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(f)
+	f.Close()
+	if err != nil || cfg.Width != 64 || cfg.Height != 85 {
+		t.Errorf("64 variant is %dx%d (%v), want 64x85", cfg.Width, cfg.Height, err)
+	}
+	if _, err := os.Stat(imgcache.Path(cache, 2, 256)); err != nil {
+		t.Errorf("the Turkish Wikipedia portrait was not cached: %v", err)
+	}
+	if err := db.QueryRow("SELECT status FROM authorPortraits WHERE authorId = 3").Scan(&status); err != nil || status != "none" {
+		t.Errorf("Anonymous recorded as %q (%v), want none", status, err)
+	}
+
+	// Everyone has been looked up, so a second run asks for nothing
+	if err := runPortraits(append(args, "--api", "http://127.0.0.1:1/%s/api.php", "--retries", "0")); err != nil {
+		t.Fatal(err)
+	}
+}
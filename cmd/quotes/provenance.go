@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"runtime/debug"
+	"time"
+
+	"quotesparser/store"
+)
+
+// parserVersion names the parser of a source and the build it is part of,
+// e.g. 1000kitap@3f2a9c1, so quotes read by a parser since fixed can be found
+func parserVersion(name string) string {
+	version, modified := "devel", false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v := info.Main.Version; v != "" && v != "(devel)" {
+			version = v
+		}
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && len(s.Value) >= 7:
+				version = s.Value[:7]
+			case s.Key == "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+	if modified {
+		version += "-dirty"
+	}
+	return name + "@" + version
+}
+
+// pageProvenance returns where the quotes of the page or file at path were
+// read from: the address and download time download recorded next to it,
+// else the time the file was last written
+func pageProvenance(path, parser string) store.Provenance {
+	p := store.Provenance{File: path, Parser: parser}
+	p.URL, p.FetchedAt = pageRecord(path)
+	if p.FetchedAt == "" {
+		if info, err := os.Stat(path); err == nil {
+			p.FetchedAt = info.ModTime().UTC().Format(time.RFC3339)
+		}
+	}
+	return p
+}
+
+// stamped returns the provenance quotes parse stamped a quote with, or file
+// for quotes kept by hand or parsed before quotes were stamped
+func stamped(p, file store.Provenance) store.Provenance {
+	if p.Parser == "" {
+		return file
+	}
+	return p
+}
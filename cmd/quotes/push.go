@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"quotesparser/push"
+)
+
+// runPush sends the quote of the day to the devices registered with
+// PUT /push/devices, each at its deliverAt time in its time zone, through
+// FCM for Android and APNs for iOS. It runs until stopped, or dispatches
+// once with --once for a cron job running it every few minutes.
+func runPush(args []string) error {
+	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database with the registered devices and the quotes")
+	fcmCredentials := fs.String("fcm-credentials", "", "service account JSON file of the Firebase project, to send to Android devices")
+	apnsKey := fs.String("apns-key", "", ".p8 key from the Apple developer account, to send to iOS devices")
+	apnsKeyID := fs.String("apns-key-id", "", "with --apns-key: the key's ID")
+	apnsTeam := fs.String("apns-team", "", "with --apns-key: the developer account's team ID")
+	apnsTopic := fs.String("apns-topic", "", "with --apns-key: the app's bundle ID")
+	apnsSandbox := fs.Bool("apns-sandbox", false, "with --apns-key: send to development builds of the app")
+	window := fs.Duration("window", time.Hour, "how late a quote may still go out after a device's delivery time")
+	interval := fs.Duration("interval", time.Minute, "how often to look for devices whose delivery time came")
+	once := fs.Bool("once", false, "send to the devices due now and exit")
+	fs.Parse(args)
+
+	senders := make(map[string]push.Sender)
+	if *fcmCredentials != "" {
+		fcm, err := push.LoadFCM(*fcmCredentials)
+		if err != nil {
+			return err
+		}
+		senders[push.FCM] = fcm
+	}
+	if *apnsKey != "" {
+		apns, err := push.LoadAPNs(*apnsKey, *apnsKeyID, *apnsTeam, *apnsTopic, *apnsSandbox)
+		if err != nil {
+			return err
+		}
+		senders[push.APNs] = apns
+	}
+	if len(senders) == 0 {
+		return errors.New("nothing to send through: give --fcm-credentials, --apns-key or both")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	d := push.NewDispatcher(db, senders)
+	d.Window = *window
+
+	if *once {
+		res, err := d.Dispatch(context.Background())
+		fmt.Fprintf(progress, "✓ Sent the quote of the day to %d devices\n", res.Sent)
+		fmt.Fprintf(progress, "  %d unregistered devices removed, %d failed\n", res.Removed, res.Failed)
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(progress, "Sending the quote of the day to the devices registered in %s (Ctrl+C to stop)\n", *dbPath)
+	d.Run(ctx, *interval)
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"quotesparser/api"
+	"quotesparser/push"
+)
+
+// sentTo records the notifications of a push.Sender
+type sentTo map[string]push.Notification
+
+func (s sentTo) Send(ctx context.Context, token string, n push.Notification) error {
+	if token == "uninstalled" {
+		return push.ErrUnregistered
+	}
+	s[token] = n
+	return nil
+}
+
+func TestPush(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO quotes (text, author, lang) VALUES ('Birinci söz.', 'Amos Oz', 'tr'), ('First quote.', 'Sally Rooney', 'en')`); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := api.New(db, "", "")
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	register := func(body string, want int) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"/push/devices", strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("PUT /push/devices %s = %d, want %d", body, resp.StatusCode, want)
+		}
+	}
+	register(`{"token": "android", "platform": "fcm", "lang": "tr", "timeZone": "Europe/Istanbul", "deliverAt": "08:00"}`, 200)
+	register(`{"token": "iphone", "platform": "apns", "timeZone": "America/New_York", "deliverAt": "08:00"}`, 200)
+	register(`{"token": "uninstalled", "platform": "fcm", "deliverAt": "05:00"}`, 200)
+	register(`{"token": "android", "platform": "fcm", "lang": "tr", "timeZone": "Europe/Istanbul", "deliverAt": "08:30"}`, 200)
+	register(`{"token": "pager", "platform": "sms"}`, 400)
+
+	// 05:40 UTC is 08:40 in Istanbul and 01:40 in New York
+	now := time.Date(2026, 3, 2, 5, 40, 0, 0, time.UTC)
+	sent := sentTo{}
+	d := push.NewDispatcher(db, map[string]push.Sender{push.FCM: sent, push.APNs: sent})
+	d.Now = func() time.Time { return now }
+	res, err := d.Dispatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 1 || res.Removed != 1 || len(sent) != 1 || sent["android"].Body != "Birinci söz." {
+		t.Fatalf("dispatch = %+v, sent %v", res, sent)
+	}
+	if res, _ := d.Dispatch(context.Background()); res != (push.Result{}) {
+		t.Errorf("dispatch again = %+v, want nothing sent twice", res)
+	}
+
+	// The New York morning
+	now = now.Add(8 * time.Hour)
+	if res, _ := d.Dispatch(context.Background()); res.Sent != 1 || sent["iphone"].Body == "" || sent["iphone"].Data["date"] != "2026-03-02" {
+		t.Errorf("dispatch in New York = %+v, sent %v", res, sent)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/push/devices/iphone", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM pushDevices").Scan(&n)
+	if resp.StatusCode != http.StatusNoContent || n != 1 {
+		t.Errorf("DELETE = %d, %d devices left, want 204 and 1", resp.StatusCode, n)
+	}
+
+	handler.Zones.Push, _ = time.LoadLocation("Asia/Tokyo")
+	register(`{"token": "tablet", "platform": "fcm"}`, 200)
+	var tz string
+	if db.QueryRow("SELECT timeZone FROM pushDevices WHERE token = 'tablet'").Scan(&tz); tz != "Asia/Tokyo" {
+		t.Errorf("device without a time zone got %q, want the server's Asia/Tokyo", tz)
+	}
+}
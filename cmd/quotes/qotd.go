@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"quotesparser/locale"
+	"quotesparser/store"
+)
+
+// runQotd prints the quote of the day: the same one on every device all day,
+// changing at midnight in --tz
+func runQotd(args []string) error {
+	fs := flag.NewFlagSet("qotd", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	lang := fs.String("lang", "", "only quotes in this language, e.g. tr")
+	author := fs.String("author", "", "only quotes by this author")
+	maxChars := fs.Int("max-chars", 0, "only quotes up to this many characters")
+	minConfidence := fs.Float64("min-confidence", 0, "only quotes the parser was at least this sure of, from 0 to 1")
+	tz := fs.String("tz", "Local", "time zone whose midnight starts a new day, e.g. Europe/Istanbul")
+	date := fs.String("date", "", "show the quote of another day, as YYYY-MM-DD")
+	fs.Parse(args)
+
+	day, err := store.Day(*date, *tz)
+	if err != nil {
+		return err
+	}
+
+	s, err := store.Open(*dsn)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	q, err := store.DailyQuote(s, store.Filter{Lang: *lang, Author: *author, MaxChars: *maxChars, MinConfidence: *minConfidence}, day)
+	if err != nil {
+		return err
+	}
+	fmt.Println(q.Text)
+	fmt.Println("  " + locale.Attribution(q.Author, q.Book, q.Lang))
+	return nil
+}
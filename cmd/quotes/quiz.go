@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"quotesparser/quiz"
+	"quotesparser/store"
+)
+
+// runQuiz exports trivia as a question bank for a learning platform, or as
+// the spreadsheet a live quiz platform imports, with wrong answers drawn from
+// the other questions for multiple choice
+func runQuiz(args []string) error {
+	fs := flag.NewFlagSet("quiz", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	format := fs.String("format", "gift", "gift, moodle (Moodle XML), kahoot or quizizz (spreadsheets)")
+	variant := fs.String("variant", quiz.MultipleChoice, "mc for multiple choice, short for short answers, or both")
+	choices := fs.Int("choices", 4, "options of a multiple-choice question, the answer included")
+	category := fs.String("category", "", "only trivia in this category")
+	limit := fs.Int("limit", 0, "export at most this many questions; 0 for all")
+	root := fs.String("root", "trivia", "question bank category the trivia categories go under")
+	seconds := fs.Int("time", 20, "seconds to answer a question on Kahoot or Quizizz")
+	out := fs.String("out", "", "file to write; standard output by default")
+	fs.Parse(args)
+
+	// written counts the questions a spreadsheet format had room for
+	written := -1
+	var write func(io.Writer, []quiz.Question) error
+	switch *format {
+	case "gift", "moodle":
+		bank := quiz.WriteGIFT
+		if *format == "moodle" {
+			bank = quiz.WriteMoodleXML
+		}
+		write = func(w io.Writer, questions []quiz.Question) error {
+			return bank(w, questions, *root, *variant)
+		}
+	case "kahoot", "quizizz":
+		sheet, most := quiz.WriteKahoot, 4
+		if *format == "quizizz" {
+			sheet, most = quiz.WriteQuizizz, 5
+		}
+		if *choices > most {
+			return fmt.Errorf("%s allows at most %d options, not --choices %d", *format, most, *choices)
+		}
+		if *out == "" {
+			return fmt.Errorf("--out is required for %s spreadsheets", *format)
+		}
+		write = func(w io.Writer, questions []quiz.Question) (err error) {
+			written, err = sheet(w, questions, *seconds)
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q, want gift, moodle, kahoot or quizizz", *format)
+	}
+	switch *variant {
+	case quiz.MultipleChoice, quiz.ShortAnswer, quiz.Both:
+	default:
+		return fmt.Errorf("unknown variant %q, want mc, short or both", *variant)
+	}
+	if *choices < 2 {
+		return fmt.Errorf("--choices must be at least 2")
+	}
+
+	s, err := store.Open(*dsn)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	trivia, err := s.Trivia(*category, *limit)
+	if err != nil {
+		return err
+	}
+	// Distractors come from the whole table even when exporting a part
+	pool := trivia
+	if *category != "" || *limit > 0 {
+		if pool, err = s.Trivia("", 0); err != nil {
+			return err
+		}
+	}
+	wanted := make(map[string]bool, len(trivia))
+	for _, t := range trivia {
+		wanted[t.Question] = true
+	}
+	var questions []quiz.Question
+	for _, q := range quiz.Build(pool, *choices-1) {
+		if wanted[q.Text] {
+			questions = append(questions, q)
+		}
+	}
+	sort.SliceStable(questions, func(i, j int) bool { return questions[i].Category < questions[j].Category })
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return fmt.Errorf("failed to create %s: %v", *out, err)
+		}
+		defer w.Close()
+	}
+	bw := bufio.NewWriter(w)
+	if err := write(bw, questions); err != nil {
+		return fmt.Errorf("failed to write question bank: %v", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write question bank: %v", err)
+	}
+	if written >= 0 {
+		fmt.Fprintf(progress, "✓ Exported %d questions to %s (%d without options or too long left out)\n", written, *out, len(questions)-written)
+	} else if *out != "" {
+		fmt.Fprintf(progress, "✓ Exported %d questions to %s\n", len(questions), *out)
+	}
+	return nil
+}
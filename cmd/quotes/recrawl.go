@@ -0,0 +1,244 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"quotesparser/archive"
+	"quotesparser/confidence"
+	"quotesparser/fetch"
+	"quotesparser/meter"
+	"quotesparser/origin"
+	"quotesparser/recrawl"
+	"quotesparser/source"
+	"quotesparser/store"
+)
+
+func runRecrawl(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes recrawl score|list|run [flags]")
+	}
+	switch args[0] {
+	case "score":
+		return runRecrawlScore(args[1:])
+	case "list":
+		return runRecrawlList(args[1:])
+	case "run":
+		return runRecrawlRun(args[1:])
+	default:
+		return fmt.Errorf("unknown recrawl action %q (score, list, run)", args[0])
+	}
+}
+
+// addWeightFlags registers the weights of the signals ranking pages and
+// returns a function reading them
+func addWeightFlags(fs *flag.FlagSet) func() recrawl.Weights {
+	d := recrawl.DefaultWeights
+	report := fs.Float64("report-weight", d.Report, "priority each report about a page's quotes adds")
+	view := fs.Float64("view-weight", d.View, "priority each doubling of the views of a page's quotes adds")
+	low := fs.Float64("low-confidence-weight", d.LowConfidence, "priority each quote parsed below --confidence adds")
+	threshold := fs.Float64("confidence", d.Confidence, "confidence below which a quote was likely misread")
+	return func() recrawl.Weights {
+		return recrawl.Weights{Report: *report, View: *view, LowConfidence: *low, Confidence: *threshold}
+	}
+}
+
+// runRecrawlScore ranks the pages quotes were read from into the frontier
+func runRecrawlScore(args []string) error {
+	fs := flag.NewFlagSet("recrawl score", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose pages to rank")
+	weights := addWeightFlags(fs)
+	fs.Parse(args)
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	n, err := recrawl.Score(db, weights())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "✓ Ranked %d pages to crawl again\n", n)
+	return nil
+}
+
+// runRecrawlList prints the top of the frontier as last ranked
+func runRecrawlList(args []string) error {
+	fs := flag.NewFlagSet("recrawl list", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose frontier to list")
+	limit := fs.Int("limit", 20, "how many pages to list")
+	fs.Parse(args)
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	pages, err := recrawl.List(db, *limit)
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		fmt.Fprintf(progress, "No pages to crawl again; run quotes recrawl score first\n")
+		return nil
+	}
+	for _, p := range pages {
+		crawled := p.CrawledAt
+		if crawled == "" {
+			crawled = "never"
+		}
+		fmt.Printf("%7.1f  %3d reports %7d views %3d doubtful  %-12s crawled %-20s %s\n", p.Priority, p.Reports, p.Views, p.LowConfidence, p.Source, crawled, p.URL)
+	}
+	return nil
+}
+
+// runRecrawlRun ranks the pages again, then fetches the most wanted ones
+// through their sources and saves the quotes they have now
+func runRecrawlRun(args []string) error {
+	fs := flag.NewFlagSet("recrawl run", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose frontier to crawl and quotes to update")
+	limit := fs.Int("pages", 50, "most pages to fetch again")
+	minAge := fs.Duration("min-age", 7*24*time.Hour, "leave pages crawled again more recently than this, unless reported since")
+	delay := fs.Duration("delay", 1*time.Second, "pause between requests")
+	weights := addWeightFlags(fs)
+	langOf := addLangFlag(fs)
+	configure := addScraperFlags(fs)
+	archiveTo := addArchiveFlag(fs, "as well")
+	fs.Parse(args)
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	if _, err := recrawl.Score(db, weights()); err != nil {
+		return err
+	}
+	due, err := recrawl.Next(db, *limit, *minAge)
+	if err != nil {
+		return err
+	}
+	if len(due) == 0 {
+		fmt.Fprintf(progress, "✓ No pages due to be crawled again\n")
+		return nil
+	}
+
+	client := fetch.NewClient()
+	if err := configure(client); err != nil {
+		return err
+	}
+	a, err := archiveTo(client, "")
+	if err != nil {
+		return err
+	}
+	err = recrawlPages(db, store.NewSQLite(db, store.Options{}), client, a, due, *delay, langOf)
+	return closeArchive(a, err)
+}
+
+// recrawlPages fetches each page through its source in turn and saves its
+// quotes, archiving the page under its source when a is set
+func recrawlPages(db *sql.DB, s store.Store, client *fetch.Client, a *archive.Cache, due []recrawl.Page, delay time.Duration, langOf func(text, fallback string) string) error {
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+
+	fmt.Fprintf(progress, "Crawling %d pages again...\n\n", len(due))
+	m := meter.New(progress, "Recrawling", "pages", len(due))
+	crawled, failed, inserted, total := 0, 0, 0, 0
+	fetched := false
+	for _, p := range due {
+		src, ok := source.Get(p.Source)
+		if !ok {
+			slog.Warn("no source to crawl the page again", "source", p.Source, "url", p.URL)
+			failed++
+			m.Add(1, 0)
+			continue
+		}
+		if fetched && !fetch.Sleep(ctx, delay) {
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		fetched = true
+		if a != nil {
+			a.Source = p.Source
+		}
+		body, err := src.Fetch(ctx, client, p.URL)
+		if errors.Is(err, fetch.ErrSourceBlocked) {
+			m.Finish()
+			return fmt.Errorf("%s: %w", p.URL, err)
+		}
+		if err != nil && !source.IsMissing(err) {
+			slog.Error("failed to download page", "url", p.URL, "err", err)
+			failed++
+			m.Add(1, 0)
+			continue
+		}
+		if err == nil {
+			n, saved, err := saveRecrawled(s, src, p.URL, body, langOf)
+			if err != nil {
+				m.Finish()
+				return err
+			}
+			total += n
+			inserted += saved
+		}
+		// A page gone from the site has nothing more to crawl
+		if err := recrawl.Crawled(db, p.URL); err != nil {
+			m.Finish()
+			return err
+		}
+		crawled++
+		m.Add(1, int64(len(body)))
+	}
+	m.Finish()
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Recrawl interrupted\n")
+	} else {
+		fmt.Fprintf(progress, "\n✓ Recrawl finished\n")
+	}
+	fmt.Fprintf(progress, "  Fetched: %d pages (%d failed)\n", crawled, failed)
+	fmt.Fprintf(progress, "  Inserted: %d quotes (%d already in the database)\n", inserted, total-inserted)
+	return nil
+}
+
+// saveRecrawled parses a page fetched again the way parse and import do, and
+// saves its quotes, returning how many it had and how many were new
+func saveRecrawled(s store.Store, src source.Adapter, url string, body []byte, langOf func(text, fallback string) string) (int, int, error) {
+	quotes, err := src.Parse(url, body)
+	if err != nil {
+		slog.Error("failed to parse page", "url", url, "err", err)
+		return 0, 0, nil
+	}
+	var report source.Report
+	quotes = report.Filter(source.PolicyFor(src.Name()), quotes)
+	prov := store.Provenance{URL: url, FetchedAt: time.Now().UTC().Format(time.RFC3339), Parser: parserVersion(src.Name())}
+	rows := make([]store.Quote, len(quotes))
+	for i, q := range quotes {
+		if q.Confidence == 0 {
+			q.Confidence = confidence.Signals{Missing: q.Missing}.Score()
+		}
+		rows[i] = store.Quote{Text: q.Text, Author: q.Author, Book: q.Book, Lang: langOf(q.Text, q.Lang), Enrich: q.Enrich, Source: src.Name(), Likes: q.Likes, Confidence: q.Confidence, Provenance: prov,
+			Origin: origin.Classify(origin.Hints{Text: q.Text, Author: q.Author, Book: q.Book, Source: src.Name()})}
+	}
+	n, err := s.SaveQuotes(rows)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %v", url, err)
+	}
+	return len(rows), n, nil
+}
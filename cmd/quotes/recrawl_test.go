@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"quotesparser/api"
+	"quotesparser/recrawl"
+	"quotesparser/store"
+)
+
+func TestRecrawl(t *testing.T) {
+	fakeSite(t)
+
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	// A doubtful quote of the first page, and one of the second shown a few
+	// times
+	first, second := "https://quotes.example/tag/love", "https://quotes.example/tag/love?page=2"
+	_, err = store.NewSQLite(db, store.Options{}).SaveQuotes([]store.Quote{
+		{Text: "The only way out is thru.", Lang: "en", Source: "quotes-example", Confidence: 0.3, Provenance: store.Provenance{URL: first, Parser: "quotes-example@old"}},
+		{Text: "Love is composed of a single soul inhabiting two bodies.", Lang: "en", Source: "quotes-example", ViewCount: 3, Confidence: 0.9, Provenance: store.Provenance{URL: second, Parser: "quotes-example@old"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers report the misread quote. The fake site took over the default
+	// transport, so the API is asked through its own client.
+	srv := httptest.NewServer(api.New(db, t.TempDir(), t.TempDir()))
+	defer srv.Close()
+	report := func(path, body string, want int) {
+		t.Helper()
+		resp, err := srv.Client().Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			got, _ := io.ReadAll(resp.Body)
+			t.Errorf("POST %s = %d, want %d: %s", path, resp.StatusCode, want, got)
+		}
+	}
+	report("/quotes/1/report", `{"reason": "typo in the text"}`, http.StatusNoContent)
+	report("/quotes/1/report", "", http.StatusNoContent)
+	report("/quotes/99/report", "", http.StatusNotFound)
+	report("/quotes/1/report", `{"why": "typo"}`, http.StatusBadRequest)
+
+	if err := runRecrawl([]string{"score", "--db", dbPath}); err != nil {
+		t.Fatal(err)
+	}
+	pages, err := recrawl.List(db, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 || pages[0].URL != first || pages[0].Reports != 2 || pages[0].LowConfidence != 1 || pages[1].URL != second || pages[1].Views != 3 {
+		t.Fatalf("frontier = %+v, want the reported page first, then the viewed one", pages)
+	}
+
+	// The reported page is crawled first, and its quotes saved again
+	if err := runRecrawl([]string{"run", "--db", dbPath, "--pages", "1", "--delay", "0", "--host-delay", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM quotes WHERE text LIKE '%only way out is through%' AND sourceUrl = ?", first).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("the quote of the page crawled again was saved %d times, want 1", n)
+	}
+	pages, _ = recrawl.List(db, 10)
+	for _, p := range pages {
+		if crawled := p.CrawledAt != ""; crawled != (p.URL == first) || p.URL == first && p.Reports != 0 {
+			t.Errorf("after one page: %+v", p)
+		}
+	}
+
+	// Next time the page crawled recently waits, answered, for the other
+	if err := runRecrawl([]string{"run", "--db", dbPath, "--pages", "1", "--delay", "0", "--host-delay", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	due, err := recrawl.Next(db, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, _ = recrawl.List(db, 10)
+	for _, p := range pages {
+		if p.CrawledAt == "" || p.Reports != 0 {
+			t.Errorf("after two runs: %+v", p)
+		}
+	}
+	if len(due) != 2 {
+		t.Errorf("%d pages due without a minimum age, want both", len(due))
+	}
+	if err := runRecrawl([]string{"list", "--db", dbPath}); err != nil {
+		t.Fatal(err)
+	}
+}
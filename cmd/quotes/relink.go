@@ -0,0 +1,335 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"quotesparser/fetch"
+	"quotesparser/frases"
+)
+
+// slugMark stands for an author's slug in a relink template
+const slugMark = "{slug}"
+
+// linkMove is an author link and what relink rewrites it to
+type linkMove struct {
+	Old, New string
+}
+
+// probeResult is how the site answered a stored link
+type probeResult struct {
+	Link     string
+	Code     int
+	Location string // absolute, for redirects
+	Err      error
+}
+
+// runRelink rewrites the stored author links of a site that changed its URL
+// structure. It probes a sample of the links, derives the new pattern from
+// the redirects the site answers them with, checks it on the sample and
+// rewrites every link in frasesauthors and authors, recording each move in
+// linkMoves.
+func runRelink(args []string) error {
+	fs := flag.NewFlagSet("relink", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose author links to rewrite")
+	site := fs.String("site", frases.BaseURL, "site whose author links to audit")
+	to := fs.String("to", "", "template of the new links, e.g. "+frases.BaseURL+"/autor/"+slugMark+"; default: detected from the redirects of the sample")
+	sample := fs.Int("sample", 20, "stored links to probe")
+	delay := fs.Duration("delay", 1*time.Second, "pause between probes")
+	timeout := fs.Duration("timeout", 15*time.Second, "timeout of each probe")
+	dryRun := fs.Bool("dry-run", false, "preview the rewritten links without changing the database")
+	show := fs.Int("show", 10, "rewritten links to print (0 = none, -1 = all)")
+	fs.Parse(args)
+
+	*site = strings.TrimRight(*site, "/")
+	if *to != "" && !strings.Contains(*to, slugMark) {
+		return fmt.Errorf("--to must contain %s", slugMark)
+	}
+	if *sample < 1 {
+		return fmt.Errorf("--sample must be at least 1")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	links, err := storedLinks(db, *site)
+	if err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		fmt.Fprintf(progress, "✓ No author links under %s\n", *site)
+		return nil
+	}
+
+	client := &http.Client{
+		Timeout: *timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	picked := append([]string(nil), links...)
+	rand.Shuffle(len(picked), func(i, j int) { picked[i], picked[j] = picked[j], picked[i] })
+	if len(picked) > *sample {
+		picked = picked[:*sample]
+	}
+
+	fmt.Fprintf(progress, "Probing %d of %d author links under %s...\n", len(picked), len(links), *site)
+	var results []probeResult
+	for i, link := range picked {
+		if i > 0 {
+			time.Sleep(*delay)
+		}
+		results = append(results, probe(client, link))
+	}
+	var ok, moved, missing, failed int
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+		case r.Location != "":
+			moved++
+		case r.Code == http.StatusOK:
+			ok++
+		default:
+			missing++
+		}
+	}
+	fmt.Fprintf(progress, "  %d answered, %d redirected, %d missing, %d failed\n", ok, moved, missing, failed)
+
+	template := *to
+	if template == "" {
+		var votes int
+		template, votes = detectTemplate(results)
+		if template == "" {
+			if moved == 0 {
+				fmt.Fprintf(progress, "\n✓ No redirects in the sample; pass --to if the links moved without them\n")
+				return nil
+			}
+			return fmt.Errorf("the redirects do not keep the authors' slugs; pass --to or fix the links by hand")
+		}
+		fmt.Fprintf(progress, "  Detected %s from %d of %d redirects\n", template, votes, moved)
+	}
+
+	rewrite := linkRewriter(template)
+
+	// The rewritten sample must answer, or the template is wrong
+	var checked, answered int
+	for _, r := range results {
+		newLink, changed := rewrite(r.Link)
+		if !changed {
+			continue
+		}
+		if r.Location == newLink {
+			checked++
+			answered++
+			continue
+		}
+		time.Sleep(*delay)
+		checked++
+		if p := probe(client, newLink); p.Err == nil && p.Code == http.StatusOK {
+			answered++
+		}
+	}
+	if checked > 0 {
+		fmt.Fprintf(progress, "  %d of %d rewritten sample links answer\n", answered, checked)
+		if answered == 0 {
+			return fmt.Errorf("no rewritten link answers; not relinking to %s", template)
+		}
+	}
+
+	var moves []linkMove
+	for _, link := range links {
+		if newLink, changed := rewrite(link); changed {
+			moves = append(moves, linkMove{link, newLink})
+		}
+	}
+	fmt.Fprintf(progress, "\n%d of %d links to rewrite:\n", len(moves), len(links))
+	for i, m := range moves {
+		if *show >= 0 && i >= *show {
+			fmt.Fprintf(progress, "  ... and %d more\n", len(moves)-i)
+			break
+		}
+		fmt.Fprintf(progress, "  %s -> %s\n", m.Old, m.New)
+	}
+
+	if *dryRun {
+		fmt.Fprintf(progress, "\n✓ Dry run, nothing changed\n")
+		return nil
+	}
+	if len(moves) == 0 {
+		fmt.Fprintf(progress, "\n✓ Nothing to rewrite\n")
+		return nil
+	}
+	if err := applyMoves(db, moves, template); err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "\n✓ Rewrote %d links, recorded in linkMoves\n", len(moves))
+	return nil
+}
+
+// storedLinks returns the distinct author links under site
+func storedLinks(db *sql.DB, site string) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT authorLink FROM frasesauthors WHERE authorLink LIKE ? || '/%'
+		UNION
+		SELECT link FROM authors WHERE link LIKE ? || '/%'
+		ORDER BY 1`, site, site)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read author links: %v", err)
+	}
+	defer rows.Close()
+	var links []string
+	for rows.Next() {
+		var link string
+		if err := rows.Scan(&link); err != nil {
+			return nil, fmt.Errorf("failed to read author links: %v", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// probe requests link without following redirects
+func probe(client *http.Client, link string) probeResult {
+	r := probeResult{Link: link}
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	req.Header.Set("User-Agent", fetch.DefaultUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	r.Code = resp.StatusCode
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		if loc, err := resp.Location(); err == nil {
+			r.Location = loc.String()
+		}
+	}
+	return r
+}
+
+// slugOf returns the last path segment of an author link
+func slugOf(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	return segments[len(segments)-1]
+}
+
+// detectTemplate returns the template most redirects agree on, made by
+// replacing the old link's slug in the new one, and how many agree
+func detectTemplate(results []probeResult) (string, int) {
+	votes := make(map[string]int)
+	for _, r := range results {
+		if r.Location == "" {
+			continue
+		}
+		if t := templateOf(r.Link, r.Location); t != "" {
+			votes[t]++
+		}
+	}
+	var templates []string
+	for t := range votes {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if votes[templates[i]] != votes[templates[j]] {
+			return votes[templates[i]] > votes[templates[j]]
+		}
+		return templates[i] < templates[j]
+	})
+	if len(templates) == 0 {
+		return "", 0
+	}
+	return templates[0], votes[templates[0]]
+}
+
+// templateOf turns the link a redirect points to into a template, or ""
+// when it does not have the old link's slug as a path segment
+func templateOf(oldLink, newLink string) string {
+	slug := slugOf(oldLink)
+	u, err := url.Parse(newLink)
+	if slug == "" || err != nil {
+		return ""
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		if s == slug {
+			segments[i] = slugMark
+			u.Path = strings.Join(segments, "/")
+			u.RawQuery, u.Fragment = "", ""
+			return strings.Replace(u.String(), url.PathEscape(slugMark), slugMark, 1)
+		}
+	}
+	return ""
+}
+
+// linkRewriter returns a func filling template with a link's slug, which
+// reports false for links already following it
+func linkRewriter(template string) func(link string) (string, bool) {
+	following := regexp.MustCompile("^" + strings.Replace(regexp.QuoteMeta(template), regexp.QuoteMeta(slugMark), "[^/?#]+", 1) + "/?$")
+	return func(link string) (string, bool) {
+		slug := slugOf(link)
+		if slug == "" || following.MatchString(link) {
+			return link, false
+		}
+		return strings.Replace(template, slugMark, slug, 1), true
+	}
+}
+
+// applyMoves rewrites the links and records the moves, pointing earlier
+// moves at the latest links
+func applyMoves(db *sql.DB, moves []linkMove, template string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, m := range moves {
+		for _, query := range []string{
+			"UPDATE frasesauthors SET authorLink = ? WHERE authorLink = ?",
+			"UPDATE authors SET link = ? WHERE link = ?",
+			"UPDATE linkMoves SET newLink = ? WHERE newLink = ?",
+		} {
+			if _, err := tx.Exec(query, m.New, m.Old); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to rewrite %s: %v", m.Old, err)
+			}
+		}
+		_, err := tx.Exec(`INSERT INTO linkMoves (oldLink, newLink, rule, movedAt) VALUES (?, ?, ?, ?)
+			ON CONFLICT(oldLink) DO UPDATE SET newLink = excluded.newLink, rule = excluded.rule, movedAt = excluded.movedAt`,
+			m.Old, m.New, template, now)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record move of %s: %v", m.Old, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
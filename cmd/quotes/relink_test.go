@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRelink(t *testing.T) {
+	// The site moved its author pages from /<slug> to /autor/<slug>, and
+	// later to /autores/<slug> without redirecting
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case len(parts) == 2 && (parts[0] == "autor" || parts[0] == "autores"):
+			w.Write([]byte("<html>author page</html>"))
+		case len(parts) == 1 && parts[0] != "gone":
+			http.Redirect(w, r, "/autor/"+parts[0], http.StatusMovedPermanently)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO frasesauthors (authorName, authorLink, quoteCount) VALUES
+		('Aldous Huxley', ?, 2), ('Albert Camus', ?, 1), ('Amos Oz', ?, 1)`,
+		srv.URL+"/aldous-huxley", srv.URL+"/albert-camus", srv.URL+"/autor/amos-oz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO authors (name, link) VALUES ('Aldous Huxley', ?), ('Elsewhere', 'https://example.com/elsewhere')", srv.URL+"/aldous-huxley"); err != nil {
+		t.Fatal(err)
+	}
+	links := func() []string {
+		t.Helper()
+		rows, err := db.Query("SELECT authorLink FROM frasesauthors UNION ALL SELECT link FROM authors ORDER BY 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var got []string
+		for rows.Next() {
+			var link string
+			rows.Scan(&link)
+			got = append(got, strings.TrimPrefix(link, srv.URL))
+		}
+		return got
+	}
+	before := links()
+
+	flags := []string{"--db", dbPath, "--site", srv.URL, "--delay", "0"}
+	if err := runRelink(append(flags, "--dry-run")); err != nil {
+		t.Fatal(err)
+	}
+	if got := links(); !reflect.DeepEqual(got, before) {
+		t.Fatalf("dry run changed the links to %v", got)
+	}
+
+	if err := runRelink(flags); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/autor/albert-camus", "/autor/aldous-huxley", "/autor/aldous-huxley", "/autor/amos-oz", "https://example.com/elsewhere"}
+	if got := links(); !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %v, want %v", got, want)
+	}
+
+	// Without redirects, the new pattern is given, and the earlier moves
+	// follow it
+	if err := runRelink(append(flags, "--to", srv.URL+"/autores/{slug}")); err != nil {
+		t.Fatal(err)
+	}
+	var moves, rewritten int
+	if err := db.QueryRow("SELECT COUNT(*), SUM(newLink LIKE '%/autores/%') FROM linkMoves").Scan(&moves, &rewritten); err != nil {
+		t.Fatal(err)
+	}
+	if moves != 5 || rewritten != 5 {
+		t.Errorf("linkMoves has %d moves, %d to the latest links; want 5 and 5", moves, rewritten)
+	}
+	var latest string
+	if err := db.QueryRow("SELECT newLink FROM linkMoves WHERE oldLink = ?", srv.URL+"/aldous-huxley").Scan(&latest); err != nil || latest != srv.URL+"/autores/aldous-huxley" {
+		t.Errorf("first link of Aldous Huxley moved to %q (%v), want the latest", latest, err)
+	}
+
+	if err := runRelink(append(flags, "--to", srv.URL+"/nowhere/{slug}")); err == nil {
+		t.Error("relinked to a template whose links do not answer")
+	}
+}
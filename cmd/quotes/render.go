@@ -0,0 +1,118 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"quotesparser/palette"
+	"quotesparser/render"
+	"quotesparser/schema"
+	"quotesparser/store"
+)
+
+// runRender draws a quote as a card for a picture frame or e-ink display.
+// With --daily it draws the quote of the day in --tz, so a display redrawn
+// from cron turns to the next quote at its own midnight.
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database to read")
+	id := fs.Int64("id", 0, "ID of the quote to draw")
+	daily := fs.Bool("daily", false, "draw the quote of the day instead of --id")
+	tz := fs.String("tz", "Local", "with --daily: time zone whose midnight starts a new day, e.g. Europe/Istanbul")
+	dailyLang := fs.String("lang", "", "with --daily: language of the quote")
+	out := fs.String("out", "quote.png", "file to write, a .png or .svg")
+	width := fs.Int("w", render.DefaultWidth, "width in pixels")
+	height := fs.Int("h", render.DefaultHeight, "height in pixels")
+	margin := fs.Int("margin", 0, "margin in pixels; 0 for a twentieth of the width")
+	size := fs.Float64("size", 0, "text size in pixels; 0 for a tenth of the height")
+	minSize := fs.Float64("min-size", 0, "smallest size long quotes shrink to; 0 for a fortieth of the height, at least 12")
+	fontPath := fs.String("font", "", "TrueType font; a serif one installed by default")
+	dither := fs.Bool("dither", false, "reduce to black and white for 1-bit e-ink panels")
+	themed := fs.Bool("theme", false, "color the card after the book's cover or the author's portrait")
+	fs.Parse(args)
+
+	if *daily == (*id > 0) {
+		return errors.New("either --id or --daily is required")
+	}
+	ext := strings.ToLower(filepath.Ext(*out))
+	if ext != ".png" && ext != ".svg" {
+		return fmt.Errorf("--out must end in .png or .svg, not %q", ext)
+	}
+	o := render.Options{Width: *width, Height: *height, Margin: *margin, Size: *size, MinSize: *minSize, Dither: *dither}
+	if err := o.Check(); err != nil {
+		return err
+	}
+
+	font, err := render.FindFont(*fontPath)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	var day time.Time
+	if *daily {
+		if day, err = store.Day("", *tz); err != nil {
+			return err
+		}
+		q, err := store.DailyQuote(store.NewSQLite(db, store.Options{}), store.Filter{Lang: *dailyLang}, day)
+		if err != nil {
+			return fmt.Errorf("failed to pick the quote of the day: %v", err)
+		}
+		*id = q.ID
+	}
+
+	var text string
+	var author, lang, colors sql.NullString
+	err = db.QueryRow(`SELECT q.text, q.author, q.lang, COALESCE(c.palette, p.palette)
+		FROM quotes q
+		LEFT JOIN bookCovers c ON c.bookId = q.bookId AND c.status = 'ok'
+		LEFT JOIN authorPortraits p ON p.authorId = q.authorId AND p.status = 'ok'
+		WHERE q.id = ?`, *id).Scan(&text, &author, &lang, &colors)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no quote %d", *id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read quote: %v", err)
+	}
+	card := render.Card{Text: text, Lang: lang.String, Date: day}
+	card.Author, card.Book = schema.SplitAttribution(author.String)
+	if *themed {
+		if p, err := palette.Parse(colors.String); err == nil && len(p) > 0 {
+			t := p.Theme()
+			o.Theme = &t
+		}
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", *out, err)
+	}
+	if ext == ".svg" {
+		err = render.SVG(f, font, card, o)
+	} else {
+		err = render.PNG(f, font, card, o)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return fmt.Errorf("failed to write %s: %v", *out, err)
+	}
+
+	fmt.Fprintf(progress, "\n✓ Rendered quote %d to %s\n", *id, *out)
+	return nil
+}
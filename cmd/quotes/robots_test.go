@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"quotesparser/fetch"
+)
+
+func TestRobots(t *testing.T) {
+	fakeSite(t)
+
+	// The fake site disallows /tag/private to the scrapers, and every page
+	// to other crawlers
+	pagesDir := t.TempDir()
+	download := func(args ...string) {
+		t.Helper()
+		args = append([]string{"quotes-example", "--pages", "2", "--delay", "0", "--host-delay", "0", "--min-free", "0", "--out", pagesDir}, args...)
+		if err := runDownload(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := func(target string) bool {
+		_, err := os.Stat(filepath.Join(pagesDir, target, "page1.html"))
+		return err == nil
+	}
+	download("tag/love", "tag/private")
+	if !saved("tag_love") || saved("tag_private") {
+		t.Errorf("saved tag/love %v and tag/private %v, want only the allowed tag/love", saved("tag_love"), saved("tag_private"))
+	}
+	download("--ignore-robots", "tag/private")
+	if !saved("tag_private") {
+		t.Error("download --ignore-robots skipped the disallowed page")
+	}
+
+	robots := fetch.ParseRobots([]byte(`User-agent: *
+Disallow: /
+
+User-agent: other
+User-agent: QuotesParser  # groups naming the scraper are merged
+Disallow: /search
+Allow: /search/about$
+Crawl-delay: 2.5
+
+user-agent: quotesparser
+disallow: /*.pdf$
+allow: /tag/*/private
+disallow: /tag/
+`), fetch.DefaultAgent)
+	if robots.CrawlDelay != 2500*time.Millisecond {
+		t.Errorf("Crawl-delay = %v, want 2.5s", robots.CrawlDelay)
+	}
+	for path, want := range map[string]bool{
+		"/":                 true,
+		"/robots.txt":       true,
+		"/search?q=love":    false,
+		"/search/about":     true,
+		"/search/about/us":  false,
+		"/books/camus.pdf":  false,
+		"/books/camus.pdfx": true,
+		"/tag/love":         false,
+		"/tag/love/private": true,
+	} {
+		if got := robots.Allowed(path); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", path, got, want)
+		}
+	}
+	if fetch.ParseRobots([]byte("User-agent: *\nDisallow: /private\n"), fetch.DefaultAgent).Allowed("/private/page") {
+		t.Error("the * group does not apply to a scraper no group names")
+	}
+}
+
+func TestRobotsUnavailable(t *testing.T) {
+	// robots.txt fails once, then allows everything by being missing
+	var failed atomic.Bool
+	var agent atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != fetch.UserAgent(fetch.DefaultAgent) {
+			agent.Store(r.UserAgent())
+		}
+		if r.URL.Path == "/robots.txt" {
+			if failed.CompareAndSwap(false, true) {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	c := fetch.NewClient()
+	c.HTTP = srv.Client()
+	c.Retries = 0
+	c.Polite = &fetch.Polite{RobotsRetry: 50 * time.Millisecond}
+	if _, err := c.Get(srv.URL + "/page"); !errors.Is(err, fetch.ErrRobotsUnavailable) {
+		t.Fatalf("with robots.txt failing, err = %v, want ErrRobotsUnavailable", err)
+	}
+	if _, err := c.Get(srv.URL + "/page"); !errors.Is(err, fetch.ErrRobotsUnavailable) {
+		t.Fatalf("before the retry, err = %v, want ErrRobotsUnavailable", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.Get(srv.URL + "/page"); err != nil {
+		t.Fatalf("with robots.txt missing, err = %v", err)
+	}
+	if sent := agent.Load(); sent != nil {
+		t.Errorf("sent User-Agent %q, want the one robots.txt rules are matched for", sent)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"quotesparser/store"
+)
+
+func runSchedule(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes schedule pin|unpin|list [flags]")
+	}
+	switch args[0] {
+	case "pin":
+		return runSchedulePin(args[1:])
+	case "unpin":
+		return runScheduleUnpin(args[1:])
+	case "list":
+		return runScheduleList(args[1:])
+	default:
+		return fmt.Errorf("unknown schedule action %q (pin, unpin, list)", args[0])
+	}
+}
+
+// openScheduler opens the store of dsn to pin quotes on
+func openScheduler(dsn string) (store.Store, store.Scheduler, error) {
+	s, err := store.Open(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	sch, ok := s.(store.Scheduler)
+	if !ok {
+		s.Close()
+		return nil, nil, fmt.Errorf("%s cannot schedule quotes", dsn)
+	}
+	return s, sch, nil
+}
+
+// runSchedulePin pins a quote to a day, such as an author's birthday, for
+// the quote of the day to be it there wherever its filters match
+func runSchedulePin(args []string) error {
+	fs := flag.NewFlagSet("schedule pin", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	date := fs.String("date", "", "day to pin the quote to, as YYYY-MM-DD")
+	id := fs.Int64("id", 0, "ID of the quote")
+	note := fs.String("note", "", `why, e.g. "Sally Rooney's birthday"`)
+	fs.Parse(args)
+
+	if *date == "" || *id <= 0 {
+		return errors.New("--date and --id are required")
+	}
+	if _, err := store.Day(*date, "Local"); err != nil {
+		return err
+	}
+	// Yesterday here may still be today in the time zone of an output
+	if *date < time.Now().AddDate(0, 0, -1).Format(time.DateOnly) {
+		return fmt.Errorf("%s is in the past", *date)
+	}
+
+	s, sch, err := openScheduler(*dsn)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	err = sch.Pin(*date, *id, *note)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no quote %d", *id)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "✓ Pinned quote %d to %s\n", *id, *date)
+	return nil
+}
+
+// runScheduleUnpin removes a quote, or every quote, pinned to a day
+func runScheduleUnpin(args []string) error {
+	fs := flag.NewFlagSet("schedule unpin", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	date := fs.String("date", "", "day to unpin, as YYYY-MM-DD")
+	id := fs.Int64("id", 0, "ID of the quote to unpin; 0 for every quote of the day")
+	fs.Parse(args)
+
+	if *date == "" {
+		return errors.New("--date is required")
+	}
+	if _, err := store.Day(*date, "Local"); err != nil {
+		return err
+	}
+
+	s, sch, err := openScheduler(*dsn)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	n, err := sch.Unpin(*date, *id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("nothing pinned to %s", *date)
+	}
+	fmt.Fprintf(progress, "✓ Unpinned %d quotes from %s\n", n, *date)
+	return nil
+}
+
+// runScheduleList prints the quotes pinned to the coming days
+func runScheduleList(args []string) error {
+	fs := flag.NewFlagSet("schedule list", flag.ExitOnError)
+	dsn := fs.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	from := fs.String("from", "", "first day to list, as YYYY-MM-DD; today by default")
+	days := fs.Int("days", 90, "how many days to list")
+	fs.Parse(args)
+
+	start, err := store.Day(*from, "Local")
+	if err != nil {
+		return err
+	}
+	if *days < 1 {
+		return errors.New("--days must be at least 1")
+	}
+
+	s, sch, err := openScheduler(*dsn)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	pins, err := sch.Schedule(start.Format(time.DateOnly), start.AddDate(0, 0, *days-1).Format(time.DateOnly))
+	if err != nil {
+		return err
+	}
+	if len(pins) == 0 {
+		fmt.Fprintf(progress, "Nothing pinned in the %d days from %s\n", *days, start.Format(time.DateOnly))
+		return nil
+	}
+	for _, p := range pins {
+		text := p.Quote.Text
+		if r := []rune(text); len(r) > 60 {
+			text = strings.TrimSpace(string(r[:59])) + "…"
+		}
+		fmt.Printf("%s  %6d  %s — %s\n", p.Date, p.Quote.ID, text, p.Quote.Credit())
+		if p.Note != "" {
+			fmt.Printf("%s  %6s  (%s)\n", strings.Repeat(" ", len(p.Date)), "", p.Note)
+		}
+	}
+	return nil
+}
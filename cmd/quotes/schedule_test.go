@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"quotesparser/store"
+)
+
+func TestSchedule(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	s, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.SaveQuotes([]store.Quote{{Text: "Birinci söz.", Lang: "tr"}, {Text: "İkinci söz.", Lang: "tr"}, {Text: "Üçüncü söz.", Lang: "tr"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Now().AddDate(0, 0, 10)
+	date := day.Format(time.DateOnly)
+	unpinned, err := store.DailyQuote(s, store.Filter{}, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := unpinned.ID%3 + 1
+	if err := runSchedule([]string{"pin", "--db", dbPath, "--date", date, "--id", fmt.Sprint(pinned), "--note", "a birthday"}); err != nil {
+		t.Fatal(err)
+	}
+	if q, err := store.DailyQuote(s, store.Filter{}, day); err != nil || q.ID != pinned {
+		t.Errorf("quote of %s = %d (%v), want the pinned %d", date, q.ID, err, pinned)
+	}
+
+	if err := runSchedule([]string{"pin", "--db", dbPath, "--date", "2001-01-01", "--id", "1"}); err == nil {
+		t.Error("pinned a quote to a past day")
+	}
+	if err := runSchedule([]string{"pin", "--db", dbPath, "--date", date, "--id", "99"}); err == nil {
+		t.Error("pinned a quote that does not exist")
+	}
+
+	if err := runSchedule([]string{"unpin", "--db", dbPath, "--date", date}); err != nil {
+		t.Fatal(err)
+	}
+	if q, _ := store.DailyQuote(s, store.Filter{}, day); q.ID != unpinned.ID {
+		t.Errorf("quote of %s after unpinning = %d, want %d again", date, q.ID, unpinned.ID)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"quotesparser/locale"
+	"quotesparser/schema"
+)
+
+// runSearch prints the quotes whose text or author has every word of the
+// query. With --fold accents do not matter: "garcia marquez" finds García
+// Márquez and "istanbul" İstanbul.
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database to search")
+	fold := fs.Bool("fold", false, "match words without their accents and diacritics")
+	limit := fs.Int("limit", 20, "quotes to print (0 = all)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: quotes search [flags] <words>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("search needs words to look for")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	indexed, err := schema.IndexSearch(db)
+	if err != nil {
+		return err
+	}
+	if indexed > 0 {
+		fmt.Fprintf(progress, "Indexed %d new or changed quotes\n", indexed)
+	}
+
+	found, err := schema.Search(db, strings.Join(fs.Args(), " "), *fold, *limit)
+	if err != nil {
+		return err
+	}
+	for i, q := range found {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(q.Text)
+		author, book := schema.SplitAttribution(q.Author)
+		fmt.Println("  " + locale.Attribution(author, book, q.Lang))
+	}
+	fmt.Fprintf(progress, "\n✓ Found %d quotes\n", len(found))
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"quotesparser/dedup"
+	"quotesparser/schema"
+)
+
+func TestSearch(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	insert := func(text, author string) {
+		t.Helper()
+		if _, err := db.Exec("INSERT INTO quotes (text, author, textHash) VALUES (?, ?, ?)", text, author, dedup.TextHash(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert("La vida no es la que uno vivió.", "Gabriel García Márquez - Vivir para contarla")
+	insert("No hay medicina que cure lo que no cura la felicidad.", "Gabriel Garcia Marquez")
+	insert("İstanbul'u dinliyorum, gözlerim kapalı.", "Orhan Veli Kanık")
+
+	search := func(query string, fold bool) []string {
+		t.Helper()
+		if err := runSearch([]string{"--db", dbPath}); err == nil {
+			t.Error("a search without words was accepted")
+		}
+		args := []string{"--db", dbPath, query}
+		if fold {
+			args = append([]string{"--fold"}, args...)
+		}
+		if err := runSearch(args); err != nil {
+			t.Fatal(err)
+		}
+		found, err := schema.Search(db, query, fold, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var texts []string
+		for _, q := range found {
+			texts = append(texts, q.Text)
+		}
+		return texts
+	}
+
+	both := []string{"No hay medicina que cure lo que no cura la felicidad.", "La vida no es la que uno vivió."}
+	for _, tc := range []struct {
+		query string
+		fold  bool
+		want  []string
+	}{
+		{"garcia marquez", true, both},
+		{"GARCÍA MÁRQUEZ", true, both},
+		{"García Márquez", false, both[1:]},
+		{"garcia marquez", false, both[:1]},
+		{"vivio", true, both[1:]},
+		{"istanbul kanik", true, []string{"İstanbul'u dinliyorum, gözlerim kapalı."}},
+		{"contarla", false, both[1:]},
+		{"borges", true, nil},
+	} {
+		if got := search(tc.query, tc.fold); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("search %q (fold %v) = %q, want %q", tc.query, tc.fold, got, tc.want)
+		}
+	}
+
+	// Edits and deletions leave the index and are indexed again
+	if _, err := db.Exec("UPDATE quotes SET author = 'Jorge Luis Borges' WHERE author = 'Gabriel Garcia Marquez'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("DELETE FROM quotes WHERE author = 'Orhan Veli Kanık'"); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("borges", true); !reflect.DeepEqual(got, both[:1]) {
+		t.Errorf("after the edit, search borges = %q", got)
+	}
+	if got := search("istanbul", true); got != nil {
+		t.Errorf("the deleted quote was found: %q", got)
+	}
+}
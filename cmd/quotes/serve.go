@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"quotesparser/api"
+	"quotesparser/jobs"
+	"quotesparser/render"
+	"quotesparser/store"
+)
+
+// runServe serves the database as a JSON API, with the portraits and covers
+// cached by quotes portraits and quotes covers. The same binary powers a
+// public API with --mode public, which only mounts the read routes and
+// opens the database read-only, and an internal curation instance with
+// --mode full --keys, whose curators' keys may add, edit and delete quotes
+// and open the dashboard at /admin/ui/, and whose admins' keys may also
+// manage the keys and the schema. In full mode it also runs the jobs queued
+// with quotes jobs submit or POST /jobs, as processes of its own binary.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database to serve")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	portraits := fs.String("portraits", "images/authors", "folder quotes portraits caches into")
+	covers := fs.String("covers", "images/books", "folder quotes covers caches into")
+	triviaMedia := fs.String("trivia-media", "images/trivia", "folder quotes trivia-media caches into")
+	triviaRecent := fs.Int("trivia-recent", 100, "questions a consumer of /trivia/random?mode=balanced is not asked again soon after")
+	triviaSession := fs.Duration("trivia-session", time.Hour, "idle time that ends a balanced trivia session")
+	dailyTZ := fs.String("daily-tz", "Local", "time zone whose midnight starts the day of /daily, e.g. Europe/Istanbul")
+	quoteTZ := fs.String("quote-tz", "Local", "time zone whose midnight changes /quotes/daily and /plain/daily")
+	displayTZ := fs.String("display-tz", "Local", "time zone whose midnight changes the card of /quotes/daily.png, for picture frames and e-ink displays")
+	pushTZ := fs.String("push-tz", "UTC", "time zone of the devices registering for push notifications without one")
+	dailyLangs := fs.String("daily-langs", "", "comma-separated quote languages to generate /daily bundles for ahead, e.g. tr,en")
+	fontPath := fs.String("font", "", "TrueType font for /quotes/random.png and /quotes/daily.png; a serif one installed by default")
+	mode := fs.String("mode", "full", "public to serve reads only, cacheable and without keys; full to also serve the curation routes")
+	keysPath := fs.String("keys", "", `file of "name role secret" lines, role reader, curator or admin, allowed to call the curation and admin routes in full mode; keys made or revoked by admins are saved to it`)
+	shadowURL := fs.String("shadow", "", "mirror part of the GET /quotes searches to the backend at this URL and log where it answers differently")
+	shadowPercent := fs.Float64("shadow-percent", 5, "with --shadow: percentage of searches to mirror")
+	usageTo := fs.String("usage", "db", "count requests per route, key, language and source into: db for the apiUsage table, a file of JSON lines for an analytics sink, or none")
+	usageDays := fs.Int("usage-days", 90, "days of usage the apiUsage table keeps; 0 keeps them all")
+	usageEvery := fs.Duration("usage-interval", time.Minute, "how often the counted requests are saved")
+	workers := fs.Int("jobs", 1, "queued jobs to run at once in full mode; 0 runs none")
+	fs.Parse(args)
+
+	if *mode != "public" && *mode != "full" {
+		return fmt.Errorf("--mode must be public or full, not %q", *mode)
+	}
+	if *mode == "public" && *keysPath != "" {
+		return errors.New("--keys only applies to --mode full")
+	}
+	var keys []api.Key
+	if *keysPath != "" {
+		var err error
+		if keys, err = api.LoadKeys(*keysPath); err != nil {
+			return err
+		}
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+	reads := db
+	if *mode == "public" {
+		// Migrated, the public instance only writes its usage counts
+		if reads, err = sql.Open("sqlite3", store.SQLiteDSN(*dbPath)+"&_query_only=true"); err != nil {
+			return fmt.Errorf("failed to open database: %v", err)
+		}
+		defer reads.Close()
+	}
+
+	handler := api.New(reads, *portraits, *covers)
+	handler.TriviaMedia = *triviaMedia
+	handler.TriviaSessions = &api.TriviaSessions{Recent: *triviaRecent, Idle: *triviaSession}
+	zones := make([]*time.Location, 4)
+	for i, tz := range []string{*dailyTZ, *quoteTZ, *displayTZ, *pushTZ} {
+		if zones[i], err = time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("unknown time zone %q", tz)
+		}
+	}
+	if *pushTZ == "Local" {
+		return errors.New("--push-tz must name a time zone, such as Europe/Istanbul, which devices keep")
+	}
+	handler.Daily = &api.DailyBundles{Location: zones[0]}
+	handler.Zones = api.Zones{Quote: zones[1], Display: zones[2], Push: zones[3]}
+	if *dailyLangs != "" {
+		handler.Daily.Langs = strings.Split(*dailyLangs, ",")
+	}
+	handler.ReadOnly = *mode == "public"
+	handler.Keys = keys
+	handler.KeysFile = *keysPath
+	if *shadowURL != "" {
+		if handler.Shadow, err = api.NewShadow(*shadowURL, *shadowPercent); err != nil {
+			return err
+		}
+		fmt.Fprintf(progress, "Mirroring %g%% of searches to %s\n", *shadowPercent, *shadowURL)
+	}
+	if *mode == "full" && len(keys) == 0 {
+		fmt.Fprintln(progress, "Not serving the curation and admin routes: no --keys")
+	}
+	if handler.Font, err = render.FindFont(*fontPath); err != nil {
+		if *fontPath != "" {
+			return err
+		}
+		slog.Warn("not drawing quote cards", "err", err)
+	}
+
+	var sink api.UsageSink
+	switch *usageTo {
+	case "none":
+	case "db":
+		sink = api.UsageTable{DB: db, Days: *usageDays}
+	default:
+		f, err := os.OpenFile(*usageTo, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", *usageTo, err)
+		}
+		defer f.Close()
+		sink = api.UsageLog{W: f}
+	}
+	usageCtx, stopUsage := context.WithCancel(context.Background())
+	defer stopUsage()
+	flushed := make(chan struct{})
+	if sink != nil {
+		handler.Usage = api.NewTracker(sink)
+		go func() {
+			handler.Usage.Run(usageCtx, *usageEvery)
+			close(flushed)
+		}()
+	} else {
+		close(flushed)
+	}
+
+	srv := &http.Server{
+		Addr:         *addr,
+		Handler:      handler,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	fmt.Fprintf(progress, "Serving %s on %s (%s mode)\n", *dbPath, *addr, *mode)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	served := make(chan error, 1)
+	go func() { served <- srv.ListenAndServe() }()
+	go handler.RunDaily(ctx)
+	ran := make(chan struct{})
+	if *mode == "full" && *workers > 0 {
+		runner := &jobs.Runner{DB: db, Command: jobCommand, Workers: *workers}
+		go func() {
+			runner.Run(ctx, time.Second)
+			close(ran)
+		}()
+	} else {
+		close(ran)
+	}
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Finish the requests in flight, then save their usage
+	stop()
+	fmt.Fprintln(progress, "\nShutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	stopUsage()
+	<-flushed
+	// Running jobs are queued again for the next start
+	<-ran
+	return err
+}
@@ -0,0 +1,1229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"quotesparser/api"
+	"quotesparser/dedup"
+	"quotesparser/imgcache"
+	"quotesparser/jobs"
+	"quotesparser/render"
+	"quotesparser/schema"
+	"quotesparser/store"
+)
+
+func TestServe(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	portraits := filepath.Join(t.TempDir(), "authors")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO authors (id, name) VALUES (1, 'Amos Oz'), (2, 'Sally Rooney')",
+		`INSERT INTO books (id, title, authorId, work, isbn, publishYear, originalTitle, detailsFetchedAt) VALUES
+			(1, 'Bir Aşk ve Karanlık Hikâyesi', 1, '/works/OL5W', '9780151008780', 2002, 'A Tale of Love and Darkness', '2024-01-01T00:00:00Z')`,
+		`INSERT INTO quotes (id, text, author, lang, authorId, bookId, confidence, sourceUrl, sourceFile, fetchedAt, parserVersion) VALUES
+			(1, 'Birinci söz.', 'Amos Oz - Bir Aşk ve Karanlık Hikâyesi', 'tr', 1, 1, NULL,
+				'https://1000kitap.com/kitap/bir-ask-ve-karanlik-hikayesi', '/srv/pages/page1.html', '2024-01-01T00:00:00Z', '1000kitap@1a2b3c4'),
+			(2, 'First quote.', 'Amos Oz', 'en', 1, NULL, 0.6, NULL, NULL, NULL, NULL),
+			(3, 'Second quote.', 'Sally Rooney', 'en', 2, NULL, 0.95, NULL, NULL, NULL, NULL)`,
+		`INSERT INTO trivia (category, question, answer, wrongAnswers, hint) VALUES
+			('science', 'What is H2O?', 'Water', '["Salt","Hydrogen peroxide"]', 'It covers most of the planet')`,
+		`INSERT INTO funFacts (id, text, source, lang, permalink) VALUES ('f1', 'Honey never spoils.', 'djtech.net', 'en',
+			'https://uselessfacts.jsph.pl/api/v2/facts/f1')`,
+		`INSERT INTO authorPortraits (authorId, status, license, artist, attributionRequired, widths, palette, fetchedAt)
+			VALUES (1, 'ok', 'CC BY-SA 3.0', 'Some One', 1, '64,256', '#ffffff,#202020', '2024-01-01T00:00:00Z')`,
+		`INSERT INTO bookCovers (bookId, status, widths, palette, fetchedAt)
+			VALUES (1, 'ok', '96', '#141e5a,#f0c828', '2024-01-01T00:00:00Z')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := schema.ClassifyOrigins(db); err != nil || n != 3 {
+		t.Fatalf("ClassifyOrigins = %d, %v; want 3 classified", n, err)
+	}
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 300, 400)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := imgcache.Save(portraits, 1, img.Bytes(), []int{64, 256}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(api.New(db, portraits, filepath.Join(t.TempDir(), "books")))
+	defer srv.Close()
+
+	get := func(path string, want int, v interface{}) *http.Response {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("GET %s: %v", path, err)
+			}
+		}
+		return resp
+	}
+
+	var quotes []api.Quote
+	get("/quotes?author=Amos+Oz", 200, &quotes)
+	if len(quotes) != 2 || quotes[0].Book != "Bir Aşk ve Karanlık Hikâyesi" || quotes[0].Author != "Amos Oz" || quotes[0].BookID != 1 {
+		t.Errorf("quotes by Amos Oz = %+v", quotes)
+	}
+	get("/quotes?maxChars=12", 200, &quotes)
+	if len(quotes) != 2 || quotes[0].ID != 1 || quotes[1].ID != 2 {
+		t.Errorf("quotes up to 12 characters = %+v, want 1 and 2", quotes)
+	}
+	if c := quotes[0].Citation; c == nil || c.ISBN != "9780151008780" || c.Year != 2002 || c.OriginalTitle != "A Tale of Love and Darkness" {
+		t.Errorf("citation of a quote from a book = %+v", c)
+	}
+	if quotes[1].Citation != nil {
+		t.Errorf("quote from no book cited %+v", quotes[1].Citation)
+	}
+	want := api.Provenance{SourceURL: "https://1000kitap.com/kitap/bir-ask-ve-karanlik-hikayesi", FetchedAt: "2024-01-01T00:00:00Z", ParserVersion: "1000kitap@1a2b3c4"}
+	if p := quotes[0].Provenance; p == nil || *p != want {
+		t.Errorf("provenance of a parsed quote = %+v, want %+v", p, want)
+	}
+	if quotes[1].Provenance != nil {
+		t.Errorf("quote saved before provenance was recorded has %+v", quotes[1].Provenance)
+	}
+	get("/quotes?maxChars=many", 400, nil)
+	// The quote from a book is themed after its cover, the other after the portrait
+	if th := quotes[0].Theme; th == nil || th.Background != "#141e5a" || th.Accent != "#f0c828" || th.Text != "#fafafa" {
+		t.Errorf("theme of a quote from a book = %+v", th)
+	}
+	if th := quotes[1].Theme; th == nil || th.Background != "#ffffff" || th.Text != "#141414" {
+		t.Errorf("theme of a quote by a portrayed author = %+v", th)
+	}
+	get("/quotes?minConfidence=0.85", 200, &quotes)
+	if len(quotes) != 1 || quotes[0].ID != 3 || quotes[0].Confidence != 0.95 {
+		t.Errorf("confident quotes = %+v, want 3", quotes)
+	}
+	get("/quotes?minConfidence=2", 400, nil)
+	get("/quotes?lang=en&limit=1", 200, &quotes)
+	if len(quotes) != 1 || quotes[0].ID != 2 {
+		t.Errorf("first English quote = %+v", quotes)
+	}
+	get("/quotes?lang=fr", 200, &quotes)
+	if quotes == nil || len(quotes) != 0 {
+		t.Errorf("no French quotes should be an empty list, got %+v", quotes)
+	}
+	get("/quotes?limit=x", 400, nil)
+	get("/quotes?origin=book", 200, &quotes)
+	if len(quotes) != 1 || quotes[0].ID != 1 || quotes[0].Origin != "book" {
+		t.Errorf("quotes from books = %+v, want 1", quotes)
+	}
+	get("/quotes?origin=proverb", 200, &quotes)
+	if len(quotes) != 0 {
+		t.Errorf("proverbs = %+v, want none", quotes)
+	}
+	get("/quotes?origin=poem", 400, nil)
+
+	var q api.Quote
+	get("/quotes/random?author=Sally+Rooney", 200, &q)
+	if q.ID != 3 {
+		t.Errorf("random Sally Rooney quote = %+v", q)
+	}
+	// Both of Amos Oz's quotes come up before either repeats
+	var first, second api.Quote
+	get("/quotes/random?author=Amos+Oz", 200, &first)
+	get("/quotes/random?author=Amos+Oz", 200, &second)
+	if first.ID == second.ID || first.ViewCount != 1 || second.ViewCount != 1 {
+		t.Errorf("random quotes repeated: %+v, %+v", first, second)
+	}
+	get("/quotes/random?lang=fr", 404, nil)
+
+	var daily, again api.Quote
+	get("/quotes/daily?date=2024-05-01&tz=Europe/Istanbul", 200, &daily)
+	// The pick is kept a while rather than made again on every request,
+	// even when a quote that would win it was added since
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	winner := ""
+	for i := 0; winner == ""; i++ {
+		if text := fmt.Sprintf("Winning quote %d.", i); store.DailyIndex(day, []string{daily.Text, text}) == 1 {
+			winner = text
+		}
+	}
+	if _, err := db.Exec("INSERT INTO quotes (id, text, lang, textHash) VALUES (99, ?, 'en', ?)", winner, dedup.TextHash(winner)); err != nil {
+		t.Fatal(err)
+	}
+	get("/quotes/daily?date=2024-05-01&tz=Europe/Istanbul", 200, &again)
+	if daily.ID == 0 || daily.ID != again.ID {
+		t.Errorf("quotes of the same day = %d, %d", daily.ID, again.ID)
+	}
+	if _, err := db.Exec("DELETE FROM quotes WHERE id = 99"); err != nil {
+		t.Fatal(err)
+	}
+	get("/quotes/daily?tz=Mars/Olympus", 400, nil)
+	get("/quotes/2", 200, &q)
+	if q.Text != "First quote." {
+		t.Errorf("quote 2 = %+v", q)
+	}
+
+	var authors []api.Author
+	get("/authors", 200, &authors)
+	if len(authors) != 2 || authors[0].Name != "Amos Oz" || authors[0].Quotes != 2 || authors[1].Portrait != nil {
+		t.Fatalf("authors = %+v", authors)
+	}
+	p := authors[0].Portrait
+	if p == nil || p.URL != "/authors/1/portrait" || p.License != "CC BY-SA 3.0" || !p.AttributionRequired {
+		t.Errorf("portrait = %+v", p)
+	}
+
+	resp := get("/authors/1/portrait?width=100", 200, nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("portrait served as %s", ct)
+	}
+	get("/authors/2/portrait", 404, nil)
+	get("/books/1/cover", 404, nil)
+
+	// A portrait pruned from disk is gone even if the row is still there
+	if err := os.RemoveAll(filepath.Join(portraits, "1")); err != nil {
+		t.Fatal(err)
+	}
+	get("/authors/1/portrait", 404, nil)
+
+	var trivia api.Trivia
+	get("/trivia/random?category=science", 200, &trivia)
+	if trivia.Answer != "Water" || strings.Join(trivia.WrongAnswers, ",") != "Salt,Hydrogen peroxide" || trivia.Hint != "It covers most of the planet" {
+		t.Errorf("trivia = %+v", trivia)
+	}
+	get("/trivia/random?category=history", 404, nil)
+
+	var fact api.FunFact
+	get("/funfacts/random", 200, &fact)
+	if fact.Text != "Honey never spoils." || fact.Source != "djtech.net" || fact.Lang != "en" || fact.Permalink == "" {
+		t.Errorf("fun fact = %+v", fact)
+	}
+}
+
+func TestServeCard(t *testing.T) {
+	font, err := render.FindFont("")
+	if errors.Is(err, render.ErrNoFont) {
+		t.Skip("no TrueType font installed")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO quotes (id, text, author, lang) VALUES (7, 'Az olsun, öz olsun.', 'Atasözü', 'tr')"); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := api.New(db, t.TempDir(), t.TempDir())
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	get := func(path string, want int) *http.Response {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Fatalf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+		return resp
+	}
+
+	get("/quotes/random.png", http.StatusServiceUnavailable).Body.Close()
+	handler.Font = font
+
+	resp := get("/quotes/random.png?w=200&h=120&dither=1", 200)
+	img, err := png.Decode(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 200 || img.Bounds().Dy() != 120 || resp.Header.Get("X-Quote-Id") != "7" {
+		t.Errorf("card is %v of quote %q, want 200x120 of quote 7", img.Bounds(), resp.Header.Get("X-Quote-Id"))
+	}
+
+	resp = get("/quotes/random.svg", 200)
+	svg, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(svg), "Atasözü") {
+		t.Errorf("SVG lacks the attribution:\n%s", svg)
+	}
+
+	get("/quotes/random.png?w=100000", http.StatusBadRequest).Body.Close()
+	var views int
+	if err := db.QueryRow("SELECT viewCount FROM quotes WHERE id = 7").Scan(&views); err != nil || views != 2 {
+		t.Errorf("viewCount = %d (%v), want the two cards drawn counted", views, err)
+	}
+	// Fitting the card keeps the other filters
+	get("/quotes/random.png?maxChars=fit&origin=film", http.StatusNotFound).Body.Close()
+	get("/quotes/random.png?maxChars=fit&minConfidence=0.9", http.StatusNotFound).Body.Close()
+
+	// The quote of the day's card lasts until midnight on Kiritimati
+	handler.Zones.Display, _ = time.LoadLocation("Pacific/Kiritimati")
+	resp = get("/quotes/daily.png?w=200&h=120", 200)
+	resp.Body.Close()
+	var age int
+	fmt.Sscanf(resp.Header.Get("Cache-Control"), "public, max-age=%d", &age)
+	now := time.Now().In(handler.Zones.Display)
+	left := 24*3600 - (now.Hour()*3600 + now.Minute()*60 + now.Second())
+	if resp.Header.Get("X-Quote-Id") != "7" || age < left-5 || age > left+5 {
+		t.Errorf("daily card of quote %q cacheable %q, want quote 7 for %ds", resp.Header.Get("X-Quote-Id"), resp.Header.Get("Cache-Control"), left)
+	}
+	resp = get("/quotes/daily.svg?date=2024-05-01", 200)
+	resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "image/svg+xml" || resp.Header.Get("Cache-Control") != "public, max-age=86400" {
+		t.Errorf("past daily card is %q, %q", resp.Header.Get("Content-Type"), resp.Header.Get("Cache-Control"))
+	}
+	get("/quotes/daily.png?tz=Mars/Olympus", http.StatusBadRequest).Body.Close()
+}
+
+func TestServePlain(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO quotes (id, text, author, lang) VALUES (1, 'Az olsun, öz olsun.', 'Atasözü', 'tr'), (2, 'Be here now.', 'Ram Dass - Be Here Now', 'en')"); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(api.New(db, t.TempDir(), t.TempDir()))
+	defer srv.Close()
+
+	get := func(path string, want int) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != want {
+			t.Fatalf("GET %s = %d, want %d: %s", path, resp.StatusCode, want, body)
+		}
+		return resp, string(body)
+	}
+
+	resp, body := get("/plain/random?lang=en", 200)
+	if body != "Be here now.\n— Ram Dass, Be Here Now\n" || resp.Header.Get("Cache-Control") != "no-store" {
+		t.Errorf("GET /plain/random = %q, Cache-Control %q", body, resp.Header.Get("Cache-Control"))
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") || resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("headers = %v", resp.Header)
+	}
+
+	_, body = get("/plain/random.json?lang=tr", 200)
+	var snippet api.Snippet
+	if err := json.Unmarshal([]byte(body), &snippet); err != nil || snippet != (api.Snippet{Text: "Az olsun, öz olsun.", Author: "Atasözü"}) {
+		t.Errorf("GET /plain/random.json = %s (%v)", body, err)
+	}
+
+	resp, _ = get("/plain/daily?tz=Europe/Istanbul", 200)
+	if cc := resp.Header.Get("Cache-Control"); !strings.HasPrefix(cc, "public, max-age=") {
+		t.Errorf("daily Cache-Control = %q, want it cached until midnight", cc)
+	}
+	resp, _ = get("/plain/daily.json?date=2024-01-01", 200)
+	if cc := resp.Header.Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("Cache-Control of a given day = %q, want immutable", cc)
+	}
+
+	if _, body := get("/plain/random?lang=fr", 404); body != "no quote\n" {
+		t.Errorf("missing quote answered %q", body)
+	}
+	get("/plain/daily?tz=Mars/Olympus", 400)
+}
+
+func TestServeModes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO quotes (id, text, author, lang, textHash) VALUES (1, 'Be here now.', 'Ram Dass', 'en', ?)", dedup.TextHash("Be here now.")); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(srv *httptest.Server, method, path, key, body string, want int) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s = %d, want %d: %s", method, path, resp.StatusCode, want, got)
+		}
+		return resp, string(got)
+	}
+
+	t.Run("public", func(t *testing.T) {
+		ro, err := sql.Open("sqlite3", store.SQLiteDSN(dbPath)+"&_query_only=true")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ro.Close()
+		handler := api.New(ro, t.TempDir(), t.TempDir())
+		handler.ReadOnly = true
+		handler.Keys = []api.Key{{Name: "ignored", Secret: "0123456789abcdef"}}
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		resp, _ := do(srv, "GET", "/quotes/1", "", "", 200)
+		if cc := resp.Header.Get("Cache-Control"); !strings.HasPrefix(cc, "public, max-age=3600") {
+			t.Errorf("GET /quotes/1 Cache-Control = %q, want it cached", cc)
+		}
+		for _, path := range []string{"/quotes/random", "/plain/random"} {
+			resp, _ = do(srv, "GET", path, "", "", 200)
+			if cc := resp.Header.Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("GET %s Cache-Control = %q, want no-store", path, cc)
+			}
+		}
+		var views int
+		if err := db.QueryRow("SELECT COALESCE(viewCount, 0) FROM quotes WHERE id = 1").Scan(&views); err != nil || views != 0 {
+			t.Errorf("viewCount = %d (%v), want a read-only server to leave it", views, err)
+		}
+
+		do(srv, "POST", "/quotes", "0123456789abcdef", `{"text": "New."}`, 405)
+		do(srv, "DELETE", "/quotes/1", "0123456789abcdef", "", 405)
+	})
+
+	t.Run("full", func(t *testing.T) {
+		handler := api.New(db, t.TempDir(), t.TempDir())
+		handler.Keys = []api.Key{{Name: "curator", Role: api.Curator, Secret: "0123456789abcdef"}}
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		resp, _ := do(srv, "POST", "/quotes", "", `{"text": "New."}`, 401)
+		if resp.Header.Get("WWW-Authenticate") == "" {
+			t.Error("401 without WWW-Authenticate")
+		}
+		do(srv, "POST", "/quotes", "wrong-key-wrong-key", `{"text": "New."}`, 401)
+		do(srv, "POST", "/quotes", "0123456789abcdef", `{"author": "Nobody"}`, 400)
+
+		resp, body := do(srv, "POST", "/quotes", "0123456789abcdef", `{"text": "Less is more.", "author": "Mies", "book": "Notes", "lang": "en"}`, 201)
+		var q api.Quote
+		if err := json.Unmarshal([]byte(body), &q); err != nil || q.Author != "Mies" || q.Book != "Notes" || q.AuthorID == 0 || q.BookID == 0 || q.Origin != "book" {
+			t.Fatalf("POST /quotes = %s (%v), want it linked to its author and book", body, err)
+		}
+		if resp.Header.Get("Location") != fmt.Sprintf("/quotes/%d", q.ID) {
+			t.Errorf("Location = %q", resp.Header.Get("Location"))
+		}
+		do(srv, "POST", "/quotes", "0123456789abcdef", `{"text": "Less is more."}`, 200)
+
+		path := fmt.Sprintf("/quotes/%d", q.ID)
+		do(srv, "PATCH", path, "0123456789abcdef", `{"text": "Be here now."}`, 409)
+		_, body = do(srv, "PATCH", path, "0123456789abcdef", `{"book": ""}`, 200)
+		var edited api.Quote
+		if err := json.Unmarshal([]byte(body), &edited); err != nil || edited.Text != "Less is more." || edited.Book != "" || edited.BookID != 0 || edited.AuthorID != q.AuthorID {
+			t.Errorf("PATCH %s = %s (%v)", path, body, err)
+		}
+		if edited.Origin != "unknown" {
+			t.Errorf("PATCH %s left origin %q, want the quote without a book classified again", path, edited.Origin)
+		}
+		_, body = do(srv, "PATCH", path, "0123456789abcdef", `{"origin": "speech"}`, 200)
+		if err := json.Unmarshal([]byte(body), &edited); err != nil || edited.Origin != "speech" {
+			t.Errorf("PATCH %s origin = %s (%v)", path, body, err)
+		}
+		do(srv, "PATCH", path, "0123456789abcdef", `{"origin": "poem"}`, 400)
+
+		do(srv, "DELETE", path, "0123456789abcdef", "", 204)
+		do(srv, "GET", path, "", "", 404)
+		do(srv, "DELETE", path, "0123456789abcdef", "", 404)
+	})
+}
+
+func TestServeIdempotency(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	handler := api.New(db, t.TempDir(), t.TempDir())
+	handler.Keys = []api.Key{
+		{Name: "app", Role: api.Curator, Secret: "0123456789abcdef"},
+		{Name: "other", Role: api.Curator, Secret: "fedcba9876543210"},
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	do := func(method, path, key, idempotencyKey, body string, want int) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+		if idempotencyKey != "" {
+			req.Header.Set(api.IdempotencyHeader, idempotencyKey)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s = %d, want %d: %s", method, path, resp.StatusCode, want, got)
+		}
+		return resp, string(got)
+	}
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM quotes").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	const add = `{"text": "Less is more.", "author": "Mies"}`
+	first, body := do("POST", "/quotes", "0123456789abcdef", "add-1", add, 201)
+	var q api.Quote
+	if err := json.Unmarshal([]byte(body), &q); err != nil {
+		t.Fatal(err)
+	}
+	// The retry is answered as the first request was, without adding again
+	resp, again := do("POST", "/quotes", "0123456789abcdef", "add-1", add, 201)
+	if again != body || resp.Header.Get("Location") != first.Header.Get("Location") || resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replay = %s %v, want %s", again, resp.Header, body)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("%d quotes after a retried POST, want 1", n)
+	}
+	do("POST", "/quotes", "0123456789abcdef", "add-1", `{"text": "More is more."}`, 422)
+	// Keys are per caller
+	if resp, _ := do("POST", "/quotes", "fedcba9876543210", "add-1", add, 200); resp.Header.Get("Idempotent-Replayed") != "" {
+		t.Error("another caller's request was answered with a replay")
+	}
+
+	path := fmt.Sprintf("/quotes/%d", q.ID)
+	do("DELETE", path, "0123456789abcdef", "delete-1", "", 204)
+	do("DELETE", path, "0123456789abcdef", "delete-1", "", 204)
+	do("DELETE", path, "0123456789abcdef", "", "", 404)
+
+	// A request still being served is not served twice, unless abandoned
+	sum := sha256.Sum256([]byte("POST /quotes\n" + `{"text": "Busy."}`))
+	if _, err := db.Exec("INSERT INTO idempotencyKeys (caller, key, request, createdAt) VALUES ('app', 'busy', ?, ?)",
+		hex.EncodeToString(sum[:]), time.Now().UTC().Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	do("POST", "/quotes", "0123456789abcdef", "busy", `{"text": "Busy."}`, 409)
+	if _, err := db.Exec("UPDATE idempotencyKeys SET createdAt = ? WHERE key = 'busy'", time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)); err != nil {
+		t.Fatal(err)
+	}
+	do("POST", "/quotes", "0123456789abcdef", "busy", `{"text": "Busy."}`, 201)
+
+	// Requests failing authentication keep nothing
+	do("POST", "/quotes", "not-a-key", "anonymous", add, 401)
+	var kept int
+	if err := db.QueryRow("SELECT COUNT(*) FROM idempotencyKeys WHERE key = 'anonymous'").Scan(&kept); err != nil || kept != 0 {
+		t.Errorf("kept %d answers to unauthenticated requests (%v)", kept, err)
+	}
+
+	// Of requests racing under one key, one is served and the others get a
+	// 409 or its answer
+	const race = `{"text": "Only once."}`
+	codes := make(chan int, 8)
+	var wg sync.WaitGroup
+	for range cap(codes) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("POST", srv.URL+"/quotes", strings.NewReader(race))
+			req.Header.Set("Authorization", "Bearer 0123456789abcdef")
+			req.Header.Set(api.IdempotencyHeader, "race")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				codes <- 0
+				return
+			}
+			resp.Body.Close()
+			codes <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != 201 && code != 409 {
+			t.Errorf("racing POST = %d, want 201 or 409", code)
+		}
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM quotes WHERE text = 'Only once.'").Scan(&n); err != nil || n != 1 {
+		t.Errorf("racing POSTs added %d quotes (%v), want 1", n, err)
+	}
+}
+
+func TestServeRoles(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO quotes (id, text, lang, textHash) VALUES (1, 'Be here now.', 'en', ?)", dedup.TextHash("Be here now.")); err != nil {
+		t.Fatal(err)
+	}
+
+	keysPath := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(keysPath, []byte("# keys\nold 0123456789oldold\nreader reader 0123456789reader\nadmin admin 0123456789admin0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := api.LoadKeys(keysPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []api.Key{
+		{Name: "old", Role: api.Curator, Secret: "0123456789oldold"},
+		{Name: "reader", Role: api.Reader, Secret: "0123456789reader"},
+		{Name: "admin", Role: api.Admin, Secret: "0123456789admin0"},
+	}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("LoadKeys = %v, want %v", keys, want)
+	}
+	handler := api.New(db, t.TempDir(), t.TempDir())
+	handler.Keys = keys
+	handler.KeysFile = keysPath
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	do := func(method, path, key, body string, want int) string {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s = %d, want %d: %s", method, path, resp.StatusCode, want, got)
+		}
+		return string(got)
+	}
+
+	// Readers only query, like anonymous callers
+	do("GET", "/quotes/1", "0123456789reader", "", 200)
+	do("PATCH", "/quotes/1", "0123456789reader", `{"lang": "tr"}`, 403)
+	do("GET", "/admin/keys", "0123456789reader", "", 403)
+
+	// Curators also curate, but do not manage keys or the schema
+	do("PATCH", "/quotes/1", "0123456789oldold", `{"lang": "tr"}`, 200)
+	do("GET", "/admin/keys", "0123456789oldold", "", 403)
+	do("POST", "/admin/schema/migrate", "0123456789oldold", "", 403)
+	do("GET", "/admin/schema", "", "", 401)
+
+	// Admins do all
+	do("PATCH", "/quotes/1", "0123456789admin0", `{"lang": "en"}`, 200)
+	if body := do("GET", "/admin/keys", "0123456789admin0", "", 200); strings.Contains(body, "0123456789") ||
+		!strings.Contains(body, `{"name":"reader","role":"reader"}`) {
+		t.Errorf("GET /admin/keys = %s, want names and roles without secrets", body)
+	}
+	var sc api.Schema
+	if err := json.Unmarshal([]byte(do("GET", "/admin/schema", "0123456789admin0", "", 200)), &sc); err != nil ||
+		sc.Version == 0 || sc.Version != sc.Latest || len(sc.Pending) != 0 {
+		t.Errorf("GET /admin/schema = %+v (%v), want migrated", sc, err)
+	}
+	do("POST", "/admin/schema/migrate", "0123456789admin0", "", 200)
+
+	// Only admins run commands on the server, which curators follow and stop
+	do("POST", "/jobs", "0123456789oldold", `{"command": "crawl"}`, 403)
+	do("POST", "/jobs", "0123456789admin0", `{"command": "serve"}`, 400)
+	var j jobs.Job
+	if err := json.Unmarshal([]byte(do("POST", "/jobs", "0123456789admin0", `{"command": "crawl", "args": ["--limit", "5"]}`, 201)), &j); err != nil ||
+		j.Status != jobs.Queued || j.SubmittedBy != "admin" || j.Line() != "quotes crawl --limit 5" {
+		t.Fatalf("POST /jobs = %+v (%v), want a queued crawl", j, err)
+	}
+	do("GET", "/jobs", "0123456789reader", "", 403)
+	do("GET", "/jobs?status=lost", "0123456789oldold", "", 400)
+	if body := do("GET", "/jobs?status=queued", "0123456789oldold", "", 200); !strings.Contains(body, `"command":"crawl"`) {
+		t.Errorf("GET /jobs = %s, want the queued crawl", body)
+	}
+	do("GET", fmt.Sprintf("/jobs/%d", j.ID), "0123456789oldold", "", 200)
+	do("GET", "/jobs/99", "0123456789oldold", "", 404)
+	if body := do("POST", fmt.Sprintf("/jobs/%d/cancel", j.ID), "0123456789oldold", "", 200); !strings.Contains(body, `"status":"cancelled"`) {
+		t.Errorf("POST /jobs/%d/cancel = %s, want it cancelled", j.ID, body)
+	}
+	do("POST", fmt.Sprintf("/jobs/%d/cancel", j.ID), "0123456789oldold", "", 409)
+
+	do("POST", "/admin/keys", "0123456789admin0", `{"name": "bad name", "role": "reader"}`, 400)
+	do("POST", "/admin/keys", "0123456789admin0", `{"name": "new", "role": "owner"}`, 400)
+	do("POST", "/admin/keys", "0123456789admin0", `{"name": "reader", "role": "reader"}`, 409)
+	var made api.KeyInfo
+	if err := json.Unmarshal([]byte(do("POST", "/admin/keys", "0123456789admin0", `{"name": "new", "role": "curator"}`, 201)), &made); err != nil ||
+		made.Role != api.Curator || len(made.Secret) < 16 {
+		t.Fatalf("POST /admin/keys = %+v (%v), want a curator key with its secret", made, err)
+	}
+	do("PATCH", "/quotes/1", made.Secret, `{"lang": "tr"}`, 200)
+
+	do("DELETE", "/admin/keys/old", "0123456789admin0", "", 204)
+	do("DELETE", "/admin/keys/old", "0123456789admin0", "", 404)
+	do("PATCH", "/quotes/1", "0123456789oldold", `{"lang": "en"}`, 401)
+	do("DELETE", "/admin/keys/admin", "0123456789admin0", "", 409)
+
+	saved, err := api.LoadKeys(keysPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []api.Key{
+		{Name: "reader", Role: api.Reader, Secret: "0123456789reader"},
+		{Name: "admin", Role: api.Admin, Secret: "0123456789admin0"},
+		{Name: "new", Role: api.Curator, Secret: made.Secret},
+	}; !reflect.DeepEqual(saved, want) {
+		t.Errorf("saved keys = %v, want %v", saved, want)
+	}
+}
+
+func TestServeDashboard(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT OR IGNORE INTO sources (name) VALUES ('goodreads')",
+		`INSERT INTO quotes (id, text, lang, textHash, sourceId, confidence, needsEnrichment, viewCount, sourceFile, parserVersion) VALUES
+			(1, 'The only way out is through.', 'en', 'h1', @src, 0.9, 0, 2, 'pages/page1.html', 'goodreads@1a2b3c4'),
+			(2, 'The only way out is always through.', 'en', 'h2', @src, 0.8, 0, 3, 'pages/page2.html', 'goodreads@1a2b3c4'),
+			(3, 'Needs an author.', 'en', 'h3', @src, NULL, 1, 0, NULL, NULL),
+			(4, 'Read with little confidence.', 'en', 'h4', @src, 0.2, 0, 0, NULL, NULL)`,
+	} {
+		if _, err := db.Exec(strings.ReplaceAll(stmt, "@src", "(SELECT id FROM sources WHERE name = 'goodreads')")); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	handler := api.New(db, t.TempDir(), t.TempDir())
+	handler.Keys = []api.Key{
+		{Name: "curator", Role: api.Curator, Secret: "0123456789abcdef"},
+		{Name: "reader", Role: api.Reader, Secret: "0123456789reader"},
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	do := func(method, path, password, site, form string, want int) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(form))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password != "" {
+			req.SetBasicAuth("me", password)
+		}
+		if site != "" {
+			req.Header.Set("Sec-Fetch-Site", site)
+		}
+		if form != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != want {
+			t.Fatalf("%s %s = %d, want %d: %s", method, path, resp.StatusCode, want, got)
+		}
+		return resp, string(got)
+	}
+	count := func(where string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM quotes WHERE " + where).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// Browsers are asked for a key, which must be a curator's
+	if resp, _ := do("GET", "/admin/ui/", "", "", "", 401); !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Basic ") {
+		t.Errorf("dashboard asked for a key with %q", resp.Header.Get("WWW-Authenticate"))
+	}
+	do("GET", "/admin/ui/", "0123456789reader", "", "", 403)
+	if _, page := do("GET", "/admin/ui/", "0123456789abcdef", "", "", 200); !strings.Contains(page, "goodreads@1a2b3c4") || !strings.Contains(page, "page2.html") {
+		t.Errorf("imports page misses the parsed pages:\n%s", page)
+	}
+	if _, page := do("GET", "/admin/ui/sources", "0123456789abcdef", "", "", 200); !strings.Contains(page, "goodreads") {
+		t.Errorf("sources page misses goodreads:\n%s", page)
+	}
+
+	_, page := do("GET", "/admin/ui/queue", "0123456789abcdef", "", "", 200)
+	if !strings.Contains(page, "Needs an author.") || !strings.Contains(page, "Read with little confidence.") || strings.Contains(page, "The only way out") {
+		t.Errorf("queue = %s, want the flagged and the unsure quote", page)
+	}
+	// Forms posted from another site are refused
+	do("POST", "/admin/ui/queue/4/approve", "0123456789abcdef", "cross-site", "", 403)
+	if resp, _ := do("POST", "/admin/ui/queue/4/approve", "0123456789abcdef", "same-origin", "", 303); resp.Header.Get("Location") != "/admin/ui/queue" {
+		t.Errorf("approving redirected to %q", resp.Header.Get("Location"))
+	}
+	if count("id = 4 AND confidence = 1") != 1 {
+		t.Error("approved quote is not certain")
+	}
+	do("POST", "/admin/ui/queue/3/delete", "0123456789abcdef", "same-origin", "", 303)
+	do("POST", "/admin/ui/queue/3/delete", "0123456789abcdef", "same-origin", "", 404)
+	if _, page := do("GET", "/admin/ui/queue", "0123456789abcdef", "", "", 200); !strings.Contains(page, "Nothing to moderate") {
+		t.Errorf("queue after moderating = %s", page)
+	}
+
+	_, page = do("GET", "/admin/ui/duplicates?threshold=0.6", "0123456789abcdef", "", "", 200)
+	if !strings.Contains(page, `name="keep" value="1"`) || !strings.Contains(page, `name="drop" value="2"`) {
+		t.Fatalf("duplicates page misses quotes 1 and 2:\n%s", page)
+	}
+	do("POST", "/admin/ui/duplicates/merge", "0123456789abcdef", "same-origin", "keep=9&drop=2", 404)
+	do("POST", "/admin/ui/duplicates/merge", "0123456789abcdef", "same-origin", "keep=1&drop=2", 303)
+	if count("id = 2") != 0 || count("id = 1 AND viewCount = 5") != 1 {
+		t.Error("merge did not fold quote 2 into quote 1")
+	}
+
+	j, err := jobs.Submit(db, "crawl", []string{"--limit", "5"}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, page := do("GET", "/admin/ui/jobs", "0123456789abcdef", "", "", 200); !strings.Contains(page, "quotes crawl --limit 5") || !strings.Contains(page, "location.reload") {
+		t.Errorf("jobs page misses the queued crawl:\n%s", page)
+	}
+	do("POST", fmt.Sprintf("/admin/ui/jobs/%d/cancel", j.ID), "0123456789abcdef", "same-origin", "", 303)
+	if _, page := do("GET", "/admin/ui/jobs", "0123456789abcdef", "", "", 200); !strings.Contains(page, "cancelled") || strings.Contains(page, "location.reload") {
+		t.Errorf("jobs page after cancelling = %s", page)
+	}
+}
+
+func TestServeShadow(t *testing.T) {
+	open := func(inserts string) *sql.DB {
+		t.Helper()
+		db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if err := migrateDB(db); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(inserts); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	primary := open("INSERT INTO quotes (id, text, lang) VALUES (1, 'One.', 'en'), (2, 'Two.', 'en'), (3, 'Three.', 'en')")
+	candidate := open("INSERT INTO quotes (id, text, lang) VALUES (1, 'One.', 'en'), (3, 'Three.', 'en'), (4, 'Four.', 'en')")
+	shadow := httptest.NewServer(api.New(candidate, t.TempDir(), t.TempDir()))
+	defer shadow.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	handler := api.New(primary, t.TempDir(), t.TempDir())
+	var err error
+	if handler.Shadow, err = api.NewShadow(shadow.URL, 100); err != nil {
+		t.Fatal(err)
+	}
+	logged := make(chan string, 1)
+	handler.Shadow.Logf = func(format string, args ...interface{}) { logged <- fmt.Sprintf(format, args...) }
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	get := func(path string) string {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var quotes []api.Quote
+		if err := json.NewDecoder(resp.Body).Decode(&quotes); err != nil || len(quotes) != 3 || quotes[1].ID != 2 {
+			t.Fatalf("GET %s = %v (%v), want the primary's quotes", path, quotes, err)
+		}
+		select {
+		case line := <-logged:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatalf("GET %s: nothing logged", path)
+			return ""
+		}
+	}
+
+	if line := get("/quotes?lang=en"); !strings.Contains(line, "GET /quotes?lang=en") || !strings.Contains(line, "missing [2], extra [4]") {
+		t.Errorf("logged %q, want the missing and extra IDs", line)
+	}
+	handler.Shadow.URL = broken.URL
+	if line := get("/quotes"); !strings.Contains(line, "status 200, shadow answered 500") {
+		t.Errorf("logged %q, want the status difference", line)
+	}
+
+	if _, err := api.NewShadow("localhost:9200", 5); err == nil {
+		t.Error("NewShadow accepted an address without a scheme")
+	}
+}
+
+func TestServeUsage(t *testing.T) {
+	db, err := openDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO quotes (id, text, lang, sourceId) VALUES
+		(1, 'Bir söz.', 'tr', (SELECT id FROM sources WHERE name = '1000kitap')),
+		(2, 'A quote.', 'en', NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	handler := api.New(db, t.TempDir(), t.TempDir())
+	handler.Keys = []api.Key{{Name: "curator", Role: api.Curator, Secret: "0123456789abcdef"}}
+	handler.Usage = api.NewTracker(api.UsageTable{DB: db, Days: 90})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	for _, path := range []string{"/quotes/1", "/quotes/1", "/quotes/2", "/quotes/99", "/quotes?lang=en", "/nowhere"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	req, _ := http.NewRequest("DELETE", srv.URL+"/quotes/2", nil)
+	req.Header.Set("X-API-Key", "0123456789abcdef")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if err := handler.Usage.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// A second flush with nothing counted adds nothing
+	if err := handler.Usage.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	for by, want := range map[string][]usageRow{
+		"endpoint": {{"GET /quotes/{id}", 4, 1}, {"DELETE /quotes/{id}", 1, 0}, {"GET /quotes", 1, 0}},
+		"lang":     {{"", 2, 1}, {"en", 2, 0}, {"tr", 2, 0}},
+		"source":   {{"", 4, 1}, {"1000kitap", 2, 0}},
+		"key":      {{"", 5, 1}, {"curator", 1, 0}},
+		"day":      {{today, 6, 1}},
+	} {
+		got, err := usageBreakdown(db, by, today, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("usage by %s = %v, want %v", by, got, want)
+		}
+	}
+
+	var lines bytes.Buffer
+	tracker := api.NewTracker(api.UsageLog{W: &lines})
+	handler.Usage = tracker
+	resp, err = http.Get(srv.URL + "/quotes/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err := tracker.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	var u api.Usage
+	if err := json.Unmarshal(lines.Bytes(), &u); err != nil || u != (api.Usage{Day: today, Endpoint: "GET /quotes/{id}", Lang: "tr", Source: "1000kitap", Requests: 1}) {
+		t.Errorf("usage log = %s (%v)", lines.String(), err)
+	}
+}
+
+func TestServeBalancedTrivia(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO trivia (category, question, answer, difficulty) VALUES
+		('geo', 'Capital of France?', 'Paris', 'easy'),
+		('geo', 'Capital of Italy?', 'Rome', 'easy'),
+		('geo', 'Capital of Spain?', 'Madrid', 'easy'),
+		('geo', 'Capital of Bhutan?', 'Thimphu', 'hard'),
+		('geo', 'Capital of Palau?', 'Ngerulmud', 'hard'),
+		('geo', 'Capital of Nauru?', 'Yaren', 'hard'),
+		('science', 'What is H2O?', 'Water', 'medium')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(api.New(db, "", ""))
+	defer srv.Close()
+	get := func(path string, want int) api.Trivia {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+		var q api.Trivia
+		json.NewDecoder(resp.Body).Decode(&q)
+		return q
+	}
+
+	// Uniform picks would mostly be geography; a balanced session alternates
+	// categories, and difficulties within geography, without repeating one
+	categories := map[string]int{}
+	difficulties := map[string]int{}
+	asked := map[int64]bool{}
+	for i := 0; i < 8; i++ {
+		q := get("/trivia/random?mode=balanced&consumer=quiz-1", 200)
+		categories[q.Category]++
+		if q.Category == "geo" {
+			difficulties[q.Difficulty]++
+			if asked[q.ID] {
+				t.Errorf("geography question %d asked twice", q.ID)
+			}
+			asked[q.ID] = true
+		}
+		if i == 1 && (categories["geo"] != 1 || categories["science"] != 1) {
+			t.Errorf("first two questions were %v, want one per category", categories)
+		}
+	}
+	if categories["geo"] != 4 || categories["science"] != 4 {
+		t.Errorf("categories served %v, want 4 each", categories)
+	}
+	if difficulties["easy"] != 2 || difficulties["hard"] != 2 {
+		t.Errorf("geography difficulties served %v, want 2 each", difficulties)
+	}
+
+	// Another consumer starts their own session
+	if q := get("/trivia/random?mode=balanced&consumer=quiz-2&category=geo", 200); q.Category != "geo" {
+		t.Errorf("balanced pick in geo = %+v", q)
+	}
+	get("/trivia/random?mode=balanced&category=history", 404)
+	get("/trivia/random?mode=fair", 400)
+}
+
+func TestServeDaily(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO quotes (text, author, lang) VALUES ('Birinci söz.', 'Amos Oz', 'tr'), ('First quote.', 'Amos Oz', 'en'),
+			('Second quote.', 'Sally Rooney', 'en')`,
+		`INSERT INTO funFacts (id, text, lang) VALUES ('f1', 'Honey never spoils.', 'en'), ('f2', 'Bal bozulmaz.', 'tr')`,
+		`INSERT INTO trivia (category, question, answer, wrongAnswers) VALUES
+			('geo', 'Capital of France?', 'Paris', '["Lyon","Nice","Lille"]'),
+			('geo', 'Capital of Italy?', 'Rome', NULL), ('geo', 'Capital of Spain?', 'Madrid', NULL),
+			('geo', 'Capital of Peru?', 'Lima', NULL)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := api.New(db, "", "")
+	handler.Daily = &api.DailyBundles{Location: time.UTC, Langs: []string{"tr"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		handler.RunDaily(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	get := func(path string, want int) (api.Bundle, http.Header) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+		var b api.Bundle
+		json.NewDecoder(resp.Body).Decode(&b)
+		return b, resp.Header
+	}
+
+	b, header := get("/daily?lang=tr", 200)
+	if b.Date != time.Now().UTC().Format(time.DateOnly) || b.Quote == nil || b.Quote.Text != "Birinci söz." {
+		t.Fatalf("bundle = %+v", b)
+	}
+	if b.FunFact == nil || b.FunFact.Text != "Bal bozulmaz." {
+		t.Errorf("fun fact = %+v", b.FunFact)
+	}
+	if !strings.HasPrefix(header.Get("Cache-Control"), "public, max-age=") {
+		t.Errorf("Cache-Control = %q", header.Get("Cache-Control"))
+	}
+	if b.Trivia == nil || len(b.Trivia.Choices) == 0 {
+		t.Fatalf("trivia = %+v", b.Trivia)
+	}
+	if b.Trivia.Question == "Capital of France?" && len(b.Trivia.Choices) != 4 {
+		t.Errorf("choices = %v, want the answer and the 3 imported wrong answers", b.Trivia.Choices)
+	}
+	if !slices.Contains(b.Trivia.Choices, b.Trivia.Answer) {
+		t.Errorf("choices %v lack the answer %q", b.Trivia.Choices, b.Trivia.Answer)
+	}
+
+	// The same all day, and for every day pickable again later
+	again, _ := get("/daily?lang=tr", 200)
+	if again.Quote.ID != b.Quote.ID || again.Trivia.ID != b.Trivia.ID || strings.Join(again.Trivia.Choices, ",") != strings.Join(b.Trivia.Choices, ",") {
+		t.Errorf("bundle changed: %+v, then %+v", b, again)
+	}
+	past, _ := get("/daily?date=2024-03-01&tz=Europe/Istanbul", 200)
+	if repeat, _ := get("/daily?date=2024-03-01&tz=Europe/Istanbul", 200); past.Quote.ID != repeat.Quote.ID || past.Date != "2024-03-01" {
+		t.Errorf("past bundle = %+v, then %+v", past, repeat)
+	}
+	get("/daily?date=yesterday", 400)
+	if b, _ := get("/daily?lang=fr", 200); b.Quote != nil || b.FunFact != nil || b.Trivia == nil {
+		t.Errorf("bundle in French = %+v, want only trivia", b)
+	}
+}
+
+func TestServeSync(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	exec := func(stmts ...string) {
+		t.Helper()
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	exec(`INSERT INTO quotes (id, text, author, lang) VALUES (1, 'First quote.', 'Amos Oz', 'en'), (2, 'Second quote.', 'Sally Rooney', 'en')`,
+		`INSERT INTO funFacts (id, text) VALUES ('f1', 'Honey never spoils.')`,
+		`INSERT INTO trivia (id, category, question, answer) VALUES (1, 'Geography', 'Capital of France?', 'Paris')`)
+
+	srv := httptest.NewServer(api.New(db, "", ""))
+	defer srv.Close()
+	get := func(path string, want int) api.Sync {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("GET %s = %d, want %d", path, resp.StatusCode, want)
+		}
+		if want == 200 && !resp.Uncompressed {
+			t.Errorf("GET %s was not gzipped", path)
+		}
+		var s api.Sync
+		json.NewDecoder(resp.Body).Decode(&s)
+		return s
+	}
+
+	snap := get("/sync", 200)
+	if !snap.Snapshot || len(snap.Quotes) != 2 || len(snap.Trivia) != 1 || len(snap.FunFacts) != 1 || snap.Token == "" {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	if d := get("/sync?token="+snap.Token, 200); d.Snapshot || len(d.Quotes)+len(d.Trivia)+len(d.FunFacts) != 0 || d.Token != snap.Token {
+		t.Errorf("delta without changes = %+v", d)
+	}
+
+	// Views are not changes; edits, additions and deletions are
+	exec(`UPDATE quotes SET viewCount = viewCount + 1`,
+		`UPDATE quotes SET text = 'First quote, edited.' WHERE id = 1`,
+		`DELETE FROM quotes WHERE id = 2`,
+		`INSERT INTO trivia (id, category, question, answer) VALUES (2, 'Geography', 'Capital of Peru?', 'Lima')`,
+		`DELETE FROM funFacts WHERE id = 'f1'`)
+	d := get("/sync?token="+snap.Token, 200)
+	if len(d.Quotes) != 1 || d.Quotes[0].Text != "First quote, edited." || len(d.Trivia) != 1 || d.Trivia[0].Answer != "Lima" || d.More {
+		t.Fatalf("delta = %+v", d)
+	}
+	if d.Deleted == nil || !reflect.DeepEqual(d.Deleted.Quotes, []int64{2}) || !reflect.DeepEqual(d.Deleted.FunFacts, []string{"f1"}) || len(d.Deleted.Trivia) != 0 {
+		t.Errorf("deleted = %+v", d.Deleted)
+	}
+	if again := get("/sync?token="+d.Token, 200); len(again.Quotes)+len(again.Trivia)+len(again.Deleted.Quotes) != 0 {
+		t.Errorf("delta after catching up = %+v", again)
+	}
+	get("/sync?token=abc", 400)
+	get("/sync?token=999", 400)
+}
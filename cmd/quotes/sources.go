@@ -0,0 +1,11 @@
+package main
+
+// Sites served by the generic download, parse and import commands register
+// themselves with package source when imported here as _ "quotesparser/<site>".
+// quotes new-source adds the import for every site it scaffolds.
+
+import (
+	_ "quotesparser/goodreads"
+	_ "quotesparser/uselessfacts"
+	_ "quotesparser/wikiquote"
+)
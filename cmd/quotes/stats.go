@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func runStats(args []string) error {
+	if len(args) < 1 || args[0] != "api" {
+		return fmt.Errorf("usage: quotes stats api [flags]")
+	}
+	return runStatsAPI(args[1:])
+}
+
+// usageColumns are the apiUsage columns quotes stats api breaks usage down by
+var usageColumns = map[string]string{
+	"endpoint": "endpoint",
+	"key":      "apiKey",
+	"lang":     "lang",
+	"source":   "source",
+	"day":      "day",
+}
+
+// usageRow is one line of a breakdown
+type usageRow struct {
+	Value    string
+	Requests int
+	Errors   int
+}
+
+// runStatsAPI summarizes the requests quotes serve counted into apiUsage
+func runStatsAPI(args []string) error {
+	fs := flag.NewFlagSet("stats api", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database quotes serve counts usage into")
+	days := fs.Int("days", 30, "summarize this many days, today included")
+	by := fs.String("by", "endpoint,lang,source,key", "breakdowns to print, of endpoint, key, lang, source and day")
+	top := fs.Int("top", 10, "rows per breakdown; 0 for all")
+	fs.Parse(args)
+
+	if *days < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+	var breakdowns []string
+	for _, b := range strings.Split(*by, ",") {
+		b = strings.TrimSpace(b)
+		if _, ok := usageColumns[b]; !ok {
+			return fmt.Errorf("unknown breakdown %q (want endpoint, key, lang, source or day)", b)
+		}
+		breakdowns = append(breakdowns, b)
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -*days+1).Format("2006-01-02")
+	var requests, errors int
+	if err := db.QueryRow("SELECT COALESCE(SUM(requests), 0), COALESCE(SUM(errors), 0) FROM apiUsage WHERE day >= ?", since).Scan(&requests, &errors); err != nil {
+		return fmt.Errorf("failed to read usage: %v", err)
+	}
+	fmt.Printf("API usage since %s: %d requests (%d errors)\n", since, requests, errors)
+	if requests == 0 {
+		return nil
+	}
+
+	for _, b := range breakdowns {
+		rows, err := usageBreakdown(db, b, since, *top)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nBy %s:\n", b)
+		for _, r := range rows {
+			fmt.Printf("  %-32s %9d %6.1f%%", usageLabel(b, r.Value), r.Requests, 100*float64(r.Requests)/float64(requests))
+			if r.Errors > 0 {
+				fmt.Printf("  (%d errors)", r.Errors)
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// usageBreakdown sums the usage since the given day per value of a
+// breakdown, busiest first, or in date order for days
+func usageBreakdown(db *sql.DB, by, since string, top int) ([]usageRow, error) {
+	column := usageColumns[by]
+	order := "SUM(requests) DESC, " + column
+	if by == "day" {
+		order = column
+	}
+	query := "SELECT " + column + ", SUM(requests), SUM(errors) FROM apiUsage WHERE day >= ? GROUP BY " + column + " ORDER BY " + order
+	args := []interface{}{since}
+	if top > 0 {
+		query += " LIMIT ?"
+		args = append(args, top)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage: %v", err)
+	}
+	defer rows.Close()
+	var breakdown []usageRow
+	for rows.Next() {
+		var r usageRow
+		if err := rows.Scan(&r.Value, &r.Requests, &r.Errors); err != nil {
+			return nil, fmt.Errorf("failed to read usage: %v", err)
+		}
+		breakdown = append(breakdown, r)
+	}
+	return breakdown, rows.Err()
+}
+
+// usageLabel names the empty values of a breakdown
+func usageLabel(by, value string) string {
+	switch {
+	case value != "":
+		return value
+	case by == "key":
+		return "(anonymous)"
+	default:
+		return "(none)"
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"quotesparser/schema"
+)
+
+func runTrivia(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: quotes trivia categories [flags]")
+	}
+	switch args[0] {
+	case "categories":
+		return runTriviaCategories(args[1:])
+	default:
+		return fmt.Errorf("unknown trivia action %q (categories)", args[0])
+	}
+}
+
+// runTriviaCategories reports how many questions each trivia category has
+// and the spellings taken for it. --alias adds spellings of a category and
+// files the questions under them again, as --normalize does with the
+// aliases already known.
+func runTriviaCategories(args []string) error {
+	fs := flag.NewFlagSet("trivia categories", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose trivia categories to report")
+	var aliases stringList
+	fs.Var(&aliases, "alias", `a spelling of a category, as "Sci=Science & Nature"; repeatable`)
+	normalize := fs.Bool("normalize", false, "file every question under the canonical name of its category")
+	fs.Parse(args)
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	if len(aliases) > 0 || *normalize {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		for _, a := range aliases {
+			alias, name, ok := strings.Cut(a, "=")
+			if !ok {
+				tx.Rollback()
+				return fmt.Errorf("--alias %q: want spelling=category", a)
+			}
+			if err := schema.AddTriviaCategoryAlias(tx, alias, name); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		renamed, err := schema.NormalizeTriviaCategories(tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		fmt.Fprintf(progress, "✓ Filed %d questions under the canonical name of their category\n\n", renamed)
+	}
+
+	categories, err := schema.TriviaCategories(db)
+	if err != nil {
+		return err
+	}
+	width, total := len("Category"), 0
+	for _, c := range categories {
+		n := len([]rune(c.Name))
+		if !c.Canonical {
+			n += len(" *")
+		}
+		width = max(width, n)
+		total += c.Questions
+	}
+	fmt.Printf("%-*s  %9s  %s\n", width, "Category", "Questions", "Aliases")
+	unnormalized := 0
+	for _, c := range categories {
+		name := c.Name
+		if !c.Canonical {
+			unnormalized += c.Questions
+			name += " *"
+		}
+		fmt.Printf("%-*s  %9d  %s\n", width, name, c.Questions, strings.Join(c.Aliases, ", "))
+	}
+	fmt.Printf("\n✓ %d questions in %d categories\n", total, len(categories))
+	if unnormalized > 0 {
+		fmt.Printf("  * %d questions are not under a canonical category; run with --normalize\n", unnormalized)
+	}
+	return nil
+}
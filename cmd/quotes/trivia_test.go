@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"quotesparser/schema"
+	"quotesparser/store"
+)
+
+func TestTriviaCategories(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	// Saved before categories were normalized
+	_, err = db.Exec(`INSERT INTO trivia (category, question, answer) VALUES
+		('Sci', 'What is H2O?', 'Water'), ('SCIENCE ', 'What is NaCl?', 'Salt'),
+		(' Geography', 'Capital of France?', 'Paris'), ('Pop', 'Who sang Thriller?', 'Michael Jackson')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runTriviaCategories([]string{"--db", dbPath, "--alias", "Pop=Music"}); err != nil {
+		t.Fatal(err)
+	}
+	categories, err := schema.TriviaCategories(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for _, c := range categories {
+		if !c.Canonical {
+			t.Errorf("%q left unnormalized", c.Name)
+		}
+		got[c.Name] = c.Questions
+	}
+	if len(got) != 3 || got["Science & Nature"] != 2 || got["Geography"] != 1 || got["Music"] != 1 {
+		t.Errorf("categories = %v", got)
+	}
+	var unlinked int
+	if err := db.QueryRow("SELECT COUNT(*) FROM trivia WHERE categoryId IS NULL").Scan(&unlinked); err != nil || unlinked != 0 {
+		t.Errorf("%d questions without a categoryId (%v)", unlinked, err)
+	}
+
+	// New questions are filed under the canonical names as they are saved,
+	// and read back by any spelling
+	s := store.NewSQLite(db, store.Options{})
+	if _, err := s.SaveTrivia([]store.Trivia{{Category: "pop", Question: "Who sang Purple Rain?", Answer: "Prince"}}); err != nil {
+		t.Fatal(err)
+	}
+	music, err := s.Trivia("POP", 0)
+	if err != nil || len(music) != 2 || music[1].Category != "Music" {
+		t.Errorf("Trivia(POP) = %+v, %v", music, err)
+	}
+
+	if err := runTriviaCategories([]string{"--db", dbPath, "--alias", "Music"}); err == nil {
+		t.Error("accepted an alias without a category")
+	}
+}
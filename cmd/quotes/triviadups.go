@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"quotesparser/schema"
+)
+
+// runTriviaDups finds trivia questions asking the same thing in different
+// words and resolves each cluster to one question. It only reports unless
+// told to --merge, keeping the most served question of each cluster; to pick
+// others, write the clusters with --out, change their "keep" ids (0 keeps
+// every question of a cluster) and apply the file with --resolve.
+func runTriviaDups(args []string) error {
+	fs := flag.NewFlagSet("trivia-dups", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose trivia to check")
+	threshold := fs.Float64("threshold", 0.7, "share of the words two questions ask about, from 0 to 1, at which questions with the same answer are duplicates")
+	show := fs.Int("show", 10, "clusters to print (0 = none, -1 = all)")
+	out := fs.String("out", "", "write the clusters to this JSON file to pick the questions to keep")
+	resolve := fs.String("resolve", "", "merge the clusters of a JSON file written by --out into the questions they keep")
+	merge := fs.Bool("merge", false, "merge every cluster into its most served question")
+	fs.Parse(args)
+
+	if *threshold <= 0 || *threshold > 1 {
+		return fmt.Errorf("--threshold must be above 0 and at most 1")
+	}
+	if *resolve != "" && (*merge || *out != "") {
+		return fmt.Errorf("--resolve applies a file of clusters; it does not go with --merge or --out")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	var clusters []schema.TriviaCluster
+	if *resolve != "" {
+		content, err := os.ReadFile(*resolve)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", *resolve, err)
+		}
+		if err := json.Unmarshal(content, &clusters); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", *resolve, err)
+		}
+	} else {
+		fmt.Fprintf(progress, "Looking for rephrased trivia in %s...\n", *dbPath)
+		clusters, err = schema.TriviaDuplicates(db, *threshold)
+		if err != nil {
+			return err
+		}
+		printTriviaClusters(clusters, *show)
+	}
+
+	if *out != "" {
+		data, err := json.MarshalIndent(clusters, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*out, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", *out, err)
+		}
+	}
+	if *resolve == "" && !*merge {
+		if *out != "" {
+			fmt.Fprintf(progress, "\n✓ %d clusters written to %s; pick the questions to keep, then run again with --resolve %s\n", len(clusters), *out, *out)
+		} else {
+			fmt.Fprintf(progress, "\n✓ %d clusters reported; run again with --merge, or --out to pick the questions to keep\n", len(clusters))
+		}
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	merged, err := schema.ResolveTrivia(tx, clusters)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	fmt.Fprintf(progress, "\n✓ Trivia duplicates resolved\n")
+	fmt.Fprintf(progress, "  Clusters: %d\n", len(clusters))
+	fmt.Fprintf(progress, "  Merged: %d\n", merged)
+	return nil
+}
+
+// printTriviaClusters prints up to show clusters, the kept question first
+func printTriviaClusters(clusters []schema.TriviaCluster, show int) {
+	for i, c := range clusters {
+		if show >= 0 && i >= show {
+			fmt.Fprintf(progress, "  ... and %d more\n", len(clusters)-i)
+			break
+		}
+		fmt.Fprintln(progress)
+		for _, q := range c.Questions {
+			mark := "drop"
+			if q.ID == c.Keep {
+				mark = "keep"
+			}
+			fmt.Fprintf(progress, "  %s %d: %s → %s (%s, %d views)\n", mark, q.ID, q.Question, q.Answer, q.Category, q.ViewCount)
+		}
+	}
+}
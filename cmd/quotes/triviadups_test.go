@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"quotesparser/dedup"
+	"quotesparser/schema"
+)
+
+func TestTriviaDups(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "database.db")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []struct {
+		question, answer string
+		views            int
+	}{
+		{"What is the capital of France?", "Paris", 2},
+		{"Name the capital city of France", "Paris", 5},
+		{"What is the largest city of France?", "Paris", 1},
+		{"Which is the capital of France?", "Paris", 0},
+	} {
+		_, err := db.Exec("INSERT INTO trivia (category, question, answer, viewCount, questionHash) VALUES ('geo', ?, ?, ?, ?)",
+			q.question, q.answer, q.views, dedup.TextHash(q.question))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("UPDATE trivia SET imageUrl = 'https://example.com/paris.jpg' WHERE id = 4"); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM trivia").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	clustersFile := filepath.Join(dir, "clusters.json")
+	if err := runTriviaDups([]string{"--db", dbPath, "--out", clustersFile}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 4 {
+		t.Fatalf("reporting left %d questions, want 4", n)
+	}
+
+	content, err := os.ReadFile(clustersFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clusters []schema.TriviaCluster
+	if err := json.Unmarshal(content, &clusters); err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || len(clusters[0].Questions) != 3 || clusters[0].Keep != 2 {
+		t.Fatalf("clusters = %+v, want questions 1, 2 and 4 keeping the most served, 2", clusters)
+	}
+
+	// Pick the first phrasing instead
+	clusters[0].Keep = 1
+	content, _ = json.Marshal(clusters)
+	if err := os.WriteFile(clustersFile, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := runTriviaDups([]string{"--db", dbPath, "--resolve", clustersFile}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := count(); n != 2 {
+		t.Errorf("%d questions left, want 2", n)
+	}
+	var question, image string
+	var views int
+	if err := db.QueryRow("SELECT question, viewCount, imageUrl FROM trivia WHERE id = 1").Scan(&question, &views, &image); err != nil {
+		t.Fatal(err)
+	}
+	if question != "What is the capital of France?" || views != 7 || image != "https://example.com/paris.jpg" {
+		t.Errorf("kept question = %q, %d views, image %q", question, views, image)
+	}
+}
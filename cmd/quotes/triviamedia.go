@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"quotesparser/fetch"
+	"quotesparser/media"
+	"quotesparser/quota"
+	"quotesparser/source"
+)
+
+// runTriviaMedia downloads the images and audio clips trivia questions point
+// to and caches them, recording them in triviaMedia, so quiz clients get
+// them from the API rather than from sites that may move or drop them.
+// Media already cached from the same address, or found missing there, are
+// skipped unless --refresh.
+func runTriviaMedia(args []string) error {
+	fs := flag.NewFlagSet("trivia-media", flag.ExitOnError)
+	dbPath := fs.String("db", "database.db", "SQLite database whose trivia media to download")
+	dir := fs.String("dir", "images/trivia", "folder to cache media in, as <triviaId>/image.<ext> and <triviaId>/audio.<ext>")
+	limit := fs.Int("limit", 0, "download at most this many files (0 = all)")
+	refresh := fs.Bool("refresh", false, "download media again, including those missing last time")
+	delay := fs.Duration("delay", 1*time.Second, "pause between downloads")
+	guard := addGuardFlags(fs)
+	configure := addFetchFlags(fs)
+	fs.Parse(args)
+
+	g, err := guard(*dir)
+	if err != nil {
+		return err
+	}
+	client := fetch.NewClient()
+	if err := configure(client); err != nil {
+		return err
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	pending, err := pendingMedia(db, *refresh, *limit)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(progress, "Downloading %d trivia images and clips...\n", len(pending))
+	cached, missing, failed := 0, 0, 0
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+	for i, m := range pending {
+		if i > 0 {
+			fetch.Sleep(ctx, *delay)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		f, err := media.Download(ctx, client, m.url, m.kind)
+		if err == nil {
+			file := media.Path(m.id, m.kind, f.Ext())
+			path := filepath.Join(*dir, file)
+			if err = g.Check(int64(len(f.Body))); err == nil {
+				if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+					err = quota.WriteFile(path, f.Body)
+				}
+			}
+			if err == nil {
+				g.Add(int64(len(f.Body)))
+				cached++
+				fmt.Fprintf(progress, "  trivia %d: %s %s\n", m.id, m.kind, file)
+				if err := saveTriviaMedia(db, m, file, f.ContentType); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		switch {
+		case errors.Is(err, media.ErrNotMedia), source.IsMissing(err):
+			missing++
+			slog.Warn("no trivia media", "kind", m.kind, "trivia", m.id, "err", err)
+			if err := saveTriviaMedia(db, m, "", ""); err != nil {
+				return err
+			}
+		case quota.IsLimit(err):
+			return err
+		default:
+			// Network trouble: leave the file for the next run
+			slog.Error("failed to fetch trivia media", "kind", m.kind, "trivia", m.id, "err", err)
+			failed++
+		}
+	}
+
+	if ctx.Err() != nil {
+		fmt.Fprintf(progress, "\n✗ Trivia media interrupted; the rest are left for the next run\n")
+	} else {
+		fmt.Fprintf(progress, "\n✓ Trivia media completed\n")
+	}
+	fmt.Fprintf(progress, "  Cached: %d\n", cached)
+	fmt.Fprintf(progress, "  Missing: %d\n", missing)
+	fmt.Fprintf(progress, "  Failed: %d\n", failed)
+	return nil
+}
+
+// triviaMedia is a question's image or clip to download
+type triviaMedia struct {
+	id        int64
+	kind, url string
+}
+
+// pendingMedia lists the media to download: those not cached from their
+// current address yet, or all of them with refresh
+func pendingMedia(db *sql.DB, refresh bool, limit int) ([]triviaMedia, error) {
+	var parts []string
+	for _, kind := range []string{media.Image, media.Audio} {
+		part := fmt.Sprintf("SELECT t.id, '%[1]s', t.%[1]sUrl FROM trivia t WHERE COALESCE(t.%[1]sUrl, '') != ''", kind)
+		if !refresh {
+			part += fmt.Sprintf(" AND NOT EXISTS (SELECT 1 FROM triviaMedia m WHERE m.triviaId = t.id AND m.kind = '%[1]s' AND m.sourceUrl = t.%[1]sUrl)", kind)
+		}
+		parts = append(parts, part)
+	}
+	query := parts[0] + " UNION ALL " + parts[1] + " ORDER BY 1, 2"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	defer rows.Close()
+	var pending []triviaMedia
+	for rows.Next() {
+		var m triviaMedia
+		if err := rows.Scan(&m.id, &m.kind, &m.url); err != nil {
+			return nil, fmt.Errorf("failed to read trivia: %v", err)
+		}
+		pending = append(pending, m)
+	}
+	return pending, rows.Err()
+}
+
+// saveTriviaMedia records the outcome of a download; an empty file records
+// that the address had no media
+func saveTriviaMedia(db *sql.DB, m triviaMedia, file, contentType string) error {
+	status := "ok"
+	if file == "" {
+		status = "none"
+	}
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO triviaMedia (triviaId, kind, status, sourceUrl, file, contentType, fetchedAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		m.id, m.kind, status, m.url, nullIfEmpty(filepath.ToSlash(file)), nullIfEmpty(contentType), time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to save trivia media: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"quotesparser/api"
+)
+
+func TestTriviaMedia(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	clip := append([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), make([]byte, 64)...)
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flag.png":
+			w.Write(img.Bytes())
+		case "/anthem.mp3":
+			w.Write(clip)
+		case "/moved.jpg":
+			w.Write([]byte("<html><body>Moved</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	cache := filepath.Join(t.TempDir(), "trivia")
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO trivia (id, category, question, answer, imageUrl, audioUrl) VALUES
+		(1, 'flags', 'Whose flag is this?', 'Japan', ?, ?),
+		(2, 'flags', 'And this one?', 'Chile', ?, NULL)`,
+		site.URL+"/flag.png", site.URL+"/anthem.mp3", site.URL+"/moved.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"--db", dbPath, "--dir", cache, "--delay", "0", "--min-free", "0", "--retries", "0"}
+	if err := runTriviaMedia(args); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct {
+		id          int64
+		kind        string
+		status      string
+		file        string
+		contentType string
+	}{
+		{1, "image", "ok", "1/image.png", "image/png"},
+		{1, "audio", "ok", "1/audio.mp3", "audio/mpeg"},
+		{2, "image", "none", "", ""},
+	} {
+		var status string
+		var file, contentType *string
+		if err := db.QueryRow("SELECT status, file, contentType FROM triviaMedia WHERE triviaId = ? AND kind = ?", want.id, want.kind).Scan(&status, &file, &contentType); err != nil {
+			t.Fatalf("trivia %d %s: %v", want.id, want.kind, err)
+		}
+		if status != want.status || deref(file) != want.file || deref(contentType) != want.contentType {
+			t.Errorf("trivia %d %s = %s, %s, %s", want.id, want.kind, status, deref(file), deref(contentType))
+		}
+		if want.file != "" {
+			if _, err := os.Stat(filepath.Join(cache, want.file)); err != nil {
+				t.Errorf("missing cached file: %v", err)
+			}
+		}
+	}
+
+	// A second run has nothing left to download
+	pending, err := pendingMedia(db, false, 0)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("pending after a run = %+v, %v", pending, err)
+	}
+
+	handler := api.New(db, t.TempDir(), t.TempDir())
+	handler.TriviaMedia = cache
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/trivia/random?category=flags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trivia api.Trivia
+	err = json.NewDecoder(resp.Body).Decode(&trivia)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch trivia.ID {
+	case 1:
+		if trivia.Image == nil || trivia.Image.URL != "/trivia/1/image" || trivia.Audio == nil || trivia.Audio.ContentType != "audio/mpeg" {
+			t.Errorf("trivia 1 media = %+v, %+v", trivia.Image, trivia.Audio)
+		}
+	case 2:
+		if trivia.Image == nil || trivia.Image.URL != site.URL+"/moved.jpg" || trivia.Audio != nil {
+			t.Errorf("trivia 2 media = %+v, %+v", trivia.Image, trivia.Audio)
+		}
+	}
+
+	resp, err = http.Get(srv.URL + "/trivia/1/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || !bytes.Equal(body, img.Bytes()) {
+		t.Errorf("GET /trivia/1/image = %d, %d bytes", resp.StatusCode, len(body))
+	}
+	if resp, err := http.Get(srv.URL + "/trivia/2/audio"); err != nil || resp.StatusCode != 404 {
+		t.Errorf("GET /trivia/2/audio = %v, %v; want 404", resp, err)
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
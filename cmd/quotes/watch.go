@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"quotesparser/dedup"
+	"quotesparser/kitap"
+	"quotesparser/origin"
+	"quotesparser/rejects"
+	"quotesparser/source"
+	"quotesparser/store"
+)
+
+// watchExts are the files watch ingests; anything else, such as images or
+// the .part files of a download in progress, is left alone
+var watchExts = map[string]bool{".json": true, ".txt": true, ".text": true, ".html": true}
+
+// runWatch ingests the files dropped into a folder as they appear: fun facts
+// saved by downloadFunFacts.go and quote pages saved by download 1000kitap.
+// Each file is moved into the archive folder once inserted, or into the
+// failed folder when it cannot be parsed, so it is read only once.
+//
+// The folder is polled every --interval rather than watched with fsnotify.
+// An event tells when a file was created, not when its writer is done with
+// it, so files would still have to be polled until they stop changing; a
+// scan does that and also works on network shares and SD cards, where
+// inotify misses changes, without adding a dependency.
+func runWatch(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	dbPath := flags.String("db", "database.db", "SQLite database to insert into")
+	archive := flags.String("archive", "", "folder to move ingested files into (default <dir>/processed)")
+	failed := flags.String("failed", "", "folder to move files that cannot be parsed into (default <dir>/failed)")
+	interval := flags.Duration("interval", 2*time.Second, "how often to look for new files; a file is taken once it stopped changing for this long")
+	once := flags.Bool("once", false, "ingest the files there now and exit")
+	authorPages := flags.Bool("author-pages", false, "quote pages are /yazar/<slug>/alintilar pages; fill the author from the page")
+	langOf := addLangFlag(flags)
+	loadPolicy := addPolicyFlag(flags, "1000kitap")
+	startReport := addReportFlag(flags)
+	decode := addStrictFlag(flags)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: quotes watch [flags] <dir>")
+	}
+	policy, err := loadPolicy()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Clean(flags.Arg(0))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", dir)
+	}
+	if *archive == "" {
+		*archive = filepath.Join(dir, "processed")
+	}
+	if *failed == "" {
+		*failed = filepath.Join(dir, "failed")
+	}
+
+	db, err := openDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := migrateDB(db); err != nil {
+		return err
+	}
+
+	w := &watcher{
+		dir:         dir,
+		archive:     filepath.Clean(*archive),
+		failed:      filepath.Clean(*failed),
+		db:          db,
+		quotes:      store.NewSQLite(db, store.Options{}),
+		langOf:      langOf,
+		authorPages: *authorPages,
+		policy:      policy,
+		decode:      decode,
+		seen:        make(map[string]fileState),
+		rejects:     startReport(),
+	}
+
+	if *once {
+		if err := w.ingestAll(w.files(true)); err != nil {
+			return err
+		}
+		w.printSummary()
+		return saveReport(w.rejects)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(progress, "Watching %s/ for new files, archiving them into %s/ (Ctrl+C to stop)\n\n", dir, *archive)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := w.ingestAll(w.files(false)); err != nil {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			w.printSummary()
+			return saveReport(w.rejects)
+		}
+	}
+}
+
+// fileState is what a scan saw of a file, to tell when it stopped changing
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watcher ingests the files of a folder
+type watcher struct {
+	dir, archive, failed string
+	db                   *sql.DB
+	quotes               store.Store
+	langOf               func(text, fallback string) string
+	authorPages          bool
+	policy               source.Policy
+	decode               func(file string, data []byte, v interface{}) error
+
+	seen map[string]fileState // files seen by the previous scan
+
+	ingested, quotesNew, quotesTotal, factsNew, factsTotal, failures int
+	incomplete                                                       source.Report
+	rejects                                                          *rejects.Report
+}
+
+// files lists the files ready to ingest: all of them when now is set, else
+// those unchanged since the previous scan, so a file still being written is
+// left for a later one
+func (w *watcher) files(now bool) []string {
+	var ready []string
+	current := make(map[string]fileState)
+	filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != w.dir && (path == w.archive || path == w.failed || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || !watchExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		current[path] = state
+		if now || w.seen[path] == state {
+			ready = append(ready, path)
+		}
+		return nil
+	})
+	w.seen = current
+	return ready
+}
+
+// ingestAll ingests files, stopping only when a file cannot be moved away,
+// which would ingest it again on every scan
+func (w *watcher) ingestAll(files []string) error {
+	for _, path := range files {
+		to := w.archive
+		if kind, err := w.ingest(path); err != nil {
+			slog.Error("failed to ingest file", "file", path, "err", err)
+			w.rejects.Add(rejects.Reject{Kind: kind, File: path, Reason: err.Error()})
+			w.failures++
+			to = w.failed
+		}
+		if err := w.move(path, to); err != nil {
+			return err
+		}
+		delete(w.seen, path)
+	}
+	return nil
+}
+
+// ingest inserts the fun fact or quotes of a file, returning which it
+// held for the report of a failure
+func (w *watcher) ingest(path string) (rejects.Kind, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return rejects.File, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return rejects.FunFactFile, w.ingestFunFact(path, content)
+	}
+	return rejects.File, w.ingestQuotes(path, content)
+}
+
+// watchedFact is a fun fact as downloadFunFacts.go saves it
+type watchedFact struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Source    string `json:"source"`
+	SourceURL string `json:"source_url"`
+	Language  string `json:"language"`
+	Permalink string `json:"permalink"`
+}
+
+func (w *watcher) ingestFunFact(path string, content []byte) error {
+	var fact watchedFact
+	if err := w.decode(path, content, &fact); err != nil {
+		return fmt.Errorf("failed to parse fun fact: %v", err)
+	}
+	fact.Text = strings.TrimSpace(fact.Text)
+	if fact.ID == "" || fact.Text == "" {
+		return errors.New("not a fun fact: no id or text")
+	}
+
+	var known int
+	if err := w.db.QueryRow("SELECT COUNT(*) FROM funFacts WHERE id = ?", fact.ID).Scan(&known); err != nil {
+		return fmt.Errorf("failed to read fun facts: %v", err)
+	}
+	// Like processFunFacts.go: keep what an earlier file recorded rather
+	// than blanking it
+	res, err := w.db.Exec(`
+		INSERT INTO funFacts (id, text, textHash, source, sourceUrl, lang, permalink) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET text = excluded.text, textHash = excluded.textHash,
+			source = COALESCE(excluded.source, source), sourceUrl = COALESCE(excluded.sourceUrl, sourceUrl),
+			lang = COALESCE(excluded.lang, lang), permalink = COALESCE(excluded.permalink, permalink)
+		ON CONFLICT(textHash) DO NOTHING`,
+		fact.ID, fact.Text, dedup.TextHashIn(fact.Text, fact.Language),
+		nullIfEmpty(fact.Source), nullIfEmpty(fact.SourceURL), nullIfEmpty(fact.Language), nullIfEmpty(fact.Permalink))
+	if err != nil {
+		return fmt.Errorf("failed to insert fun fact %s: %v", fact.ID, err)
+	}
+
+	w.ingested++
+	w.factsTotal++
+	if n, _ := res.RowsAffected(); n > 0 && known == 0 {
+		w.factsNew++
+	}
+	fmt.Fprintf(progress, "[%s] %s: fun fact %s\n", time.Now().Format("15:04:05"), path, fact.ID)
+	return nil
+}
+
+func (w *watcher) ingestQuotes(path string, content []byte) error {
+	parse := kitap.ParseQuotes
+	if w.authorPages {
+		parse = kitap.ParseAuthorQuotes
+	}
+	quotes, err := parse(string(content))
+	if err != nil {
+		return err
+	}
+	if len(quotes) == 0 {
+		return errors.New("no quotes found")
+	}
+	for _, q := range quotes {
+		rejectDropped(w.rejects, w.policy, path, q.QuoteText, q.Missing)
+	}
+	quotes = kitap.ApplyPolicy(quotes, w.policy, &w.incomplete)
+
+	prov := pageProvenance(path, parserVersion("1000kitap"))
+	rows := make([]store.Quote, len(quotes))
+	for i, q := range quotes {
+		rows[i] = store.Quote{Text: q.QuoteText, Author: q.Author, Book: q.BookName, Lang: w.langOf(q.QuoteText, "tr"), Enrich: q.Enrich, Source: "1000kitap", Raw: q.RawText, Confidence: q.Confidence, Provenance: prov,
+			Origin: origin.Classify(origin.Hints{Text: q.QuoteText, Author: q.Author, Book: q.BookName, Source: "1000kitap"})}
+	}
+	n, err := w.quotes.SaveQuotes(rows)
+	if err != nil {
+		return err
+	}
+
+	w.ingested++
+	w.quotesTotal += len(rows)
+	w.quotesNew += n
+	fmt.Fprintf(progress, "[%s] %s: %d quotes (%d new)\n", time.Now().Format("15:04:05"), path, len(rows), n)
+	return nil
+}
+
+// move moves path into folder, keeping its place under the watched folder
+// and never overwriting an earlier file of the same name
+func (w *watcher) move(path, folder string) error {
+	rel, err := filepath.Rel(w.dir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	to := filepath.Join(folder, rel)
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return fmt.Errorf("failed to create folder: %v", err)
+	}
+	ext := filepath.Ext(to)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(to); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		to = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(filepath.Join(folder, rel), ext), i, ext)
+	}
+	if err := os.Rename(path, to); err != nil {
+		return fmt.Errorf("failed to move %s: %v", path, err)
+	}
+	return nil
+}
+
+func (w *watcher) printSummary() {
+	fmt.Fprintf(progress, "\n✓ Ingested %d files\n", w.ingested)
+	fmt.Fprintf(progress, "  Quotes: %d (%d new)\n", w.quotesTotal, w.quotesNew)
+	fmt.Fprintf(progress, "  Incomplete quotes (%s): %s\n", w.policy, w.incomplete)
+	fmt.Fprintf(progress, "  Fun facts: %d (%d new)\n", w.factsTotal, w.factsNew)
+	fmt.Fprintf(progress, "  Failed: %d files, moved to %s/\n", w.failures, w.failed)
+}
+
+// nullIfEmpty stores a missing field as NULL
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"quotesparser/rejects"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "database.db")
+	page, err := os.ReadFile("testdata/site/1000kitap.com/kitap_normal-insanlar--182700_alintilar_1.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"abc.json":                       `{"id": "abc", "text": "Honey never spoils.", "source": "djtech.net", "language": "en"}`,
+		"bad.json":                       `{"id": "bad", "text": ""}`,
+		"normal-insanlar/file1.txt":      string(page),
+		"normal-insanlar/file2.txt.part": "half a page",
+		".seen":                          "abc\n",
+		"funfact1.jpg":                   "not text",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	if err := runWatch([]string{"--db", dbPath, "--once", "--report", reportPath, dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct{ Rejects []rejects.Reject }
+	if b, err := os.ReadFile(reportPath); err != nil {
+		t.Error(err)
+	} else if err := json.Unmarshal(b, &report); err != nil {
+		t.Error(err)
+	}
+	want := []rejects.Reject{{Kind: rejects.FunFactFile, File: filepath.Join(dir, "bad.json"), Reason: "not a fun fact: no id or text"}}
+	if !reflect.DeepEqual(report.Rejects, want) {
+		t.Errorf("rejects = %+v, want %+v", report.Rejects, want)
+	}
+
+	for _, name := range []string{"processed/abc.json", "processed/normal-insanlar/file1.txt", "failed/bad.json",
+		"normal-insanlar/file2.txt.part", ".seen", "funfact1.jpg"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"abc.json", "bad.json", "normal-insanlar/file1.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s was left in the watched folder", name)
+		}
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var facts, quotes int
+	var source string
+	if err := db.QueryRow("SELECT COUNT(*), MAX(source) FROM funFacts").Scan(&facts, &source); err != nil || facts != 1 || source != "djtech.net" {
+		t.Errorf("fun facts = %d from %q (%v), want the one with its source", facts, source, err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM quotes").Scan(&quotes); err != nil || quotes == 0 {
+		t.Errorf("quotes = %d (%v), want the page's", quotes, err)
+	}
+
+	// The same file again is archived next to the first
+	if err := os.WriteFile(filepath.Join(dir, "abc.json"), []byte(files["abc.json"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runWatch([]string{"--db", dbPath, "--once", dir}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "processed", "abc.2.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWatchWaitsForWrites(t *testing.T) {
+	dir := t.TempDir()
+	w := &watcher{dir: dir, archive: filepath.Join(dir, "processed"), failed: filepath.Join(dir, "failed"), seen: make(map[string]fileState)}
+	path := filepath.Join(dir, "abc.json")
+	if err := os.WriteFile(path, []byte(`{"id": "abc",`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ready := w.files(false); len(ready) != 0 {
+		t.Errorf("first scan = %v, want the new file left until it stops changing", ready)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(` "text": "Honey never spoils."}`)
+	f.Close()
+	if ready := w.files(false); len(ready) != 0 {
+		t.Errorf("scan after a write = %v, want the file left", ready)
+	}
+	if ready := w.files(false); len(ready) != 1 || ready[0] != path {
+		t.Errorf("scan of an unchanged file = %v, want it", ready)
+	}
+}
@@ -0,0 +1,59 @@
+// Package confidence scores how sure extraction is that it read a quote
+// right, from how it found the quote: a quote whose selectors matched
+// everything around its own text scores 1, one missing its book, read from
+// the data embedded in the page or with markup cleaned out of it less. The
+// score travels with the quote into the database, the API and the exports,
+// where consumers may ask for high-confidence quotes only.
+package confidence
+
+import "math"
+
+// Signals are what extraction noticed while finding a quote
+type Signals struct {
+	Missing  []string // parts the selectors found nothing for, such as the book
+	Fallback bool     // read from data embedded in the page rather than its markup
+	Distant  bool     // the book link was found above the quote's own element, or the author in the page heading
+	Cleaned  []string // cleanup steps that changed the text
+	Conflict bool     // the markup and the embedded data disagreed on a field
+}
+
+// Penalties taken off a score of 1
+const (
+	missingPenalty  = 0.2
+	fallbackPenalty = 0.15
+	distantPenalty  = 0.1
+	conflictPenalty = 0.1
+)
+
+// cleanPenalties are taken for the cleanup steps that changed a text; markup
+// left in it suggests the selector took more than the quote, while spacing
+// and quote marks are the site's typography
+var cleanPenalties = map[string]float64{
+	"html":    0.1,
+	"control": 0.02,
+	"unwrap":  0.02,
+}
+
+// High is the score from which a quote counts as high-confidence: one
+// missing nothing, found in the page's markup with at most a little cleanup
+const High = 0.85
+
+// Score is the confidence of a quote found with s, from 0 to 1 rounded to
+// two decimals. Every signal at once still scores above 0, which stands
+// for an unknown confidence.
+func (s Signals) Score() float64 {
+	score := 1 - missingPenalty*float64(len(s.Missing))
+	if s.Fallback {
+		score -= fallbackPenalty
+	}
+	if s.Distant {
+		score -= distantPenalty
+	}
+	if s.Conflict {
+		score -= conflictPenalty
+	}
+	for _, step := range s.Cleaned {
+		score -= cleanPenalties[step]
+	}
+	return math.Round(math.Max(score, 0.01)*100) / 100
+}
@@ -0,0 +1,27 @@
+package confidence
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	for _, tc := range []struct {
+		s    Signals
+		want float64
+	}{
+		{Signals{}, 1},
+		{Signals{Cleaned: []string{"whitespace", "smart-quotes"}}, 1},
+		{Signals{Cleaned: []string{"html", "whitespace", "unwrap"}}, 0.88},
+		{Signals{Missing: []string{"book"}}, 0.8},
+		{Signals{Missing: []string{"book"}, Fallback: true, Distant: true}, 0.55},
+		{Signals{Missing: []string{"book", "author", "lang", "title", "year"}}, 0.01},
+	} {
+		if got := tc.s.Score(); got != tc.want {
+			t.Errorf("%+v: Score = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+	if (Signals{Missing: []string{"book"}}).Score() >= High {
+		t.Error("a quote missing its book is high-confidence")
+	}
+	if (Signals{Distant: true, Cleaned: []string{"unwrap"}}).Score() < High {
+		t.Error("a cleaned quote found a level up is not high-confidence")
+	}
+}
@@ -0,0 +1,56 @@
+package covers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"quotesparser/dedup"
+)
+
+// ErrNoBook is returned when OpenLibrary matches no work to a book
+var ErrNoBook = errors.New("no such book")
+
+// Book is what OpenLibrary knows of a book, for citing it
+type Book struct {
+	Work          string // OpenLibrary work, e.g. /works/OL20195335W
+	OriginalTitle string // the work's title when the book was looked up by another, such as a translation's
+	ISBN          string // of one of its editions, ISBN-13 when there is one
+	Year          int    // first published, 0 when unknown
+	CoverURL      string // large cover, empty when it has none
+}
+
+// Describe returns what OpenLibrary knows of the first work it matches to
+// title and author; author may be empty. OpenLibrary matches the titles of
+// translations too, so a Turkish title may find the English original.
+func (f *Finder) Describe(title, author string) (Book, error) {
+	docs, err := f.search(title, author, "key,title,cover_i,isbn,first_publish_year")
+	if err != nil {
+		return Book{}, err
+	}
+	if len(docs) == 0 {
+		return Book{}, fmt.Errorf("%w: %s", ErrNoBook, title)
+	}
+	d := docs[0]
+	b := Book{Work: d.Key, ISBN: isbn(d.ISBN), Year: d.FirstPublishYear}
+	if dedup.NameKey(d.Title) != dedup.NameKey(title) {
+		b.OriginalTitle = d.Title
+	}
+	if d.CoverID > 0 {
+		b.CoverURL = fmt.Sprintf(f.CoverURL, d.CoverID)
+	}
+	return b, nil
+}
+
+// isbn picks the first ISBN-13 of isbns, else the first of them
+func isbn(isbns []string) string {
+	for _, n := range isbns {
+		if n = strings.ReplaceAll(n, "-", ""); len(n) == 13 {
+			return n
+		}
+	}
+	if len(isbns) > 0 {
+		return strings.ReplaceAll(isbns[0], "-", "")
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+// Package covers finds book covers on OpenLibrary, and what else it knows of
+// the books for citing them. quotes covers caches the covers with imgcache;
+// quotes books saves the rest with the books.
+package covers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"quotesparser/fetch"
+)
+
+const (
+	// DefaultSearchURL is OpenLibrary's book search
+	DefaultSearchURL = "https://openlibrary.org/search.json"
+	// DefaultCoverURL is the large cover of a cover id; default=false makes
+	// missing covers a 404 instead of a blank image
+	DefaultCoverURL = "https://covers.openlibrary.org/b/id/%d-L.jpg?default=false"
+	// DefaultUserAgent identifies the crawler, as OpenLibrary asks clients to
+	DefaultUserAgent = "quotesparser-covers/1.0 (book covers for a quotes database)"
+)
+
+// DefaultSizes are the widths covers are cached at: list thumbnails, book
+// pages and share images
+var DefaultSizes = []int{96, 240, 480}
+
+// ErrNotFound is returned when OpenLibrary has no cover for a book
+var ErrNotFound = errors.New("no cover")
+
+// Cover is a book's cover on OpenLibrary
+type Cover struct {
+	ID        int64  // OpenLibrary cover id
+	Work      string // OpenLibrary work, e.g. /works/OL20195335W
+	Title     string // title OpenLibrary knows the work by
+	SourceURL string // image the cached variants are made from
+}
+
+// Finder looks books up on OpenLibrary
+type Finder struct {
+	Client    *fetch.Client
+	SearchURL string
+	CoverURL  string // with %d for the cover id
+}
+
+// NewFinder returns a Finder for openlibrary.org
+func NewFinder() *Finder {
+	c := fetch.NewClient()
+	c.UserAgent = DefaultUserAgent
+	return &Finder{Client: c, SearchURL: DefaultSearchURL, CoverURL: DefaultCoverURL}
+}
+
+// doc is a work OpenLibrary's search found
+type doc struct {
+	Key              string   `json:"key"`
+	Title            string   `json:"title"`
+	CoverID          int64    `json:"cover_i"`
+	ISBN             []string `json:"isbn"`
+	FirstPublishYear int      `json:"first_publish_year"`
+}
+
+// search returns the fields of the first five works OpenLibrary matches to
+// title and author; author may be empty
+func (f *Finder) search(title, author, fields string) ([]doc, error) {
+	params := url.Values{
+		"title":  {title},
+		"fields": {fields},
+		"limit":  {"5"},
+	}
+	if author != "" {
+		params.Set("author", author)
+	}
+	body, err := f.Client.Get(f.SearchURL + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Docs []doc `json:"docs"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("bad answer from OpenLibrary: %v", err)
+	}
+	return result.Docs, nil
+}
+
+// Find returns the cover of the first edition OpenLibrary matches to title
+// and author that has one; author may be empty
+func (f *Finder) Find(title, author string) (Cover, error) {
+	docs, err := f.search(title, author, "key,title,cover_i")
+	if err != nil {
+		return Cover{}, err
+	}
+	for _, doc := range docs {
+		if doc.CoverID > 0 {
+			return Cover{
+				ID:        doc.CoverID,
+				Work:      doc.Key,
+				Title:     doc.Title,
+				SourceURL: fmt.Sprintf(f.CoverURL, doc.CoverID),
+			}, nil
+		}
+	}
+	return Cover{}, fmt.Errorf("%w for %s", ErrNotFound, title)
+}
+
+// Download returns the image the cover's variants are made from
+func (f *Finder) Download(c Cover) ([]byte, error) {
+	body, err := f.Client.Get(c.SourceURL)
+	if errors.Is(err, fetch.ErrNotFound) {
+		return nil, fmt.Errorf("%w: cover %d is gone", ErrNotFound, c.ID)
+	}
+	return body, err
+}
@@ -0,0 +1,211 @@
+// Package csvimport reads quotes from CSV files, such as a Goodreads, Notion
+// or spreadsheet export, by a mapping of quote fields to columns.
+package csvimport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"quotesparser/source"
+)
+
+// Fields are the quote fields a column can be mapped to
+var Fields = []string{"text", "author", "book", "lang", "likes"}
+
+// guesses are the header names a field is taken from when no mapping names
+// its column, compared in lowercase
+var guesses = map[string][]string{
+	"text":   {"text", "quote", "quotation", "highlight", "content", "alıntı", "söz", "cita", "frase"},
+	"author": {"author", "authors", "by", "yazar", "autor"},
+	"book":   {"book", "title", "book title", "source", "work", "kitap", "libro"},
+	"lang":   {"lang", "language", "dil", "idioma"},
+	"likes":  {"likes", "votes", "beğeni"},
+}
+
+// Mapping maps quote fields to columns: a header name, compared without
+// case, or a 1-based column number, written 3 or col3
+type Mapping map[string]string
+
+// ParseMapping reads a mapping written field=column,..., e.g.
+// text=Quote,author=col2
+func ParseMapping(s string) (Mapping, error) {
+	m := make(Mapping)
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field, column = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(column)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("bad mapping %q: want field=column", pair)
+		}
+		if !isField(field) {
+			return nil, fmt.Errorf("unknown field %q (%s)", field, strings.Join(Fields, ", "))
+		}
+		m[field] = column
+	}
+	return m, nil
+}
+
+func isField(name string) bool {
+	for _, f := range Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Options say how to read a file
+type Options struct {
+	Map Mapping
+	// Comma separates fields; 0 guesses it from the first line among comma,
+	// semicolon and tab
+	Comma rune
+	// NoHeader reads the first line as a quote; columns can then only be
+	// mapped by number
+	NoHeader bool
+}
+
+// Read reads the quotes of a CSV text. Fields may be quoted, span lines
+// and hold the separator; rows without text are skipped, and rows without
+// an author are marked so. Columns left out of the mapping are guessed from
+// the header.
+func Read(text string, opts Options) ([]source.Quote, error) {
+	comma := opts.Comma
+	if comma == 0 {
+		comma = sniff(text)
+	}
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	var header []string
+	if !opts.NoHeader {
+		var err error
+		if header, err = r.Read(); err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to read header: %v", err)
+		}
+	}
+	columns, err := resolve(opts.Map, header)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := columns["text"]; !ok {
+		return nil, fmt.Errorf("no column for the quote text: map one with text=<column> (columns: %s)", strings.Join(header, ", "))
+	}
+
+	var quotes []source.Quote
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return quotes, fmt.Errorf("failed to read CSV: %v", err)
+		}
+		get := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		q := source.Quote{Text: get("text"), Author: get("author"), Book: get("book"), Lang: get("lang")}
+		if q.Text == "" {
+			continue
+		}
+		if q.Author == "" {
+			q.Missing = append(q.Missing, "author")
+		}
+		if likes := get("likes"); likes != "" {
+			q.Likes, _ = strconv.Atoi(strings.NewReplacer(",", "", ".", "", " ", "").Replace(likes))
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
+
+// resolve turns the mapping and the header's guesses into column indexes
+func resolve(m Mapping, header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for field, column := range m {
+		i, err := columnIndex(column, header)
+		if err != nil {
+			return nil, fmt.Errorf("%s=%s: %v", field, column, err)
+		}
+		columns[field] = i
+	}
+	taken := make(map[int]bool)
+	for _, i := range columns {
+		taken[i] = true
+	}
+	for _, field := range Fields {
+		if _, ok := columns[field]; ok {
+			continue
+		}
+		for i, name := range header {
+			if !taken[i] && guessed(field, name) {
+				columns[field] = i
+				taken[i] = true
+				break
+			}
+		}
+	}
+	return columns, nil
+}
+
+func guessed(field, name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, g := range guesses[field] {
+		if name == g {
+			return true
+		}
+	}
+	return false
+}
+
+// columnIndex finds a column by header name or 1-based number
+func columnIndex(column string, header []string) (int, error) {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(column), "col")); err == nil && n >= 1 {
+		return n - 1, nil
+	}
+	if header == nil {
+		return 0, fmt.Errorf("without a header, columns are numbers")
+	}
+	return 0, fmt.Errorf("no such column (columns: %s)", strings.Join(header, ", "))
+}
+
+// sniff picks the separator occurring most on the first line outside quotes
+func sniff(text string) rune {
+	line, _, _ := strings.Cut(text, "\n")
+	counts := map[rune]int{}
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ',' || r == ';' || r == '\t'):
+			counts[r]++
+		}
+	}
+	best := ','
+	for _, r := range []rune{';', '\t'} {
+		if counts[r] > counts[best] {
+			best = r
+		}
+	}
+	return best
+}
@@ -0,0 +1,62 @@
+package csvimport
+
+import (
+	"reflect"
+	"testing"
+
+	"quotesparser/source"
+)
+
+func TestRead(t *testing.T) {
+	text := "Quote;Who;Book Title;Likes\n" +
+		"\"Be yourself; everyone else is already taken.\";Oscar Wilde;;\"1,024\"\n" +
+		"\"A line\nand \"\"another\"\"\";;Poems;3\n" +
+		";Nobody;;\n"
+	got, err := Read(text, Options{Map: Mapping{"author": "who"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []source.Quote{
+		{Text: "Be yourself; everyone else is already taken.", Author: "Oscar Wilde", Likes: 1024},
+		{Text: "A line\nand \"another\"", Book: "Poems", Likes: 3, Missing: []string{"author"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read = %+v, want %+v", got, want)
+	}
+
+	got, err = Read("Stay hungry.\tSteve Jobs\n", Options{Map: Mapping{"text": "col1", "author": "2"}, NoHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Author != "Steve Jobs" {
+		t.Errorf("headerless tab-separated = %+v", got)
+	}
+
+	if _, err := Read("Name,Notes\nx,y\n", Options{}); err == nil {
+		t.Error("a file without a text column was read")
+	}
+	if _, err := ParseMapping("quote=Text"); err == nil {
+		t.Error("an unknown field was mapped")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, tt := range []struct {
+		data     []byte
+		want     string
+		encoding string
+	}{
+		{[]byte("\xef\xbb\xbfQuote\n"), "Quote\n", "utf-8"},
+		{[]byte("\xff\xfeA\x00\xe7\x00"), "Aç", "utf-16le"},
+		{[]byte("\x93Hello\x94 caf\xe9"), "“Hello” café", "windows-1252"},
+		{[]byte("Sabah\xfdn ya\xf0muru \xddstanbul"), "Sabahın yağmuru İstanbul", "windows-1254"},
+	} {
+		got, encoding, err := Decode(tt.data, "auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want || encoding != tt.encoding {
+			t.Errorf("Decode(%q) = %q (%s), want %q (%s)", tt.data, got, encoding, tt.want, tt.encoding)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package csvimport
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings are the names --encoding accepts besides auto
+var Encodings = []string{"utf-8", "utf-16le", "utf-16be", "windows-1252", "windows-1254"}
+
+// cp1252 maps the bytes 0x80-0x9F of Windows-1252 that differ from Latin-1;
+// spreadsheets saved on Windows in western locales use it
+var cp1252 = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+// cp1254 is Windows-1254, Turkish Excel's encoding: Windows-1252 with Ğ, İ,
+// Ş and their lowercase in place of Icelandic letters
+var cp1254 = map[byte]rune{0xD0: 'Ğ', 0xDD: 'İ', 0xDE: 'Ş', 0xF0: 'ğ', 0xFD: 'ı', 0xFE: 'ş'}
+
+// Decode converts data in encoding to UTF-8. With "auto" a byte order mark
+// decides, then valid UTF-8 is taken as is, and anything else is read as
+// Windows-1254 when it has Turkish letters' bytes, else as Windows-1252.
+// It returns the encoding used.
+func Decode(data []byte, encoding string) (string, string, error) {
+	encoding = strings.ToLower(encoding)
+	if encoding == "auto" || encoding == "" {
+		encoding = Detect(data)
+	}
+	switch encoding {
+	case "utf-8", "utf8":
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		if !utf8.Valid(data) {
+			return "", encoding, fmt.Errorf("not valid UTF-8: try --encoding windows-1252 or windows-1254")
+		}
+		return string(data), "utf-8", nil
+	case "utf-16le", "utf-16be":
+		return decodeUTF16(data, encoding == "utf-16be"), encoding, nil
+	case "windows-1252", "cp1252", "latin1", "iso-8859-1":
+		return decodeSingleByte(data, nil), "windows-1252", nil
+	case "windows-1254", "cp1254", "iso-8859-9":
+		return decodeSingleByte(data, cp1254), "windows-1254", nil
+	}
+	return "", encoding, fmt.Errorf("unknown encoding %q (auto, %s)", encoding, strings.Join(Encodings, ", "))
+}
+
+// Detect guesses the encoding of data, as Decode does with "auto"
+func Detect(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xfe")):
+		return "utf-16le"
+	case bytes.HasPrefix(data, []byte("\xfe\xff")):
+		return "utf-16be"
+	case utf8.Valid(data):
+		return "utf-8"
+	}
+	for _, b := range data {
+		if _, ok := cp1254[b]; ok {
+			return "windows-1254"
+		}
+	}
+	return "windows-1252"
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	if len(units) > 0 && units[0] == 0xFEFF {
+		units = units[1:]
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeSingleByte reads Windows-1252, with the letters of extra in place of
+// its own
+func decodeSingleByte(data []byte, extra map[byte]rune) string {
+	var b strings.Builder
+	b.Grow(len(data))
+	for _, c := range data {
+		if r, ok := extra[c]; ok {
+			b.WriteRune(r)
+		} else if r, ok := cp1252[c]; ok {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,206 @@
+// Package datafile reads the files quotes are curated in by hand: JSON, or
+// YAML and TOML, whose multi-line strings are kinder to long quotes. YAML
+// and TOML are converted to JSON and checked by jsoncheck like any JSON
+// input, with the errors pointing at the lines of the YAML.
+//
+// A TOML document is a table, so a list is written as an array of tables;
+// in YAML or TOML a document whose only field is a list, such as
+//
+//	[[quotes]]
+//	text = """
+//	Kişi, sevdiği şeye benzer."""
+//	author = "Ahmet Hamdi Tanpınar"
+//
+// stands for that list where one is expected.
+package datafile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"quotesparser/jsoncheck"
+)
+
+// position is where a value is in a YAML file
+type position struct{ line, col int }
+
+// Unmarshal reads data, the content of file, into v by the format its
+// extension names, JSON when it names none of the others. Errors are those
+// of jsoncheck.
+func Unmarshal(file string, data []byte, v interface{}, strict bool) error {
+	var (
+		converted []byte
+		positions map[string]position
+		err       error
+	)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		converted, positions, err = fromYAML(file, data, wantsList(v))
+	case ".toml":
+		converted, err = fromTOML(file, data, wantsList(v))
+	default:
+		return jsoncheck.Unmarshal(file, data, v, strict)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = jsoncheck.Unmarshal(file, converted, v, strict)
+	// The lines of the JSON are none of the reader's business
+	var list jsoncheck.Errors
+	var one *jsoncheck.Error
+	switch {
+	case errors.As(err, &list):
+		for _, e := range list {
+			locate(e, positions)
+		}
+	case errors.As(err, &one):
+		locate(one, positions)
+	}
+	return err
+}
+
+// locate moves e to the line of the YAML value at its path, or of the
+// closest value around it; TOML has no positions, leaving the path alone
+func locate(e *jsoncheck.Error, positions map[string]position) {
+	e.Line, e.Col = 0, 0
+	for path := e.Path; path != ""; path = parent(path) {
+		if p, ok := positions[path]; ok {
+			e.Line, e.Col = p.line, p.col
+			return
+		}
+	}
+}
+
+// parent is the path of the value holding the one at path: $[2] of
+// $[2].author, $ of $[2]
+func parent(path string) string {
+	if i := strings.LastIndexAny(path, ".["); i > 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// wantsList reports whether v is a pointer to a list
+func wantsList(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return false
+	}
+	k := t.Elem().Kind()
+	return k == reflect.Slice || k == reflect.Array
+}
+
+func fromYAML(file string, data []byte, list bool) ([]byte, map[string]position, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, &jsoncheck.Error{File: file, Msg: "invalid YAML: " + strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	root := &doc
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return nil, nil, &jsoncheck.Error{File: file, Msg: "empty YAML document"}
+		}
+		root = root.Content[0]
+	}
+	if list && root.Kind == yaml.MappingNode && len(root.Content) == 2 && root.Content[1].Kind == yaml.SequenceNode {
+		root = root.Content[1]
+	}
+	c := converter{positions: make(map[string]position)}
+	if err := c.node("$", root); err != nil {
+		return nil, nil, &jsoncheck.Error{File: file, Line: c.line, Msg: err.Error()}
+	}
+	return c.out.Bytes(), c.positions, nil
+}
+
+// converter writes YAML nodes out as JSON, noting where each value was
+type converter struct {
+	out       bytes.Buffer
+	positions map[string]position
+	line      int // of the node being converted, for errors
+}
+
+func (c *converter) node(path string, n *yaml.Node) error {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	c.positions[path] = position{n.Line, n.Column}
+	c.line = n.Line
+	switch n.Kind {
+	case yaml.MappingNode:
+		c.out.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return fmt.Errorf("keys must be plain text")
+			}
+			if key.Value == "<<" {
+				return fmt.Errorf("merge keys are not supported")
+			}
+			if i > 0 {
+				c.out.WriteByte(',')
+			}
+			k, _ := json.Marshal(key.Value)
+			c.out.Write(k)
+			c.out.WriteByte(':')
+			if err := c.node(path+"."+key.Value, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		c.out.WriteByte('}')
+	case yaml.SequenceNode:
+		c.out.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				c.out.WriteByte(',')
+			}
+			if err := c.node(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+		c.out.WriteByte(']')
+	default:
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return err
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		c.out.Write(value)
+	}
+	return nil
+}
+
+func fromTOML(file string, data []byte, list bool) ([]byte, error) {
+	var doc map[string]interface{}
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		var parse toml.ParseError
+		if errors.As(err, &parse) {
+			return nil, &jsoncheck.Error{File: file, Line: parse.Position.Line, Col: parse.Position.Col, Msg: "invalid TOML: " + parse.Message}
+		}
+		return nil, &jsoncheck.Error{File: file, Msg: "invalid TOML: " + err.Error()}
+	}
+	var v interface{} = doc
+	if list && len(doc) == 1 {
+		for _, only := range doc {
+			if _, ok := only.([]map[string]interface{}); ok {
+				v = only
+			}
+		}
+	}
+	converted, err := json.Marshal(v)
+	if err != nil {
+		return nil, &jsoncheck.Error{File: file, Msg: err.Error()}
+	}
+	return converted, nil
+}
@@ -0,0 +1,84 @@
+package datafile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type quote struct {
+	Text   string `json:"text" jsoncheck:"required"`
+	Author string `json:"author"`
+	Likes  int    `json:"likes"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	want := []quote{
+		{Text: "Kişi, sevdiği şeye benzer.\nYa da benzemek istediğine.", Author: "Ahmet Hamdi Tanpınar", Likes: 3},
+		{Text: "Az olsun, öz olsun."},
+	}
+	for file, data := range map[string]string{
+		"quotes.yaml": `
+- text: |-
+    Kişi, sevdiği şeye benzer.
+    Ya da benzemek istediğine.
+  author: Ahmet Hamdi Tanpınar
+  likes: 3
+- text: Az olsun, öz olsun.
+`,
+		"wrapped.yml": `
+quotes:
+  - text: "Kişi, sevdiği şeye benzer.\nYa da benzemek istediğine."
+    author: Ahmet Hamdi Tanpınar
+    likes: 3
+  - text: 'Az olsun, öz olsun.'
+`,
+		"quotes.toml": `
+[[quotes]]
+text = """
+Kişi, sevdiği şeye benzer.
+Ya da benzemek istediğine."""
+author = "Ahmet Hamdi Tanpınar"
+likes = 3
+
+[[quotes]]
+text = "Az olsun, öz olsun."
+`,
+		"quotes.json": `[{"text": "Kişi, sevdiği şeye benzer.\nYa da benzemek istediğine.", "author": "Ahmet Hamdi Tanpınar", "likes": 3}, {"text": "Az olsun, öz olsun."}]`,
+	} {
+		var got []quote
+		if err := Unmarshal(file, []byte(data), &got, true); err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", file, got, want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		file, data string
+		want       []string
+	}{
+		{"q.yaml", "- text: One.\n  likes: many\n- author: Nobody\n  auther: x\n", []string{
+			`q.yaml:2:10: $[0].likes: expected integer, got string "many"`,
+			`q.yaml:3:3: $[1]: unknown field "auther" (did you mean "author"?)`,
+			`q.yaml:3:3: $[1]: missing required field "text"`,
+		}},
+		{"q.yaml", "- text: [unclosed\n", []string{`q.yaml: invalid YAML: line 1: did not find expected ',' or ']'`}},
+		{"q.toml", "[[quotes]]\nlikes = 2\n", []string{`q.toml: $[0]: missing required field "text"`}},
+		{"q.toml", "[[quotes]]\ntext = \"unclosed\n", []string{`q.toml:2:17: invalid TOML: strings cannot contain newlines`}},
+	} {
+		var quotes []quote
+		err := Unmarshal(tc.file, []byte(tc.data), &quotes, true)
+		var got []string
+		if err != nil {
+			got = strings.Split(err.Error(), "\n")
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %q:\ngot  %q\nwant %q", tc.file, tc.data, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+var bloomMagic = [4]byte{'Q', 'B', 'L', 'M'}
+
+// Bloom is a bloom filter over content hashes. A negative answer means the
+// key was never added; a positive one still has to be confirmed against the
+// database. It is safe for concurrent use.
+type Bloom struct {
+	mu    sync.RWMutex
+	bits  []uint64
+	m     uint64 // number of bits
+	k     uint32 // number of hash functions
+	count uint64 // keys added
+}
+
+// NewBloom sizes a filter for about expected keys at the given false
+// positive rate (e.g. 0.01)
+func NewBloom(expected int, fpRate float64) *Bloom {
+	if expected < 1000 {
+		expected = 1000
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	m := uint64(math.Ceil(-float64(expected) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Round(float64(m) / float64(expected) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &Bloom{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Add records key in the filter
+func (b *Bloom) Add(key string) {
+	h1, h2 := bloomHashes(key)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+	b.count++
+}
+
+// Test reports whether key may have been added
+func (b *Bloom) Test(key string) bool {
+	h1, h2 := bloomHashes(key)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + uint64(i)*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Count returns how many keys were added
+func (b *Bloom) Count() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.count
+}
+
+// Save writes the filter to path, replacing it atomically
+func (b *Bloom) Save(path string) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	tmp := path + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", tmp, err)
+	}
+	w := bufio.NewWriter(f)
+	header := []interface{}{bloomMagic, b.m, b.k, b.count}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("failed to write bloom filter: %v", err)
+		}
+	}
+	if err := binary.Write(w, binary.LittleEndian, b.bits); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write bloom filter: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write bloom filter: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write bloom filter: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadBloom reads a filter written by Save
+func LoadBloom(path string) (*Bloom, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	var magic [4]byte
+	b := &Bloom{}
+	for _, v := range []interface{}{&magic, &b.m, &b.k, &b.count} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("invalid bloom filter %s: %v", path, err)
+		}
+	}
+	if magic != bloomMagic || b.m == 0 || b.k == 0 {
+		return nil, fmt.Errorf("invalid bloom filter %s", path)
+	}
+
+	b.bits = make([]uint64, (b.m+63)/64)
+	if err := binary.Read(r, binary.LittleEndian, b.bits); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("truncated bloom filter %s", path)
+		}
+		return nil, fmt.Errorf("invalid bloom filter %s: %v", path, err)
+	}
+	return b, nil
+}
+
+func bloomHashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h = fnv.New64()
+	h.Write([]byte(key))
+	// Odd, so that the step is never 0 and the probes do not all land on
+	// h1's bit. They are all distinct only when m is a power of two, which
+	// NewBloom does not round to; a repeat costs a little accuracy, not
+	// correctness.
+	h2 := h.Sum64() | 1
+	return h1, h2
+}
@@ -0,0 +1,96 @@
+// Package dedup tracks which texts have already been seen, safely across
+// goroutines.
+package dedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"sync"
+)
+
+const shardCount = 64
+
+// Set is a concurrent set of normalized keys. It is split into shards with
+// their own locks so parallel parsers rarely wait on each other.
+type Set struct {
+	shards [shardCount]shard
+}
+
+type shard struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// New returns an empty Set
+func New() *Set {
+	s := &Set{}
+	for i := range s.shards {
+		s.shards[i].seen = make(map[string]struct{})
+	}
+	return s
+}
+
+// Key normalizes text for duplicate detection, the same way TextHash does
+func Key(text string) string {
+	return Normalize(text)
+}
+
+// Add records text and reports whether it was new. Empty keys are never new.
+func (s *Set) Add(text string) bool {
+	key := Key(text)
+	if key == "" {
+		return false
+	}
+
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, ok := sh.seen[key]; ok {
+		return false
+	}
+	sh.seen[key] = struct{}{}
+	return true
+}
+
+// Contains reports whether text has been added before
+func (s *Set) Contains(text string) bool {
+	key := Key(text)
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	_, ok := sh.seen[key]
+	return ok
+}
+
+// Len returns the number of distinct keys in the set
+func (s *Set) Len() int {
+	n := 0
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		n += len(s.shards[i].seen)
+		s.shards[i].mu.Unlock()
+	}
+	return n
+}
+
+func (s *Set) shardFor(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.shards[h.Sum32()%shardCount]
+}
+
+// TextHash is the hex SHA-256 of the normalized text. The database keeps it
+// in a column with a unique index (quotes.textHash, trivia.questionHash,
+// funFacts.textHash), so duplicates are rejected whichever ingester writes
+// them. Changing Normalize changes the hashes: run quotes dedup afterwards.
+func TextHash(text string) string {
+	return TextHashIn(text, "")
+}
+
+// TextHashIn is TextHash of a text in language lang, whose case folding
+// follows the language's rules
+func TextHashIn(text, lang string) string {
+	sum := sha256.Sum256([]byte(NormalizeIn(text, lang)))
+	return hex.EncodeToString(sum[:])
+}
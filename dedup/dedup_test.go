@@ -0,0 +1,231 @@
+package dedup
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAddNormalizes(t *testing.T) {
+	s := New()
+	if !s.Add("Hello World") {
+		t.Fatal("first Add should report a new key")
+	}
+	if s.Add("  hello world ") {
+		t.Fatal("Add should ignore case and surrounding spaces")
+	}
+	if s.Add("   ") {
+		t.Fatal("empty keys should never be new")
+	}
+	if !s.Contains("HELLO WORLD") {
+		t.Fatal("Contains should find the normalized key")
+	}
+	if got := s.Len(); got != 1 {
+		t.Fatalf("Len = %d, want 1", got)
+	}
+}
+
+// TestConcurrentAdd checks that each key is reported new exactly once when
+// many goroutines race on overlapping keys. Run with -race.
+func TestConcurrentAdd(t *testing.T) {
+	const (
+		workers = 16
+		keys    = 2000
+	)
+
+	s := New()
+	var fresh int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				// Every worker adds every key, starting at a different offset
+				k := (i + w*keys/workers) % keys
+				if s.Add(fmt.Sprintf("quote %d", k)) {
+					atomic.AddInt64(&fresh, 1)
+				}
+				s.Contains(fmt.Sprintf("quote %d", i))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if fresh != keys {
+		t.Fatalf("%d keys reported new, want %d", fresh, keys)
+	}
+	if got := s.Len(); got != keys {
+		t.Fatalf("Len = %d, want %d", got, keys)
+	}
+}
+
+func TestBloomSaveLoad(t *testing.T) {
+	b := NewBloom(10000, 0.01)
+	for i := 0; i < 5000; i++ {
+		b.Add(fmt.Sprintf("hash-%d", i))
+	}
+
+	path := t.TempDir() + "/quotes.bloom"
+	if err := b.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBloom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Count() != 5000 {
+		t.Fatalf("Count = %d, want 5000", loaded.Count())
+	}
+	for i := 0; i < 5000; i++ {
+		if !loaded.Test(fmt.Sprintf("hash-%d", i)) {
+			t.Fatalf("added key hash-%d reported missing", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 5000; i < 15000; i++ {
+		if loaded.Test(fmt.Sprintf("hash-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Fatalf("%d false positives in 10000 lookups, want about 1%%", falsePositives)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	same := [][]string{
+		{"Hello World", "  hello   world ", "hello world", "“Hello World”", "'hello world'"},
+		{"A duck's quack doesn't echo", "A duck`s quack doesn`t echo", "A duck’s quack doesn’t echo"},
+		{"Wait - what...", "Wait — what…", "wait – what..."},
+	}
+	for _, group := range same {
+		for _, text := range group[1:] {
+			if Normalize(text) != Normalize(group[0]) {
+				t.Errorf("Normalize(%q) = %q, want %q", text, Normalize(text), Normalize(group[0]))
+			}
+			if TextHash(text) != TextHash(group[0]) {
+				t.Errorf("TextHash(%q) differs from TextHash(%q)", text, group[0])
+			}
+		}
+	}
+
+	different := [][2]string{
+		{"Hello world.", "Hello world!"},
+		{"said \"no\" to them", "said no to them"},
+	}
+	for _, pair := range different {
+		if TextHash(pair[0]) == TextHash(pair[1]) {
+			t.Errorf("%q and %q should not be duplicates", pair[0], pair[1])
+		}
+	}
+}
+
+func TestNormalizeIn(t *testing.T) {
+	same := []struct{ lang, a, b string }{
+		{"tr", "ISPARTA'YA GİTTİM.", "Isparta'ya gittim."},
+		{"tr", "ısparta'ya gittim.", "Isparta'ya gittim."},
+		{"tr", "İstanbul'u dinliyorum.", "istanbul'u dinliyorum."},
+		{"", "İstanbul", "istanbul"},
+		{"es", "Gabriel Garci\u0301a Ma\u0301rquez", "Gabriel García Márquez"},
+		{"es", "CANCIÓN DE OTOÑO", "Canción de otoño"},
+	}
+	for _, tc := range same {
+		if NormalizeIn(tc.a, tc.lang) != NormalizeIn(tc.b, tc.lang) {
+			t.Errorf("NormalizeIn(%q, %q) = %q, want %q", tc.a, tc.lang, NormalizeIn(tc.a, tc.lang), NormalizeIn(tc.b, tc.lang))
+		}
+		if TextHashIn(tc.a, tc.lang) != TextHashIn(tc.b, tc.lang) {
+			t.Errorf("TextHashIn(%q, %q) differs from that of %q", tc.a, tc.lang, tc.b)
+		}
+	}
+	if NormalizeIn("ISPARTA", "tr") == NormalizeIn("isparta", "tr") {
+		t.Error("Turkish I matched i")
+	}
+	if Normalize("Hello World") != NormalizeIn("Hello World", "en") {
+		t.Error("English folds differently from an unknown language")
+	}
+}
+
+func TestLoose(t *testing.T) {
+	for _, tc := range []struct{ text, want string }{
+		{"Gabriel García Márquez", "gabriel garcia marquez"},
+		{"GABRIEL GARCI\u0301A MA\u0301RQUEZ", "gabriel garcia marquez"},
+		{"Sabahattin Ali", "sabahattin ali"},
+		{"ISPARTA, İstanbul, ılık", "isparta, istanbul, ilik"},
+		{"Søren Kierkegaard", "soren kierkegaard"},
+		{"Straße", "strasse"},
+		{"Stanisław Lem", "stanislaw lem"},
+		{"“Canción de otoño”", "cancion de otono"},
+	} {
+		if got := Loose(tc.text); got != tc.want {
+			t.Errorf("Loose(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+	for _, name := range []string{"SABAHATTİN ALİ", "Sabahattin  Ali ", "Sabahattin Alí", "sabahattin ali."} {
+		if got := NameKey(name); got != "sabahattin ali" {
+			t.Errorf("NameKey(%q) = %q", name, got)
+		}
+	}
+	if NameKey("J.R.R. Tolkien") != NameKey("J. R. R. Tolkien") {
+		t.Error("NameKey kept the spaces between initials")
+	}
+	if Normalize("García") == Normalize("Garcia") {
+		t.Error("Normalize dropped an accent")
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	texts := []string{
+		"The only way out is through.",
+		"Whatever our souls are made of, his and mine are the same.",
+		"The only way out is through...",
+		"Whatever our souls are made of, his and mine are alike.",
+		"Not all those who wander are lost.",
+	}
+	for _, metric := range []Metric{Jaccard, Levenshtein} {
+		m := NewMatcher(0.75, metric)
+		for _, text := range texts {
+			m.Add(text)
+		}
+		pairs := m.Pairs()
+		if len(pairs) != 2 || pairs[0].A != 0 || pairs[0].B != 2 || pairs[1].A != 1 || pairs[1].B != 3 {
+			t.Fatalf("metric %d: Pairs = %+v", metric, pairs)
+		}
+		if pairs[0].Score != 1 {
+			t.Errorf("metric %d: texts differing in punctuation scored %v, want 1", metric, pairs[0].Score)
+		}
+	}
+
+	if s := Similarity("What is the capital of Austria?", "Where is Vienna?", Jaccard); s > 0.3 {
+		t.Errorf("unrelated questions scored %v", s)
+	}
+}
+
+func TestTriviaClusters(t *testing.T) {
+	questions := []string{
+		"What is the capital of France?",
+		"Which river flows through Cairo?",
+		"Name the capital city of France",
+		"What is the largest city of France?",
+		"France's capital is which city?",
+		"Which is the longest river in Africa?",
+	}
+	answers := []string{"Paris", "The Nile", "Paris.", "Paris", "paris", "Nile"}
+
+	got := TriviaClusters(questions, answers, 0.7)
+	want := [][]int{{0, 2, 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TriviaClusters = %v, want %v", got, want)
+	}
+
+	if s := QuestionSimilarity("What is the capital of France?", "Name the capital city of France"); s != 0.8 {
+		t.Errorf("rephrased questions scored %v, want 0.8", s)
+	}
+	if AnswerKey("The Nile.") != AnswerKey("nile") {
+		t.Error("answers differing in their article do not match")
+	}
+}
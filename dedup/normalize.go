@@ -0,0 +1,94 @@
+package dedup
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"quotesparser/textshape"
+)
+
+// typography maps the typographic variants the sites use to plain ASCII, so
+// a quote copied from two sources with different quote marks still matches
+var typography = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'", "`", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`, "«", `"`, "»", `"`,
+	"–", "-", "—", "-", "―", "-", "‐", "-", "‑", "-",
+	"…", "...",
+)
+
+// Normalize reduces text to the form duplicates are compared in: NFC,
+// lowercase, plain quote marks and dashes, single spaces, and no quote marks
+// wrapped around the whole text
+func Normalize(text string) string {
+	return NormalizeIn(text, "")
+}
+
+// NormalizeIn is Normalize of a text in language lang, lowercased by the
+// language's rules: Turkish "ISPARTA" matches "ısparta" and "İSTANBUL"
+// "istanbul"
+func NormalizeIn(text, lang string) string {
+	text = typography.Replace(Fold(text, lang))
+
+	var b strings.Builder
+	b.Grow(len(text))
+	space := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return strings.Trim(b.String(), `"' `)
+}
+
+// Fold brings text to NFC, so composed and decomposed accents match, and
+// lowercases it by the rules of lang. Outside Turkish and Azerbaijani İ
+// lowers to a plain i rather than one with a combining dot.
+func Fold(text, lang string) string {
+	text = textshape.Lower(norm.NFC.String(text), lang)
+	return strings.ReplaceAll(text, "i\u0307", "i")
+}
+
+// letters are the Latin letters that are not a base letter and marks, and
+// what they read as once accents are dropped
+var letters = strings.NewReplacer(
+	"ı", "i", "ø", "o", "Ø", "O", "ł", "l", "Ł", "L", "đ", "d", "Đ", "D",
+	"ð", "d", "Ð", "D", "þ", "th", "Þ", "Th", "ß", "ss", "æ", "ae", "Æ", "Ae",
+	"œ", "oe", "Œ", "Oe", "ħ", "h", "Ħ", "H",
+)
+
+// Unaccent drops the accents and other marks of text and spells the letters
+// that have none in plain Latin: "García Márquez" becomes "Garcia Marquez",
+// "ılık" "ilik" and "Straße" "Strasse"
+func Unaccent(text string) string {
+	decomposed := norm.NFD.String(letters.Replace(text))
+	var b strings.Builder
+	b.Grow(len(decomposed))
+	for _, r := range decomposed {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(b.String())
+}
+
+// Loose is Normalize without accents, the form searches and author names
+// are matched in when told to fold: "GARCÍA MÁRQUEZ" and "Garcia Marquez"
+// are both "garcia marquez", and every Turkish i is an i
+func Loose(text string) string {
+	return Unaccent(Normalize(text))
+}
+
+// NameKey is the form author names are matched in: FuzzyText without
+// accents, so "SABAHATTİN ALİ", "Sabahattin  Ali " and "Sabahattin Alí" are
+// all "sabahattin ali", and "J.R.R. Tolkien" is "J. R. R. Tolkien"
+func NameKey(name string) string {
+	return Unaccent(FuzzyText(name))
+}
@@ -0,0 +1,140 @@
+package dedup
+
+import (
+	"sort"
+	"strings"
+)
+
+// questionWords are the words that phrase a trivia question rather than say
+// what it asks about, in the languages of trivia.txt: "What is the capital of
+// France?" and "Name the capital city of France" both come down to capital,
+// (city,) France
+var questionWords = wordSet(`
+	a an the of in on at to for by from with and or as is are was were be been
+	what which who whom whose where when why how does do did this that these
+	those its it name called known
+
+	bir ve ile de da mi mı mu mü ne nedir hangi hangisi hangisidir kim
+	kimdir nerede nereye kaç kaçtır olan adı adıdır denir
+
+	el la los las lo un una unos unas del al en y o es son fue qué que cuál
+	cual cuáles quién quien dónde donde cuándo cuando cómo como se llama
+`)
+
+func wordSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		set[w] = true
+	}
+	return set
+}
+
+// QuestionWords returns the words of a question that say what it asks about:
+// FuzzyText words without those that only phrase the question
+func QuestionWords(question string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.Fields(FuzzyText(question)) {
+		if !questionWords[w] {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+// QuestionSimilarity scores how alike two questions are by the words they
+// ask about, from 0 to 1 (the Dice coefficient of QuestionWords), so
+// rephrasings score high where character shingles would not
+func QuestionSimilarity(a, b string) float64 {
+	return dice(QuestionWords(a), QuestionWords(b))
+}
+
+func dice(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
+
+// AnswerKey reduces an answer to the form answers are matched in: FuzzyText
+// without a leading article, so "The Nile" and "Nile." match
+func AnswerKey(answer string) string {
+	key := FuzzyText(answer)
+	for _, article := range []string{"the ", "a ", "an "} {
+		key = strings.TrimPrefix(key, article)
+	}
+	return key
+}
+
+// TriviaClusters groups trivia questions asking the same thing in different
+// words: questions with the same AnswerKey whose QuestionSimilarity is at
+// least threshold are linked, and linked questions form a cluster even when
+// some pairs in it score lower. Clusters hold indexes into questions, in
+// order; questions without duplicates are left out.
+func TriviaClusters(questions, answers []string, threshold float64) [][]int {
+	byAnswer := make(map[string][]int)
+	var keys []string
+	for i, a := range answers {
+		key := AnswerKey(a)
+		if key == "" {
+			continue
+		}
+		if _, ok := byAnswer[key]; !ok {
+			keys = append(keys, key)
+		}
+		byAnswer[key] = append(byAnswer[key], i)
+	}
+
+	parent := make([]int, len(questions))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for _, key := range keys {
+		group := byAnswer[key]
+		if len(group) < 2 {
+			continue
+		}
+		words := make([]map[string]bool, len(group))
+		for i, q := range group {
+			words[i] = QuestionWords(questions[q])
+		}
+		for x := range group {
+			for y := x + 1; y < len(group); y++ {
+				if dice(words[x], words[y]) >= threshold {
+					rx, ry := find(group[x]), find(group[y])
+					if rx > ry {
+						rx, ry = ry, rx
+					}
+					parent[ry] = rx
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range questions {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+	var clusters [][]int
+	for _, m := range members {
+		if len(m) > 1 {
+			clusters = append(clusters, m)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i][0] < clusters[j][0] })
+	return clusters
+}
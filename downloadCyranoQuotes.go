@@ -8,6 +8,9 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"quotesparser/fetch"
+	"quotesparser/quota"
 )
 
 func downloadAndSave(pageNum int, folderPath string) error {
@@ -48,9 +51,9 @@ func downloadAndSave(pageNum int, folderPath string) error {
 	filename := fmt.Sprintf("file%d.txt", pageNum)
 	filePath := filepath.Join(folderPath, filename)
 
-	// Save to file
-	if err := ioutil.WriteFile(filePath, body, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %v", err)
+	// Save to file, renamed into place so Ctrl+C never leaves half a page
+	if err := quota.WriteFile(filePath, body); err != nil {
+		return err
 	}
 
 	fmt.Printf("[%s] Page %d downloaded: %s\n", time.Now().Format("15:04:05"), pageNum, filePath)
@@ -65,10 +68,14 @@ func main() {
 	fmt.Printf("Saving to: %s/\n", folderPath)
 	fmt.Printf("Pages: 1-100\n\n")
 
+	// Ctrl+C lets the page in flight finish and be saved, then stops
+	ctx, stop := fetch.Interrupted()
+	defer stop()
+
 	successCount := 0
 	failCount := 0
 
-	for pageNum := 1; pageNum <= 100; pageNum++ {
+	for pageNum := 1; pageNum <= 100 && ctx.Err() == nil; pageNum++ {
 		if err := downloadAndSave(pageNum, folderPath); err != nil {
 			log.Printf("Error on page %d: %v", pageNum, err)
 			failCount++
@@ -77,10 +84,14 @@ func main() {
 		}
 
 		// Add a small delay to avoid overwhelming the server
-		time.Sleep(1 * time.Second)
+		fetch.Sleep(ctx, 1*time.Second)
 	}
 
-	fmt.Printf("\n✓ Download completed!\n")
+	if ctx.Err() != nil {
+		fmt.Printf("\n✗ Download interrupted\n")
+	} else {
+		fmt.Printf("\n✓ Download completed!\n")
+	}
 	fmt.Printf("  Success: %d pages\n", successCount)
 	fmt.Printf("  Failed: %d pages\n", failCount)
 }
@@ -1,88 +1,196 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+
+	"quotesparser/fetch"
+	"quotesparser/quota"
+	"quotesparser/uselessfacts"
 )
 
-func downloadAndSave(url string, folderPath string) error {
-	// Create folder if it doesn't exist
+// seenFacts is the set of fact IDs already downloaded. It lives in memory
+// and in a .seen file in the folder, one ID a line, so duplicates are skipped
+// across runs even after the downloaded files are imported and removed.
+type seenFacts struct {
+	ids  map[string]bool
+	file *os.File
+}
+
+// loadSeen reads the .seen file of folderPath and the IDs of the facts
+// already in it
+func loadSeen(folderPath string) (*seenFacts, error) {
 	if err := os.MkdirAll(folderPath, 0755); err != nil {
-		return fmt.Errorf("failed to create folder: %v", err)
+		return nil, fmt.Errorf("failed to create folder: %v", err)
+	}
+	seen := &seenFacts{ids: make(map[string]bool)}
+
+	path := filepath.Join(folderPath, ".seen")
+	if data, err := ioutil.ReadFile(path); err == nil {
+		for _, id := range strings.Fields(string(data)) {
+			seen.ids[id] = true
+		}
+	}
+	// Facts saved before the .seen file existed: named by ID since the
+	// JSON API, with the ID inside before that
+	files, _ := filepath.Glob(filepath.Join(folderPath, "*.json"))
+	for _, f := range files {
+		seen.ids[strings.TrimSuffix(filepath.Base(f), ".json")] = true
+	}
+	files, _ = filepath.Glob(filepath.Join(folderPath, "*.txt"))
+	for _, f := range files {
+		var fact uselessfacts.Fact
+		if data, err := ioutil.ReadFile(f); err == nil && json.Unmarshal(data, &fact) == nil && fact.ID != "" {
+			seen.ids[fact.ID] = true
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	seen.file = file
+	return seen, nil
+}
+
+// add records id as downloaded
+func (s *seenFacts) add(id string) error {
+	s.ids[id] = true
+	if _, err := fmt.Fprintln(s.file, id); err != nil {
+		return fmt.Errorf("failed to record fact %s: %v", id, err)
+	}
+	return nil
+}
+
+// close flushes the .seen file to disk
+func (s *seenFacts) close() error {
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("failed to save %s: %v", s.file.Name(), err)
 	}
+	return s.file.Close()
+}
 
-	// Download HTML
+// downloadAndSave fetches a random fact and saves it unless it was seen
+// before, reporting whether it was new
+func downloadAndSave(url string, folderPath string, seen *seenFacts) (bool, error) {
 	client := &http.Client{
 		Timeout: 15 * time.Second,
 	}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return false, fmt.Errorf("failed to create request: %v", err)
 	}
+	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to download: %v", err)
+		return false, fmt.Errorf("failed to download: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+		return false, fmt.Errorf("bad status: %s", resp.Status)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %v", err)
+		return false, fmt.Errorf("failed to read response: %v", err)
 	}
 
-	// Generate random filename
-	timestamp := time.Now().Unix()
-	randomNum := rand.Intn(100000)
-	filename := fmt.Sprintf("funfact_%d_%d.txt", timestamp, randomNum)
-	filePath := filepath.Join(folderPath, filename)
+	// Check the answer is a fact before keeping it, so processFunFacts.go
+	// never meets an error page
+	fact, err := uselessfacts.Decode(body)
+	if err != nil {
+		return false, err
+	}
 
-	// Save to file
-	if err := ioutil.WriteFile(filePath, body, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %v", err)
+	if seen.ids[fact.ID] {
+		fmt.Printf("[%s] Skipped fact %s, already downloaded\n", time.Now().Format("15:04:05"), fact.ID)
+		return false, nil
+	}
+
+	// Renamed into place, so an interrupted write leaves no partial fact
+	filePath := filepath.Join(folderPath, fact.ID+".json")
+	if err := quota.WriteFile(filePath, body); err != nil {
+		return false, err
+	}
+	if err := seen.add(fact.ID); err != nil {
+		return false, err
 	}
 
 	fmt.Printf("[%s] Downloaded and saved to: %s\n", time.Now().Format("15:04:05"), filePath)
-	return nil
+	return true, nil
 }
 
 func main() {
-	url := "https://uselessfacts.jsph.pl/random.html?language=en"
-	folderPath := "funfacts"
+	count := flag.Int("count", 0, "stop after downloading this many new facts; 0 to run until Ctrl+C")
+	interval := flag.Duration("interval", 5*time.Second, "time between requests")
+	folderPath := flag.String("folder", "funfacts", "folder to save facts into")
+	flag.Parse()
+
+	url := uselessfacts.RandomURL("en")
 
-	// Seed random number generator
-	rand.Seed(time.Now().UnixNano())
+	seen, err := loadSeen(*folderPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Ctrl+C lets the request in flight finish and be saved, then stops
+	ctx, stop := fetch.Interrupted()
+	defer stop()
 
 	fmt.Printf("Starting fun facts downloader...\n")
 	fmt.Printf("URL: %s\n", url)
-	fmt.Printf("Saving to: %s/\n", folderPath)
-	fmt.Printf("Interval: 5 seconds\n")
-	fmt.Printf("Press Ctrl+C to stop\n\n")
-
-	// Download immediately on start
-	if err := downloadAndSave(url, folderPath); err != nil {
-		log.Printf("Error: %v", err)
+	fmt.Printf("Saving to: %s/ (%d facts already seen)\n", *folderPath, len(seen.ids))
+	fmt.Printf("Interval: %s\n", *interval)
+	if *count > 0 {
+		fmt.Printf("Stopping after %d new facts\n\n", *count)
+	} else {
+		fmt.Printf("Press Ctrl+C to stop\n\n")
 	}
 
-	// Create a ticker that fires every 5 seconds
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(*interval)
 	defer ticker.Stop()
 
-	// Download on every tick
-	for range ticker.C {
-		if err := downloadAndSave(url, folderPath); err != nil {
+	// Download immediately on start, then on every tick
+	saved, skipped := 0, 0
+loop:
+	for ctx.Err() == nil {
+		isNew, err := downloadAndSave(url, *folderPath, seen)
+		switch {
+		case err != nil:
 			log.Printf("Error: %v", err)
+		case isNew:
+			saved++
+		default:
+			skipped++
+		}
+		if *count > 0 && saved >= *count {
+			break
 		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	if err := seen.close(); err != nil {
+		log.Print(err)
+	}
+	if ctx.Err() != nil {
+		fmt.Printf("\n✗ Interrupted after downloading %d new facts (%d duplicates skipped)\n", saved, skipped)
+		return
 	}
+	fmt.Printf("\n✓ Downloaded %d new facts (%d duplicates skipped)\n", saved, skipped)
 }
@@ -0,0 +1,238 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// CassetteMode says whether a Cassette answers from disk, the network or both
+type CassetteMode int
+
+const (
+	// Replay answers only from the cassettes and fails on anything unrecorded
+	Replay CassetteMode = iota
+	// Record always asks the network and records the answers
+	Record
+	// ReplayOrRecord replays what is recorded and records the rest
+	ReplayOrRecord
+)
+
+// ParseCassetteMode reads a mode as given on the command line
+func ParseCassetteMode(s string) (CassetteMode, error) {
+	switch s {
+	case "replay":
+		return Replay, nil
+	case "record":
+		return Record, nil
+	case "auto":
+		return ReplayOrRecord, nil
+	}
+	return 0, fmt.Errorf("unknown cassette mode %q (replay, record, auto)", s)
+}
+
+// ErrNotRecorded is returned in Replay mode for requests with no recording.
+// It is never retried.
+var ErrNotRecorded = errors.New("no recorded response")
+
+// Interaction is one recorded request and its response. Bodies that are not
+// UTF-8 (fraseslibros serves Latin-1) are kept in BodyBase64.
+type Interaction struct {
+	Method      string    `json:"method"`
+	URL         string    `json:"url"`
+	Status      int       `json:"status"`
+	ContentType string    `json:"contentType,omitempty"`
+	Body        string    `json:"body,omitempty"`
+	BodyBase64  string    `json:"bodyBase64,omitempty"`
+	Recorded    time.Time `json:"recorded"`
+}
+
+// Cassette is an http.RoundTripper that records interactions into one JSON
+// Lines file per host under Dir, e.g. cassettes/1000kitap.com.jsonl, and
+// replays them, so parsers can be worked on and tested without hitting the
+// live sites. Re-recording a URL appends a new line; the last one wins.
+type Cassette struct {
+	Dir  string
+	Mode CassetteMode
+	Next http.RoundTripper // nil means http.DefaultTransport
+
+	mu    sync.Mutex
+	tapes map[string]map[string]Interaction // host -> method and URL -> interaction
+}
+
+// NewCassette returns a Cassette over the files in dir
+func NewCassette(dir string, mode CassetteMode) *Cassette {
+	return &Cassette{Dir: dir, Mode: mode}
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String()
+
+	if c.Mode != Record {
+		tape, err := c.tape(req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		in, ok := tape[key]
+		c.mu.Unlock()
+		if ok {
+			return in.response(req)
+		}
+		if c.Mode == Replay {
+			return nil, fmt.Errorf("%w for %s", ErrNotRecorded, key)
+		}
+	}
+
+	next := c.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Server trouble is not worth replaying; cut-off bodies are not either
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 ||
+		(resp.ContentLength >= 0 && int64(len(body)) != resp.ContentLength) {
+		return resp, nil
+	}
+	in := Interaction{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Recorded:    time.Now().UTC(),
+	}
+	if utf8.Valid(body) {
+		in.Body = string(body)
+	} else {
+		in.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+	if err := c.record(req.URL.Host, key, in); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// tape returns the interactions recorded for host, loading them on first use
+func (c *Cassette) tape(host string) (map[string]Interaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tapes == nil {
+		c.tapes = make(map[string]map[string]Interaction)
+	}
+	if tape, ok := c.tapes[host]; ok {
+		return tape, nil
+	}
+
+	tape := make(map[string]Interaction)
+	f, err := os.Open(c.path(host))
+	if os.IsNotExist(err) {
+		c.tapes[host] = tape
+		return tape, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var in Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &in); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", c.path(host), line, err)
+		}
+		tape[in.Method+" "+in.URL] = in
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %v", err)
+	}
+	c.tapes[host] = tape
+	return tape, nil
+}
+
+// record appends in to the host's cassette
+func (c *Cassette) record(host, key string, in Interaction) error {
+	tape, err := c.tape(host)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cassette folder: %v", err)
+	}
+	f, err := os.OpenFile(c.path(host), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open cassette: %v", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to record %s: %v", key, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to record %s: %v", key, err)
+	}
+	tape[key] = in
+	return nil
+}
+
+func (c *Cassette) path(host string) string {
+	name := strings.NewReplacer(":", "_", "/", "_").Replace(host)
+	return filepath.Join(c.Dir, name+".jsonl")
+}
+
+// response rebuilds the recorded response for req
+func (in Interaction) response(req *http.Request) (*http.Response, error) {
+	body := []byte(in.Body)
+	if in.BodyBase64 != "" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(in.BodyBase64); err != nil {
+			return nil, fmt.Errorf("bad recorded body for %s: %v", in.URL, err)
+		}
+	}
+	header := make(http.Header)
+	if in.ContentType != "" {
+		header.Set("Content-Type", in.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,113 @@
+package fetch
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Chaos is an http.RoundTripper that breaks a share of the requests going
+// through it, to rehearse retry, resume and validation before a long crawl.
+// A broken request fails in one of four ways, picked at random:
+//
+//   - timeout: the request fails as if the server never answered
+//   - 500: the server answers 500 Internal Server Error
+//   - truncated: the body is cut short, half the time without a Content-Length
+//   - slow: the response arrives after up to MaxDelay, but intact
+type Chaos struct {
+	Next     http.RoundTripper // nil means http.DefaultTransport
+	Rate     float64           // share of requests to break, 0..1
+	MaxDelay time.Duration     // longest injected delay for slow responses
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewChaos returns a Chaos transport breaking rate of the requests, seeded so
+// a failing run can be replayed
+func NewChaos(rate float64, seed int64) *Chaos {
+	return &Chaos{
+		Rate:     rate,
+		MaxDelay: 5 * time.Second,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// chaosTimeout is what an injected timeout returns
+type chaosTimeout struct{}
+
+func (chaosTimeout) Error() string   { return "chaos: injected timeout" }
+func (chaosTimeout) Timeout() bool   { return true }
+func (chaosTimeout) Temporary() bool { return true }
+
+func (c *Chaos) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := c.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	c.mu.Lock()
+	broken := c.rng.Float64() < c.Rate
+	fault := c.rng.Intn(4)
+	cut := c.rng.Float64()
+	keepLength := c.rng.Intn(2) == 0
+	delay := time.Duration(c.rng.Int63n(int64(c.MaxDelay) + 1))
+	c.mu.Unlock()
+
+	if !broken {
+		return next.RoundTrip(req)
+	}
+
+	switch fault {
+	case 0:
+		slog.Info("chaos: timeout", "url", req.URL)
+		return nil, chaosTimeout{}
+	case 1:
+		slog.Info("chaos: 500", "url", req.URL)
+		return &http.Response{
+			Status:        "500 Internal Server Error",
+			StatusCode:    http.StatusInternalServerError,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        make(http.Header),
+			Body:          io.NopCloser(bytes.NewReader(nil)),
+			ContentLength: 0,
+			Request:       req,
+		}, nil
+	case 2:
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		n := int(float64(len(body)) * cut)
+		slog.Info("chaos: truncated", "url", req.URL, "bytes", n, "of", len(body))
+		resp.Body = io.NopCloser(bytes.NewReader(body[:n]))
+		if keepLength {
+			resp.ContentLength = int64(len(body))
+			resp.Header.Set("Content-Length", fmt.Sprint(len(body)))
+		} else {
+			resp.ContentLength = -1
+			resp.Header.Del("Content-Length")
+		}
+		return resp, nil
+	default:
+		slog.Info("chaos: delaying", "url", req.URL, "by", delay)
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		return next.RoundTrip(req)
+	}
+}
@@ -0,0 +1,213 @@
+// Package fetch downloads pages for the quote scrapers.
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DefaultUserAgent is the user agent sent by every downloader, naming the
+// crawler robots.txt rules are matched for
+const DefaultUserAgent = DefaultAgent + userAgentVersion
+
+// StatusError is returned for non-200 responses
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return "bad status: " + e.Status
+}
+
+// Is lets errors.Is match a StatusError to the kind of failure its status
+// stands for, ErrNotFound or ErrSourceBlocked
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == http.StatusNotFound || e.Code == http.StatusGone
+	case ErrSourceBlocked:
+		return e.Code == http.StatusUnauthorized || e.Code == http.StatusForbidden || e.Code == http.StatusTooManyRequests
+	}
+	return false
+}
+
+var (
+	// ErrNotFound matches a site answering that a page does not exist
+	ErrNotFound = errors.New("page not found")
+	// ErrSourceBlocked matches a site refusing the crawler, by denying it
+	// or by rate limiting it past the retries
+	ErrSourceBlocked = errors.New("blocked by the site")
+)
+
+// ErrTruncated is returned when a body is shorter than its Content-Length
+// or fails the client's Validate check
+var ErrTruncated = errors.New("truncated response")
+
+// Client wraps an http.Client with the headers the sites expect
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string
+
+	// Retries is how many more times a failed request is tried. Network
+	// errors, timeouts, truncated bodies, 429 and 5xx responses are retried;
+	// other statuses are not.
+	Retries int
+	Backoff time.Duration // wait before the first retry, doubled after each
+
+	// Validate optionally checks a complete-looking body, e.g. that an HTML
+	// page was not cut off; failures are retried like truncated bodies
+	Validate func(body []byte) error
+
+	// Cache optionally keeps the pages downloaded, for the client to ask
+	// the site whether they changed since rather than download them again
+	Cache Cache
+
+	// Polite optionally keeps the client off the pages robots.txt
+	// disallows, and paces its requests to each host
+	Polite *Polite
+}
+
+// Cache keeps pages with the validators the site sent for them
+type Cache interface {
+	// Lookup returns the latest copy of url kept, if any
+	Lookup(url string) (Cached, bool, error)
+	// Store keeps a page just downloaded from url
+	Store(url string, page Cached) error
+	// Unchanged notes the site answering that url did not change since
+	// the copy Lookup returned
+	Unchanged(url string)
+}
+
+// Cached is a page kept by a Cache
+type Cached struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+}
+
+// NewClient returns a Client with the default timeout, user agent and retries
+func NewClient() *Client {
+	return &Client{
+		HTTP: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		UserAgent: DefaultUserAgent,
+		Retries:   2,
+		Backoff:   1 * time.Second,
+	}
+}
+
+// Get downloads url and returns the response body, failing on non-200 responses
+func (c *Client) Get(url string) ([]byte, error) {
+	return c.GetContext(context.Background(), url)
+}
+
+// GetContext is Get giving up on its retries once ctx is done. The request
+// already sent is not cancelled, so an interrupted downloader still gets the
+// page it was waiting for.
+func (c *Client) GetContext(ctx context.Context, url string) ([]byte, error) {
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		if c.Polite != nil {
+			if err := c.Polite.wait(ctx, c, url); err != nil {
+				return nil, err
+			}
+		}
+		body, err := c.get(url)
+		if err == nil || attempt >= c.Retries || !retryable(err) || ctx.Err() != nil {
+			return body, err
+		}
+		slog.Warn("retrying", "url", url, "in", backoff, "attempt", attempt+1, "of", c.Retries, "err", err)
+		if !Sleep(ctx, backoff) {
+			return body, err
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) get(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	var cached Cached
+	kept := false
+	if c.Cache != nil {
+		if cached, kept, err = c.Cache.Lookup(url); err != nil {
+			return nil, err
+		}
+		if kept && cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if kept && cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && kept {
+		c.Cache.Unchanged(url)
+		return cached.Body, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.ContentLength >= 0 && int64(len(body)) != resp.ContentLength {
+		return nil, fmt.Errorf("%w: got %d of %d bytes", ErrTruncated, len(body), resp.ContentLength)
+	}
+	if c.Validate != nil {
+		if err := c.Validate(body); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTruncated, err)
+		}
+	}
+	if c.Cache != nil {
+		page := Cached{Body: body, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+		if err := c.Cache.Store(url, page); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// CompleteHTML is a Validate check for HTML pages: a page cut off in
+// transit has lost its closing </html> tag
+func CompleteHTML(body []byte) error {
+	tail := body
+	if len(tail) > 1024 {
+		tail = tail[len(tail)-1024:]
+	}
+	if !bytes.Contains(bytes.ToLower(tail), []byte("</html>")) {
+		return errors.New("page has no closing </html>")
+	}
+	return nil
+}
+
+// retryable reports whether a failed request may succeed if tried again
+func retryable(err error) bool {
+	if errors.Is(err, ErrNotRecorded) || errors.Is(err, ErrDisallowed) || errors.Is(err, ErrRobotsUnavailable) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code == http.StatusTooManyRequests || status.Code >= 500
+	}
+	return true
+}
@@ -0,0 +1,300 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDisallowed is returned for pages the site's robots.txt keeps
+	// crawlers away from
+	ErrDisallowed = errors.New("disallowed by robots.txt")
+	// ErrRobotsUnavailable is returned for the pages of a site whose
+	// robots.txt failed to download, until it is read again
+	ErrRobotsUnavailable = errors.New("robots.txt unavailable")
+)
+
+// DefaultAgent is the product token robots.txt groups name the scrapers by,
+// the one their User-Agent starts with
+const DefaultAgent = "quotesparser"
+
+// DefaultRobotsRetry is how long a site whose robots.txt failed to download
+// is left alone before it is read again
+const DefaultRobotsRetry = time.Minute
+
+// UserAgent is the User-Agent header of a crawler going by agent in
+// robots.txt
+func UserAgent(agent string) string {
+	return agent + userAgentVersion
+}
+
+const userAgentVersion = "/1.0 (quotes database crawler)"
+
+// Robots is the group of a robots.txt that applies to a crawler
+type Robots struct {
+	rules []robotsRule
+	// CrawlDelay is how long the site asks crawlers to wait between
+	// requests, 0 when it does not say
+	CrawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// ParseRobots reads the rules of robots.txt for the crawler going by agent:
+// those of the groups naming it, or of the * groups when none does. Groups
+// naming the same agent are merged, as RFC 9309 has it.
+func ParseRobots(content []byte, agent string) Robots {
+	agent = strings.ToLower(agent)
+	var named, others Robots
+	var foundNamed bool
+	var group []*Robots // the groups the current lines go to
+	inAgents := false   // whether the previous line named an agent
+	for _, line := range strings.Split(string(content), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				group = group[:0]
+			}
+			inAgents = true
+			switch strings.ToLower(value) {
+			case agent:
+				foundNamed = true
+				group = append(group, &named)
+			case "*":
+				group = append(group, &others)
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if value == "" {
+				// An empty Disallow allows everything, as no rule does
+				continue
+			}
+			for _, r := range group {
+				r.rules = append(r.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			inAgents = false
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			for _, r := range group {
+				r.CrawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inAgents = false
+		}
+	}
+	if foundNamed {
+		return named
+	}
+	return others
+}
+
+// Allowed reports whether the page at path, with its query, may be crawled:
+// the longest rule matching it decides, an Allow winning a tie
+func (r Robots) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !matchRobots(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || n == longest && rule.allow {
+			allowed, longest = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// matchRobots matches path against a robots.txt pattern: a prefix, in which
+// * stands for any characters and a final $ anchors the end
+func matchRobots(pattern, path string) bool {
+	if pattern == "" {
+		return true
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(path); i++ {
+			if matchRobots(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	case '$':
+		if len(pattern) == 1 {
+			return path == ""
+		}
+	}
+	return path != "" && pattern[0] == path[0] && matchRobots(pattern[1:], path[1:])
+}
+
+// Polite keeps the requests of clients sharing it to what the robots.txt of
+// each host asks, read once a host or again later when it failed to
+// download, and paces the requests to a host at least Delay apart, or the
+// host's crawl delay when longer. Clients copied from one another share it.
+type Polite struct {
+	Agent string        // matched against the groups of robots.txt, DefaultAgent when empty
+	Delay time.Duration // least time between two requests to a host
+	// IgnoreRobots paces the requests by Delay alone, without reading
+	// robots.txt
+	IgnoreRobots bool
+	// RobotsRetry is how long to wait before reading a robots.txt that
+	// failed to download again, DefaultRobotsRetry when 0
+	RobotsRetry time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*politeHost
+}
+
+type politeHost struct {
+	read   sync.Mutex // held while robots.txt is downloaded
+	robots *Robots    // nil until read
+	retry  time.Time  // when to read robots.txt again, after failing to
+	next   time.Time  // when the host may be asked again
+}
+
+// wait returns ErrDisallowed when robots.txt disallows rawURL, or
+// ErrRobotsUnavailable when it could not be read, and otherwise waits for
+// its host's turn
+func (p *Polite) wait(ctx context.Context, c *Client, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		// The request fails on its own
+		return nil
+	}
+	origin := u.Scheme + "://" + u.Host
+	p.mu.Lock()
+	if p.hosts == nil {
+		p.hosts = make(map[string]*politeHost)
+	}
+	h := p.hosts[origin]
+	if h == nil {
+		h = &politeHost{}
+		p.hosts[origin] = h
+	}
+	p.mu.Unlock()
+
+	delay := p.Delay
+	if !p.IgnoreRobots {
+		robots, err := p.robots(ctx, c, h, origin)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrRobotsUnavailable, rawURL, err)
+		}
+		if !robots.Allowed(u.RequestURI()) {
+			return fmt.Errorf("%w: %s", ErrDisallowed, rawURL)
+		}
+		delay = max(delay, robots.CrawlDelay)
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	at := h.next
+	if at.Before(now) {
+		at = now
+	}
+	h.next = at.Add(delay)
+	p.mu.Unlock()
+	if wait := at.Sub(now); wait > 0 && !Sleep(ctx, wait) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// robots returns the rules of robots.txt for the host, reading them the
+// first time and again RobotsRetry after failing to
+func (p *Polite) robots(ctx context.Context, c *Client, h *politeHost, origin string) (*Robots, error) {
+	h.read.Lock()
+	defer h.read.Unlock()
+	if h.robots != nil {
+		return h.robots, nil
+	}
+	if time.Now().Before(h.retry) {
+		return nil, errors.New("failed to download it, trying again later")
+	}
+	robots, err := p.readRobots(ctx, c, origin)
+	if err != nil {
+		retry := p.RobotsRetry
+		if retry == 0 {
+			retry = DefaultRobotsRetry
+		}
+		h.retry = time.Now().Add(retry)
+		slog.Warn("failed to read robots.txt, staying off the site for now", "site", origin, "retry", retry, "err", err)
+		return nil, err
+	}
+	h.robots = &robots
+	return h.robots, nil
+}
+
+// readRobots downloads the robots.txt of origin with the client's retries.
+// A site without one, answering 4xx, allows everything; a 5xx or a network
+// error leaves its rules unknown.
+func (p *Polite) readRobots(ctx context.Context, c *Client, origin string) (Robots, error) {
+	agent := p.Agent
+	if agent == "" {
+		agent = DefaultAgent
+	}
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		body, err := c.robots(ctx, origin+"/robots.txt")
+		var status *StatusError
+		switch {
+		case err == nil:
+			return ParseRobots(body, agent), nil
+		case errors.Is(err, ErrNotRecorded):
+			// Replaying cassettes recorded without it
+			return Robots{}, nil
+		case errors.As(err, &status) && status.Code >= 400 && status.Code < 500:
+			return Robots{}, nil
+		}
+		if attempt >= c.Retries || ctx.Err() != nil || !Sleep(ctx, backoff) {
+			return Robots{}, err
+		}
+		backoff *= 2
+	}
+}
+
+// robots downloads a robots.txt, without the client's cache, and reads at
+// most the 500 KiB RFC 9309 asks crawlers to
+func (c *Client) robots(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 500<<10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,39 @@
+package fetch
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Interrupted returns a context cancelled by the first SIGINT or SIGTERM.
+// Downloaders check it between requests, so the request in flight finishes
+// and its page is saved before they stop and print what they did. A second
+// signal kills the process as usual. Call stop when done.
+func Interrupted() (ctx context.Context, stop func()) {
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		if context.Cause(ctx) != context.Canceled {
+			slog.Warn("stopping after the current request; interrupt again to quit now")
+		}
+		stop()
+	}()
+	return ctx, stop
+}
+
+// Sleep pauses for d, or until ctx is done, reporting whether it slept
+// the whole time
+func Sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
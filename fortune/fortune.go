@@ -0,0 +1,126 @@
+// Package fortune writes quotes as fortune(6) files: entries separated by
+// lines holding a single %, with the index strfile(8) would build next to
+// them, so a collection dropped into /usr/share/games/fortunes (or any
+// directory passed to fortune) works without strfile installed.
+package fortune
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+
+	"quotesparser/textshape"
+)
+
+// DefaultWidth keeps entries inside an 80-column terminal with room for a
+// shell prompt's indentation
+const DefaultWidth = 72
+
+// version is the strfile index format fortune-mod and the BSDs read
+const version = 2
+
+// Format lays out an entry as fortune prints it: text wrapped to width
+// columns, then the attribution indented on a line of its own, the way the
+// classic fortune files credit their quotes:
+//
+//	Az olsun, öz olsun.
+//			-- Atasözü
+//
+// Lines holding just a % would end the entry early, so they get a space.
+func Format(text, attribution string, width int) string {
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	var lines []string
+	for _, l := range textshape.Wrap(strings.TrimSpace(text), float64(width), textshape.Columns) {
+		if l == "%" {
+			l = " %"
+		}
+		lines = append(lines, l)
+	}
+	if attribution != "" {
+		lines = append(lines, "\t\t-- "+attribution)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Writer writes entries to a fortune file and keeps their offsets for the
+// index
+type Writer struct {
+	w       *bufio.Writer
+	pos     uint32
+	offsets []uint32
+	longest uint32
+	// shortest starts at the largest length so the first entry sets it
+	shortest uint32
+}
+
+// NewWriter returns a Writer writing a fortune file to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), offsets: []uint32{0}, shortest: math.MaxUint32}
+}
+
+// ErrTooLarge is returned when a file outgrows the 32-bit offsets of the
+// index
+var ErrTooLarge = errors.New("fortune file over 4 GB")
+
+// Add writes an entry, as made by Format. Blank entries are skipped, since
+// fortune would show them as empty fortunes.
+func (w *Writer) Add(entry string) error {
+	entry = strings.TrimRight(entry, "\n")
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+	text := entry + "\n"
+	size := uint64(w.pos) + uint64(len(text)) + 2
+	if size > math.MaxUint32 {
+		return ErrTooLarge
+	}
+	if _, err := w.w.WriteString(text + "%\n"); err != nil {
+		return err
+	}
+	// strfile counts an entry's length with its last newline, without the
+	// delimiter line
+	n := uint32(len(text))
+	w.longest = max(w.longest, n)
+	w.shortest = min(w.shortest, n)
+	w.pos = uint32(size)
+	w.offsets = append(w.offsets, w.pos)
+	return nil
+}
+
+// Len returns how many entries were added
+func (w *Writer) Len() int {
+	return len(w.offsets) - 1
+}
+
+// Flush writes any buffered entries
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// WriteIndex writes the strfile index of the entries added so far, the .dat
+// file fortune looks for next to the fortune file: a header of big-endian
+// version, entry count, longest and shortest entry lengths, flags and the
+// delimiter, then the offset of every entry and of the end of the file.
+func (w *Writer) WriteIndex(out io.Writer) error {
+	shortest := w.shortest
+	if w.Len() == 0 {
+		shortest = 0
+	}
+	header := []uint32{version, uint32(w.Len()), w.longest, shortest, 0}
+	b := bufio.NewWriter(out)
+	if err := binary.Write(b, binary.BigEndian, header); err != nil {
+		return err
+	}
+	if _, err := b.Write([]byte{'%', 0, 0, 0}); err != nil {
+		return err
+	}
+	if err := binary.Write(b, binary.BigEndian, w.offsets); err != nil {
+		return err
+	}
+	return b.Flush()
+}
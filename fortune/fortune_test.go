@@ -0,0 +1,64 @@
+package fortune
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	got := Format("Az olsun, öz olsun; çok olsun, boş olsun.", "Atasözü", 20)
+	want := "Az olsun, öz olsun;\nçok olsun, boş\nolsun.\n\t\t-- Atasözü"
+	if got != want {
+		t.Errorf("Format = %q, want %q", got, want)
+	}
+	if got := Format("50\n%\nof it", "", 20); got != "50\n %\nof it" {
+		t.Errorf("a lone %% line was not escaped: %q", got)
+	}
+}
+
+func TestWriterIndex(t *testing.T) {
+	var file, index bytes.Buffer
+	w := NewWriter(&file)
+	for _, e := range []string{"One.\n\t\t-- A", "", "Three, the longest.", "Ş"} {
+		if err := w.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteIndex(&index); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "One.\n\t\t-- A\n%\nThree, the longest.\n%\nŞ\n%\n"; file.String() != want {
+		t.Errorf("file = %q, want %q", file.String(), want)
+	}
+
+	var header struct {
+		Version, Count, Longest, Shortest, Flags uint32
+		Delim                                    [4]byte
+	}
+	r := bytes.NewReader(index.Bytes())
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != 2 || header.Count != 3 || header.Longest != 20 || header.Shortest != 3 || header.Delim[0] != '%' {
+		t.Errorf("header = %+v", header)
+	}
+	offsets := make([]uint32, header.Count+1)
+	if err := binary.Read(r, binary.BigEndian, offsets); err != nil {
+		t.Fatal(err)
+	}
+	for i, off := range offsets[:header.Count] {
+		entry := file.String()[off:offsets[i+1]]
+		if !strings.HasSuffix(entry, "\n%\n") {
+			t.Errorf("entry %d at %d = %q, want it to run to its delimiter", i, off, entry)
+		}
+	}
+	if int(offsets[header.Count]) != file.Len() {
+		t.Errorf("last offset = %d, want the file length %d", offsets[header.Count], file.Len())
+	}
+}
@@ -0,0 +1,131 @@
+package frases
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+
+	"quotesparser/fetch"
+	"quotesparser/source"
+)
+
+func init() {
+	source.RegisterAdapter(Adapter{})
+}
+
+// Adapter is fraseslibros for quotes download: a target is an author, by
+// slug or URL, or an index letter standing for every author listed under it
+type Adapter struct{}
+
+func (Adapter) Name() string { return "fraseslibros" }
+
+// Discover visits an author's pages until one has no quotes; for a letter,
+// it walks the index pages and does so for each author with quotes
+func (a Adapter) Discover(ctx context.Context, c *fetch.Client, target string, visit func(string) (int, error)) error {
+	target = strings.TrimSpace(target)
+	if len([]rune(target)) != 1 {
+		return authorPages(ctx, authorURL(strings.TrimPrefix(target, "/")), visit)
+	}
+
+	letter := strings.ToLower(target)
+	indexLink := BaseURL + "/autores/" + letter
+	for page := 1; ctx.Err() == nil; page++ {
+		body, err := a.Fetch(ctx, c, IndexURL(letter, page))
+		if err != nil {
+			return err
+		}
+		content := DecodeLatin1(body)
+		authors, err := ParseAuthors(content)
+		if err != nil {
+			return err
+		}
+		for _, author := range authors {
+			if author.QuoteCount == 0 || !strings.HasPrefix(author.Link, BaseURL) {
+				continue
+			}
+			if err := authorPages(ctx, author.Link, visit); err != nil {
+				return err
+			}
+		}
+		if !HasPage(content, indexLink, page+1) {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// authorPages visits link, link/2, ... until a page has no quotes
+func authorPages(ctx context.Context, link string, visit func(string) (int, error)) error {
+	link = strings.TrimSuffix(link, "/")
+	for page := 1; ctx.Err() == nil; page++ {
+		pageURL := link
+		if page > 1 {
+			pageURL = link + "/" + strconv.Itoa(page)
+		}
+		n, err := visit(pageURL)
+		if err != nil || n == 0 {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (Adapter) Fetch(ctx context.Context, c *fetch.Client, url string) ([]byte, error) {
+	return source.FetchHTML(ctx, c, url)
+}
+
+// Parse reads an author page, crediting its quotes to the name in its
+// heading ("Frases de <name>"), or to the one in url's slug when it has none
+func (Adapter) Parse(url string, page []byte) ([]source.Quote, error) {
+	content := DecodeLatin1(page)
+	link := pageNumRe.ReplaceAllString(strings.TrimSuffix(url, "/"), "")
+	quotes, err := ParseQuotes(content, link)
+	if err != nil {
+		return nil, err
+	}
+	author := headingName(content)
+	if author == "" && link != "" {
+		author = slugName(link[strings.LastIndex(link, "/")+1:])
+	}
+	found := make([]source.Quote, len(quotes))
+	for i, q := range quotes {
+		found[i] = source.Quote{Text: q.Text, Author: author, Book: q.BookName, Lang: "es"}
+	}
+	return found, nil
+}
+
+// pageNumRe matches the page number of a later author page
+var pageNumRe = regexp.MustCompile(`/\d+$`)
+
+var headingSel = source.MustCompile("h1")
+
+// headingPrefixRe matches what author pages put before the name
+var headingPrefixRe = regexp.MustCompile(`(?i)^(frases|citas) de\s+`)
+
+// headingName returns the author named by a page's first <h1>
+func headingName(content string) string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	if h := headingSel.First(doc); h != nil {
+		name := spaceRe.ReplaceAllString(strings.TrimSpace(getTextContent(h)), " ")
+		return headingPrefixRe.ReplaceAllString(name, "")
+	}
+	return ""
+}
+
+// slugName turns an author slug such as aldous-huxley into Aldous Huxley
+func slugName(slug string) string {
+	words := strings.Fields(strings.ReplaceAll(slug, "-", " "))
+	for i, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
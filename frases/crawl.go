@@ -0,0 +1,169 @@
+package frases
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"quotesparser/fetch"
+	"quotesparser/quota"
+)
+
+// Crawler downloads index and author pages, keeping a copy of every page on
+// disk so interrupted crawls resume without refetching
+type Crawler struct {
+	Client *fetch.Client
+	Guard  *quota.Guard // optional disk space and quota limits
+	Delay  time.Duration
+}
+
+// NewCrawler returns a Crawler with the defaults of the original script
+func NewCrawler() *Crawler {
+	client := fetch.NewClient()
+	client.Validate = fetch.CompleteHTML
+	return &Crawler{
+		Client: client,
+		Delay:  1 * time.Second,
+	}
+}
+
+// IndexURL returns the URL of the given page of authors starting with letter
+func IndexURL(letter string, page int) string {
+	return fmt.Sprintf("%s/autores/%s/%d", BaseURL, letter, page)
+}
+
+// DownloadIndex saves up to pages index pages of letter as <outDir>/<letter><N>.text,
+// stopping at the last page the site links to, or once ctx is done
+func (c *Crawler) DownloadIndex(ctx context.Context, letter string, pages int, outDir string) (int, error) {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create folder: %v", err)
+	}
+
+	saved := 0
+	indexLink := fmt.Sprintf("%s/autores/%s", BaseURL, letter)
+	for pageNum := 1; pageNum <= pages; pageNum++ {
+		if err := ctx.Err(); err != nil {
+			return saved, err
+		}
+		filePath := filepath.Join(outDir, fmt.Sprintf("%s%d.text", letter, pageNum))
+		content, err := c.download(ctx, IndexURL(letter, pageNum), filePath)
+		if err != nil {
+			return saved, fmt.Errorf("%s page %d: %w", letter, pageNum, err)
+		}
+		saved++
+		slog.Info("downloaded", "file", filePath)
+
+		if !HasPage(content, indexLink, pageNum+1) {
+			break
+		}
+	}
+	return saved, nil
+}
+
+// CrawlAuthor walks every page of an author and collects their quotes,
+// stopping with ctx's error once ctx is done
+func (c *Crawler) CrawlAuthor(ctx context.Context, author Author, cacheFolder string) ([]Quote, error) {
+	var quotes []Quote
+	seen := make(map[string]bool)
+
+	slug := filepath.Base(strings.TrimSuffix(author.Link, "/"))
+	pageURL := author.Link
+
+	for pageNum := 1; ; pageNum++ {
+		if err := ctx.Err(); err != nil {
+			return quotes, err
+		}
+		cachePath := filepath.Join(cacheFolder, fmt.Sprintf("%s_%d.text", slug, pageNum))
+		content, cached, err := c.fetchPage(ctx, pageURL, cachePath)
+		if err != nil {
+			return quotes, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+
+		pageQuotes, err := ParseQuotes(content, author.Link)
+		if err != nil {
+			return quotes, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+
+		added := 0
+		for _, q := range pageQuotes {
+			if !seen[q.Text] {
+				seen[q.Text] = true
+				quotes = append(quotes, q)
+				added++
+			}
+		}
+
+		// Stop when the page brought nothing new or there is no next page
+		if added == 0 || !HasPage(content, author.Link, pageNum+1) {
+			break
+		}
+		pageURL = fmt.Sprintf("%s/%d", strings.TrimSuffix(author.Link, "/"), pageNum+1)
+
+		// Add a small delay to avoid overwhelming the server
+		if !cached {
+			fetch.Sleep(ctx, c.Delay)
+		}
+	}
+
+	return quotes, nil
+}
+
+// CrawlAll runs the second crawl stage over every author with quotes. Once
+// ctx is done it returns the quotes collected so far with ctx's error.
+func (c *Crawler) CrawlAll(ctx context.Context, authors []Author, cacheFolder string) ([]Quote, error) {
+	if err := os.MkdirAll(cacheFolder, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create folder: %v", err)
+	}
+
+	var allQuotes []Quote
+	for i, author := range authors {
+		if author.QuoteCount == 0 || !strings.HasPrefix(author.Link, BaseURL) {
+			continue
+		}
+
+		quotes, err := c.CrawlAuthor(ctx, author, cacheFolder)
+		if err != nil {
+			if quota.IsLimit(err) || ctx.Err() != nil {
+				return append(allQuotes, quotes...), err
+			}
+			slog.Error("failed to crawl author", "author", author.Name, "err", err)
+		}
+
+		slog.Info("crawled author", "n", i+1, "of", len(authors), "author", author.Name, "quotes", len(quotes), "listed", author.QuoteCount)
+		allQuotes = append(allQuotes, quotes...)
+	}
+
+	return allQuotes, nil
+}
+
+// fetchPage returns the HTML of a page, reusing the cached copy if present
+func (c *Crawler) fetchPage(ctx context.Context, pageURL string, cachePath string) (string, bool, error) {
+	if content, err := os.ReadFile(cachePath); err == nil {
+		return DecodeLatin1(content), true, nil
+	}
+	content, err := c.download(ctx, pageURL, cachePath)
+	return content, false, err
+}
+
+// download fetches pageURL into filePath within the guard's limits
+func (c *Crawler) download(ctx context.Context, pageURL string, filePath string) (string, error) {
+	if err := c.Guard.Check(0); err != nil {
+		return "", err
+	}
+	body, err := c.Client.GetContext(ctx, pageURL)
+	if err != nil {
+		return "", err
+	}
+	if err := c.Guard.Check(int64(len(body))); err != nil {
+		return "", err
+	}
+	if err := quota.WriteFile(filePath, body); err != nil {
+		return "", err
+	}
+	c.Guard.Add(int64(len(body)))
+	return DecodeLatin1(body), nil
+}
@@ -0,0 +1,286 @@
+// Package frases crawls and parses the Spanish author and quote pages of
+// fraseslibros.com.
+package frases
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+
+	"quotesparser/pipeline"
+	"quotesparser/source"
+)
+
+// BaseURL is the root of the fraseslibros site
+const BaseURL = "https://fraseslibros.com"
+
+// Author represents an author with their quote count
+type Author struct {
+	Name       string `json:"name"`
+	QuoteCount int    `json:"quoteCount"`
+	Link       string `json:"link"`
+}
+
+// Quote represents a quote scraped from an author's page
+type Quote struct {
+	AuthorLink string `json:"authorLink"`
+	Text       string `json:"text"`
+	BookName   string `json:"bookName,omitempty"`
+}
+
+var (
+	countRe  = regexp.MustCompile(`\((\d+)\)`)
+	letterRe = regexp.MustCompile(`[a-zA-ZÀ-ÿ]`)
+	spaceRe  = regexp.MustCompile(`\s+`)
+)
+
+// Selectors of the parts of an author page
+var (
+	quoteSel = source.MustCompile(`[class*="frase"]`)
+	bookSel  = source.MustCompile(`a[href*="/libro"]`)
+)
+
+// ParseAuthors extracts the authors listed on an /autores index page
+func ParseAuthors(htmlContent string) ([]Author, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %v", err)
+	}
+
+	var authors []Author
+	seenInFile := make(map[string]bool)
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		// Look for <div> tags containing author info
+		if n.Type == html.ElementNode && n.Data == "div" {
+			// Check if this div contains an <a> tag with author link
+			var authorLink *html.Node
+			var authorName string
+			var authorHref string
+			var quoteCount int
+
+			// Search for <a> tag inside this div
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && c.Data == "a" {
+					href := getAttr(c, "href")
+					// Check if it's an author link (not /autor/ but direct author link)
+					if href != "" && !strings.Contains(href, "telf") {
+						authorLink = c
+						authorName = getTextContent(c)
+						authorName = strings.TrimSpace(authorName)
+						authorHref = href
+						break
+					}
+				}
+			}
+
+			// If we found an author link, look for the quote count
+			if authorLink != nil && authorName != "" {
+				// Extract quote count from pattern like "(123)"
+				matches := countRe.FindStringSubmatch(getTextContent(n))
+				if len(matches) >= 2 {
+					quoteCount, _ = strconv.Atoi(matches[1])
+				}
+
+				fullLink := authorURL(authorHref)
+
+				// Filter valid names (at least 3 chars, contains letters)
+				if len(authorName) >= 3 && letterRe.MatchString(authorName) {
+					key := authorName
+					if !seenInFile[key] {
+						seenInFile[key] = true
+						authors = append(authors, Author{
+							Name:       authorName,
+							QuoteCount: quoteCount,
+							Link:       fullLink,
+						})
+					}
+				}
+			}
+		}
+
+		// Continue traversing
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+	return authors, nil
+}
+
+// authorURL makes the link of an author page absolute
+func authorURL(href string) string {
+	switch {
+	case strings.HasPrefix(href, "http"):
+		return href
+	case strings.HasPrefix(href, "/"):
+		return BaseURL + href
+	default:
+		return BaseURL + "/" + href
+	}
+}
+
+// ParseAuthorsFromFile streams the authors of a saved index page
+func ParseAuthorsFromFile(filename string) ([]Author, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", filename, err)
+	}
+	defer f.Close()
+	return StreamAuthors(bufio.NewReader(f))
+}
+
+// ParseAuthorsFromFolder parses every saved index page (*.text) in folderPath
+// on workers goroutines (one per CPU core when 0), keeping the first
+// occurrence of each author in file order
+func ParseAuthorsFromFolder(folderPath string, workers int) ([]Author, error) {
+	var allAuthors []Author
+	globalSeen := make(map[string]bool)
+
+	files, err := filepath.Glob(filepath.Join(folderPath, "*.text"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %v", err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .text files found in %s", folderPath)
+	}
+
+	slog.Info("processing files", "count", len(files))
+
+	for _, parsed := range pipeline.ParseFiles(files, workers, ParseAuthorsFromFile) {
+		if parsed.Err != nil {
+			slog.Error("failed to parse file", "file", parsed.File, "err", parsed.Err)
+			continue
+		}
+
+		slog.Info("parsed file", "file", filepath.Base(parsed.File), "authors", len(parsed.Items))
+
+		for _, author := range parsed.Items {
+			key := author.Name
+			// Track globally to avoid duplicates across all files
+			if !globalSeen[key] {
+				globalSeen[key] = true
+				allAuthors = append(allAuthors, author)
+			}
+		}
+	}
+
+	return allAuthors, nil
+}
+
+// ParseQuotes extracts the quotes listed on an author page
+func ParseQuotes(htmlContent string, authorLink string) ([]Quote, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %v", err)
+	}
+
+	var quotes []Quote
+	seenInPage := make(map[string]bool)
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if quoteSel.Match(n) {
+			text := strings.TrimSpace(getTextContent(n))
+			text = spaceRe.ReplaceAllString(text, " ")
+			text = strings.Trim(text, " \"«»“”")
+
+			// The book, when known, is linked right after the quote
+			var bookName string
+			for s := n.NextSibling; s != nil; s = s.NextSibling {
+				if s.Type != html.ElementNode {
+					continue
+				}
+				if a := findBookLink(s); a != nil {
+					bookName = strings.TrimSpace(getTextContent(a))
+				}
+				break
+			}
+
+			if len(text) > 10 && !seenInPage[text] {
+				seenInPage[text] = true
+				quotes = append(quotes, Quote{
+					AuthorLink: authorLink,
+					Text:       text,
+					BookName:   bookName,
+				})
+			}
+			return
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+
+	traverse(doc)
+	return quotes, nil
+}
+
+// HasPage reports whether the page links to the given page number of link
+// Example: https://fraseslibros.com/aldous-huxley -> /aldous-huxley/2
+func HasPage(htmlContent string, link string, pageNum int) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	target := fmt.Sprintf("%s/%d", strings.TrimSuffix(u.Path, "/"), pageNum)
+	return strings.Contains(htmlContent, `href="`+target+`"`) ||
+		strings.Contains(htmlContent, `href="`+BaseURL+target+`"`)
+}
+
+// DecodeLatin1 converts ISO-8859-1 pages (what fraseslibros serves) to UTF-8
+func DecodeLatin1(content []byte) string {
+	if utf8.Valid(content) {
+		return string(content)
+	}
+	runes := make([]rune, len(content))
+	for i, b := range content {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// findBookLink returns n or the first element inside it linking to a book
+func findBookLink(n *html.Node) *html.Node {
+	if bookSel.Match(n) {
+		return n
+	}
+	return bookSel.First(n)
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func getTextContent(n *html.Node) string {
+	var text strings.Builder
+	var traverse func(*html.Node)
+	traverse = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			text.WriteString(node.Data)
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(n)
+	return text.String()
+}
@@ -0,0 +1,124 @@
+package frases
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// InsertAuthors upserts authors into frasesauthors so viewCount and manual
+// edits survive later runs. The database must be migrated.
+func InsertAuthors(db *sql.DB, authors []Author) (inserted int, updated int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	var before int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM frasesauthors").Scan(&before); err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("failed to count authors: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO frasesauthors (authorName, authorLink, quoteCount) VALUES (?, ?, ?)
+		ON CONFLICT(authorName) DO UPDATE SET authorLink = excluded.authorLink, quoteCount = excluded.quoteCount
+	`)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+
+	processed := 0
+	for _, author := range authors {
+		if author.Name != "" && author.Link != "" && strings.HasPrefix(author.Link, BaseURL) {
+			_, err = stmt.Exec(author.Name, author.Link, author.QuoteCount)
+			if err != nil {
+				slog.Warn("failed to insert author", "author", author.Name, "err", err)
+				continue
+			}
+			processed++
+		}
+	}
+
+	var after int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM frasesauthors").Scan(&after); err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("failed to count authors: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return after - before, processed - (after - before), nil
+}
+
+// InsertQuotes upserts quotes into frasesquotes, attaching each to the author
+// row with the same link. Quotes of unknown authors are skipped.
+func InsertQuotes(db *sql.DB, quotes []Quote) (inserted int, updated int, err error) {
+	// Map author links to their row ids
+	authorIDs := make(map[string]int64)
+	rows, err := db.Query("SELECT id, authorLink FROM frasesauthors")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read authors: %v", err)
+	}
+	for rows.Next() {
+		var id int64
+		var link string
+		if err := rows.Scan(&id, &link); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to read authors: %v", err)
+		}
+		authorIDs[link] = id
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	var before int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM frasesquotes").Scan(&before); err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("failed to count quotes: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO frasesquotes (authorId, text, bookName) VALUES (?, ?, ?)
+		ON CONFLICT(authorId, text) DO UPDATE SET bookName = COALESCE(excluded.bookName, bookName)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+
+	processed := 0
+	for _, quote := range quotes {
+		authorID, ok := authorIDs[quote.AuthorLink]
+		if !ok {
+			slog.Warn("no author row", "link", quote.AuthorLink)
+			continue
+		}
+		_, err = stmt.Exec(authorID, quote.Text, sql.NullString{String: quote.BookName, Valid: quote.BookName != ""})
+		if err != nil {
+			slog.Warn("failed to insert quote", "err", err)
+			continue
+		}
+		processed++
+	}
+
+	var after int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM frasesquotes").Scan(&after); err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("failed to count quotes: %v", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return after - before, processed - (after - before), nil
+}
@@ -0,0 +1,147 @@
+package frases
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"quotesparser/source"
+)
+
+// countTail is how much of a div's text is kept to find a "(123)" split
+// across text tokens
+const countTail = 32
+
+// openElement is what StreamAuthors follows of an element whose end tag is
+// still to come
+type openElement struct {
+	source.OpenElement
+	order int // of the divs' start tags, which orders the authors as ParseAuthors does
+
+	linked     bool             // a div whose first author link was seen
+	name       *strings.Builder // the text of that link
+	href       string
+	counted    bool // a div whose first "(123)" was seen
+	count      int
+	tail       string
+	authorLink *openElement // for an <a>, the div it names
+}
+
+// StreamAuthors parses an /autores index page like ParseAuthors, reading it
+// token by token instead of building its DOM, so memory stays flat however
+// large the page is
+func StreamAuthors(r io.Reader) ([]Author, error) {
+	type found struct {
+		order int
+		Author
+	}
+	var stack []*openElement
+	var authors []found
+	divs := 0
+
+	pop := func() {
+		el := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if el.Tag != "div" || el.name == nil {
+			return
+		}
+		name := strings.TrimSpace(el.name.String())
+		if len(name) >= 3 && letterRe.MatchString(name) {
+			authors = append(authors, found{el.order, Author{Name: name, QuoteCount: el.count, Link: authorURL(el.href)}})
+		}
+	}
+	closeTag := func(tag string) {
+		if i := source.Innermost(stack, tag); i >= 0 {
+			for len(stack) > i {
+				pop()
+			}
+		}
+	}
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				return nil, fmt.Errorf("failed to parse HTML: %v", z.Err())
+			}
+			for len(stack) > 0 {
+				pop()
+			}
+			sort.SliceStable(authors, func(i, j int) bool { return authors[i].order < authors[j].order })
+			var list []Author
+			seenInFile := make(map[string]bool)
+			for _, a := range authors {
+				if !seenInFile[a.Name] {
+					seenInFile[a.Name] = true
+					list = append(list, a.Author)
+				}
+			}
+			return list, nil
+
+		case html.TextToken:
+			text := string(z.Text())
+			for _, el := range stack {
+				switch {
+				case el.authorLink != nil:
+					el.authorLink.name.WriteString(text)
+				case el.Tag == "div" && !el.counted:
+					searched := el.tail + text
+					if m := countRe.FindStringSubmatch(searched); m != nil {
+						el.count, _ = strconv.Atoi(m[1])
+						el.counted, el.tail = true, ""
+						continue
+					}
+					if len(searched) > countTail {
+						searched = searched[len(searched)-countTail:]
+					}
+					el.tail = searched
+				}
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if !source.Opens(tt, tok.Data) {
+				continue
+			}
+			if tok.Data == "a" {
+				// Links do not nest: the parser closes the open one
+				closeTag("a")
+			}
+			el := &openElement{OpenElement: source.OpenElement{Tag: tok.Data}}
+			switch tok.Data {
+			case "div":
+				el.order = divs
+				divs++
+			case "a":
+				href := tokenAttr(tok, "href")
+				if len(stack) == 0 || href == "" || strings.Contains(href, "telf") {
+					break
+				}
+				if div := stack[len(stack)-1]; div.Tag == "div" && !div.linked {
+					div.linked, div.href, div.name = true, href, &strings.Builder{}
+					el.authorLink = div
+				}
+			}
+			stack = append(stack, el)
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			closeTag(string(name))
+		}
+	}
+}
+
+func tokenAttr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
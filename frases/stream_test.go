@@ -0,0 +1,36 @@
+package frases
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestStreamAuthorsMatchesParse checks that streaming an index page finds
+// the authors parsing its DOM does
+func TestStreamAuthorsMatchesParse(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html><head><meta charset="iso-8859-1"><title>Frases Libros</title></head><body>
+<div id="list"><div><a id="telf" href="/abel-cutillas" title="Abel Cutillas">Abel Cutillas</a> (0)</div><div><a id="telf" href="/albert-camus">Albert <b>Camus</b></a> (1<!-- -->2)</div>
+<div><a href="/telf/skip">Telf</a><a href="aldous-huxley">Aldous Huxley</a> (2)</div>
+<div><span>(7)</span><a href="https://fraseslibros.com/amos-oz">Amos Oz</a></div>
+<div><a href="/xy">Xy</a> (3)</div><div><a href="/albert-camus">Albert Camus</a> (9)</div>
+<div><p><a href="/not-direct">Not Direct</a></p> (4)</div></div>
+<div id="pags"><a href="/autores/a">1</a> <a href="/autores/a/2">2</a></div>
+</body></html>`
+
+	want, err := ParseAuthors(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := StreamAuthors(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) == 0 {
+		t.Fatal("DOM parse found no authors")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("streamed\n%v\nwant\n%v", got, want)
+	}
+}
@@ -3,6 +3,10 @@ module quotesparser
 go 1.25.2
 
 require (
-	github.com/mattn/go-sqlite3 v1.14.32 // indirect
-	golang.org/x/net v0.47.0 // indirect
+	github.com/BurntSushi/toml v1.6.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.32
+	golang.org/x/net v0.47.0
+	golang.org/x/text v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
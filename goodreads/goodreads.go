@@ -0,0 +1,116 @@
+// Package goodreads downloads and parses the quote pages of Goodreads
+// authors and books, with how many readers liked each quote.
+package goodreads
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"quotesparser/source"
+)
+
+// BaseURL is the site's address
+const BaseURL = "https://www.goodreads.com"
+
+func init() {
+	source.Register(Source{})
+}
+
+// Source is Goodreads for quotes download, parse and import. A target is a
+// quote page by URL or path: an author's (/author/quotes/1244.Mark_Twain),
+// a book's (/work/quotes/1876279) or a tag's (/quotes/tag/love). An
+// author's profile, /author/show/1244.Mark_Twain, stands for their quotes.
+type Source struct{}
+
+func (Source) Name() string { return "goodreads" }
+
+// PageURL returns page n of the target's quotes, which Goodreads asks for
+// with ?page=n
+func (Source) PageURL(target string, page int) (string, error) {
+	ref, err := url.Parse(strings.TrimSpace(target))
+	if err != nil {
+		return "", fmt.Errorf("bad target %q: %v", target, err)
+	}
+	if ref.Host != "" && !strings.HasSuffix(ref.Host, "goodreads.com") {
+		return "", fmt.Errorf("%s is not a Goodreads page", target)
+	}
+	path := "/" + strings.TrimPrefix(ref.Path, "/")
+	if strings.HasPrefix(path, "/author/show/") {
+		path = "/author/quotes/" + strings.TrimPrefix(path, "/author/show/")
+	}
+	if !strings.Contains(path, "quotes") {
+		return "", fmt.Errorf("%s is not a quote page: use /author/quotes/<id>, /work/quotes/<id> or /quotes/tag/<tag>", target)
+	}
+	u, err := url.Parse(BaseURL + path)
+	if err != nil {
+		return "", err
+	}
+	if page > 1 {
+		u.RawQuery = url.Values{"page": {strconv.Itoa(page)}}.Encode()
+	}
+	return u.String(), nil
+}
+
+var (
+	quoteSel  = source.MustCompile("div.quote")
+	textSel   = source.MustCompile("div.quoteText")
+	authorSel = source.MustCompile("div.quoteText span.authorOrTitle")
+	bookSel   = source.MustCompile("div.quoteText a.authorOrTitle")
+	likesSel  = source.MustCompile("div.quoteFooter div.right a")
+
+	likesRe = regexp.MustCompile(`([\d,.]+)\s+likes?`)
+)
+
+// Parse extracts the quotes of a page: their text, author, book when the
+// quote names one, and likes
+func (Source) Parse(page []byte) ([]source.Quote, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %v", err)
+	}
+	var quotes []source.Quote
+	for _, n := range quoteSel.SelectAll(doc) {
+		body := textSel.First(n)
+		if body == nil {
+			continue
+		}
+		text := quoteText(body)
+		if text == "" {
+			continue
+		}
+		q := source.Quote{Text: text}
+		if a := authorSel.First(n); a != nil {
+			q.Author = strings.TrimRight(clean(source.Text(a)), ", ")
+		} else {
+			q.Missing = append(q.Missing, "author")
+		}
+		if b := bookSel.First(n); b != nil {
+			q.Book = clean(source.Text(b))
+		}
+		if l := likesSel.First(n); l != nil {
+			if m := likesRe.FindStringSubmatch(source.Text(l)); m != nil {
+				q.Likes, _ = strconv.Atoi(strings.NewReplacer(",", "", ".", "").Replace(m[1]))
+			}
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
+
+// quoteText returns the quote in a div.quoteText: the text before the dash
+// that leads to its author, without the curly quotes around it
+func quoteText(n *html.Node) string {
+	text, _, _ := strings.Cut(source.Text(n), "―")
+	return strings.Trim(clean(text), "“”\" ")
+}
+
+// clean collapses the whitespace of text as the page lays it out
+func clean(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,69 @@
+package goodreads
+
+import (
+	"reflect"
+	"testing"
+
+	"quotesparser/source"
+)
+
+func TestParse(t *testing.T) {
+	page := []byte(`<html><body><div class="leftContainer">
+<div class="quote mediumText">
+  <div class="quoteDetails">
+    <div class="quoteText">
+      &ldquo;The secret of getting ahead
+      is getting started.&rdquo;
+      <br>  &#8213;
+      <span class="authorOrTitle">
+        Mark Twain,
+      </span>
+      <span id="quote_book_link_1"><a class="authorOrTitle" href="/work/quotes/1876279">The Adventures of Tom Sawyer</a></span>
+    </div>
+    <div class="quoteFooter">
+      <div class="greyText smallText left">tags: <a href="/quotes/tag/success">success</a></div>
+      <div class="right"><a class="smallText" title="View this quote" href="/quotes/1-the-secret">2,315 likes</a></div>
+    </div>
+  </div>
+</div>
+<div class="quote mediumText">
+  <div class="quoteDetails">
+    <div class="quoteText">&ldquo;Courage is resistance to fear.&rdquo; <br> &#8213; <span class="authorOrTitle">Mark Twain</span></div>
+    <div class="quoteFooter"><div class="right"><a class="smallText" href="/quotes/2">1 like</a></div></div>
+  </div>
+</div>
+</div></body></html>`)
+
+	got, err := Source{}.Parse(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []source.Quote{
+		{Text: "The secret of getting ahead is getting started.", Author: "Mark Twain", Book: "The Adventures of Tom Sawyer", Likes: 2315},
+		{Text: "Courage is resistance to fear.", Author: "Mark Twain", Likes: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	for _, tc := range []struct {
+		target string
+		page   int
+		want   string
+	}{
+		{"/author/quotes/1244.Mark_Twain", 1, BaseURL + "/author/quotes/1244.Mark_Twain"},
+		{"https://www.goodreads.com/author/show/1244.Mark_Twain", 3, BaseURL + "/author/quotes/1244.Mark_Twain?page=3"},
+		{"work/quotes/1876279", 2, BaseURL + "/work/quotes/1876279?page=2"},
+	} {
+		if got, err := (Source{}).PageURL(tc.target, tc.page); err != nil || got != tc.want {
+			t.Errorf("PageURL(%q, %d) = %q, %v; want %q", tc.target, tc.page, got, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"https://example.com/author/quotes/1", "/book/show/24583"} {
+		if _, err := (Source{}).PageURL(bad, 1); err == nil {
+			t.Errorf("PageURL(%q) succeeded", bad)
+		}
+	}
+}
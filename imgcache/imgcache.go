@@ -0,0 +1,136 @@
+// Package imgcache keeps downloaded images, such as author portraits and book
+// covers, on disk in a few widths, one folder per database row:
+// <dir>/<id>/<width>.jpg. Caches shared by rows name the folders after the
+// images instead: <dir>/<hash>/<width>.jpg.
+package imgcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	// Commons and OpenLibrary serve JPEG, PNG or GIF
+	_ "image/gif"
+	_ "image/png"
+
+	"quotesparser/quota"
+)
+
+// Path returns where the variant of row id's image that is width pixels wide
+// is cached under dir
+func Path(dir string, id int64, width int) string {
+	return filepath.Join(dir, strconv.FormatInt(id, 10), strconv.Itoa(width)+".jpg")
+}
+
+// HashPath returns where the variant that is width pixels wide of the image
+// whose Hash is hash is cached under dir
+func HashPath(dir, hash string, width int) string {
+	return filepath.Join(dir, hash, strconv.Itoa(width)+".jpg")
+}
+
+// Hash names img in a shared cache by its content, so the rows pointing to
+// the same image share its variants
+func Hash(img []byte) string {
+	sum := sha256.Sum256(img)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Save scales img down to each of widths and writes the variants under dir,
+// keeping the aspect ratio. Widths larger than the image are skipped, so
+// nothing is upscaled; the widths written are returned. guard may be nil.
+func Save(dir string, id int64, img []byte, widths []int, guard *quota.Guard) ([]int, error) {
+	return save(filepath.Dir(Path(dir, id, 0)), img, widths, guard)
+}
+
+// SaveHashed is Save for a shared cache, writing the variants under the
+// Hash of img, which it returns
+func SaveHashed(dir string, img []byte, widths []int, guard *quota.Guard) (string, []int, error) {
+	hash := Hash(img)
+	saved, err := save(filepath.Dir(HashPath(dir, hash, 0)), img, widths, guard)
+	return hash, saved, err
+}
+
+// save writes the variants of img into folder
+func save(folder string, img []byte, widths []int, guard *quota.Guard) ([]int, error) {
+	src, _, err := image.Decode(bytes.NewReader(img))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create folder: %v", err)
+	}
+
+	var saved []int
+	for _, width := range widths {
+		b := src.Bounds()
+		if width > b.Dx() {
+			continue
+		}
+		height := max(1, (b.Dy()*width+b.Dx()/2)/b.Dx())
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scale(src, width, height), &jpeg.Options{Quality: 85}); err != nil {
+			return saved, fmt.Errorf("failed to encode image: %v", err)
+		}
+		if err := guard.Check(int64(buf.Len())); err != nil {
+			return saved, err
+		}
+		if err := quota.WriteFile(filepath.Join(folder, strconv.Itoa(width)+".jpg"), buf.Bytes()); err != nil {
+			return saved, err
+		}
+		guard.Add(int64(buf.Len()))
+		saved = append(saved, width)
+	}
+	return saved, nil
+}
+
+// scale shrinks src to width x height by averaging the source pixels each
+// destination pixel covers, which keeps faces and titles smooth at thumbnail sizes
+func scale(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/width)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// JPEG has no alpha: lay transparent parts over white
+			white := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + white), G: uint16(g/n + white), B: uint16(bl/n + white), A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
+
+// Load decodes the cached variant of row id's image that is width pixels wide
+func Load(dir string, id int64, width int) (image.Image, error) {
+	f, err := os.Open(Path(dir, id, width))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", Path(dir, id, width), err)
+	}
+	return img, nil
+}
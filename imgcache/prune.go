@@ -0,0 +1,106 @@
+package imgcache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Entry is the cached images of one row
+type Entry struct {
+	ID      int64
+	Bytes   int64
+	Written time.Time // when the newest variant was written
+}
+
+// Entries lists the rows with images cached under dir, oldest first
+func Entries(dir string) ([]Entry, error) {
+	folders, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %v", err)
+	}
+
+	var entries []Entry
+	for _, folder := range folders {
+		id, err := strconv.ParseInt(folder.Name(), 10, 64)
+		if err != nil || !folder.IsDir() {
+			continue
+		}
+		e := Entry{ID: id}
+		files, err := os.ReadDir(filepath.Join(dir, folder.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache: %v", err)
+		}
+		for _, f := range files {
+			info, err := f.Info()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read cache: %v", err)
+			}
+			e.Bytes += info.Size()
+			if info.ModTime().After(e.Written) {
+				e.Written = info.ModTime()
+			}
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Written.Equal(entries[j].Written) {
+			return entries[i].Written.Before(entries[j].Written)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// PruneReport is what Prune removed
+type PruneReport struct {
+	Orphans []int64 // rows no longer in the database
+	Evicted []int64 // rows removed to fit the size limit
+	Freed   int64   // bytes
+	Kept    int64   // bytes left in the cache
+}
+
+// Prune removes the images of rows live reports gone, such as deleted or
+// merged authors, then the images written longest ago until the cache fits in
+// maxBytes (0 = no limit). Callers forget evicted rows so they are fetched
+// again when next asked for.
+func Prune(dir string, live func(id int64) bool, maxBytes int64) (PruneReport, error) {
+	var report PruneReport
+	entries, err := Entries(dir)
+	if err != nil {
+		return report, err
+	}
+
+	var kept []Entry
+	for _, e := range entries {
+		if live(e.ID) {
+			kept = append(kept, e)
+			report.Kept += e.Bytes
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, strconv.FormatInt(e.ID, 10))); err != nil {
+			return report, fmt.Errorf("failed to prune cache: %v", err)
+		}
+		report.Orphans = append(report.Orphans, e.ID)
+		report.Freed += e.Bytes
+	}
+
+	for _, e := range kept {
+		if maxBytes <= 0 || report.Kept <= maxBytes {
+			break
+		}
+		if err := os.RemoveAll(filepath.Join(dir, strconv.FormatInt(e.ID, 10))); err != nil {
+			return report, fmt.Errorf("failed to prune cache: %v", err)
+		}
+		report.Evicted = append(report.Evicted, e.ID)
+		report.Freed += e.Bytes
+		report.Kept -= e.Bytes
+	}
+	return report, nil
+}
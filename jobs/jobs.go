@@ -0,0 +1,175 @@
+// Package jobs queues long quotes commands, such as a full crawl or the
+// enrichment of authors, in the database for quotes serve to run in the
+// background, and keeps how far each got. quotes jobs and the /jobs routes
+// submit, list and cancel them.
+package jobs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Status is where a job is in its life
+type Status string
+
+const (
+	Queued    Status = "queued"
+	Running   Status = "running"
+	Done      Status = "done"
+	Failed    Status = "failed"
+	Cancelled Status = "cancelled"
+)
+
+// Finished reports whether s is a status a job never leaves
+func (s Status) Finished() bool {
+	return s == Done || s == Failed || s == Cancelled
+}
+
+// Commands are the quotes commands that may run as jobs: the long ones that
+// end by themselves. serve, watch and push run until stopped, and migrate
+// would change the schema under the server running it.
+var Commands = []string{
+	"download", "parse", "import", "crawl", "normalize", "dedup", "authors", "books",
+	"covers", "portraits", "assets", "trivia-media", "opentdb", "relink", "render", "fortune", "export", "archive", "recrawl",
+}
+
+var (
+	// ErrNotFound is returned for a job id the queue never had
+	ErrNotFound = errors.New("no such job")
+	// ErrFinished is returned when cancelling a job that already ended
+	ErrFinished = errors.New("job already finished")
+	// ErrCommand matches the error submitting a command not in Commands
+	ErrCommand = errors.New("command cannot run as a job")
+)
+
+// Job is a command in the queue
+type Job struct {
+	ID      int64    `json:"id"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Status  Status   `json:"status"`
+	// Progress, from 0 to 1, is how far the command said it got; commands
+	// going through several stages start over at each
+	Progress float64 `json:"progress"`
+	Message  string  `json:"message,omitempty"` // the last the command said, or why it failed
+	// Cancelling is set on a running job asked to stop, until it does
+	Cancelling  bool   `json:"cancelling,omitempty"`
+	SubmittedBy string `json:"submittedBy,omitempty"`
+	SubmittedAt string `json:"submittedAt"`
+	StartedAt   string `json:"startedAt,omitempty"`
+	FinishedAt  string `json:"finishedAt,omitempty"`
+}
+
+// Line is the command line of j, e.g. quotes crawl --limit 100
+func (j Job) Line() string {
+	return strings.Join(append([]string{"quotes", j.Command}, j.Args...), " ")
+}
+
+const columns = "id, command, args, status, progress, COALESCE(message, ''), cancel, COALESCE(submittedBy, ''), submittedAt, COALESCE(startedAt, ''), COALESCE(finishedAt, '')"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (Job, error) {
+	var j Job
+	var args string
+	if err := row.Scan(&j.ID, &j.Command, &args, &j.Status, &j.Progress, &j.Message, &j.Cancelling, &j.SubmittedBy, &j.SubmittedAt, &j.StartedAt, &j.FinishedAt); err != nil {
+		return j, err
+	}
+	if err := json.Unmarshal([]byte(args), &j.Args); err != nil {
+		return j, fmt.Errorf("bad arguments of job %d: %v", j.ID, err)
+	}
+	j.Cancelling = j.Cancelling && j.Status == Running
+	return j, nil
+}
+
+// now is the time as the jobs table keeps it
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// Submit queues command with args, submitted by the key or user named by
+func Submit(db *sql.DB, command string, args []string, by string) (Job, error) {
+	if !slices.Contains(Commands, command) {
+		return Job{}, fmt.Errorf("%w: %q (%s)", ErrCommand, command, strings.Join(Commands, ", "))
+	}
+	if args == nil {
+		args = []string{}
+	}
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return Job{}, fmt.Errorf("failed to encode arguments: %v", err)
+	}
+	var submittedBy sql.NullString
+	if by != "" {
+		submittedBy = sql.NullString{String: by, Valid: true}
+	}
+	j, err := scan(db.QueryRow("INSERT INTO jobs (command, args, submittedBy, submittedAt) VALUES (?, ?, ?, ?) RETURNING "+columns,
+		command, string(encoded), submittedBy, now()))
+	if err != nil {
+		return j, fmt.Errorf("failed to queue job: %v", err)
+	}
+	return j, nil
+}
+
+// Get returns job id, or ErrNotFound
+func Get(db *sql.DB, id int64) (Job, error) {
+	j, err := scan(db.QueryRow("SELECT "+columns+" FROM jobs WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return j, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	if err != nil {
+		return j, fmt.Errorf("failed to read job %d: %v", id, err)
+	}
+	return j, nil
+}
+
+// List returns the latest limit jobs of status, or of any status when it is
+// empty, newest first
+func List(db *sql.DB, status Status, limit int) ([]Job, error) {
+	rows, err := db.Query("SELECT "+columns+" FROM jobs WHERE ? = '' OR status = ? ORDER BY id DESC LIMIT ?", string(status), string(status), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jobs: %v", err)
+	}
+	defer rows.Close()
+	list := []Job{}
+	for rows.Next() {
+		j, err := scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jobs: %v", err)
+		}
+		list = append(list, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read jobs: %v", err)
+	}
+	return list, nil
+}
+
+// Cancel takes a queued job out of the queue and asks a running one to
+// stop, which its runner does within its interval. Cancelling a finished
+// job returns ErrFinished.
+func Cancel(db *sql.DB, id int64) (Job, error) {
+	res, err := db.Exec(`UPDATE jobs SET
+		status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+		finishedAt = CASE WHEN status = 'queued' THEN @now ELSE finishedAt END,
+		cancel = 1
+		WHERE id = @id AND status IN ('queued', 'running')`, sql.Named("now", now()), sql.Named("id", id))
+	if err != nil {
+		return Job{}, fmt.Errorf("failed to cancel job %d: %v", id, err)
+	}
+	j, err := Get(db, id)
+	if err != nil {
+		return j, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return j, fmt.Errorf("%w: %d is %s", ErrFinished, id, j.Status)
+	}
+	return j, nil
+}
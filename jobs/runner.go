@@ -0,0 +1,292 @@
+package jobs
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// errCancelled stops a job cancelled with Cancel
+	errCancelled = errors.New("cancelled")
+	// errStopped stops the jobs running when the runner stops
+	errStopped = errors.New("runner stopped")
+)
+
+// Runner runs the queued jobs, oldest first, each as a process of its own.
+// One runner takes the queue of a database: on start it queues again the
+// jobs a runner stopped before they ended, as do the jobs running when it
+// stops, and the commands pick up where they were stopped.
+type Runner struct {
+	DB *sql.DB
+	// Command makes the process running j with exec.CommandContext(ctx),
+	// which the runner interrupts to stop the job. It logs as JSON lines on
+	// stderr, as quotes --log-format json does, for the runner to read the
+	// job's progress from; its last line on stdout is the job's message.
+	Command func(ctx context.Context, j Job) *exec.Cmd
+	// Workers is how many jobs run at once, 1 when 0
+	Workers int
+	// Grace is how long a job asked to stop has to end before it is
+	// killed; 30 seconds when 0
+	Grace time.Duration
+
+	mu      sync.Mutex
+	running map[int64]context.CancelCauseFunc
+	wg      sync.WaitGroup
+}
+
+// Run starts jobs and stops those cancelled every interval until ctx is
+// done, then stops the running ones and waits for them
+func (r *Runner) Run(ctx context.Context, interval time.Duration) {
+	r.running = make(map[int64]context.CancelCauseFunc)
+	if _, err := r.DB.Exec("UPDATE jobs SET status = 'queued', startedAt = NULL WHERE status = 'running'"); err != nil {
+		slog.Error("failed to queue stopped jobs again", "err", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.tick(ctx); err != nil {
+			slog.Error("failed to run jobs", "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			r.mu.Lock()
+			for _, stop := range r.running {
+				stop(errStopped)
+			}
+			r.mu.Unlock()
+			r.wg.Wait()
+			return
+		}
+	}
+}
+
+// tick stops the jobs cancelled since the last one and starts queued jobs
+// while workers are free
+func (r *Runner) tick(runCtx context.Context) error {
+	rows, err := r.DB.Query("SELECT id FROM jobs WHERE status = 'running' AND cancel = 1")
+	if err != nil {
+		return fmt.Errorf("failed to read cancelled jobs: %v", err)
+	}
+	var cancelled []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read cancelled jobs: %v", err)
+		}
+		cancelled = append(cancelled, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read cancelled jobs: %v", err)
+	}
+	r.mu.Lock()
+	for _, id := range cancelled {
+		if stop, ok := r.running[id]; ok {
+			stop(errCancelled)
+		}
+	}
+	free := max(r.Workers, 1) - len(r.running)
+	r.mu.Unlock()
+
+	for ; free > 0; free-- {
+		j, err := scan(r.DB.QueryRow(`UPDATE jobs SET status = 'running', progress = 0, message = NULL, startedAt = ?
+			WHERE id = (SELECT id FROM jobs WHERE status = 'queued' ORDER BY id LIMIT 1)
+			RETURNING `+columns, now()))
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to start a job: %v", err)
+		}
+		ctx, stop := context.WithCancelCause(context.Background())
+		r.mu.Lock()
+		r.running[j.ID] = stop
+		r.mu.Unlock()
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.run(runCtx, ctx, j)
+			r.mu.Lock()
+			delete(r.running, j.ID)
+			r.mu.Unlock()
+			stop(nil)
+		}()
+	}
+	return nil
+}
+
+// report is what a job said last
+type report struct {
+	mu       sync.Mutex
+	progress float64
+	message  string // of its progress
+	output   string // its last line on stdout
+	failure  string // of the last error it logged
+	saved    time.Time
+}
+
+// run runs j until it ends or ctx is cancelled, keeping its progress. A
+// job failing once runCtx is done was likely interrupted with the server,
+// as a terminal interrupts the whole process group, and is queued again.
+func (r *Runner) run(runCtx, ctx context.Context, j Job) {
+	slog.Info("starting job", "id", j.ID, "command", j.Line())
+	cmd := r.Command(ctx, j)
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = r.Grace
+	if cmd.WaitDelay <= 0 {
+		cmd.WaitDelay = 30 * time.Second
+	}
+	// Pipes of our own, rather than cmd's, so that WaitDelay also bounds
+	// reading what processes the job left behind still write
+	stdout, outW := io.Pipe()
+	stderr, errW := io.Pipe()
+	cmd.Stdout, cmd.Stderr = outW, errW
+	if err := cmd.Start(); err != nil {
+		r.finish(j, Failed, fmt.Sprintf("failed to start: %v", err), 0)
+		return
+	}
+
+	rep := &report{}
+	var read sync.WaitGroup
+	read.Add(2)
+	go func() {
+		defer read.Done()
+		r.readLog(j.ID, stderr, rep)
+	}()
+	go func() {
+		defer read.Done()
+		r.readOutput(j.ID, stdout, rep)
+	}()
+	err := cmd.Wait()
+	outW.Close()
+	errW.Close()
+	read.Wait()
+
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errCancelled):
+		r.finish(j, Cancelled, rep.message, rep.progress)
+	case err != nil && (errors.Is(cause, errStopped) || runCtx.Err() != nil):
+		if _, err := r.DB.Exec("UPDATE jobs SET status = 'queued', startedAt = NULL WHERE id = ?", j.ID); err != nil {
+			slog.Error("failed to queue stopped job again", "id", j.ID, "err", err)
+		}
+		slog.Info("stopped job, queued again", "id", j.ID)
+	case err != nil:
+		message := rep.failure
+		if message == "" {
+			message = err.Error()
+		}
+		r.finish(j, Failed, message, rep.progress)
+	default:
+		message := rep.output
+		if message == "" {
+			message = rep.message
+		}
+		r.finish(j, Done, message, 1)
+	}
+}
+
+// finish records how j ended
+func (r *Runner) finish(j Job, status Status, message string, progress float64) {
+	_, err := r.DB.Exec("UPDATE jobs SET status = ?, message = ?, progress = ?, finishedAt = ? WHERE id = ?",
+		string(status), nullIfEmpty(message), progress, now(), j.ID)
+	if err != nil {
+		slog.Error("failed to record job", "id", j.ID, "status", status, "err", err)
+		return
+	}
+	slog.Info("job ended", "id", j.ID, "status", status, "message", message)
+}
+
+// readLog reads the JSON log lines of job id: those of a meter.Meter tell
+// how far it got, and errors why it failed
+func (r *Runner) readLog(id int64, log io.Reader, rep *report) {
+	sc := bufio.NewScanner(log)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var line struct {
+			Level string   `json:"level"`
+			Msg   string   `json:"msg"`
+			Err   string   `json:"err"`
+			Done  *float64 `json:"done"`
+			Total *float64 `json:"total"`
+			Unit  string   `json:"unit"`
+		}
+		if json.Unmarshal(sc.Bytes(), &line) != nil {
+			// Not a log line: a panic, or a message of a library
+			if text := strings.TrimSpace(sc.Text()); text != "" {
+				r.note(id, rep, func() { rep.failure = text })
+			}
+			continue
+		}
+		switch {
+		case line.Done != nil && line.Total != nil && *line.Total > 0:
+			r.note(id, rep, func() {
+				rep.progress = min(*line.Done / *line.Total, 1)
+				rep.message = fmt.Sprintf("%s: %.0f of %.0f %s", line.Msg, *line.Done, *line.Total, line.Unit)
+			})
+		case line.Level == "ERROR":
+			text := line.Msg
+			if line.Err != "" {
+				text = line.Err
+			}
+			r.note(id, rep, func() { rep.failure = text })
+		}
+	}
+	drain(id, log, sc.Err())
+}
+
+// readOutput reads what job id prints, keeping its last line as its
+// message until it logs progress again, and once it is done
+func (r *Runner) readOutput(id int64, out io.Reader, rep *report) {
+	sc := bufio.NewScanner(out)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		// Meters redraw with carriage returns on a terminal
+		parts := strings.Split(sc.Text(), "\r")
+		if text := strings.TrimSpace(parts[len(parts)-1]); text != "" {
+			r.note(id, rep, func() { rep.output, rep.message = text, text })
+		}
+	}
+	drain(id, out, sc.Err())
+}
+
+// drain reads the rest of what a job writes once a line too long stopped
+// reading it, so that the job is not left blocked writing to the pipe
+func drain(id int64, r io.Reader, err error) {
+	if err == nil {
+		return
+	}
+	slog.Warn("failed to read job output, skipping the rest", "id", id, "err", err)
+	io.Copy(io.Discard, r)
+}
+
+// note changes rep and saves it, at most every second so a chatty command
+// does not write on every line
+func (r *Runner) note(id int64, rep *report, change func()) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	change()
+	if time.Since(rep.saved) < time.Second {
+		return
+	}
+	rep.saved = time.Now()
+	if _, err := r.DB.Exec("UPDATE jobs SET progress = ?, message = ? WHERE id = ?", rep.progress, nullIfEmpty(rep.message), id); err != nil {
+		slog.Error("failed to save job progress", "id", id, "err", err)
+	}
+}
+
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
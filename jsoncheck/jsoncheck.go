@@ -0,0 +1,421 @@
+// Package jsoncheck reads JSON inputs against the Go types they fill, so a
+// misspelled field or a number where text belongs is an error naming the
+// file, line, path and expected type rather than a quote silently left
+// blank. Fields tagged `jsoncheck:"required"` must be present, e.g.
+//
+//	Text string `json:"text" jsoncheck:"required"`
+//
+// Unknown fields are errors in strict mode and warnings otherwise.
+package jsoncheck
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Error is one problem of a JSON input
+type Error struct {
+	File      string
+	Line, Col int    // 0 when unknown
+	Path      string // e.g. $[2].author
+	Msg       string
+}
+
+func (e *Error) Error() string {
+	at := e.File
+	switch {
+	case e.Col > 0:
+		at = fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Col)
+	case e.Line > 0:
+		at = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s", at, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s: %s", at, e.Path, e.Msg)
+}
+
+// Errors are the problems of an input, one per line
+type Errors []*Error
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maxErrors is how many problems an input lists before giving up
+const maxErrors = 20
+
+// ReadFile reads the JSON file at path into v
+func ReadFile(path string, v interface{}, strict bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(path, data, v, strict)
+}
+
+// Unmarshal checks data, named file in errors, against the type of v, a
+// pointer, and reads it into v when it fits. It returns Errors or an
+// *Error for what does not fit.
+func Unmarshal(file string, data []byte, v interface{}, strict bool) error {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return fmt.Errorf("jsoncheck: Unmarshal needs a pointer, not %T", v)
+	}
+	c := &checker{file: file, data: data, strict: strict, dec: json.NewDecoder(bytes.NewReader(data))}
+	c.dec.UseNumber()
+	if err := c.value("$", t.Elem()); err != nil {
+		return c.fatal(err)
+	}
+	if off := c.start(); c.dec.More() {
+		return c.at(off, "", "unexpected data after the JSON value")
+	}
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return &Error{File: file, Line: 1, Col: 1, Msg: err.Error()}
+	}
+	return nil
+}
+
+// checker walks the tokens of an input along the type they fill
+type checker struct {
+	file   string
+	data   []byte
+	strict bool
+	dec    *json.Decoder
+	errs   Errors
+}
+
+// errFull stops a walk that found enough problems
+var errFull = errors.New("too many errors")
+
+// fatal turns an error that stopped the walk into the one to return
+func (c *checker) fatal(err error) error {
+	var syntax *json.SyntaxError
+	switch {
+	case errors.Is(err, errFull):
+		return c.errs
+	case errors.As(err, &syntax):
+		return c.at(syntax.Offset, "", "invalid JSON: "+syntax.Error())
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return c.at(int64(len(c.data)), "", "unexpected end of JSON")
+	}
+	return c.at(c.dec.InputOffset(), "", err.Error())
+}
+
+// at is the *Error of the byte at offset
+func (c *checker) at(offset int64, path, msg string) *Error {
+	if offset > int64(len(c.data)) {
+		offset = int64(len(c.data))
+	}
+	before := c.data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len([]rune(string(before[bytes.LastIndexByte(before, '\n')+1:]))) + 1
+	return &Error{File: c.file, Line: line, Col: col, Path: path, Msg: msg}
+}
+
+// add records a problem, stopping the walk once there are enough
+func (c *checker) add(offset int64, path, format string, args ...interface{}) error {
+	c.errs = append(c.errs, c.at(offset, path, fmt.Sprintf(format, args...)))
+	if len(c.errs) >= maxErrors {
+		return errFull
+	}
+	return nil
+}
+
+// start is the offset of the next token, past spaces and separators
+func (c *checker) start() int64 {
+	off := c.dec.InputOffset()
+	for off < int64(len(c.data)) && strings.IndexByte(" \t\r\n,:", c.data[off]) >= 0 {
+		off++
+	}
+	return off
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// value reads the next value, which fills a t at path; a nil t takes any
+func (c *checker) value(path string, t reflect.Type) error {
+	off := c.start()
+	tok, err := c.dec.Token()
+	if err != nil {
+		return err
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// null leaves any value as it is; types reading themselves are theirs
+	// to check
+	if t == nil || tok == nil || t.Kind() == reflect.Interface ||
+		reflect.PointerTo(t).Implements(jsonUnmarshaler) || reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return c.skip(tok)
+	}
+
+	want, got := expected(t), kind(tok)
+	if got == "number" && want == "integer" && !strings.ContainsAny(string(tok.(json.Number)), ".eE") {
+		got = "integer"
+	}
+	if got != want {
+		shown := got
+		if got != "object" && got != "array" {
+			shown = fmt.Sprintf("%s %v", got, tok)
+			if s, ok := tok.(string); ok {
+				shown = fmt.Sprintf("string %q", s)
+			}
+		}
+		if err := c.add(off, path, "expected %s, got %s", want, shown); err != nil {
+			return err
+		}
+		return c.skip(tok)
+	}
+
+	if got != "object" && got != "array" {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return c.object(path, off, t)
+	case reflect.Map:
+		for c.dec.More() {
+			key, err := c.dec.Token()
+			if err != nil {
+				return err
+			}
+			if err := c.value(fmt.Sprintf("%s.%v", path, key), t.Elem()); err != nil {
+				return err
+			}
+		}
+		_, err := c.dec.Token()
+		return err
+	case reflect.Slice, reflect.Array:
+		for i := 0; c.dec.More(); i++ {
+			if err := c.value(fmt.Sprintf("%s[%d]", path, i), t.Elem()); err != nil {
+				return err
+			}
+		}
+		_, err := c.dec.Token()
+		return err
+	}
+	return nil
+}
+
+// object reads the fields of a struct t whose { was at off
+func (c *checker) object(path string, off int64, t reflect.Type) error {
+	fields := fieldsOf(t)
+	seen := make(map[string]bool)
+	for c.dec.More() {
+		keyOff := c.start()
+		tok, err := c.dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		f, ok := lookup(fields, key)
+		if !ok {
+			if err := c.unknown(keyOff, path, key, fields); err != nil {
+				return err
+			}
+			if err := c.value(path+"."+key, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		seen[f.name] = true
+		if err := c.value(path+"."+f.name, f.typ); err != nil {
+			return err
+		}
+	}
+	if _, err := c.dec.Token(); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.required && !seen[f.name] {
+			if err := c.add(off, path, "missing required field %q", f.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unknown reports a field t does not have: an error in strict mode, else a
+// warning
+func (c *checker) unknown(off int64, path, key string, fields []field) error {
+	msg := fmt.Sprintf("unknown field %q", key)
+	if near := nearest(key, fields); near != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", near)
+	}
+	if c.strict {
+		return c.add(off, path, "%s", msg)
+	}
+	slog.Warn("ignored a JSON field", "at", c.at(off, path, msg).Error())
+	return nil
+}
+
+// skip reads past the rest of the value tok starts
+func (c *checker) skip(tok json.Token) error {
+	if d, ok := tok.(json.Delim); !ok || (d != '{' && d != '[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := c.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// kind names the JSON type of tok
+func kind(tok json.Token) string {
+	switch tok.(type) {
+	case json.Delim:
+		if tok == json.Delim('{') {
+			return "object"
+		}
+		return "array"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	}
+	return "null"
+}
+
+// expected names the JSON type a Go type reads
+func expected(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string" // base64
+		}
+	}
+	return "array"
+}
+
+// field is a JSON field of a struct
+type field struct {
+	name     string
+	typ      reflect.Type
+	required bool
+}
+
+// fieldsOf lists the JSON fields of struct t as encoding/json reads them,
+// with those of embedded structs
+func fieldsOf(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, fieldsOf(ft)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if strings.Contains(","+opts+",", ",string,") {
+			ft = nil // a number or boolean written as a string
+		}
+		fields = append(fields, field{name: name, typ: ft, required: sf.Tag.Get("jsoncheck") == "required"})
+	}
+	return fields
+}
+
+// lookup finds the field of key, preferring an exact match to one in
+// another case as encoding/json does
+func lookup(fields []field, key string) (field, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// nearest is the field key likely misspells, if any
+func nearest(key string, fields []field) string {
+	best, bestDist := "", 3
+	for _, f := range fields {
+		if d := distance(strings.ToLower(key), strings.ToLower(f.name)); d < bestDist {
+			best, bestDist = f.name, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
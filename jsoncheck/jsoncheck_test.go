@@ -0,0 +1,67 @@
+package jsoncheck
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type meta struct {
+	Source string `json:"source"`
+}
+
+type quote struct {
+	Text   string   `json:"text" jsoncheck:"required"`
+	Author string   `json:"author"`
+	Likes  int      `json:"likes"`
+	Tags   []string `json:"tags"`
+	Score  float64  `json:"score"`
+	meta
+}
+
+func TestUnmarshal(t *testing.T) {
+	var quotes []quote
+	data := `[
+  {"text": "Az olsun, öz olsun.", "Author": "Atasözü", "likes": 3, "score": 1, "source": "x"},
+  {"text": "Two", "tags": ["a", "b"], "score": 0.5, "extra": {"a": [1, 2]}}
+]`
+	if err := Unmarshal("q.json", []byte(data), &quotes, false); err != nil {
+		t.Fatal(err)
+	}
+	want := []quote{{Text: "Az olsun, öz olsun.", Author: "Atasözü", Likes: 3, Score: 1, meta: meta{Source: "x"}}, {Text: "Two", Tags: []string{"a", "b"}, Score: 0.5}}
+	if !reflect.DeepEqual(quotes, want) {
+		t.Errorf("Unmarshal = %+v, want %+v", quotes, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, tc := range []struct {
+		data   string
+		strict bool
+		want   []string
+	}{
+		{`[{"text": "a", "auther": "b"}]`, false, nil},
+		{`[{"text": "a", "auther": "b"}]`, true, []string{`q.json:1:16: $[0]: unknown field "auther" (did you mean "author"?)`}},
+		{"[\n  {\"text\": 1, \"likes\": 1.5},\n  {\"quote\": \"a\", \"tags\": \"x\"}\n]", false, []string{
+			`q.json:2:12: $[0].text: expected string, got number 1`,
+			`q.json:2:24: $[0].likes: expected integer, got number 1.5`,
+			`q.json:3:26: $[1].tags: expected array, got string "x"`,
+			`q.json:3:3: $[1]: missing required field "text"`,
+		}},
+		{`{"text": "a"}`, false, []string{`q.json:1:1: $: expected array, got object`}},
+		{`[{"text": "a",}]`, false, []string{`q.json:1:15: invalid JSON: invalid character ',' looking for beginning of value`}},
+		{`[{"text": "a"}`, false, []string{`q.json:1:15: invalid JSON: unexpected end of JSON input`}},
+		{``, false, []string{`q.json:1:1: unexpected end of JSON`}},
+		{`[] []`, false, []string{`q.json:1:4: unexpected data after the JSON value`}},
+	} {
+		var quotes []quote
+		err := Unmarshal("q.json", []byte(tc.data), &quotes, tc.strict)
+		var got []string
+		if err != nil {
+			got = strings.Split(err.Error(), "\n")
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Unmarshal(%s, strict %v):\ngot  %q\nwant %q", tc.data, tc.strict, got, tc.want)
+		}
+	}
+}
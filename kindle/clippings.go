@@ -0,0 +1,199 @@
+// Package kindle reads the highlights a Kindle keeps in "My Clippings.txt".
+package kindle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clipping is a highlight: the book it is from, where in it and when it
+// was made, and its text. Start and End are Kindle locations, End equal to
+// Start for one-location highlights; both are 0 for books that only number
+// pages.
+type Clipping struct {
+	Title  string
+	Author string
+	Start  int
+	End    int
+	Added  time.Time // zero when the device wrote it in a format not known here
+	Text   string
+}
+
+// separator ends every clipping
+const separator = "=========="
+
+var (
+	// metaRe reads the line under the title, e.g. "- Your Highlight on page
+	// 12 | Location 170-172 | Added on Sunday, March 3, 2019 10:12:45 PM"
+	metaRe     = regexp.MustCompile(`^-\s*Your (\w+)`)
+	locationRe = regexp.MustCompile(`(?i)location (\d+)(?:-(\d+))?`)
+	addedRe    = regexp.MustCompile(`(?i)added on (.+)$`)
+	// authorRe matches the author in parentheses closing the title line
+	authorRe = regexp.MustCompile(`^(.*)\(([^()]*)\)\s*$`)
+)
+
+// addedLayouts are the date formats of English Kindles, older ones
+// without the seconds
+var addedLayouts = []string{
+	"Monday, January 2, 2006 3:04:05 PM",
+	"Monday, 2 January 2006 15:04:05",
+	"Monday, January 2, 2006, 03:04 PM",
+}
+
+// Parse reads a My Clippings.txt file, returning its highlights in file
+// order. Notes and bookmarks are skipped: they hold no text of the book.
+func Parse(r io.Reader) ([]Clipping, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var clips []Clipping
+	var entry []string
+	flush := func() {
+		if c, ok := parseEntry(entry); ok {
+			clips = append(clips, c)
+		}
+		entry = entry[:0]
+	}
+	for scanner.Scan() {
+		line := strings.TrimRight(strings.TrimPrefix(scanner.Text(), "\ufeff"), "\r")
+		if strings.TrimSpace(line) == separator {
+			flush()
+			continue
+		}
+		entry = append(entry, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read clippings: %v", err)
+	}
+	flush()
+	return clips, nil
+}
+
+// parseEntry reads the lines between two separators: title, metadata, a
+// blank line and the text
+func parseEntry(lines []string) (Clipping, bool) {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) < 2 {
+		return Clipping{}, false
+	}
+	meta := strings.TrimSpace(lines[1])
+	m := metaRe.FindStringSubmatch(meta)
+	if m == nil || !strings.EqualFold(m[1], "highlight") {
+		return Clipping{}, false
+	}
+	text := strings.Join(strings.Fields(strings.Join(lines[2:], " ")), " ")
+	if text == "" {
+		return Clipping{}, false
+	}
+
+	c := Clipping{Text: text}
+	c.Title, c.Author = splitTitle(strings.TrimSpace(lines[0]))
+	if loc := locationRe.FindStringSubmatch(meta); loc != nil {
+		c.Start, _ = strconv.Atoi(loc[1])
+		c.End = c.Start
+		if loc[2] != "" {
+			c.End = endLocation(loc[1], loc[2])
+		}
+	}
+	if added := addedRe.FindStringSubmatch(meta); added != nil {
+		for _, layout := range addedLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(added[1])); err == nil {
+				c.Added = t
+				break
+			}
+		}
+	}
+	return c, true
+}
+
+// endLocation reads the end of a range such as 1170-72, which Kindles
+// sometimes shorten to the digits that change
+func endLocation(start, end string) int {
+	if len(end) < len(start) {
+		end = start[:len(start)-len(end)] + end
+	}
+	n, _ := strconv.Atoi(end)
+	return n
+}
+
+// splitTitle reads "Title (Author)" lines. Authors written "Last, First" are
+// turned around, and several authors are joined with " & ".
+func splitTitle(line string) (title, author string) {
+	m := authorRe.FindStringSubmatch(line)
+	if m == nil {
+		return line, ""
+	}
+	var names []string
+	for _, name := range strings.Split(m[2], ";") {
+		name = strings.TrimSpace(name)
+		if last, first, ok := strings.Cut(name, ","); ok && !strings.Contains(first, ",") {
+			name = strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.TrimSpace(m[1]), strings.Join(names, " & ")
+}
+
+// Dedup drops highlights another one of the same book overlaps, as Kindle
+// adds a clipping each time a highlight is extended or moved rather than
+// replacing it. Of overlapping highlights the latest is kept, or the longest
+// when they were added at the same time; highlights without locations are
+// only merged when their text is the same. The order of the file is kept.
+func Dedup(clips []Clipping) []Clipping {
+	order := make([]int, len(clips))
+	for i := range order {
+		order[i] = i
+	}
+	// Latest first, so each highlight is kept unless a later one overlaps it
+	sort.SliceStable(order, func(a, b int) bool {
+		ca, cb := clips[order[a]], clips[order[b]]
+		if !ca.Added.Equal(cb.Added) {
+			return ca.Added.After(cb.Added)
+		}
+		return len(ca.Text) > len(cb.Text)
+	})
+
+	keep := make([]bool, len(clips))
+	var kept []int
+	for _, i := range order {
+		dup := false
+		for _, j := range kept {
+			if overlaps(clips[i], clips[j]) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			keep[i] = true
+			kept = append(kept, i)
+		}
+	}
+
+	var out []Clipping
+	for i, c := range clips {
+		if keep[i] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// overlaps reports whether a and b highlight the same passage
+func overlaps(a, b Clipping) bool {
+	if a.Title != b.Title || a.Author != b.Author {
+		return false
+	}
+	if a.Start == 0 || b.Start == 0 {
+		return a.Text == b.Text
+	}
+	return a.Start <= b.End && b.Start <= a.End
+}
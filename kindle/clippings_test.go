@@ -0,0 +1,95 @@
+package kindle
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const clippings = "\ufeffThe Great Gatsby (Fitzgerald, F. Scott)\r\n" +
+	`- Your Highlight on page 180 | Location 2744-2745 | Added on Sunday, March 3, 2019 10:12:45 PM
+
+So we beat on, boats against the current,
+==========
+The Great Gatsby (Fitzgerald, F. Scott)
+- Your Highlight on page 180 | Location 2744-46 | Added on Sunday, March 3, 2019 10:13:02 PM
+
+So we beat on, boats against the current, borne back ceaselessly into the past.
+==========
+The Great Gatsby (Fitzgerald, F. Scott)
+- Your Note on page 180 | Location 2746 | Added on Sunday, March 3, 2019 10:14:00 PM
+
+ending!
+==========
+The Great Gatsby (Fitzgerald, F. Scott)
+- Your Bookmark on page 12 | Location 170 | Added on Sunday, March 3, 2019 10:15:00 PM
+
+
+==========
+Meditations (Penguin Classics) (Marcus Aurelius)
+- Your Highlight at location 1020-1022 | Added on Monday, April 1, 2019 8:00:00 AM
+
+The happiness of your life depends upon the quality of your thoughts.
+==========
+The Great Gatsby (Fitzgerald, F. Scott)
+- Your Highlight on page 1 | Location 20-21 | Added on Tuesday, April 2, 2019 9:30:00 AM
+
+Whenever you feel like criticizing anyone, just remember that all the people in this world haven't had the advantages that you've had.
+==========
+`
+
+func TestParse(t *testing.T) {
+	clips, err := Parse(strings.NewReader(clippings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clips) != 4 {
+		t.Fatalf("got %d highlights, want 4 (notes and bookmarks skipped): %+v", len(clips), clips)
+	}
+
+	first := clips[0]
+	if first.Title != "The Great Gatsby" || first.Author != "F. Scott Fitzgerald" {
+		t.Errorf("book = %q by %q", first.Title, first.Author)
+	}
+	if first.Start != 2744 || first.End != 2745 {
+		t.Errorf("location = %d-%d", first.Start, first.End)
+	}
+	if want := time.Date(2019, time.March, 3, 22, 12, 45, 0, time.UTC); !first.Added.Equal(want) {
+		t.Errorf("added = %v, want %v", first.Added, want)
+	}
+	if first.Text != "So we beat on, boats against the current," {
+		t.Errorf("text = %q", first.Text)
+	}
+	if clips[1].End != 2746 {
+		t.Errorf("shortened range end = %d, want 2746", clips[1].End)
+	}
+	if c := clips[2]; c.Title != "Meditations (Penguin Classics)" || c.Author != "Marcus Aurelius" || c.Start != 1020 {
+		t.Errorf("got %+v", c)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	clips, err := Parse(strings.NewReader(clippings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Dedup(clips)
+	var texts []string
+	for _, c := range got {
+		texts = append(texts, c.Text)
+	}
+	want := []string{
+		"So we beat on, boats against the current, borne back ceaselessly into the past.",
+		"The happiness of your life depends upon the quality of your thoughts.",
+		"Whenever you feel like criticizing anyone, just remember that all the people in this world haven't had the advantages that you've had.",
+	}
+	if strings.Join(texts, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Dedup kept:\n%s\nwant:\n%s", strings.Join(texts, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Without locations only the same text is a duplicate
+	paged := []Clipping{{Title: "A", Text: "one"}, {Title: "A", Text: "one"}, {Title: "A", Text: "two"}}
+	if got := Dedup(paged); len(got) != 2 {
+		t.Errorf("Dedup of page-only highlights kept %d, want 2", len(got))
+	}
+}
@@ -0,0 +1,55 @@
+package kitap
+
+import (
+	"context"
+	"strings"
+
+	"quotesparser/fetch"
+	"quotesparser/source"
+)
+
+func init() {
+	source.RegisterAdapter(Adapter{})
+}
+
+// Adapter is 1000kitap for quotes download: targets are books and authors,
+// whose quote pages are numbered
+type Adapter struct{}
+
+func (Adapter) Name() string { return "1000kitap" }
+
+// Discover visits the target's quote pages from 1 until one has no quotes
+func (Adapter) Discover(ctx context.Context, c *fetch.Client, target string, visit func(string) (int, error)) error {
+	t, err := ParseTarget(target)
+	if err != nil {
+		return err
+	}
+	for page := 1; ctx.Err() == nil; page++ {
+		n, err := visit(t.QuotesURL(page))
+		if err != nil || n == 0 {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (Adapter) Fetch(ctx context.Context, c *fetch.Client, url string) ([]byte, error) {
+	return source.FetchHTML(ctx, c, url)
+}
+
+// Parse reads a book page, or an author page when url is one
+func (Adapter) Parse(url string, page []byte) ([]source.Quote, error) {
+	parse := ParseQuotes
+	if strings.Contains(url, "/yazar/") {
+		parse = ParseAuthorQuotes
+	}
+	quotes, err := parse(string(page))
+	if err != nil {
+		return nil, err
+	}
+	found := make([]source.Quote, len(quotes))
+	for i, q := range quotes {
+		found[i] = source.Quote{Text: q.QuoteText, Author: q.Author, Book: q.BookName, Lang: "tr", Missing: q.Missing, Confidence: q.Confidence}
+	}
+	return found, nil
+}
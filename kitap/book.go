@@ -0,0 +1,117 @@
+// Package kitap downloads and parses quote pages from 1000kitap.com.
+package kitap
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// BaseURL is the root of the 1000kitap site
+const BaseURL = "https://1000kitap.com"
+
+// Target is a page of quotes that can be downloaded page by page
+type Target interface {
+	String() string
+	QuotesURL(page int) string
+}
+
+// Book identifies a book page on 1000kitap, e.g. normal-insanlar--182700
+type Book struct {
+	Slug string
+	ID   string
+}
+
+var bookRe = regexp.MustCompile(`^([^/]+)--(\d+)$`)
+
+// ParseBook accepts either a book slug ("normal-insanlar--182700") or any
+// 1000kitap URL pointing at the book or its quote pages
+func ParseBook(s string) (Book, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "://") || strings.HasPrefix(s, "1000kitap.com") {
+		if !strings.Contains(s, "://") {
+			s = "https://" + s
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return Book{}, fmt.Errorf("invalid book URL %q: %v", s, err)
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 2 || parts[0] != "kitap" {
+			return Book{}, fmt.Errorf("not a 1000kitap book URL: %s", s)
+		}
+		s = parts[1]
+	}
+
+	matches := bookRe.FindStringSubmatch(s)
+	if matches == nil {
+		return Book{}, fmt.Errorf("invalid book slug %q (expected name--id)", s)
+	}
+	return Book{Slug: matches[1], ID: matches[2]}, nil
+}
+
+// String returns the slug form used in 1000kitap URLs
+func (b Book) String() string {
+	return b.Slug + "--" + b.ID
+}
+
+// QuotesURL returns the URL of the given page of the book's quotes
+func (b Book) QuotesURL(page int) string {
+	return fmt.Sprintf("%s/kitap/%s/alintilar?sayfa=%d", BaseURL, b, page)
+}
+
+// Author identifies an author page on 1000kitap, e.g. sally-rooney
+type Author struct {
+	Slug string
+}
+
+var authorSlugRe = regexp.MustCompile(`^[^/?#\s]+$`)
+
+// ParseAuthor accepts either an author slug ("sally-rooney") or any 1000kitap
+// URL pointing at the author or their quote pages
+func ParseAuthor(s string) (Author, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "://") || strings.HasPrefix(s, "1000kitap.com") {
+		if !strings.Contains(s, "://") {
+			s = "https://" + s
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return Author{}, fmt.Errorf("invalid author URL %q: %v", s, err)
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 2 || parts[0] != "yazar" {
+			return Author{}, fmt.Errorf("not a 1000kitap author URL: %s", s)
+		}
+		s = parts[1]
+	}
+
+	if !authorSlugRe.MatchString(s) {
+		return Author{}, fmt.Errorf("invalid author slug %q", s)
+	}
+	return Author{Slug: s}, nil
+}
+
+// String returns the slug used in 1000kitap URLs
+func (a Author) String() string {
+	return a.Slug
+}
+
+// QuotesURL returns the URL of the given page of the author's quotes
+func (a Author) QuotesURL(page int) string {
+	return fmt.Sprintf("%s/yazar/%s/alintilar?sayfa=%d", BaseURL, a.Slug, page)
+}
+
+// ParseTarget accepts a book or an author: a URL of either, a book slug
+// (name--id) or else an author slug
+func ParseTarget(s string) (Target, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/yazar/") {
+		return ParseAuthor(s)
+	}
+	if strings.Contains(s, "/kitap/") || bookRe.MatchString(s) {
+		return ParseBook(s)
+	}
+	return ParseAuthor(s)
+}
@@ -0,0 +1,430 @@
+package kitap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"quotesparser/confidence"
+	"quotesparser/dedup"
+	"quotesparser/origin"
+	"quotesparser/source"
+)
+
+// Quote represents a single quote with its metadata. Quotes the page shows
+// without their book are returned with Missing set; ApplyPolicy decides on
+// them.
+type Quote struct {
+	QuoteText string   `json:"quoteText" jsoncheck:"required"`
+	Author    string   `json:"author"` // empty for proverbs and other anonymous quotes
+	BookName  string   `json:"bookName"`
+	BookLink  string   `json:"bookLink"`
+	Missing   []string `json:"missing,omitempty"`
+	Enrich    bool     `json:"enrich,omitempty"`  // flagged for a later pass to fill in Missing
+	RawText   string   `json:"rawText,omitempty"` // the text as scraped, when cleaning changed it
+	// Confidence is how sure the parser is that it read the quote right,
+	// see confidence.Signals; 0 when unknown
+	Confidence float64 `json:"confidence,omitempty"`
+	// Extractor found the quote: dom for the page's markup, nextData for
+	// the data embedded in it. FilledBy names the fields another extractor
+	// filled in, e.g. {"bookName": "nextData"} or {"author": "heading"}.
+	// Where the two extractors disagree Conflict is set and Conflicts holds
+	// the value of the other one, for curation to choose from.
+	Extractor string            `json:"extractor,omitempty"`
+	FilledBy  map[string]string `json:"filledBy,omitempty"`
+	Conflict  bool              `json:"conflict,omitempty"`
+	Conflicts map[string]string `json:"conflicts,omitempty"`
+	// Where the quote was read from, stamped by quotes parse: the page's
+	// address and file, when the page was downloaded, and the parser's
+	// version
+	SourceURL     string `json:"sourceUrl,omitempty"`
+	SourceFile    string `json:"sourceFile,omitempty"`
+	FetchedAt     string `json:"fetchedAt,omitempty"`
+	ParserVersion string `json:"parserVersion,omitempty"`
+}
+
+// Extractors, as Quote.Extractor and Quote.FilledBy name them
+const (
+	FromDOM      = "dom"
+	FromNextData = "nextData"
+	FromHeading  = "heading"
+)
+
+// ParseQuotes extracts quotes from a book or listing page, where every quote
+// links to both its book and its author
+func ParseQuotes(htmlContent string) ([]Quote, error) {
+	return DefaultRules.ParseQuotes(htmlContent)
+}
+
+// ParseAuthorQuotes extracts quotes from an author page (/yazar/<slug>/alintilar).
+// Quotes there only link to their book, with the book link a few levels up from
+// the quote text, so the author is taken from the page heading instead.
+func ParseAuthorQuotes(htmlContent string) ([]Quote, error) {
+	return DefaultRules.ParseAuthorQuotes(htmlContent)
+}
+
+// ParseQuotesFromFile streams the quotes of a saved book page
+func ParseQuotesFromFile(filename string) ([]Quote, error) {
+	return DefaultRules.ParseQuotesFromFile(filename)
+}
+
+// ParseAuthorQuotesFromFile streams the quotes of a saved author page
+func ParseAuthorQuotesFromFile(filename string) ([]Quote, error) {
+	return DefaultRules.ParseAuthorQuotesFromFile(filename)
+}
+
+// ParseQuotes is ParseQuotes with r's selectors
+func (r Rules) ParseQuotes(htmlContent string) ([]Quote, error) {
+	return r.parse(htmlContent, false)
+}
+
+// ParseAuthorQuotes is ParseAuthorQuotes with r's selectors
+func (r Rules) ParseAuthorQuotes(htmlContent string) ([]Quote, error) {
+	return r.parse(htmlContent, true)
+}
+
+// ParseQuotesFromFile is ParseQuotesFromFile with r's selectors
+func (r Rules) ParseQuotesFromFile(filename string) ([]Quote, error) {
+	return streamFile(filename, r.StreamQuotes)
+}
+
+// ParseAuthorQuotesFromFile is ParseAuthorQuotesFromFile with r's selectors
+func (r Rules) ParseAuthorQuotesFromFile(filename string) ([]Quote, error) {
+	return streamFile(filename, r.StreamAuthorQuotes)
+}
+
+func (r Rules) parse(htmlContent string, authorPage bool) ([]Quote, error) {
+	c, err := r.compile(authorPage)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, err
+	}
+
+	var author string
+	if h := c.heading.First(doc); h != nil {
+		author = c.clean.Clean(textOfNode(h))
+	}
+	return c.combine(c.quoteSpans(doc), c.parseNextData(htmlContent), author), nil
+}
+
+// candidate is a quote as one extractor found it, before newQuote cleans it
+type candidate struct {
+	text, author, bookName, bookLink string
+	from                             string // the extractor
+	sig                              confidence.Signals
+}
+
+// combine makes the quotes of a page from what its markup and its
+// __NEXT_DATA__ found. The markup's quotes are kept unless none of them is
+// complete, when those of __NEXT_DATA__ are. The other extractor's quote of
+// the same text then fills in the fields the kept one lacks, and where both
+// have a field and disagree the kept value stays and the conflict is
+// recorded. pageAuthor fills in quotes still without an author.
+func (c compiledRules) combine(dom, next []candidate, pageAuthor string) []Quote {
+	kept, other := dom, next
+	if !c.anyComplete(dom) && c.anyText(next) {
+		kept, other = next, dom
+	}
+	byText := make(map[string]candidate)
+	for _, o := range other {
+		if key := c.textKey(o.text); byText[key].from == "" {
+			byText[key] = o
+		}
+	}
+
+	var quotes []Quote
+	for _, k := range kept {
+		filled := make(map[string]string)
+		conflicts := make(map[string]string)
+		if o, ok := byText[c.textKey(k.text)]; ok {
+			fields := []struct {
+				name        string
+				kept, other *string
+				same        func(a, b string) bool
+			}{
+				{"author", &k.author, &o.author, c.sameName},
+				{"bookName", &k.bookName, &o.bookName, c.sameName},
+				{"bookLink", &k.bookLink, &o.bookLink, func(a, b string) bool { return a == b }},
+			}
+			for _, f := range fields {
+				switch {
+				case c.blank(*f.other):
+				case c.blank(*f.kept):
+					*f.kept = *f.other
+					filled[f.name] = o.from
+					k.sig.Fallback = k.sig.Fallback || o.sig.Fallback
+				case !f.same(*f.kept, *f.other):
+					conflicts[f.name] = c.clean.Clean(*f.other)
+					k.sig.Conflict = true
+				}
+			}
+		}
+		if c.blank(k.author) && pageAuthor != "" {
+			k.author = pageAuthor
+			filled["author"] = FromHeading
+			k.sig.Distant = true
+		}
+
+		q, ok := c.newQuote(k.text, k.author, k.bookName, k.bookLink, k.sig)
+		if !ok {
+			continue
+		}
+		q.Extractor = k.from
+		if len(filled) > 0 {
+			q.FilledBy = filled
+		}
+		if len(conflicts) > 0 {
+			q.Conflict, q.Conflicts = true, conflicts
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes
+}
+
+// anyComplete reports whether newQuote would make a quote missing nothing
+// of any of found
+func (c compiledRules) anyComplete(found []candidate) bool {
+	for _, f := range found {
+		if !c.blank(f.text) && !c.blank(f.bookName) && f.bookLink != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// anyText reports whether newQuote would make a quote of any of found
+func (c compiledRules) anyText(found []candidate) bool {
+	for _, f := range found {
+		if !c.blank(f.text) {
+			return true
+		}
+	}
+	return false
+}
+
+// blank reports whether s is empty once cleaned
+func (c compiledRules) blank(s string) bool {
+	return strings.TrimSpace(c.clean.Clean(s)) == ""
+}
+
+// textKey is what two extractors' quotes of the same text share
+func (c compiledRules) textKey(text string) string {
+	return dedup.Normalize(c.clean.Clean(text))
+}
+
+// sameName reports whether two names or titles differ at most in case,
+// accents or punctuation
+func (c compiledRules) sameName(a, b string) bool {
+	return dedup.NameKey(c.clean.Clean(a)) == dedup.NameKey(c.clean.Clean(b))
+}
+
+// ApplyPolicy applies p to the quotes missing their book, counting the
+// outcomes in r
+func ApplyPolicy(quotes []Quote, p source.Policy, r *source.Report) []Quote {
+	kept := quotes[:0]
+	for _, q := range quotes {
+		keep, flag := r.Apply(p, q.Missing)
+		if keep {
+			q.Enrich = flag
+			kept = append(kept, q)
+		}
+	}
+	return kept
+}
+
+// quoteSpans finds the quote elements and the links around them. Links are
+// searched among the siblings of the quote's parent, then up to climb more
+// ancestors.
+func (c compiledRules) quoteSpans(doc *html.Node) []candidate {
+	var found []candidate
+	for _, n := range c.quote.SelectAll(doc) {
+		quoteText := textOfNode(n)
+		var author, bookName, bookLink string
+
+		var sig confidence.Signals
+		container := n.Parent
+		for level := 0; container != nil && level <= c.climb; level++ {
+			c.findLinks(container, level > 0, &author, &bookName, &bookLink)
+			if bookLink != "" {
+				sig.Distant = level > 0
+				break
+			}
+			container = container.Parent
+		}
+		found = append(found, candidate{quoteText, author, bookName, bookLink, FromDOM, sig})
+	}
+	return found
+}
+
+// findLinks fills the book and author found in the links directly under n,
+// or anywhere below it when deep is set
+func (c compiledRules) findLinks(n *html.Node, deep bool, author, bookName, bookLink *string) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		isBook, isAuthor := c.book.Match(child), c.author.Match(child)
+		if isBook || isAuthor {
+			title := c.clean.Clean(textOfNode(child))
+			switch {
+			case isBook && *bookLink == "":
+				*bookName = title
+				*bookLink = BaseURL + getAttr(child, "href")
+			case isAuthor && *author == "":
+				*author = title
+			}
+			continue
+		}
+		if deep {
+			c.findLinks(child, deep, author, bookName, bookLink)
+		}
+	}
+}
+
+// parseNextData parses the __NEXT_DATA__ <script> tag
+func (c compiledRules) parseNextData(htmlContent string) []candidate {
+	start := strings.Index(htmlContent, `id="__NEXT_DATA__"`)
+	if start <= 0 {
+		return nil
+	}
+	scriptTag := htmlContent[start:]
+	startJSON := strings.Index(scriptTag, ">") + 1
+	endJSON := strings.Index(scriptTag, "</script>")
+	if startJSON <= 0 || endJSON <= startJSON {
+		return nil
+	}
+	return c.nextDataQuotes(scriptTag[startJSON:endJSON])
+}
+
+// nextDataQuotes parses the JSON of a __NEXT_DATA__ <script> tag
+func (c compiledRules) nextDataQuotes(script string) []candidate {
+	var nextData map[string]interface{}
+	if err := json.Unmarshal([]byte(script), &nextData); err != nil {
+		return nil
+	}
+
+	var found []candidate
+	// Traverse into pageProps/response/_sonuc/gonderiler
+	props := getMap(nextData, "props")
+	pageProps := getMap(props, "pageProps")
+	resp := getMap(pageProps, "response")
+	sonuc := getMap(resp, "_sonuc")
+	gonderiler, ok := sonuc["gonderiler"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	for _, item := range gonderiler {
+		post, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if turu, _ := post["turu"].(string); turu != "sozler" {
+			continue
+		}
+		alt := getMap(post, "alt")
+		kitaplar := getMap(alt, "kitaplar")
+		yazarlar := getMap(alt, "yazarlar")
+		sozler := getMap(alt, "sozler")
+		sozParse := getMap(sozler, "sozParse")
+
+		var quoteText string
+		switch v := sozParse["parse"].(type) {
+		case []interface{}:
+			var b strings.Builder
+			for _, s := range v {
+				if sstr, ok := s.(string); ok {
+					b.WriteString(sstr)
+				}
+			}
+			quoteText = b.String()
+		case string:
+			quoteText = v
+		}
+
+		bookName, _ := kitaplar["adi"].(string)
+		bookID, _ := kitaplar["id"].(string)
+		bookSlug, _ := kitaplar["seo_adi"].(string)
+		authorName, _ := yazarlar["adi"].(string)
+		var bookLink string
+		if bookSlug != "" || bookID != "" {
+			bookLink = fmt.Sprintf("%s/kitap/%s--%s", BaseURL, bookSlug, bookID)
+		}
+		found = append(found, candidate{quoteText, authorName, bookName, bookLink, FromNextData, confidence.Signals{Fallback: true}})
+	}
+	return found
+}
+
+// newQuote cleans the fields and reports whether there is a quote at all,
+// keeping the text as scraped when cleaning changed it. A quote linking no
+// author, or one such as "Anonim", is anonymous: it is kept without an
+// author. One linking no book is kept missing it. Its confidence is scored
+// from sig and what the cleanup and the selectors made of it.
+func (c compiledRules) newQuote(rawText, author, bookName, bookLink string, sig confidence.Signals) (Quote, bool) {
+	quoteText, cleaned := c.clean.Trace(rawText)
+	author = c.clean.Clean(author)
+	bookName = c.clean.Clean(bookName)
+	if origin.IsAnonymousName(author) {
+		author = ""
+	}
+	if strings.TrimSpace(quoteText) == "" {
+		return Quote{}, false
+	}
+	q := Quote{
+		QuoteText: quoteText,
+		Author:    author,
+		BookName:  bookName,
+		BookLink:  bookLink,
+	}
+	if rawText != quoteText {
+		q.RawText = rawText
+	}
+	if bookName == "" || bookLink == "" {
+		q.BookName, q.BookLink = "", ""
+		q.Missing = []string{"book"}
+	}
+	sig.Missing, sig.Cleaned = q.Missing, cleaned
+	q.Confidence = sig.Score()
+	return q, true
+}
+
+// Helper function: get attribute value by name
+func getAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// Helper function: get text content of node, as on the page
+func textOfNode(n *html.Node) string {
+	var b strings.Builder
+	var f func(*html.Node)
+	f = func(nd *html.Node) {
+		if nd.Type == html.TextNode {
+			b.WriteString(nd.Data)
+		}
+		for c := nd.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(n)
+	return b.String()
+}
+
+// Helper function: get map[string]interface{} field
+func getMap(m map[string]interface{}, key string) map[string]interface{} {
+	if raw, ok := m[key]; ok {
+		if out, ok := raw.(map[string]interface{}); ok {
+			return out
+		}
+	}
+	return nil
+}
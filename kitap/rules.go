@@ -0,0 +1,80 @@
+package kitap
+
+import (
+	"fmt"
+
+	"quotesparser/cleanup"
+	"quotesparser/jsoncheck"
+	"quotesparser/source"
+)
+
+// Rules are the selectors that find the parts of each quote on a page, so a
+// redesign of 1000kitap is an edit of a rules file rather than of the parser.
+// See source.Compile for the selectors understood.
+type Rules struct {
+	Quote   string `json:"quote"`   // element holding each quote's text
+	Book    string `json:"book"`    // link to the quote's book; its href is the book link
+	Author  string `json:"author"`  // link to the quote's author
+	Heading string `json:"heading"` // author pages: element naming the author
+	Climb   int    `json:"climb"`   // author pages: ancestors above the quote's parent searched for links
+	Cleanup string `json:"cleanup"` // steps tidying the text, see cleanup.Parse
+}
+
+// DefaultRules match the pages as 1000kitap serves them
+var DefaultRules = Rules{
+	Quote:   "span.text.text-15",
+	Book:    `a[href^="/kitap/"][href*="--"]`,
+	Author:  `a[href^="/yazar/"]`,
+	Heading: "h1",
+	Climb:   3,
+	Cleanup: cleanup.Default.String(),
+}
+
+// LoadRules reads rules from a JSON file. Fields it leaves out keep their
+// DefaultRules values.
+func LoadRules(path string) (Rules, error) {
+	r := DefaultRules
+	if err := jsoncheck.ReadFile(path, &r, true); err != nil {
+		return r, fmt.Errorf("bad rules: %v", err)
+	}
+	if _, err := r.compile(true); err != nil {
+		return r, fmt.Errorf("bad rules in %s: %v", path, err)
+	}
+	return r, nil
+}
+
+// compiledRules are Rules ready for one kind of page
+type compiledRules struct {
+	quote, book, author, heading source.Selector
+	climb                        int
+	clean                        cleanup.Chain
+}
+
+// compile compiles the selectors an author page or a book page needs. Book
+// pages link every quote to its author, so they use no heading and no climb.
+func (r Rules) compile(authorPage bool) (compiledRules, error) {
+	var c compiledRules
+	if r.Quote == "" {
+		return c, fmt.Errorf("quote is required")
+	}
+	if r.Climb < 0 {
+		return c, fmt.Errorf("climb must not be negative")
+	}
+	var err error
+	if c.clean, err = cleanup.Parse(r.Cleanup); err != nil {
+		return c, err
+	}
+	heading := ""
+	if authorPage {
+		heading, c.climb = r.Heading, r.Climb
+	}
+	for _, s := range []struct {
+		sel *source.Selector
+		src string
+	}{{&c.quote, r.Quote}, {&c.book, r.Book}, {&c.author, r.Author}, {&c.heading, heading}} {
+		if *s.sel, err = source.Compile(s.src); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,260 @@
+package kitap
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"quotesparser/confidence"
+	"quotesparser/source"
+)
+
+// StreamQuotes parses quotes like ParseQuotes, reading the page token by
+// token instead of building its DOM, so memory stays flat however large the
+// page is. Markup the HTML parser would restructure, such as unclosed
+// paragraphs around blocks, may link quotes differently.
+func StreamQuotes(r io.Reader) ([]Quote, error) {
+	return DefaultRules.StreamQuotes(r)
+}
+
+// StreamAuthorQuotes parses an author page like ParseAuthorQuotes, token by
+// token as StreamQuotes does
+func StreamAuthorQuotes(r io.Reader) ([]Quote, error) {
+	return DefaultRules.StreamAuthorQuotes(r)
+}
+
+// StreamQuotes is StreamQuotes with r's selectors
+func (r Rules) StreamQuotes(rd io.Reader) ([]Quote, error) {
+	return r.stream(rd, false)
+}
+
+// StreamAuthorQuotes is StreamAuthorQuotes with r's selectors
+func (r Rules) StreamAuthorQuotes(rd io.Reader) ([]Quote, error) {
+	return r.stream(rd, true)
+}
+
+// link is an element the book or author rule matched
+type link struct {
+	title, href string
+}
+
+// openElement is what the streamer follows of an element whose end tag is
+// still to come
+type openElement struct {
+	source.OpenElement
+	text *strings.Builder // for quotes, links, the heading and __NEXT_DATA__
+	href string
+
+	quote, heading, nextData bool
+	isBook, isAuthor         bool
+
+	book, author         link // first found among its children
+	deepBook, deepAuthor link // first found anywhere below it
+	waiting              []waitingQuote
+}
+
+// waitingQuote is a quote looking for its links in an element climb levels
+// above its own
+type waitingQuote struct {
+	i     int // in streamer.quotes
+	level int
+}
+
+// streamer follows the elements open at a point of a page
+type streamer struct {
+	rules    compiledRules
+	stack    []*openElement
+	path     []source.Element // the stack as the selectors see it
+	quotes   []Quote          // unclean, in page order
+	distant  []bool           // by quote, whether its book link was found a level up
+	inQuote  bool
+	inLink   bool
+	heading  string
+	nextData string
+}
+
+func (r Rules) stream(rd io.Reader, authorPage bool) ([]Quote, error) {
+	rules, err := r.compile(authorPage)
+	if err != nil {
+		return nil, err
+	}
+	s := &streamer{rules: rules}
+	var headingSeen bool
+
+	z := html.NewTokenizer(rd)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				return nil, z.Err()
+			}
+			for len(s.stack) > 0 {
+				s.pop()
+			}
+			return s.finish(), nil
+
+		case html.TextToken:
+			text := string(z.Text())
+			for _, el := range s.stack {
+				if el.text != nil {
+					el.text.WriteString(text)
+				}
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if !source.Opens(tt, tok.Data) {
+				continue
+			}
+			if tok.Data == "a" && s.inLink {
+				// Links do not nest: the parser closes the open one
+				s.close("a")
+			}
+			el := &openElement{OpenElement: source.OpenElement{Tag: tok.Data}}
+			s.path = append(s.path, source.Element{Tag: tok.Data, Attr: tok.Attr})
+			if tok.Data == "a" {
+				s.inLink = true
+			}
+			switch {
+			case !s.inQuote && s.rules.quote.MatchPath(s.path):
+				el.text = &strings.Builder{}
+				el.quote = true
+				s.inQuote = true
+			case s.rules.book.MatchPath(s.path) || s.rules.author.MatchPath(s.path):
+				el.text = &strings.Builder{}
+				el.href = attr(tok, "href")
+				el.isBook = s.rules.book.MatchPath(s.path)
+				el.isAuthor = s.rules.author.MatchPath(s.path)
+			case !headingSeen && s.rules.heading.MatchPath(s.path):
+				el.text = &strings.Builder{}
+				el.heading = true
+				headingSeen = true
+			case tok.Data == "script" && attr(tok, "id") == "__NEXT_DATA__":
+				el.text = &strings.Builder{}
+				el.nextData = true
+			}
+			s.stack = append(s.stack, el)
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			s.close(string(name))
+		}
+	}
+}
+
+func attr(tok html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// close ends the innermost open element named tag and those inside it, or
+// nothing when none is open, as for a stray end tag
+func (s *streamer) close(tag string) {
+	if i := source.Innermost(s.stack, tag); i >= 0 {
+		for len(s.stack) > i {
+			s.pop()
+		}
+	}
+}
+
+// pop ends the innermost open element
+func (s *streamer) pop() {
+	el := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+	s.path = s.path[:len(s.path)-1]
+	var parent *openElement
+	if len(s.stack) > 0 {
+		parent = s.stack[len(s.stack)-1]
+	}
+
+	if el.Tag == "a" {
+		s.inLink = false
+	}
+	switch {
+	case el.quote:
+		s.inQuote = false
+		s.quotes = append(s.quotes, Quote{QuoteText: el.text.String()})
+		s.distant = append(s.distant, false)
+		if parent != nil {
+			parent.waiting = append(parent.waiting, waitingQuote{i: len(s.quotes) - 1})
+		}
+	case el.isBook || el.isAuthor:
+		s.addLink(el, parent)
+	case el.heading:
+		s.heading = el.text.String()
+	case el.nextData:
+		s.nextData = el.text.String()
+	}
+
+	for _, w := range el.waiting {
+		q := &s.quotes[w.i]
+		book, author := el.book, el.author
+		if w.level > 0 {
+			book, author = el.deepBook, el.deepAuthor
+		}
+		if q.BookLink == "" && book.href != "" {
+			q.BookName, q.BookLink = book.title, BaseURL+book.href
+			s.distant[w.i] = w.level > 0
+		}
+		if q.Author == "" {
+			q.Author = author.title
+		}
+		if q.BookLink == "" && w.level < s.rules.climb && parent != nil {
+			parent.waiting = append(parent.waiting, waitingQuote{i: w.i, level: w.level + 1})
+		}
+	}
+}
+
+// addLink records a closed link in its parent and every element around it
+func (s *streamer) addLink(a, parent *openElement) {
+	l := link{title: s.rules.clean.Clean(a.text.String()), href: a.href}
+	isBook, isAuthor := a.isBook, a.isAuthor
+	if parent != nil {
+		if isBook && parent.book.href == "" {
+			parent.book = l
+		}
+		if isAuthor && parent.author.title == "" {
+			parent.author = l
+		}
+	}
+	for _, el := range s.stack {
+		if isBook && el.deepBook.href == "" {
+			el.deepBook = l
+		}
+		if isAuthor && el.deepAuthor.title == "" {
+			el.deepAuthor = l
+		}
+	}
+}
+
+// finish combines the quotes found in the markup with those of
+// __NEXT_DATA__, as parse does
+func (s *streamer) finish() []Quote {
+	dom := make([]candidate, len(s.quotes))
+	for i, q := range s.quotes {
+		dom[i] = candidate{q.QuoteText, q.Author, q.BookName, q.BookLink, FromDOM, confidence.Signals{Distant: s.distant[i]}}
+	}
+	var next []candidate
+	if s.nextData != "" {
+		next = s.rules.nextDataQuotes(s.nextData)
+	}
+	return s.rules.combine(dom, next, s.rules.clean.Clean(s.heading))
+}
+
+// streamFile streams the quotes of a saved page
+func streamFile(filename string, stream func(io.Reader) ([]Quote, error)) ([]Quote, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return stream(bufio.NewReader(f))
+}
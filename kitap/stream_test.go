@@ -0,0 +1,235 @@
+package kitap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestStreamMatchesParse checks that streaming a page finds the quotes
+// parsing its DOM does
+func TestStreamMatchesParse(t *testing.T) {
+	pages := map[string]string{
+		"book": `<html><body>
+<div class="post">
+  <span class="text text text-15">Hayatında ilk kez <b>kendini</b> normal hissetti.</span>
+  <a href="/kitap/normal-insanlar--182700">Normal İnsanlar</a>
+  <a href="/yazar/sally-rooney">Sally Rooney</a>
+</div>
+<div class="post">
+  <a href="/yazar/sally-rooney"></a><a href="/yazar/sally-rooney">Sally Rooney</a>
+  <span class="text text text-15">&ldquo;Seni seviyorum,&rdquo; dedi.<br>Ve bu doğruydu.</span>
+  <a href="/kitap/normal-insanlar--182700">Normal İnsanlar</a><img src="x.png">
+</div>
+<div class="post"><span class="text text text-15">No links here.</span></div>
+</body></html>`,
+		"author": `<html><body><h1>Sally <i>Rooney</i></h1>
+<div class="post">
+  <a href="/kitap/arkadaslarla-sohbetler--412345">Arkadaşlarla Sohbetler</a>
+  <div class="body"><div><span class="text text text-15">Bir şeyi sevmek onu anlamaktan daha kolaydır.</span></div></div>
+</div>
+<div class="post"><div class="body"><div><div><div><span class="text text text-15">Too deep.</span></div></div></div></div>
+  <a href="/kitap/normal-insanlar--182700">Normal İnsanlar</a></div>
+<div class="post">
+  <div class="body"><div><span class="text text text-15">İnsanlar birbirini değiştirebilir.</span></div></div>
+  <a href="/kitap/normal-insanlar--182700">Normal İnsanlar</a>
+</div>
+</body></html>`,
+		"next data": `<html><body><div>No spans.</div>
+<script id="__NEXT_DATA__" type="application/json">{"props":{"pageProps":{"response":{"_sonuc":{"gonderiler":[
+{"turu":"sozler","alt":{"kitaplar":{"adi":"Normal İnsanlar","id":"182700","seo_adi":"normal-insanlar"},"yazarlar":{"adi":"Sally Rooney"},"sozler":{"sozParse":{"parse":["Bir ","söz."]}}}}
+]}}}}}</script></body></html>`,
+	}
+
+	for name, page := range pages {
+		for _, authorPage := range []bool{false, true} {
+			parse, stream := ParseQuotes, StreamQuotes
+			if authorPage {
+				parse, stream = ParseAuthorQuotes, StreamAuthorQuotes
+			}
+			want, err := parse(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := stream(strings.NewReader(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(want) == 0 && (authorPage || name != "author") {
+				t.Errorf("%s (author page %v): DOM parse found no quotes", name, authorPage)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s (author page %v): streamed\n%v\nwant\n%v", name, authorPage, got, want)
+			}
+		}
+	}
+}
+
+// TestConfidence checks the confidence of quotes found in different ways
+func TestConfidence(t *testing.T) {
+	page := `<html><body><h1>Sally Rooney</h1>
+<div class="post">
+  <span class="text text text-15">Linked &amp; close.</span>
+  <a href="/kitap/normal-insanlar--182700">Normal İnsanlar</a>
+</div>
+<div class="post">
+  <div class="body"><span class="text text text-15">Linked a level up.</span></div>
+  <a href="/kitap/normal-insanlar--182700">Normal İnsanlar</a>
+</div>
+<div class="post"><span class="text text text-15">“No links here.”</span></div>
+</body></html>`
+	// Each takes its author from the heading; the last climbs to the book of
+	// the first and is unwrapped
+	want := map[string]float64{"Linked & close.": 0.9, "Linked a level up.": 0.9, "No links here.": 0.88}
+	for _, quotes := range [][]Quote{must(ParseAuthorQuotes(page)), must(StreamAuthorQuotes(strings.NewReader(page)))} {
+		for _, q := range quotes {
+			if q.Confidence != want[q.QuoteText] {
+				t.Errorf("%q: confidence %v, want %v", q.QuoteText, q.Confidence, want[q.QuoteText])
+			}
+		}
+	}
+
+	next := `<html><body><script id="__NEXT_DATA__" type="application/json">{"props":{"pageProps":{"response":{"_sonuc":{"gonderiler":[
+{"turu":"sozler","alt":{"kitaplar":{"adi":"Normal İnsanlar","id":"182700","seo_adi":"normal-insanlar"},"yazarlar":{"adi":"Sally Rooney"},"sozler":{"sozParse":{"parse":"Bir söz."}}}}
+]}}}}}</script></body></html>`
+	if quotes := must(ParseQuotes(next)); len(quotes) != 1 || quotes[0].Confidence != 0.85 {
+		t.Errorf("__NEXT_DATA__ quotes = %+v, want one at 0.85", quotes)
+	}
+}
+
+// TestProvenance checks that the markup's quotes take what they lack from
+// __NEXT_DATA__ and record where it disagrees
+func TestProvenance(t *testing.T) {
+	page := `<html><body>
+<div class="post"><span class="text text text-15">Birinci söz.</span>
+<a href="/kitap/kurk-mantolu-madonna--1">Kürk Mantolu Madonna</a><a href="/yazar/sabahattin-ali">Sabahattin Ali</a></div>
+<div class="post"><span class="text text text-15">İkinci söz.</span></div>
+<script id="__NEXT_DATA__" type="application/json">{"props":{"pageProps":{"response":{"_sonuc":{"gonderiler":[
+{"turu":"sozler","alt":{"kitaplar":{"adi":"Kürk Mantolu Madonna","id":"1","seo_adi":"kurk-mantolu-madonna"},"yazarlar":{"adi":"SABAHATTİN ALİ"},"sozler":{"sozParse":{"parse":"Birinci  söz."}}}},
+{"turu":"sozler","alt":{"kitaplar":{"adi":"İçimizdeki Şeytan","id":"2","seo_adi":"icimizdeki-seytan"},"yazarlar":{"adi":"Oğuz Atay"},"sozler":{"sozParse":{"parse":"İkinci söz."}}}},
+{"turu":"sozler","alt":{"kitaplar":{"adi":"Tutunamayanlar","id":"3","seo_adi":"tutunamayanlar"},"yazarlar":{"adi":"Oğuz Atay"},"sozler":{"sozParse":{"parse":"Yalnız burada."}}}}
+]}}}}}</script></body></html>`
+
+	// The first quote's book link differs; the spelling of its author does not count
+	page = strings.Replace(page, `"id":"1","seo_adi":"kurk-mantolu-madonna"`, `"id":"9","seo_adi":"kurk-mantolu-madonna"`, 1)
+	want := []Quote{
+		{
+			QuoteText: "Birinci söz.", Author: "Sabahattin Ali", BookName: "Kürk Mantolu Madonna", BookLink: BaseURL + "/kitap/kurk-mantolu-madonna--1",
+			Confidence: 0.9, Extractor: FromDOM, Conflict: true, Conflicts: map[string]string{"bookLink": BaseURL + "/kitap/kurk-mantolu-madonna--9"},
+		},
+		{
+			QuoteText: "İkinci söz.", Author: "Oğuz Atay", BookName: "İçimizdeki Şeytan", BookLink: BaseURL + "/kitap/icimizdeki-seytan--2",
+			Confidence: 0.85, Extractor: FromDOM, FilledBy: map[string]string{"author": FromNextData, "bookName": FromNextData, "bookLink": FromNextData},
+		},
+	}
+	parsed := must(ParseQuotes(page))
+	streamed := must(StreamQuotes(strings.NewReader(page)))
+	if !reflect.DeepEqual(parsed, want) || !reflect.DeepEqual(streamed, want) {
+		t.Errorf("parsed\n%+v\nstreamed\n%+v\nwant\n%+v", parsed, streamed, want)
+	}
+}
+
+func must(quotes []Quote, err error) []Quote {
+	if err != nil {
+		panic(err)
+	}
+	return quotes
+}
+
+func TestStreamLargePage(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("<html><body>")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&b, `<div class="post"><span class="text text text-15">Quote number %d.</span>
+<a href="/kitap/book--%d">Book %d</a><a href="/yazar/author-%d">Author %d</a></div>`, i, i, i, i, i)
+	}
+	b.WriteString("</body></html>")
+
+	quotes, err := StreamQuotes(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 20000 {
+		t.Fatalf("streamed %d quotes, want 20000", len(quotes))
+	}
+	if want := (Quote{QuoteText: "Quote number 19999.", Author: "Author 19999", BookName: "Book 19999", BookLink: BaseURL + "/kitap/book--19999", Confidence: 1, Extractor: FromDOM}); !reflect.DeepEqual(quotes[19999], want) {
+		t.Errorf("last quote = %+v, want %+v", quotes[19999], want)
+	}
+}
+
+// TestRules checks that a rules file follows a redesigned page both when
+// parsing its DOM and when streaming it
+func TestRules(t *testing.T) {
+	page := `<html><body><header><h1>Site</h1></header>
+<article class="quote">
+  <p class="quote-body">Bir şeyi sevmek onu anlamaktan daha kolaydır.</p>
+  <a class="book" href="/eser/arkadaslarla-sohbetler">Arkadaşlarla Sohbetler</a>
+  <a class="writer" href="/yazar/sally-rooney">Sally Rooney</a>
+</article>
+</body></html>`
+
+	if quotes, err := ParseQuotes(page); err != nil || len(quotes) != 0 {
+		t.Fatalf("default rules found %v, %v in the redesigned page", quotes, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	os.WriteFile(path, []byte(`{"quote": "article.quote > p.quote-body", "book": "a.book", "author": "article a.writer"}`), 0644)
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Heading != DefaultRules.Heading || rules.Climb != DefaultRules.Climb {
+		t.Errorf("LoadRules did not keep the defaults of fields left out: %+v", rules)
+	}
+
+	want := []Quote{{
+		QuoteText:  "Bir şeyi sevmek onu anlamaktan daha kolaydır.",
+		Author:     "Sally Rooney",
+		BookName:   "Arkadaşlarla Sohbetler",
+		BookLink:   BaseURL + "/eser/arkadaslarla-sohbetler",
+		Confidence: 1,
+		Extractor:  FromDOM,
+	}}
+	parsed, err := rules.ParseQuotes(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed, err := rules.StreamQuotes(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, want) || !reflect.DeepEqual(streamed, want) {
+		t.Errorf("parsed %v, streamed %v, want %v", parsed, streamed, want)
+	}
+	if quotes, err := rules.ParseAuthorQuotes(page); err != nil || !reflect.DeepEqual(quotes, want) {
+		t.Errorf("author page: parsed %v, %v, want %v", quotes, err, want)
+	}
+
+	for _, bad := range []string{`{"quote": "span["}`, `{"quote": ""}`, `{"quotes": "span"}`, `{"climb": -1}`} {
+		os.WriteFile(path, []byte(bad), 0644)
+		if _, err := LoadRules(path); err == nil {
+			t.Errorf("LoadRules accepted %s", bad)
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for in, want := range map[string]string{
+		"normal-insanlar--182700": "https://1000kitap.com/kitap/normal-insanlar--182700/alintilar?sayfa=1",
+		"sally-rooney":            "https://1000kitap.com/yazar/sally-rooney/alintilar?sayfa=1",
+		"https://1000kitap.com/yazar/sally-rooney/alintilar":   "https://1000kitap.com/yazar/sally-rooney/alintilar?sayfa=1",
+		"https://1000kitap.com/kitap/normal-insanlar--182700/": "https://1000kitap.com/kitap/normal-insanlar--182700/alintilar?sayfa=1",
+	} {
+		target, err := ParseTarget(in)
+		if err != nil {
+			t.Errorf("ParseTarget(%s): %v", in, err)
+			continue
+		}
+		if got := target.QuotesURL(1); got != want {
+			t.Errorf("ParseTarget(%s) pages %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Package langdetect guesses the language of a quote. It knows the languages
+// the quote sources publish in and their close neighbours, and scores a text
+// by its common words and the letters only some of those languages use,
+// which is enough for sentences but not for a word or two.
+package langdetect
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Languages are the ISO 639-1 codes Detect can return
+var Languages = []string{"tr", "es", "en", "fr", "de", "pt", "it"}
+
+// profile is what gives a language away
+type profile struct {
+	words   map[string]bool // most frequent words
+	strong  string          // letters used by this language alone among Languages
+	weak    string          // letters it shares with one or two others
+	endings []string        // frequent word endings
+}
+
+var profiles = map[string]profile{
+	"tr": {
+		words: set(`bir ve bu da de ne için ile çok ben sen o biz siz onlar gibi daha ama kadar her şey değil
+			mi mı mu mü olan olarak diye hiç ki ya bana beni seni onu sonra nasıl neden niçin şimdi var yok
+			hep bile artık zaman ise en bazen hem çünkü eğer belki sadece böyle öyle şöyle insan hayat`),
+		strong:  "ığşİ",
+		weak:    "çöüâîû",
+		endings: []string{"lar", "ler", "mak", "mek", "yor", "dır", "dir", "dur", "dür", "sın", "sin", "ken", "mış", "miş", "ım", "im", "ın", "in"},
+	},
+	"es": {
+		words: set(`el la los las de del que y en un una es no por con para se lo le su sus al como más pero
+			yo tú mi me te nos muy sin sobre también todo todos cuando porque hay ser está son fue era
+			qué quién donde cada nunca siempre vida amor así ya ni`),
+		strong: "ñ¿¡",
+		weak:   "áíóú",
+	},
+	"en": {
+		words: set(`the and of to a in is it you that he was for on are with as I his they be at one have
+			this from or had by not but what all were we when your can there an which their if do will
+			my me so no she her him them who would been has more than our us life love never only
+			cannot itself how about just like know people time good first well don't can't it's i'm
+			you're because every much after before through without nothing something always often`),
+		endings: []string{"ing", "ness", "ful", "n't", "'s"},
+	},
+	"fr": {
+		words: set(`le la les de des du et un une est que qui dans pour pas ne au aux ce cette il elle ils
+			nous vous je tu on avec sur plus par se sont mais ou où son sa ses leur été être avoir fait
+			tout tous comme bien aussi très toujours jamais rien vie amour c'est n'est qu'il`),
+		strong: "èœù",
+		weak:   "éêàâîç",
+	},
+	"de": {
+		words: set(`der die das und ist in zu den von nicht mit sich des auf für ein eine einer dem ich du
+			er sie es wir ihr auch als an nach wie im aus bei nur noch wenn so aber oder man hat sind
+			war wird kann muss immer nie mehr leben liebe`),
+		strong: "ßä",
+		weak:   "öü",
+	},
+	"pt": {
+		words: set(`o a os as de do da dos das e em um uma é que não por com para se mais mas como no na
+			nos nas ao eu você ele ela nós eles meu minha seu sua quando também muito sem sobre ser foi
+			era está são tudo nunca sempre vida amor isso esse essa já`),
+		strong: "ãõ",
+		weak:   "çêôáí",
+	},
+	"it": {
+		words: set(`il lo la gli le di del della dei e è che non un una per con in al alla si come più ma
+			io tu lui lei noi voi loro mi ti ci sono era essere anche molto sempre mai tutto quando
+			perché questo questa quello vita amore se già ho hai ha`),
+		weak: "òìèà",
+	},
+}
+
+func set(words string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		m[w] = true
+	}
+	return m
+}
+
+// Detect returns the language text is most likely written in and how sure
+// it is, from 0 to 1. It returns "" when nothing in text points to a language
+// or two languages fit it equally well.
+func Detect(text string) (lang string, confidence float64) {
+	text = strings.ReplaceAll(compose(text), "’", "'")
+	scores := make(map[string]float64)
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	for _, w := range words {
+		// Turkish lowercases I to ı and İ to i
+		lower := strings.ToLower(w)
+		for code, p := range profiles {
+			if p.words[lower] || p.words[strings.Trim(lower, "'")] {
+				scores[code]++
+			}
+			for _, e := range p.endings {
+				if len([]rune(lower)) > len([]rune(e))+2 && strings.HasSuffix(lower, e) {
+					scores[code] += 0.5
+					break
+				}
+			}
+		}
+	}
+	// A letter counts at most twice, so ¿¿¿ says no more than ¿...?
+	seen := make(map[rune]int)
+	for _, r := range text {
+		if seen[r]++; seen[r] > 2 {
+			continue
+		}
+		for code, p := range profiles {
+			if strings.ContainsRune(p.strong, r) || strings.ContainsRune(p.strong, unicode.ToLower(r)) {
+				scores[code] += 2
+			} else if strings.ContainsRune(p.weak, unicode.ToLower(r)) {
+				scores[code] += 0.5
+			}
+		}
+	}
+
+	var best, second float64
+	for _, code := range Languages {
+		switch s := scores[code]; {
+		case s > best:
+			lang, best, second = code, s, best
+		case s > second:
+			second = s
+		}
+	}
+	if best < 1 || best == second {
+		return "", 0
+	}
+	return lang, (best - second) / best
+}
+
+// composed are the precomposed forms of the letters the profiles look for;
+// some pages spell ğ as g and a combining breve, which would otherwise split
+// the word and hide the letter
+var composed = map[[2]rune]rune{
+	{'g', '\u0306'}: 'ğ', {'G', '\u0306'}: 'Ğ', {'I', '\u0307'}: 'İ',
+	{'s', '\u0327'}: 'ş', {'S', '\u0327'}: 'Ş', {'c', '\u0327'}: 'ç', {'C', '\u0327'}: 'Ç',
+	{'o', '\u0308'}: 'ö', {'O', '\u0308'}: 'Ö', {'u', '\u0308'}: 'ü', {'U', '\u0308'}: 'Ü', {'a', '\u0308'}: 'ä',
+	{'n', '\u0303'}: 'ñ', {'a', '\u0303'}: 'ã', {'o', '\u0303'}: 'õ',
+	{'a', '\u0301'}: 'á', {'e', '\u0301'}: 'é', {'i', '\u0301'}: 'í', {'o', '\u0301'}: 'ó', {'u', '\u0301'}: 'ú',
+	{'a', '\u0300'}: 'à', {'e', '\u0300'}: 'è', {'u', '\u0300'}: 'ù',
+}
+
+// compose replaces a letter followed by a combining mark with the
+// precomposed letter and drops the marks it does not know
+func compose(text string) string {
+	if !strings.ContainsFunc(text, isMark) {
+		return text
+	}
+	var out []rune
+	for _, r := range text {
+		if !isMark(r) {
+			out = append(out, r)
+			continue
+		}
+		if n := len(out); n > 0 {
+			if c, ok := composed[[2]rune{out[n-1], r}]; ok {
+				out[n-1] = c
+			}
+		}
+	}
+	return string(out)
+}
+
+func isMark(r rune) bool { return unicode.Is(unicode.Mn, r) }
+
+// DetectOr returns the detected language of text, or fallback when Detect
+// cannot tell
+func DetectOr(text, fallback string) string {
+	if lang, _ := Detect(text); lang != "" {
+		return lang
+	}
+	return fallback
+}
@@ -0,0 +1,39 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Bu kapkaranlık dünyada üzerime düşen ışıksın sen.", "tr"},
+		{"Bazı yaralar vardır; zamanla değil, kabullenişle iyileşir.", "tr"},
+		{"Bir kadın kaç kez âşık olur?", "tr"},
+		// NFD: the ğ and ş are a letter and a combining mark
+		{"Dünya deg\u0306is\u0327iyor. Bunu suda, toprakta hissedebiliyorum.", "tr"},
+		{"A house divided against itself cannot stand.", "en"},
+		{"Tired minds don't plan well. Sleep first, plan later.", "en"},
+		{"El amor es una locura que ni el cura lo cura.", "es"},
+		{"¿Qué es la vida? Un frenesí.", "es"},
+		{"On ne voit bien qu'avec le cœur, l'essentiel est invisible pour les yeux.", "fr"},
+		{"Wer kämpft, kann verlieren. Wer nicht kämpft, hat schon verloren.", "de"},
+		{"O coração tem razões que a própria razão desconhece.", "pt"},
+		{"Nel mezzo del cammin di nostra vita mi ritrovai per una selva oscura.", "it"},
+	}
+	for _, tt := range tests {
+		if got, _ := Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectUnsure(t *testing.T) {
+	for _, text := range []string{"", "(｡•́︿•̀｡)", "12345", "Zeze"} {
+		if got, confidence := Detect(text); got != "" || confidence != 0 {
+			t.Errorf("Detect(%q) = %q, %v; want no guess", text, got, confidence)
+		}
+	}
+	if got := DetectOr("Zeze", "tr"); got != "tr" {
+		t.Errorf("DetectOr fell back to %q, want tr", got)
+	}
+}
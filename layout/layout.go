@@ -0,0 +1,143 @@
+// Package layout fits a quote and its attribution into a fixed-size box, such
+// as an 800x480 e-ink panel: it wraps the text with the metrics of the font
+// it will be drawn with and shrinks the font until every line fits.
+package layout
+
+import (
+	"quotesparser/textshape"
+)
+
+// Face measures text in a font at a size in pixels per em. render.Font is
+// one.
+type Face interface {
+	Measure(text string, size float64) float64
+	Metrics(size float64) (ascent, descent, lineHeight float64)
+}
+
+// Block is the text to lay out
+type Block struct {
+	Text        string
+	Attribution string // set under the text, right-aligned; may be empty
+	Lang        string // for line breaking and hyphenation
+}
+
+// Box is where the block goes, in pixels
+type Box struct {
+	Width, Height float64
+	Margin        float64 // on every side
+	MaxSize       float64 // the text size to use when the block fits
+	MinSize       float64 // the smallest to shrink to; MaxSize when 0
+}
+
+// AttributionScale is the size of the attribution relative to the text
+const AttributionScale = 2.0 / 3
+
+// Line is a laid-out line, its baseline at Y pixels from the top of the box
+type Line struct {
+	Text        string
+	X, Y        float64
+	Size        float64
+	Attribution bool
+}
+
+// Result is a laid-out block
+type Result struct {
+	Lines []Line
+	Size  float64 // the text size chosen
+	// Overflow is set when the block did not fit even at MinSize; Lines
+	// then stop at the last one inside the box
+	Overflow bool
+}
+
+// Fit lays the block out at the largest size from MinSize to MaxSize at which
+// it fits the box, wrapped to the box's width and centered in its height. A
+// word too wide for the box shrinks the text too.
+func Fit(face Face, b Block, box Box) Result {
+	minSize := box.MinSize
+	if minSize <= 0 || minSize > box.MaxSize {
+		minSize = box.MaxSize
+	}
+	fits := func(size float64) bool {
+		_, height, widest := set(face, b, box, size)
+		return height <= box.Height-2*box.Margin && widest <= box.Width-2*box.Margin
+	}
+
+	size := box.MaxSize
+	if !fits(size) {
+		// Fitting is all but monotonic in the size, so binary search it to
+		// a quarter pixel
+		lo, hi := minSize, box.MaxSize
+		for hi-lo > 0.25 {
+			if mid := (lo + hi) / 2; fits(mid) {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		size = lo
+	}
+
+	lines, height, _ := set(face, b, box, size)
+	room := box.Height - 2*box.Margin
+	top := box.Margin + max(0, (room-height)/2)
+	r := Result{Size: size}
+	for _, l := range lines {
+		l.Y += top
+		_, descent, _ := face.Metrics(l.Size)
+		if l.Y+descent > box.Height-box.Margin+0.5 {
+			r.Overflow = true
+			break
+		}
+		r.Lines = append(r.Lines, l)
+	}
+	return r
+}
+
+// set lays the block out at size from the top of the box, returning its
+// lines, how tall it is from the first ascent to the last descent and how
+// wide its widest line
+func set(face Face, b Block, box Box, size float64) (lines []Line, height, widest float64) {
+	width := box.Width - 2*box.Margin
+	wrap := func(text string, size float64) []string {
+		return textshape.Wrap(text, width, func(s string) float64 { return face.Measure(s, size) })
+	}
+
+	ascent, descent, lineHeight := face.Metrics(size)
+	y := ascent
+	for _, text := range wrap(textshape.Hyphenate(b.Text, b.Lang), size) {
+		lines = append(lines, Line{Text: text, X: box.Margin, Y: y, Size: size})
+		widest = max(widest, face.Measure(text, size))
+		y += lineHeight
+	}
+	bottom := y - lineHeight + descent
+
+	if b.Attribution != "" {
+		small := size * AttributionScale
+		a, d, h := face.Metrics(small)
+		y += h - a // half a blank line before it
+		for _, text := range wrap(b.Attribution, small) {
+			y += a
+			w := face.Measure(text, small)
+			lines = append(lines, Line{Text: text, X: box.Width - box.Margin - w, Y: y, Size: small, Attribution: true})
+			widest = max(widest, w)
+			bottom = y + d
+			y += h - a
+		}
+	}
+	return lines, bottom, widest
+}
+
+// Capacity estimates how many characters of running text fit the box at
+// size, for picking quotes short enough to show before laying any out
+func Capacity(face Face, box Box, size float64) int {
+	const sample = "the quick brown fox jumps over the lazy dog; "
+	perChar := face.Measure(sample, size) / float64(len(sample))
+	_, _, lineHeight := face.Metrics(size)
+	if perChar <= 0 || lineHeight <= 0 {
+		return 0
+	}
+	perLine := (box.Width - 2*box.Margin) / perChar
+	// Leave room for the attribution and for lines that wrap short
+	lines := (box.Height-2*box.Margin)/lineHeight - 1.5
+	return max(0, int(perLine*lines*0.85))
+}
@@ -0,0 +1,85 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// mono is a monospaced face: every character half an em wide
+type mono struct{}
+
+func (mono) Measure(text string, size float64) float64 {
+	return float64(utf8.RuneCountInString(text)) * size / 2
+}
+
+func (mono) Metrics(size float64) (ascent, descent, lineHeight float64) {
+	return 0.8 * size, 0.2 * size, 1.2 * size
+}
+
+var box = Box{Width: 200, Height: 100, Margin: 10, MaxSize: 20, MinSize: 8}
+
+func TestFitKeepsShortTextLarge(t *testing.T) {
+	r := Fit(mono{}, Block{Text: "Short.", Attribution: "— Me"}, box)
+	if r.Size != 20 || r.Overflow || len(r.Lines) != 2 {
+		t.Fatalf("Fit = %+v, want two lines at 20", r)
+	}
+	text, by := r.Lines[0], r.Lines[1]
+	if text.X != 10 || by.X+(mono{}).Measure(by.Text, by.Size) != 190 || !by.Attribution {
+		t.Errorf("lines = %+v, want the text at the margin and the attribution right-aligned", r.Lines)
+	}
+	// Centered: as much room above the first ascent as below the last descent
+	top := text.Y - 0.8*text.Size
+	bottom := by.Y + 0.2*by.Size
+	if d := (top - 10) - (90 - bottom); d > 0.01 || d < -0.01 {
+		t.Errorf("block spans %.1f to %.1f, want it centered between 10 and 90", top, bottom)
+	}
+}
+
+func TestFitShrinks(t *testing.T) {
+	r := Fit(mono{}, Block{Text: strings.Repeat("word ", 30)}, box)
+	if r.Overflow || r.Size >= 20 || r.Size < 8 {
+		t.Fatalf("Fit = size %.2f overflow %v, want shrunk to fit", r.Size, r.Overflow)
+	}
+	last := r.Lines[len(r.Lines)-1]
+	if last.Y+0.2*last.Size > 90 {
+		t.Errorf("last line ends at %.1f, past the margin", last.Y+0.2*last.Size)
+	}
+	for _, l := range r.Lines {
+		if w := (mono{}).Measure(l.Text, l.Size); w > 180 {
+			t.Errorf("%q is %.1f wide, past the margins", l.Text, w)
+		}
+	}
+	// A quarter pixel larger would not fit
+	bigger := box
+	bigger.MinSize, bigger.MaxSize = r.Size+0.3, r.Size+0.3
+	if !Fit(mono{}, Block{Text: strings.Repeat("word ", 30)}, bigger).Overflow {
+		t.Errorf("%.2f fits too, want the largest size that fits", r.Size+0.3)
+	}
+}
+
+func TestFitShrinksForLongWords(t *testing.T) {
+	r := Fit(mono{}, Block{Text: "Pneumonoultramicroscopicsilicovolcanoconiosis"}, box)
+	if w := (mono{}).Measure(r.Lines[0].Text, r.Size); w > 180 {
+		t.Errorf("the word is %.1f wide at %.2f, want it shrunk inside 180", w, r.Size)
+	}
+}
+
+func TestFitOverflows(t *testing.T) {
+	r := Fit(mono{}, Block{Text: strings.Repeat("word ", 500), Attribution: "— Me"}, box)
+	if !r.Overflow || r.Size != 8 {
+		t.Fatalf("Fit = size %.2f overflow %v, want overflow at the smallest size", r.Size, r.Overflow)
+	}
+	last := r.Lines[len(r.Lines)-1]
+	if last.Y+0.2*last.Size > 90.5 {
+		t.Errorf("kept a line ending at %.1f, past the margin", last.Y+0.2*last.Size)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	// 180 wide at 5 per character is 36 a line; 80 high at 12 a line is 6
+	// lines, less room for the attribution
+	if n := Capacity(mono{}, box, 10); n < 100 || n > 36*6 {
+		t.Errorf("Capacity = %d, want a cautious estimate of 36 by 6", n)
+	}
+}
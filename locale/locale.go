@@ -0,0 +1,101 @@
+// Package locale writes what is shown around a quote, the line crediting it
+// and dates, the way the quote's language does: "— Sally Rooney, Normal
+// İnsanlar" in Turkish, "—Sally Rooney, «Gente normal»" in Spanish. The card
+// renderer, the plain-text and JSON snippets, the command-line outputs and
+// the push notifications all go through it.
+package locale
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"quotesparser/origin"
+)
+
+// style is how a language credits quotes and writes dates
+type style struct {
+	dash        string // before the credit
+	open, close string // around a book title
+	months      [12]string
+	date        func(day int, month string, year int) string
+}
+
+var english = style{
+	dash:   "— ",
+	months: [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	date:   func(d int, m string, y int) string { return fmt.Sprintf("%s %d, %d", m, d, y) },
+}
+
+// styles are the languages with conventions of their own; the others are
+// written like English
+var styles = map[string]style{
+	"en": english,
+	"tr": {
+		dash:   "— ",
+		months: [12]string{"Ocak", "Şubat", "Mart", "Nisan", "Mayıs", "Haziran", "Temmuz", "Ağustos", "Eylül", "Ekim", "Kasım", "Aralık"},
+		date:   func(d int, m string, y int) string { return fmt.Sprintf("%d %s %d", d, m, y) },
+	},
+	// The raya is set against the name, and titles go in angle quotes
+	// where there are no italics
+	"es": {
+		dash: "—", open: "«", close: "»",
+		months: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		date:   func(d int, m string, y int) string { return fmt.Sprintf("%d de %s de %d", d, m, y) },
+	},
+	"pt": {
+		dash:   "— ",
+		months: [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		date:   func(d int, m string, y int) string { return fmt.Sprintf("%d de %s de %d", d, m, y) },
+	},
+	// Guillemets take a narrow no-break space inside
+	"fr": {
+		dash: "— ", open: "«\u202f", close: "\u202f»",
+		months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		date: func(d int, m string, y int) string {
+			if d == 1 {
+				return fmt.Sprintf("1er %s %d", m, y)
+			}
+			return fmt.Sprintf("%d %s %d", d, m, y)
+		},
+	},
+	"de": {
+		dash: "— ", open: "„", close: "“",
+		months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		date:   func(d int, m string, y int) string { return fmt.Sprintf("%d. %s %d", d, m, y) },
+	},
+}
+
+// styleOf returns the style of lang, a code such as tr, tr-TR or es_ES
+func styleOf(lang string) style {
+	base, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	base, _, _ = strings.Cut(base, "_")
+	if s, ok := styles[base]; ok {
+		return s
+	}
+	return english
+}
+
+// Credit names who a quote in lang is by, "Author, Book", crediting quotes
+// without an author to the language's word for anonymous, e.g. "Anonim"
+func Credit(author, book, lang string) string {
+	if author == "" {
+		author = origin.Credit(lang)
+	}
+	if book == "" {
+		return author
+	}
+	s := styleOf(lang)
+	return author + ", " + s.open + book + s.close
+}
+
+// Attribution is the line under a quote in lang: its Credit after a dash
+func Attribution(author, book, lang string) string {
+	return styleOf(lang).dash + Credit(author, book, lang)
+}
+
+// Date writes day's date as lang does, e.g. "2 Mart 2026" in Turkish
+func Date(day time.Time, lang string) string {
+	s := styleOf(lang)
+	return s.date(day.Day(), s.months[day.Month()-1], day.Year())
+}
@@ -0,0 +1,49 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttribution(t *testing.T) {
+	cases := []struct {
+		author, book, lang string
+		want               string
+	}{
+		{"Sally Rooney", "Normal İnsanlar", "tr", "— Sally Rooney, Normal İnsanlar"},
+		{"Sally Rooney", "Gente normal", "es", "—Sally Rooney, «Gente normal»"},
+		{"Sally Rooney", "Gente normal", "es-MX", "—Sally Rooney, «Gente normal»"},
+		{"Sally Rooney", "Normal People", "", "— Sally Rooney, Normal People"},
+		{"Sally Rooney", "Normale Menschen", "de", "— Sally Rooney, „Normale Menschen“"},
+		{"Sally Rooney", "Normal People", "fr", "— Sally Rooney, «\u202fNormal People\u202f»"},
+		{"Oscar Wilde", "", "en", "— Oscar Wilde"},
+		{"", "", "tr", "— Anonim"},
+		{"", "", "es", "—Anónimo"},
+		{"", "Dede Korkut", "tr", "— Anonim, Dede Korkut"},
+	}
+	for _, c := range cases {
+		if got := Attribution(c.author, c.book, c.lang); got != c.want {
+			t.Errorf("Attribution(%q, %q, %q) = %q, want %q", c.author, c.book, c.lang, got, c.want)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	day := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for lang, want := range map[string]string{
+		"en":    "March 2, 2026",
+		"tr":    "2 Mart 2026",
+		"es":    "2 de marzo de 2026",
+		"pt_BR": "2 de março de 2026",
+		"de":    "2. März 2026",
+		"fr":    "2 mars 2026",
+		"ja":    "March 2, 2026",
+	} {
+		if got := Date(day, lang); got != want {
+			t.Errorf("Date(%s) = %q, want %q", lang, got, want)
+		}
+	}
+	if got := Date(day.AddDate(0, 0, -1), "fr"); got != "1er mars 2026" {
+		t.Errorf("Date(fr) of the first = %q", got)
+	}
+}
@@ -0,0 +1,109 @@
+// Package media downloads and caches the pictures and audio clips of trivia
+// questions, one folder per question: <dir>/<triviaId>/<kind>.<ext>.
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"quotesparser/fetch"
+)
+
+// Kinds of media a question can have
+const (
+	Image = "image"
+	Audio = "audio"
+)
+
+// ErrNotMedia is returned when an address answers something that is not
+// the kind of media asked for, such as an HTML error page
+var ErrNotMedia = errors.New("not an image or audio clip")
+
+// audioExts are the extensions of the clips audio rounds use
+var audioExts = map[string]bool{".mp3": true, ".ogg": true, ".oga": true, ".opus": true, ".wav": true, ".m4a": true, ".aac": true, ".flac": true}
+
+// KindOf tells an audio clip's address from a picture's by its extension
+func KindOf(address string) string {
+	p := address
+	if u, err := url.Parse(address); err == nil {
+		p = u.Path
+	}
+	if audioExts[strings.ToLower(path.Ext(p))] {
+		return Audio
+	}
+	return Image
+}
+
+// IsURL reports whether s is an http(s) address, as media columns hold
+func IsURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// File is a downloaded image or clip
+type File struct {
+	Body        []byte
+	ContentType string
+}
+
+// Ext returns the extension to save f with, e.g. .jpg
+func (f File) Ext() string {
+	switch f.ContentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/wave", "audio/wav", "audio/x-wav":
+		return ".wav"
+	case "application/ogg", "audio/ogg":
+		return ".ogg"
+	}
+	if exts, _ := mime.ExtensionsByType(f.ContentType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// Download fetches the media at address, checking it is of kind: its
+// content is sniffed, and when that cannot tell, the address's extension
+// decides
+func Download(ctx context.Context, c *fetch.Client, address, kind string) (File, error) {
+	body, err := c.GetContext(ctx, address)
+	if err != nil {
+		return File{}, err
+	}
+	f := File{Body: body, ContentType: http.DetectContentType(body)}
+	if strings.HasPrefix(f.ContentType, "application/octet-stream") || strings.HasPrefix(f.ContentType, "text/plain") {
+		if t := mime.TypeByExtension(path.Ext(address)); t != "" {
+			f.ContentType = t
+		}
+	}
+	f.ContentType, _, _ = strings.Cut(f.ContentType, ";")
+	if !matches(f.ContentType, kind) {
+		return File{}, fmt.Errorf("%w: %s answered %s", ErrNotMedia, address, f.ContentType)
+	}
+	return f, nil
+}
+
+// matches reports whether a content type is of kind; Ogg files may hold
+// either, and are taken for audio
+func matches(contentType, kind string) bool {
+	if kind == Audio && contentType == "application/ogg" {
+		return true
+	}
+	return strings.HasPrefix(contentType, kind+"/")
+}
+
+// Path returns where the media of kind of question id is cached, relative
+// to the cache folder
+func Path(id int64, kind, ext string) string {
+	return filepath.Join(strconv.FormatInt(id, 10), kind+ext)
+}
@@ -0,0 +1,201 @@
+// Package meter shows how far a long run got: the items done of the total,
+// the bytes read or written, the current rate and the time left. On a
+// terminal it redraws a bar in place; elsewhere, as in a log file or under
+// cron, it logs a line every so often instead.
+package meter
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"quotesparser/quota"
+)
+
+const (
+	barWidth = 24
+	// redraw is the shortest pause between two draws of the bar
+	redraw = 100 * time.Millisecond
+	// sample is how often the rate is measured again
+	sample = time.Second
+)
+
+// Meter counts the progress of a run. It is safe for concurrent use.
+type Meter struct {
+	// Interval is the pause between log lines off a terminal; 10 seconds
+	// when 0
+	Interval time.Duration
+
+	w     io.Writer
+	label string
+	unit  string
+	tty   bool
+	now   func() time.Time
+
+	mu      sync.Mutex
+	total   int
+	done    int
+	bytes   int64
+	start   time.Time
+	drawn   time.Time // of the bar, or the last log line
+	width   int       // of the bar as last drawn
+	sampled time.Time
+	at      int     // done when sampled
+	rate    float64 // per second, smoothed
+}
+
+// New returns a Meter of total items named unit, such as pages, drawing to
+// w when it is a terminal
+func New(w io.Writer, label, unit string, total int) *Meter {
+	m := &Meter{w: w, label: label, unit: unit, total: total, now: time.Now}
+	if f, ok := w.(*os.File); ok {
+		info, err := f.Stat()
+		m.tty = err == nil && info.Mode()&os.ModeCharDevice != 0
+	}
+	m.start = m.now()
+	m.sampled = m.start
+	return m
+}
+
+// Add counts items more done, which read or wrote size bytes
+func (m *Meter) Add(items int, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.done += items
+	m.bytes += size
+	m.report()
+}
+
+// Grow changes the total by n, as when a target ends before its last page
+func (m *Meter) Grow(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total = max(m.total+n, m.done)
+	m.report()
+}
+
+// Printf writes a line of its own, keeping the bar below it on a terminal
+func (m *Meter) Printf(format string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clear()
+	fmt.Fprintf(m.w, format, args...)
+	if m.tty && m.width > 0 {
+		m.draw()
+	}
+}
+
+// Finish draws the bar a last time and ends its line
+func (m *Meter) Finish() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tty {
+		m.draw()
+		fmt.Fprintln(m.w)
+		m.width = 0
+	}
+}
+
+// report draws the bar or logs a line when it is time to
+func (m *Meter) report() {
+	now := m.now()
+	if elapsed := now.Sub(m.sampled); elapsed >= sample {
+		current := float64(m.done-m.at) / elapsed.Seconds()
+		if m.rate == 0 {
+			m.rate = current
+		} else {
+			m.rate = 0.3*current + 0.7*m.rate
+		}
+		m.sampled, m.at = now, m.done
+	}
+
+	if m.tty {
+		if now.Sub(m.drawn) >= redraw || m.done >= m.total {
+			m.draw()
+		}
+		return
+	}
+	interval := m.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if now.Sub(m.drawn) >= interval {
+		m.drawn = now
+		slog.Info(m.label, "done", m.done, "total", m.total, "unit", m.unit, "bytes", quota.FormatSize(uint64(m.bytes)),
+			"rate", fmt.Sprintf("%.1f/s", m.currentRate()), "eta", m.eta().String())
+	}
+}
+
+// draw redraws the bar over the last one
+func (m *Meter) draw() {
+	line := m.line()
+	pad := ""
+	if n := len([]rune(line)); n < m.width {
+		pad = strings.Repeat(" ", m.width-n)
+	}
+	fmt.Fprint(m.w, "\r"+line+pad)
+	m.width = len([]rune(line))
+	m.drawn = m.now()
+}
+
+// clear erases the bar, to write something else in its place
+func (m *Meter) clear() {
+	if m.tty && m.width > 0 {
+		fmt.Fprint(m.w, "\r"+strings.Repeat(" ", m.width)+"\r")
+	}
+}
+
+// line is the bar as drawn, e.g.
+// "Downloading [=======>          ] 42/120 pages  1.2MB  3.4/s  ETA 0:23"
+func (m *Meter) line() string {
+	filled := 0
+	if m.total > 0 {
+		filled = min(barWidth*m.done/m.total, barWidth)
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	line := fmt.Sprintf("%s [%s] %d/%d %s", m.label, bar, m.done, m.total, m.unit)
+	if m.bytes > 0 {
+		line += "  " + quota.FormatSize(uint64(m.bytes))
+	}
+	if rate := m.currentRate(); rate > 0 {
+		line += fmt.Sprintf("  %.1f/s  ETA %s", rate, clock(m.eta()))
+	}
+	return line
+}
+
+// currentRate is the smoothed rate, or the average one before the first
+// sample
+func (m *Meter) currentRate() float64 {
+	if m.rate > 0 {
+		return m.rate
+	}
+	if elapsed := m.now().Sub(m.start).Seconds(); elapsed > 0 {
+		return float64(m.done) / elapsed
+	}
+	return 0
+}
+
+// eta is the time left at the current rate
+func (m *Meter) eta() time.Duration {
+	rate := m.currentRate()
+	if rate <= 0 || m.done >= m.total {
+		return 0
+	}
+	return time.Duration(float64(m.total-m.done) / rate * float64(time.Second)).Round(time.Second)
+}
+
+// clock formats d as h:mm:ss, or m:ss under an hour
+func clock(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,48 @@
+package meter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLine(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	m := New(&out, "Downloading", "pages", 120)
+	m.now = func() time.Time { return now }
+	m.start, m.sampled = now, now
+
+	if got, want := m.line(), "Downloading [>                       ] 0/120 pages"; got != want {
+		t.Errorf("line = %q, want %q", got, want)
+	}
+	now = now.Add(10 * time.Second)
+	m.Add(30, 3<<20)
+	if got, want := m.line(), "Downloading [======>                 ] 30/120 pages  3.0MB  3.0/s  ETA 0:30"; got != want {
+		t.Errorf("line = %q, want %q", got, want)
+	}
+	// Targets ending early shrink the total, never below what is done
+	m.Grow(-200)
+	if got := m.line(); !strings.Contains(got, "[========================] 30/30 pages") || strings.Contains(got, "ETA") && !strings.HasSuffix(got, "ETA 0:00") {
+		t.Errorf("line = %q, want a full bar", got)
+	}
+
+	// Off a terminal nothing is drawn; lines of their own still are
+	m.Printf("saved %s\n", "page1.html")
+	if out.String() != "saved page1.html\n" {
+		t.Errorf("written %q", out.String())
+	}
+}
+
+func TestClock(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                           "0:00",
+		83 * time.Second:            "1:23",
+		2*time.Hour + 5*time.Second: "2:00:05",
+	} {
+		if got := clock(d); got != want {
+			t.Errorf("clock(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
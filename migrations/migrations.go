@@ -0,0 +1,269 @@
+// Package migrations tracks the database schema version and applies the
+// embedded migrations to existing database.db files.
+//
+// Each migration is a pair of SQL files in sql/ named <version>_<name>.up.sql
+// and .down.sql, optionally with Go steps registered in steps.go for work SQL
+// cannot do (hashing rows, conditional ALTER TABLE). Going up runs the SQL
+// then the Go step; going down runs the Go step then the SQL. Every migration
+// runs in its own transaction and is recorded in schemaMigrations.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// ErrSchemaMismatch is returned when the database was migrated by a newer
+// build, past the migrations this one knows
+var ErrSchemaMismatch = errors.New("database schema is newer than this build")
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+
+	upSQL, downSQL   string
+	upStep, downStep func(tx *sql.Tx) error
+}
+
+// Reversible reports whether the migration can be rolled back
+func (m Migration) Reversible() bool {
+	return m.downSQL != "" || m.downStep != nil
+}
+
+var fileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// All returns every known migration in version order
+func All() ([]Migration, error) {
+	byVersion := make(map[int]*Migration)
+	get := func(version int, name string) *Migration {
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		return m
+	}
+
+	entries, err := files.ReadDir("sql")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		matches := fileRe.FindStringSubmatch(e.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", e.Name())
+		}
+		version, _ := strconv.Atoi(matches[1])
+		content, err := files.ReadFile(path.Join("sql", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m := get(version, matches[2])
+		if matches[3] == "up" {
+			m.upSQL = string(content)
+		} else {
+			m.downSQL = string(content)
+		}
+	}
+	for version, s := range steps {
+		m := get(version, s.name)
+		m.upStep = s.up
+		m.downStep = s.down
+	}
+
+	var all []Migration
+	for _, m := range byVersion {
+		all = append(all, *m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// Latest returns the highest known version
+func Latest() int {
+	all, err := All()
+	if err != nil || len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
+
+// Current returns the schema version recorded in the database, 0 when no
+// migration has run yet
+func Current(db *sql.DB) (int, error) {
+	if err := ensureVersionTable(db); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schemaMigrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return int(version.Int64), nil
+}
+
+// Recorded returns the schema version recorded in the database like
+// Current, but without creating the version table, so db may be read-only
+func Recorded(db *sql.DB) (int, error) {
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schemaMigrations'").Scan(&tables); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+	var version sql.NullInt64
+	if err := db.QueryRow("SELECT MAX(version) FROM schemaMigrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return int(version.Int64), nil
+}
+
+// Pending returns the migrations not yet applied to the database
+func Pending(db *sql.DB) ([]Migration, error) {
+	current, err := Current(db)
+	if err != nil {
+		return nil, err
+	}
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if err := known(current, all); err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range all {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies pending migrations up to and including target (0 means all)
+// and returns the ones it applied
+func Up(db *sql.DB, target int) ([]Migration, error) {
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		if target > 0 && m.Version > target {
+			break
+		}
+		err := inTx(db, func(tx *sql.Tx) error {
+			if m.upSQL != "" {
+				if _, err := tx.Exec(m.upSQL); err != nil {
+					return err
+				}
+			}
+			if m.upStep != nil {
+				if err := m.upStep(tx); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec("INSERT INTO schemaMigrations (version, name, appliedAt) VALUES (?, ?, ?)",
+				m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// Down rolls back the last steps applied migrations and returns them
+func Down(db *sql.DB, steps int) ([]Migration, error) {
+	current, err := Current(db)
+	if err != nil {
+		return nil, err
+	}
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	// Rolling back under migrations it does not know would leave them applied
+	if err := known(current, all); err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(all) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := all[i]
+		if m.Version > current {
+			continue
+		}
+		if !m.Reversible() {
+			return reverted, fmt.Errorf("migration %04d_%s cannot be rolled back", m.Version, m.Name)
+		}
+		err := inTx(db, func(tx *sql.Tx) error {
+			if m.downStep != nil {
+				if err := m.downStep(tx); err != nil {
+					return err
+				}
+			}
+			if m.downSQL != "" {
+				if _, err := tx.Exec(m.downSQL); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec("DELETE FROM schemaMigrations WHERE version = ?", m.Version)
+			return err
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("rollback of %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		reverted = append(reverted, m)
+	}
+	return reverted, nil
+}
+
+// known returns ErrSchemaMismatch when the database is at a version past all
+func known(current int, all []Migration) error {
+	if len(all) > 0 && current > all[len(all)-1].Version {
+		return fmt.Errorf("%w: database is at version %d, this build knows up to %d", ErrSchemaMismatch, current, all[len(all)-1].Version)
+	}
+	return nil
+}
+
+func ensureVersionTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schemaMigrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			appliedAt TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schemaMigrations table: %v", err)
+	}
+	return nil
+}
+
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,37 @@
+-- Tables as the original ingest scripts created them
+CREATE TABLE IF NOT EXISTS quotes (
+    id INTEGER PRIMARY KEY,
+    text TEXT NOT NULL,
+    author TEXT,
+    lang TEXT,
+    viewCount INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS trivia (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    category TEXT NOT NULL,
+    question TEXT NOT NULL,
+    answer TEXT NOT NULL,
+    viewCount INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS funFacts (
+    id TEXT PRIMARY KEY,
+    text TEXT NOT NULL,
+    viewCount INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS frasesauthors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    authorName TEXT NOT NULL,
+    authorLink TEXT NOT NULL,
+    quoteCount INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS frasesquotes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    authorId INTEGER NOT NULL REFERENCES frasesauthors(id),
+    text TEXT NOT NULL,
+    bookName TEXT,
+    viewCount INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_frasesauthors_name;
+DROP INDEX IF EXISTS idx_frasesquotes_author_text;
+DROP INDEX IF EXISTS idx_trivia_question;
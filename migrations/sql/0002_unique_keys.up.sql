@@ -0,0 +1,19 @@
+-- Natural keys so re-running an ingester updates rows instead of duplicating them
+
+-- Quotes of a duplicate author move to the author row that is kept
+UPDATE frasesquotes SET authorId = (
+    SELECT MIN(k.id) FROM frasesauthors k
+    WHERE k.authorName = (SELECT authorName FROM frasesauthors WHERE id = frasesquotes.authorId)
+) WHERE authorId IN (SELECT id FROM frasesauthors WHERE id NOT IN (SELECT MIN(id) FROM frasesauthors GROUP BY authorName));
+DELETE FROM frasesauthors WHERE id NOT IN (SELECT MIN(id) FROM frasesauthors GROUP BY authorName);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_frasesauthors_name ON frasesauthors(authorName);
+
+-- The quote kept sums the views of its duplicates
+UPDATE frasesquotes SET viewCount = (
+    SELECT SUM(d.viewCount) FROM frasesquotes d WHERE d.authorId = frasesquotes.authorId AND d.text = frasesquotes.text
+) WHERE id IN (SELECT MIN(id) FROM frasesquotes GROUP BY authorId, text HAVING COUNT(*) > 1);
+DELETE FROM frasesquotes WHERE id NOT IN (SELECT MIN(id) FROM frasesquotes GROUP BY authorId, text);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_frasesquotes_author_text ON frasesquotes(authorId, text);
+
+DELETE FROM trivia WHERE id NOT IN (SELECT MIN(id) FROM trivia GROUP BY question);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_trivia_question ON trivia(question);
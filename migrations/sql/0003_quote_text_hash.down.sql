@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_quotes_text_hash;
+ALTER TABLE quotes DROP COLUMN textHash;
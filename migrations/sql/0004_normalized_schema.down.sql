@@ -0,0 +1,8 @@
+ALTER TABLE quotes DROP COLUMN authorId;
+ALTER TABLE quotes DROP COLUMN bookId;
+ALTER TABLE quotes DROP COLUMN sourceId;
+DROP TABLE IF EXISTS quoteTags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS books;
+DROP TABLE IF EXISTS authors;
+DROP TABLE IF EXISTS sources;
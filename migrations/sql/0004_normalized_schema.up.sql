@@ -0,0 +1,38 @@
+-- authors, books, sources and tags; quotes keeps its flat columns (paperpi
+-- reads them directly) and gains foreign keys to these in the Go step
+CREATE TABLE IF NOT EXISTS sources (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    baseUrl TEXT
+);
+
+CREATE TABLE IF NOT EXISTS authors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    link TEXT
+);
+
+CREATE TABLE IF NOT EXISTS books (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    authorId INTEGER REFERENCES authors(id),
+    link TEXT,
+    UNIQUE(title, authorId)
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS quoteTags (
+    quoteId INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    tagId INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (quoteId, tagId)
+);
+
+INSERT INTO sources (name, baseUrl) VALUES
+    ('1000kitap', 'https://1000kitap.com'),
+    ('fraseslibros', 'https://fraseslibros.com'),
+    ('uselessfacts', 'https://uselessfacts.jsph.pl')
+ON CONFLICT(name) DO NOTHING;
@@ -0,0 +1,6 @@
+-- quotes.textHash keeps its normalized values; the exact-text hashes are gone
+DROP INDEX IF EXISTS idx_trivia_question_hash;
+ALTER TABLE trivia DROP COLUMN questionHash;
+
+DROP INDEX IF EXISTS idx_funfacts_text_hash;
+ALTER TABLE funFacts DROP COLUMN textHash;
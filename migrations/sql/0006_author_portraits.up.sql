@@ -0,0 +1,17 @@
+-- Wikimedia portraits found by quotes portraits; the image files live in the
+-- portrait cache as <authorId>/<width>.jpg, one per width listed here
+CREATE TABLE IF NOT EXISTS authorPortraits (
+    authorId INTEGER PRIMARY KEY REFERENCES authors(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,           -- ok, or none when no free portrait was found
+    title TEXT,                     -- Commons file
+    page TEXT,                      -- Wikipedia article it illustrates
+    sourceUrl TEXT,
+    descriptionUrl TEXT,
+    license TEXT,
+    licenseUrl TEXT,
+    artist TEXT,
+    credit TEXT,
+    attributionRequired INTEGER NOT NULL DEFAULT 0,
+    widths TEXT,                    -- e.g. 64,256,512
+    fetchedAt TEXT NOT NULL
+);
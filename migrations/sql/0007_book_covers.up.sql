@@ -0,0 +1,11 @@
+-- OpenLibrary covers found by quotes covers; the image files live in the
+-- cover cache as <bookId>/<width>.jpg, one per width listed here
+CREATE TABLE IF NOT EXISTS bookCovers (
+    bookId INTEGER PRIMARY KEY REFERENCES books(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,           -- ok, or none when no cover was found
+    coverId INTEGER,                -- OpenLibrary cover id
+    work TEXT,                      -- OpenLibrary work, e.g. /works/OL20195335W
+    sourceUrl TEXT,
+    widths TEXT,                    -- e.g. 96,240,480
+    fetchedAt TEXT NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE bookCovers DROP COLUMN palette;
+ALTER TABLE authorPortraits DROP COLUMN palette;
@@ -0,0 +1,4 @@
+-- Dominant colors of each cached portrait and cover, most common first, as
+-- #rrggbb,#rrggbb; quote cards are themed with them
+ALTER TABLE authorPortraits ADD COLUMN palette TEXT;
+ALTER TABLE bookCovers ADD COLUMN palette TEXT;
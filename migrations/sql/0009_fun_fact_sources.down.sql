@@ -0,0 +1,4 @@
+ALTER TABLE funFacts DROP COLUMN permalink;
+ALTER TABLE funFacts DROP COLUMN lang;
+ALTER TABLE funFacts DROP COLUMN sourceUrl;
+ALTER TABLE funFacts DROP COLUMN source;
@@ -0,0 +1,5 @@
+-- Where each fun fact comes from, as the uselessfacts JSON API reports it
+ALTER TABLE funFacts ADD COLUMN source TEXT;
+ALTER TABLE funFacts ADD COLUMN sourceUrl TEXT;
+ALTER TABLE funFacts ADD COLUMN lang TEXT;
+ALTER TABLE funFacts ADD COLUMN permalink TEXT;
@@ -0,0 +1,13 @@
+-- Requests answered by quotes serve, counted per day, route, API key,
+-- language and source; quotes stats api summarizes them and the server
+-- drops days older than it keeps
+CREATE TABLE IF NOT EXISTS apiUsage (
+    day TEXT NOT NULL,                  -- UTC, e.g. 2024-01-31
+    endpoint TEXT NOT NULL,             -- route, e.g. GET /quotes/{id}
+    apiKey TEXT NOT NULL DEFAULT '',    -- name of the key; '' when anonymous
+    lang TEXT NOT NULL DEFAULT '',      -- language asked for or answered
+    source TEXT NOT NULL DEFAULT '',    -- source of the quote answered
+    requests INTEGER NOT NULL DEFAULT 0,
+    errors INTEGER NOT NULL DEFAULT 0,  -- answered with a 4xx or 5xx
+    PRIMARY KEY (day, endpoint, apiKey, lang, source)
+);
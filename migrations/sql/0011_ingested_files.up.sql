@@ -0,0 +1,7 @@
+-- Files processFunFacts.go has read, so later runs skip the unchanged ones
+CREATE TABLE IF NOT EXISTS ingestedFiles (
+    path TEXT PRIMARY KEY,          -- as read, e.g. funfacts/abc.json
+    hash TEXT NOT NULL,             -- SHA-256 of the content, hex
+    size INTEGER NOT NULL,
+    ingestedAt TEXT NOT NULL        -- RFC 3339, UTC
+);
@@ -0,0 +1,8 @@
+-- Author links quotes relink rewrote after a site changed its URLs, so old
+-- links found elsewhere can still be resolved
+CREATE TABLE IF NOT EXISTS linkMoves (
+    oldLink TEXT PRIMARY KEY,
+    newLink TEXT NOT NULL,          -- the latest link, updated by later moves
+    rule TEXT NOT NULL,             -- template it was rewritten with, e.g. https://fraseslibros.com/autor/{slug}
+    movedAt TEXT NOT NULL           -- RFC 3339, UTC
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_quotes_origin;
+ALTER TABLE quotes DROP COLUMN origin;
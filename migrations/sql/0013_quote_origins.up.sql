@@ -0,0 +1,4 @@
+-- The kind of work each quote comes from (book, speech, film, song,
+-- anonymous or unknown), filled in by the Go step and by quotes normalize
+ALTER TABLE quotes ADD COLUMN origin TEXT;
+CREATE INDEX IF NOT EXISTS idx_quotes_origin ON quotes(origin);
@@ -0,0 +1,3 @@
+-- Nothing to undo in the schema. Quotes stay anonymous: the author names
+-- taken off them, such as "Anonymous" or "Atasözü", were not kept, so they
+-- cannot be put back. The origin column marking them goes with 0013.
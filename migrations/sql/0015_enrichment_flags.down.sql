@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_quotes_needs_enrichment;
+ALTER TABLE quotes DROP COLUMN needsEnrichment;
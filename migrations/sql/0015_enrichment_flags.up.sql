@@ -0,0 +1,4 @@
+-- Quotes kept without fields their source normally has, flagged for a later
+-- pass to look them up, as the enrich policy of parse saves them
+ALTER TABLE quotes ADD COLUMN needsEnrichment INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_quotes_needs_enrichment ON quotes(needsEnrichment) WHERE needsEnrichment = 1;
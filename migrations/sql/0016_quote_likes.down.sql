@@ -0,0 +1,3 @@
+DELETE FROM sources WHERE name IN ('goodreads', 'wikiquote')
+    AND id NOT IN (SELECT sourceId FROM quotes WHERE sourceId IS NOT NULL);
+ALTER TABLE quotes DROP COLUMN likes;
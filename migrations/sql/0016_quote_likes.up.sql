@@ -0,0 +1,8 @@
+-- How many readers liked a quote on the site it was collected from, as
+-- Goodreads counts them, and the sites added since the normalized schema
+ALTER TABLE quotes ADD COLUMN likes INTEGER;
+
+INSERT INTO sources (name, baseUrl) VALUES
+    ('goodreads', 'https://www.goodreads.com'),
+    ('wikiquote', 'https://www.wikiquote.org')
+ON CONFLICT(name) DO NOTHING;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS triviaMedia;
+ALTER TABLE trivia DROP COLUMN audioUrl;
+ALTER TABLE trivia DROP COLUMN imageUrl;
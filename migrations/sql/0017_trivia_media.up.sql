@@ -0,0 +1,16 @@
+-- Optional media of picture and audio rounds: the address of a question's
+-- image or clip, and the copy quotes trivia-media caches of it as
+-- <triviaId>/<kind>.<ext> under its folder
+ALTER TABLE trivia ADD COLUMN imageUrl TEXT;
+ALTER TABLE trivia ADD COLUMN audioUrl TEXT;
+
+CREATE TABLE IF NOT EXISTS triviaMedia (
+    triviaId INTEGER NOT NULL REFERENCES trivia(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,             -- image or audio
+    status TEXT NOT NULL,           -- ok, or none when the address has no media
+    sourceUrl TEXT NOT NULL,
+    file TEXT,                      -- e.g. 12/image.jpg
+    contentType TEXT,
+    fetchedAt TEXT NOT NULL,
+    PRIMARY KEY (triviaId, kind)
+);
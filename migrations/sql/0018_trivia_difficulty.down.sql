@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_trivia_category_difficulty;
+ALTER TABLE trivia DROP COLUMN difficulty;
@@ -0,0 +1,5 @@
+-- How hard a question is, easy, medium or hard, so balanced selection can
+-- spread a session over the levels; NULL when trivia.txt does not say
+ALTER TABLE trivia ADD COLUMN difficulty TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_trivia_category_difficulty ON trivia(category, difficulty);
@@ -0,0 +1,2 @@
+ALTER TABLE trivia DROP COLUMN hint;
+ALTER TABLE trivia DROP COLUMN wrongAnswers;
@@ -0,0 +1,4 @@
+-- Wrong answers of a multiple-choice question, as a JSON array of strings,
+-- and a hint to show before the answer; NULL when the importer has none
+ALTER TABLE trivia ADD COLUMN wrongAnswers TEXT;
+ALTER TABLE trivia ADD COLUMN hint TEXT;
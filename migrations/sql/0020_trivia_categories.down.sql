@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_trivia_category_id;
+ALTER TABLE trivia DROP COLUMN categoryId;
+DROP TABLE IF EXISTS triviaCategoryAliases;
+DROP TABLE IF EXISTS triviaCategories;
@@ -0,0 +1,15 @@
+-- Canonical trivia categories and the other spellings of them, added with
+-- quotes trivia categories --alias; trivia keeps its category column, now
+-- the canonical name, and gains a foreign key filled in by the Go step
+CREATE TABLE IF NOT EXISTS triviaCategories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS triviaCategoryAliases (
+    alias TEXT PRIMARY KEY,         -- category.Key of the spelling
+    categoryId INTEGER NOT NULL REFERENCES triviaCategories(id) ON DELETE CASCADE
+);
+
+ALTER TABLE trivia ADD COLUMN categoryId INTEGER REFERENCES triviaCategories(id);
+CREATE INDEX IF NOT EXISTS idx_trivia_category_id ON trivia(categoryId);
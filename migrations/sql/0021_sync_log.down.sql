@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS syncFunFactsDelete;
+DROP TRIGGER IF EXISTS syncFunFactsUpdate;
+DROP TRIGGER IF EXISTS syncFunFactsInsert;
+DROP TRIGGER IF EXISTS syncTriviaDelete;
+DROP TRIGGER IF EXISTS syncTriviaUpdate;
+DROP TRIGGER IF EXISTS syncTriviaInsert;
+DROP TRIGGER IF EXISTS syncQuotesDelete;
+DROP TRIGGER IF EXISTS syncQuotesUpdate;
+DROP TRIGGER IF EXISTS syncQuotesInsert;
+DROP TABLE IF EXISTS syncLog;
@@ -0,0 +1,70 @@
+-- The last change to each quote, trivia question and fun fact, in the order
+-- they were made, so offline clients can download what changed since they
+-- last synced (GET /sync?token=). Deleted rows stay as tombstones. Views are
+-- not changes, nor are saves that leave a row as it was. The triggers
+-- delete and insert rather than INSERT OR REPLACE, which the conflict
+-- clause of an upsert firing them would override.
+CREATE TABLE IF NOT EXISTS syncLog (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    collection TEXT NOT NULL,       -- quotes, trivia or funFacts
+    rowId TEXT NOT NULL,
+    deleted INTEGER NOT NULL DEFAULT 0,
+    updatedAt TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    UNIQUE(collection, rowId)
+);
+
+CREATE TRIGGER IF NOT EXISTS syncQuotesInsert AFTER INSERT ON quotes BEGIN
+    DELETE FROM syncLog WHERE collection = 'quotes' AND rowId = NEW.id;
+    INSERT INTO syncLog (collection, rowId) VALUES ('quotes', NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS syncQuotesUpdate AFTER UPDATE ON quotes
+WHEN OLD.text IS NOT NEW.text OR OLD.author IS NOT NEW.author OR OLD.lang IS NOT NEW.lang
+    OR OLD.authorId IS NOT NEW.authorId OR OLD.bookId IS NOT NEW.bookId
+    OR OLD.sourceId IS NOT NEW.sourceId OR OLD.origin IS NOT NEW.origin
+BEGIN
+    DELETE FROM syncLog WHERE collection = 'quotes' AND rowId = NEW.id;
+    INSERT INTO syncLog (collection, rowId) VALUES ('quotes', NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS syncQuotesDelete AFTER DELETE ON quotes BEGIN
+    DELETE FROM syncLog WHERE collection = 'quotes' AND rowId = OLD.id;
+    INSERT INTO syncLog (collection, rowId, deleted) VALUES ('quotes', OLD.id, 1);
+END;
+
+CREATE TRIGGER IF NOT EXISTS syncTriviaInsert AFTER INSERT ON trivia BEGIN
+    DELETE FROM syncLog WHERE collection = 'trivia' AND rowId = NEW.id;
+    INSERT INTO syncLog (collection, rowId) VALUES ('trivia', NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS syncTriviaUpdate AFTER UPDATE ON trivia
+WHEN OLD.category IS NOT NEW.category OR OLD.question IS NOT NEW.question OR OLD.answer IS NOT NEW.answer
+    OR OLD.wrongAnswers IS NOT NEW.wrongAnswers OR OLD.hint IS NOT NEW.hint OR OLD.difficulty IS NOT NEW.difficulty
+    OR OLD.imageUrl IS NOT NEW.imageUrl OR OLD.audioUrl IS NOT NEW.audioUrl
+BEGIN
+    DELETE FROM syncLog WHERE collection = 'trivia' AND rowId = NEW.id;
+    INSERT INTO syncLog (collection, rowId) VALUES ('trivia', NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS syncTriviaDelete AFTER DELETE ON trivia BEGIN
+    DELETE FROM syncLog WHERE collection = 'trivia' AND rowId = OLD.id;
+    INSERT INTO syncLog (collection, rowId, deleted) VALUES ('trivia', OLD.id, 1);
+END;
+
+CREATE TRIGGER IF NOT EXISTS syncFunFactsInsert AFTER INSERT ON funFacts BEGIN
+    DELETE FROM syncLog WHERE collection = 'funFacts' AND rowId = NEW.id;
+    INSERT INTO syncLog (collection, rowId) VALUES ('funFacts', NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS syncFunFactsUpdate AFTER UPDATE ON funFacts
+WHEN OLD.text IS NOT NEW.text OR OLD.source IS NOT NEW.source OR OLD.sourceUrl IS NOT NEW.sourceUrl
+    OR OLD.lang IS NOT NEW.lang OR OLD.permalink IS NOT NEW.permalink
+BEGIN
+    DELETE FROM syncLog WHERE collection = 'funFacts' AND rowId = NEW.id;
+    INSERT INTO syncLog (collection, rowId) VALUES ('funFacts', NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS syncFunFactsDelete AFTER DELETE ON funFacts BEGIN
+    DELETE FROM syncLog WHERE collection = 'funFacts' AND rowId = OLD.id;
+    INSERT INTO syncLog (collection, rowId, deleted) VALUES ('funFacts', OLD.id, 1);
+END;
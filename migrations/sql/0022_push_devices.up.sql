@@ -0,0 +1,11 @@
+-- Devices registered with POST /push/devices for the quote of the day,
+-- which quotes push sends them at a local time of their choosing
+CREATE TABLE IF NOT EXISTS pushDevices (
+    token TEXT PRIMARY KEY,         -- FCM registration token or APNs device token
+    platform TEXT NOT NULL,         -- fcm or apns
+    lang TEXT,                      -- language of the quote, any when NULL
+    timeZone TEXT NOT NULL DEFAULT 'UTC',
+    deliverAt TEXT NOT NULL DEFAULT '09:00', -- HH:MM in timeZone
+    lastSent TEXT,                  -- local date of the last quote sent
+    registeredAt TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
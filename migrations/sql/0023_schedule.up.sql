@@ -0,0 +1,10 @@
+-- Quotes pinned to a date with quotes schedule, e.g. on an author's
+-- birthday: the quote of that day is one of them when it matches, before
+-- the deterministic pick
+CREATE TABLE IF NOT EXISTS schedule (
+    date TEXT NOT NULL,             -- YYYY-MM-DD, in the time zone of each output
+    quoteId INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    note TEXT,                      -- why, e.g. "Sally Rooney's birthday"
+    createdAt TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    PRIMARY KEY (date, quoteId)
+);
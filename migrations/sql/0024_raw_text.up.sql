@@ -0,0 +1,3 @@
+-- The text of a quote as scraped, before its source's cleanup chain tidied
+-- it into text; NULL when the cleanup changed nothing
+ALTER TABLE quotes ADD COLUMN rawText TEXT;
@@ -0,0 +1,3 @@
+-- Nothing to undo in the schema. The hashes stay folded for case and accents,
+-- and the rows merged because of it stay merged, as which rows they were
+-- cannot be told any more.
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS quoteSearchDelete;
+DROP TRIGGER IF EXISTS quoteSearchUpdate;
+DROP TABLE IF EXISTS quoteSearch;
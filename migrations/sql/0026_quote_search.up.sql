@@ -0,0 +1,15 @@
+-- Full-text index of quotes for quotes search, keyed by quote id (docid).
+-- It holds the text and author folded by dedup.Loose, which the Go step and
+-- schema.IndexSearch fill in; the triggers only take out the quotes whose
+-- text or author changed, to be indexed again on the next search.
+CREATE VIRTUAL TABLE IF NOT EXISTS quoteSearch USING fts4(text, author, tokenize=unicode61 "remove_diacritics=2");
+
+CREATE TRIGGER IF NOT EXISTS quoteSearchUpdate AFTER UPDATE ON quotes
+WHEN OLD.text IS NOT NEW.text OR OLD.author IS NOT NEW.author
+BEGIN
+    DELETE FROM quoteSearch WHERE docid = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS quoteSearchDelete AFTER DELETE ON quotes BEGIN
+    DELETE FROM quoteSearch WHERE docid = OLD.id;
+END;
@@ -0,0 +1,7 @@
+-- Other spellings of authors merged by quotes authors merge, such as
+-- "SABAHATTİN ALİ" for Sabahattin Ali, so quotes credited to one later are
+-- linked to the canonical author
+CREATE TABLE IF NOT EXISTS authorAliases (
+    name TEXT PRIMARY KEY,
+    authorId INTEGER NOT NULL REFERENCES authors(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- How sure the parser was of a quote, from 0 to 1: lower when fields were
+-- missing, a fallback found it or its cleanup had much to undo. NULL for
+-- quotes saved before it was scored.
+ALTER TABLE quotes ADD COLUMN confidence REAL;
@@ -0,0 +1,6 @@
+ALTER TABLE authors DROP COLUMN bio;
+ALTER TABLE authors DROP COLUMN nationality;
+ALTER TABLE authors DROP COLUMN deathYear;
+ALTER TABLE authors DROP COLUMN birthYear;
+ALTER TABLE authors DROP COLUMN wikidataId;
+DROP TABLE IF EXISTS authorLookups;
@@ -0,0 +1,19 @@
+-- What quotes authors enrich found on Wikidata, by name, so an author looked
+-- up once, from authors or frasesauthors, is not looked up again
+CREATE TABLE IF NOT EXISTS authorLookups (
+    name TEXT PRIMARY KEY,
+    status TEXT NOT NULL,           -- ok, or none when no Wikipedia tried has the person
+    wikidataId TEXT,                -- e.g. Q312739
+    birthYear INTEGER,              -- negative before the common era
+    deathYear INTEGER,
+    nationality TEXT,               -- countries of citizenship, comma separated
+    bio TEXT,
+    fetchedAt TEXT NOT NULL
+);
+
+-- The lookups of the authors of the same name
+ALTER TABLE authors ADD COLUMN wikidataId TEXT;
+ALTER TABLE authors ADD COLUMN birthYear INTEGER;
+ALTER TABLE authors ADD COLUMN deathYear INTEGER;
+ALTER TABLE authors ADD COLUMN nationality TEXT;
+ALTER TABLE authors ADD COLUMN bio TEXT;
@@ -0,0 +1,6 @@
+ALTER TABLE books DROP COLUMN detailsFetchedAt;
+ALTER TABLE books DROP COLUMN coverUrl;
+ALTER TABLE books DROP COLUMN originalTitle;
+ALTER TABLE books DROP COLUMN publishYear;
+ALTER TABLE books DROP COLUMN isbn;
+ALTER TABLE books DROP COLUMN work;
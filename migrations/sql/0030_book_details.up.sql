@@ -0,0 +1,7 @@
+-- What quotes books found on OpenLibrary, for citing the books
+ALTER TABLE books ADD COLUMN work TEXT;             -- OpenLibrary work, e.g. /works/OL20195335W
+ALTER TABLE books ADD COLUMN isbn TEXT;
+ALTER TABLE books ADD COLUMN publishYear INTEGER;   -- first published
+ALTER TABLE books ADD COLUMN originalTitle TEXT;    -- the work's title when it differs, as a translation's does
+ALTER TABLE books ADD COLUMN coverUrl TEXT;
+ALTER TABLE books ADD COLUMN detailsFetchedAt TEXT; -- when looked up, found or not
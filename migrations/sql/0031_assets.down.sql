@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS assets;
+ALTER TABLE authors DROP COLUMN imageUrl;
+ALTER TABLE authorLookups DROP COLUMN imageUrl;
@@ -0,0 +1,15 @@
+-- The picture of the author Wikidata has, found by quotes authors enrich
+ALTER TABLE authorLookups ADD COLUMN imageUrl TEXT;
+ALTER TABLE authors ADD COLUMN imageUrl TEXT;
+
+-- Images the enrichment steps point to, books.coverUrl and authors.imageUrl,
+-- cached by quotes assets. Addresses serving the same image share its hash
+-- and so its variants.
+CREATE TABLE IF NOT EXISTS assets (
+    url TEXT PRIMARY KEY,
+    status TEXT NOT NULL,   -- ok, or none when the address serves no image
+    hash TEXT,              -- of the image, naming its folder in the cache
+    widths TEXT,            -- cached widths, e.g. 96,240,480
+    fetchedAt TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_assets_hash ON assets(hash);
@@ -0,0 +1,14 @@
+-- Answers to API mutations sent with an Idempotency-Key header, replayed
+-- when the client retries the same request
+CREATE TABLE IF NOT EXISTS idempotencyKeys (
+    caller TEXT NOT NULL,    -- name of the API key sent, empty for none
+    key TEXT NOT NULL,       -- as the client sent it
+    request TEXT NOT NULL,   -- hash of the method, address and body
+    status INTEGER,          -- of the answer, NULL while the request is served
+    contentType TEXT,
+    location TEXT,
+    body BLOB,
+    createdAt TEXT NOT NULL,
+    PRIMARY KEY (caller, key)
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotencyKeys(createdAt);
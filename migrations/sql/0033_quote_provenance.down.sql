@@ -0,0 +1,4 @@
+ALTER TABLE quotes DROP COLUMN parserVersion;
+ALTER TABLE quotes DROP COLUMN fetchedAt;
+ALTER TABLE quotes DROP COLUMN sourceFile;
+ALTER TABLE quotes DROP COLUMN sourceUrl;
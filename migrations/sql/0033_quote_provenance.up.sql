@@ -0,0 +1,6 @@
+-- Where each quote was read from, as first saved; NULL for quotes saved
+-- before it was recorded
+ALTER TABLE quotes ADD COLUMN sourceUrl TEXT;     -- page the quote was on
+ALTER TABLE quotes ADD COLUMN sourceFile TEXT;    -- page or file it was parsed or imported from
+ALTER TABLE quotes ADD COLUMN fetchedAt TEXT;     -- when the page was downloaded
+ALTER TABLE quotes ADD COLUMN parserVersion TEXT; -- e.g. 1000kitap@3f2a9c1
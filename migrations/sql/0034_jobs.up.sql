@@ -0,0 +1,16 @@
+-- Long commands queued with quotes jobs submit or POST /jobs, which
+-- quotes serve runs in the background one after another
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    command TEXT NOT NULL,              -- quotes command, e.g. crawl
+    args TEXT NOT NULL DEFAULT '[]',    -- its arguments, as a JSON array
+    status TEXT NOT NULL DEFAULT 'queued', -- queued, running, done, failed or cancelled
+    progress REAL NOT NULL DEFAULT 0,   -- from 0 to 1, as the command reports it
+    message TEXT,                       -- the last the command said, or why it failed
+    cancel INTEGER NOT NULL DEFAULT 0,  -- 1 once asked to stop while running
+    submittedBy TEXT,
+    submittedAt TEXT NOT NULL,          -- RFC 3339, UTC
+    startedAt TEXT,
+    finishedAt TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
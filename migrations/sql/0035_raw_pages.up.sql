@@ -0,0 +1,12 @@
+-- Raw pages kept by quotes archive and download --archive, gzipped, for
+-- quotes parse --archive to read again without downloading them
+CREATE TABLE IF NOT EXISTS rawPages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,       -- name of the source, e.g. goodreads
+    url TEXT NOT NULL,          -- address of the page, or its file when unknown
+    fetchedAt TEXT NOT NULL,    -- RFC 3339, UTC
+    size INTEGER NOT NULL,      -- of the page, uncompressed
+    body BLOB NOT NULL,         -- the page, gzipped
+    UNIQUE (url, fetchedAt)
+);
+CREATE INDEX IF NOT EXISTS idx_rawPages_source ON rawPages(source);
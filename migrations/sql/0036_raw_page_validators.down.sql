@@ -0,0 +1,2 @@
+ALTER TABLE rawPages DROP COLUMN lastModified;
+ALTER TABLE rawPages DROP COLUMN etag;
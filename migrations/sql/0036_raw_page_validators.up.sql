@@ -0,0 +1,4 @@
+-- The ETag and Last-Modified headers sent with each archived page, which
+-- download --archive sends back to fetch only the pages changed since
+ALTER TABLE rawPages ADD COLUMN etag TEXT;
+ALTER TABLE rawPages ADD COLUMN lastModified TEXT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS recrawlFrontier;
+DROP TABLE IF EXISTS quoteReports;
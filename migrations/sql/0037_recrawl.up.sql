@@ -0,0 +1,22 @@
+-- Quotes readers reported as wrong, through POST /quotes/{id}/report
+CREATE TABLE IF NOT EXISTS quoteReports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    quoteId INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    reason TEXT,                -- as the reader gave it, if at all
+    reportedAt TEXT NOT NULL    -- RFC 3339, UTC
+);
+CREATE INDEX IF NOT EXISTS idx_quoteReports_quote ON quoteReports(quoteId);
+
+-- The pages quotes were read from, ranked by quotes recrawl score for
+-- quotes recrawl run to fetch again, most wanted first
+CREATE TABLE IF NOT EXISTS recrawlFrontier (
+    url TEXT PRIMARY KEY,       -- the quotes' sourceUrl
+    source TEXT NOT NULL,       -- name of the source that parses it
+    priority REAL NOT NULL DEFAULT 0,
+    reports INTEGER NOT NULL DEFAULT 0,       -- about its quotes, since it was last crawled
+    views INTEGER NOT NULL DEFAULT 0,         -- of its quotes
+    lowConfidence INTEGER NOT NULL DEFAULT 0, -- quotes parsed below the confidence threshold
+    scoredAt TEXT NOT NULL,     -- RFC 3339, UTC
+    crawledAt TEXT              -- when quotes recrawl run last fetched it
+);
+CREATE INDEX IF NOT EXISTS idx_recrawlFrontier_priority ON recrawlFrontier(priority);
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"database/sql"
+
+	"quotesparser/schema"
+)
+
+type step struct {
+	name     string
+	up, down func(tx *sql.Tx) error
+}
+
+// steps are the Go parts of migrations, keyed by version
+var steps = map[int]step{
+	3: {
+		name: "quote_text_hash",
+		up: func(tx *sql.Tx) error {
+			return schema.AddTextHashes(tx)
+		},
+	},
+	4: {
+		name: "normalized_schema",
+		up: func(tx *sql.Tx) error {
+			if err := schema.AddQuoteForeignKeys(tx); err != nil {
+				return err
+			}
+			_, err := schema.Normalize(tx)
+			return err
+		},
+	},
+	5: {
+		name: "normalized_hashes",
+		up: func(tx *sql.Tx) error {
+			_, err := schema.Dedup(tx)
+			return err
+		},
+	},
+	13: {
+		name: "quote_origins",
+		up: func(tx *sql.Tx) error {
+			_, err := schema.ClassifyOrigins(tx)
+			return err
+		},
+	},
+	14: {
+		name: "anonymous_quotes",
+		up: func(tx *sql.Tx) error {
+			_, err := schema.Anonymize(tx)
+			return err
+		},
+	},
+	20: {
+		name: "trivia_categories",
+		up: func(tx *sql.Tx) error {
+			_, err := schema.NormalizeTriviaCategories(tx)
+			return err
+		},
+	},
+	25: {
+		name: "folded_hashes",
+		up: func(tx *sql.Tx) error {
+			_, err := schema.Dedup(tx)
+			return err
+		},
+	},
+	26: {
+		name: "quote_search",
+		up: func(tx *sql.Tx) error {
+			_, err := schema.IndexSearch(tx)
+			return err
+		},
+	},
+}
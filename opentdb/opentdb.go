@@ -0,0 +1,168 @@
+// Package opentdb downloads trivia questions from the Open Trivia Database
+// API, https://opentdb.com.
+package opentdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"quotesparser/fetch"
+)
+
+// BaseURL is the root of the API
+const BaseURL = "https://opentdb.com"
+
+// MaxAmount is the most questions the API answers a request with
+const MaxAmount = 50
+
+// Question is a question as the API answers it, HTML entities decoded
+type Question struct {
+	Type             string   `json:"type"`       // multiple or boolean
+	Difficulty       string   `json:"difficulty"` // easy, medium or hard
+	Category         string   `json:"category"`   // e.g. Entertainment: Books
+	Question         string   `json:"question"`
+	CorrectAnswer    string   `json:"correct_answer"`
+	IncorrectAnswers []string `json:"incorrect_answers"`
+}
+
+// Query narrows the questions asked for; zero fields ask for any
+type Query struct {
+	Amount     int    // 1 to MaxAmount; 0 means MaxAmount
+	Category   int    // category id, see Categories
+	Difficulty string // easy, medium or hard
+	Type       string // multiple or boolean
+	Token      string // session token, so no question is answered twice
+}
+
+// URL returns the address answering q
+func (q Query) URL() string {
+	v := url.Values{}
+	amount := q.Amount
+	if amount <= 0 || amount > MaxAmount {
+		amount = MaxAmount
+	}
+	v.Set("amount", strconv.Itoa(amount))
+	if q.Category > 0 {
+		v.Set("category", strconv.Itoa(q.Category))
+	}
+	if q.Difficulty != "" {
+		v.Set("difficulty", q.Difficulty)
+	}
+	if q.Type != "" {
+		v.Set("type", q.Type)
+	}
+	if q.Token != "" {
+		v.Set("token", q.Token)
+	}
+	return BaseURL + "/api.php?" + v.Encode()
+}
+
+// ErrExhausted is returned once a session token has been answered every
+// question matching the query
+var ErrExhausted = errors.New("no more questions for this query")
+
+// ErrTooFew is returned when fewer questions match the query than its
+// amount; asking for fewer may still get some
+var ErrTooFew = errors.New("fewer questions match than asked for")
+
+// ErrRateLimited is returned when the API asks to wait, as it does for more
+// than one request every 5 seconds from an address
+var ErrRateLimited = errors.New("rate limited: wait 5 seconds between requests")
+
+// responseErrors are the API's response codes other than 0, success
+var responseErrors = map[int]error{
+	1: ErrTooFew,
+	2: errors.New("invalid parameter"),
+	3: errors.New("session token not found"),
+	4: ErrExhausted,
+	5: ErrRateLimited,
+}
+
+// Decode reads an answer of api.php
+func Decode(body []byte) ([]Question, error) {
+	var resp struct {
+		ResponseCode int        `json:"response_code"`
+		Results      []Question `json:"results"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse questions: %v", err)
+	}
+	if resp.ResponseCode != 0 {
+		if err, ok := responseErrors[resp.ResponseCode]; ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected response code %d", resp.ResponseCode)
+	}
+	for i := range resp.Results {
+		q := &resp.Results[i]
+		q.Category = unescape(q.Category)
+		q.Question = unescape(q.Question)
+		q.CorrectAnswer = unescape(q.CorrectAnswer)
+		for j, a := range q.IncorrectAnswers {
+			q.IncorrectAnswers[j] = unescape(a)
+		}
+	}
+	return resp.Results, nil
+}
+
+// unescape decodes the HTML entities the API encodes text with by default,
+// e.g. &quot; and &#039;
+func unescape(s string) string {
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// Get asks the API for the questions of q
+func Get(ctx context.Context, c *fetch.Client, q Query) ([]Question, error) {
+	body, err := c.GetContext(ctx, q.URL())
+	if err != nil {
+		return nil, err
+	}
+	return Decode(body)
+}
+
+// Token requests a session token: while it lives (6 hours of disuse), the
+// queries made with it never answer the same question twice
+func Token(ctx context.Context, c *fetch.Client) (string, error) {
+	body, err := c.GetContext(ctx, BaseURL+"/api_token.php?command=request")
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		ResponseCode int    `json:"response_code"`
+		Token        string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse token: %v", err)
+	}
+	if resp.ResponseCode != 0 || resp.Token == "" {
+		return "", fmt.Errorf("failed to get a session token: response code %d", resp.ResponseCode)
+	}
+	return resp.Token, nil
+}
+
+// Category is one of the API's categories
+type Category struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Categories lists the categories questions can be asked from
+func Categories(ctx context.Context, c *fetch.Client) ([]Category, error) {
+	body, err := c.GetContext(ctx, BaseURL+"/api_category.php")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Categories []Category `json:"trivia_categories"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse categories: %v", err)
+	}
+	return resp.Categories, nil
+}
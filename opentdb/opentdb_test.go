@@ -0,0 +1,42 @@
+package opentdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	body := []byte(`{"response_code":0,"results":[{"type":"multiple","difficulty":"medium","category":"Entertainment: Books",
+		"question":"Who wrote &quot;The Hobbit&quot;?","correct_answer":"J. R. R. Tolkien",
+		"incorrect_answers":["C. S. Lewis","Terry Pratchett","Ursula K. Le Guin"]},
+		{"type":"boolean","difficulty":"easy","category":"Science &amp; Nature",
+		"question":"The Sun&#039;s core is hotter than its surface.","correct_answer":"True","incorrect_answers":["False"]}]}`)
+	got, err := Decode(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Question{
+		{Type: "multiple", Difficulty: "medium", Category: "Entertainment: Books", Question: `Who wrote "The Hobbit"?`,
+			CorrectAnswer: "J. R. R. Tolkien", IncorrectAnswers: []string{"C. S. Lewis", "Terry Pratchett", "Ursula K. Le Guin"}},
+		{Type: "boolean", Difficulty: "easy", Category: "Science & Nature", Question: "The Sun's core is hotter than its surface.",
+			CorrectAnswer: "True", IncorrectAnswers: []string{"False"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+
+	for code, want := range map[int]error{1: ErrTooFew, 4: ErrExhausted, 5: ErrRateLimited} {
+		body := []byte(`{"response_code":` + string(rune('0'+code)) + `,"results":[]}`)
+		if _, err := Decode(body); !errors.Is(err, want) {
+			t.Errorf("response code %d: err = %v, want %v", code, err, want)
+		}
+	}
+}
+
+func TestQueryURL(t *testing.T) {
+	got := Query{Amount: 80, Category: 9, Difficulty: "hard", Token: "abc"}.URL()
+	if want := BaseURL + "/api.php?amount=50&category=9&difficulty=hard&token=abc"; got != want {
+		t.Errorf("URL = %s, want %s", got, want)
+	}
+}
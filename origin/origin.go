@@ -0,0 +1,120 @@
+// Package origin classifies where a quote comes from: a book, a speech, a
+// film, a song, or nowhere in particular, as with proverbs and anonymous
+// sayings. The sources mix all of these, so the API and the exports filter
+// on it.
+package origin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Type is the kind of work a quote comes from
+type Type string
+
+const (
+	Book      Type = "book"
+	Speech    Type = "speech"
+	Film      Type = "film"
+	Song      Type = "song"
+	Anonymous Type = "anonymous" // proverbs and sayings without a known author
+	Unknown   Type = "unknown"   // classified, but none of the above could be told
+)
+
+// Types lists the types in the order the help texts show them
+var Types = []Type{Book, Speech, Film, Song, Anonymous, Unknown}
+
+// aliases are the other names Parse accepts
+var aliases = map[string]Type{
+	"proverb": Anonymous,
+	"saying":  Anonymous,
+	"movie":   Film,
+	"lyrics":  Song,
+}
+
+// Parse reads a type by name, case-insensitively
+func Parse(s string) (Type, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, t := range Types {
+		if s == string(t) {
+			return t, nil
+		}
+	}
+	if t, ok := aliases[s]; ok {
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown origin %q (origins: %s)", s, List())
+}
+
+// List returns the type names joined for a help text
+func List() string {
+	names := make([]string, len(Types))
+	for i, t := range Types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, ", ")
+}
+
+// SourceHints are the origins of the quotes of sites that only collect one
+// kind, used when a quote's own attribution says nothing else. 1000kitap and
+// fraseslibros list quotes from books, and Kindle highlights are from them.
+var SourceHints = map[string]Type{
+	"1000kitap":    Book,
+	"fraseslibros": Book,
+	"kindle":       Book,
+}
+
+// Hints are what is known about a quote
+type Hints struct {
+	Text   string
+	Author string
+	Book   string // the work it is attributed to, whatever its kind
+	Lang   string // of the text; "" to look it up in every proverb table
+	Source string // name of the site it was collected from, e.g. 1000kitap
+}
+
+// proverbAuthorRe matches attributions such as "Chinese proverb",
+// "Türk atasözü" or "Proverbio árabe"
+var proverbAuthorRe = words(`proverb|saying|atasözü|atasozu|proverbio|refrán|refran|dicho`)
+
+// workRules tell a work's kind from markers in its title or attribution,
+// such as "The Godfather (film)" or "I Have a Dream speech"
+var workRules = []struct {
+	re  *regexp.Regexp
+	typ Type
+}{
+	{words(`speech|lecture|sermon|konuşma(sı)?|nutuk|söylev|discurso`), Speech},
+	{words(`film|movie|filmi|película|pelicula|screenplay|senaryo`), Film},
+	{words(`song|lyrics|şarkı(sı)?|sarki(si)?|canción|cancion|album|albüm`), Song},
+}
+
+// words matches any of the alternatives as a whole word, case-insensitively.
+// \b only knows ASCII letters, so it would not end a word at ü or ı.
+func words(alternatives string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(?:` + alternatives + `)(?:$|[^\p{L}\p{N}])`)
+}
+
+// Classify returns the origin the hints point to. In order: known proverbs,
+// quotes without an author and those credited to a proverb or "Anonymous"
+// are Anonymous; markers in the book or author make it a Speech, Film or
+// Song; any other book makes it a Book; then the source's hint applies.
+// Unknown is left.
+func Classify(h Hints) Type {
+	author := strings.TrimSpace(h.Author)
+	if author == "" || IsAnonymousName(author) || IsProverb(h.Text, h.Lang) {
+		return Anonymous
+	}
+	for _, r := range workRules {
+		if r.re.MatchString(h.Book) || r.re.MatchString(author) {
+			return r.typ
+		}
+	}
+	if strings.TrimSpace(h.Book) != "" {
+		return Book
+	}
+	if t, ok := SourceHints[h.Source]; ok {
+		return t
+	}
+	return Unknown
+}
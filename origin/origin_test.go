@@ -0,0 +1,62 @@
+package origin
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		hints Hints
+		want  Type
+	}{
+		{Hints{Author: "Sally Rooney", Book: "Normal İnsanlar"}, Book},
+		{Hints{Author: "Sally Rooney", Source: "1000kitap"}, Book},
+		{Hints{Author: "Anonim"}, Anonymous},
+		{Hints{Author: "Türk Atasözü", Source: "1000kitap"}, Anonymous},
+		{Hints{Author: "Chinese proverb"}, Anonymous},
+		{Hints{Text: "Damlaya damlaya göl olur."}, Anonymous},
+		{Hints{Author: "Martin Luther King", Book: "I Have a Dream speech"}, Speech},
+		{Hints{Author: "Atatürk", Book: "Gençliğe Hitabe (Nutuk)"}, Speech},
+		{Hints{Author: "Vito Corleone", Book: "The Godfather (film)"}, Film},
+		{Hints{Author: "John Lennon", Book: "Imagine şarkısı"}, Song},
+		{Hints{Author: "Bob Dylan", Book: "Songs of Innocence"}, Book}, // not the word song
+		{Hints{Author: "Oscar Wilde"}, Unknown},
+	}
+	for _, c := range cases {
+		if got := Classify(c.hints); got != c.want {
+			t.Errorf("Classify(%+v) = %s, want %s", c.hints, got, c.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Type{"book": Book, " Film ": Film, "proverb": Anonymous, "movie": Film} {
+		if got, err := Parse(in); err != nil || got != want {
+			t.Errorf("Parse(%q) = %s, %v, want %s", in, got, err, want)
+		}
+	}
+	if _, err := Parse("poem"); err == nil {
+		t.Error("Parse accepted poem")
+	}
+}
+
+func TestProverbTables(t *testing.T) {
+	for _, name := range []string{"Anonymous", "anonim", "Anónimo", " Atasözü "} {
+		if !IsAnonymousName(name) {
+			t.Errorf("IsAnonymousName(%q) = false", name)
+		}
+	}
+	if IsAnonymousName("Amos Oz") {
+		t.Errorf("IsAnonymousName(Amos Oz) = true")
+	}
+	if !IsProverb("Ağaç yaşken eğilir", "tr") || !IsProverb("ağaç yaşken eğilir.", "") {
+		t.Errorf("a Turkish proverb was not found")
+	}
+	if IsProverb("Ağaç yaşken eğilir.", "es") {
+		t.Errorf("a Turkish proverb was found in the Spanish table")
+	}
+	if got := Credit("tr"); got != "Anonim" {
+		t.Errorf("Credit(tr) = %q", got)
+	}
+	if got := Credit("xx"); got != "Anonymous" {
+		t.Errorf("Credit(xx) = %q", got)
+	}
+}
@@ -0,0 +1,87 @@
+package origin
+
+import (
+	"bufio"
+	"embed"
+	"path"
+	"strings"
+
+	"quotesparser/dedup"
+)
+
+// anonymousNames are, per language, the names sources credit proverbs and
+// unattributed quotes to. The first is how quotes without an author are
+// credited when shown.
+var anonymousNames = map[string][]string{
+	"en": {"Anonymous", "Anon", "Unknown", "Proverb", "Saying"},
+	"tr": {"Anonim", "Atasözü", "Atasozu", "Bilinmiyor", "Özdeyiş"},
+	"es": {"Anónimo", "Anonimo", "Refrán", "Refran", "Proverbio", "Desconocido"},
+}
+
+// anonymous holds every name of anonymousNames, lowercased
+var anonymous = make(map[string]bool)
+
+//go:embed proverbs/*.txt
+var proverbFiles embed.FS
+
+// proverbs are, per language, the texts of proverbs/<lang>.txt in
+// dedup.FuzzyText form
+var proverbs = make(map[string]map[string]bool)
+
+func init() {
+	for _, names := range anonymousNames {
+		for _, name := range names {
+			anonymous[strings.ToLower(name)] = true
+		}
+	}
+
+	files, _ := proverbFiles.ReadDir("proverbs")
+	for _, f := range files {
+		lang := strings.TrimSuffix(f.Name(), ".txt")
+		file, err := proverbFiles.Open(path.Join("proverbs", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		proverbs[lang] = make(map[string]bool)
+		lines := bufio.NewScanner(file)
+		for lines.Scan() {
+			line := strings.TrimSpace(lines.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				proverbs[lang][dedup.FuzzyText(line)] = true
+			}
+		}
+		file.Close()
+	}
+}
+
+// IsAnonymousName reports whether a source crediting a quote to name means
+// nobody in particular: "Anonim", "Refrán", or attributions such as
+// "Chinese proverb"
+func IsAnonymousName(name string) bool {
+	name = strings.TrimSpace(name)
+	return anonymous[strings.ToLower(name)] || proverbAuthorRe.MatchString(name)
+}
+
+// IsProverb reports whether text is in the proverb table of lang, or of any
+// language when lang is ""
+func IsProverb(text, lang string) bool {
+	fuzzy := dedup.FuzzyText(text)
+	if lang != "" {
+		return proverbs[lang][fuzzy]
+	}
+	for _, table := range proverbs {
+		if table[fuzzy] {
+			return true
+		}
+	}
+	return false
+}
+
+// Credit returns how to credit a quote without an author in lang, e.g.
+// "Anonim" for Turkish, falling back on English
+func Credit(lang string) string {
+	if names, ok := anonymousNames[lang]; ok {
+		return names[0]
+	}
+	return anonymousNames["en"][0]
+}
@@ -0,0 +1,244 @@
+// Package palette extracts the dominant colors of cached covers and portraits
+// and turns them into a theme for quote cards, so a card matches its book.
+package palette
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+)
+
+// DefaultSize is how many colors Extract is asked for by quotes covers and
+// quotes portraits
+const DefaultSize = 5
+
+// Palette is an image's dominant colors, most common first
+type Palette []color.RGBA
+
+// bucket is a histogram cell: the colors whose top 5 bits per channel agree
+type bucket struct {
+	r, g, b uint64 // sums, for the average
+	n       uint64
+	key     [3]uint8 // 5 bit channels, what median cut splits on
+}
+
+// Extract returns up to n dominant colors of img by median cut: the colors
+// are binned, then the box of bins with the widest channel range is split at
+// its median until there are n boxes. Each box gives its average color.
+func Extract(img image.Image, n int) Palette {
+	bins := make(map[[3]uint8]*bucket)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			if c.A < 128 {
+				continue
+			}
+			key := [3]uint8{c.R >> 3, c.G >> 3, c.B >> 3}
+			bin := bins[key]
+			if bin == nil {
+				bin = &bucket{key: key}
+				bins[key] = bin
+			}
+			bin.r += uint64(c.R)
+			bin.g += uint64(c.G)
+			bin.b += uint64(c.B)
+			bin.n++
+		}
+	}
+	if len(bins) == 0 || n <= 0 {
+		return nil
+	}
+
+	all := make([]*bucket, 0, len(bins))
+	for _, bin := range bins {
+		all = append(all, bin)
+	}
+	// Map order is random; sort so the same image gives the same palette
+	slices.SortFunc(all, func(a, b *bucket) int {
+		return int(uint32(a.key[0])<<10|uint32(a.key[1])<<5|uint32(a.key[2])) -
+			int(uint32(b.key[0])<<10|uint32(b.key[1])<<5|uint32(b.key[2]))
+	})
+
+	boxes := [][]*bucket{all}
+	for len(boxes) < n {
+		i, channel, width := -1, 0, 0
+		for j, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if c, w := widest(box); w > width {
+				i, channel, width = j, c, w
+			}
+		}
+		if i < 0 {
+			break // every box is one bin
+		}
+		box := boxes[i]
+		slices.SortStableFunc(box, func(a, b *bucket) int { return int(a.key[channel]) - int(b.key[channel]) })
+		cut := median(box)
+		boxes[i] = box[:cut]
+		boxes = append(boxes, box[cut:])
+	}
+
+	type swatch struct {
+		c color.RGBA
+		n uint64
+	}
+	swatches := make([]swatch, len(boxes))
+	for i, box := range boxes {
+		var r, g, bl, total uint64
+		for _, bin := range box {
+			r, g, bl, total = r+bin.r, g+bin.g, bl+bin.b, total+bin.n
+		}
+		swatches[i] = swatch{color.RGBA{uint8(r / total), uint8(g / total), uint8(bl / total), 255}, total}
+	}
+	slices.SortStableFunc(swatches, func(a, b swatch) int {
+		switch {
+		case a.n > b.n:
+			return -1
+		case a.n < b.n:
+			return 1
+		}
+		return 0
+	})
+
+	p := make(Palette, len(swatches))
+	for i, s := range swatches {
+		p[i] = s.c
+	}
+	return p
+}
+
+// widest returns the channel whose bins spread furthest in box, and how far
+func widest(box []*bucket) (channel, width int) {
+	for c := 0; c < 3; c++ {
+		lo, hi := 255, 0
+		for _, bin := range box {
+			lo, hi = min(lo, int(bin.key[c])), max(hi, int(bin.key[c]))
+		}
+		if hi-lo > width {
+			channel, width = c, hi-lo
+		}
+	}
+	return channel, width
+}
+
+// median returns where to cut a sorted box so both halves hold about as many
+// pixels, leaving at least one bin on each side
+func median(box []*bucket) int {
+	var total uint64
+	for _, bin := range box {
+		total += bin.n
+	}
+	var seen uint64
+	for i, bin := range box {
+		seen += bin.n
+		if seen*2 >= total {
+			return min(max(i+1, 1), len(box)-1)
+		}
+	}
+	return len(box) - 1
+}
+
+// String formats the palette as stored in the database: #rrggbb,#rrggbb
+func (p Palette) String() string {
+	hex := make([]string, len(p))
+	for i, c := range p {
+		hex[i] = Hex(c)
+	}
+	return strings.Join(hex, ",")
+}
+
+// Parse reads a palette formatted by String
+func Parse(s string) (Palette, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var p Palette
+	for _, part := range strings.Split(s, ",") {
+		var c color.RGBA
+		if _, err := fmt.Sscanf(strings.TrimSpace(part), "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+			return nil, fmt.Errorf("bad palette color %q", part)
+		}
+		c.A = 255
+		p = append(p, c)
+	}
+	return p, nil
+}
+
+// Hex formats c as #rrggbb
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Theme colors a quote card
+type Theme struct {
+	Background color.RGBA
+	Text       color.RGBA // black or white, whichever reads better on Background
+	Accent     color.RGBA // for the attribution and decorations
+}
+
+var (
+	black = color.RGBA{20, 20, 20, 255}
+	white = color.RGBA{250, 250, 250, 255}
+)
+
+// Theme puts the dominant color behind the text and picks the most colorful
+// of the others that stands out from it as the accent, falling back to the
+// text color. An empty palette gives the plain theme.
+func (p Palette) Theme() Theme {
+	if len(p) == 0 {
+		return Theme{Background: color.RGBA{245, 245, 245, 255}, Text: black, Accent: color.RGBA{100, 100, 100, 255}}
+	}
+	t := Theme{Background: p[0], Text: black}
+	if Contrast(white, p[0]) > Contrast(black, p[0]) {
+		t.Text = white
+	}
+
+	t.Accent = t.Text
+	best := -1.0
+	for _, c := range p[1:] {
+		// 3:1 is WCAG's minimum for large text
+		if Contrast(c, p[0]) < 3 {
+			continue
+		}
+		if s := saturation(c); s > best {
+			t.Accent, best = c, s
+		}
+	}
+	return t
+}
+
+// Contrast is the WCAG contrast ratio of two colors, from 1 to 21
+func Contrast(a, b color.RGBA) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// luminance is the WCAG relative luminance of c
+func luminance(c color.RGBA) float64 {
+	channel := func(v uint8) float64 {
+		s := float64(v) / 255
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(c.R) + 0.7152*channel(c.G) + 0.0722*channel(c.B)
+}
+
+// saturation is the HSV saturation of c
+func saturation(c color.RGBA) float64 {
+	hi := max(c.R, c.G, c.B)
+	if hi == 0 {
+		return 0
+	}
+	return float64(hi-min(c.R, c.G, c.B)) / float64(hi)
+}
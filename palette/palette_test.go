@@ -0,0 +1,56 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	// A navy cover with a yellow title band and a little white
+	navy := color.RGBA{20, 30, 90, 255}
+	yellow := color.RGBA{240, 200, 40, 255}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			c := navy
+			switch {
+			case y >= 40 && y < 65:
+				c = yellow
+			case y >= 90:
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	p := Extract(img, DefaultSize)
+	if len(p) != 3 {
+		t.Fatalf("palette = %v, want the three colors", p)
+	}
+	if p[0] != navy || p[1] != yellow {
+		t.Errorf("palette = %v, want navy then yellow", p)
+	}
+
+	theme := p.Theme()
+	if theme.Background != navy || theme.Text != white || theme.Accent != yellow {
+		t.Errorf("theme = %+v, want white text and a yellow accent on navy", theme)
+	}
+
+	back, err := Parse(p.String())
+	if err != nil || back.String() != p.String() {
+		t.Errorf("Parse(%q) = %v, %v", p.String(), back, err)
+	}
+}
+
+func TestThemeContrast(t *testing.T) {
+	// Nothing stands out from pale grey, so the accent is the text color
+	p := Palette{{230, 230, 230, 255}, {210, 210, 215, 255}}
+	theme := p.Theme()
+	if theme.Text != black || theme.Accent != black {
+		t.Errorf("theme = %+v, want black text and accent", theme)
+	}
+	if c := Contrast(black, white); c < 17 || c > 21 {
+		t.Errorf("Contrast(black, white) = %.1f", c)
+	}
+}
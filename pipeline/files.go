@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Parsed is what parsing one file found
+type Parsed[T any] struct {
+	File  string
+	Items []T
+	Err   error
+}
+
+// ParseFiles parses files on workers goroutines, as many as GOMAXPROCS when
+// workers is 0, and returns what each gave in the order of files, so that
+// merging them gives the same result as parsing them one at a time
+func ParseFiles[T any](files []string, workers int, parse func(file string) ([]T, error)) []Parsed[T] {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	results := make([]Parsed[T], len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				items, err := parse(files[i])
+				results[i] = Parsed[T]{File: files[i], Items: items, Err: err}
+			}
+		}()
+	}
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
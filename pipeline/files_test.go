@@ -0,0 +1,43 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseFilesKeepsOrder(t *testing.T) {
+	var files []string
+	for i := 0; i < 50; i++ {
+		files = append(files, strconv.Itoa(i))
+	}
+	parse := func(file string) ([]string, error) {
+		n, _ := strconv.Atoi(file)
+		// Later files finish first
+		time.Sleep(time.Duration(50-n) * 100 * time.Microsecond)
+		if n%7 == 0 {
+			return nil, errors.New("bad page")
+		}
+		return []string{file + "a", file + "b"}, nil
+	}
+
+	for _, workers := range []int{0, 1, 4, 100} {
+		parsed := ParseFiles(files, workers, parse)
+		if len(parsed) != len(files) {
+			t.Fatalf("%d workers: %d results for %d files", workers, len(parsed), len(files))
+		}
+		for i, p := range parsed {
+			if p.File != files[i] || (i%7 == 0) != (p.Err != nil) {
+				t.Errorf("%d workers: result %d = %+v", workers, i, p)
+			}
+			if p.Err == nil && fmt.Sprint(p.Items) != fmt.Sprintf("[%da %db]", i, i) {
+				t.Errorf("%d workers: items of %s = %v", workers, p.File, p.Items)
+			}
+		}
+	}
+	if parsed := ParseFiles(nil, 0, parse); len(parsed) != 0 {
+		t.Errorf("no files parsed to %v", parsed)
+	}
+}
@@ -0,0 +1,250 @@
+// Package pipeline runs fetch → parse → insert crawls with a bounded amount
+// of work in memory at any time.
+package pipeline
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Page is a downloaded page waiting to be parsed
+type Page struct {
+	URL  string
+	Body []byte
+}
+
+// Config bounds the memory a crawl may use. Zero values get defaults sized
+// for a Raspberry Pi.
+type Config struct {
+	Fetchers    int           // concurrent downloads
+	MaxPages    int           // downloaded pages held in memory waiting for the parser
+	MaxRows     int           // parsed rows held in memory waiting for the insert stage
+	BatchSize   int           // rows per insert call
+	Delay       time.Duration // pause between requests of each fetcher
+	LogInterval time.Duration // how often queue depths are logged (0 disables)
+}
+
+func (c *Config) setDefaults() {
+	if c.Fetchers <= 0 {
+		c.Fetchers = 2
+	}
+	if c.MaxPages <= 0 {
+		c.MaxPages = 8
+	}
+	if c.MaxRows <= 0 {
+		c.MaxRows = 1000
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.BatchSize > c.MaxRows {
+		c.BatchSize = c.MaxRows
+	}
+}
+
+// Stats counts what went through each stage
+type Stats struct {
+	Fetched   int64
+	Failed    int64
+	Parsed    int64
+	Inserted  int64
+	Throttled int64 // times a fetcher waited because the insert stage lagged
+}
+
+// metrics exposes the live queue depths at /debug/vars when a server is running
+var metrics = expvar.NewMap("pipeline")
+
+// Run downloads every URL, parses the pages and hands the rows to insert in
+// batches. Channels between the stages are bounded, so a slow insert stage
+// blocks the parser, which blocks the fetchers; fetchers additionally back off
+// while the row queue is more than three quarters full. Fetch and parse errors
+// are logged and counted, an insert error stops the run. Cancelling ctx
+// stops feeding URLs: the pages being fetched are still parsed and inserted
+// before Run returns ctx's error, so an interrupted crawl loses nothing it
+// downloaded.
+func Run[T any](ctx context.Context, cfg Config, urls []string, fetch func(url string) ([]byte, error), parse func(Page) ([]T, error), insert func([]T) error) (Stats, error) {
+	cfg.setDefaults()
+	// ctx aborts the stages when an insert fails; parent only stops the feed
+	parent := ctx
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	var stats Stats
+	todo := make(chan string)
+	pages := make(chan Page, cfg.MaxPages)
+	rows := make(chan T, cfg.MaxRows)
+
+	// Report queue depths while the crawl runs
+	monitorDone := make(chan struct{})
+	go func() {
+		defer close(monitorDone)
+		var tick <-chan time.Time
+		if cfg.LogInterval > 0 {
+			ticker := time.NewTicker(cfg.LogInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		publish := time.NewTicker(time.Second)
+		defer publish.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-publish.C:
+				setMetrics(len(pages), len(rows), &stats)
+			case <-tick:
+				slog.Info("queues", "pages", len(pages), "maxPages", cfg.MaxPages, "rows", len(rows), "maxRows", cfg.MaxRows,
+					"fetched", atomic.LoadInt64(&stats.Fetched), "parsed", atomic.LoadInt64(&stats.Parsed), "inserted", atomic.LoadInt64(&stats.Inserted))
+			}
+		}
+	}()
+
+	// Feed URLs
+	go func() {
+		defer close(todo)
+		for _, u := range urls {
+			if parent.Err() != nil {
+				return
+			}
+			select {
+			case todo <- u:
+			case <-ctx.Done():
+				return
+			case <-parent.Done():
+				return
+			}
+		}
+	}()
+
+	// Fetch stage
+	var fetchers sync.WaitGroup
+	for i := 0; i < cfg.Fetchers; i++ {
+		fetchers.Add(1)
+		go func() {
+			defer fetchers.Done()
+			for u := range todo {
+				// Slow down while the insert stage is behind
+				for len(rows) > cfg.MaxRows*3/4 {
+					atomic.AddInt64(&stats.Throttled, 1)
+					select {
+					case <-time.After(200 * time.Millisecond):
+					case <-ctx.Done():
+						return
+					}
+				}
+
+				body, err := fetch(u)
+				if err != nil {
+					slog.Error("failed to fetch", "url", u, "err", err)
+					atomic.AddInt64(&stats.Failed, 1)
+				} else {
+					atomic.AddInt64(&stats.Fetched, 1)
+					select {
+					case pages <- Page{URL: u, Body: body}:
+					case <-ctx.Done():
+						return
+					}
+				}
+
+				if cfg.Delay > 0 {
+					select {
+					case <-time.After(cfg.Delay):
+					case <-ctx.Done():
+						return
+					case <-parent.Done():
+					}
+				}
+			}
+		}()
+	}
+	var stages sync.WaitGroup
+	stages.Add(2)
+	go func() {
+		defer stages.Done()
+		fetchers.Wait()
+		close(pages)
+	}()
+
+	// Parse stage
+	go func() {
+		defer stages.Done()
+		defer close(rows)
+		for p := range pages {
+			parsed, err := parse(p)
+			if err != nil {
+				slog.Error("failed to parse", "url", p.URL, "err", err)
+				continue
+			}
+			atomic.AddInt64(&stats.Parsed, 1)
+			for _, r := range parsed {
+				select {
+				case rows <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	// Insert stage
+	var insertErr error
+	batch := make([]T, 0, cfg.BatchSize)
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		if err := insert(batch); err != nil {
+			insertErr = fmt.Errorf("insert failed: %w", err)
+			cancel()
+			return false
+		}
+		atomic.AddInt64(&stats.Inserted, int64(len(batch)))
+		batch = batch[:0]
+		return true
+	}
+	for r := range rows {
+		batch = append(batch, r)
+		if len(batch) >= cfg.BatchSize && !flush() {
+			break
+		}
+	}
+	if insertErr == nil {
+		flush()
+	}
+
+	// Let the other stages wind down before reading their counters
+	cancel()
+	stages.Wait()
+	<-monitorDone
+	setMetrics(0, 0, &stats)
+
+	if insertErr == nil && parent.Err() != nil {
+		insertErr = parent.Err()
+	}
+	return stats, insertErr
+}
+
+func setMetrics(pageDepth, rowDepth int, stats *Stats) {
+	depth := new(expvar.Int)
+	depth.Set(int64(pageDepth))
+	metrics.Set("pagesQueued", depth)
+	rowsQueued := new(expvar.Int)
+	rowsQueued.Set(int64(rowDepth))
+	metrics.Set("rowsQueued", rowsQueued)
+	for name, v := range map[string]*int64{
+		"fetched":   &stats.Fetched,
+		"failed":    &stats.Failed,
+		"parsed":    &stats.Parsed,
+		"inserted":  &stats.Inserted,
+		"throttled": &stats.Throttled,
+	} {
+		n := new(expvar.Int)
+		n.Set(atomic.LoadInt64(v))
+		metrics.Set(name, n)
+	}
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// settled fails t when goroutines started since before are still running
+func settled(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines left running:\n%s", runtime.NumGoroutine()-before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func urls(n int) []string {
+	var list []string
+	for i := 0; i < n; i++ {
+		list = append(list, strconv.Itoa(i))
+	}
+	return list
+}
+
+func TestRunBackpressure(t *testing.T) {
+	before := runtime.NumGoroutine()
+	const perPage = 4
+	cfg := Config{Fetchers: 2, MaxPages: 2, MaxRows: 8, BatchSize: 2}
+
+	// What each stage holds: pages fetched but not parsed, rows parsed but
+	// not inserted
+	var pagesHeld, rowsHeld, maxPages, maxRows int64
+	raise := func(max *int64, n int64) {
+		for m := atomic.LoadInt64(max); n > m && !atomic.CompareAndSwapInt64(max, m, n); m = atomic.LoadInt64(max) {
+		}
+	}
+	fetch := func(url string) ([]byte, error) {
+		raise(&maxPages, atomic.AddInt64(&pagesHeld, 1))
+		return []byte(url), nil
+	}
+	parse := func(p Page) ([]string, error) {
+		atomic.AddInt64(&pagesHeld, -1)
+		rows := make([]string, perPage)
+		for i := range rows {
+			rows[i] = string(p.Body)
+		}
+		raise(&maxRows, atomic.AddInt64(&rowsHeld, perPage))
+		return rows, nil
+	}
+	// The insert stage lags far behind the fetchers
+	insert := func(batch []string) error {
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt64(&rowsHeld, -int64(len(batch)))
+		return nil
+	}
+
+	stats, err := Run(context.Background(), cfg, urls(20), fetch, parse, insert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Fetched != 20 || stats.Failed != 0 || stats.Parsed != 20 || stats.Inserted != 20*perPage {
+		t.Errorf("stats = %+v, want 20 pages and %d rows through every stage", stats, 20*perPage)
+	}
+	if stats.Throttled == 0 {
+		t.Error("fetchers never waited for the lagging insert stage")
+	}
+	// The queues, and a page or a batch in the hands of each stage
+	if limit := int64(cfg.MaxPages + cfg.Fetchers + 1); maxPages > limit {
+		t.Errorf("held %d pages at once, want at most %d", maxPages, limit)
+	}
+	if limit := int64(cfg.MaxRows + cfg.BatchSize + perPage); maxRows > limit {
+		t.Errorf("held %d rows at once, want at most %d", maxRows, limit)
+	}
+	settled(t, before)
+}
+
+func TestRunInsertError(t *testing.T) {
+	before := runtime.NumGoroutine()
+	errFull := errors.New("disk full")
+	var fetched, inserts int64
+	fetch := func(url string) ([]byte, error) {
+		atomic.AddInt64(&fetched, 1)
+		return []byte(url), nil
+	}
+	parse := func(p Page) ([]string, error) { return []string{string(p.Body)}, nil }
+	insert := func(batch []string) error {
+		if atomic.AddInt64(&inserts, 1) == 2 {
+			return errFull
+		}
+		return nil
+	}
+
+	stats, err := Run(context.Background(), Config{Fetchers: 1, MaxPages: 1, MaxRows: 2, BatchSize: 2}, urls(1000), fetch, parse, insert)
+	if !errors.Is(err, errFull) {
+		t.Fatalf("Run = %v, want the insert error", err)
+	}
+	if stats.Inserted != 2 || inserts != 2 {
+		t.Errorf("inserted %d rows in %d calls, want the first batch of 2 only", stats.Inserted, inserts)
+	}
+	if n := atomic.LoadInt64(&fetched); n >= 1000 {
+		t.Errorf("fetched all %d pages, want the crawl stopped", n)
+	}
+	settled(t, before)
+}
+
+func TestRunCancelled(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	var fetched int64
+	fetch := func(url string) ([]byte, error) {
+		if atomic.AddInt64(&fetched, 1) == 5 {
+			cancel()
+		}
+		return []byte(url), nil
+	}
+	parse := func(p Page) ([]string, error) { return []string{string(p.Body)}, nil }
+	var inserted int64
+	insert := func(batch []string) error {
+		atomic.AddInt64(&inserted, int64(len(batch)))
+		return nil
+	}
+
+	stats, err := Run(ctx, Config{Fetchers: 1, BatchSize: 3}, urls(1000), fetch, parse, insert)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v, want context.Canceled", err)
+	}
+	// Every page downloaded before the cancel is still saved
+	if stats.Fetched >= 1000 || stats.Inserted != stats.Fetched || inserted != stats.Fetched {
+		t.Errorf("stats = %+v, inserted %d; want the pages fetched all inserted", stats, inserted)
+	}
+	settled(t, before)
+}
@@ -0,0 +1,195 @@
+// Package portraits finds author portraits on Wikipedia, together with the
+// license metadata Wikimedia Commons keeps for each file. quotes portraits
+// caches them with imgcache.
+package portraits
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"quotesparser/fetch"
+)
+
+// DefaultAPI is the MediaWiki API of each language's Wikipedia; %s is the
+// language code
+const DefaultAPI = "https://%s.wikipedia.org/w/api.php"
+
+// DefaultUserAgent identifies the crawler, as the Wikimedia API asks clients to
+const DefaultUserAgent = "quotesparser-portraits/1.0 (author portraits for a quotes database)"
+
+// DefaultSizes are the widths portraits are cached at: list avatars, author
+// pages and share images
+var DefaultSizes = []int{64, 256, 512}
+
+// ErrNotFound is returned when no Wikipedia tried has a freely licensed
+// portrait for an author
+var ErrNotFound = errors.New("no free portrait")
+
+// Portrait is an author's portrait and what its license asks of us
+type Portrait struct {
+	Title          string // Commons file, e.g. File:Amos Oz 2005.jpg
+	Page           string // Wikipedia article the portrait illustrates
+	SourceURL      string // image the cached variants are made from
+	DescriptionURL string // file page with the full license terms
+
+	License             string // short name, e.g. CC BY-SA 4.0
+	LicenseURL          string
+	Artist              string // plain text
+	Credit              string // plain text
+	AttributionRequired bool
+}
+
+// Finder looks authors up on Wikipedia
+type Finder struct {
+	Client *fetch.Client
+	API    string   // MediaWiki api.php address with %s for the language
+	Wikis  []string // languages tried in order, e.g. en, tr, es
+	Width  int      // width of the image downloaded, the largest variant
+}
+
+// NewFinder returns a Finder trying English, Turkish and Spanish Wikipedia,
+// the languages of the quote sources
+func NewFinder() *Finder {
+	c := fetch.NewClient()
+	c.UserAgent = DefaultUserAgent
+	return &Finder{
+		Client: c,
+		API:    DefaultAPI,
+		Wikis:  []string{"en", "tr", "es"},
+		Width:  DefaultSizes[len(DefaultSizes)-1],
+	}
+}
+
+// Find returns the portrait of the article titled name on the first
+// Wikipedia that has a freely licensed one
+func (f *Finder) Find(name string) (Portrait, error) {
+	for _, lang := range f.Wikis {
+		p, err := f.find(lang, name)
+		if err == nil {
+			return p, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return p, err
+		}
+	}
+	return Portrait{}, fmt.Errorf("%w for %s", ErrNotFound, name)
+}
+
+func (f *Finder) find(lang, name string) (Portrait, error) {
+	var pages struct {
+		Query struct {
+			Pages []struct {
+				Title     string            `json:"title"`
+				Missing   bool              `json:"missing"`
+				PageImage string            `json:"pageimage"`
+				PageProps map[string]string `json:"pageprops"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	// pilicense=free leaves out the non-free images enwiki hosts itself
+	err := f.query(lang, url.Values{
+		"titles":    {name},
+		"redirects": {"1"},
+		"prop":      {"pageimages|pageprops"},
+		"piprop":    {"name"},
+		"pilicense": {"free"},
+		"ppprop":    {"disambiguation"},
+	}, &pages)
+	if err != nil {
+		return Portrait{}, err
+	}
+	if len(pages.Query.Pages) == 0 {
+		return Portrait{}, ErrNotFound
+	}
+	page := pages.Query.Pages[0]
+	if _, ambiguous := page.PageProps["disambiguation"]; page.Missing || ambiguous || page.PageImage == "" {
+		return Portrait{}, ErrNotFound
+	}
+
+	var files struct {
+		Query struct {
+			Pages []struct {
+				ImageInfo []struct {
+					URL            string `json:"url"`
+					ThumbURL       string `json:"thumburl"`
+					DescriptionURL string `json:"descriptionurl"`
+					ExtMetadata    map[string]struct {
+						Value interface{} `json:"value"`
+					} `json:"extmetadata"`
+				} `json:"imageinfo"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	title := "File:" + page.PageImage
+	err = f.query(lang, url.Values{
+		"titles":              {title},
+		"prop":                {"imageinfo"},
+		"iiprop":              {"url|extmetadata"},
+		"iiurlwidth":          {fmt.Sprint(f.Width)},
+		"iiextmetadatafilter": {"LicenseShortName|LicenseUrl|Artist|Credit|AttributionRequired"},
+	}, &files)
+	if err != nil {
+		return Portrait{}, err
+	}
+	if len(files.Query.Pages) == 0 || len(files.Query.Pages[0].ImageInfo) == 0 {
+		return Portrait{}, ErrNotFound
+	}
+	info := files.Query.Pages[0].ImageInfo[0]
+	meta := func(key string) string {
+		s, _ := info.ExtMetadata[key].Value.(string)
+		return plainText(s)
+	}
+
+	p := Portrait{
+		Title:               title,
+		Page:                page.Title,
+		SourceURL:           info.ThumbURL,
+		DescriptionURL:      info.DescriptionURL,
+		License:             meta("LicenseShortName"),
+		LicenseURL:          meta("LicenseUrl"),
+		Artist:              meta("Artist"),
+		Credit:              meta("Credit"),
+		AttributionRequired: meta("AttributionRequired") == "true",
+	}
+	// Images narrower than Width come back without a thumbnail
+	if p.SourceURL == "" {
+		p.SourceURL = info.URL
+	}
+	// A portrait we cannot credit properly is no use to us
+	if p.License == "" {
+		return Portrait{}, ErrNotFound
+	}
+	return p, nil
+}
+
+// Download returns the image the portrait's variants are made from
+func (f *Finder) Download(p Portrait) ([]byte, error) {
+	return f.Client.Get(p.SourceURL)
+}
+
+// query runs a MediaWiki query on the lang Wikipedia and decodes the answer
+func (f *Finder) query(lang string, params url.Values, v interface{}) error {
+	params.Set("action", "query")
+	params.Set("format", "json")
+	params.Set("formatversion", "2")
+	body, err := f.Client.Get(fmt.Sprintf(f.API, lang) + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("bad answer from %s Wikipedia: %v", lang, err)
+	}
+	return nil
+}
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// plainText turns the HTML Commons keeps artists and credits in into text
+func plainText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(tagRe.ReplaceAllString(s, " "))), " ")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -11,6 +12,11 @@ import (
 	"strings"
 
 	"golang.org/x/net/html"
+
+	"quotesparser/dedup"
+	"quotesparser/pipeline"
+	"quotesparser/quality"
+	"quotesparser/rejects"
 )
 
 // CyranoQuote represents a quote from Cyrano de Bergerac
@@ -18,7 +24,13 @@ type CyranoQuote struct {
 	Text string `json:"text"`
 }
 
-func parseQuotesFromHTML(htmlContent string) ([]CyranoQuote, error) {
+// legacyFilter is the quality filter of pages without one configured: it
+// keeps anything longer than 20 characters
+var legacyFilter = quality.Filter{MinLength: 21}
+
+// parseQuotesFromHTML extracts the quotes of a page that pass filter,
+// listing in rep the texts of quote spans it rejects
+func parseQuotesFromHTML(htmlContent, filename string, filter quality.Filter, rep *rejects.Report) ([]CyranoQuote, error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %v", err)
@@ -50,17 +62,18 @@ func parseQuotesFromHTML(htmlContent string) ([]CyranoQuote, error) {
 				quoteText = cleanText(quoteText)
 
 				// Normalize for duplicate detection and filtering
-				normalized := strings.ToLower(strings.TrimSpace(quoteText))
-
-				// Filter out headings and short text
-				if normalized != "" &&
-					len(quoteText) > 20 &&
-					!filterWords[normalized] &&
-					!seenTexts[normalized] {
-					seenTexts[normalized] = true
-					quotes = append(quotes, CyranoQuote{
-						Text: quoteText,
-					})
+				normalized := dedup.NormalizeIn(quoteText, "tr")
+
+				// Filter out headings, then what fails the quality checks
+				if normalized != "" && !filterWords[normalized] && !seenTexts[normalized] {
+					if v := filter.Check(quoteText); !v.OK() {
+						rep.Add(rejects.Reject{Kind: rejects.Quote, File: filename, Text: quoteText, Reason: v.Reason()})
+					} else {
+						seenTexts[normalized] = true
+						quotes = append(quotes, CyranoQuote{
+							Text: quoteText,
+						})
+					}
 				}
 			}
 		}
@@ -106,36 +119,42 @@ func cleanText(s string) string {
 	return s
 }
 
-func processAllFiles(folderPath string) ([]CyranoQuote, error) {
+func processAllFiles(folderPath string, filter quality.Filter, rep *rejects.Report) ([]CyranoQuote, error) {
 	var allQuotes []CyranoQuote
 	globalSeen := make(map[string]bool)
 
 	fmt.Printf("Processing files from %s...\n\n", folderPath)
 
+	var files []string
 	for i := 1; i <= 100; i++ {
-		filename := fmt.Sprintf("file%d.txt", i)
-		filePath := filepath.Join(folderPath, filename)
-
+		filePath := filepath.Join(folderPath, fmt.Sprintf("file%d.txt", i))
 		if _, err := os.Stat(filePath); os.IsNotExist(err) {
 			continue
 		}
+		files = append(files, filePath)
+	}
 
+	// Parse on every core; results come back in file order, so the
+	// first occurrence of a quote is kept as before
+	parsed := pipeline.ParseFiles(files, 0, func(filePath string) ([]CyranoQuote, error) {
 		content, err := ioutil.ReadFile(filePath)
 		if err != nil {
-			log.Printf("Error reading %s: %v", filename, err)
-			continue
+			return nil, fmt.Errorf("failed to read: %v", err)
 		}
-
-		quotes, err := parseQuotesFromHTML(string(content))
-		if err != nil {
-			log.Printf("Error parsing %s: %v", filename, err)
+		return parseQuotesFromHTML(string(content), filePath, filter, rep)
+	})
+	for _, p := range parsed {
+		filename := filepath.Base(p.File)
+		if p.Err != nil {
+			log.Printf("Error parsing %s: %v", filename, p.Err)
+			rep.Add(rejects.Reject{Kind: rejects.File, File: p.File, Reason: p.Err.Error()})
 			continue
 		}
 
-		fmt.Printf("File: %s - Found %d quotes\n", filename, len(quotes))
+		fmt.Printf("File: %s - Found %d quotes\n", filename, len(p.Items))
 
-		for _, quote := range quotes {
-			normalized := strings.ToLower(strings.TrimSpace(quote.Text))
+		for _, quote := range p.Items {
+			normalized := dedup.NormalizeIn(quote.Text, "tr")
 			if !globalSeen[normalized] {
 				globalSeen[normalized] = true
 				allQuotes = append(allQuotes, quote)
@@ -147,6 +166,19 @@ func processAllFiles(folderPath string) ([]CyranoQuote, error) {
 }
 
 func main() {
+	reportPath := flag.String("report", "", "JSON file to list the files that cannot be parsed and the quotes filtered out in")
+	qualityPath := flag.String("quality", "", "JSON file of quality filters per source, the 1000kitap one applying; without it quotes need more than 20 characters")
+	flag.Parse()
+
+	filter := legacyFilter
+	if *qualityPath != "" {
+		c, err := quality.Load(*qualityPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		filter = c.For("1000kitap")
+	}
+
 	folderPath := "quoteFiles"
 	outputPath := filepath.Join(folderPath, "output.json")
 
@@ -154,10 +186,20 @@ func main() {
 		log.Fatalf("Folder %s does not exist", folderPath)
 	}
 
-	quotes, err := processAllFiles(folderPath)
+	var rep *rejects.Report
+	if *reportPath != "" {
+		rep = rejects.New(*reportPath, "processCyranoQuotes.go")
+	}
+	quotes, err := processAllFiles(folderPath, filter, rep)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := rep.Save(); err != nil {
+		log.Fatal(err)
+	}
+	if rep != nil {
+		fmt.Printf("Listed %d rejects in %s\n", rep.Len(), *reportPath)
+	}
 
 	fmt.Printf("\nFound %d unique quotes\n", len(quotes))
 
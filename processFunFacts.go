@@ -1,36 +1,89 @@
 package main
 
 import (
+	"crypto/sha256"
 	"database/sql"
-	"encoding/json"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+
+	"quotesparser/dedup"
+	"quotesparser/jsoncheck"
+	"quotesparser/migrations"
+	"quotesparser/rejects"
+	"quotesparser/store"
 )
 
-// FunFact represents the structure of the JSON data
+// FunFact represents the structure of the JSON data: the uselessfacts API
+// answer saved by downloadFunFacts.go. Files saved from random.html before
+// it used the API carry only id and text.
 type FunFact struct {
-	ID   string `json:"id"`
-	Text string `json:"text"`
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Source    string `json:"source"`
+	SourceURL string `json:"source_url"`
+	Language  string `json:"language"`
+	Permalink string `json:"permalink"`
+}
+
+// strict makes the fields a fact does not have errors rather than warnings
+var strict bool
+
+// ingestedFile is a file read by this run, recorded in ingestedFiles once
+// its facts are inserted
+type ingestedFile struct {
+	path string
+	hash string
+	size int
+}
+
+// loadIngested returns the content hash of every file an earlier run read
+func loadIngested(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query("SELECT path, hash FROM ingestedFiles")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ingested files: %v", err)
+	}
+	defer rows.Close()
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var path, hash string
+		if err := rows.Scan(&path, &hash); err != nil {
+			return nil, fmt.Errorf("failed to read ingested files: %v", err)
+		}
+		hashes[path] = hash
+	}
+	return hashes, rows.Err()
 }
 
-func parseFunFactsFromFolder(folderPath string) ([]FunFact, error) {
+// parseFunFactsFromFolder reads the facts of the files in folderPath, skipping
+// those ingested before with the same content, and lists the files it cannot
+// use in rep
+func parseFunFactsFromFolder(folderPath string, ingested map[string]string, rep *rejects.Report) ([]FunFact, []ingestedFile, int, error) {
 	var allFacts []FunFact
+	var read []ingestedFile
+	unchanged := 0
 	seenTexts := make(map[string]bool)
 
-	// Find all .txt files in the folder
-	files, err := filepath.Glob(filepath.Join(folderPath, "*.txt"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to list files: %v", err)
+	// Find all .json files in the folder, and the .txt files of older runs
+	var files []string
+	for _, pattern := range []string{"*.json", "*.txt"} {
+		matches, err := filepath.Glob(filepath.Join(folderPath, pattern))
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed to list files: %v", err)
+		}
+		files = append(files, matches...)
 	}
 
 	if len(files) == 0 {
-		return nil, fmt.Errorf("no .txt files found in %s", folderPath)
+		return nil, nil, 0, fmt.Errorf("no .json or .txt files found in %s", folderPath)
 	}
 
 	fmt.Printf("Processing %d files...\n", len(files))
@@ -39,17 +92,30 @@ func parseFunFactsFromFolder(folderPath string) ([]FunFact, error) {
 		content, err := ioutil.ReadFile(file)
 		if err != nil {
 			log.Printf("Error reading %s: %v", file, err)
+			rep.Add(rejects.Reject{Kind: rejects.FunFactFile, File: file, Reason: err.Error()})
+			continue
+		}
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		if ingested[file] == hash {
+			unchanged++
 			continue
 		}
 
 		var fact FunFact
-		if err := json.Unmarshal(content, &fact); err != nil {
-			log.Printf("Error parsing JSON in %s: %v", file, err)
+		if err := jsoncheck.Unmarshal(file, content, &fact, strict); err != nil {
+			log.Printf("Error parsing %v", err)
+			rep.Add(rejects.Reject{Kind: rejects.FunFactFile, File: file, Reason: err.Error()})
+			continue
+		}
+		read = append(read, ingestedFile{path: file, hash: hash, size: len(content)})
+		if fact.ID == "" || strings.TrimSpace(fact.Text) == "" {
+			rep.Add(rejects.Reject{Kind: rejects.FunFactFile, File: file, Text: fact.Text, Reason: "no id or text"})
 			continue
 		}
 
-		// Normalize text for comparison (trim spaces, lowercase)
-		normalizedText := strings.TrimSpace(strings.ToLower(fact.Text))
+		// Normalize text for comparison, as its hash does
+		normalizedText := dedup.NormalizeIn(fact.Text, fact.Language)
 
 		// Skip duplicates based on text content
 		if normalizedText != "" && !seenTexts[normalizedText] {
@@ -58,61 +124,89 @@ func parseFunFactsFromFolder(folderPath string) ([]FunFact, error) {
 		}
 	}
 
-	return allFacts, nil
+	return allFacts, read, unchanged, nil
 }
 
-func insertIntoDatabase(facts []FunFact, dbPath string) error {
-	// Open database with UTF-8 encoding
-	db, err := sql.Open("sqlite3", dbPath+"?charset=utf8&parseTime=true")
+// openDatabase opens dbPath with UTF-8 encoding and brings its schema up to
+// date
+func openDatabase(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", store.SQLiteDSN(dbPath))
 	if err != nil {
-		return fmt.Errorf("failed to open database: %v", err)
+		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
-	defer db.Close()
 
 	// Set UTF-8 encoding
-	_, err = db.Exec("PRAGMA encoding = 'UTF-8'")
-	if err != nil {
-		return fmt.Errorf("failed to set encoding: %v", err)
+	if _, err := db.Exec("PRAGMA encoding = 'UTF-8'"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set encoding: %v", err)
 	}
 
-	// Drop and recreate table
-	_, err = db.Exec("DROP TABLE IF EXISTS funFacts")
-	if err != nil {
-		return fmt.Errorf("failed to drop table: %v", err)
-	}
-
-	_, err = db.Exec(`
-        CREATE TABLE funFacts (
-            id TEXT PRIMARY KEY,
-            text TEXT NOT NULL
-        )
-    `)
-	if err != nil {
-		return fmt.Errorf("failed to create table: %v", err)
+	// Later runs update the rows in place so viewCount and manual edits
+	// survive; the migrations create the table and its unique text hash
+	if _, err := migrations.Up(db, 0); err != nil {
+		db.Close()
+		return nil, err
 	}
+	return db, nil
+}
 
+// insertIntoDatabase upserts facts and records the files they were read from
+// in the same transaction, so a failed run reads them again
+func insertIntoDatabase(db *sql.DB, facts []FunFact, read []ingestedFile) error {
 	// Begin transaction
 	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %v", err)
 	}
 
-	stmt, err := tx.Prepare("INSERT INTO funFacts (id, text) VALUES (?, ?)")
+	var before int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM funFacts").Scan(&before); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to count fun facts: %v", err)
+	}
+
+	// Facts from older files have no source; keep what a later download
+	// recorded rather than blanking it
+	stmt, err := tx.Prepare(`
+        INSERT INTO funFacts (id, text, textHash, source, sourceUrl, lang, permalink) VALUES (?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(id) DO UPDATE SET text = excluded.text, textHash = excluded.textHash,
+            source = COALESCE(excluded.source, source), sourceUrl = COALESCE(excluded.sourceUrl, sourceUrl),
+            lang = COALESCE(excluded.lang, lang), permalink = COALESCE(excluded.permalink, permalink)
+        ON CONFLICT(textHash) DO NOTHING
+    `)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to prepare statement: %v", err)
 	}
 	defer stmt.Close()
 
-	inserted := 0
+	processed := 0
 	for _, fact := range facts {
 		if fact.ID != "" && fact.Text != "" {
-			_, err = stmt.Exec(fact.ID, fact.Text)
+			_, err = stmt.Exec(fact.ID, fact.Text, dedup.TextHashIn(fact.Text, fact.Language),
+				nullIfEmpty(fact.Source), nullIfEmpty(fact.SourceURL), nullIfEmpty(fact.Language), nullIfEmpty(fact.Permalink))
 			if err != nil {
 				log.Printf("Warning: failed to insert fact %s: %v", fact.ID, err)
 				continue
 			}
-			inserted++
+			processed++
+		}
+	}
+
+	var after int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM funFacts").Scan(&after); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to count fun facts: %v", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, f := range read {
+		_, err = tx.Exec(`INSERT INTO ingestedFiles (path, hash, size, ingestedAt) VALUES (?, ?, ?, ?)
+			ON CONFLICT(path) DO UPDATE SET hash = excluded.hash, size = excluded.size, ingestedAt = excluded.ingestedAt`,
+			f.path, f.hash, f.size, now)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record %s: %v", f.path, err)
 		}
 	}
 
@@ -120,11 +214,22 @@ func insertIntoDatabase(facts []FunFact, dbPath string) error {
 		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	fmt.Printf("✓ Inserted %d fun facts into database.db\n", inserted)
+	inserted := after - before
+	fmt.Printf("✓ Inserted %d new and updated %d existing fun facts in database.db\n", inserted, processed-inserted)
 	return nil
 }
 
+// nullIfEmpty stores a missing field as NULL
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
 func main() {
+	force := flag.Bool("force", false, "read every file again, even those ingested before unchanged")
+	reportPath := flag.String("report", "", "JSON file to list the files that cannot be parsed in")
+	flag.BoolVar(&strict, "strict", false, "reject files with fields a fact does not have, such as misspelled ones, rather than warn")
+	flag.Parse()
+
 	folderPath := "funfacts"
 	dbPath := "database.db"
 
@@ -133,16 +238,46 @@ func main() {
 		log.Fatalf("Folder %s does not exist", folderPath)
 	}
 
+	db, err := openDatabase(dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	ingested := map[string]string{}
+	if !*force {
+		if ingested, err = loadIngested(db); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// Parse all fun facts (with duplicate removal based on text)
-	facts, err := parseFunFactsFromFolder(folderPath)
+	var rep *rejects.Report
+	if *reportPath != "" {
+		rep = rejects.New(*reportPath, "processFunFacts.go")
+	}
+	facts, read, unchanged, err := parseFunFactsFromFolder(folderPath, ingested, rep)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := rep.Save(); err != nil {
+		log.Fatal(err)
+	}
+	if rep != nil {
+		fmt.Printf("Listed %d files that cannot be parsed in %s\n", rep.Len(), *reportPath)
+	}
 
+	if unchanged > 0 {
+		fmt.Printf("Skipped %d files ingested before unchanged (--force reads them again)\n", unchanged)
+	}
+	if len(read) == 0 {
+		fmt.Println("✓ Nothing new to ingest")
+		return
+	}
 	fmt.Printf("Found %d unique fun facts (duplicates removed)\n", len(facts))
 
 	// Insert into database
-	if err := insertIntoDatabase(facts, dbPath); err != nil {
+	if err := insertIntoDatabase(db, facts, read); err != nil {
 		log.Fatal(err)
 	}
 
@@ -154,6 +289,10 @@ func main() {
 		if i >= 5 {
 			break
 		}
-		fmt.Printf("%d. [%s] %s\n", i+1, fact.ID[:8], fact.Text)
+		id := fact.ID
+		if len(id) > 8 {
+			id = id[:8]
+		}
+		fmt.Printf("%d. [%s] %s\n", i+1, id, fact.Text)
 	}
 }
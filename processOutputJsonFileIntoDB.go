@@ -1,120 +1,316 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
+
+	"quotesparser/datafile"
+	"quotesparser/dedup"
+	"quotesparser/jsoncheck"
+	"quotesparser/langdetect"
+	"quotesparser/pipeline"
+	"quotesparser/store"
 )
 
-// CyranoQuote represents a quote from the JSON file
-type CyranoQuote struct {
-	Text string `json:"text"`
+// jsonQuote is a quote of the files: the JSON of processCyranoQuotes.go's
+// output.json, or YAML and TOML kept by hand. Only the text is required; the
+// other fields override the metadata of the file.
+type jsonQuote struct {
+	Text   string `json:"text" jsoncheck:"required"`
+	Author string `json:"author"`
+	Book   string `json:"book"`
+	Lang   string `json:"lang"`
 }
 
-func readQuotesFromJSON(filename string) ([]CyranoQuote, error) {
-	content, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read JSON file: %v", err)
-	}
+// fileMeta is who the quotes of a file are by, from which book, in which
+// language and from which source. A sidecar <name>.meta.json next to the
+// file gives it, else the manifest entry the file matched, else the flags;
+// the first of them to set a field wins.
+type fileMeta struct {
+	Author string `json:"author"`
+	Book   string `json:"book"`
+	Lang   string `json:"lang"` // a code, or auto to detect each quote's
+	Source string `json:"source"`
+}
+
+// manifestEntry names the files of some metadata, by path or glob relative
+// to the manifest
+type manifestEntry struct {
+	Files string `json:"files" jsoncheck:"required"`
+	fileMeta
+}
 
-	var quotes []CyranoQuote
-	if err := json.Unmarshal(content, &quotes); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %v", err)
+// strict makes the fields the files do not have errors rather than warnings
+var strict bool
+
+// legacyFile is the file imported when none is given, with the metadata it
+// always had
+const legacyFile = "quoteFiles/output.json"
+
+var legacyMeta = fileMeta{Author: "Sally Rooney", Book: "Normal İnsanlar"}
+
+// or returns m with its empty fields filled from fallback
+func (m fileMeta) or(fallback fileMeta) fileMeta {
+	if m.Author == "" {
+		m.Author = fallback.Author
+	}
+	if m.Book == "" {
+		m.Book = fallback.Book
 	}
+	if m.Lang == "" {
+		m.Lang = fallback.Lang
+	}
+	if m.Source == "" {
+		m.Source = fallback.Source
+	}
+	return m
+}
 
-	return quotes, nil
+// sidecarPath is where the metadata of filename may be: data.json's is
+// data.meta.json
+func sidecarPath(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".meta.json"
 }
 
-func insertQuotesIntoDatabase(quotes []CyranoQuote, dbPath string) error {
-	// Open database with UTF-8 encoding
-	db, err := sql.Open("sqlite3", dbPath+"?charset=utf8&parseTime=true")
+// readMeta reads a sidecar, returning nothing when there is none
+func readMeta(path string) (fileMeta, error) {
+	var m fileMeta
+	content, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to open database: %v", err)
+		return m, fmt.Errorf("failed to read %s: %v", path, err)
 	}
-	defer db.Close()
+	if err := jsoncheck.Unmarshal(path, content, &m, strict); err != nil {
+		return m, err
+	}
+	return m, nil
+}
 
-	// Set UTF-8 encoding
-	_, err = db.Exec("PRAGMA encoding = 'UTF-8'")
+// readManifest reads the files a manifest lists with their metadata
+func readManifest(path string) ([]string, map[string]fileMeta, error) {
+	content, err := ioutil.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to set encoding: %v", err)
+		return nil, nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	var entries []manifestEntry
+	if err := datafile.Unmarshal(path, content, &entries, strict); err != nil {
+		return nil, nil, fmt.Errorf("bad manifest: %v", err)
+	}
+	var files []string
+	metas := make(map[string]fileMeta)
+	for _, e := range entries {
+		pattern := e.Files
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := expandGlob(pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, f := range matches {
+			if _, ok := metas[f]; !ok {
+				files = append(files, f)
+				metas[f] = e.fileMeta
+			}
+		}
 	}
+	return files, metas, nil
+}
 
-	// Begin transaction
-	tx, err := db.Begin()
+// expandGlob returns the files of quotes matching pattern, leaving out
+// sidecars
+func expandGlob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(pattern)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
+		return nil, fmt.Errorf("invalid pattern %s: %v", pattern, err)
+	}
+	var files []string
+	for _, f := range matches {
+		if !strings.HasSuffix(f, ".meta.json") {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match %s", pattern)
 	}
+	return files, nil
+}
 
-	stmt, err := tx.Prepare("INSERT INTO quotes (text, author, lang, viewCount) VALUES (?, ?, ?, ?)")
+// readQuotesFromJSON reads the quotes of a file with the metadata its
+// sidecar, manifest entry or the flags give them
+func readQuotesFromJSON(filename string, meta fileMeta) ([]store.Quote, error) {
+	sidecar, err := readMeta(sidecarPath(filename))
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to prepare statement: %v", err)
+		return nil, err
 	}
-	defer stmt.Close()
+	meta = sidecar.or(meta)
 
-	author := "Sally Rooney - Normal İnsanlar"
-	lang := "tr"
-	viewCount := 0
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON file: %v", err)
+	}
+	var quotes []jsonQuote
+	if err := datafile.Unmarshal(filename, content, &quotes, strict); err != nil {
+		return nil, err
+	}
 
-	inserted := 0
-	for _, quote := range quotes {
-		if quote.Text != "" {
-			_, err = stmt.Exec(quote.Text, author, lang, viewCount)
-			if err != nil {
-				log.Printf("Warning: failed to insert quote: %v", err)
-				continue
-			}
-			inserted++
+	var rows []store.Quote
+	for _, q := range quotes {
+		q.Text = strings.TrimSpace(q.Text)
+		if q.Text == "" {
+			continue
 		}
+		// A quote by another author is not from the file's book either
+		fallback := meta
+		if q.Author != "" {
+			fallback.Book = ""
+		}
+		quoteMeta := fileMeta{Author: q.Author, Book: q.Book, Lang: q.Lang}.or(fallback)
+		// 1000kitap is a Turkish site; detection catches the odd English quote
+		if quoteMeta.Lang == "" || quoteMeta.Lang == "auto" {
+			quoteMeta.Lang = langdetect.DetectOr(q.Text, "tr")
+		}
+		rows = append(rows, store.Quote{Text: q.Text, Author: quoteMeta.Author, Book: quoteMeta.Book, Lang: quoteMeta.Lang, Source: quoteMeta.Source})
 	}
+	return rows, nil
+}
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
+// dedupQuotes keeps the first of the quotes of all files with the same
+// normalized text, in the order of the files, and returns how many of each
+// file's it kept
+func dedupQuotes(parsed []pipeline.Parsed[store.Quote]) ([]store.Quote, []int) {
+	var rows []store.Quote
+	kept := make([]int, len(parsed))
+	seen := make(map[string]bool)
+	for i, p := range parsed {
+		for _, q := range p.Items {
+			hash := dedup.TextHashIn(q.Text, q.Lang)
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			rows = append(rows, q)
+			kept[i]++
+		}
 	}
-
-	fmt.Printf("✓ Inserted %d quotes into database.db\n", inserted)
-	return nil
+	return rows, kept
 }
 
 func main() {
-	jsonFile := "quoteFiles/output.json"
-	dbPath := "database.db"
+	batchSize := flag.Int("batch", store.DefaultBatchSize, "quotes per multi-row INSERT")
+	dbPath := flag.String("db", "database.db", "SQLite file, sqlite:<path>, postgres://... or memory:")
+	manifest := flag.String("manifest", "", `JSON, YAML or TOML list of {"files": path or glob, "author", "book", "lang", "source"} to import`)
+	parsers := flag.Int("parsers", 0, "files read at once; 0 for one per CPU core")
+	var defaults fileMeta
+	flag.StringVar(&defaults.Author, "author", "", "author of the quotes of files without one of their own")
+	flag.StringVar(&defaults.Book, "book", "", "book of the quotes of files without one of their own")
+	flag.StringVar(&defaults.Lang, "lang", "auto", "language of the quotes of files without one of their own: auto detects each one, falling back to tr")
+	flag.StringVar(&defaults.Source, "source", "", "source to record the quotes of files without one of their own under")
+	flag.BoolVar(&strict, "strict", false, "reject files with fields a quote, sidecar or manifest does not have, such as misspelled ones, rather than warn")
+	dryRun := flag.Bool("dry-run", false, "report what would be inserted, updated or skipped without changing the database")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: go run processOutputJsonFileIntoDB.go [flags] [JSON, YAML or TOML files or globs]\n\n"+
+			"Imports %s when given no files. A file's data.meta.json sidecar overrides the manifest and the flags.\n\n", legacyFile)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	// Check if JSON file exists
-	if _, err := os.Stat(jsonFile); os.IsNotExist(err) {
-		log.Fatalf("File %s does not exist", jsonFile)
+	metas := make(map[string]fileMeta)
+	var files []string
+	if *manifest != "" {
+		var err error
+		if files, metas, err = readManifest(*manifest); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, arg := range flag.Args() {
+		matches, err := expandGlob(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, f := range matches {
+			if _, ok := metas[f]; !ok {
+				files = append(files, f)
+				metas[f] = fileMeta{}
+			}
+		}
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(legacyFile); os.IsNotExist(err) {
+			log.Fatalf("File %s does not exist", legacyFile)
+		}
+		files = []string{legacyFile}
+		metas[legacyFile] = legacyMeta
 	}
 
 	// Check if database exists
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		log.Fatalf("Database %s does not exist", dbPath)
+	if !strings.Contains(*dbPath, ":") {
+		if _, err := os.Stat(*dbPath); os.IsNotExist(err) {
+			log.Fatalf("Database %s does not exist", *dbPath)
+		}
 	}
 
-	fmt.Printf("Reading quotes from %s...\n", jsonFile)
+	fmt.Printf("Reading quotes from %d files...\n", len(files))
+	parsed := pipeline.ParseFiles(files, *parsers, func(filename string) ([]store.Quote, error) {
+		return readQuotesFromJSON(filename, metas[filename].or(defaults))
+	})
+	failed := 0
+	for _, p := range parsed {
+		if p.Err != nil {
+			log.Printf("Error reading %s: %v", p.File, p.Err)
+			failed++
+		}
+	}
+	rows, kept := dedupQuotes(parsed)
+	for i, p := range parsed {
+		if p.Err == nil {
+			fmt.Printf("  %s: %d quotes (%d already in an earlier file)\n", p.File, len(p.Items), len(p.Items)-kept[i])
+		}
+	}
+	if failed == len(files) {
+		log.Fatal("no file could be read")
+	}
 
-	// Read quotes from JSON
-	quotes, err := readQuotesFromJSON(jsonFile)
+	s, err := store.OpenWith(*dbPath, store.Options{BatchSize: *batchSize, ReadOnly: *dryRun})
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer s.Close()
+	if *dryRun {
+		if s, err = store.NewPreview(s); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	fmt.Printf("Found %d quotes in JSON file\n", len(quotes))
-
-	// Insert into database
-	if err := insertQuotesIntoDatabase(quotes, dbPath); err != nil {
+	inserted, err := s.SaveQuotes(rows)
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	fmt.Println("✓ Database operations completed successfully")
+	if p, ok := s.(*store.Preview); ok {
+		c, _, _ := p.Changes()
+		fmt.Printf("✓ Dry run, nothing changed in %s\n", *dbPath)
+		fmt.Printf("  %d quotes to insert, %d to update, %d to skip\n", c.Inserted, c.Updated, c.Skipped)
+		return
+	}
+	fmt.Printf("✓ Inserted %d new and updated %d existing quotes in %s\n", inserted, len(rows)-inserted, *dbPath)
+	if failed > 0 {
+		fmt.Printf("✗ %d files could not be read\n", failed)
+	}
 
 	// Show preview
 	fmt.Println("\nPreview (first 3 quotes inserted):")
-	for i, quote := range quotes {
+	for i, quote := range rows {
 		if i >= 3 {
 			break
 		}
@@ -2,35 +2,254 @@ package main
 
 import (
 	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
+
+	"quotesparser/dedup"
+	"quotesparser/media"
+	"quotesparser/migrations"
+	"quotesparser/rejects"
+	"quotesparser/schema"
+	"quotesparser/store"
 )
 
-// TriviaQuestion represents a trivia question with category, question, and answer
+// TriviaQuestion represents a trivia question with category, question, and
+// answer, the wrong answers of its multiple-choice variant, a hint, how hard
+// it is, and the picture or clip of a picture or audio round
 type TriviaQuestion struct {
-	Category string
-	Question string
-	Answer   string
+	Category     string
+	Question     string
+	Answer       string
+	WrongAnswers []string
+	Hint         string
+	Difficulty   string
+	ImageURL     string
+	AudioURL     string
+}
+
+// difficulties are the levels a line may give after the answer
+var difficulties = map[string]bool{"easy": true, "medium": true, "hard": true}
+
+// Formats of a trivia file: CSV, TSV or the legacy one of comma-separated
+// fields in curly brackets
+const (
+	formatAuto  = "auto"
+	formatCSV   = "csv"
+	formatTSV   = "tsv"
+	formatBrace = "brace"
+)
+
+// detectFormat picks the format of a trivia file by its extension, falling
+// back to the legacy brace format
+func detectFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return formatCSV
+	case ".tsv", ".tab":
+		return formatTSV
+	}
+	return formatBrace
 }
 
-func readTriviaFromFile(filename string) ([]TriviaQuestion, error) {
+// readTriviaFromFile reads the questions of a trivia file in format, one per
+// record of category, question and answer followed by optional fields,
+// listing the records it skips in rep
+func readTriviaFromFile(filename, format string, rep *rejects.Report) ([]TriviaQuestion, error) {
 	content, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %v", err)
 	}
+	if format == formatAuto {
+		format = detectFormat(filename)
+	}
+
+	var records [][]string
+	var lines []int
+	switch format {
+	case formatCSV, formatTSV:
+		records, lines, err = readDelimited(string(content), format)
+		if err != nil {
+			return nil, err
+		}
+	case formatBrace:
+		records, lines = readBraced(string(content))
+	default:
+		return nil, fmt.Errorf("unknown format %q, want auto, csv, tsv or brace", format)
+	}
 
 	var trivia []TriviaQuestion
-	seen := make(map[string]bool)
+	seen := make(map[string]int) // line of each question
+	var header []string
+	skip := func(line int, parts []string, reason string) {
+		log.Printf("Skipping line %d: %s", line, reason)
+		rep.Add(rejects.Reject{Kind: rejects.TriviaLine, File: filename, Line: line, Text: strings.Join(parts, ","), Reason: reason})
+	}
+
+	for i, parts := range records {
+		// Expect 3 parts: category, question, answer, then optionally the
+		// difficulty, a hint, wrong answers and the addresses of an image
+		// and an audio clip; a header row names them instead
+		if len(parts) < 3 {
+			skip(lines[i], parts, fmt.Sprintf("not enough columns (%d)", len(parts)))
+			continue
+		}
+		if i == 0 && isTriviaHeader(parts) {
+			header = parts
+			continue
+		}
+
+		var q TriviaQuestion
+		if header != nil {
+			q = namedTrivia(header, parts, lines[i])
+		} else {
+			q = positionalTrivia(parts, lines[i])
+		}
+
+		// Skip empty entries
+		if q.Category == "" || q.Question == "" || q.Answer == "" {
+			skip(lines[i], parts, "no category, question or answer")
+			continue
+		}
 
-	// Split by newlines
-	lines := strings.Split(string(content), "\n")
+		// Remove duplicates based on question text
+		key := dedup.Key(q.Question)
+		if first, ok := seen[key]; ok {
+			rep.Add(rejects.Reject{Kind: rejects.TriviaLine, File: filename, Line: lines[i], Text: strings.Join(parts, ","), Reason: fmt.Sprintf("repeats the question of line %d", first)})
+			continue
+		}
+		seen[key] = lines[i]
+		trivia = append(trivia, q)
+	}
+
+	return trivia, nil
+}
 
-	for lineNum, line := range lines {
+// positionalTrivia reads a record without a header: category, question and
+// answer, then fields told apart by their content. A difficulty is easy,
+// medium or hard, a hint starts with "hint:", http(s) addresses are media by
+// their extension, and anything else is a wrong answer.
+func positionalTrivia(parts []string, line int) TriviaQuestion {
+	q := TriviaQuestion{
+		Category: strings.TrimSpace(parts[0]),
+		Question: strings.TrimSpace(parts[1]),
+		Answer:   strings.TrimSpace(parts[2]),
+	}
+	for _, part := range parts[3:] {
+		addField(&q, strings.TrimSpace(part), line)
+	}
+	return q
+}
+
+// addField adds an optional field of a record without a header to q
+func addField(q *TriviaQuestion, field string, line int) {
+	switch {
+	case field == "":
+	case difficulties[strings.ToLower(field)]:
+		q.Difficulty = strings.ToLower(field)
+	case len(field) > 5 && strings.EqualFold(field[:5], "hint:"):
+		q.Hint = strings.TrimSpace(field[5:])
+	case media.IsURL(field):
+		setMedia(q, field)
+	case strings.Contains(field, "://"):
+		log.Printf("Line %d: ignoring media %q: not an http(s) address", line, field)
+	default:
+		q.WrongAnswers = append(q.WrongAnswers, field)
+	}
+}
+
+// namedTrivia reads a record by the column names of the header: category,
+// question, answer, difficulty, hint, image and audio, with every column
+// whose name starts with wrong, incorrect or option a wrong answer. Columns
+// past the header are told apart by content as in positionalTrivia.
+func namedTrivia(header, parts []string, line int) TriviaQuestion {
+	var q TriviaQuestion
+	for i, part := range parts {
+		field := strings.TrimSpace(part)
+		if i >= len(header) {
+			addField(&q, field, line)
+			continue
+		}
+		if field == "" {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(header[i]))
+		switch {
+		case name == "category":
+			q.Category = field
+		case name == "question":
+			q.Question = field
+		case name == "answer":
+			q.Answer = field
+		case name == "difficulty":
+			if difficulties[strings.ToLower(field)] {
+				q.Difficulty = strings.ToLower(field)
+			}
+		case name == "hint":
+			q.Hint = field
+		case strings.HasPrefix(name, "image") || strings.HasPrefix(name, "audio") || name == "media":
+			if media.IsURL(field) {
+				setMedia(&q, field)
+			} else {
+				log.Printf("Line %d: ignoring media %q: not an http(s) address", line, field)
+			}
+		case strings.HasPrefix(name, "wrong") || strings.HasPrefix(name, "incorrect") || strings.HasPrefix(name, "option"):
+			q.WrongAnswers = append(q.WrongAnswers, field)
+		}
+	}
+	return q
+}
+
+// setMedia keeps address as the image or audio clip of q, by its extension
+func setMedia(q *TriviaQuestion, address string) {
+	if media.KindOf(address) == media.Audio {
+		q.AudioURL = address
+	} else {
+		q.ImageURL = address
+	}
+}
+
+// readDelimited splits CSV or TSV content into records, with quoted fields
+// that may hold the delimiter, quotes and newlines, and the line each starts on
+func readDelimited(content, format string) ([][]string, []int, error) {
+	r := csv.NewReader(strings.NewReader(strings.TrimPrefix(content, "\ufeff")))
+	if format == formatTSV {
+		r.Comma = '\t'
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var records [][]string
+	var lines []int
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, lines, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %v", format, err)
+		}
+		line, _ := r.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+}
+
+// readBraced splits the legacy format into records: a line per question with
+// its fields separated by commas outside curly brackets, the brackets removed;
+// it returns the line of each record with them
+func readBraced(content string) ([][]string, []int) {
+	var records [][]string
+	var lines []int
+	for n, line := range strings.Split(content, "\n") {
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
@@ -58,40 +277,27 @@ func readTriviaFromFile(filename string) ([]TriviaQuestion, error) {
 			parts = append(parts, current.String())
 		}
 
-		// Expect 3 parts: category, question, answer
-		if len(parts) < 3 {
-			log.Printf("Skipping line %d: not enough columns (%d)", lineNum+1, len(parts))
-			continue
-		}
-
-		// Remove curly brackets and trim spaces
-		category := strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(parts[0], "{", ""), "}", ""))
-		question := strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(parts[1], "{", ""), "}", ""))
-		answer := strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(parts[2], "{", ""), "}", ""))
-
-		// Skip empty entries
-		if category == "" || question == "" || answer == "" {
-			continue
-		}
-
-		// Remove duplicates based on question text
-		key := strings.ToLower(question)
-		if !seen[key] {
-			seen[key] = true
-			trivia = append(trivia, TriviaQuestion{
-				Category: category,
-				Question: question,
-				Answer:   answer,
-			})
+		// Remove curly brackets
+		for i, part := range parts {
+			parts[i] = strings.ReplaceAll(strings.ReplaceAll(part, "{", ""), "}", "")
 		}
+		records = append(records, parts)
+		lines = append(lines, n+1)
 	}
+	return records, lines
+}
 
-	return trivia, nil
+// isTriviaHeader reports whether a first record names the columns rather
+// than holding a question
+func isTriviaHeader(parts []string) bool {
+	return strings.EqualFold(strings.TrimSpace(parts[0]), "category") &&
+		strings.EqualFold(strings.TrimSpace(parts[1]), "question") &&
+		strings.EqualFold(strings.TrimSpace(parts[2]), "answer")
 }
 
-func insertTriviaIntoDatabase(trivia []TriviaQuestion, dbPath string) error {
-	// Open database with UTF-8 encoding
-	db, err := sql.Open("sqlite3", dbPath+"?charset=utf8&parseTime=true")
+func insertTriviaIntoDatabase(trivia []TriviaQuestion, dbPath string, batchSize int) error {
+	// Open database with UTF-8 encoding, in WAL mode
+	db, err := sql.Open("sqlite3", store.SQLiteDSN(dbPath))
 	if err != nil {
 		return fmt.Errorf("failed to open database: %v", err)
 	}
@@ -103,23 +309,10 @@ func insertTriviaIntoDatabase(trivia []TriviaQuestion, dbPath string) error {
 		return fmt.Errorf("failed to set encoding: %v", err)
 	}
 
-	// Drop and recreate table
-	_, err = db.Exec("DROP TABLE IF EXISTS trivia")
-	if err != nil {
-		return fmt.Errorf("failed to drop table: %v", err)
-	}
-
-	_, err = db.Exec(`
-        CREATE TABLE trivia (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            category TEXT NOT NULL,
-            question TEXT NOT NULL,
-            answer TEXT NOT NULL,
-            viewCount INTEGER NOT NULL DEFAULT 0
-        )
-    `)
-	if err != nil {
-		return fmt.Errorf("failed to create table: %v", err)
+	// Later runs update the rows in place so viewCount and manual edits
+	// survive; the migrations create the table and its unique question hash
+	if _, err := migrations.Up(db, 0); err != nil {
+		return err
 	}
 
 	// Begin transaction
@@ -128,33 +321,80 @@ func insertTriviaIntoDatabase(trivia []TriviaQuestion, dbPath string) error {
 		return fmt.Errorf("failed to begin transaction: %v", err)
 	}
 
-	stmt, err := tx.Prepare("INSERT INTO trivia (category, question, answer, viewCount) VALUES (?, ?, ?, 0)")
+	var before int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM trivia").Scan(&before); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to count trivia: %v", err)
+	}
+
+	// Multi-row inserts of batchSize questions each
+	rows := make([][]interface{}, len(trivia))
+	for i, q := range trivia {
+		rows[i] = []interface{}{q.Category, q.Question, q.Answer, dedup.TextHash(q.Question), nullIfBlank(q.ImageURL), nullIfBlank(q.AudioURL), nullIfBlank(q.Difficulty), wrongAnswers(q.WrongAnswers), nullIfBlank(q.Hint)}
+	}
+	b := store.Batch{
+		Insert:   "INSERT INTO trivia (category, question, answer, questionHash, imageUrl, audioUrl, difficulty, wrongAnswers, hint)",
+		Conflict: "ON CONFLICT(questionHash) DO UPDATE SET category = excluded.category, answer = excluded.answer, difficulty = COALESCE(excluded.difficulty, trivia.difficulty), wrongAnswers = COALESCE(excluded.wrongAnswers, trivia.wrongAnswers), hint = COALESCE(excluded.hint, trivia.hint), imageUrl = COALESCE(excluded.imageUrl, trivia.imageUrl), audioUrl = COALESCE(excluded.audioUrl, trivia.audioUrl)",
+		Size:     batchSize,
+		Key:      func(row []interface{}) string { return row[3].(string) },
+	}
+	if err = b.Exec(tx, rows); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to insert trivia: %v", err)
+	}
+	processed := len(rows)
+
+	// File the questions under the canonical names of their categories
+	renamed, err := schema.NormalizeTriviaCategories(tx)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to prepare statement: %v", err)
+		return err
 	}
-	defer stmt.Close()
 
-	inserted := 0
-	for _, q := range trivia {
-		_, err = stmt.Exec(q.Category, q.Question, q.Answer)
-		if err != nil {
-			log.Printf("Warning: failed to insert trivia: %v", err)
-			continue
-		}
-		inserted++
+	var after int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM trivia").Scan(&after); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to count trivia: %v", err)
 	}
 
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	fmt.Printf("✓ Inserted %d trivia questions into database.db\n", inserted)
+	inserted := after - before
+	fmt.Printf("✓ Inserted %d new and updated %d existing trivia questions in database.db\n", inserted, processed-inserted)
+	if renamed > 0 {
+		fmt.Printf("✓ Filed %d questions under the canonical spelling of their category\n", renamed)
+	}
 	return nil
 }
 
+// nullIfBlank stores a missing media address, difficulty or hint as NULL
+func nullIfBlank(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// wrongAnswers stores wrong answers as the JSON array store.DecodeAnswers
+// reads, or NULL when there are none
+func wrongAnswers(answers []string) sql.NullString {
+	if len(answers) == 0 {
+		return sql.NullString{}
+	}
+	b, err := json.Marshal(answers)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return nullIfBlank(string(b))
+}
+
 func main() {
-	triviaFile := "trivia.txt"
+	batchSize := flag.Int("batch", store.DefaultBatchSize, "questions per multi-row INSERT")
+	triviaFlag := flag.String("file", "trivia.txt", "trivia file to read")
+	format := flag.String("format", formatAuto, "csv, tsv, brace (fields in curly brackets) or auto to tell by the extension")
+	reportPath := flag.String("report", "", "JSON file to list the skipped lines in, to fix the trivia file by")
+	flag.Parse()
+
+	triviaFile := *triviaFlag
 	dbPath := "database.db"
 
 	// Check if trivia file exists
@@ -164,16 +404,25 @@ func main() {
 
 	fmt.Printf("Reading trivia from %s...\n", triviaFile)
 
-	// Read trivia from file with custom parsing
-	trivia, err := readTriviaFromFile(triviaFile)
+	var rep *rejects.Report
+	if *reportPath != "" {
+		rep = rejects.New(*reportPath, "processTrivia.go")
+	}
+	trivia, err := readTriviaFromFile(triviaFile, *format, rep)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := rep.Save(); err != nil {
+		log.Fatal(err)
+	}
+	if rep != nil {
+		fmt.Printf("Listed %d skipped lines in %s\n", rep.Len(), *reportPath)
+	}
 
 	fmt.Printf("Found %d unique trivia questions (duplicates removed)\n", len(trivia))
 
 	// Insert into database
-	if err := insertTriviaIntoDatabase(trivia, dbPath); err != nil {
+	if err := insertTriviaIntoDatabase(trivia, dbPath, *batchSize); err != nil {
 		log.Fatal(err)
 	}
 
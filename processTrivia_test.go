@@ -0,0 +1,79 @@
+package main
+
+// Run with: go test processTrivia.go processTrivia_test.go
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadTriviaRecords(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		format  string
+		content string
+		records [][]string
+		lines   []int
+	}{
+		{
+			name:    "csv with a quoted comma",
+			format:  formatCSV,
+			content: "category,question,answer\nhistory,\"Who said \"\"veni, vidi, vici\"\"?\",Caesar\n",
+			records: [][]string{{"category", "question", "answer"}, {"history", `Who said "veni, vidi, vici"?`, "Caesar"}},
+			lines:   []int{1, 2},
+		},
+		{
+			name:    "csv with an embedded newline",
+			format:  formatCSV,
+			content: "\ufeffscience,\"What is\nH2O?\",Water\nmusic,Who wrote Bolero?,Ravel\n",
+			records: [][]string{{"science", "What is\nH2O?", "Water"}, {"music", "Who wrote Bolero?", "Ravel"}},
+			lines:   []int{1, 3},
+		},
+		{
+			name:    "tsv keeps commas in fields",
+			format:  formatTSV,
+			content: "geography\tLargest city, by population?\tTokyo\teasy\n",
+			records: [][]string{{"geography", "Largest city, by population?", "Tokyo", "easy"}},
+			lines:   []int{1},
+		},
+		{
+			name:    "braces keep commas in fields",
+			format:  formatBrace,
+			content: "{history},{Who said veni, vidi, vici?},{Caesar}\n\n{science}, {What is H2O?},{Water}\n",
+			records: [][]string{{"history", "Who said veni, vidi, vici?", "Caesar"}, {"science", " What is H2O?", "Water"}},
+			lines:   []int{1, 3},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var records [][]string
+			var lines []int
+			if tc.format == formatBrace {
+				records, lines = readBraced(tc.content)
+			} else {
+				var err error
+				if records, lines, err = readDelimited(tc.content, tc.format); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(records, tc.records) || !reflect.DeepEqual(lines, tc.lines) {
+				t.Errorf("records = %q on lines %v, want %q on lines %v", records, lines, tc.records, tc.lines)
+			}
+		})
+	}
+}
+
+func TestIsTriviaHeader(t *testing.T) {
+	for _, tc := range []struct {
+		parts []string
+		want  bool
+	}{
+		{[]string{"category", "question", "answer"}, true},
+		{[]string{" Category ", "QUESTION", "Answer", "hint"}, true},
+		{[]string{"category", "answer", "question"}, false},
+		{[]string{"history", "Who said it?", "Caesar"}, false},
+	} {
+		if got := isTriviaHeader(tc.parts); got != tc.want {
+			t.Errorf("isTriviaHeader(%q) = %v, want %v", tc.parts, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// The APNs endpoints of apps in production and of development builds
+const (
+	APNsEndpoint        = "https://api.push.apple.com"
+	APNsSandboxEndpoint = "https://api.sandbox.push.apple.com"
+)
+
+// APNsSender sends through APNs with a token-based connection: a .p8 key
+// from the Apple developer account signs the tokens it authenticates with.
+// APNs wants HTTP/2, which http.Client negotiates over TLS.
+type APNsSender struct {
+	KeyID    string
+	TeamID   string
+	Topic    string // the app's bundle ID
+	Key      *ecdsa.PrivateKey
+	Endpoint string // APNsEndpoint or APNsSandboxEndpoint
+	HTTP     *http.Client
+
+	mu     sync.Mutex
+	token  string
+	issued time.Time
+}
+
+// LoadAPNs reads the .p8 key of keyID, belonging to teamID, to send to the
+// app topic
+func LoadAPNs(path, keyID, teamID, topic string, sandbox bool) (*APNsSender, error) {
+	if keyID == "" || teamID == "" || topic == "" {
+		return nil, errors.New("APNs needs the key ID, the team ID and the app's bundle ID")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read APNs key: %v", err)
+	}
+	key, err := parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("APNs key: %v", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("APNs key: want an ECDSA private key")
+	}
+	endpoint := APNsEndpoint
+	if sandbox {
+		endpoint = APNsSandboxEndpoint
+	}
+	return &APNsSender{
+		KeyID:    keyID,
+		TeamID:   teamID,
+		Topic:    topic,
+		Key:      ecKey,
+		Endpoint: endpoint,
+		HTTP:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// authToken returns the provider token, signed again every 50 minutes:
+// APNs refuses those older than an hour, and new ones more often than every
+// 20 minutes
+func (a *APNsSender) authToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Since(a.issued) < 50*time.Minute {
+		return a.token, nil
+	}
+	now := time.Now()
+	token, err := signJWT(map[string]interface{}{"alg": "ES256", "kid": a.KeyID},
+		map[string]interface{}{"iss": a.TeamID, "iat": now.Unix()}, signES256(a.Key))
+	if err != nil {
+		return "", err
+	}
+	a.token, a.issued = token, now
+	return token, nil
+}
+
+// Send sends n to the device of token
+func (a *APNsSender) Send(ctx context.Context, token string, n Notification) error {
+	auth, err := a.authToken()
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{}
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+	alert := map[string]string{"body": n.Body}
+	if n.Title != "" {
+		alert["title"] = n.Title
+	}
+	payload["aps"] = map[string]interface{}{"alert": alert}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Endpoint+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+auth)
+	req.Header.Set("apns-topic", a.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send through APNs: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var failure struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+	switch {
+	case resp.StatusCode == http.StatusGone, failure.Reason == "BadDeviceToken", failure.Reason == "Unregistered":
+		return ErrUnregistered
+	case failure.Reason == "ExpiredProviderToken":
+		a.mu.Lock()
+		a.token = ""
+		a.mu.Unlock()
+	}
+	return fmt.Errorf("APNs answered %s: %s", resp.Status, failure.Reason)
+}
@@ -0,0 +1,184 @@
+package push
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"quotesparser/store"
+)
+
+// Dispatcher sends each registered device the quote of the day in its
+// language once a day, at its deliverAt time in its time zone, so every
+// device in a time zone gets the quote /quotes/daily shows there
+type Dispatcher struct {
+	DB    *sql.DB
+	Store store.Store // on DB
+	// Senders deliver to the devices of each platform; devices of the
+	// others wait until one is configured
+	Senders map[string]Sender
+	// Window is how late a quote may still go out, as after a restart;
+	// devices missing it get the next day's. An hour when 0.
+	Window time.Duration
+	// Now is the clock, time.Now when nil
+	Now func() time.Time
+}
+
+// Result counts what a dispatch did
+type Result struct {
+	Sent    int
+	Removed int // devices no longer registered with their platform
+	Failed  int // tried again on the next dispatch within the window
+}
+
+// NewDispatcher returns a Dispatcher reading devices and quotes from db
+func NewDispatcher(db *sql.DB, senders map[string]Sender) *Dispatcher {
+	return &Dispatcher{DB: db, Store: store.NewSQLite(db, store.Options{}), Senders: senders}
+}
+
+// due is a device with the local time of its quote today
+type due struct {
+	Device
+	at time.Time
+}
+
+// Dispatch sends the quote of the day to the devices whose delivery time
+// has come and who have not had it yet
+func (d *Dispatcher) Dispatch(ctx context.Context) (Result, error) {
+	var res Result
+	devices, err := d.due()
+	if err != nil {
+		return res, err
+	}
+
+	quotes := make(map[string]store.Quote) // date/lang
+	for _, dev := range devices {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		date := dev.at.Format(time.DateOnly)
+		q, ok := quotes[date+"/"+dev.Lang]
+		if !ok {
+			q, err = store.DailyQuote(d.Store, store.Filter{Lang: dev.Lang}, dev.at)
+			if errors.Is(err, store.ErrNotFound) && dev.Lang != "" {
+				q, err = store.DailyQuote(d.Store, store.Filter{}, dev.at)
+			}
+			if errors.Is(err, store.ErrNotFound) {
+				return res, nil
+			}
+			if err != nil {
+				return res, err
+			}
+			quotes[date+"/"+dev.Lang] = q
+		}
+
+		err := d.Senders[dev.Platform].Send(ctx, dev.Token, notification(q, date))
+		switch {
+		case errors.Is(err, ErrUnregistered):
+			if _, err := Unregister(d.DB, dev.Token); err != nil {
+				return res, err
+			}
+			res.Removed++
+		case err != nil:
+			slog.Warn("failed to send the quote of the day", "platform", dev.Platform, "device", short(dev.Token), "err", err)
+			res.Failed++
+		default:
+			if _, err := d.DB.Exec("UPDATE pushDevices SET lastSent = ? WHERE token = ?", date, dev.Token); err != nil {
+				return res, fmt.Errorf("failed to record notification: %v", err)
+			}
+			res.Sent++
+		}
+	}
+	return res, nil
+}
+
+// due returns the devices of the configured platforms whose delivery time
+// passed less than the window ago and who were not sent that day's quote
+func (d *Dispatcher) due() ([]due, error) {
+	now := time.Now()
+	if d.Now != nil {
+		now = d.Now()
+	}
+	window := d.Window
+	if window <= 0 {
+		window = time.Hour
+	}
+
+	rows, err := d.DB.Query("SELECT token, platform, lang, timeZone, deliverAt, lastSent FROM pushDevices ORDER BY registeredAt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read devices: %v", err)
+	}
+	defer rows.Close()
+	var devices []due
+	for rows.Next() {
+		var dev Device
+		var lang, lastSent sql.NullString
+		if err := rows.Scan(&dev.Token, &dev.Platform, &lang, &dev.TimeZone, &dev.DeliverAt, &lastSent); err != nil {
+			return nil, fmt.Errorf("failed to read devices: %v", err)
+		}
+		dev.Lang = lang.String
+		if d.Senders[dev.Platform] == nil {
+			continue
+		}
+		loc, err := time.LoadLocation(dev.TimeZone)
+		if err != nil {
+			continue
+		}
+		clock, err := time.Parse("15:04", dev.DeliverAt)
+		if err != nil {
+			continue
+		}
+		local := now.In(loc)
+		at := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		if local.Before(at) || !local.Before(at.Add(window)) || lastSent.String == at.Format(time.DateOnly) {
+			continue
+		}
+		devices = append(devices, due{dev, at})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read devices: %v", err)
+	}
+	return devices, nil
+}
+
+// Run dispatches every interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		res, err := d.Dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("failed to dispatch", "err", err)
+		}
+		if res != (Result{}) {
+			slog.Info("dispatched the quote of the day", "sent", res.Sent, "removed", res.Removed, "failed", res.Failed)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// notification shows q, credited as its language does, and opens it in the
+// app
+func notification(q store.Quote, date string) Notification {
+	return Notification{
+		Title: q.Credit(),
+		Body:  q.Text,
+		Data:  map[string]string{"quoteId": strconv.FormatInt(q.ID, 10), "date": date},
+	}
+}
+
+// short abbreviates a device token for the log
+func short(token string) string {
+	if len(token) > 12 {
+		return token[:12] + "…"
+	}
+	return token
+}
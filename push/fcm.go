@@ -0,0 +1,183 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FCMEndpoint is the root of the FCM HTTP v1 API
+const FCMEndpoint = "https://fcm.googleapis.com"
+
+// FCMSender sends through the FCM HTTP v1 API as a Firebase project's
+// service account
+type FCMSender struct {
+	ProjectID string
+	Email     string // the service account's
+	Key       *rsa.PrivateKey
+	TokenURL  string // where access tokens are granted
+	Endpoint  string // FCMEndpoint, unless testing
+	HTTP      *http.Client
+
+	mu      sync.Mutex
+	access  string
+	expires time.Time
+}
+
+// LoadFCM reads the service account JSON file downloaded from the Firebase
+// console
+func LoadFCM(path string) (*FCMSender, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FCM credentials: %v", err)
+	}
+	var account struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse FCM credentials: %v", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" {
+		return nil, errors.New("FCM credentials lack project_id or client_email")
+	}
+	key, err := parseKey([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("FCM credentials: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("FCM credentials: want an RSA private key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &FCMSender{
+		ProjectID: account.ProjectID,
+		Email:     account.ClientEmail,
+		Key:       rsaKey,
+		TokenURL:  account.TokenURI,
+		Endpoint:  FCMEndpoint,
+		HTTP:      &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// accessToken returns an OAuth access token for the messaging scope,
+// granted for the service account's signed assertion and reused until a
+// minute before it expires
+func (f *FCMSender) accessToken(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.access != "" && time.Now().Before(f.expires) {
+		return f.access, nil
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(map[string]interface{}{"alg": "RS256", "typ": "JWT"}, map[string]interface{}{
+		"iss":   f.Email,
+		"scope": "https://www.googleapis.com/auth/firebase.messaging",
+		"aud":   f.TokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}, signRS256(f.Key))
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := f.HTTP.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an FCM access token: %v", err)
+	}
+	defer resp.Body.Close()
+	var granted struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("failed to get an FCM access token: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&granted); err != nil || granted.AccessToken == "" {
+		return "", fmt.Errorf("failed to get an FCM access token: bad answer (%v)", err)
+	}
+	f.access, f.expires = granted.AccessToken, now.Add(time.Duration(granted.ExpiresIn)*time.Second-time.Minute)
+	return f.access, nil
+}
+
+// Send sends n to the app instance of token
+func (f *FCMSender) Send(ctx context.Context, token string, n Notification) error {
+	access, err := f.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	type notification struct {
+		Title string `json:"title,omitempty"`
+		Body  string `json:"body"`
+	}
+	body, err := json.Marshal(map[string]interface{}{"message": struct {
+		Token        string            `json:"token"`
+		Notification notification      `json:"notification"`
+		Data         map[string]string `json:"data,omitempty"`
+	}{token, notification{n.Title, n.Body}, n.Data}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/v1/projects/%s/messages:send", f.Endpoint, url.PathEscape(f.ProjectID)), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+access)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send through FCM: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var failure struct {
+		Error struct {
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+	for _, d := range failure.Error.Details {
+		if d.ErrorCode == "UNREGISTERED" {
+			return ErrUnregistered
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrUnregistered
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Revoked early; ask for another next time
+		f.mu.Lock()
+		f.access = ""
+		f.mu.Unlock()
+	}
+	return fmt.Errorf("FCM answered %s: %s", resp.Status, failure.Error.Message)
+}
@@ -0,0 +1,168 @@
+// Package push sends the quote of the day to the phones registered for it,
+// through Firebase Cloud Messaging for Android and the Apple Push
+// Notification service for iOS, each at the local time its device asked for.
+package push
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The platforms a device registers for
+const (
+	FCM  = "fcm"
+	APNs = "apns"
+)
+
+// Notification is what a device shows, with Data for the app to open
+type Notification struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Sender delivers notifications to the devices of one platform
+type Sender interface {
+	Send(ctx context.Context, token string, n Notification) error
+}
+
+// ErrUnregistered is returned for a token the platform no longer delivers
+// to, as after the app is uninstalled; its device is removed
+var ErrUnregistered = errors.New("device token is no longer registered")
+
+// Device is a device registered for the quote of the day
+type Device struct {
+	Token     string `json:"token"`
+	Platform  string `json:"platform"`            // fcm or apns
+	Lang      string `json:"lang,omitempty"`      // language of the quote; any when empty
+	TimeZone  string `json:"timeZone,omitempty"`  // e.g. Europe/Istanbul; UTC when empty
+	DeliverAt string `json:"deliverAt,omitempty"` // HH:MM in TimeZone; 09:00 when empty
+}
+
+// Check fills in the defaults of d and reports what is wrong with it
+func (d *Device) Check() error {
+	d.Token = strings.TrimSpace(d.Token)
+	if d.Token == "" || len(d.Token) > 4096 {
+		return errors.New("token must have 1 to 4096 characters")
+	}
+	if d.Platform != FCM && d.Platform != APNs {
+		return fmt.Errorf("platform must be %s or %s, not %q", FCM, APNs, d.Platform)
+	}
+	if len(d.Lang) > 16 {
+		return fmt.Errorf("unknown language %q", d.Lang)
+	}
+	if d.TimeZone == "" {
+		d.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(d.TimeZone); err != nil || d.TimeZone == "Local" {
+		return fmt.Errorf("unknown time zone %q", d.TimeZone)
+	}
+	if d.DeliverAt == "" {
+		d.DeliverAt = "09:00"
+	}
+	if _, err := time.Parse("15:04", d.DeliverAt); err != nil {
+		return fmt.Errorf("bad deliverAt %q, want HH:MM", d.DeliverAt)
+	}
+	return nil
+}
+
+// Register adds d to the pushDevices table, or updates it when its token is
+// there already. d must have been checked.
+func Register(db *sql.DB, d Device) error {
+	_, err := db.Exec(`INSERT INTO pushDevices (token, platform, lang, timeZone, deliverAt) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(token) DO UPDATE SET platform = excluded.platform, lang = excluded.lang,
+			timeZone = excluded.timeZone, deliverAt = excluded.deliverAt`,
+		d.Token, d.Platform, nullable(d.Lang), d.TimeZone, d.DeliverAt)
+	if err != nil {
+		return fmt.Errorf("failed to register device: %v", err)
+	}
+	return nil
+}
+
+// Unregister removes the device of token, reporting whether it was there
+func Unregister(db *sql.DB, token string) (bool, error) {
+	res, err := db.Exec("DELETE FROM pushDevices WHERE token = ?", token)
+	if err != nil {
+		return false, fmt.Errorf("failed to unregister device: %v", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// signJWT returns a JSON web token of claims signed by sign, which gets the
+// SHA-256 digest of the header and claims
+func signJWT(header, claims map[string]interface{}, sign func(digest []byte) ([]byte, error)) (string, error) {
+	var parts []string
+	for _, part := range []map[string]interface{}{header, claims} {
+		b, err := json.Marshal(part)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, base64.RawURLEncoding.EncodeToString(b))
+	}
+	digest := sha256.Sum256([]byte(strings.Join(parts, ".")))
+	sig, err := sign(digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %v", err)
+	}
+	return strings.Join(parts, ".") + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// signRS256 signs a digest as RS256 tokens are
+func signRS256(key *rsa.PrivateKey) func([]byte) ([]byte, error) {
+	return func(digest []byte) ([]byte, error) {
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	}
+}
+
+// signES256 signs a digest as ES256 tokens are: r and s of 32 bytes each
+func signES256(key *ecdsa.PrivateKey) func([]byte) ([]byte, error) {
+	return func(digest []byte) ([]byte, error) {
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			return nil, err
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	}
+}
+
+// parseKey reads the PKCS #8 private key of a PEM block, as Google's
+// service account files and Apple's .p8 files hold
+func parseKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key %T", key)
+	}
+	return signer, nil
+}
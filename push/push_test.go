@@ -0,0 +1,133 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFCMSend(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants := 0
+	var got map[string]map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			grants++
+			if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || strings.Count(r.FormValue("assertion"), ".") != 2 {
+				http.Error(w, "bad grant", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "secret", "expires_in": 3600})
+		case "/v1/projects/quotes-app/messages:send":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&got)
+			if got["message"]["token"] == "gone" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":{"code":404,"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f := &FCMSender{ProjectID: "quotes-app", Email: "push@quotes-app.iam.gserviceaccount.com", Key: key,
+		TokenURL: srv.URL + "/token", Endpoint: srv.URL, HTTP: srv.Client()}
+	n := Notification{Title: "Amos Oz", Body: "First quote.", Data: map[string]string{"quoteId": "1"}}
+	if err := f.Send(context.Background(), "device-1", n); err != nil {
+		t.Fatal(err)
+	}
+	if got["message"]["token"] != "device-1" || got["message"]["notification"].(map[string]interface{})["body"] != "First quote." {
+		t.Errorf("message = %v", got)
+	}
+	if err := f.Send(context.Background(), "gone", n); !errors.Is(err, ErrUnregistered) {
+		t.Errorf("Send to an unregistered token = %v, want ErrUnregistered", err)
+	}
+	if grants != 1 {
+		t.Errorf("access token granted %d times, want once", grants)
+	}
+}
+
+func TestAPNsSend(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("apns-topic") != "com.example.quotes" || !verifyES256(&key.PublicKey, strings.TrimPrefix(r.Header.Get("Authorization"), "bearer ")) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"reason":"InvalidProviderToken"}`))
+			return
+		}
+		if r.URL.Path == "/3/device/gone" {
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	a := &APNsSender{KeyID: "ABC123", TeamID: "TEAM42", Topic: "com.example.quotes", Key: key, Endpoint: srv.URL, HTTP: srv.Client()}
+	n := Notification{Title: "Amos Oz", Body: "First quote.", Data: map[string]string{"quoteId": "1"}}
+	if err := a.Send(context.Background(), "abcdef", n); err != nil {
+		t.Fatal(err)
+	}
+	alert := got["aps"].(map[string]interface{})["alert"].(map[string]interface{})
+	if alert["title"] != "Amos Oz" || alert["body"] != "First quote." || got["quoteId"] != "1" {
+		t.Errorf("payload = %v", got)
+	}
+	if err := a.Send(context.Background(), "gone", n); !errors.Is(err, ErrUnregistered) {
+		t.Errorf("Send to an unregistered token = %v, want ErrUnregistered", err)
+	}
+}
+
+// verifyES256 checks the signature of an ES256 token
+func verifyES256(pub *ecdsa.PublicKey, token string) bool {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || len(sig) != 64 {
+		return false
+	}
+	digest := sha256.Sum256([]byte(token[:i]))
+	return ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+}
+
+func TestDeviceCheck(t *testing.T) {
+	d := Device{Token: " abc ", Platform: APNs}
+	if err := d.Check(); err != nil || d.Token != "abc" || d.TimeZone != "UTC" || d.DeliverAt != "09:00" {
+		t.Errorf("Check = %v, %+v", err, d)
+	}
+	for _, bad := range []Device{
+		{Platform: FCM},
+		{Token: "abc", Platform: "sms"},
+		{Token: "abc", Platform: FCM, TimeZone: "Mars/Olympus"},
+		{Token: "abc", Platform: FCM, DeliverAt: "25:00"},
+	} {
+		if err := bad.Check(); err == nil {
+			t.Errorf("Check(%+v) = nil, want an error", bad)
+		}
+	}
+}
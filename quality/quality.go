@@ -0,0 +1,140 @@
+// Package quality scores the quotes extracted from pages and filters out
+// what is not one: navigation links, headings and sentences cut short. A
+// Filter holds the checks, configured per source in a JSON file such as
+//
+//	{
+//	  "default":   {"minLength": 10},
+//	  "1000kitap": {"minLength": 21, "endPunctuation": true, "blacklist": ["devamını oku"], "maxUpperRatio": 0.5}
+//	}
+package quality
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"quotesparser/jsoncheck"
+)
+
+// Filter is the checks a quote must pass. Zero fields check nothing, so the
+// zero Filter keeps every quote.
+type Filter struct {
+	MinLength      int      `json:"minLength"`      // in characters
+	MaxLength      int      `json:"maxLength"`      // in characters
+	EndPunctuation bool     `json:"endPunctuation"` // must end a sentence: . ! ? or …, maybe inside quotes
+	Blacklist      []string `json:"blacklist"`      // phrases that mark junk, in any case
+	MaxUpperRatio  float64  `json:"maxUpperRatio"`  // of the letters, e.g. 0.5 to drop shouted menu items
+}
+
+// Verdict is what a Filter made of a quote: the share of its checks the
+// quote passed, and why it failed the others
+type Verdict struct {
+	Score   float64
+	Reasons []string
+}
+
+// OK reports whether the quote passed every check
+func (v Verdict) OK() bool {
+	return len(v.Reasons) == 0
+}
+
+// Reason is the reasons of a rejection in one line, with the score
+func (v Verdict) Reason() string {
+	return fmt.Sprintf("quality %.2f: %s", v.Score, strings.Join(v.Reasons, "; "))
+}
+
+// closers may follow the punctuation that ends a sentence
+const closers = `"'”’»)]`
+
+// Check scores text against f
+func (f Filter) Check(text string) Verdict {
+	text = strings.TrimSpace(text)
+	length := utf8.RuneCountInString(text)
+	checks := 0
+	var reasons []string
+	check := func(ok bool, reason string, args ...interface{}) {
+		checks++
+		if !ok {
+			reasons = append(reasons, fmt.Sprintf(reason, args...))
+		}
+	}
+
+	if f.MinLength > 0 {
+		check(length >= f.MinLength, "shorter than %d characters", f.MinLength)
+	}
+	if f.MaxLength > 0 {
+		check(length <= f.MaxLength, "longer than %d characters", f.MaxLength)
+	}
+	if f.EndPunctuation {
+		last, _ := utf8.DecodeLastRuneInString(strings.TrimRight(text, closers))
+		check(strings.ContainsRune(".!?…", last), "does not end a sentence")
+	}
+	if len(f.Blacklist) > 0 {
+		lower := strings.ToLower(text)
+		found := ""
+		for _, phrase := range f.Blacklist {
+			if phrase != "" && strings.Contains(lower, strings.ToLower(phrase)) {
+				found = phrase
+				break
+			}
+		}
+		check(found == "", "contains %q", found)
+	}
+	if f.MaxUpperRatio > 0 {
+		ratio := upperRatio(text)
+		check(ratio <= f.MaxUpperRatio, "%.0f%% uppercase", ratio*100)
+	}
+
+	v := Verdict{Score: 1, Reasons: reasons}
+	if checks > 0 {
+		v.Score = float64(checks-len(reasons)) / float64(checks)
+	}
+	return v
+}
+
+// upperRatio is the share of the letters of text in uppercase
+func upperRatio(text string) float64 {
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters == 0 {
+		return 0
+	}
+	return float64(upper) / float64(letters)
+}
+
+// Config is the filters of each source by name, with the one named default
+// for the sources it leaves out
+type Config map[string]Filter
+
+// For returns the filter of the source named name
+func (c Config) For(name string) Filter {
+	if f, ok := c[name]; ok {
+		return f
+	}
+	return c["default"]
+}
+
+// Load reads a Config from a JSON file
+func Load(path string) (Config, error) {
+	var c Config
+	if err := jsoncheck.ReadFile(path, &c, true); err != nil {
+		return nil, fmt.Errorf("bad quality filters: %v", err)
+	}
+	for name, f := range c {
+		if f.MinLength < 0 || f.MaxLength < 0 || (f.MaxLength > 0 && f.MaxLength < f.MinLength) {
+			return nil, fmt.Errorf("bad quality filters in %s: %s: lengths out of order", path, name)
+		}
+		if f.MaxUpperRatio < 0 || f.MaxUpperRatio > 1 {
+			return nil, fmt.Errorf("bad quality filters in %s: %s: maxUpperRatio must be between 0 and 1", path, name)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,56 @@
+package quality
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	f := Filter{MinLength: 21, MaxLength: 120, EndPunctuation: true, Blacklist: []string{"Devamını oku"}, MaxUpperRatio: 0.5}
+	for _, tc := range []struct {
+		text    string
+		score   float64
+		reasons []string
+	}{
+		{"Hayatında ilk kez kendini normal hissetti.", 1, nil},
+		{"“Seni seviyorum,” dedi, ve bu doğruydu.”", 1, nil},
+		{"Tümünü göster", 0.6, []string{"shorter than 21 characters", "does not end a sentence"}},
+		{"TÜMÜNÜ GÖSTER VE DEVAMINI OKU", 0.6, []string{"does not end a sentence", "100% uppercase"}},
+		{"Bir şeyi sevmek onu anlamaktan daha kolaydır... devamını oku", 0.6, []string{"does not end a sentence", `contains "Devamını oku"`}},
+		{"İnsanlar birbirini gerçekten değiştirebilir ve", 0.8, []string{"does not end a sentence"}},
+	} {
+		v := f.Check(tc.text)
+		if v.Score != tc.score || !reflect.DeepEqual(v.Reasons, tc.reasons) {
+			t.Errorf("Check(%q) = %.2f %q, want %.2f %q", tc.text, v.Score, v.Reasons, tc.score, tc.reasons)
+		}
+	}
+
+	if v := (Filter{}).Check("x"); !v.OK() || v.Score != 1 {
+		t.Errorf("the zero Filter rejected a quote: %+v", v)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "quality.json")
+	os.WriteFile(path, []byte(`{"default": {"minLength": 10}, "1000kitap": {"minLength": 21, "endPunctuation": true}}`), 0644)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := c.For("1000kitap"); f.MinLength != 21 || !f.EndPunctuation {
+		t.Errorf("For(1000kitap) = %+v", f)
+	}
+	if f := c.For("fraseslibros"); f.MinLength != 10 || f.EndPunctuation {
+		t.Errorf("For(fraseslibros) = %+v, want the default", f)
+	}
+
+	for _, bad := range []string{`{"default": {"minLenght": 10}}`, `{"x": {"minLength": 30, "maxLength": 20}}`, `{"x": {"maxUpperRatio": 2}}`} {
+		os.WriteFile(path, []byte(bad), 0644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load(%s) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package quiz
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"quotesparser/store"
+)
+
+// Alphabets are the letters crossword answers may use, by language
+var Alphabets = map[string]string{
+	"en": "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"tr": "ABCÇDEFGĞHIİJKLMNOÖPRSŞTUÜVYZ",
+	"es": "ABCDEFGHIJKLMNÑOPQRSTUVWXYZ",
+}
+
+// Clue is a crossword answer and the trivia question that clues it
+type Clue struct {
+	Answer string
+	Clue   string
+}
+
+// CrosswordOptions limit the answers kept for a crossword
+type CrosswordOptions struct {
+	Min, Max int    // answer length in letters; Max 0 for no limit
+	Alphabet string // letters an answer may use, or a key of Alphabets
+}
+
+// Clues picks the trivia whose answer is a single word of opts.Alphabet and
+// of a length in range, upper-cased as grids are, sorted by answer. The same
+// clue for the same answer is kept once.
+func Clues(trivia []store.Trivia, opts CrosswordOptions) []Clue {
+	alphabet := opts.Alphabet
+	if named, ok := Alphabets[alphabet]; ok {
+		alphabet = named
+	}
+	upper := strings.ToUpper
+	if opts.Alphabet == "tr" {
+		upper = func(s string) string { return strings.ToUpperSpecial(unicode.TurkishCase, s) }
+	}
+	alphabet = upper(alphabet)
+
+	var clues []Clue
+	seen := make(map[Clue]bool)
+	for _, t := range trivia {
+		answer := upper(strings.TrimSpace(t.Answer))
+		n := len([]rune(answer))
+		if answer == "" || n < opts.Min || (opts.Max > 0 && n > opts.Max) {
+			continue
+		}
+		if strings.IndexFunc(answer, func(r rune) bool { return !strings.ContainsRune(alphabet, r) }) >= 0 {
+			continue
+		}
+		c := Clue{Answer: answer, Clue: strings.Join(strings.Fields(t.Question), " ")}
+		if !seen[c] {
+			seen[c] = true
+			clues = append(clues, c)
+		}
+	}
+	sort.SliceStable(clues, func(i, j int) bool { return clues[i].Answer < clues[j].Answer })
+	return clues
+}
+
+// WriteClues writes clues as a clue database: a line of answer, tab and clue
+// per clue, the form Crossword Compiler and Qxw import
+func WriteClues(w io.Writer, clues []Clue) error {
+	for _, c := range clues {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", c.Answer, c.Clue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteWordList writes the answers of clues once each as a scored word list,
+// a line of answer;score, the form Crossfire and other grid fillers load
+func WriteWordList(w io.Writer, clues []Clue, score int) error {
+	last := ""
+	for _, c := range clues {
+		if c.Answer == last {
+			continue
+		}
+		last = c.Answer
+		if _, err := fmt.Fprintf(w, "%s;%d\n", c.Answer, score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
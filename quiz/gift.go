@@ -0,0 +1,85 @@
+package quiz
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Variants of a question to export
+const (
+	MultipleChoice = "mc"    // choose among the answer and its distractors
+	ShortAnswer    = "short" // type the answer
+	Both           = "both"
+)
+
+// giftEscaper escapes the characters GIFT gives a meaning to
+var giftEscaper = strings.NewReplacer(
+	`\`, `\\`, "~", `\~`, "=", `\=`, "#", `\#`, "{", `\{`, "}", `\}`, ":", `\:`, "\n", `\n`,
+)
+
+// WriteGIFT writes questions in Moodle's GIFT format, under a category per
+// trivia category below root. Questions without distractors are written as
+// short answers whatever variant asks; true or false questions are always
+// written as such.
+func WriteGIFT(w io.Writer, questions []Question, root, variant string) error {
+	category := ""
+	for i, q := range questions {
+		if i == 0 || q.Category != category {
+			category = q.Category
+			if _, err := fmt.Fprintf(w, "$CATEGORY: %s\n\n", categoryPath(root, category)); err != nil {
+				return err
+			}
+		}
+		for _, v := range variants(q, variant) {
+			var body string
+			switch v {
+			case "truefalse":
+				value, _ := trueFalse(q.Answer)
+				body = map[bool]string{true: "TRUE", false: "FALSE"}[value]
+			case MultipleChoice:
+				parts := []string{"=" + giftEscaper.Replace(q.Answer)}
+				for _, c := range q.Choices {
+					parts = append(parts, "~"+giftEscaper.Replace(c))
+				}
+				body = strings.Join(parts, " ")
+			default:
+				body = "=" + giftEscaper.Replace(q.Answer)
+			}
+			name := questionName(i, q, v)
+			if _, err := fmt.Fprintf(w, "::%s:: %s {%s}\n\n", giftEscaper.Replace(name), giftEscaper.Replace(q.Text), body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// variants lists the variants of q to write: truefalse, MultipleChoice or
+// ShortAnswer
+func variants(q Question, variant string) []string {
+	switch {
+	case q.IsTrueFalse():
+		return []string{"truefalse"}
+	case len(q.Choices) == 0 || variant == ShortAnswer:
+		return []string{ShortAnswer}
+	case variant == Both:
+		return []string{MultipleChoice, ShortAnswer}
+	}
+	return []string{MultipleChoice}
+}
+
+// questionName names question i in the bank, e.g. "geo 12 (mc)"
+func questionName(i int, q Question, variant string) string {
+	name := fmt.Sprintf("%s %d", q.Category, i+1)
+	if variant == MultipleChoice || variant == ShortAnswer {
+		name += " (" + variant + ")"
+	}
+	return name
+}
+
+// categoryPath is the bank category of a trivia category, e.g.
+// $course$/trivia/geo; slashes in the trivia category would nest it
+func categoryPath(root, category string) string {
+	return "$course$/" + strings.Trim(root, "/") + "/" + strings.ReplaceAll(category, "/", "-")
+}
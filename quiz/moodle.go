@@ -0,0 +1,89 @@
+package quiz
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// moodleQuestion is a <question> of Moodle XML, of type category,
+// multichoice, shortanswer or truefalse
+type moodleQuestion struct {
+	Type         string         `xml:"type,attr"`
+	Category     *moodleText    `xml:"category,omitempty"`
+	Name         *moodleText    `xml:"name,omitempty"`
+	QuestionText *moodleText    `xml:"questiontext,omitempty"`
+	Single       string         `xml:"single,omitempty"`
+	Shuffle      string         `xml:"shuffleanswers,omitempty"`
+	Numbering    string         `xml:"answernumbering,omitempty"`
+	Answers      []moodleAnswer `xml:"answer"`
+}
+
+type moodleText struct {
+	Format string `xml:"format,attr,omitempty"`
+	Text   string `xml:"text"`
+}
+
+type moodleAnswer struct {
+	Fraction int    `xml:"fraction,attr"`
+	Format   string `xml:"format,attr,omitempty"`
+	Text     string `xml:"text"`
+}
+
+// WriteMoodleXML writes questions as a Moodle XML question bank, with the
+// same categories and variants as WriteGIFT
+func WriteMoodleXML(w io.Writer, questions []Question, root, variant string) error {
+	bank := struct {
+		XMLName   xml.Name         `xml:"quiz"`
+		Questions []moodleQuestion `xml:"question"`
+	}{}
+	category := ""
+	for i, q := range questions {
+		if i == 0 || q.Category != category {
+			category = q.Category
+			bank.Questions = append(bank.Questions, moodleQuestion{Type: "category", Category: &moodleText{Text: categoryPath(root, category)}})
+		}
+		for _, v := range variants(q, variant) {
+			mq := moodleQuestion{
+				Name:         &moodleText{Text: questionName(i, q, v)},
+				QuestionText: &moodleText{Format: "plain_text", Text: q.Text},
+			}
+			switch v {
+			case "truefalse":
+				value, _ := trueFalse(q.Answer)
+				mq.Type = "truefalse"
+				mq.Answers = []moodleAnswer{
+					{Fraction: fraction(value), Format: "moodle_auto_format", Text: "true"},
+					{Fraction: fraction(!value), Format: "moodle_auto_format", Text: "false"},
+				}
+			case MultipleChoice:
+				mq.Type, mq.Single, mq.Shuffle, mq.Numbering = "multichoice", "true", "true", "abc"
+				mq.Answers = append(mq.Answers, moodleAnswer{Fraction: 100, Format: "plain_text", Text: q.Answer})
+				for _, c := range q.Choices {
+					mq.Answers = append(mq.Answers, moodleAnswer{Fraction: 0, Format: "plain_text", Text: c})
+				}
+			default:
+				mq.Type = "shortanswer"
+				mq.Answers = []moodleAnswer{{Fraction: 100, Format: "plain_text", Text: q.Answer}}
+			}
+			bank.Questions = append(bank.Questions, mq)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(bank); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func fraction(correct bool) int {
+	if correct {
+		return 100
+	}
+	return 0
+}
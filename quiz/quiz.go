@@ -0,0 +1,195 @@
+// Package quiz turns trivia into question banks for quiz and learning
+// platforms, giving questions wrong answers to choose from, and into clues
+// for crosswords.
+package quiz
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"unicode"
+
+	"quotesparser/dedup"
+	"quotesparser/store"
+)
+
+// Question is a trivia question ready to export. Choices are the wrong
+// answers of its multiple-choice variant, none when not enough could be
+// found.
+type Question struct {
+	Category   string
+	Text       string
+	Answer     string
+	Difficulty string
+	Choices    []string
+}
+
+// IsTrueFalse reports whether q is answered true or false
+func (q Question) IsTrueFalse() bool {
+	_, ok := trueFalse(q.Answer)
+	return ok
+}
+
+// trueFalse reads a true or false answer
+func trueFalse(answer string) (value, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "true", "doğru", "verdadero":
+		return true, true
+	case "false", "yanlış", "falso":
+		return false, true
+	}
+	return false, false
+}
+
+// Build makes questions of trivia, each with up to wrong distractors: the
+// wrong answers imported with it, then the answers of other questions of its
+// category, else of any category, that look like its own (a year for a year, a number for a number, words of a
+// similar length for words). The distractors of a question are the same on
+// every export of the same trivia.
+func Build(trivia []store.Trivia, wrong int) []Question {
+	p := newPool(trivia)
+	questions := make([]Question, len(trivia))
+	for i, t := range trivia {
+		questions[i] = p.question(t, wrong)
+	}
+	return questions
+}
+
+// Choose makes a question of t as Build does, with distractors from the
+// answers of pool
+func Choose(t store.Trivia, pool []store.Trivia, wrong int) Question {
+	return newPool(pool).question(t, wrong)
+}
+
+// pool is the answers distractors are drawn from, once each per category
+type pool struct {
+	byCategory map[string][]string
+	all        []string
+}
+
+func newPool(trivia []store.Trivia) pool {
+	p := pool{byCategory: make(map[string][]string)}
+	seen := make(map[string]bool)
+	for _, t := range trivia {
+		key := t.Category + "\x00" + dedup.AnswerKey(t.Answer)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		p.byCategory[t.Category] = append(p.byCategory[t.Category], t.Answer)
+		p.all = append(p.all, t.Answer)
+	}
+	return p
+}
+
+func (p pool) question(t store.Trivia, wrong int) Question {
+	q := Question{Category: t.Category, Text: t.Question, Answer: t.Answer, Difficulty: t.Difficulty}
+	if q.IsTrueFalse() || wrong <= 0 {
+		return q
+	}
+	q.Choices = appendNew(nil, t.WrongAnswers, wrong)
+	if len(q.Choices) < wrong {
+		more := Distractors(t.Question, t.Answer, p.byCategory[t.Category], wrong)
+		q.Choices = appendNew(q.Choices, more, wrong)
+	}
+	if len(q.Choices) < wrong {
+		more := Distractors(t.Question, t.Answer, p.all, wrong)
+		q.Choices = appendNew(q.Choices, more, wrong)
+	}
+	if len(q.Choices) < wrong {
+		q.Choices = nil
+	}
+	return q
+}
+
+// Distractors picks up to n wrong answers for a question from candidates:
+// those of the same shape as answer, closest in length first, with ties
+// broken by a shuffle seeded from the question
+func Distractors(question, answer string, candidates []string, n int) []string {
+	key := dedup.AnswerKey(answer)
+	shape := shapeOf(answer)
+	type candidate struct {
+		text string
+		gap  int
+	}
+	var pool []candidate
+	seen := map[string]bool{key: true}
+	for _, c := range candidates {
+		k := dedup.AnswerKey(c)
+		if k == "" || seen[k] || shapeOf(c) != shape {
+			continue
+		}
+		if _, ok := trueFalse(c); ok {
+			continue
+		}
+		seen[k] = true
+		gap := len([]rune(c)) - len([]rune(answer))
+		if gap < 0 {
+			gap = -gap
+		}
+		pool = append(pool, candidate{c, gap})
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(question))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+	r.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+	// Within a band of similar lengths the shuffle decides
+	sort.SliceStable(pool, func(i, j int) bool { return pool[i].gap/4 < pool[j].gap/4 })
+
+	var out []string
+	for _, c := range pool {
+		if len(out) == n {
+			break
+		}
+		out = append(out, c.text)
+	}
+	return out
+}
+
+// appendNew adds the answers of more not already in choices, up to n
+func appendNew(choices, more []string, n int) []string {
+	have := make(map[string]bool)
+	for _, c := range choices {
+		have[dedup.AnswerKey(c)] = true
+	}
+	for _, c := range more {
+		if len(choices) == n {
+			break
+		}
+		if !have[dedup.AnswerKey(c)] {
+			have[dedup.AnswerKey(c)] = true
+			choices = append(choices, c)
+		}
+	}
+	return choices
+}
+
+// Answer shapes distractors must share
+const (
+	shapeWords = iota
+	shapeNumber
+	shapeYear
+)
+
+// shapeOf tells years and other numbers from worded answers
+func shapeOf(answer string) int {
+	s := strings.TrimSpace(answer)
+	digits, letters := 0, 0
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case unicode.IsLetter(r):
+			letters++
+		}
+	}
+	switch {
+	case digits == 0 || letters > digits:
+		return shapeWords
+	case digits == 4 && letters == 0 && len(s) == 4:
+		return shapeYear
+	}
+	return shapeNumber
+}
@@ -0,0 +1,224 @@
+package quiz
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"quotesparser/store"
+)
+
+var trivia = []store.Trivia{
+	{Category: "geo", Question: "What is the capital of France?", Answer: "Paris"},
+	{Category: "geo", Question: "What is the capital of Italy?", Answer: "Rome"},
+	{Category: "geo", Question: "What is the capital of Spain?", Answer: "Madrid"},
+	{Category: "geo", Question: "What is the capital of Peru?", Answer: "Lima"},
+	{Category: "geo", Question: "When was Brasília founded?", Answer: "1960"},
+	{Category: "history", Question: "When did the Berlin Wall fall?", Answer: "1989"},
+	{Category: "history", Question: "When did World War II end?", Answer: "1945"},
+	{Category: "science", Question: "Water boils at 100°C at sea level.", Answer: "True"},
+}
+
+func TestBuild(t *testing.T) {
+	questions := Build(trivia, 3)
+	paris := questions[0]
+	if len(paris.Choices) != 3 {
+		t.Fatalf("Paris got choices %v, want 3", paris.Choices)
+	}
+	for _, c := range paris.Choices {
+		if c == "Paris" || c == "1960" || c == "1989" {
+			t.Errorf("Paris got %q as a wrong answer", c)
+		}
+	}
+	// Only two other years anywhere: not enough for a multiple choice
+	if c := questions[4].Choices; c != nil {
+		t.Errorf("1960 got choices %v, want none", c)
+	}
+	if !questions[7].IsTrueFalse() || questions[7].Choices != nil {
+		t.Errorf("true or false question = %+v", questions[7])
+	}
+	imported := append([]store.Trivia{{Category: "geo", Question: "When was Brasília founded?", Answer: "1960", WrongAnswers: []string{"1955"}}}, trivia[1:]...)
+	if c := Build(imported, 3)[0].Choices; len(c) != 3 || c[0] != "1955" {
+		t.Errorf("1960 with an imported wrong answer got choices %v, want 1955 and two other years", c)
+	}
+	if again := Build(trivia, 3); strings.Join(again[0].Choices, ",") != strings.Join(paris.Choices, ",") {
+		t.Errorf("choices changed between exports: %v, then %v", paris.Choices, again[0].Choices)
+	}
+}
+
+func TestWriteGIFT(t *testing.T) {
+	questions := []Question{
+		{Category: "geo", Text: "What is 2 = 2?", Answer: "Yes {really}", Choices: []string{"No"}},
+		{Category: "geo", Text: "Capital of Peru?", Answer: "Lima"},
+		{Category: "science", Text: "Water is wet.", Answer: "True"},
+	}
+	var b bytes.Buffer
+	if err := WriteGIFT(&b, questions, "trivia", Both); err != nil {
+		t.Fatal(err)
+	}
+	want := `$CATEGORY: $course$/trivia/geo
+
+::geo 1 (mc):: What is 2 \= 2? {=Yes \{really\} ~No}
+
+::geo 1 (short):: What is 2 \= 2? {=Yes \{really\}}
+
+::geo 2 (short):: Capital of Peru? {=Lima}
+
+$CATEGORY: $course$/trivia/science
+
+::science 3:: Water is wet. {TRUE}
+
+`
+	if b.String() != want {
+		t.Errorf("WriteGIFT =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteMoodleXML(t *testing.T) {
+	questions := []Question{
+		{Category: "geo", Text: "Capital of France?", Answer: "Paris", Choices: []string{"Rome", "Lima"}},
+		{Category: "science", Text: "The Sun is a planet.", Answer: "False"},
+	}
+	var b bytes.Buffer
+	if err := WriteMoodleXML(&b, questions, "trivia", MultipleChoice); err != nil {
+		t.Fatal(err)
+	}
+	var bank struct {
+		Questions []struct {
+			Type     string `xml:"type,attr"`
+			Category string `xml:"category>text"`
+			Text     string `xml:"questiontext>text"`
+			Answers  []struct {
+				Fraction int    `xml:"fraction,attr"`
+				Text     string `xml:"text"`
+			} `xml:"answer"`
+		} `xml:"question"`
+	}
+	if err := xml.Unmarshal(b.Bytes(), &bank); err != nil {
+		t.Fatalf("%v:\n%s", err, b.String())
+	}
+	var types []string
+	for _, q := range bank.Questions {
+		types = append(types, q.Type)
+	}
+	if got := strings.Join(types, " "); got != "category multichoice category truefalse" {
+		t.Fatalf("question types = %s", got)
+	}
+	mc := bank.Questions[1]
+	if bank.Questions[0].Category != "$course$/trivia/geo" || len(mc.Answers) != 3 || mc.Answers[0].Fraction != 100 || mc.Answers[0].Text != "Paris" {
+		t.Errorf("multiple choice = %+v", mc)
+	}
+	if tf := bank.Questions[3]; tf.Answers[0].Text != "true" || tf.Answers[0].Fraction != 0 || tf.Answers[1].Fraction != 100 {
+		t.Errorf("true or false = %+v", tf)
+	}
+}
+
+func TestWriteKahoot(t *testing.T) {
+	questions := []Question{
+		{Category: "geo", Text: "What is the capital of France?", Answer: "Paris", Choices: []string{"Rome", "Lima", "Madrid"}},
+		{Category: "geo", Text: "What is the capital of Peru?", Answer: "Lima"},
+		{Category: "geo", Text: strings.Repeat("Why? ", 30), Answer: "Because", Choices: []string{"No"}},
+		{Category: "science", Text: "Water boils at 100°C at sea level.", Answer: "True"},
+	}
+	if _, err := WriteKahoot(io.Discard, questions, 25); err == nil {
+		t.Fatal("accepted a 25 second time limit")
+	}
+	var b bytes.Buffer
+	n, err := WriteKahoot(&b, questions, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("wrote %d questions, want 2", n)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	for _, f := range z.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := xml.NewDecoder(r).Decode(&sheet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sheet.Rows) != 3 {
+		t.Fatalf("%d rows, want a header and 2 questions", len(sheet.Rows))
+	}
+	cells := make(map[string]string)
+	for _, c := range sheet.Rows[1].Cells {
+		cells[c.Ref] = c.Value + c.Inline
+	}
+	options, correct := questions[0].Shuffled()
+	if cells["B2"] != questions[0].Text || cells["G2"] != "20" || cells["H2"] != string(rune('1'+correct)) || options[correct] != "Paris" {
+		t.Errorf("row = %v", cells)
+	}
+	if got := cells[cellRef(2+correct, 1)]; got != "Paris" {
+		t.Errorf("correct answer cell = %q", got)
+	}
+	for _, c := range sheet.Rows[2].Cells {
+		if c.Ref == "F3" || (c.Ref == "H3" && c.Value != "1") {
+			t.Errorf("true or false row has %s = %q", c.Ref, c.Value+c.Inline)
+		}
+	}
+}
+
+func TestCellRef(t *testing.T) {
+	for _, tc := range []struct {
+		c, r int
+		want string
+	}{{0, 0, "A1"}, {7, 2, "H3"}, {25, 9, "Z10"}, {26, 0, "AA1"}, {27, 0, "AB1"}, {701, 0, "ZZ1"}, {702, 0, "AAA1"}} {
+		if got := cellRef(tc.c, tc.r); got != tc.want {
+			t.Errorf("cellRef(%d, %d) = %s, want %s", tc.c, tc.r, got, tc.want)
+		}
+	}
+}
+
+func TestClues(t *testing.T) {
+	trivia := []store.Trivia{
+		{Question: "In the game of cricket which bird name means scoring no runs", Answer: "Duck "},
+		{Question: "Capital of  France", Answer: "Paris"},
+		{Question: "Capital of France", Answer: "paris"},
+		{Question: "Band of 'Melt with you'", Answer: "Modern English"},
+		{Question: "Year the Wall fell", Answer: "1989"},
+		{Question: "Largest ocean", Answer: "Pacific"},
+		{Question: "Türkiye'nin başkenti", Answer: "Ankara"},
+		{Question: "Boğaziçi'nin kıtası", Answer: "Asya"},
+		{Question: "Kuzey Kıbrıs'ın başkenti", Answer: "Lefkoşa"},
+		{Question: "Long", Answer: "Ab"},
+	}
+	var b bytes.Buffer
+	if err := WriteClues(&b, Clues(trivia, CrosswordOptions{Min: 3, Max: 6, Alphabet: "en"})); err != nil {
+		t.Fatal(err)
+	}
+	want := "ANKARA\tTürkiye'nin başkenti\nASYA\tBoğaziçi'nin kıtası\nDUCK\tIn the game of cricket which bird name means scoring no runs\nPARIS\tCapital of France\n"
+	if b.String() != want {
+		t.Errorf("clues =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := WriteWordList(&b, Clues(trivia, CrosswordOptions{Min: 3, Alphabet: "tr"}), 50); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ANKARA;50\nASYA;50\nDUCK;50\nLEFKOŞA;50\nPACİFİC;50\nPARİS;50\n"; b.String() != want {
+		t.Errorf("word list =\n%s\nwant\n%s", b.String(), want)
+	}
+}
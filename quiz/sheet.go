@@ -0,0 +1,123 @@
+package quiz
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"strconv"
+	"unicode/utf8"
+)
+
+// KahootTimes are the time limits, in seconds, Kahoot accepts
+var KahootTimes = []int{5, 10, 20, 30, 60, 90, 120, 240}
+
+// Kahoot's limits on the length of a question and of an answer
+const (
+	kahootQuestionMax = 120
+	kahootAnswerMax   = 75
+)
+
+// Shuffled returns q's answer and wrong choices in an order seeded from the
+// question, and the index of the answer among them. True or false questions
+// keep True first.
+func (q Question) Shuffled() ([]string, int) {
+	if value, ok := trueFalse(q.Answer); ok {
+		if value {
+			return []string{"True", "False"}, 0
+		}
+		return []string{"True", "False"}, 1
+	}
+	options := append([]string{q.Answer}, q.Choices...)
+	h := fnv.New64a()
+	h.Write([]byte(q.Text))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+	r.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+	for i, o := range options {
+		if o == q.Answer {
+			return options, i
+		}
+	}
+	return options, 0
+}
+
+// choosable reports whether q can be asked with options: it has wrong
+// choices, or is answered true or false
+func choosable(q Question) bool {
+	return len(q.Choices) > 0 || q.IsTrueFalse()
+}
+
+// WriteKahoot writes questions as the spreadsheet Kahoot imports: question,
+// up to four answers, time limit and the number of the correct answer.
+// Questions without choices, with more than four options or longer than
+// Kahoot allows are left out; it returns how many were written.
+func WriteKahoot(w io.Writer, questions []Question, seconds int) (int, error) {
+	valid := false
+	for _, t := range KahootTimes {
+		valid = valid || t == seconds
+	}
+	if !valid {
+		return 0, fmt.Errorf("Kahoot time limits are %v seconds, not %d", KahootTimes, seconds)
+	}
+
+	rows := [][]string{{"", "Question - max 120 characters", "Answer 1 - max 75 characters", "Answer 2 - max 75 characters",
+		"Answer 3 - max 75 characters", "Answer 4 - max 75 characters", "Time limit (sec) – 5, 10, 20, 30, 60, 90, 120, or 240 secs",
+		"Correct answer(s) - choose at least one"}}
+	for _, q := range questions {
+		if !choosable(q) || utf8.RuneCountInString(q.Text) > kahootQuestionMax {
+			continue
+		}
+		options, correct := q.Shuffled()
+		if len(options) > 4 || !fits(options, kahootAnswerMax) {
+			continue
+		}
+		row := []string{strconv.Itoa(len(rows)), q.Text}
+		for i := 0; i < 4; i++ {
+			if i < len(options) {
+				row = append(row, options[i])
+			} else {
+				row = append(row, "")
+			}
+		}
+		rows = append(rows, append(row, strconv.Itoa(seconds), strconv.Itoa(correct+1)))
+	}
+	return len(rows) - 1, writeXLSX(w, "Kahoot", rows)
+}
+
+// WriteQuizizz writes questions as the spreadsheet Quizizz imports: question,
+// type, up to five options, the number of the correct one and the time.
+// Questions without choices or with more than five options are left out; it
+// returns how many were written.
+func WriteQuizizz(w io.Writer, questions []Question, seconds int) (int, error) {
+	rows := [][]string{{"Question Text", "Question Type", "Option 1", "Option 2", "Option 3", "Option 4", "Option 5",
+		"Correct Answer", "Time in seconds", "Image Link", "Answer explanation"}}
+	for _, q := range questions {
+		if !choosable(q) {
+			continue
+		}
+		options, correct := q.Shuffled()
+		if len(options) > 5 {
+			continue
+		}
+		row := []string{q.Text, "Multiple Choice"}
+		for i := 0; i < 5; i++ {
+			if i < len(options) {
+				row = append(row, options[i])
+			} else {
+				row = append(row, "")
+			}
+		}
+		rows = append(rows, append(row, strconv.Itoa(correct+1), strconv.Itoa(seconds), "", ""))
+	}
+	return len(rows) - 1, writeXLSX(w, "Quizizz", rows)
+}
+
+// fits reports whether every option is at most max characters
+func fits(options []string, max int) bool {
+	for _, o := range options {
+		if utf8.RuneCountInString(o) > max {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package quota
+
+// FreeBytes is not implemented on this platform; the guard skips the check
+func FreeBytes(dir string) (uint64, error) {
+	return 0, errUnsupported
+}
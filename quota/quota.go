@@ -0,0 +1,160 @@
+// Package quota keeps downloads from filling the disk they write to.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrDiskFull is returned when free space drops below the configured minimum
+	ErrDiskFull = errors.New("not enough free disk space")
+	// ErrQuotaExceeded is returned when a run has written as much as it is allowed to
+	ErrQuotaExceeded = errors.New("download quota exceeded")
+
+	errUnsupported = errors.New("free space check not supported on this platform")
+)
+
+// freeBytes is FreeBytes, which tests swap for a disk filling up and freed
+var freeBytes = FreeBytes
+
+// Guard tracks what a crawl has written and checks it against the limits
+// before each write. A zero limit disables that check.
+type Guard struct {
+	Dir      string        // folder whose filesystem is checked
+	MinFree  uint64        // bytes that must stay free on Dir's filesystem
+	MaxBytes int64         // bytes this run may write
+	MaxFiles int           // files this run may write
+	Pause    bool          // wait for space to be freed instead of aborting
+	Interval time.Duration // how often to re-check while paused
+
+	written int64
+	files   int
+}
+
+// Check returns an error if writing another file of about size bytes would
+// break one of the limits. With Pause set, it blocks until free space recovers;
+// quotas are never waited on since they cannot recover on their own.
+func (g *Guard) Check(size int64) error {
+	if g == nil {
+		return nil
+	}
+
+	if g.MaxFiles > 0 && g.files >= g.MaxFiles {
+		return fmt.Errorf("%w: %d files written (limit %d)", ErrQuotaExceeded, g.files, g.MaxFiles)
+	}
+	if g.MaxBytes > 0 && g.written+size > g.MaxBytes {
+		return fmt.Errorf("%w: %s written (limit %s)", ErrQuotaExceeded, FormatSize(uint64(g.written)), FormatSize(uint64(g.MaxBytes)))
+	}
+
+	if g.MinFree == 0 {
+		return nil
+	}
+	for {
+		free, err := freeBytes(g.Dir)
+		if errors.Is(err, errUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check free space on %s: %v", g.Dir, err)
+		}
+		if free >= g.MinFree+uint64(size) {
+			return nil
+		}
+		if !g.Pause {
+			return fmt.Errorf("%w on %s: %s free, %s required", ErrDiskFull, g.Dir, FormatSize(free), FormatSize(g.MinFree))
+		}
+
+		interval := g.Interval
+		if interval <= 0 {
+			interval = 30 * time.Second
+		}
+		slog.Warn("paused for disk space", "free", FormatSize(free), "dir", g.Dir, "need", FormatSize(g.MinFree), "recheck", interval)
+		time.Sleep(interval)
+	}
+}
+
+// Add records a file of size bytes as written
+func (g *Guard) Add(size int64) {
+	if g == nil {
+		return
+	}
+	g.written += size
+	g.files++
+}
+
+// Written returns the bytes and files recorded so far
+func (g *Guard) Written() (int64, int) {
+	if g == nil {
+		return 0, 0
+	}
+	return g.written, g.files
+}
+
+var sizeUnits = []struct {
+	suffix string
+	mult   uint64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses sizes like "500MB", "2GB" or "1048576"
+func ParseSize(s string) (uint64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid size %q", s)
+			}
+			return uint64(n * float64(u.mult)), nil
+		}
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n, nil
+}
+
+// FormatSize renders a byte count for humans, e.g. "1.5GB"
+func FormatSize(n uint64) string {
+	for _, u := range sizeUnits {
+		if n >= u.mult && u.mult > 1 {
+			return strconv.FormatFloat(float64(n)/float64(u.mult), 'f', 1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatUint(n, 10) + "B"
+}
+
+// IsLimit reports whether err comes from a guard refusing a write
+func IsLimit(err error) bool {
+	return errors.Is(err, ErrDiskFull) || errors.Is(err, ErrQuotaExceeded)
+}
+
+// WriteFile writes data next to path and renames it into place, so a full
+// disk never leaves a truncated page behind
+func WriteFile(path string, data []byte) error {
+	tmp := path + ".part"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	return nil
+}
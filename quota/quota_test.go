@@ -0,0 +1,114 @@
+package quota
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGuardLimits(t *testing.T) {
+	g := &Guard{MaxFiles: 2}
+	for i := 0; i < 2; i++ {
+		if err := g.Check(10); err != nil {
+			t.Fatalf("file %d: %v", i+1, err)
+		}
+		g.Add(10)
+	}
+	if err := g.Check(10); !errors.Is(err, ErrQuotaExceeded) || !IsLimit(err) {
+		t.Errorf("third file = %v, want ErrQuotaExceeded", err)
+	}
+
+	g = &Guard{MaxBytes: 100}
+	g.Add(60)
+	if err := g.Check(40); err != nil {
+		t.Errorf("filling the quota exactly = %v", err)
+	}
+	if err := g.Check(41); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("going over the quota = %v, want ErrQuotaExceeded", err)
+	}
+	if written, files := g.Written(); written != 60 || files != 1 {
+		t.Errorf("Written = %d, %d; want 60, 1", written, files)
+	}
+
+	var none *Guard
+	none.Add(10)
+	if err := none.Check(1 << 40); err != nil {
+		t.Errorf("nil guard = %v", err)
+	}
+}
+
+func TestGuardMinFree(t *testing.T) {
+	free := []uint64{100, 150, 1000}
+	calls := 0
+	freeBytes = func(string) (uint64, error) {
+		n := free[min(calls, len(free)-1)]
+		calls++
+		return n, nil
+	}
+	defer func() { freeBytes = FreeBytes }()
+
+	g := &Guard{Dir: t.TempDir(), MinFree: 500}
+	if err := g.Check(10); !errors.Is(err, ErrDiskFull) || !IsLimit(err) {
+		t.Errorf("without Pause = %v, want ErrDiskFull", err)
+	}
+
+	// Paused, the guard waits for space to be freed rather than failing
+	calls = 0
+	g.Pause, g.Interval = true, time.Millisecond
+	if err := g.Check(10); err != nil {
+		t.Errorf("with Pause = %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("checked free space %d times, want 3", calls)
+	}
+
+	// Quotas do not recover, so they fail even paused
+	g = &Guard{MaxFiles: 1, Pause: true}
+	g.Add(1)
+	if err := g.Check(1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("paused quota = %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]uint64{
+		"":        0,
+		"0":       0,
+		"0MB":     0,
+		"1048576": 1048576,
+		"512B":    512,
+		"500MB":   500 << 20,
+		"500mb":   500 << 20,
+		"2 gb":    2 << 30,
+		"1.5GB":   3 << 29,
+		" 1TB ":   1 << 40,
+		"1KB":     1024,
+	} {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"junk", "MB", "-1MB", "1.5", "12XB", "1,5GB"} {
+		if got, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) = %d, want an error", in, got)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for in, want := range map[uint64]string{
+		0:        "0B",
+		512:      "512B",
+		1024:     "1.0KB",
+		3 << 29:  "1.5GB",
+		5 << 40:  "5.0TB",
+		10485760: "10.0MB",
+	} {
+		if got := FormatSize(in); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", in, got, want)
+		}
+	}
+	if n, err := ParseSize(FormatSize(3 << 29)); err != nil || n != 3<<29 {
+		t.Errorf("ParseSize(FormatSize) = %d, %v", n, err)
+	}
+}
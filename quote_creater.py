@@ -1,16 +1,40 @@
+import json
+import sys
+import urllib.request
+
 from PIL import Image, ImageDraw, ImageFont
 
 quote = (
     "The truest measure of care is found in the time and effort we give; "
     "for what we invest in someone’s happiness becomes the quiet foundation of our own."
 )
+attribution = "- Generated by GitHub Copilot"
 
 # Image settings
 width, height = 1000, 340
 background_color = (245, 245, 245)
 text_color = (30, 30, 30)
+accent_color = (100, 100, 100)
 font_size = 32
 
+def hex_color(value):
+    value = value.lstrip("#")
+    return tuple(int(value[i:i + 2], 16) for i in (0, 2, 4))
+
+# Pass a quotes serve address, e.g. http://localhost:8080/quotes/random?lang=en,
+# to render a quote from the database themed after its book cover or author
+if len(sys.argv) > 1:
+    with urllib.request.urlopen(sys.argv[1]) as response:
+        data = json.load(response)
+    quote = data["text"]
+    credit = " - ".join(part for part in (data.get("author"), data.get("book")) if part)
+    attribution = "- " + credit if credit else ""
+    theme = data.get("theme")
+    if theme:
+        background_color = hex_color(theme["background"])
+        text_color = hex_color(theme["text"])
+        accent_color = hex_color(theme["accent"])
+
 # Create image
 image = Image.new("RGB", (width, height), background_color)
 draw = ImageDraw.Draw(image)
@@ -43,7 +67,7 @@ for line in lines:
     y_text += font_size + 8
 
 # Add attribution if you wish
-draw.text((50, y_text + 16), "- Generated by GitHub Copilot", fill=(100, 100, 100), font=font)
+draw.text((50, y_text + 16), attribution, fill=accent_color, font=font)
 
 # Save image
 image.save("quote_image.png")
@@ -0,0 +1,183 @@
+// Package recrawl ranks the pages quotes were read from by how much readers
+// want them read again: pages whose quotes were reported, are shown often,
+// or were parsed with low confidence. quotes recrawl score keeps the ranking
+// in the recrawlFrontier table, and quotes recrawl run fetches the top pages
+// again, so what readers see steers what is scraped next.
+package recrawl
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when reporting a quote the database does not have
+var ErrNotFound = errors.New("no such quote")
+
+// MaxReason is the longest reason a report keeps, in bytes
+const MaxReason = 500
+
+// now is the time as the tables keep it
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// Report records that a reader found quote id wrong, for the reason given
+func Report(db *sql.DB, id int64, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if len(reason) > MaxReason {
+		reason = strings.ToValidUTF8(reason[:MaxReason], "")
+	}
+	var why sql.NullString
+	if reason != "" {
+		why = sql.NullString{String: reason, Valid: true}
+	}
+	res, err := db.Exec("INSERT INTO quoteReports (quoteId, reason, reportedAt) SELECT id, ?, ? FROM quotes WHERE id = ?", why, now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to report quote %d: %v", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return nil
+}
+
+// Weights turn the signals about a page into its priority
+type Weights struct {
+	Report        float64 // per report about its quotes since it was last crawled
+	View          float64 // per doubling of the views of its quotes
+	LowConfidence float64 // per quote parsed below Confidence
+	Confidence    float64 // scores below which a quote was likely misread
+}
+
+// DefaultWeights make a report weigh about as much as a thousand views, or
+// three doubtful quotes
+var DefaultWeights = Weights{Report: 10, View: 1, LowConfidence: 3, Confidence: 0.6}
+
+// Priority is what w makes of the signals of a page
+func (w Weights) Priority(reports, views, lowConfidence int) float64 {
+	return w.Report*float64(reports) + w.View*math.Log2(1+float64(views)) + w.LowConfidence*float64(lowConfidence)
+}
+
+// Page is a page in the frontier
+type Page struct {
+	URL           string  `json:"url"`
+	Source        string  `json:"source"`
+	Priority      float64 `json:"priority"`
+	Reports       int     `json:"reports"`
+	Views         int     `json:"views"`
+	LowConfidence int     `json:"lowConfidence"`
+	ScoredAt      string  `json:"scoredAt"`
+	CrawledAt     string  `json:"crawledAt,omitempty"`
+}
+
+// Score ranks every page quotes were read from by its signals, replacing
+// the priorities in the frontier, and returns how many pages have one. The
+// crawl times of pages already in the frontier are kept.
+func Score(db *sql.DB, w Weights) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Reports count only when made after the page was crawled again, as
+	// those before are what the crawl answered
+	rows, err := tx.Query(`SELECT q.sourceUrl, MIN(src.name), SUM(COALESCE(q.viewCount, 0)),
+		SUM(CASE WHEN q.confidence < ? THEN 1 ELSE 0 END),
+		(SELECT COUNT(*) FROM quoteReports r JOIN quotes rq ON rq.id = r.quoteId
+			WHERE rq.sourceUrl = q.sourceUrl AND r.reportedAt > COALESCE(f.crawledAt, ''))
+		FROM quotes q
+		JOIN sources src ON src.id = q.sourceId
+		LEFT JOIN recrawlFrontier f ON f.url = q.sourceUrl
+		WHERE q.sourceUrl LIKE 'http%'
+		GROUP BY q.sourceUrl`, w.Confidence)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the signals of pages: %v", err)
+	}
+	var pages []Page
+	for rows.Next() {
+		var p Page
+		if err := rows.Scan(&p.URL, &p.Source, &p.Views, &p.LowConfidence, &p.Reports); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read the signals of pages: %v", err)
+		}
+		if p.Priority = w.Priority(p.Reports, p.Views, p.LowConfidence); p.Priority > 0 {
+			pages = append(pages, p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read the signals of pages: %v", err)
+	}
+
+	// Pages whose signals are gone keep their crawl time, at no priority
+	at := now()
+	if _, err := tx.Exec("UPDATE recrawlFrontier SET priority = 0, reports = 0, views = 0, lowConfidence = 0, scoredAt = ?", at); err != nil {
+		return 0, fmt.Errorf("failed to reset priorities: %v", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO recrawlFrontier (url, source, priority, reports, views, lowConfidence, scoredAt) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(url) DO UPDATE SET source = excluded.source, priority = excluded.priority, reports = excluded.reports,
+		views = excluded.views, lowConfidence = excluded.lowConfidence, scoredAt = excluded.scoredAt`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+	for _, p := range pages {
+		if _, err := stmt.Exec(p.URL, p.Source, p.Priority, p.Reports, p.Views, p.LowConfidence, at); err != nil {
+			return 0, fmt.Errorf("failed to rank %s: %v", p.URL, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return len(pages), nil
+}
+
+const columns = "url, source, priority, reports, views, lowConfidence, scoredAt, COALESCE(crawledAt, '')"
+
+// Next returns up to limit pages to crawl, highest priority first: those
+// with a priority never crawled, or crawled longer than minAge ago. A page
+// with reports since its crawl is due at once.
+func Next(db *sql.DB, limit int, minAge time.Duration) ([]Page, error) {
+	before := time.Now().Add(-minAge).UTC().Format(time.RFC3339)
+	return list(db, `WHERE priority > 0 AND (crawledAt IS NULL OR crawledAt <= ? OR reports > 0)
+		ORDER BY priority DESC, url LIMIT ?`, before, limit)
+}
+
+// List returns the top limit pages of the frontier, whether due or not
+func List(db *sql.DB, limit int) ([]Page, error) {
+	return list(db, "WHERE priority > 0 ORDER BY priority DESC, url LIMIT ?", limit)
+}
+
+func list(db *sql.DB, where string, args ...interface{}) ([]Page, error) {
+	rows, err := db.Query("SELECT "+columns+" FROM recrawlFrontier "+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the frontier: %v", err)
+	}
+	defer rows.Close()
+	pages := []Page{}
+	for rows.Next() {
+		var p Page
+		if err := rows.Scan(&p.URL, &p.Source, &p.Priority, &p.Reports, &p.Views, &p.LowConfidence, &p.ScoredAt, &p.CrawledAt); err != nil {
+			return nil, fmt.Errorf("failed to read the frontier: %v", err)
+		}
+		pages = append(pages, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the frontier: %v", err)
+	}
+	return pages, nil
+}
+
+// Crawled records that url was fetched again, which answers the reports
+// about it so far
+func Crawled(db *sql.DB, url string) error {
+	if _, err := db.Exec("UPDATE recrawlFrontier SET crawledAt = ?, reports = 0 WHERE url = ?", now(), url); err != nil {
+		return fmt.Errorf("failed to record the crawl of %s: %v", url, err)
+	}
+	return nil
+}
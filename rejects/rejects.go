@@ -0,0 +1,98 @@
+// Package rejects collects what an ingest run could not use: trivia lines
+// it skipped, fun fact files and pages it could not parse, and quotes a
+// filter or policy rejected. The report is saved as JSON, one entry per
+// rejected record with its file, line and reason, to fix the source data by.
+package rejects
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Kind is what was rejected
+type Kind string
+
+const (
+	TriviaLine  Kind = "trivia-line"
+	FunFactFile Kind = "fun-fact-file"
+	File        Kind = "file" // a page or other file of quotes
+	Quote       Kind = "quote"
+)
+
+// Reject is a record a run could not use
+type Reject struct {
+	Kind   Kind   `json:"kind"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"` // 1-based, when the file has lines
+	Text   string `json:"text,omitempty"` // the line, or the quote
+	Reason string `json:"reason"`
+}
+
+// Report is the rejects of a run. It is safe for concurrent use, and a nil
+// Report takes and saves nothing, for runs without one.
+type Report struct {
+	path string
+
+	mu       sync.Mutex
+	Command  string       `json:"command"`
+	Started  time.Time    `json:"started"`
+	Finished time.Time    `json:"finished"`
+	Counts   map[Kind]int `json:"counts"`
+	Rejects  []Reject     `json:"rejects"`
+}
+
+// New starts the report of a run of command, to be saved to path
+func New(path, command string) *Report {
+	return &Report{path: path, Command: command, Started: time.Now().UTC(), Counts: map[Kind]int{}, Rejects: []Reject{}}
+}
+
+// Add records a reject
+func (r *Report) Add(x Reject) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Rejects = append(r.Rejects, x)
+	r.Counts[x.Kind]++
+}
+
+// Len returns how many records were rejected
+func (r *Report) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.Rejects)
+}
+
+// Path returns where the report is saved
+func (r *Report) Path() string {
+	if r == nil {
+		return ""
+	}
+	return r.path
+}
+
+// Save writes the report to its path as indented JSON, replacing the
+// report of an earlier run
+func (r *Report) Save() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Finished = time.Now().UTC()
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %v", err)
+	}
+	if err := os.WriteFile(r.path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+	return nil
+}
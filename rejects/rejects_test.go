@@ -0,0 +1,49 @@
+package rejects
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	r := New(path, "processTrivia")
+	r.Add(Reject{Kind: TriviaLine, File: "trivia.txt", Line: 3, Text: "{Tarih},{Soru}", Reason: "not enough columns (2)"})
+	r.Add(Reject{Kind: Quote, File: "page1.txt", Text: "Az olsun, öz olsun.", Reason: "missing book"})
+	r.Add(Reject{Kind: Quote, File: "page2.txt", Text: "Sabır acıdır.", Reason: "missing book"})
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		Command string
+		Counts  map[Kind]int
+		Rejects []Reject
+	}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Command != "processTrivia" || len(saved.Rejects) != 3 || saved.Rejects[0].Line != 3 {
+		t.Errorf("saved %+v", saved)
+	}
+	if saved.Counts[TriviaLine] != 1 || saved.Counts[Quote] != 2 {
+		t.Errorf("counts = %v", saved.Counts)
+	}
+}
+
+func TestNil(t *testing.T) {
+	var r *Report
+	r.Add(Reject{Kind: File, Reason: "unreadable"})
+	if r.Len() != 0 {
+		t.Errorf("nil report took a reject")
+	}
+	if err := r.Save(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,485 @@
+package render
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// DefaultFonts are tried in order when no font is given: serif faces that
+// cover Turkish and Spanish on Debian/Raspberry Pi OS, Fedora, macOS and
+// Windows
+var DefaultFonts = []string{
+	"/usr/share/fonts/truetype/dejavu/DejaVuSerif.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSerif.ttf",
+	"/usr/share/fonts/truetype/liberation/LiberationSerif-Regular.ttf",
+	"/usr/share/fonts/liberation/LiberationSerif-Regular.ttf",
+	"/Library/Fonts/Georgia.ttf",
+	"/System/Library/Fonts/Supplemental/Georgia.ttf",
+	`C:\Windows\Fonts\georgia.ttf`,
+}
+
+// ErrNoFont is returned by FindFont when none of DefaultFonts is installed
+var ErrNoFont = errors.New("no font found; pass one with --font")
+
+// FindFont loads path, or the first of DefaultFonts installed when path is
+// empty
+func FindFont(path string) (*Font, error) {
+	if path != "" {
+		return LoadFont(path)
+	}
+	for _, p := range DefaultFonts {
+		if _, err := os.Stat(p); err == nil {
+			return LoadFont(p)
+		}
+	}
+	return nil, ErrNoFont
+}
+
+// LoadFont reads a TrueType font file
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font: %v", err)
+	}
+	f, err := ParseFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return f, nil
+}
+
+// Font is a TrueType font (glyf outlines, not CFF). Only what a card needs is
+// read: character map, advances and outlines; there is no kerning or shaping,
+// and combining marks are drawn over the letter before them.
+type Font struct {
+	Name       string // family name, for SVG
+	unitsPerEm float64
+	ascent     float64 // font units above the baseline
+	descent    float64 // font units below it, negative
+	lineGap    float64
+
+	cmap    func(r rune) uint16
+	hmetric []uint16 // advance widths, the last repeating for later glyphs
+	loca    []uint32 // glyph offsets into glyf
+	glyf    []byte
+}
+
+// ParseFont reads a TrueType font
+func ParseFont(data []byte) (*Font, error) {
+	if len(data) < 12 {
+		return nil, errors.New("not a font")
+	}
+	switch tag := string(data[:4]); tag {
+	case "\x00\x01\x00\x00", "true":
+	case "OTTO":
+		return nil, errors.New("CFF (OpenType/PostScript) fonts are not supported; use a TrueType one")
+	default:
+		return nil, errors.New("not a TrueType font")
+	}
+
+	tables := make(map[string][]byte)
+	n := int(u16(data, 4))
+	for i := 0; i < n; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return nil, errors.New("truncated table directory")
+		}
+		off, length := int(u32(data, rec+8)), int(u32(data, rec+12))
+		if off+length > len(data) {
+			return nil, fmt.Errorf("truncated %s table", data[rec:rec+4])
+		}
+		tables[string(data[rec:rec+4])] = data[off : off+length]
+	}
+	for _, name := range []string{"cmap", "head", "hhea", "hmtx", "loca", "glyf", "maxp"} {
+		if tables[name] == nil {
+			return nil, fmt.Errorf("missing %s table", name)
+		}
+	}
+
+	f := &Font{glyf: tables["glyf"]}
+	head, hhea, maxp := tables["head"], tables["hhea"], tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, errors.New("truncated header tables")
+	}
+	f.unitsPerEm = float64(u16(head, 18))
+	f.ascent = float64(int16(u16(hhea, 4)))
+	f.descent = float64(int16(u16(hhea, 6)))
+	f.lineGap = float64(int16(u16(hhea, 8)))
+	numGlyphs := int(u16(maxp, 4))
+
+	hmtx := tables["hmtx"]
+	numMetrics := int(u16(hhea, 34))
+	if numMetrics == 0 || len(hmtx) < 4*numMetrics {
+		return nil, errors.New("bad hmtx table")
+	}
+	f.hmetric = make([]uint16, numMetrics)
+	for i := range f.hmetric {
+		f.hmetric[i] = u16(hmtx, 4*i)
+	}
+
+	loca := tables["loca"]
+	f.loca = make([]uint32, numGlyphs+1)
+	long := int16(u16(head, 50)) == 1
+	for i := range f.loca {
+		switch {
+		case long && 4*i+4 <= len(loca):
+			f.loca[i] = u32(loca, 4*i)
+		case !long && 2*i+2 <= len(loca):
+			f.loca[i] = 2 * uint32(u16(loca, 2*i))
+		default:
+			return nil, errors.New("truncated loca table")
+		}
+	}
+
+	var err error
+	if f.cmap, err = parseCmap(tables["cmap"]); err != nil {
+		return nil, err
+	}
+	if name := tables["name"]; name != nil {
+		f.Name = familyName(name)
+	}
+	return f, nil
+}
+
+func u16(b []byte, i int) uint16 { return binary.BigEndian.Uint16(b[i:]) }
+func u32(b []byte, i int) uint32 { return binary.BigEndian.Uint32(b[i:]) }
+
+// parseCmap returns the Unicode character map, preferring the full
+// (format 12) one to the Basic Multilingual Plane (format 4) one
+func parseCmap(cmap []byte) (func(rune) uint16, error) {
+	if len(cmap) < 4 {
+		return nil, errors.New("bad cmap table")
+	}
+	var bmp, full []byte
+	for i := 0; i < int(u16(cmap, 2)); i++ {
+		rec := 4 + 8*i
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform, encoding, off := u16(cmap, rec), u16(cmap, rec+2), int(u32(cmap, rec+4))
+		if off+4 > len(cmap) {
+			continue
+		}
+		sub := cmap[off:]
+		unicode := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		switch format := u16(sub, 0); {
+		case unicode && format == 12:
+			full = sub
+		case unicode && format == 4:
+			bmp = sub
+		}
+	}
+
+	switch {
+	case full != nil && len(full) >= 16:
+		groups := int(u32(full, 12))
+		if 16+12*groups > len(full) {
+			return nil, errors.New("truncated cmap")
+		}
+		return func(r rune) uint16 {
+			lo, hi := 0, groups
+			for lo < hi {
+				m := (lo + hi) / 2
+				g := 16 + 12*m
+				start, end := rune(u32(full, g)), rune(u32(full, g+4))
+				switch {
+				case r < start:
+					hi = m
+				case r > end:
+					lo = m + 1
+				default:
+					return uint16(u32(full, g+8) + uint32(r-start))
+				}
+			}
+			return 0
+		}, nil
+	case bmp != nil && len(bmp) >= 14:
+		segs := int(u16(bmp, 6)) / 2
+		if 16+8*segs > len(bmp) {
+			return nil, errors.New("truncated cmap")
+		}
+		ends, starts, deltas, ranges := 14, 16+2*segs, 16+4*segs, 16+6*segs
+		return func(r rune) uint16 {
+			if r > 0xFFFF {
+				return 0
+			}
+			c := uint16(r)
+			for s := 0; s < segs; s++ {
+				if c > u16(bmp, ends+2*s) {
+					continue
+				}
+				if c < u16(bmp, starts+2*s) {
+					return 0
+				}
+				delta := u16(bmp, deltas+2*s)
+				rangeOff := int(u16(bmp, ranges+2*s))
+				if rangeOff == 0 {
+					return c + delta
+				}
+				at := ranges + 2*s + rangeOff + 2*int(c-u16(bmp, starts+2*s))
+				if at+2 > len(bmp) {
+					return 0
+				}
+				if g := u16(bmp, at); g != 0 {
+					return g + delta
+				}
+				return 0
+			}
+			return 0
+		}, nil
+	}
+	return nil, errors.New("no Unicode character map")
+}
+
+// familyName reads the font family (name id 1) from the name table
+func familyName(name []byte) string {
+	if len(name) < 6 {
+		return ""
+	}
+	count, strings := int(u16(name, 2)), int(u16(name, 4))
+	for i := 0; i < count; i++ {
+		rec := 6 + 12*i
+		if rec+12 > len(name) {
+			break
+		}
+		platform, id := u16(name, rec), u16(name, rec+6)
+		length, off := int(u16(name, rec+8)), strings+int(u16(name, rec+10))
+		if id != 1 || off+length > len(name) {
+			continue
+		}
+		raw := name[off : off+length]
+		if platform == 1 {
+			return string(raw) // Mac Roman; family names are ASCII in practice
+		}
+		var runes []rune
+		for j := 0; j+1 < len(raw); j += 2 {
+			runes = append(runes, rune(u16(raw, j)))
+		}
+		return string(runes)
+	}
+	return ""
+}
+
+// glyph returns the glyph index of r, 0 (the missing glyph box) if the font
+// lacks it
+func (f *Font) glyph(r rune) uint16 {
+	return f.cmap(r)
+}
+
+// advance returns the advance width of glyph g in font units
+func (f *Font) advance(g uint16) float64 {
+	if int(g) < len(f.hmetric) {
+		return float64(f.hmetric[g])
+	}
+	return float64(f.hmetric[len(f.hmetric)-1])
+}
+
+// Advance returns how far the pen moves after drawing r at size pixels per em
+func (f *Font) Advance(r rune, size float64) float64 {
+	return f.advance(f.glyph(r)) * size / f.unitsPerEm
+}
+
+// Measure returns the width of text at size pixels per em. Combining marks
+// take no room.
+func (f *Font) Measure(text string, size float64) float64 {
+	w := 0.0
+	for _, r := range text {
+		if !isMark(r) {
+			w += f.Advance(r, size)
+		}
+	}
+	return w
+}
+
+// Metrics returns the ascent, descent (positive, below the baseline) and
+// line height of the font at size pixels per em
+func (f *Font) Metrics(size float64) (ascent, descent, lineHeight float64) {
+	scale := size / f.unitsPerEm
+	return f.ascent * scale, -f.descent * scale, (f.ascent - f.descent + f.lineGap) * scale
+}
+
+// point is a point of an outline, in font units
+type point struct {
+	x, y    float64
+	onCurve bool
+}
+
+// outline returns the contours of glyph g, with composite glyphs resolved.
+// depth guards against composite glyphs that include themselves.
+func (f *Font) outline(g uint16, depth int) ([][]point, error) {
+	if int(g)+1 >= len(f.loca) || depth > 8 {
+		return nil, nil
+	}
+	start, end := f.loca[g], f.loca[g+1]
+	if start >= end {
+		return nil, nil // blank, e.g. space
+	}
+	if int(end) > len(f.glyf) || end-start < 10 {
+		return nil, errors.New("bad glyph offset")
+	}
+	data := f.glyf[start:end]
+	contours := int(int16(u16(data, 0)))
+	if contours < 0 {
+		return f.composite(data, depth)
+	}
+	return simpleGlyph(data, contours)
+}
+
+// simpleGlyph decodes the contours of a glyph drawn directly
+func simpleGlyph(data []byte, contours int) ([][]point, error) {
+	bad := errors.New("bad glyph")
+	i := 10
+	if i+2*contours+2 > len(data) {
+		return nil, bad
+	}
+	ends := make([]int, contours)
+	for c := range ends {
+		ends[c] = int(u16(data, i))
+		i += 2
+	}
+	if contours == 0 {
+		return nil, nil
+	}
+	n := ends[contours-1] + 1
+	i += 2 + int(u16(data, i)) // skip the hinting instructions
+
+	flags := make([]byte, 0, n)
+	for len(flags) < n {
+		if i >= len(data) {
+			return nil, bad
+		}
+		flag := data[i]
+		i++
+		flags = append(flags, flag)
+		if flag&8 != 0 { // repeated
+			if i >= len(data) {
+				return nil, bad
+			}
+			for r := 0; r < int(data[i]) && len(flags) < n; r++ {
+				flags = append(flags, flag)
+			}
+			i++
+		}
+	}
+
+	points := make([]point, n)
+	coords := func(short, same byte, set func(p *point, v float64)) error {
+		v := 0
+		for p, flag := range flags {
+			switch {
+			case flag&short != 0:
+				if i >= len(data) {
+					return bad
+				}
+				d := int(data[i])
+				i++
+				if flag&same == 0 {
+					d = -d
+				}
+				v += d
+			case flag&same == 0:
+				if i+2 > len(data) {
+					return bad
+				}
+				v += int(int16(u16(data, i)))
+				i += 2
+			}
+			set(&points[p], float64(v))
+		}
+		return nil
+	}
+	if err := coords(2, 16, func(p *point, v float64) { p.x = v }); err != nil {
+		return nil, err
+	}
+	if err := coords(4, 32, func(p *point, v float64) { p.y = v }); err != nil {
+		return nil, err
+	}
+
+	out := make([][]point, 0, contours)
+	from := 0
+	for _, end := range ends {
+		if end < from || end >= n {
+			return nil, bad
+		}
+		contour := points[from : end+1]
+		for k := range contour {
+			contour[k].onCurve = flags[from+k]&1 != 0
+		}
+		out = append(out, contour)
+		from = end + 1
+	}
+	return out, nil
+}
+
+// composite decodes a glyph made of other glyphs, each moved and scaled
+func (f *Font) composite(data []byte, depth int) ([][]point, error) {
+	bad := errors.New("bad composite glyph")
+	var out [][]point
+	i := 10
+	for {
+		if i+4 > len(data) {
+			return nil, bad
+		}
+		flags, g := u16(data, i), u16(data, i+2)
+		i += 4
+
+		var dx, dy float64
+		if flags&1 != 0 { // arguments are words
+			if i+4 > len(data) {
+				return nil, bad
+			}
+			dx, dy = float64(int16(u16(data, i))), float64(int16(u16(data, i+2)))
+			i += 4
+		} else {
+			if i+2 > len(data) {
+				return nil, bad
+			}
+			dx, dy = float64(int8(data[i])), float64(int8(data[i+1]))
+			i += 2
+		}
+		if flags&2 == 0 {
+			dx, dy = 0, 0 // anchored by point numbers, which no card font needs
+		}
+
+		a, b, c, d := 1.0, 0.0, 0.0, 1.0
+		f2dot14 := func(at int) float64 { return float64(int16(u16(data, at))) / 16384 }
+		switch {
+		case flags&8 != 0:
+			if i+2 > len(data) {
+				return nil, bad
+			}
+			a = f2dot14(i)
+			d = a
+			i += 2
+		case flags&0x40 != 0:
+			if i+4 > len(data) {
+				return nil, bad
+			}
+			a, d = f2dot14(i), f2dot14(i+2)
+			i += 4
+		case flags&0x80 != 0:
+			if i+8 > len(data) {
+				return nil, bad
+			}
+			a, b, c, d = f2dot14(i), f2dot14(i+2), f2dot14(i+4), f2dot14(i+6)
+			i += 8
+		}
+
+		parts, err := f.outline(g, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		for _, contour := range parts {
+			moved := make([]point, len(contour))
+			for k, p := range contour {
+				moved[k] = point{x: a*p.x + c*p.y + dx, y: b*p.x + d*p.y + dy, onCurve: p.onCurve}
+			}
+			out = append(out, moved)
+		}
+		if flags&0x20 == 0 { // no more components
+			return out, nil
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"unicode"
+)
+
+// rasterizer accumulates the signed area each outline edge covers in every
+// pixel, so a running sum along a row gives the pixel's coverage: exact
+// antialiasing without supersampling
+type rasterizer struct {
+	w, h int
+	acc  []float32
+}
+
+func newRasterizer(w, h int) *rasterizer {
+	return &rasterizer{w: w, h: h, acc: make([]float32, w*h+4)}
+}
+
+// line adds the edge from (x0, y0) to (x1, y1), in pixels from the top left
+func (r *rasterizer) line(x0, y0, x1, y1 float64) {
+	if y0 == y1 {
+		return
+	}
+	dir := float32(1)
+	if y0 > y1 {
+		dir = -1
+		x0, y0, x1, y1 = x1, y1, x0, y0
+	}
+	dxdy := (x1 - x0) / (y1 - y0)
+	x := x0
+	if y0 < 0 {
+		x -= y0 * dxdy
+	}
+	for y := max(int(y0), 0); y < r.h && float64(y) < y1; y++ {
+		row := y * r.w
+		dy := math.Min(float64(y+1), y1) - math.Max(float64(y), y0)
+		xnext := x + dxdy*dy
+		d := float32(dy) * dir
+		xa, xb := x, xnext
+		if xa > xb {
+			xa, xb = xb, xa
+		}
+		xaFloor := math.Floor(xa)
+		ia, ib := int(xaFloor), int(math.Ceil(xb))
+		if ia < 0 || ib >= r.w {
+			x = xnext
+			continue // glyph boxes are padded, so only bad outlines get here
+		}
+		if ib <= ia+1 {
+			xm := float32(0.5*(x+xnext) - xaFloor)
+			r.acc[row+ia] += d - d*xm
+			r.acc[row+ia+1] += d * xm
+		} else {
+			s := float32(1 / (xb - xa))
+			fa := float32(xa - xaFloor)
+			a0 := 0.5 * s * (1 - fa) * (1 - fa)
+			fb := float32(xb - math.Ceil(xb) + 1)
+			am := 0.5 * s * fb * fb
+			r.acc[row+ia] += d * a0
+			if ib == ia+2 {
+				r.acc[row+ia+1] += d * (1 - a0 - am)
+			} else {
+				a1 := s * (1.5 - fa)
+				r.acc[row+ia+1] += d * (a1 - a0)
+				for i := ia + 2; i < ib-1; i++ {
+					r.acc[row+i] += d * s
+				}
+				a2 := a1 + float32(ib-ia-3)*s
+				r.acc[row+ib-1] += d * (1 - a2 - am)
+			}
+			r.acc[row+ib] += d * am
+		}
+		x = xnext
+	}
+}
+
+// quad adds a quadratic Bézier edge, flattened into lines close enough that
+// the difference is under a third of a pixel
+func (r *rasterizer) quad(x0, y0, cx, cy, x1, y1 float64) {
+	ddx, ddy := x0-2*cx+x1, y0-2*cy+y1
+	dev := ddx*ddx + ddy*ddy
+	if dev < 0.333 {
+		r.line(x0, y0, x1, y1)
+		return
+	}
+	n := int(1 + math.Floor(math.Sqrt(math.Sqrt(3*dev))))
+	px, py := x0, y0
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		nx := u*u*x0 + 2*u*t*cx + t*t*x1
+		ny := u*u*y0 + 2*u*t*cy + t*t*y1
+		r.line(px, py, nx, ny)
+		px, py = nx, ny
+	}
+}
+
+// mask returns the coverage of every pixel
+func (r *rasterizer) mask() *image.Alpha {
+	m := image.NewAlpha(image.Rect(0, 0, r.w, r.h))
+	var sum float32
+	for i := 0; i < r.w*r.h; i++ {
+		sum += r.acc[i]
+		a := sum
+		if a < 0 {
+			a = -a
+		}
+		if a > 1 {
+			a = 1
+		}
+		m.Pix[i] = uint8(a*255 + 0.5)
+	}
+	return m
+}
+
+// isMark reports whether r is a combining mark, drawn over the letter before
+// it rather than after it
+func isMark(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me)
+}
+
+// DrawString draws text onto dst in c with its baseline starting at (x, y),
+// size pixels per em, and returns the x the pen ends at
+func (f *Font) DrawString(dst draw.Image, text string, x, y, size float64, c color.Color) float64 {
+	src := image.NewUniform(c)
+	for _, r := range text {
+		g := f.glyph(r)
+		f.drawGlyph(dst, src, g, x, y, size)
+		// Combining marks are drawn to the left of their origin, over the
+		// letter before them, and take no room of their own
+		if !isMark(r) {
+			x += f.advance(g) * size / f.unitsPerEm
+		}
+	}
+	return x
+}
+
+// drawGlyph rasterizes glyph g with its origin at (x, y) and composites it
+func (f *Font) drawGlyph(dst draw.Image, src image.Image, g uint16, x, y, size float64) {
+	contours, err := f.outline(g, 0)
+	if err != nil || len(contours) == 0 {
+		return
+	}
+	scale := size / f.unitsPerEm
+
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range contours {
+		for _, p := range c {
+			px, py := x+p.x*scale, y-p.y*scale
+			minX, maxX = math.Min(minX, px), math.Max(maxX, px)
+			minY, maxY = math.Min(minY, py), math.Max(maxY, py)
+		}
+	}
+	// Pad the box a pixel so every edge lands inside the rasterizer
+	box := image.Rect(int(math.Floor(minX))-1, int(math.Floor(minY))-1, int(math.Ceil(maxX))+2, int(math.Ceil(maxY))+2)
+	if !box.Overlaps(dst.Bounds()) {
+		return
+	}
+	r := newRasterizer(box.Dx(), box.Dy())
+	ox, oy := x-float64(box.Min.X), y-float64(box.Min.Y)
+	at := func(p point) (float64, float64) { return ox + p.x*scale, oy - p.y*scale }
+
+	for _, c := range contours {
+		n := len(c)
+		if n < 2 {
+			continue
+		}
+		// Start on a point on the curve; between two off-curve points lies
+		// an implied one halfway
+		start := -1
+		for i, p := range c {
+			if p.onCurve {
+				start = i
+				break
+			}
+		}
+		var sx, sy float64
+		var rest []point
+		if start < 0 {
+			sx, sy = at(point{x: (c[0].x + c[n-1].x) / 2, y: (c[0].y + c[n-1].y) / 2})
+			rest = c
+		} else {
+			sx, sy = at(c[start])
+			rest = append(append([]point{}, c[start+1:]...), c[:start]...)
+		}
+		px, py := sx, sy
+		var cx, cy float64
+		pending := false
+		for _, p := range rest {
+			qx, qy := at(p)
+			switch {
+			case p.onCurve && pending:
+				r.quad(px, py, cx, cy, qx, qy)
+				px, py, pending = qx, qy, false
+			case p.onCurve:
+				r.line(px, py, qx, qy)
+				px, py = qx, qy
+			case pending:
+				mx, my := (cx+qx)/2, (cy+qy)/2
+				r.quad(px, py, cx, cy, mx, my)
+				px, py, cx, cy = mx, my, qx, qy
+			default:
+				cx, cy, pending = qx, qy, true
+			}
+		}
+		if pending {
+			r.quad(px, py, cx, cy, sx, sy)
+		} else {
+			r.line(px, py, sx, sy)
+		}
+	}
+
+	draw.DrawMask(dst, box, src, image.Point{}, r.mask(), image.Point{}, draw.Over)
+}
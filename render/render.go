@@ -0,0 +1,228 @@
+// Package render draws a quote and its author as a card for a picture frame
+// or e-ink display: a PNG, optionally dithered to pure black and white, or an
+// SVG. Text is fitted to the card by layout and drawn with a TrueType font.
+package render
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"time"
+
+	"quotesparser/layout"
+	"quotesparser/locale"
+	"quotesparser/palette"
+)
+
+const (
+	// DefaultWidth and DefaultHeight fit the common 7.5" e-ink panels
+	DefaultWidth  = 800
+	DefaultHeight = 480
+	// MaxSide caps the width and height, so a request cannot ask for an
+	// image that takes all memory
+	MaxSide = 4096
+)
+
+// Card is the text to draw
+type Card struct {
+	Text   string
+	Author string // the language's word for anonymous when empty
+	Book   string
+	Lang   string // for line breaking, hyphenation and the attribution
+	// Date is shown after the attribution when set, as on the card of a
+	// day's quote
+	Date time.Time
+}
+
+// attribution is the line under the quote, as its language writes it
+func (c Card) attribution() string {
+	by := locale.Attribution(c.Author, c.Book, c.Lang)
+	if !c.Date.IsZero() {
+		by += " · " + locale.Date(c.Date, c.Lang)
+	}
+	return by
+}
+
+// Options sets how a card is drawn. The zero value draws black on white at
+// DefaultWidth by DefaultHeight.
+type Options struct {
+	Width, Height int
+	Margin        int // pixels on every side; 0 for a twentieth of the width
+	// Size is the quote's text size in pixels per em, 0 for a tenth of the
+	// height. Quotes too long for it shrink down to MinSize, 0 for a
+	// fortieth of the height but at least 12, and are cut below that.
+	Size, MinSize float64
+	// Theme colors the card, e.g. after the cover of the quote's book; nil
+	// for black on white
+	Theme *palette.Theme
+	// Dither reduces the PNG to pure black and white with Floyd–Steinberg
+	// error diffusion, for 1-bit e-ink panels
+	Dither bool
+}
+
+// Check reports whether the options can draw a card, e.g. before picking a
+// quote to draw
+func (o Options) Check() error {
+	_, err := o.withDefaults()
+	return err
+}
+
+func (o Options) withDefaults() (Options, error) {
+	if o.Width == 0 {
+		o.Width = DefaultWidth
+	}
+	if o.Height == 0 {
+		o.Height = DefaultHeight
+	}
+	if o.Width < 0 || o.Height < 0 || o.Width > MaxSide || o.Height > MaxSide {
+		return o, fmt.Errorf("size %dx%d out of range, at most %d on a side", o.Width, o.Height, MaxSide)
+	}
+	if o.Margin == 0 {
+		o.Margin = o.Width / 20
+	}
+	if o.Size == 0 {
+		o.Size = float64(o.Height) / 10
+	}
+	if o.MinSize == 0 {
+		o.MinSize = min(o.Size, max(12, float64(o.Height)/40))
+	}
+	if o.Margin < 0 || 2*o.Margin >= min(o.Width, o.Height) {
+		return o, fmt.Errorf("margin %d does not fit a %dx%d card", o.Margin, o.Width, o.Height)
+	}
+	if o.Size < 1 || o.MinSize < 1 {
+		return o, errors.New("font size must be positive")
+	}
+	if o.MinSize > o.Size {
+		return o, fmt.Errorf("smallest font size %g is over the largest, %g", o.MinSize, o.Size)
+	}
+	if o.Theme == nil {
+		o.Theme = &palette.Theme{
+			Background: color.RGBA{255, 255, 255, 255},
+			Text:       color.RGBA{0, 0, 0, 255},
+			Accent:     color.RGBA{0, 0, 0, 255},
+		}
+	}
+	return o, nil
+}
+
+// fit lays the card out in o's box, shrinking its text as needed
+func fit(f *Font, c Card, o Options) layout.Result {
+	return layout.Fit(f, layout.Block{Text: c.Text, Attribution: c.attribution(), Lang: c.Lang}, layout.Box{
+		Width:   float64(o.Width),
+		Height:  float64(o.Height),
+		Margin:  float64(o.Margin),
+		MaxSize: o.Size,
+		MinSize: o.MinSize,
+	})
+}
+
+// Capacity estimates how many characters of a quote fit a card drawn with o
+// at its smallest size, for picking quotes that will not be cut
+func Capacity(f *Font, o Options) int {
+	o, err := o.withDefaults()
+	if err != nil {
+		return 0
+	}
+	return layout.Capacity(f, layout.Box{Width: float64(o.Width), Height: float64(o.Height), Margin: float64(o.Margin)}, o.MinSize)
+}
+
+// Image draws the card
+func Image(f *Font, c Card, o Options) (image.Image, error) {
+	o, err := o.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, o.Width, o.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(o.Theme.Background), image.Point{}, draw.Src)
+	for _, l := range fit(f, c, o).Lines {
+		ink := o.Theme.Text
+		if l.Attribution {
+			ink = o.Theme.Accent
+		}
+		f.DrawString(img, l.Text, l.X, l.Y, l.Size, ink)
+	}
+	if o.Dither {
+		return dither(img), nil
+	}
+	return img, nil
+}
+
+// PNG draws the card as a PNG
+func PNG(w io.Writer, f *Font, c Card, o Options) error {
+	img, err := Image(f, c, o)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// SVG writes the card as an SVG, its lines broken with f's metrics and set
+// in f's family so it looks the same where that font is installed
+func SVG(w io.Writer, f *Font, c Card, o Options) error {
+	o, err := o.withDefaults()
+	if err != nil {
+		return err
+	}
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", o.Width, o.Height, o.Width, o.Height)
+	fmt.Fprintf(b, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", palette.Hex(o.Theme.Background))
+	family := "serif"
+	if f.Name != "" {
+		family = fmt.Sprintf("'%s', serif", f.Name)
+	}
+	fmt.Fprintf(b, "<g font-family=\"%s\">\n", html.EscapeString(family))
+	for _, l := range fit(f, c, o).Lines {
+		ink := o.Theme.Text
+		if l.Attribution {
+			ink = o.Theme.Accent
+		}
+		fmt.Fprintf(b, "<text x=\"%.1f\" y=\"%.1f\" font-size=\"%.1f\" fill=\"%s\" xml:space=\"preserve\">%s</text>\n",
+			l.X, l.Y, l.Size, palette.Hex(ink), html.EscapeString(l.Text))
+	}
+	b.WriteString("</g>\n</svg>\n")
+	return b.Flush()
+}
+
+// dither reduces img to black and white, spreading each pixel's error over
+// its unvisited neighbours (Floyd–Steinberg), so grays and antialiased edges
+// survive on a 1-bit panel
+func dither(img *image.RGBA) *image.Paletted {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	out := image.NewPaletted(bounds, color.Palette{color.Black, color.White})
+
+	gray := make([]float32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+			gray[y*w+x] = 0.299*float32(c.R) + 0.587*float32(c.G) + 0.114*float32(c.B)
+		}
+	}
+	spread := func(x, y int, e float32) {
+		if x >= 0 && x < w && y < h {
+			gray[y*w+x] += e
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			old := gray[y*w+x]
+			var v float32
+			if old >= 128 {
+				v = 255
+				out.Pix[y*out.Stride+x] = 1
+			}
+			e := old - v
+			spread(x+1, y, e*7/16)
+			spread(x-1, y+1, e*3/16)
+			spread(x, y+1, e*5/16)
+			spread(x+1, y+1, e*1/16)
+		}
+	}
+	return out
+}
@@ -0,0 +1,133 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFont loads an installed font, skipping the test on machines without one
+func testFont(t *testing.T) *Font {
+	t.Helper()
+	f, err := FindFont("")
+	if errors.Is(err, ErrNoFont) {
+		t.Skip("no TrueType font installed")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+var card = Card{
+	Text:   "Hayatta en hakiki mürşit ilimdir, fendir.",
+	Author: "Mustafa Kemal Atatürk",
+	Lang:   "tr",
+}
+
+func TestPNG(t *testing.T) {
+	f := testFont(t)
+	var buf bytes.Buffer
+	if err := PNG(&buf, f, card, Options{Width: 400, Height: 240}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds(); got != image.Rect(0, 0, 400, 240) {
+		t.Errorf("bounds = %v, want 400x240", got)
+	}
+	if dark := inked(img); dark == 0 {
+		t.Error("no text drawn")
+	}
+}
+
+func TestDither(t *testing.T) {
+	f := testFont(t)
+	img, err := Image(f, card, Options{Width: 400, Height: 240, Dither: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := img.(*image.Paletted)
+	if !ok || len(p.Palette) != 2 {
+		t.Fatalf("got %T, want a black and white image", img)
+	}
+	if inked(img) == 0 {
+		t.Error("no text drawn")
+	}
+}
+
+// inked counts the pixels darker than mid-gray
+func inked(img image.Image) int {
+	n := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if r, g, bl, _ := img.At(x, y).RGBA(); r+g+bl < 3*0x8000 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestSVG(t *testing.T) {
+	f := testFont(t)
+	var buf bytes.Buffer
+	c := Card{Text: "Less is <more> & then some", Author: "Mies"}
+	if err := SVG(&buf, f, c, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{`width="800" height="480"`, "Less is &lt;more&gt; &amp; then some", "— Mies"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("SVG lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestAttribution(t *testing.T) {
+	dated := Card{Author: "Sally Rooney", Book: "Gente normal", Lang: "es", Date: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)}
+	if got, want := dated.attribution(), "—Sally Rooney, «Gente normal» · 2 de marzo de 2026"; got != want {
+		t.Errorf("attribution = %q, want %q", got, want)
+	}
+	if got := (Card{Lang: "tr"}).attribution(); got != "— Anonim" {
+		t.Errorf("anonymous attribution = %q, want — Anonim", got)
+	}
+}
+
+func TestFitShrinksLongQuotes(t *testing.T) {
+	f := testFont(t)
+	o, _ := Options{}.withDefaults()
+	long := card
+	long.Text = strings.Repeat(card.Text+" ", 8)
+	r := fit(f, long, o)
+	if r.Overflow || r.Size >= o.Size || r.Size < o.MinSize {
+		t.Errorf("long quote set at %.1f (overflow %v), want between %.1f and %.1f", r.Size, r.Overflow, o.MinSize, o.Size)
+	}
+	if short := fit(f, card, o); short.Size != o.Size {
+		t.Errorf("short quote set at %.1f, want the full %.1f", short.Size, o.Size)
+	}
+}
+
+func TestOptionsOutOfRange(t *testing.T) {
+	f := testFont(t)
+	for _, o := range []Options{{Width: -1}, {Width: MaxSide + 1}, {Width: 100, Height: 100, Margin: 50}} {
+		if _, err := Image(f, card, o); err == nil {
+			t.Errorf("Image with %+v: want an error", o)
+		}
+	}
+}
+
+func TestParseFontRejects(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("OTTO\x00\x00\x00\x00\x00\x00\x00\x00"), []byte("not a font at all")} {
+		if _, err := ParseFont(data); err == nil {
+			t.Errorf("ParseFont(%q): want an error", data)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"quotesparser/dedup"
+)
+
+// author is a row of authors, with the number of quotes linked to it
+type author struct {
+	id     int64
+	name   string
+	quotes int
+}
+
+// readAuthors reads every author, oldest first
+func readAuthors(db DB) ([]author, error) {
+	rows, err := db.Query("SELECT a.id, a.name, (SELECT COUNT(*) FROM quotes q WHERE q.authorId = a.id) FROM authors a ORDER BY a.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authors: %v", err)
+	}
+	defer rows.Close()
+	var authors []author
+	for rows.Next() {
+		var a author
+		if err := rows.Scan(&a.id, &a.name, &a.quotes); err != nil {
+			return nil, fmt.Errorf("failed to read authors: %v", err)
+		}
+		authors = append(authors, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read authors: %v", err)
+	}
+	return authors, nil
+}
+
+// canonicalFirst sorts the spellings of one author, the one to keep first:
+// a name without stray spaces, not in capitals, with the most accents, with
+// the most quotes, then the oldest
+func canonicalFirst(authors []author) {
+	rank := func(a author) [4]int {
+		tidy, lower := 0, 0
+		if a.name == strings.Join(strings.Fields(a.name), " ") {
+			tidy = 1
+		}
+		if strings.IndexFunc(a.name, unicode.IsLower) >= 0 {
+			lower = 1
+		}
+		return [4]int{tidy, lower, accents(a.name), a.quotes}
+	}
+	sort.SliceStable(authors, func(i, j int) bool {
+		ri, rj := rank(authors[i]), rank(authors[j])
+		for k := range ri {
+			if ri[k] != rj[k] {
+				return ri[k] > rj[k]
+			}
+		}
+		return false
+	})
+}
+
+// accents counts the letters of name outside ASCII
+func accents(name string) int {
+	n := 0
+	for _, r := range name {
+		if r > unicode.MaxASCII {
+			n++
+		}
+	}
+	return n
+}
+
+// MergeAuthors merges the authors that are one author written differently:
+// those whose names share a dedup.NameKey, such as "Sabahattin Ali",
+// "SABAHATTİN ALİ" and "Sabahattin Ali ", and those named by an alias of
+// another, as an earlier merge left them. The canonical spelling is kept;
+// see MergeAuthorsInto for what the others become.
+func MergeAuthors(db DB) (DedupReport, error) {
+	report := DedupReport{Table: "authors"}
+	authors, err := readAuthors(db)
+	if err != nil {
+		return report, err
+	}
+	report.Rows = len(authors)
+
+	aliases, err := readAliases(db)
+	if err != nil {
+		return report, err
+	}
+	byID := make(map[int64]author)
+	for _, a := range authors {
+		byID[a.id] = a
+	}
+	targets := make(map[int64]bool)
+	for _, id := range aliases {
+		targets[id] = true
+	}
+	groups := make(map[string][]author)
+	var keys []string
+	for _, a := range authors {
+		key := dedup.NameKey(a.name)
+		if id, ok := aliases[a.name]; ok && id != a.id {
+			if canonical, ok := byID[id]; ok {
+				key = dedup.NameKey(canonical.name)
+			}
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], a)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		canonicalFirst(group)
+		// The author an alias names stays the canonical one
+		sort.SliceStable(group, func(i, j int) bool {
+			return targets[group[i].id] && !targets[group[j].id]
+		})
+		g, err := mergeAuthors(db, group[0], group[1:])
+		if err != nil {
+			return report, err
+		}
+		report.Merged += len(g.drop)
+		report.Groups = append(report.Groups, g)
+	}
+	return report, nil
+}
+
+// MergeAuthorsInto merges the authors named drop into the one named keep,
+// whichever way they are spelled. Their quotes and books move to it, the
+// flat quotes.author of their quotes is respelled, and their names become
+// aliases of keep, which Normalize links later quotes by.
+func MergeAuthorsInto(db DB, keep string, drop []string) (DuplicateGroup, error) {
+	authors, err := readAuthors(db)
+	if err != nil {
+		return DuplicateGroup{}, err
+	}
+	find := func(name string) (author, error) {
+		for _, a := range authors {
+			if a.name == name {
+				return a, nil
+			}
+		}
+		return author{}, fmt.Errorf("no author %q", name)
+	}
+	k, err := find(keep)
+	if err != nil {
+		return DuplicateGroup{}, err
+	}
+	var dups []author
+	for _, name := range drop {
+		d, err := find(name)
+		if err != nil {
+			return DuplicateGroup{}, err
+		}
+		if d.id == k.id {
+			return DuplicateGroup{}, fmt.Errorf("cannot merge %q into itself", name)
+		}
+		dups = append(dups, d)
+	}
+	return mergeAuthors(db, k, dups)
+}
+
+func mergeAuthors(db DB, keep author, dups []author) (DuplicateGroup, error) {
+	g := DuplicateGroup{Keep: keep.name, keep: keep.id}
+	merge, err := authorMergeStatements(db)
+	if err != nil {
+		return g, err
+	}
+	for _, dup := range dups {
+		for _, stmt := range merge {
+			_, err := db.Exec(stmt, sql.Named("dup", dup.id), sql.Named("keep", keep.id),
+				sql.Named("dupName", dup.name), sql.Named("keepName", keep.name))
+			if err != nil {
+				return g, fmt.Errorf("failed to merge author %s into %s: %v", dup.name, keep.name, err)
+			}
+		}
+		g.Drop = append(g.Drop, dup.name)
+		g.drop = append(g.drop, dup.id)
+	}
+	return g, nil
+}
+
+// authorMergeStatements returns the statements folding an author (@dup,
+// named @dupName) into another (@keep, @keepName), skipping the tables this
+// database lacks. A book both have keeps the kept author's row.
+func authorMergeStatements(db DB) ([]string, error) {
+	// The kept author's book of the same title as a book of the duplicate
+	const sameBook = "(SELECT k.id FROM books k WHERE k.authorId = @keep AND k.title = (SELECT title FROM books WHERE id = %s))"
+
+	var stmts []string
+	covers, err := TableExists(db, "bookCovers")
+	if err != nil {
+		return nil, err
+	}
+	if covers {
+		stmts = append(stmts, fmt.Sprintf(
+			"UPDATE OR IGNORE bookCovers SET bookId = "+sameBook+" WHERE bookId IN (SELECT id FROM books WHERE authorId = @dup) AND "+sameBook+" IS NOT NULL",
+			"bookCovers.bookId", "bookCovers.bookId"),
+			"DELETE FROM bookCovers WHERE bookId IN (SELECT id FROM books WHERE authorId = @dup AND title IN (SELECT title FROM books WHERE authorId = @keep))")
+	}
+	stmts = append(stmts,
+		fmt.Sprintf("UPDATE quotes SET bookId = COALESCE("+sameBook+", bookId) WHERE bookId IN (SELECT id FROM books WHERE authorId = @dup)", "quotes.bookId"),
+		"DELETE FROM books WHERE authorId = @dup AND title IN (SELECT title FROM books WHERE authorId = @keep)",
+		"UPDATE books SET authorId = @keep WHERE authorId = @dup",
+		`UPDATE quotes SET author = @keepName || substr(author, length(@dupName) + 1)
+			WHERE authorId = @dup AND (author = @dupName OR substr(author, 1, length(@dupName) + 3) = @dupName || ' - ')`,
+		"UPDATE quotes SET authorId = @keep WHERE authorId = @dup",
+	)
+	portraits, err := TableExists(db, "authorPortraits")
+	if err != nil {
+		return nil, err
+	}
+	if portraits {
+		stmts = append(stmts,
+			"UPDATE OR IGNORE authorPortraits SET authorId = @keep WHERE authorId = @dup",
+			"DELETE FROM authorPortraits WHERE authorId = @dup",
+		)
+	}
+	aliases, err := TableExists(db, "authorAliases")
+	if err != nil {
+		return nil, err
+	}
+	if aliases {
+		stmts = append(stmts,
+			"UPDATE authorAliases SET authorId = @keep WHERE authorId = @dup",
+			"DELETE FROM authorAliases WHERE name = @keepName",
+			"INSERT INTO authorAliases (name, authorId) VALUES (@dupName, @keep) ON CONFLICT(name) DO UPDATE SET authorId = excluded.authorId",
+		)
+	}
+	return append(stmts,
+		"UPDATE authors SET link = COALESCE(link, (SELECT link FROM authors WHERE id = @dup)) WHERE id = @keep",
+		"DELETE FROM authors WHERE id = @dup",
+	), nil
+}
+
+// readAliases maps each alias to the id of its author; empty before the
+// authorAliases table exists
+func readAliases(db DB) (map[string]int64, error) {
+	aliases := make(map[string]int64)
+	exists, err := TableExists(db, "authorAliases")
+	if err != nil || !exists {
+		return aliases, err
+	}
+	rows, err := db.Query("SELECT name, authorId FROM authorAliases")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read author aliases: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var id int64
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, fmt.Errorf("failed to read author aliases: %v", err)
+		}
+		aliases[name] = id
+	}
+	return aliases, rows.Err()
+}
+
+// AuthorAlias is another spelling of an author's name
+type AuthorAlias struct {
+	Alias  string
+	Author string
+}
+
+// AuthorAliases lists the aliases merges left, by author
+func AuthorAliases(db DB) ([]AuthorAlias, error) {
+	rows, err := db.Query("SELECT al.name, a.name FROM authorAliases al JOIN authors a ON a.id = al.authorId ORDER BY a.name, al.name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read author aliases: %v", err)
+	}
+	defer rows.Close()
+	var aliases []AuthorAlias
+	for rows.Next() {
+		var a AuthorAlias
+		if err := rows.Scan(&a.Alias, &a.Author); err != nil {
+			return nil, fmt.Errorf("failed to read author aliases: %v", err)
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+// AuthorSuggestion is a pair of authors that may be one, for a person to
+// merge with MergeAuthorsInto
+type AuthorSuggestion struct {
+	Keep, Drop string
+	Score      float64
+}
+
+// SuggestAuthors finds the authors whose names are close without sharing a
+// dedup.NameKey, such as a misspelling ("Sabahatin Ali") or the surname
+// written first ("Ali, Sabahattin"): names scoring at least threshold by
+// Levenshtein similarity, or with the same words in another order. The
+// canonical spelling of each pair is Keep; the best matches come first.
+func SuggestAuthors(db DB, threshold float64) ([]AuthorSuggestion, error) {
+	authors, err := readAuthors(db)
+	if err != nil {
+		return nil, err
+	}
+	// One spelling per key; MergeAuthors merges the rest
+	type candidate struct {
+		author
+		key, words string
+	}
+	var candidates []candidate
+	seen := make(map[string]bool)
+	for _, a := range authors {
+		key := dedup.NameKey(a.name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		words := strings.Fields(key)
+		sort.Strings(words)
+		candidates = append(candidates, candidate{a, key, strings.Join(words, " ")})
+	}
+
+	var found []AuthorSuggestion
+	for i, a := range candidates {
+		for _, b := range candidates[i+1:] {
+			la, lb := len([]rune(a.key)), len([]rune(b.key))
+			if float64(min(la, lb)) < threshold*float64(max(la, lb)) && a.words != b.words {
+				continue // too different in length to score enough
+			}
+			score := 1.0
+			if a.words != b.words {
+				score = dedup.Similarity(a.key, b.key, dedup.Levenshtein)
+			}
+			if score < threshold {
+				continue
+			}
+			pair := []author{a.author, b.author}
+			canonicalFirst(pair)
+			found = append(found, AuthorSuggestion{Keep: pair[0].name, Drop: pair[1].name, Score: score})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Score > found[j].Score })
+	return found, nil
+}
+
+// FillAuthorMetadata gives the authors not enriched yet what quotes authors
+// enrich found on Wikidata under their name, such as an author of
+// frasesauthors looked up before Normalize converted it. It returns how many
+// authors it filled in, none before the authorLookups table exists.
+func FillAuthorMetadata(db DB) (int, error) {
+	exists, err := TableExists(db, "authorLookups")
+	if err != nil || !exists {
+		return 0, err
+	}
+	res, err := db.Exec(`
+		UPDATE authors SET wikidataId = l.wikidataId, birthYear = l.birthYear, deathYear = l.deathYear,
+			nationality = l.nationality, bio = l.bio, imageUrl = l.imageUrl
+		FROM authorLookups l
+		WHERE l.name = authors.name AND l.status = 'ok' AND authors.wikidataId IS NULL`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fill in author metadata: %v", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,266 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+
+	"quotesparser/category"
+)
+
+// TriviaCategoryMap returns the category map of the database: the built-in
+// aliases, the canonical categories and the aliases added to it. Before the
+// tables exist it has the built-in aliases alone.
+func TriviaCategoryMap(db DB) (*category.Map, error) {
+	exists, err := TableExists(db, "triviaCategories")
+	if err != nil || !exists {
+		return category.New(nil, nil), err
+	}
+
+	var names []string
+	rows, err := db.Query("SELECT name FROM triviaCategories ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trivia categories: %v", err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read trivia categories: %v", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trivia categories: %v", err)
+	}
+
+	aliases := make(map[string]string)
+	rows, err = db.Query("SELECT a.alias, c.name FROM triviaCategoryAliases a JOIN triviaCategories c ON c.id = a.categoryId")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trivia category aliases: %v", err)
+	}
+	for rows.Next() {
+		var alias, name string
+		if err := rows.Scan(&alias, &name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read trivia category aliases: %v", err)
+		}
+		aliases[alias] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trivia category aliases: %v", err)
+	}
+	return category.New(names, aliases), nil
+}
+
+// TriviaCategoryID returns the id of the canonical category name, adding it
+// if new
+func TriviaCategoryID(db DB, name string) (int64, error) {
+	if _, err := db.Exec("INSERT INTO triviaCategories (name) VALUES (?) ON CONFLICT(name) DO NOTHING", name); err != nil {
+		return 0, fmt.Errorf("failed to add trivia category %s: %v", name, err)
+	}
+	var id int64
+	if err := db.QueryRow("SELECT id FROM triviaCategories WHERE name = ?", name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to read trivia category %s: %v", name, err)
+	}
+	return id, nil
+}
+
+// NormalizeTriviaCategories renames the category of every trivia question
+// to its canonical name, adding that to triviaCategories, and links the
+// question to it, removing the categories made aliases of others. It returns
+// how many questions it renamed. Before the tables exist it does nothing.
+func NormalizeTriviaCategories(db DB) (int, error) {
+	exists, err := ColumnExists(db, "trivia", "categoryId")
+	if err != nil || !exists {
+		return 0, err
+	}
+	m, err := TriviaCategoryMap(db)
+	if err != nil {
+		return 0, err
+	}
+
+	counts := make(map[string]int)
+	var spellings []string
+	rows, err := db.Query("SELECT category, COUNT(*) FROM trivia GROUP BY category ORDER BY MIN(id)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	for rows.Next() {
+		var spelling string
+		var n int
+		if err := rows.Scan(&spelling, &n); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read trivia: %v", err)
+		}
+		counts[spelling] = n
+		spellings = append(spellings, spelling)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read trivia: %v", err)
+	}
+
+	renamed := 0
+	for _, spelling := range spellings {
+		name := m.Canonical(spelling)
+		if name == "" {
+			continue
+		}
+		id, err := TriviaCategoryID(db, name)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := db.Exec("UPDATE trivia SET category = ?, categoryId = ? WHERE category = ?", name, id, spelling); err != nil {
+			return 0, fmt.Errorf("failed to rename trivia category %q: %v", spelling, err)
+		}
+		if name != spelling {
+			renamed += counts[spelling]
+		}
+	}
+
+	// Categories since made aliases of others are left without questions;
+	// their own aliases move to the category they are now an alias of
+	stale := make(map[string]string)
+	rows, err = db.Query("SELECT name FROM triviaCategories")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read trivia categories: %v", err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read trivia categories: %v", err)
+		}
+		if canonical := m.Canonical(name); canonical != name {
+			stale[name] = canonical
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read trivia categories: %v", err)
+	}
+	for name, canonical := range stale {
+		id, err := TriviaCategoryID(db, canonical)
+		if err != nil {
+			return 0, err
+		}
+		_, err = db.Exec(`UPDATE OR REPLACE triviaCategoryAliases SET categoryId = ?
+			WHERE categoryId = (SELECT id FROM triviaCategories WHERE name = ?)`, id, name)
+		if err == nil {
+			_, err = db.Exec("DELETE FROM triviaCategories WHERE name = ?", name)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to remove trivia category %q: %v", name, err)
+		}
+	}
+	return renamed, nil
+}
+
+// AddTriviaCategoryAlias records alias as a spelling of the canonical
+// category name, adding the category if new. Run NormalizeTriviaCategories
+// to rename the questions already filed under it.
+func AddTriviaCategoryAlias(db DB, alias, name string) error {
+	key, name := category.Key(alias), category.Tidy(name)
+	if key == "" || name == "" {
+		return fmt.Errorf("alias %q = %q: both must have letters", alias, name)
+	}
+	id, err := TriviaCategoryID(db, name)
+	if err != nil {
+		return err
+	}
+	if key == category.Key(name) {
+		return nil
+	}
+	_, err = db.Exec("INSERT INTO triviaCategoryAliases (alias, categoryId) VALUES (?, ?) ON CONFLICT(alias) DO UPDATE SET categoryId = excluded.categoryId", key, id)
+	if err != nil {
+		return fmt.Errorf("failed to add trivia category alias %q: %v", alias, err)
+	}
+	return nil
+}
+
+// TriviaCategory is a category trivia questions are filed under, with their
+// number and the aliases of it. It is not Canonical when the questions were
+// saved under another spelling and not normalized since.
+type TriviaCategory struct {
+	Name      string
+	Questions int
+	Canonical bool
+	Aliases   []string
+}
+
+// TriviaCategories returns the categories of the trivia table and the
+// canonical ones without questions yet, the most asked first
+func TriviaCategories(db DB) ([]TriviaCategory, error) {
+	m, err := TriviaCategoryMap(db)
+	if err != nil {
+		return nil, err
+	}
+	aliases := m.AliasesOf()
+
+	byName := make(map[string]*TriviaCategory)
+	var categories []*TriviaCategory
+	add := func(name string) *TriviaCategory {
+		c, ok := byName[name]
+		if !ok {
+			c = &TriviaCategory{Name: name, Aliases: aliases[name]}
+			byName[name] = c
+			categories = append(categories, c)
+		}
+		return c
+	}
+
+	rows, err := db.Query("SELECT category, COUNT(*) FROM trivia GROUP BY category")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	for rows.Next() {
+		var name string
+		var n int
+		if err := rows.Scan(&name, &n); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read trivia: %v", err)
+		}
+		add(name).Questions = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trivia: %v", err)
+	}
+
+	exists, err := TableExists(db, "triviaCategories")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		rows, err := db.Query("SELECT name FROM triviaCategories")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trivia categories: %v", err)
+		}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read trivia categories: %v", err)
+			}
+			add(name).Canonical = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read trivia categories: %v", err)
+		}
+	}
+
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].Questions != categories[j].Questions {
+			return categories[i].Questions > categories[j].Questions
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	out := make([]TriviaCategory, len(categories))
+	for i, c := range categories {
+		out[i] = *c
+	}
+	return out, nil
+}
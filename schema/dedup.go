@@ -0,0 +1,306 @@
+package schema
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"quotesparser/dedup"
+)
+
+// ErrDuplicate matches a DuplicateError
+var ErrDuplicate = errors.New("duplicate quote")
+
+// DuplicateError is returned when a change would give a quote the text of
+// another
+type DuplicateError struct {
+	ID int64 // of the quote that has the text
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("quote %d has the same text", e.ID)
+}
+
+// Is lets errors.Is match e to ErrDuplicate
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// CheckUnique returns a DuplicateError when a quote other than id has the
+// text hash; id is 0 for a quote not saved yet
+func CheckUnique(db DB, hash string, id int64) error {
+	var other int64
+	err := db.QueryRow("SELECT id FROM quotes WHERE textHash = ? AND id != ?", hash, id).Scan(&other)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read quotes: %v", err)
+	}
+	return &DuplicateError{ID: other}
+}
+
+// hashedTable is a table whose rows are unique by dedup.TextHashIn of one
+// of its text columns
+type hashedTable struct {
+	table string
+	text  string // column the hash is computed from
+	lang  string // column of the text's language, if any
+	hash  string // column holding the hash
+	index string // unique index on hash
+
+	// fill lists columns a kept row takes from the duplicates merged into it
+	// when its own value is NULL; viewCount is always summed
+	fill []string
+	// children lists table.column references moved from a duplicate to the
+	// row it is merged into
+	children []string
+	// same lists columns near-duplicates must agree on: trivia questions
+	// differing in one word are different questions unless the answer matches
+	same []string
+}
+
+var hashedTables = []hashedTable{
+	{
+		table:    "quotes",
+		text:     "text",
+		lang:     "lang",
+		hash:     "textHash",
+		index:    "idx_quotes_text_hash",
+		fill:     []string{"author", "lang", "authorId", "bookId", "sourceId"},
+		children: []string{"quoteTags.quoteId"},
+	},
+	{
+		table:    "trivia",
+		text:     "question",
+		hash:     "questionHash",
+		index:    "idx_trivia_question_hash",
+		fill:     []string{"imageUrl", "audioUrl", "difficulty", "wrongAnswers", "hint"},
+		children: []string{"triviaMedia.triviaId"},
+		same:     []string{"answer"},
+	},
+	{
+		table: "funFacts",
+		text:  "text",
+		lang:  "lang",
+		hash:  "textHash",
+		index: "idx_funfacts_text_hash",
+	},
+}
+
+// DuplicateGroup is a set of rows with the same normalized text, or with
+// near-identical text for FuzzyDedup; the oldest row is kept and the others
+// are merged into it
+type DuplicateGroup struct {
+	Keep   string    // text of the kept row
+	Drop   []string  // texts of the merged rows
+	Scores []float64 // similarity of each merged row to the kept one, FuzzyDedup only
+
+	keep interface{}
+	drop []interface{}
+}
+
+// IDs returns the id of the row g keeps and those of the rows merged into it
+func (g DuplicateGroup) IDs() (keep interface{}, drop []interface{}) {
+	return g.keep, g.drop
+}
+
+// MergeQuotes merges the quotes drop into keep like Dedup merges duplicates:
+// keep takes their views, tags and the columns it lacks, and they are deleted
+func MergeQuotes(db DB, keep int64, drop []int64) error {
+	stmts, err := hashedTables[0].mergeStatements(db)
+	if err != nil {
+		return err
+	}
+	for _, id := range drop {
+		if id == keep {
+			continue
+		}
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt, sql.Named("dup", id), sql.Named("keep", keep)); err != nil {
+				return fmt.Errorf("failed to merge quote %d into %d: %v", id, keep, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DedupReport is what Dedup did to one table
+type DedupReport struct {
+	Table    string
+	Rows     int // rows read
+	Rehashed int // rows whose stored hash was missing or out of date
+	Merged   int // duplicate rows merged away
+	Groups   []DuplicateGroup
+}
+
+// Dedup recomputes the normalized hash of every quote, trivia question and fun
+// fact, merges rows that now share a hash and recreates the unique indexes.
+// Merging keeps the oldest row, fills its empty columns from the others and
+// adds up their view counts. Run it in a transaction; rolling back gives a
+// dry run with the same report.
+func Dedup(db DB) ([]DedupReport, error) {
+	var reports []DedupReport
+	for _, t := range hashedTables {
+		exists, err := TableExists(db, t.table)
+		if err != nil {
+			return reports, err
+		}
+		if !exists {
+			continue
+		}
+		r, err := t.dedup(db)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+func (t hashedTable) dedup(db DB) (DedupReport, error) {
+	report := DedupReport{Table: t.table}
+	if err := AddColumn(db, t.table, t.hash, "TEXT"); err != nil {
+		return report, err
+	}
+
+	type row struct {
+		id         interface{}
+		text, hash string
+		stale      bool
+	}
+	// Databases from before the language columns fold every text alike
+	lang := "''"
+	if t.lang != "" {
+		exists, err := ColumnExists(db, t.table, t.lang)
+		if err != nil {
+			return report, err
+		}
+		if exists {
+			lang = fmt.Sprintf("COALESCE(%s, '')", t.lang)
+		}
+	}
+	var rows []row
+	res, err := db.Query(fmt.Sprintf("SELECT id, %s, %s, %s FROM %s ORDER BY rowid", t.text, lang, t.hash, t.table))
+	if err != nil {
+		return report, fmt.Errorf("failed to read %s: %v", t.table, err)
+	}
+	for res.Next() {
+		var r row
+		var lang string
+		var stored sql.NullString
+		if err := res.Scan(&r.id, &r.text, &lang, &stored); err != nil {
+			res.Close()
+			return report, fmt.Errorf("failed to read %s: %v", t.table, err)
+		}
+		r.hash = dedup.TextHashIn(r.text, lang)
+		r.stale = stored.String != r.hash
+		rows = append(rows, r)
+	}
+	res.Close()
+	if err := res.Err(); err != nil {
+		return report, fmt.Errorf("failed to read %s: %v", t.table, err)
+	}
+	report.Rows = len(rows)
+
+	// Rows are in insertion order, so the first of each hash is the oldest
+	first := make(map[string]row)
+	groups := make(map[string]int) // hash -> index in report.Groups
+	dropped := make(map[interface{}]bool)
+	for _, r := range rows {
+		keep, ok := first[r.hash]
+		if !ok {
+			first[r.hash] = r
+			continue
+		}
+		i, ok := groups[r.hash]
+		if !ok {
+			i = len(report.Groups)
+			groups[r.hash] = i
+			report.Groups = append(report.Groups, DuplicateGroup{Keep: keep.text, keep: keep.id})
+		}
+		g := &report.Groups[i]
+		g.Drop = append(g.Drop, r.text)
+		g.drop = append(g.drop, r.id)
+		dropped[r.id] = true
+	}
+
+	// The index would reject the new hashes while duplicates remain
+	if _, err := db.Exec("DROP INDEX IF EXISTS " + t.index); err != nil {
+		return report, fmt.Errorf("failed to drop %s: %v", t.index, err)
+	}
+
+	merge, err := t.mergeStatements(db)
+	if err != nil {
+		return report, err
+	}
+	for _, g := range report.Groups {
+		for _, id := range g.drop {
+			for _, stmt := range merge {
+				if _, err := db.Exec(stmt, sql.Named("dup", id), sql.Named("keep", g.keep)); err != nil {
+					return report, fmt.Errorf("failed to merge %s row %v into %v: %v", t.table, id, g.keep, err)
+				}
+			}
+			report.Merged++
+		}
+	}
+
+	for _, r := range rows {
+		if !r.stale || dropped[r.id] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", t.table, t.hash), r.hash, r.id); err != nil {
+			return report, fmt.Errorf("failed to update %s hash: %v", t.table, err)
+		}
+		report.Rehashed++
+	}
+
+	_, err = db.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s(%s)", t.index, t.table, t.hash))
+	if err != nil {
+		return report, fmt.Errorf("failed to create %s: %v", t.index, err)
+	}
+	return report, nil
+}
+
+// mergeStatements returns the statements folding a duplicate (@dup) into the
+// row it duplicates (@keep), skipping columns and tables this database lacks
+func (t hashedTable) mergeStatements(db DB) ([]string, error) {
+	var sets []string
+	for _, column := range append([]string{"viewCount"}, t.fill...) {
+		exists, err := ColumnExists(db, t.table, column)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		if column == "viewCount" {
+			sets = append(sets, fmt.Sprintf("viewCount = COALESCE(viewCount, 0) + COALESCE((SELECT viewCount FROM %s WHERE id = @dup), 0)", t.table))
+		} else {
+			sets = append(sets, fmt.Sprintf("%[1]s = COALESCE(%[1]s, (SELECT %[1]s FROM %[2]s WHERE id = @dup))", column, t.table))
+		}
+	}
+
+	var stmts []string
+	if len(sets) > 0 {
+		stmts = append(stmts, fmt.Sprintf("UPDATE %s SET %s WHERE id = @keep", t.table, strings.Join(sets, ", ")))
+	}
+	for _, child := range t.children {
+		table, column, _ := strings.Cut(child, ".")
+		exists, err := TableExists(db, table)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		// Rows the kept row already has are left behind and deleted
+		stmts = append(stmts,
+			fmt.Sprintf("UPDATE OR IGNORE %s SET %s = @keep WHERE %s = @dup", table, column, column),
+			fmt.Sprintf("DELETE FROM %s WHERE %s = @dup", table, column),
+		)
+	}
+	stmts = append(stmts, fmt.Sprintf("DELETE FROM %s WHERE id = @dup", t.table))
+	return stmts, nil
+}
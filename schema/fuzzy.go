@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"quotesparser/dedup"
+)
+
+// FuzzyDedup finds near-duplicate quotes, trivia questions and fun facts:
+// rows whose text scores at least threshold against an older row once
+// punctuation is ignored, such as a quote with and without its ellipsis or
+// with one word changed. Each group is built around its oldest row and only
+// holds rows close to that row, so a chain of small edits never collapses
+// unrelated texts. With merge the groups are merged like Dedup merges exact
+// duplicates; without it they are only reported.
+func FuzzyDedup(db DB, threshold float64, metric dedup.Metric, merge bool) ([]DedupReport, error) {
+	var reports []DedupReport
+	for _, t := range hashedTables {
+		exists, err := TableExists(db, t.table)
+		if err != nil {
+			return reports, err
+		}
+		if !exists {
+			continue
+		}
+		r, err := t.fuzzyDedup(db, threshold, metric, merge)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// NearDuplicateQuotes returns the groups of quotes FuzzyDedup would merge,
+// leaving them as they are
+func NearDuplicateQuotes(db DB, threshold float64, metric dedup.Metric) ([]DuplicateGroup, error) {
+	r, err := hashedTables[0].fuzzyDedup(db, threshold, metric, false)
+	return r.Groups, err
+}
+
+func (t hashedTable) fuzzyDedup(db DB, threshold float64, metric dedup.Metric, merge bool) (DedupReport, error) {
+	report := DedupReport{Table: t.table}
+
+	columns := append([]string{"id", t.text}, t.same...)
+	res, err := db.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY rowid", strings.Join(columns, ", "), t.table))
+	if err != nil {
+		return report, fmt.Errorf("failed to read %s: %v", t.table, err)
+	}
+	var ids []interface{}
+	var texts []string
+	matchers := make(map[string]*dedup.Matcher) // by the normalized same columns
+	rows := make(map[string][]int)              // matcher index -> row index
+	for res.Next() {
+		var id interface{}
+		var text string
+		same := make([]sql.NullString, len(t.same))
+		dest := []interface{}{&id, &text}
+		for i := range same {
+			dest = append(dest, &same[i])
+		}
+		if err := res.Scan(dest...); err != nil {
+			res.Close()
+			return report, fmt.Errorf("failed to read %s: %v", t.table, err)
+		}
+
+		var key strings.Builder
+		for _, s := range same {
+			key.WriteString(dedup.Normalize(s.String))
+			key.WriteByte(0)
+		}
+		m, ok := matchers[key.String()]
+		if !ok {
+			m = dedup.NewMatcher(threshold, metric)
+			matchers[key.String()] = m
+		}
+		m.Add(text)
+		rows[key.String()] = append(rows[key.String()], len(ids))
+		ids = append(ids, id)
+		texts = append(texts, text)
+	}
+	res.Close()
+	if err := res.Err(); err != nil {
+		return report, fmt.Errorf("failed to read %s: %v", t.table, err)
+	}
+	report.Rows = len(ids)
+
+	// Pairs come ordered by their older row, so walking them in row order
+	// makes each unclaimed row the keeper of the rows still free around it
+	type match struct {
+		row   int
+		score float64
+	}
+	near := make(map[int][]match)
+	for key, m := range matchers {
+		for _, p := range m.Pairs() {
+			a, b := rows[key][p.A], rows[key][p.B]
+			near[a] = append(near[a], match{b, p.Score})
+		}
+	}
+	claimed := make(map[int]bool)
+	for i := range ids {
+		if claimed[i] || len(near[i]) == 0 {
+			continue
+		}
+		g := DuplicateGroup{Keep: texts[i], keep: ids[i]}
+		for _, m := range near[i] {
+			if claimed[m.row] {
+				continue
+			}
+			claimed[m.row] = true
+			g.Drop = append(g.Drop, texts[m.row])
+			g.Scores = append(g.Scores, m.score)
+			g.drop = append(g.drop, ids[m.row])
+		}
+		if len(g.drop) > 0 {
+			claimed[i] = true
+			report.Groups = append(report.Groups, g)
+		}
+	}
+	if !merge {
+		return report, nil
+	}
+
+	stmts, err := t.mergeStatements(db)
+	if err != nil {
+		return report, err
+	}
+	for _, g := range report.Groups {
+		for _, id := range g.drop {
+			for _, stmt := range stmts {
+				if _, err := db.Exec(stmt, sql.Named("dup", id), sql.Named("keep", g.keep)); err != nil {
+					return report, fmt.Errorf("failed to merge %s row %v into %v: %v", t.table, id, g.keep, err)
+				}
+			}
+			report.Merged++
+		}
+	}
+	return report, nil
+}
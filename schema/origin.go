@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"quotesparser/origin"
+)
+
+// ClassifyOrigins fills quotes.origin for the quotes without one, from their
+// attribution and the site they came from, and returns how many it
+// classified. Before the column exists it does nothing.
+func ClassifyOrigins(db DB) (int, error) {
+	exists, err := ColumnExists(db, "quotes", "origin")
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	type unclassified struct {
+		id     int64
+		origin origin.Type
+	}
+	var found []unclassified
+	rows, err := db.Query(`
+		SELECT q.id, q.text, q.author, src.name
+		FROM quotes q LEFT JOIN sources src ON src.id = q.sourceId
+		WHERE q.origin IS NULL`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read quotes: %v", err)
+	}
+	for rows.Next() {
+		var id int64
+		var text string
+		var attribution, source sql.NullString
+		if err := rows.Scan(&id, &text, &attribution, &source); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read quotes: %v", err)
+		}
+		author, book := SplitAttribution(attribution.String)
+		found = append(found, unclassified{id, origin.Classify(origin.Hints{Text: text, Author: author, Book: book, Source: source.String})})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read quotes: %v", err)
+	}
+
+	for _, q := range found {
+		if _, err := db.Exec("UPDATE quotes SET origin = ? WHERE id = ?", string(q.origin), q.id); err != nil {
+			return 0, fmt.Errorf("failed to classify quote %d: %v", q.id, err)
+		}
+	}
+	return len(found), nil
+}
+
+// Anonymize takes the author off the quotes credited to a name such as
+// "Anonymous" or "Atasözü", keeping their book, and marks them anonymous. It
+// returns how many quotes it changed.
+func Anonymize(db DB) (int, error) {
+	type credited struct {
+		id   int64
+		book string
+	}
+	var found []credited
+	var names []interface{} // each once
+	seen := make(map[string]bool)
+	rows, err := db.Query("SELECT id, author FROM quotes WHERE author IS NOT NULL AND author != ''")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read quotes: %v", err)
+	}
+	for rows.Next() {
+		var id int64
+		var attribution string
+		if err := rows.Scan(&id, &attribution); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read quotes: %v", err)
+		}
+		if author, book := SplitAttribution(attribution); author != "" && origin.IsAnonymousName(author) {
+			found = append(found, credited{id, book})
+			if !seen[author] {
+				seen[author] = true
+				names = append(names, author)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read quotes: %v", err)
+	}
+
+	for _, q := range found {
+		// Unlinked, so Normalize links the book alone
+		_, err := db.Exec("UPDATE quotes SET author = ?, authorId = NULL, bookId = NULL, origin = ? WHERE id = ?",
+			sql.NullString{String: JoinAttribution("", q.book), Valid: q.book != ""}, string(origin.Anonymous), q.id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to anonymize quote %d: %v", q.id, err)
+		}
+	}
+	if len(found) == 0 {
+		return 0, nil
+	}
+	if _, err := Normalize(db); err != nil {
+		return 0, err
+	}
+
+	// The authors they were credited to go once nothing uses them, with
+	// their books
+	in := "(?" + strings.Repeat(", ?", len(names)-1) + ")"
+	for _, query := range []string{
+		`DELETE FROM books WHERE authorId IN (SELECT id FROM authors WHERE name IN ` + in + `)
+			AND id NOT IN (SELECT bookId FROM quotes WHERE bookId IS NOT NULL)`,
+		`DELETE FROM authors WHERE name IN ` + in + `
+			AND id NOT IN (SELECT authorId FROM quotes WHERE authorId IS NOT NULL)
+			AND id NOT IN (SELECT authorId FROM books WHERE authorId IS NOT NULL)`,
+	} {
+		if _, err := db.Exec(query, names...); err != nil {
+			return 0, fmt.Errorf("failed to remove anonymous authors: %v", err)
+		}
+	}
+	return len(found), nil
+}
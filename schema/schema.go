@@ -0,0 +1,402 @@
+// Package schema holds the schema helpers used by the migrations and converts
+// the flat tables written by the original scripts into the normalized layout.
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"quotesparser/dedup"
+	"quotesparser/langdetect"
+	"quotesparser/origin"
+)
+
+// quoteForeignKeys are added to the existing quotes table
+var quoteForeignKeys = []struct {
+	column     string
+	definition string
+}{
+	{"authorId", "INTEGER REFERENCES authors(id)"},
+	{"bookId", "INTEGER REFERENCES books(id)"},
+	{"sourceId", "INTEGER REFERENCES sources(id)"},
+}
+
+// DB is satisfied by both *sql.DB and *sql.Tx, so the helpers can run
+// inside a migration's transaction
+type DB interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// AddTextHashes adds the quotes.textHash column if needed, fills it for rows
+// inserted before it existed, merges duplicate quotes and creates the unique
+// index
+func AddTextHashes(db DB) error {
+	_, err := hashedTables[0].dedup(db)
+	return err
+}
+
+// AddQuoteForeignKeys adds the authorId, bookId and sourceId columns to quotes
+func AddQuoteForeignKeys(db DB) error {
+	for _, fk := range quoteForeignKeys {
+		if err := AddColumn(db, "quotes", fk.column, fk.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report counts what Normalize converted
+type Report struct {
+	Authors      int
+	Books        int
+	LinkedQuotes int
+	FrasesQuotes int
+	Classified   int // quotes given an origin
+}
+
+// Normalize converts the flat tables into the normalized layout, which the
+// migrations must have created. It only touches quotes that are not linked
+// yet, so it is safe to run repeatedly; callers provide the transaction.
+//
+//   - quotes.author "Author - Book" becomes an authors row and a books row,
+//     and the "- Book" of an anonymous quote a books row without an author
+//   - Turkish quotes are attributed to 1000kitap
+//   - frasesauthors become authors with their link, and frasesquotes are
+//     copied into quotes from fraseslibros, in the language detected for
+//     each (Spanish when unsure)
+//   - quotes without an origin are classified, once the column exists
+//   - authors get the metadata looked up on Wikidata under their name
+func Normalize(tx DB) (Report, error) {
+	var report Report
+	c := &converter{tx: tx, authors: map[string]int64{}, books: map[string]int64{}}
+
+	var authorsBefore, booksBefore int
+	if err := tx.QueryRow("SELECT (SELECT COUNT(*) FROM authors), (SELECT COUNT(*) FROM books)").Scan(&authorsBefore, &booksBefore); err != nil {
+		return report, fmt.Errorf("failed to count authors: %v", err)
+	}
+
+	// Split the flat author strings of unlinked quotes
+	type flatQuote struct {
+		id     int64
+		author string
+		lang   sql.NullString
+	}
+	var flat []flatQuote
+	rows, err := tx.Query("SELECT id, author, lang FROM quotes WHERE authorId IS NULL AND bookId IS NULL AND author IS NOT NULL AND author != ''")
+	if err != nil {
+		return report, fmt.Errorf("failed to read quotes: %v", err)
+	}
+	for rows.Next() {
+		var q flatQuote
+		if err := rows.Scan(&q.id, &q.author, &q.lang); err != nil {
+			rows.Close()
+			return report, fmt.Errorf("failed to read quotes: %v", err)
+		}
+		flat = append(flat, q)
+	}
+	rows.Close()
+
+	kitapID, err := c.sourceID("1000kitap")
+	if err != nil {
+		return report, err
+	}
+	for _, q := range flat {
+		authorName, bookTitle := SplitAttribution(q.author)
+		var authorID int64
+		if authorName != "" {
+			canonical, err := c.canonical(authorName)
+			if err != nil {
+				return report, err
+			}
+			if canonical != authorName {
+				_, err := tx.Exec("UPDATE quotes SET author = ? WHERE id = ?", JoinAttribution(canonical, bookTitle), q.id)
+				if err != nil {
+					return report, fmt.Errorf("failed to respell the author of quote %d: %v", q.id, err)
+				}
+			}
+			if authorID, err = c.authorID(canonical, ""); err != nil {
+				return report, err
+			}
+		}
+		var bookID, sourceID sql.NullInt64
+		if bookTitle != "" {
+			id, err := c.bookID(bookTitle, authorID, "")
+			if err != nil {
+				return report, err
+			}
+			bookID = sql.NullInt64{Int64: id, Valid: true}
+		}
+		if q.lang.String == "tr" {
+			sourceID = sql.NullInt64{Int64: kitapID, Valid: true}
+		}
+		_, err = tx.Exec("UPDATE quotes SET authorId = ?, bookId = ?, sourceId = COALESCE(sourceId, ?) WHERE id = ?", nullID(authorID), bookID, sourceID, q.id)
+		if err != nil {
+			return report, fmt.Errorf("failed to link quote %d: %v", q.id, err)
+		}
+		report.LinkedQuotes++
+	}
+
+	// Copy the fraseslibros tables if they exist
+	hasFrases, err := TableExists(tx, "frasesquotes")
+	if err != nil {
+		return report, err
+	}
+	if hasFrases {
+		n, err := c.convertFrases()
+		if err != nil {
+			return report, err
+		}
+		report.FrasesQuotes = n
+	}
+
+	if report.Classified, err = ClassifyOrigins(tx); err != nil {
+		return report, err
+	}
+	if _, err := FillAuthorMetadata(tx); err != nil {
+		return report, err
+	}
+
+	var authorsAfter, booksAfter int
+	if err := tx.QueryRow("SELECT (SELECT COUNT(*) FROM authors), (SELECT COUNT(*) FROM books)").Scan(&authorsAfter, &booksAfter); err != nil {
+		return report, fmt.Errorf("failed to count authors: %v", err)
+	}
+	report.Authors = authorsAfter - authorsBefore
+	report.Books = booksAfter - booksBefore
+	return report, nil
+}
+
+// SplitAttribution splits the legacy "Author - Book" strings. Book titles may
+// contain " - " themselves, so only the first separator counts. Anonymous
+// quotes from a book are stored as "- Book".
+func SplitAttribution(s string) (author, book string) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "- ") {
+		return "", strings.TrimSpace(s[2:])
+	}
+	if i := strings.Index(s, " - "); i > 0 {
+		return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+3:])
+	}
+	return s, ""
+}
+
+type converter struct {
+	tx      DB
+	authors map[string]int64
+	books   map[string]int64
+	// names maps the dedup.NameKey of each author and alias to the
+	// author's name, once canonical needs it
+	names map[string]string
+}
+
+// JoinAttribution is the inverse of SplitAttribution
+func JoinAttribution(author, book string) string {
+	switch {
+	case book == "":
+		return author
+	case author == "":
+		return "- " + book
+	}
+	return author + " - " + book
+}
+
+func (c *converter) sourceID(name string) (int64, error) {
+	var id int64
+	if err := c.tx.QueryRow("SELECT id FROM sources WHERE name = ?", name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to find source %s: %v", name, err)
+	}
+	return id, nil
+}
+
+// canonical is the name of the author name is another spelling of: an
+// author whose name differs only in case, accents or punctuation, or whose
+// alias name is; name itself for a new author
+func (c *converter) canonical(name string) (string, error) {
+	if c.names == nil {
+		authors, err := readAuthors(c.tx)
+		if err != nil {
+			return "", err
+		}
+		aliases, err := readAliases(c.tx)
+		if err != nil {
+			return "", err
+		}
+		c.names = make(map[string]string)
+		byID := make(map[int64]string)
+		for _, a := range authors {
+			byID[a.id] = a.name
+			if key := dedup.NameKey(a.name); c.names[key] == "" {
+				c.names[key] = a.name
+			}
+		}
+		for alias, id := range aliases {
+			c.names[dedup.NameKey(alias)] = byID[id]
+		}
+	}
+	if known := c.names[dedup.NameKey(name)]; known != "" {
+		return known, nil
+	}
+	return name, nil
+}
+
+func (c *converter) authorID(name, link string) (int64, error) {
+	if id, ok := c.authors[name]; ok {
+		return id, nil
+	}
+	_, err := c.tx.Exec(`
+		INSERT INTO authors (name, link) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET link = COALESCE(authors.link, excluded.link)
+	`, name, sql.NullString{String: link, Valid: link != ""})
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert author %s: %v", name, err)
+	}
+
+	var id int64
+	if err := c.tx.QueryRow("SELECT id FROM authors WHERE name = ?", name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to find author %s: %v", name, err)
+	}
+	c.authors[name] = id
+	if c.names != nil {
+		if key := dedup.NameKey(name); c.names[key] == "" {
+			c.names[key] = name
+		}
+	}
+	return id, nil
+}
+
+func (c *converter) bookID(title string, authorID int64, link string) (int64, error) {
+	key := fmt.Sprintf("%d/%s", authorID, title)
+	if id, ok := c.books[key]; ok {
+		return id, nil
+	}
+
+	var id int64
+	err := c.tx.QueryRow("SELECT id FROM books WHERE title = ? AND authorId IS ?", title, nullID(authorID)).Scan(&id)
+	if err == sql.ErrNoRows {
+		res, err := c.tx.Exec("INSERT INTO books (title, authorId, link) VALUES (?, ?, ?)", title, nullID(authorID), sql.NullString{String: link, Valid: link != ""})
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert book %s: %v", title, err)
+		}
+		id, _ = res.LastInsertId()
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to find book %s: %v", title, err)
+	}
+	c.books[key] = id
+	return id, nil
+}
+
+// nullID stores the ID 0, for anonymous quotes' missing author, as NULL
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// convertFrases copies frasesquotes into quotes, linked to their authors
+func (c *converter) convertFrases() (int, error) {
+	sourceID, err := c.sourceID("fraseslibros")
+	if err != nil {
+		return 0, err
+	}
+
+	type frase struct {
+		text, authorName, authorLink string
+		bookName                     sql.NullString
+	}
+	var frases []frase
+	rows, err := c.tx.Query(`
+		SELECT q.text, a.authorName, a.authorLink, q.bookName
+		FROM frasesquotes q JOIN frasesauthors a ON a.id = q.authorId
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read frasesquotes: %v", err)
+	}
+	for rows.Next() {
+		var f frase
+		if err := rows.Scan(&f.text, &f.authorName, &f.authorLink, &f.bookName); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read frasesquotes: %v", err)
+		}
+		frases = append(frases, f)
+	}
+	rows.Close()
+
+	copied := 0
+	for _, f := range frases {
+		// fraseslibros lists proverbs under an author page such as Anónimo
+		var authorID int64
+		if origin.IsAnonymousName(f.authorName) {
+			f.authorName = ""
+		} else if f.authorName, err = c.canonical(f.authorName); err != nil {
+			return copied, err
+		} else if authorID, err = c.authorID(f.authorName, f.authorLink); err != nil {
+			return copied, err
+		}
+		var bookID sql.NullInt64
+		if f.bookName.Valid && f.bookName.String != "" {
+			id, err := c.bookID(f.bookName.String, authorID, "")
+			if err != nil {
+				return copied, err
+			}
+			bookID = sql.NullInt64{Int64: id, Valid: true}
+		}
+		display := JoinAttribution(f.authorName, f.bookName.String)
+
+		lang := langdetect.DetectOr(f.text, "es")
+		res, err := c.tx.Exec(`
+			INSERT INTO quotes (text, author, lang, viewCount, textHash, authorId, bookId, sourceId)
+			VALUES (?, ?, ?, 0, ?, ?, ?, ?)
+			ON CONFLICT(textHash) DO NOTHING
+		`, f.text, sql.NullString{String: display, Valid: display != ""}, lang, dedup.TextHashIn(f.text, lang), nullID(authorID), bookID, sourceID)
+		if err != nil {
+			return copied, fmt.Errorf("failed to copy quote: %v", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			copied++
+		}
+	}
+	return copied, nil
+}
+
+// AddColumn adds a column to table unless it is already there
+func AddColumn(db DB, table, column, definition string) error {
+	exists, err := ColumnExists(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s column: %v", table, column, err)
+	}
+	return nil
+}
+
+// ColumnExists reports whether table has a column called name
+func ColumnExists(db DB, table, name string) (bool, error) {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s schema: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var colName, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &colName, &colType, &notNull, &dflt, &pk); err != nil {
+			return false, fmt.Errorf("failed to read %s schema: %v", table, err)
+		}
+		if colName == name {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// TableExists reports whether the database has a table called name
+func TableExists(q DB, name string) (bool, error) {
+	var n int
+	if err := q.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %v", name, err)
+	}
+	return n > 0, nil
+}
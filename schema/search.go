@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"quotesparser/dedup"
+)
+
+// SearchResult is a quote found by Search
+type SearchResult struct {
+	ID     int64
+	Text   string
+	Author string // as quotes.author has it, with the book
+	Lang   string
+}
+
+// IndexSearch adds the quotes missing from the quoteSearch full-text index:
+// new ones, and those whose text or author changed, which the triggers of
+// the table take out. The index holds dedup.Loose of both, so the tokenizer
+// sees text without accents or case whoever wrote the quote. It returns how
+// many quotes it indexed.
+func IndexSearch(db DB) (int, error) {
+	type quote struct {
+		id           int64
+		text, author string
+	}
+	var missing []quote
+	rows, err := db.Query("SELECT id, text, COALESCE(author, '') FROM quotes WHERE id NOT IN (SELECT docid FROM quoteSearch)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read quotes: %v", err)
+	}
+	for rows.Next() {
+		var q quote
+		if err := rows.Scan(&q.id, &q.text, &q.author); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read quotes: %v", err)
+		}
+		missing = append(missing, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read quotes: %v", err)
+	}
+
+	for _, q := range missing {
+		_, err := db.Exec("INSERT INTO quoteSearch (docid, text, author) VALUES (?, ?, ?)", q.id, dedup.Loose(q.text), dedup.Loose(q.author))
+		if err != nil {
+			return 0, fmt.Errorf("failed to index quote %d: %v", q.id, err)
+		}
+	}
+	return len(missing), nil
+}
+
+// Search finds the quotes whose text or author has every word of query,
+// newest first. With fold the words match without their accents, so
+// "garcia marquez" finds García Márquez; without it they must be spelled as
+// in the quote, up to case. The index must be up to date; see IndexSearch.
+func Search(db DB, query string, fold bool, limit int) ([]SearchResult, error) {
+	words := searchWords(dedup.Loose(query))
+	if len(words) == 0 {
+		return nil, fmt.Errorf("nothing to search for in %q", query)
+	}
+	match := `"` + strings.Join(words, `" "`) + `"`
+	rows, err := db.Query(`
+		SELECT q.id, q.text, COALESCE(q.author, ''), COALESCE(q.lang, '')
+		FROM quoteSearch s JOIN quotes q ON q.id = s.docid
+		WHERE quoteSearch MATCH ?
+		ORDER BY q.id DESC`, match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search quotes: %v", err)
+	}
+	defer rows.Close()
+
+	exact := searchWords(dedup.Normalize(query))
+	var found []SearchResult
+	for rows.Next() && (limit <= 0 || len(found) < limit) {
+		var r SearchResult
+		if err := rows.Scan(&r.ID, &r.Text, &r.Author, &r.Lang); err != nil {
+			return nil, fmt.Errorf("failed to search quotes: %v", err)
+		}
+		if fold || hasWords(r.Text+" "+r.Author, exact) {
+			found = append(found, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search quotes: %v", err)
+	}
+	return found, nil
+}
+
+// searchWords splits text into words as the index's tokenizer does
+func searchWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	})
+}
+
+// hasWords reports whether text, normalized, has each of words
+func hasWords(text string, words []string) bool {
+	have := make(map[string]bool)
+	for _, w := range searchWords(dedup.Normalize(text)) {
+		have[w] = true
+	}
+	for _, w := range words {
+		if !have[w] {
+			return false
+		}
+	}
+	return true
+}
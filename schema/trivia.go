@@ -0,0 +1,128 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+
+	"quotesparser/dedup"
+)
+
+// TriviaQuestion is a question of a TriviaCluster
+type TriviaQuestion struct {
+	ID        int64  `json:"id"`
+	Category  string `json:"category"`
+	Question  string `json:"question"`
+	Answer    string `json:"answer"`
+	ViewCount int    `json:"viewCount"`
+}
+
+// TriviaCluster is a set of questions asking the same thing in different
+// words. Keep is the question to merge the others into, 0 to leave them all:
+// TriviaDuplicates picks the most served one, the oldest on a tie, and the
+// pick can be changed before ResolveTrivia merges.
+type TriviaCluster struct {
+	Keep      int64            `json:"keep"`
+	Questions []TriviaQuestion `json:"questions"`
+}
+
+// TriviaDuplicates finds trivia questions with the same answer phrased
+// differently, such as "What is the capital of France?" and "Name the capital
+// city of France": see dedup.TriviaClusters. Unlike FuzzyDedup it only reports;
+// ResolveTrivia merges.
+func TriviaDuplicates(db DB, threshold float64) ([]TriviaCluster, error) {
+	res, err := db.Query("SELECT id, category, question, answer, viewCount FROM trivia ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	defer res.Close()
+	var all []TriviaQuestion
+	var questions, answers []string
+	for res.Next() {
+		var q TriviaQuestion
+		if err := res.Scan(&q.ID, &q.Category, &q.Question, &q.Answer, &q.ViewCount); err != nil {
+			return nil, fmt.Errorf("failed to read trivia: %v", err)
+		}
+		all = append(all, q)
+		questions = append(questions, q.Question)
+		answers = append(answers, q.Answer)
+	}
+	if err := res.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trivia: %v", err)
+	}
+
+	var clusters []TriviaCluster
+	for _, members := range dedup.TriviaClusters(questions, answers, threshold) {
+		var c TriviaCluster
+		for _, i := range members {
+			q := all[i]
+			if c.Keep == 0 || q.ViewCount > c.viewCount() {
+				c.Keep = q.ID
+			}
+			c.Questions = append(c.Questions, q)
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, nil
+}
+
+// viewCount is how often the kept question was served
+func (c TriviaCluster) viewCount() int {
+	for _, q := range c.Questions {
+		if q.ID == c.Keep {
+			return q.ViewCount
+		}
+	}
+	return 0
+}
+
+// ResolveTrivia merges every question of a cluster into the one it keeps,
+// adding up view counts and taking over media, like Dedup merges exact
+// duplicates. Clusters keeping 0, or a question not in them, are left
+// alone. It returns how many questions were merged away; resolving the same
+// clusters again merges nothing.
+func ResolveTrivia(db DB, clusters []TriviaCluster) (int, error) {
+	var trivia hashedTable
+	for _, t := range hashedTables {
+		if t.table == "trivia" {
+			trivia = t
+		}
+	}
+	stmts, err := trivia.mergeStatements(db)
+	if err != nil {
+		return 0, err
+	}
+
+	merged := 0
+	for _, c := range clusters {
+		if !c.has(c.Keep) {
+			continue
+		}
+		for _, q := range c.Questions {
+			if q.ID == c.Keep {
+				continue
+			}
+			// The last statement deletes the question, unless an earlier
+			// run already merged it
+			var deleted int64
+			for _, stmt := range stmts {
+				res, err := db.Exec(stmt, sql.Named("dup", q.ID), sql.Named("keep", c.Keep))
+				if err != nil {
+					return merged, fmt.Errorf("failed to merge trivia %d into %d: %v", q.ID, c.Keep, err)
+				}
+				deleted, _ = res.RowsAffected()
+			}
+			merged += int(deleted)
+		}
+	}
+	return merged, nil
+}
+
+// has reports whether the cluster holds question id
+func (c TriviaCluster) has(id int64) bool {
+	for _, q := range c.Questions {
+		if q.ID == id {
+			return true
+		}
+	}
+	return false
+}
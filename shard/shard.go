@@ -0,0 +1,157 @@
+// Package shard writes a large export as numbered parts under a size limit,
+// such as quotes-00001.ndjson, quotes-00002.ndjson, ..., never splitting a
+// record, with a manifest listing each part and its rows, so that loaders
+// capping the files they accept (BigQuery, Google Sheets) take the export
+// part by part.
+package shard
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Part is a file of an export
+type Part struct {
+	File   string `json:"file"` // name, next to the manifest
+	Rows   int64  `json:"rows"`
+	Size   int64  `json:"size"` // in bytes, the header included
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest lists the parts of an export in order
+type Manifest struct {
+	Format  string `json:"format"`
+	Rows    int64  `json:"rows"`
+	MaxSize int64  `json:"maxSize,omitempty"` // of a part, 0 when unsharded
+	Header  bool   `json:"header"`            // each part starts with it
+	Parts   []Part `json:"parts"`
+}
+
+// Writer writes records into the parts of an export
+type Writer struct {
+	path    string
+	maxSize int64
+	header  []byte
+
+	manifest Manifest
+	file     *os.File
+	buf      *bufio.Writer
+	sum      hash.Hash
+	part     Part
+}
+
+// Create starts an export at path. With a maxSize, the records go into
+// parts named after path with a number before the extension, each at most
+// maxSize bytes unless a single record is larger, and Close writes their
+// manifest; without, they all go into path. header, such as the column names
+// of a CSV file, starts every part.
+func Create(path, format string, maxSize int64, header []byte) *Writer {
+	return &Writer{path: path, maxSize: maxSize, header: header,
+		manifest: Manifest{Format: format, MaxSize: maxSize, Header: len(header) > 0, Parts: []Part{}}}
+}
+
+// PartName is the name of part n, from 1, of the export at path
+func PartName(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%05d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// ManifestName is the name of the manifest of the export at path
+func ManifestName(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".manifest.json"
+}
+
+// Write adds a record, encoded in full with its line break
+func (w *Writer) Write(record []byte) error {
+	if w.file != nil && w.maxSize > 0 && w.part.Rows > 0 && w.part.Size+int64(len(record)) > w.maxSize {
+		if err := w.finish(); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	if err := w.write(record); err != nil {
+		return err
+	}
+	w.part.Rows++
+	w.manifest.Rows++
+	return nil
+}
+
+// open starts the next part
+func (w *Writer) open() error {
+	name := w.path
+	if w.maxSize > 0 {
+		name = PartName(w.path, len(w.manifest.Parts)+1)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", name, err)
+	}
+	w.file, w.sum = f, sha256.New()
+	w.buf = bufio.NewWriter(io.MultiWriter(f, w.sum))
+	w.part = Part{File: filepath.Base(name)}
+	return w.write(w.header)
+}
+
+func (w *Writer) write(b []byte) error {
+	if _, err := w.buf.Write(b); err != nil {
+		return fmt.Errorf("failed to write %s: %v", w.part.File, err)
+	}
+	w.part.Size += int64(len(b))
+	return nil
+}
+
+// finish closes the current part
+func (w *Writer) finish() error {
+	err := w.buf.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", w.part.File, err)
+	}
+	w.part.SHA256 = hex.EncodeToString(w.sum.Sum(nil))
+	w.manifest.Parts = append(w.manifest.Parts, w.part)
+	return nil
+}
+
+// Close ends the last part and, when sharding, writes the manifest,
+// returning it. An export without records still has a first part, holding
+// the header alone.
+func (w *Writer) Close() (Manifest, error) {
+	if w.file == nil && len(w.manifest.Parts) == 0 {
+		if err := w.open(); err != nil {
+			return w.manifest, err
+		}
+	}
+	if w.file != nil {
+		if err := w.finish(); err != nil {
+			return w.manifest, err
+		}
+	}
+	if w.maxSize == 0 {
+		return w.manifest, nil
+	}
+	content, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return w.manifest, fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	name := ManifestName(w.path)
+	if err := os.WriteFile(name, append(content, '\n'), 0644); err != nil {
+		return w.manifest, fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	return w.manifest, nil
+}
@@ -0,0 +1,73 @@
+package shard
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "quotes.csv")
+	w := Create(path, "csv", 30, []byte("id,text\n"))
+	for _, record := range []string{"1,one\n", "2,two\n", "3,three\n", "4,a record longer than a part\n", "5,five\n"} {
+		if err := w.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parts fill up to the size, a record too large for one gets its own
+	// and every part starts with the header
+	want := []string{"id,text\n1,one\n2,two\n3,three\n", "id,text\n4,a record longer than a part\n", "id,text\n5,five\n"}
+	if m.Rows != 5 || len(m.Parts) != len(want) {
+		t.Fatalf("manifest = %+v, want 5 rows in %d parts", m, len(want))
+	}
+	for i, p := range m.Parts {
+		content, err := os.ReadFile(filepath.Join(dir, p.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want[i] || p.Size != int64(len(content)) || len(p.SHA256) != 64 {
+			t.Errorf("part %s = %q (%d bytes, %s), want %q", p.File, content, p.Size, p.SHA256, want[i])
+		}
+	}
+	if m.Parts[0].File != "quotes-00001.csv" || m.Parts[0].Rows != 3 {
+		t.Errorf("first part = %+v, want quotes-00001.csv with 3 rows", m.Parts[0])
+	}
+
+	content, err := os.ReadFile(ManifestName(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Manifest
+	if err := json.Unmarshal(content, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Rows != 5 || len(saved.Parts) != 3 || !saved.Header || saved.MaxSize != 30 {
+		t.Errorf("saved manifest = %+v", saved)
+	}
+}
+
+func TestWriterUnsharded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.ndjson")
+	w := Create(path, "ndjson", 0, nil)
+	if _, err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// An empty export is an empty file, without a manifest
+	if content, err := os.ReadFile(path); err != nil || len(content) != 0 {
+		t.Errorf("empty export = %q, %v", content, err)
+	}
+	if _, err := os.Stat(ManifestName(path)); !os.IsNotExist(err) {
+		t.Errorf("unsharded export wrote a manifest: %v", err)
+	}
+	if name := PartName("out/quotes.ndjson", 12); !strings.HasSuffix(name, "quotes-00012.ndjson") {
+		t.Errorf("PartName = %s", name)
+	}
+}
@@ -0,0 +1,80 @@
+package source
+
+import (
+	"context"
+	"errors"
+
+	"quotesparser/fetch"
+)
+
+// Adapter is a site as quotes download, parse and import drive it: it finds
+// the pages of a target, downloads them and reads quotes out of them, so a
+// site is added as one self-contained package. Sites that page a target by
+// number only need to be a Source; Register adapts them with Paged.
+type Adapter interface {
+	// Name is the name used on the command line, e.g. quotes download <name>
+	Name() string
+	// Discover calls visit with the address of each page of target, a slug
+	// or URL given by the user, in order. visit answers how many quotes the
+	// page had; a site paging a target until it runs out stops at the first
+	// page with none. Discover returns the first error visit returns.
+	Discover(ctx context.Context, c *fetch.Client, target string, visit func(url string) (int, error)) error
+	// Fetch downloads a page Discover found
+	Fetch(ctx context.Context, c *fetch.Client, url string) ([]byte, error)
+	// Parse extracts the quotes from a downloaded page. url is where the
+	// page was downloaded from, or "" when that is not known.
+	Parse(url string, page []byte) ([]Quote, error)
+}
+
+// DumpParser is an Adapter some of whose inputs are dumps of a whole site,
+// too large to read at once like a page: quotes parse streams those through
+// ParseDump and gives any other file to Parse
+type DumpParser interface {
+	IsDump(path string) bool
+	ParseDump(path string) ([]Quote, error)
+}
+
+// Paged adapts a Source: the pages of a target are PageURL's pages 1, 2, ...
+// up to the first that has no quotes or does not exist
+type Paged struct {
+	Source
+}
+
+// Discover visits PageURL's pages from 1 until one has no quotes
+func (p Paged) Discover(ctx context.Context, c *fetch.Client, target string, visit func(string) (int, error)) error {
+	for page := 1; ctx.Err() == nil; page++ {
+		url, err := p.PageURL(target, page)
+		if err != nil {
+			return err
+		}
+		n, err := visit(url)
+		if err != nil || n == 0 {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// Fetch downloads an HTML page, retrying pages cut off in transit
+func (p Paged) Fetch(ctx context.Context, c *fetch.Client, url string) ([]byte, error) {
+	return FetchHTML(ctx, c, url)
+}
+
+// Parse is the Source's Parse
+func (p Paged) Parse(url string, page []byte) ([]Quote, error) {
+	return p.Source.Parse(page)
+}
+
+// FetchHTML downloads an HTML page with c, also retrying pages that lost
+// their closing tag
+func FetchHTML(ctx context.Context, c *fetch.Client, url string) ([]byte, error) {
+	html := *c
+	html.Validate = fetch.CompleteHTML
+	return html.GetContext(ctx, url)
+}
+
+// IsMissing reports whether err is the site answering that a page does not
+// exist, which ends a target rather than failing it
+func IsMissing(err error) bool {
+	return errors.Is(err, fetch.ErrNotFound)
+}
@@ -0,0 +1,90 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy is what becomes of a record missing a field its source normally
+// has, such as a quote found without its book
+type Policy string
+
+const (
+	Drop   Policy = "drop"
+	Keep   Policy = "keep"   // saved with the missing fields empty
+	Enrich Policy = "enrich" // saved like Keep and flagged for a later pass to fill in
+)
+
+// Policies lists the policies in the order the help texts show them
+var Policies = []Policy{Drop, Keep, Enrich}
+
+// ParsePolicy reads a policy by name
+func ParsePolicy(s string) (Policy, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, p := range Policies {
+		if s == string(p) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown policy %q (policies: drop, keep, enrich)", s)
+}
+
+// DefaultPolicies are the policies of sources that do not keep incomplete
+// records. 1000kitap lists every quote with its book, so one without is
+// more likely a stray element than a quote.
+var DefaultPolicies = map[string]Policy{
+	"1000kitap": Drop,
+}
+
+// PolicyFor returns the default policy of the source named name
+func PolicyFor(name string) Policy {
+	if p, ok := DefaultPolicies[name]; ok {
+		return p
+	}
+	return Keep
+}
+
+// Report counts what a policy made of the records of a run
+type Report struct {
+	Complete int
+	Kept     int // incomplete, saved with empty fields
+	Flagged  int // incomplete, saved for enrichment
+	Dropped  int
+}
+
+// Apply decides on a record missing the fields in missing, counting the
+// outcome. It reports whether to keep the record and whether to flag it.
+func (r *Report) Apply(p Policy, missing []string) (keep, flag bool) {
+	switch {
+	case len(missing) == 0:
+		r.Complete++
+		return true, false
+	case p == Drop:
+		r.Dropped++
+		return false, false
+	case p == Enrich:
+		r.Flagged++
+		return true, true
+	default:
+		r.Kept++
+		return true, false
+	}
+}
+
+// Filter applies p to quotes, flagging the ones it keeps for enrichment
+func (r *Report) Filter(p Policy, quotes []Quote) []Quote {
+	kept := quotes[:0]
+	for _, q := range quotes {
+		keep, flag := r.Apply(p, q.Missing)
+		if keep {
+			q.Enrich = flag
+			kept = append(kept, q)
+		}
+	}
+	return kept
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%d complete, %d kept with empty fields, %d flagged for enrichment, %d dropped",
+		r.Complete, r.Kept, r.Flagged, r.Dropped)
+}
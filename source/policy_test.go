@@ -0,0 +1,41 @@
+package source
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportFilter(t *testing.T) {
+	quotes := func() []Quote {
+		return []Quote{
+			{Text: "Complete.", Author: "Ayşe Kulin", Book: "Füreya"},
+			{Text: "No book.", Author: "Ayşe Kulin", Missing: []string{"book"}},
+		}
+	}
+	for _, tc := range []struct {
+		policy Policy
+		want   []Quote
+		report Report
+	}{
+		{Drop, quotes()[:1], Report{Complete: 1, Dropped: 1}},
+		{Keep, quotes(), Report{Complete: 1, Kept: 1}},
+		{Enrich, []Quote{quotes()[0], {Text: "No book.", Author: "Ayşe Kulin", Missing: []string{"book"}, Enrich: true}}, Report{Complete: 1, Flagged: 1}},
+	} {
+		var r Report
+		if got := r.Filter(tc.policy, quotes()); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: Filter = %+v, want %+v", tc.policy, got, tc.want)
+		}
+		if r != tc.report {
+			t.Errorf("%s: report = %+v, want %+v", tc.policy, r, tc.report)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	if p, err := ParsePolicy(" Enrich "); err != nil || p != Enrich {
+		t.Errorf("ParsePolicy(Enrich) = %q, %v", p, err)
+	}
+	if _, err := ParsePolicy("skip"); err == nil {
+		t.Error("ParsePolicy(skip) succeeded")
+	}
+}
@@ -0,0 +1,460 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Selectors say where a page keeps the parts of each quote, so a site's
+// layout lives in a config file rather than in DOM-walking code. Quote
+// matches one element per quote; Text, Author and Book are looked up inside
+// it, with an empty Text meaning the whole element.
+//
+// Selectors are the common subset of CSS: tag, .class, #id and [attr],
+// [attr=v], [attr^=v], [attr$=v], [attr*=v] and [attr~=v] conditions,
+// :not(...), descendant and child (>) combinators and comma separated
+// alternatives, e.g. "div.quote span.text" or `a[href^="/author/"]`.
+type Selectors struct {
+	Quote  string `json:"quote"`
+	Text   string `json:"text,omitempty"`
+	Author string `json:"author,omitempty"`
+	Book   string `json:"book,omitempty"`
+	Lang   string `json:"lang,omitempty"` // language of every quote on the site
+}
+
+// ParseSelectors reads selectors from a JSON config
+func ParseSelectors(data []byte) (Selectors, error) {
+	var s Selectors
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("bad selectors: %v", err)
+	}
+	if s.Quote == "" {
+		return s, fmt.Errorf("bad selectors: quote is required")
+	}
+	for _, sel := range []string{s.Quote, s.Text, s.Author, s.Book} {
+		if _, err := Compile(sel); err != nil {
+			return s, fmt.Errorf("bad selectors: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// Extract returns the quotes the selectors find on page
+func (s Selectors) Extract(page []byte) ([]Quote, error) {
+	var quote, text, author, book Selector
+	for _, c := range []struct {
+		sel *Selector
+		src string
+	}{{&quote, s.Quote}, {&text, s.Text}, {&author, s.Author}, {&book, s.Book}} {
+		var err error
+		if *c.sel, err = Compile(c.src); err != nil {
+			return nil, err
+		}
+	}
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes []Quote
+	for _, n := range quote.SelectAll(doc) {
+		q := Quote{Text: textAt(n, text), Lang: s.Lang}
+		if !author.IsZero() {
+			if q.Author = textAt(n, author); q.Author == "" {
+				q.Missing = append(q.Missing, "author")
+			}
+		}
+		if !book.IsZero() {
+			if q.Book = textAt(n, book); q.Book == "" {
+				q.Missing = append(q.Missing, "book")
+			}
+		}
+		if q.Text != "" {
+			quotes = append(quotes, q)
+		}
+	}
+	return quotes, nil
+}
+
+// textAt returns the text of the first element under n matching sel, or of
+// n itself for an empty selector
+func textAt(n *html.Node, sel Selector) string {
+	if !sel.IsZero() {
+		if n = sel.First(n); n == nil {
+			return ""
+		}
+	}
+	return strings.Join(strings.Fields(Text(n)), " ")
+}
+
+// Text returns the text under n, as it is in the page
+func Text(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// Element is an element as a selector sees it
+type Element struct {
+	Tag  string
+	Attr []html.Attribute
+}
+
+// Selector is a compiled selector. Its zero value, compiled from "",
+// matches nothing.
+type Selector struct {
+	src  string
+	alts [][]compound
+}
+
+// compound is the conditions one element must meet
+type compound struct {
+	tag   string // "" for any
+	conds []condition
+	not   []compound
+	child bool // a child of the element the compound before it matched, or of the scope for the first
+}
+
+// condition is an [attr op value] test; .class is [class~=class] and #id
+// is [id=id]
+type condition struct {
+	key, op, val string
+}
+
+// Compile parses a selector
+func Compile(selector string) (Selector, error) {
+	s := Selector{src: selector}
+	if strings.TrimSpace(selector) == "" {
+		return s, nil
+	}
+	p := &selectorParser{src: selector}
+	for {
+		chain, err := p.chain()
+		if err != nil {
+			return Selector{}, fmt.Errorf("bad selector %q: %v", selector, err)
+		}
+		s.alts = append(s.alts, chain)
+		if p.done() {
+			return s, nil
+		}
+		p.pos++ // the comma
+	}
+}
+
+// MustCompile is Compile for selectors known to be valid
+func MustCompile(selector string) Selector {
+	s, err := Compile(selector)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s Selector) String() string { return s.src }
+
+// IsZero reports whether s was compiled from ""
+func (s Selector) IsZero() bool { return len(s.alts) == 0 }
+
+// MatchPath reports whether s matches the last element of path, which runs
+// from the outermost element of the scope searched down to it
+func (s Selector) MatchPath(path []Element) bool {
+	for _, chain := range s.alts {
+		if matchChain(chain, len(chain)-1, path, len(path)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether s matches n, searching from the top of its document
+func (s Selector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode || s.IsZero() {
+		return false
+	}
+	var path []Element
+	for a := n; a != nil; a = a.Parent {
+		if a.Type == html.ElementNode {
+			path = append(path, Element{Tag: a.Data, Attr: a.Attr})
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return s.MatchPath(path)
+}
+
+// SelectAll returns the elements under root that s matches, in document
+// order, searching root's descendants only
+func (s Selector) SelectAll(root *html.Node) []*html.Node {
+	var found []*html.Node
+	s.walk(root, func(n *html.Node) bool {
+		found = append(found, n)
+		return true
+	})
+	return found
+}
+
+// First returns the first element under root that s matches, or nil
+func (s Selector) First(root *html.Node) *html.Node {
+	var first *html.Node
+	s.walk(root, func(n *html.Node) bool {
+		first = n
+		return false
+	})
+	return first
+}
+
+// walk calls found with the matches under root until it returns false
+func (s Selector) walk(root *html.Node, found func(*html.Node) bool) {
+	if s.IsZero() {
+		return
+	}
+	var path []Element
+	var visit func(n *html.Node) bool
+	visit = func(n *html.Node) bool {
+		if n != root && n.Type == html.ElementNode {
+			path = append(path, Element{Tag: n.Data, Attr: n.Attr})
+			defer func() { path = path[:len(path)-1] }()
+			if s.MatchPath(path) && !found(n) {
+				return false
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if !visit(c) {
+				return false
+			}
+		}
+		return true
+	}
+	visit(root)
+}
+
+// matchChain reports whether chain[:ci+1] matches path[:pi+1] with
+// chain[ci] on path[pi]
+func matchChain(chain []compound, ci int, path []Element, pi int) bool {
+	if pi < 0 || !chain[ci].matches(path[pi]) {
+		return false
+	}
+	c := chain[ci]
+	if ci == 0 {
+		return !c.child || pi == 0
+	}
+	if c.child {
+		return matchChain(chain, ci-1, path, pi-1)
+	}
+	for j := pi - 1; j >= 0; j-- {
+		if matchChain(chain, ci-1, path, j) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compound) matches(el Element) bool {
+	if c.tag != "" && c.tag != el.Tag {
+		return false
+	}
+	for _, cond := range c.conds {
+		if !cond.matches(el) {
+			return false
+		}
+	}
+	for _, n := range c.not {
+		if n.matches(el) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cond condition) matches(el Element) bool {
+	for _, a := range el.Attr {
+		if a.Key != cond.key {
+			continue
+		}
+		switch cond.op {
+		case "":
+			return true
+		case "=":
+			return a.Val == cond.val
+		case "^=":
+			return cond.val != "" && strings.HasPrefix(a.Val, cond.val)
+		case "$=":
+			return cond.val != "" && strings.HasSuffix(a.Val, cond.val)
+		case "*=":
+			return cond.val != "" && strings.Contains(a.Val, cond.val)
+		case "~=":
+			for _, word := range strings.Fields(a.Val) {
+				if word == cond.val {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// selectorParser reads a selector from left to right
+type selectorParser struct {
+	src string
+	pos int
+}
+
+func (p *selectorParser) done() bool { return p.pos >= len(p.src) }
+
+func (p *selectorParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.done() && strings.IndexByte(" \t\n\r", p.peek()) >= 0 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// chain reads compounds up to a comma or the end
+func (p *selectorParser) chain() ([]compound, error) {
+	var chain []compound
+	p.skipSpace()
+	for {
+		child := false
+		if p.peek() == '>' {
+			child = true
+			p.pos++
+			p.skipSpace()
+		}
+		c, err := p.compound()
+		if err != nil {
+			return nil, err
+		}
+		c.child = child
+		chain = append(chain, c)
+
+		p.skipSpace()
+		if p.done() || p.peek() == ',' {
+			return chain, nil
+		}
+	}
+}
+
+// compound reads a tag and its conditions
+func (p *selectorParser) compound() (compound, error) {
+	var c compound
+	start := p.pos
+	if p.peek() == '*' {
+		p.pos++
+	} else if name := p.ident(); name != "" {
+		c.tag = strings.ToLower(name)
+	}
+	for {
+		switch p.peek() {
+		case '.', '#':
+			key := map[byte]string{'.': "class", '#': "id"}[p.peek()]
+			op := map[byte]string{'.': "~=", '#': "="}[p.peek()]
+			p.pos++
+			name := p.ident()
+			if name == "" {
+				return c, fmt.Errorf("missing name at %d", p.pos)
+			}
+			c.conds = append(c.conds, condition{key: key, op: op, val: name})
+		case '[':
+			cond, err := p.attribute()
+			if err != nil {
+				return c, err
+			}
+			c.conds = append(c.conds, cond)
+		case ':':
+			if !strings.HasPrefix(p.src[p.pos:], ":not(") {
+				return c, fmt.Errorf("unsupported pseudo-class at %d", p.pos)
+			}
+			p.pos += len(":not(")
+			p.skipSpace()
+			n, err := p.compound()
+			if err != nil {
+				return c, err
+			}
+			p.skipSpace()
+			if p.peek() != ')' {
+				return c, fmt.Errorf("missing ) at %d", p.pos)
+			}
+			p.pos++
+			c.not = append(c.not, n)
+		default:
+			if p.pos == start {
+				return c, fmt.Errorf("unexpected %q at %d", p.src[p.pos:], p.pos)
+			}
+			return c, nil
+		}
+	}
+}
+
+// attribute reads an [attr op value] condition
+func (p *selectorParser) attribute() (condition, error) {
+	p.pos++ // [
+	p.skipSpace()
+	cond := condition{key: strings.ToLower(p.ident())}
+	if cond.key == "" {
+		return cond, fmt.Errorf("missing attribute name at %d", p.pos)
+	}
+	p.skipSpace()
+	for _, op := range []string{"=", "^=", "$=", "*=", "~="} {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			cond.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if cond.op != "" {
+		p.skipSpace()
+		switch q := p.peek(); q {
+		case '"', '\'':
+			end := strings.IndexByte(p.src[p.pos+1:], q)
+			if end < 0 {
+				return cond, fmt.Errorf("unterminated string at %d", p.pos)
+			}
+			cond.val = p.src[p.pos+1 : p.pos+1+end]
+			p.pos += end + 2
+		default:
+			cond.val = p.ident()
+		}
+		p.skipSpace()
+	}
+	if p.peek() != ']' {
+		return cond, fmt.Errorf("missing ] at %d", p.pos)
+	}
+	p.pos++
+	return cond, nil
+}
+
+// ident reads a name of letters, digits, - and _
+func (p *selectorParser) ident() string {
+	start := p.pos
+	for !p.done() {
+		b := p.peek()
+		if b == '-' || b == '_' || b >= 0x80 || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
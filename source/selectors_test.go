@@ -0,0 +1,102 @@
+package source
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestExtract(t *testing.T) {
+	page := []byte(`<html><body>
+<aside><div class="quote"><p class="text">Not a quote: outside the list</p></div></aside>
+<ul class="list">
+  <li><div class="quote card">
+    <p class="text">First   quote,
+      on two lines.</p>
+    <span class="meta"><a class="author" href="/a">Ayşe Kulin</a> · <a class="book">Füreya</a></span>
+  </div></li>
+  <li><div class="quote"><p class="text">Second quote.</p></div></li>
+  <li><div class="quote"><p class="text">  </p></div></li>
+</ul>
+</body></html>`)
+
+	sel := Selectors{Quote: "ul.list div.quote", Text: "p.text", Author: ".meta a.author", Book: "a.book", Lang: "tr"}
+	got, err := sel.Extract(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Quote{
+		{Text: "First quote, on two lines.", Author: "Ayşe Kulin", Book: "Füreya", Lang: "tr"},
+		{Text: "Second quote.", Lang: "tr", Missing: []string{"author", "book"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Extract = %+v\nwant %+v", got, want)
+	}
+
+	// Without a text selector the whole quote element is the text
+	got, _ = Selectors{Quote: "li .card"}.Extract(page)
+	if len(got) != 1 || got[0].Text != "First quote, on two lines. Ayşe Kulin · Füreya" {
+		t.Errorf("Extract(li .card) = %+v", got)
+	}
+}
+
+func TestParseSelectors(t *testing.T) {
+	if _, err := ParseSelectors([]byte(`{"text": ".text"}`)); err == nil {
+		t.Error("selectors without quote should be rejected")
+	}
+	s, err := ParseSelectors([]byte(`{"quote": "div.quote", "lang": "es"}`))
+	if err != nil || s.Quote != "div.quote" || s.Lang != "es" {
+		t.Errorf("ParseSelectors = %+v, %v", s, err)
+	}
+}
+
+func TestSelector(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><body>
+<div id="list" class="authors">
+  <div><a id="telf" href="/albert-camus">Albert Camus</a> (1)</div>
+  <div><p><a href="/amos-oz">Amos Oz</a></p></div>
+  <div><a href="/kitap/normal-insanlar--182700">Normal İnsanlar</a><a href='/yazar/sally-rooney'>Sally Rooney</a></div>
+</div>
+<a href="/telf/contact">Contact</a>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for selector, want := range map[string][]string{
+		"a":                                  {"Albert Camus", "Amos Oz", "Normal İnsanlar", "Sally Rooney", "Contact"},
+		"#list > div > a":                    {"Albert Camus", "Normal İnsanlar", "Sally Rooney"},
+		"div.authors a#telf":                 {"Albert Camus"},
+		`a[href^="/kitap/"][href*="--"]`:     {"Normal İnsanlar"},
+		`a[href^='/yazar/'], a[href$="-oz"]`: {"Amos Oz", "Sally Rooney"},
+		`body > a:not([href*=telf])`:         nil,
+		`div > a:not([href*="telf"]):not([href^="/kitap/"])`: {"Albert Camus", "Sally Rooney"},
+		"> body > a": {"Contact"},
+		"span":       nil,
+		"":           nil,
+	} {
+		sel, err := Compile(selector)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", selector, err)
+			continue
+		}
+		var got []string
+		for _, n := range sel.SelectAll(doc.FirstChild) {
+			got = append(got, Text(n))
+			if !sel.Match(n) && !strings.HasPrefix(selector, ">") {
+				t.Errorf("%q selected %s but does not match it", selector, Text(n))
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q selected %q, want %q", selector, got, want)
+		}
+	}
+
+	for _, bad := range []string{"div >", "a[href", `a[href="x]`, "a:first-child", "div..x", "a,"} {
+		if _, err := Compile(bad); err == nil {
+			t.Errorf("Compile(%q) accepted a bad selector", bad)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package source is the registry of quote sites that plug into quotes
+// download, parse and import without code of their own in the command.
+// Sites register themselves from an init function, as an Adapter or, when
+// their pages are numbered, as a Source; quotes new-source scaffolds a new
+// one.
+package source
+
+import (
+	"sort"
+	"sync"
+)
+
+// Quote is a quote as a source parses it from a page. Missing names the
+// fields the page should have had but did not, which a Policy decides on.
+type Quote struct {
+	Text    string   `json:"text" jsoncheck:"required"`
+	Author  string   `json:"author,omitempty"`
+	Book    string   `json:"book,omitempty"`
+	Lang    string   `json:"lang,omitempty"`
+	Missing []string `json:"missing,omitempty"`
+	Enrich  bool     `json:"enrich,omitempty"` // flagged for a later pass to fill in Missing
+	Likes   int      `json:"likes,omitempty"`  // readers who liked it on sites that count them
+	// Confidence is how sure the parser is that it read the quote right,
+	// see confidence.Signals; 0 when the parser does not tell
+	Confidence float64 `json:"confidence,omitempty"`
+	// Where the quote was read from, stamped by quotes parse: the page's
+	// address and file, when the page was downloaded, and the parser's
+	// version
+	SourceURL     string `json:"sourceUrl,omitempty"`
+	SourceFile    string `json:"sourceFile,omitempty"`
+	FetchedAt     string `json:"fetchedAt,omitempty"`
+	ParserVersion string `json:"parserVersion,omitempty"`
+}
+
+// Source is a quote site
+type Source interface {
+	// Name is the name used on the command line, e.g. quotes download <name>
+	Name() string
+	// PageURL returns page n (from 1) of target, a slug or URL given by the user
+	PageURL(target string, page int) (string, error)
+	// Parse extracts the quotes from a downloaded page. A page with no
+	// quotes ends the target's pages.
+	Parse(page []byte) ([]Quote, error)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]Adapter)
+)
+
+// Register makes a source available by name, adapted with Paged. It panics
+// if the name is taken, like database/sql.Register.
+func Register(s Source) {
+	RegisterAdapter(Paged{s})
+}
+
+// RegisterAdapter makes an adapter available by name. It panics if the name
+// is taken.
+func RegisterAdapter(a Adapter) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := registry[a.Name()]; dup {
+		panic("source: Register called twice for " + a.Name())
+	}
+	registry[a.Name()] = a
+}
+
+// Get returns the adapter registered as name
+func Get(name string) (Adapter, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := registry[name]
+	return s, ok
+}
+
+// Names returns the registered source names, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
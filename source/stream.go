@@ -0,0 +1,36 @@
+package source
+
+import "golang.org/x/net/html"
+
+// voidElements are the elements without an end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// Opens reports whether a start tag token of a streamed page, of type tt and
+// named tag, opens an element whose end tag is still to come: one neither
+// self-closing nor void
+func Opens(tt html.TokenType, tag string) bool {
+	return tt == html.StartTagToken && !voidElements[tag]
+}
+
+// OpenElement is an element whose end tag is still to come, for parsers
+// streaming a page to embed in what they follow of each element
+type OpenElement struct {
+	Tag string
+}
+
+func (e *OpenElement) open() *OpenElement { return e }
+
+// Innermost returns where in stack the innermost open element named tag is,
+// for the end tag to close it and those inside it, or -1 when none is open,
+// as for a stray end tag
+func Innermost[E interface{ open() *OpenElement }](stack []E, tag string) int {
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].open().Tag == tag {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,35 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestOpenElements(t *testing.T) {
+	// The elements left open at the end of the page, innermost last
+	var stack []*OpenElement
+	z := html.NewTokenizer(strings.NewReader(`<div><p>One<br>two<img src="x.png"/><span>three<hr></span><a>four`))
+	for tt := z.Next(); tt != html.ErrorToken; tt = z.Next() {
+		name, _ := z.TagName()
+		switch tag := string(name); {
+		case tt == html.EndTagToken:
+			if i := Innermost(stack, tag); i >= 0 {
+				stack = stack[:i]
+			}
+		case Opens(tt, tag):
+			stack = append(stack, &OpenElement{Tag: tag})
+		}
+	}
+	var tags []string
+	for _, el := range stack {
+		tags = append(tags, el.Tag)
+	}
+	if got := strings.Join(tags, " "); got != "div p a" {
+		t.Errorf("open elements = %q, want div p a", got)
+	}
+	if i := Innermost(stack, "table"); i != -1 {
+		t.Errorf("Innermost(table) = %d, want -1", i)
+	}
+}
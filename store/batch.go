@@ -0,0 +1,147 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DefaultBatchSize is the number of rows sent in one multi-row INSERT
+const DefaultBatchSize = 500
+
+// maxVariables is the most ? parameters SQLite accepts in one statement
+const maxVariables = 32766
+
+// SQLiteDSN returns the connection string every SQLite opener uses. WAL lets
+// readers (paperpi, quotes serve) keep going during big ingests, and
+// synchronous=NORMAL only syncs at checkpoints, which is safe in WAL mode.
+// Both are set through the DSN so every pooled connection gets them.
+func SQLiteDSN(path string) string {
+	return path + "?charset=utf8&parseTime=true&_journal_mode=WAL&_synchronous=NORMAL"
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+// Batch inserts many rows with multi-row INSERT ... VALUES (...), (...)
+// statements instead of one statement per row
+type Batch struct {
+	Insert   string // INSERT INTO table (col, ...)
+	Conflict string // optional ON CONFLICT clause
+	Size     int    // rows per statement, DefaultBatchSize when 0
+
+	// Key returns the conflict key of a row. A row repeating a key already in
+	// the statement starts a new one: PostgreSQL refuses to upsert the same
+	// row twice in one statement, and this keeps SQLite's row-by-row result.
+	Key func(row []interface{}) string
+
+	dollar bool // number placeholders $1, $2... for PostgreSQL
+}
+
+// Exec inserts rows, all of which must have the same number of values
+func (b Batch) Exec(db Execer, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	columns := len(rows[0])
+
+	size := b.Size
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	if size*columns > maxVariables {
+		size = maxVariables / columns
+	}
+
+	// Full statements are prepared once; parsing a 500-row INSERT for every
+	// batch costs more than the single-row inserts it replaces
+	var full *sql.Stmt
+	defer func() {
+		if full != nil {
+			full.Close()
+		}
+	}()
+
+	var args []interface{}
+	keys := make(map[string]bool)
+	count := 0
+	flush := func() error {
+		if count == 0 {
+			return nil
+		}
+		var err error
+		if count == size {
+			if full == nil {
+				if full, err = db.Prepare(b.query(columns, size)); err != nil {
+					return err
+				}
+			}
+			_, err = full.Exec(args...)
+		} else {
+			_, err = db.Exec(b.query(columns, count), args...)
+		}
+		args = args[:0]
+		count = 0
+		for k := range keys {
+			delete(keys, k)
+		}
+		return err
+	}
+
+	for _, row := range rows {
+		if len(row) != columns {
+			return fmt.Errorf("row has %d values, want %d", len(row), columns)
+		}
+		if b.Key != nil {
+			key := b.Key(row)
+			if keys[key] {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			keys[key] = true
+		}
+		args = append(args, row...)
+		count++
+		if count == size {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// query builds the statement for count rows of columns values
+func (b Batch) query(columns int, count int) string {
+	var q strings.Builder
+	q.WriteString(b.Insert)
+	q.WriteString(" VALUES ")
+	n := 0
+	for r := 0; r < count; r++ {
+		if r > 0 {
+			q.WriteString(", ")
+		}
+		q.WriteByte('(')
+		for c := 0; c < columns; c++ {
+			if c > 0 {
+				q.WriteString(", ")
+			}
+			n++
+			if b.dollar {
+				fmt.Fprintf(&q, "$%d", n)
+			} else {
+				q.WriteByte('?')
+			}
+		}
+		q.WriteByte(')')
+	}
+	if b.Conflict != "" {
+		q.WriteByte(' ')
+		q.WriteString(b.Conflict)
+	}
+	return q.String()
+}
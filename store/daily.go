@@ -0,0 +1,115 @@
+package store
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	// Time zones work on machines without a zoneinfo database too
+	_ "time/tzdata"
+
+	"quotesparser/dedup"
+)
+
+// DailyQuote returns the quote of the day matching f: the same one all day,
+// on every device reading the same quotes, whatever their order or IDs.
+// day is taken in its own location, so the quote changes at that midnight.
+//
+// Each quote is scored by hashing the date with its normalized text and the
+// best score wins, so quotes added during the day change the pick only if
+// one of them beats it. Quotes pinned to the day on a Scheduler come first:
+// the pick is among those matching f, if any.
+func DailyQuote(s Store, f Filter, day time.Time) (Quote, error) {
+	f.Limit = 0
+	if pinned, err := pinnedQuotes(s, f, day); err != nil || len(pinned) > 0 {
+		if err != nil {
+			return Quote{}, err
+		}
+		texts := make([]string, len(pinned))
+		for i, q := range pinned {
+			texts[i] = q.Text
+		}
+		return pinned[DailyIndex(day, texts)], nil
+	}
+
+	quotes, err := s.Quotes(f)
+	if err != nil {
+		return Quote{}, err
+	}
+	if len(quotes) == 0 {
+		return Quote{}, ErrNotFound
+	}
+
+	texts := make([]string, len(quotes))
+	for i, q := range quotes {
+		texts[i] = q.Text
+	}
+	return quotes[DailyIndex(day, texts)], nil
+}
+
+// pinnedQuotes returns the quotes matching f pinned to day, when s is a
+// Scheduler
+func pinnedQuotes(s Store, f Filter, day time.Time) ([]Quote, error) {
+	sch, ok := s.(Scheduler)
+	if !ok {
+		return nil, nil
+	}
+	date := day.Format(time.DateOnly)
+	pins, err := sch.Schedule(date, date)
+	if err != nil {
+		return nil, err
+	}
+	var quotes []Quote
+	for _, p := range pins {
+		if f.match(p.Quote) {
+			quotes = append(quotes, p.Quote)
+		}
+	}
+	return quotes, nil
+}
+
+// DailyIndex returns which of texts is the pick of day, as DailyQuote picks
+// quotes, or -1 when there are none
+func DailyIndex(day time.Time, texts []string) int {
+	date := day.Format(time.DateOnly)
+	best := -1
+	var bestScore uint64
+	for i, text := range texts {
+		h := fnv.New64a()
+		h.Write([]byte(date))
+		h.Write([]byte{0})
+		h.Write([]byte(dedup.TextHash(text)))
+		if score := h.Sum64(); i == 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// Day returns the day to pick a quote for: date, as YYYY-MM-DD, if given,
+// else today in the time zone tz ("Local" or "" for the machine's, or a name
+// such as Europe/Istanbul)
+func Day(date, tz string) (time.Time, error) {
+	if tz == "" {
+		tz = "Local" // LoadLocation would take "" for UTC
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown time zone %q", tz)
+	}
+	if date == "" {
+		return time.Now().In(loc), nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, date, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad date %q, want YYYY-MM-DD", date)
+	}
+	return day, nil
+}
+
+// NextMidnight returns when the day after day starts, in day's location:
+// when a daily pick made for day changes
+func NextMidnight(day time.Time) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, day.Location())
+}
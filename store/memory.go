@@ -0,0 +1,230 @@
+package store
+
+import (
+	"math/rand"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"quotesparser/category"
+	"quotesparser/dedup"
+)
+
+// Memory is a Store that keeps everything in memory, for tests and dry runs
+type Memory struct {
+	mu      sync.Mutex
+	quotes  []Quote
+	hashes  map[string]int // textHash -> index in quotes
+	authors map[string]Author
+	trivia  []Trivia
+	asked   map[string]int   // questionHash -> index in trivia
+	pins    map[string][]Pin // by date, holding only the IDs of their quotes
+	// categories resolves the spellings of trivia categories
+	categories *category.Map
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		hashes:  make(map[string]int),
+		authors: make(map[string]Author),
+		asked:   make(map[string]int),
+
+		categories: category.New(nil, nil),
+	}
+}
+
+func (m *Memory) SaveQuotes(quotes []Quote) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inserted := 0
+	for _, q := range quotes {
+		q = prepared(q)
+		q.Raw = "" // not read back, as from the SQL stores
+		hash := dedup.TextHashIn(q.Text, q.Lang)
+		i, ok := m.hashes[hash]
+		if !ok {
+			m.hashes[hash] = len(m.quotes)
+			q.ID = int64(len(m.quotes) + 1)
+			m.quotes = append(m.quotes, q)
+			inserted++
+			continue
+		}
+		// Keep what is already there, like the SQL upserts
+		if m.quotes[i].Author == "" && m.quotes[i].Book == "" {
+			// A quote saved without an author was taken for anonymous
+			m.quotes[i].Author, m.quotes[i].Book, m.quotes[i].Origin = q.Author, q.Book, q.Origin
+		}
+		if m.quotes[i].Lang == "" {
+			m.quotes[i].Lang = q.Lang
+		}
+		m.quotes[i].Enrich = m.quotes[i].Enrich && q.Enrich
+		if m.quotes[i].Source == "" {
+			m.quotes[i].Source = q.Source
+		}
+		if q.Likes > 0 {
+			m.quotes[i].Likes = q.Likes
+		}
+		if q.Confidence > m.quotes[i].Confidence {
+			m.quotes[i].Confidence = q.Confidence
+		}
+		if m.quotes[i].Provenance.Parser == "" {
+			m.quotes[i].Provenance = q.Provenance
+		}
+	}
+	return inserted, nil
+}
+
+func (m *Memory) SaveAuthors(authors []Author) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inserted := 0
+	for _, a := range authors {
+		a.Name = norm.NFC.String(a.Name)
+		existing, ok := m.authors[a.Name]
+		if !ok {
+			inserted++
+		} else if a.Link == "" {
+			a.Link = existing.Link
+		}
+		m.authors[a.Name] = a
+	}
+	return inserted, nil
+}
+
+func (m *Memory) SaveTrivia(trivia []Trivia) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inserted := 0
+	for _, t := range trivia {
+		if name := m.categories.Canonical(t.Category); name != "" {
+			t.Category = name
+		}
+		hash := dedup.TextHash(t.Question)
+		if i, ok := m.asked[hash]; ok {
+			m.trivia[i].Category, m.trivia[i].Answer = t.Category, t.Answer
+			if len(t.WrongAnswers) > 0 {
+				m.trivia[i].WrongAnswers = t.WrongAnswers
+			}
+			if t.Hint != "" {
+				m.trivia[i].Hint = t.Hint
+			}
+			if t.Difficulty != "" {
+				m.trivia[i].Difficulty = t.Difficulty
+			}
+			if t.ImageURL != "" {
+				m.trivia[i].ImageURL = t.ImageURL
+			}
+			if t.AudioURL != "" {
+				m.trivia[i].AudioURL = t.AudioURL
+			}
+			continue
+		}
+		m.asked[hash] = len(m.trivia)
+		m.trivia = append(m.trivia, t)
+		inserted++
+	}
+	return inserted, nil
+}
+
+func (m *Memory) Quotes(f Filter) ([]Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var quotes []Quote
+	for _, q := range m.quotes {
+		if !f.match(q) {
+			continue
+		}
+		quotes = append(quotes, q)
+		if f.Limit > 0 && len(quotes) == f.Limit {
+			break
+		}
+	}
+	return quotes, nil
+}
+
+// match reports whether q passes f, ignoring f.Limit
+func (f Filter) match(q Quote) bool {
+	return (f.Lang == "" || q.Lang == f.Lang) && (f.Author == "" || q.Author == f.Author) &&
+		(f.Origin == "" || q.Origin == f.Origin) && (f.MaxChars == 0 || utf8.RuneCountInString(q.Text) <= f.MaxChars) &&
+		(f.MinConfidence == 0 || q.Confidence >= f.MinConfidence)
+}
+
+func (m *Memory) RandomQuote(f Filter) (Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var least []int
+	for i, q := range m.quotes {
+		switch {
+		case !f.match(q):
+		case len(least) == 0 || q.ViewCount < m.quotes[least[0]].ViewCount:
+			least = append(least[:0], i)
+		case q.ViewCount == m.quotes[least[0]].ViewCount:
+			least = append(least, i)
+		}
+	}
+	if len(least) == 0 {
+		return Quote{}, ErrNotFound
+	}
+	i := least[rand.Intn(len(least))]
+	m.quotes[i].ViewCount++
+	return m.quotes[i], nil
+}
+
+func (m *Memory) Trivia(category string, limit int) ([]Trivia, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := m.categories.Canonical(category)
+	var trivia []Trivia
+	for _, t := range m.trivia {
+		if category != "" && t.Category != name {
+			continue
+		}
+		trivia = append(trivia, t)
+		if limit > 0 && len(trivia) == limit {
+			break
+		}
+	}
+	return trivia, nil
+}
+
+// quote returns the quote saved with the text hash, for Preview
+func (m *Memory) quote(hash string) (Quote, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.hashes[hash]
+	if !ok {
+		return Quote{}, false
+	}
+	return m.quotes[i], true
+}
+
+// author returns the author saved as name, for Preview
+func (m *Memory) author(name string) (Author, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.authors[name]
+	return a, ok
+}
+
+// question returns the trivia saved with the question hash, for Preview
+func (m *Memory) question(hash string) (Trivia, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.asked[hash]
+	if !ok {
+		return Trivia{}, false
+	}
+	return m.trivia[i], true
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
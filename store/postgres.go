@@ -0,0 +1,116 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresSchema creates the tables the Store reads and writes. The SQLite
+// migrations carry history PostgreSQL databases never had, so they start
+// from the current layout.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS sources (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    baseUrl TEXT
+);
+
+CREATE TABLE IF NOT EXISTS quotes (
+    id BIGSERIAL PRIMARY KEY,
+    text TEXT NOT NULL,
+    author TEXT,
+    lang TEXT,
+    viewCount INTEGER DEFAULT 0,
+    textHash TEXT UNIQUE,
+    origin TEXT,
+    needsEnrichment INTEGER NOT NULL DEFAULT 0,
+    sourceId BIGINT REFERENCES sources(id),
+    likes INTEGER,
+    rawText TEXT,
+    confidence DOUBLE PRECISION,
+    sourceUrl TEXT,
+    sourceFile TEXT,
+    fetchedAt TEXT,
+    parserVersion TEXT
+);
+
+CREATE TABLE IF NOT EXISTS schedule (
+    date TEXT NOT NULL,
+    quoteId BIGINT NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    note TEXT,
+    createdAt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (date, quoteId)
+);
+
+CREATE TABLE IF NOT EXISTS authors (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    link TEXT
+);
+
+CREATE TABLE IF NOT EXISTS triviaCategories (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS triviaCategoryAliases (
+    alias TEXT PRIMARY KEY,
+    categoryId BIGINT NOT NULL REFERENCES triviaCategories(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS trivia (
+    id BIGSERIAL PRIMARY KEY,
+    category TEXT NOT NULL,
+    question TEXT NOT NULL UNIQUE,
+    answer TEXT NOT NULL,
+    viewCount INTEGER NOT NULL DEFAULT 0,
+    questionHash TEXT UNIQUE,
+    imageUrl TEXT,
+    audioUrl TEXT,
+    difficulty TEXT,
+    wrongAnswers TEXT,
+    hint TEXT,
+    categoryId BIGINT REFERENCES triviaCategories(id)
+);
+
+ALTER TABLE trivia ADD COLUMN IF NOT EXISTS questionHash TEXT UNIQUE;
+ALTER TABLE trivia ADD COLUMN IF NOT EXISTS imageUrl TEXT;
+ALTER TABLE trivia ADD COLUMN IF NOT EXISTS audioUrl TEXT;
+ALTER TABLE trivia ADD COLUMN IF NOT EXISTS difficulty TEXT;
+ALTER TABLE trivia ADD COLUMN IF NOT EXISTS wrongAnswers TEXT;
+ALTER TABLE trivia ADD COLUMN IF NOT EXISTS hint TEXT;
+ALTER TABLE trivia ADD COLUMN IF NOT EXISTS categoryId BIGINT REFERENCES triviaCategories(id);
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS origin TEXT;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS needsEnrichment INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS sourceId BIGINT REFERENCES sources(id);
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS likes INTEGER;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS confidence DOUBLE PRECISION;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS sourceUrl TEXT;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS sourceFile TEXT;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS fetchedAt TEXT;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS parserVersion TEXT;
+`
+
+// OpenPostgres connects to the PostgreSQL database named by dsn and creates
+// the tables if needed
+func OpenPostgres(dsn string, opts Options) (Store, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	if opts.ReadOnly {
+		return &sqlStore{db: db, dollar: true, batchSize: opts.BatchSize}, nil
+	}
+	if _, err := db.Exec(postgresSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables: %v", err)
+	}
+	return &sqlStore{db: db, dollar: true, batchSize: opts.BatchSize}, nil
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"reflect"
+	"sync"
+
+	"quotesparser/dedup"
+)
+
+// Changes counts what saving rows did, or would do
+type Changes struct {
+	Inserted int
+	Updated  int
+	Skipped  int // already saved as they are, or repeated in the input
+}
+
+// Preview is a Store for dry runs: it reads the quotes and trivia of
+// another store once and saves into a copy of them in memory, counting the
+// rows that saving into the other store would insert, update or leave as
+// they are. The other store is never written to. Stores do not read authors
+// back, so each one counts as inserted the first time it is saved.
+type Preview struct {
+	mem *Memory
+	s   Store
+
+	mu            sync.Mutex
+	quoteChanges  Changes
+	authorChanges Changes
+	triviaChanges Changes
+}
+
+// NewPreview returns a Preview of s
+func NewPreview(s Store) (*Preview, error) {
+	quotes, err := s.Quotes(Filter{})
+	if err != nil {
+		return nil, err
+	}
+	trivia, err := s.Trivia("", 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &Preview{mem: NewMemory(), s: s}
+	p.mem.SaveQuotes(quotes)
+	p.mem.SaveTrivia(trivia)
+	return p, nil
+}
+
+// Changes returns what the saves so far would have done to each table
+func (p *Preview) Changes() (quotes, authors, trivia Changes) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.quoteChanges, p.authorChanges, p.triviaChanges
+}
+
+func (p *Preview) SaveQuotes(quotes []Quote) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	inserted := 0
+	for _, q := range quotes {
+		before, ok := p.mem.quote(dedup.TextHashIn(q.Text, q.Lang))
+		p.mem.SaveQuotes([]Quote{q})
+		after, _ := p.mem.quote(dedup.TextHashIn(q.Text, q.Lang))
+		inserted += p.quoteChanges.count(ok, before == after)
+	}
+	return inserted, nil
+}
+
+func (p *Preview) SaveAuthors(authors []Author) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	inserted := 0
+	for _, a := range authors {
+		before, ok := p.mem.author(a.Name)
+		p.mem.SaveAuthors([]Author{a})
+		after, _ := p.mem.author(a.Name)
+		inserted += p.authorChanges.count(ok, before == after)
+	}
+	return inserted, nil
+}
+
+func (p *Preview) SaveTrivia(trivia []Trivia) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	inserted := 0
+	for _, t := range trivia {
+		before, ok := p.mem.question(dedup.TextHash(t.Question))
+		p.mem.SaveTrivia([]Trivia{t})
+		after, _ := p.mem.question(dedup.TextHash(t.Question))
+		inserted += p.triviaChanges.count(ok, reflect.DeepEqual(before, after))
+	}
+	return inserted, nil
+}
+
+// count adds a row saved, new unless it existed, and returns 1 if it was new
+func (c *Changes) count(existed, unchanged bool) int {
+	switch {
+	case !existed:
+		c.Inserted++
+		return 1
+	case unchanged:
+		c.Skipped++
+	default:
+		c.Updated++
+	}
+	return 0
+}
+
+// Quotes reads the copy, with the quotes saved so far
+func (p *Preview) Quotes(f Filter) ([]Quote, error) {
+	return p.mem.Quotes(f)
+}
+
+// RandomQuote picks from the copy, counting the view there only
+func (p *Preview) RandomQuote(f Filter) (Quote, error) {
+	return p.mem.RandomQuote(f)
+}
+
+// Trivia reads the copy, with the questions saved so far
+func (p *Preview) Trivia(category string, limit int) ([]Trivia, error) {
+	return p.mem.Trivia(category, limit)
+}
+
+// Close closes the other store
+func (p *Preview) Close() error {
+	return p.s.Close()
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// Pin is a quote pinned to a date, to be the quote of that day
+type Pin struct {
+	Date  string // YYYY-MM-DD
+	Quote Quote
+	Note  string // why, e.g. "Sally Rooney's birthday"
+}
+
+// Scheduler is a Store whose quotes can be pinned to dates. DailyQuote
+// picks among the quotes pinned to a day that match its filter before
+// falling back to its deterministic pick.
+type Scheduler interface {
+	// Pin pins the quote of id to date, changing the note of a pin already
+	// there; ErrNotFound when there is no such quote
+	Pin(date string, id int64, note string) error
+	// Unpin removes the pin of the quote of id from date, or every pin of
+	// date when id is 0, returning how many it removed
+	Unpin(date string, id int64) (int, error)
+	// Schedule returns the pins from one date to another, both included,
+	// by date
+	Schedule(from, to string) ([]Pin, error)
+}
+
+func (s *sqlStore) Pin(date string, id int64, note string) error {
+	var found int
+	if err := s.db.QueryRow(s.rebind("SELECT COUNT(*) FROM quotes WHERE id = ?"), id).Scan(&found); err != nil {
+		return fmt.Errorf("failed to read quote: %v", err)
+	}
+	if found == 0 {
+		return ErrNotFound
+	}
+	_, err := s.db.Exec(s.rebind(`INSERT INTO schedule (date, quoteId, note) VALUES (?, ?, ?)
+		ON CONFLICT(date, quoteId) DO UPDATE SET note = excluded.note`), date, id, nullString(note))
+	if err != nil {
+		return fmt.Errorf("failed to pin quote: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) Unpin(date string, id int64) (int, error) {
+	query, args := "DELETE FROM schedule WHERE date = ?", []interface{}{date}
+	if id != 0 {
+		query += " AND quoteId = ?"
+		args = append(args, id)
+	}
+	res, err := s.db.Exec(s.rebind(query), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unpin quote: %v", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *sqlStore) Schedule(from, to string) ([]Pin, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT schedule.date, schedule.note, `+quoteColumns+`
+		FROM schedule JOIN quotes ON quotes.id = schedule.quoteId
+		WHERE schedule.date BETWEEN ? AND ? ORDER BY schedule.date, schedule.createdAt, quotes.id`), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedule: %v", err)
+	}
+	defer rows.Close()
+	var pins []Pin
+	for rows.Next() {
+		var p Pin
+		var note sql.NullString
+		if p.Quote, err = scanQuote(pinRow{rows, &p.Date, &note}); err != nil {
+			return nil, fmt.Errorf("failed to read schedule: %v", err)
+		}
+		p.Note = note.String
+		pins = append(pins, p)
+	}
+	return pins, rows.Err()
+}
+
+// pinRow scans the date and note of a pin before the quoteColumns
+type pinRow struct {
+	row  scanner
+	date *string
+	note *sql.NullString
+}
+
+func (r pinRow) Scan(dest ...interface{}) error {
+	return r.row.Scan(append([]interface{}{r.date, r.note}, dest...)...)
+}
+
+func (m *Memory) Pin(date string, id int64, note string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id <= 0 || int(id) > len(m.quotes) {
+		return ErrNotFound
+	}
+	if m.pins == nil {
+		m.pins = make(map[string][]Pin)
+	}
+	for i, p := range m.pins[date] {
+		if p.Quote.ID == id {
+			m.pins[date][i].Note = note
+			return nil
+		}
+	}
+	m.pins[date] = append(m.pins[date], Pin{Date: date, Quote: Quote{ID: id}, Note: note})
+	return nil
+}
+
+func (m *Memory) Unpin(date string, id int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.pins[date][:0]
+	for _, p := range m.pins[date] {
+		if id != 0 && p.Quote.ID != id {
+			kept = append(kept, p)
+		}
+	}
+	removed := len(m.pins[date]) - len(kept)
+	m.pins[date] = kept
+	return removed, nil
+}
+
+func (m *Memory) Schedule(from, to string) ([]Pin, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pins []Pin
+	for date, pinned := range m.pins {
+		if date < from || date > to {
+			continue
+		}
+		for _, p := range pinned {
+			p.Quote = m.quotes[p.Quote.ID-1]
+			pins = append(pins, p)
+		}
+	}
+	sort.SliceStable(pins, func(i, j int) bool { return pins[i].Date < pins[j].Date })
+	return pins, nil
+}
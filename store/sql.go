@@ -0,0 +1,416 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"quotesparser/category"
+	"quotesparser/dedup"
+	"quotesparser/origin"
+	"quotesparser/schema"
+)
+
+// sqlStore implements Store on database/sql. SQLite and PostgreSQL share
+// the queries; only the placeholders differ.
+type sqlStore struct {
+	db        *sql.DB
+	dollar    bool // PostgreSQL numbers its placeholders: $1, $2...
+	batchSize int
+	temp      string // folder of a copy of the database, removed on Close
+}
+
+// rebind rewrites ? placeholders for the database
+func (s *sqlStore) rebind(query string) string {
+	if !s.dollar {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// upsert inserts rows with b in one transaction and returns how many rows
+// table gained
+func (s *sqlStore) upsert(table string, b Batch, rows [][]interface{}) (int, error) {
+	b.Size = s.batchSize
+	b.dollar = s.dollar
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	var before int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&before); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to count %s: %v", table, err)
+	}
+
+	if err := b.Exec(tx, rows); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to insert into %s: %v", table, err)
+	}
+
+	var after int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&after); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to count %s: %v", table, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return after - before, nil
+}
+
+// keyColumn is a Batch.Key for conflicts on the i'th value of a row
+func keyColumn(i int) func(row []interface{}) string {
+	return func(row []interface{}) string {
+		return fmt.Sprint(row[i])
+	}
+}
+
+func (s *sqlStore) SaveQuotes(quotes []Quote) (int, error) {
+	sources := make(map[string]int64)
+	rows := make([][]interface{}, len(quotes))
+	for i, q := range quotes {
+		q = prepared(q)
+		author := schema.JoinAttribution(q.Author, q.Book)
+		var sourceID sql.NullInt64
+		if q.Source != "" {
+			id, ok := sources[q.Source]
+			if !ok {
+				var err error
+				if id, err = s.sourceID(q.Source); err != nil {
+					return 0, err
+				}
+				sources[q.Source] = id
+			}
+			sourceID = sql.NullInt64{Int64: id, Valid: true}
+		}
+		rows[i] = []interface{}{q.Text, nullString(author), nullString(q.Lang), q.ViewCount, dedup.TextHashIn(q.Text, q.Lang), string(q.Origin), flag(q.Enrich), sourceID, nullInt(q.Likes), nullString(q.Raw), nullFloat(q.Confidence),
+			nullString(q.Provenance.URL), nullString(q.Provenance.File), nullString(q.Provenance.FetchedAt), nullString(q.Provenance.Parser)}
+	}
+	// A quote saved without an author was taken for anonymous, so filling in
+	// its author classifies it again. A flag stays only while every save of
+	// the quote was flagged. Likes change, so the latest count wins; of two
+	// confidences the higher one does.
+	return s.upsert("quotes", Batch{
+		Insert:   "INSERT INTO quotes (text, author, lang, viewCount, textHash, origin, needsEnrichment, sourceId, likes, rawText, confidence, sourceUrl, sourceFile, fetchedAt, parserVersion)",
+		Conflict: "ON CONFLICT(textHash) DO UPDATE SET author = COALESCE(quotes.author, excluded.author), lang = COALESCE(quotes.lang, excluded.lang), origin = CASE WHEN quotes.author IS NULL THEN excluded.origin ELSE COALESCE(quotes.origin, excluded.origin) END, needsEnrichment = quotes.needsEnrichment * excluded.needsEnrichment, sourceId = COALESCE(quotes.sourceId, excluded.sourceId), likes = COALESCE(excluded.likes, quotes.likes), rawText = COALESCE(quotes.rawText, excluded.rawText), confidence = CASE WHEN quotes.confidence IS NULL OR excluded.confidence > quotes.confidence THEN excluded.confidence ELSE quotes.confidence END, " + KeepProvenance,
+		Key:      keyColumn(4),
+	}, rows)
+}
+
+// sourceID returns the id of the sources row named name, adding it if new
+func (s *sqlStore) sourceID(name string) (int64, error) {
+	if _, err := s.db.Exec(s.rebind("INSERT INTO sources (name) VALUES (?) ON CONFLICT(name) DO NOTHING"), name); err != nil {
+		return 0, fmt.Errorf("failed to add source %s: %v", name, err)
+	}
+	var id int64
+	if err := s.db.QueryRow(s.rebind("SELECT id FROM sources WHERE name = ?"), name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to read source %s: %v", name, err)
+	}
+	return id, nil
+}
+
+func (s *sqlStore) SaveAuthors(authors []Author) (int, error) {
+	rows := make([][]interface{}, len(authors))
+	for i, a := range authors {
+		// Composed and decomposed accents are one author to the unique name
+		rows[i] = []interface{}{norm.NFC.String(a.Name), nullString(a.Link)}
+	}
+	return s.upsert("authors", Batch{
+		Insert:   "INSERT INTO authors (name, link)",
+		Conflict: "ON CONFLICT(name) DO UPDATE SET link = COALESCE(excluded.link, authors.link)",
+		Key:      keyColumn(0),
+	}, rows)
+}
+
+func (s *sqlStore) SaveTrivia(trivia []Trivia) (int, error) {
+	categories, err := s.triviaCategories()
+	if err != nil {
+		return 0, err
+	}
+	ids := make(map[string]int64)
+	rows := make([][]interface{}, len(trivia))
+	for i, t := range trivia {
+		if name := categories.Canonical(t.Category); name != "" {
+			t.Category = name
+		}
+		id, ok := ids[t.Category]
+		if !ok {
+			if id, err = s.triviaCategoryID(t.Category); err != nil {
+				return 0, err
+			}
+			ids[t.Category] = id
+		}
+		rows[i] = []interface{}{t.Category, t.Question, t.Answer, t.ViewCount, dedup.TextHash(t.Question), nullString(t.ImageURL), nullString(t.AudioURL), nullString(t.Difficulty), encodeAnswers(t.WrongAnswers), nullString(t.Hint), id}
+	}
+	return s.upsert("trivia", Batch{
+		Insert:   "INSERT INTO trivia (category, question, answer, viewCount, questionHash, imageUrl, audioUrl, difficulty, wrongAnswers, hint, categoryId)",
+		Conflict: "ON CONFLICT(questionHash) DO UPDATE SET category = excluded.category, categoryId = excluded.categoryId, answer = excluded.answer, difficulty = COALESCE(excluded.difficulty, trivia.difficulty), wrongAnswers = COALESCE(excluded.wrongAnswers, trivia.wrongAnswers), hint = COALESCE(excluded.hint, trivia.hint), imageUrl = COALESCE(excluded.imageUrl, trivia.imageUrl), audioUrl = COALESCE(excluded.audioUrl, trivia.audioUrl)",
+		Key:      keyColumn(4),
+	}, rows)
+}
+
+// triviaCategories returns the map of the canonical trivia categories and
+// their aliases, like schema.TriviaCategoryMap on either database
+func (s *sqlStore) triviaCategories() (*category.Map, error) {
+	var names []string
+	aliases := make(map[string]string)
+	for _, query := range []string{
+		"SELECT name, '' FROM triviaCategories ORDER BY id",
+		"SELECT c.name, a.alias FROM triviaCategoryAliases a JOIN triviaCategories c ON c.id = a.categoryId",
+	} {
+		rows, err := s.db.Query(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trivia categories: %v", err)
+		}
+		for rows.Next() {
+			var name, alias string
+			if err := rows.Scan(&name, &alias); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read trivia categories: %v", err)
+			}
+			if alias == "" {
+				names = append(names, name)
+			} else {
+				aliases[alias] = name
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read trivia categories: %v", err)
+		}
+	}
+	return category.New(names, aliases), nil
+}
+
+// triviaCategoryID returns the id of the trivia category named name, adding
+// it if new
+func (s *sqlStore) triviaCategoryID(name string) (int64, error) {
+	if _, err := s.db.Exec(s.rebind("INSERT INTO triviaCategories (name) VALUES (?) ON CONFLICT(name) DO NOTHING"), name); err != nil {
+		return 0, fmt.Errorf("failed to add trivia category %s: %v", name, err)
+	}
+	var id int64
+	if err := s.db.QueryRow(s.rebind("SELECT id FROM triviaCategories WHERE name = ?"), name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to read trivia category %s: %v", name, err)
+	}
+	return id, nil
+}
+
+// quoteWhere narrows the quotes table to f, ignoring f.Limit
+func quoteWhere(f Filter) (string, []interface{}) {
+	where := " WHERE 1 = 1"
+	var args []interface{}
+	if f.Lang != "" {
+		where += " AND lang = ?"
+		args = append(args, f.Lang)
+	}
+	if f.Author != "" {
+		// Match "Author" and "Author - Book", case-sensitively like Memory
+		prefix := f.Author + " - "
+		where += " AND (author = ? OR substr(author, 1, ?) = ?)"
+		args = append(args, f.Author, utf8.RuneCountInString(prefix), prefix)
+	}
+	if f.Origin != "" {
+		where += " AND origin = ?"
+		args = append(args, string(f.Origin))
+	}
+	if f.MaxChars > 0 {
+		where += " AND length(text) <= ?"
+		args = append(args, f.MaxChars)
+	}
+	if f.MinConfidence > 0 {
+		where += " AND confidence >= ?"
+		args = append(args, f.MinConfidence)
+	}
+	return where, args
+}
+
+func (s *sqlStore) Quotes(f Filter) ([]Quote, error) {
+	where, args := quoteWhere(f)
+	query := "SELECT " + quoteColumns + " FROM quotes" + where + " ORDER BY id"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quotes: %v", err)
+	}
+	defer rows.Close()
+
+	var quotes []Quote
+	for rows.Next() {
+		q, err := scanQuote(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read quotes: %v", err)
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, rows.Err()
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// KeepProvenance updates the provenance of a quote saved again, in the
+// ON CONFLICT clause of an upsert into quotes: it is kept whole from the
+// first save with a parser, never mixed from two
+const KeepProvenance = "sourceUrl = CASE WHEN quotes.parserVersion IS NULL THEN excluded.sourceUrl ELSE quotes.sourceUrl END, " +
+	"sourceFile = CASE WHEN quotes.parserVersion IS NULL THEN excluded.sourceFile ELSE quotes.sourceFile END, " +
+	"fetchedAt = CASE WHEN quotes.parserVersion IS NULL THEN excluded.fetchedAt ELSE quotes.fetchedAt END, " +
+	"parserVersion = COALESCE(quotes.parserVersion, excluded.parserVersion)"
+
+// quoteColumns are the columns scanQuote reads, the source by name
+const quoteColumns = "id, text, author, lang, origin, needsEnrichment, (SELECT name FROM sources WHERE sources.id = quotes.sourceId), likes, viewCount, confidence, " +
+	"sourceUrl, sourceFile, fetchedAt, parserVersion"
+
+// scanQuote reads the quoteColumns
+func scanQuote(row scanner) (Quote, error) {
+	var q Quote
+	var author, lang, kind, src sql.NullString
+	var enrich, likes, viewCount sql.NullInt64
+	var confidence sql.NullFloat64
+	var url, file, fetchedAt, parser sql.NullString
+	if err := row.Scan(&q.ID, &q.Text, &author, &lang, &kind, &enrich, &src, &likes, &viewCount, &confidence, &url, &file, &fetchedAt, &parser); err != nil {
+		return q, err
+	}
+	q.Confidence = confidence.Float64
+	q.Provenance = Provenance{URL: url.String, File: file.String, FetchedAt: fetchedAt.String, Parser: parser.String}
+	q.Lang, q.Origin, q.Enrich, q.ViewCount = lang.String, origin.Type(kind.String), enrich.Int64 != 0, int(viewCount.Int64)
+	q.Source, q.Likes = src.String, int(likes.Int64)
+	q.Author, q.Book = schema.SplitAttribution(author.String)
+	return q, nil
+}
+
+func (s *sqlStore) RandomQuote(f Filter) (Quote, error) {
+	// One statement, so two displays asking at once cannot both miss the
+	// other's view
+	where, args := quoteWhere(f)
+	q, err := scanQuote(s.db.QueryRow(s.rebind(`
+		UPDATE quotes SET viewCount = COALESCE(viewCount, 0) + 1
+		WHERE id = (SELECT id FROM quotes`+where+` ORDER BY COALESCE(viewCount, 0), RANDOM() LIMIT 1)
+		RETURNING `+quoteColumns), args...))
+	if err == sql.ErrNoRows {
+		return q, ErrNotFound
+	}
+	if err != nil {
+		return q, fmt.Errorf("failed to pick a quote: %v", err)
+	}
+	return q, nil
+}
+
+func (s *sqlStore) Trivia(category string, limit int) ([]Trivia, error) {
+	query := "SELECT category, question, answer, wrongAnswers, hint, difficulty, imageUrl, audioUrl, viewCount FROM trivia"
+	var args []interface{}
+	if category != "" {
+		// Rows saved before categories were normalized keep their spelling
+		categories, err := s.triviaCategories()
+		if err != nil {
+			return nil, err
+		}
+		query += " WHERE category IN (?, ?)"
+		args = append(args, category, categories.Canonical(category))
+	}
+	query += " ORDER BY id"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trivia: %v", err)
+	}
+	defer rows.Close()
+
+	var trivia []Trivia
+	for rows.Next() {
+		var t Trivia
+		var wrong, hint, difficulty, image, audio sql.NullString
+		if err := rows.Scan(&t.Category, &t.Question, &t.Answer, &wrong, &hint, &difficulty, &image, &audio, &t.ViewCount); err != nil {
+			return nil, fmt.Errorf("failed to read trivia: %v", err)
+		}
+		if t.WrongAnswers, err = DecodeAnswers(wrong.String); err != nil {
+			return nil, err
+		}
+		t.Hint, t.Difficulty, t.ImageURL, t.AudioURL = hint.String, difficulty.String, image.String, audio.String
+		trivia = append(trivia, t)
+	}
+	return trivia, rows.Err()
+}
+
+func (s *sqlStore) Close() error {
+	err := s.db.Close()
+	if s.temp != "" {
+		os.RemoveAll(s.temp)
+	}
+	return err
+}
+
+// flag stores a bool as the 0 or 1 of an INTEGER column
+func flag(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// nullInt stores zero as NULL so later upserts can fill it
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
+
+// nullFloat stores a zero float as NULL, for unknown
+func nullFloat(f float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: f, Valid: f != 0}
+}
+
+// nullString stores empty strings as NULL so later upserts can fill them
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// encodeAnswers stores wrong answers as a JSON array, or NULL when there are
+// none so saving a question again without them keeps them
+func encodeAnswers(answers []string) sql.NullString {
+	if len(answers) == 0 {
+		return sql.NullString{}
+	}
+	b, _ := json.Marshal(answers)
+	return nullString(string(b))
+}
+
+// DecodeAnswers reads the wrongAnswers column of trivia: a JSON array of
+// strings, or empty for none
+func DecodeAnswers(column string) ([]string, error) {
+	if column == "" {
+		return nil, nil
+	}
+	var answers []string
+	if err := json.Unmarshal([]byte(column), &answers); err != nil {
+		return nil, fmt.Errorf("failed to read wrong answers %q: %v", column, err)
+	}
+	return answers, nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"quotesparser/migrations"
+)
+
+// OpenSQLite opens the SQLite database at path and applies pending migrations
+func OpenSQLite(path string, opts Options) (Store, error) {
+	if opts.ReadOnly {
+		return openSQLiteReadOnly(path, opts)
+	}
+	db, err := sql.Open("sqlite3", SQLiteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	if _, err := db.Exec("PRAGMA encoding = 'UTF-8'"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set encoding: %v", err)
+	}
+	if _, err := migrations.Up(db, 0); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlStore{db: db, batchSize: opts.BatchSize}, nil
+}
+
+// openSQLiteReadOnly opens the SQLite database at path without writing to
+// it, not even to switch it to WAL. A database missing migrations is copied
+// to a temporary file and the copy migrated, since migrating changes what
+// saving would do.
+func openSQLiteReadOnly(path string, opts Options) (Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	version, err := migrations.Recorded(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version >= migrations.Latest() {
+		return &sqlStore{db: db, batchSize: opts.BatchSize}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "quotes-dry-run")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to copy database: %v", err)
+	}
+	copied := filepath.Join(dir, filepath.Base(path))
+	_, err = db.Exec("VACUUM INTO ?", copied)
+	db.Close()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to copy database: %v", err)
+	}
+	s, err := OpenSQLite(copied, Options{BatchSize: opts.BatchSize})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	s.(*sqlStore).temp = dir
+	return s, nil
+}
+
+// NewSQLite returns a Store on an SQLite database the caller has opened and
+// migrated, e.g. to share it with queries the Store does not cover. Closing
+// the Store closes db.
+func NewSQLite(db *sql.DB, opts Options) Store {
+	return &sqlStore{db: db, batchSize: opts.BatchSize}
+}
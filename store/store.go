@@ -0,0 +1,161 @@
+// Package store persists quotes, authors and trivia behind one interface so
+// the commands can write to SQLite, PostgreSQL or memory.
+package store
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"quotesparser/locale"
+	"quotesparser/origin"
+)
+
+// ErrNotFound is returned by RandomQuote when no quote matches
+var ErrNotFound = errors.New("no matching quote")
+
+// Quote is a quote as the stores save it. Book is optional, and so is Author
+// for proverbs and other anonymous quotes: authors such as "Anonymous" or
+// "Atasözü" are saved as none. ID is set on the quotes a store returns and
+// ignored when saving. Origin is classified from the attribution when saved
+// empty. Enrich flags a quote saved without fields its source should have
+// had, for a later pass to fill in; saving it complete clears the flag.
+// Source names the site the quote was collected from, e.g. goodreads, and
+// Likes how many of its readers liked it there; saving it again keeps the
+// first source and updates the likes. Confidence, from 0 to 1, is how sure
+// the parser was of the quote, zero when unknown; saving it again keeps the
+// higher one. Provenance is kept from the first save that had one.
+type Quote struct {
+	ID         int64
+	Text       string
+	Author     string
+	Book       string
+	Lang       string
+	Origin     origin.Type
+	Enrich     bool
+	Source     string
+	Likes      int
+	ViewCount  int
+	Confidence float64
+	Raw        string // the text as scraped, when cleaning changed it; saved, not read back
+	Provenance Provenance
+}
+
+// Provenance tells where a quote was read from
+type Provenance struct {
+	URL       string // page the quote was on, when it was downloaded
+	File      string // page or file it was parsed or imported from
+	FetchedAt string // when the page was downloaded, RFC 3339
+	Parser    string // parser that read it and its version, e.g. 1000kitap@3f2a9c1
+}
+
+// Author is an author known to the quote sources
+type Author struct {
+	Name string
+	Link string
+}
+
+// Trivia is a question and its answer. WrongAnswers are the other options of
+// a multiple-choice question and Hint a clue to show before the answer.
+// ImageURL and AudioURL optionally point to the picture or clip of a picture
+// or audio round, and Difficulty is easy, medium or hard when known; saving
+// the question again without any of these keeps them.
+type Trivia struct {
+	Category     string
+	Question     string
+	Answer       string
+	WrongAnswers []string
+	Hint         string
+	Difficulty   string
+	ImageURL     string
+	AudioURL     string
+	ViewCount    int
+}
+
+// Filter narrows the quotes returned by Store.Quotes. Zero fields match
+// everything.
+type Filter struct {
+	Lang   string
+	Author string
+	Origin origin.Type
+	// MaxChars skips quotes longer than this many characters, e.g. to pick
+	// only those that fit a small display
+	MaxChars int
+	// MinConfidence skips quotes scored below it, and those never scored
+	MinConfidence float64
+	Limit         int
+}
+
+// Store saves and reads back the collected data. Save methods upsert, keeping
+// viewCount and fields edited by hand, and return how many rows were new.
+type Store interface {
+	SaveQuotes(quotes []Quote) (inserted int, err error)
+	SaveAuthors(authors []Author) (inserted int, err error)
+	SaveTrivia(trivia []Trivia) (inserted int, err error)
+	Quotes(f Filter) ([]Quote, error)
+	// RandomQuote picks one of the least shown quotes matching f, ignoring
+	// f.Limit, and counts the view by incrementing its viewCount in the same
+	// step, so a display calling it in turn shows every quote before any
+	// comes back
+	RandomQuote(f Filter) (Quote, error)
+	// Trivia returns the questions of a category, by any spelling of it, or
+	// of all when category is empty. Saving trivia files it under the
+	// canonical name of its category.
+	Trivia(category string, limit int) ([]Trivia, error)
+	Close() error
+}
+
+// Options tune how a store writes
+type Options struct {
+	BatchSize int // rows per multi-row INSERT, DefaultBatchSize when 0
+	// ReadOnly opens the database without changing it, for dry runs: an
+	// SQLite file must exist and is opened read-only, or migrated in a
+	// temporary copy when it misses migrations, and PostgreSQL tables are
+	// not created
+	ReadOnly bool
+}
+
+// Open returns the store named by dsn with default options:
+//
+//	memory:                       in-memory store, gone on Close
+//	postgres://user@host/dbname   PostgreSQL (postgresql:// works too)
+//	sqlite:database.db            SQLite; a bare path means the same
+func Open(dsn string) (Store, error) {
+	return OpenWith(dsn, Options{})
+}
+
+// OpenWith is Open with options
+func OpenWith(dsn string, opts Options) (Store, error) {
+	switch {
+	case dsn == "memory:":
+		return NewMemory(), nil
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return OpenPostgres(dsn, opts)
+	default:
+		return OpenSQLite(strings.TrimPrefix(dsn, "sqlite:"), opts)
+	}
+}
+
+// Credit returns who to credit q to when showing it, "Author, Book", as
+// locale.Credit writes it in q's language
+func (q Quote) Credit() string {
+	return locale.Credit(q.Author, q.Book, q.Lang)
+}
+
+// prepared returns q as the stores save it: in NFC, without an author when
+// it is anonymous, and classified when it has no origin
+func prepared(q Quote) Quote {
+	q.Text, q.Author, q.Book = norm.NFC.String(q.Text), norm.NFC.String(q.Author), norm.NFC.String(q.Book)
+	if q.Origin == "" {
+		q.Origin = origin.Classify(origin.Hints{Text: q.Text, Author: q.Author, Book: q.Book, Lang: q.Lang})
+	}
+	// A quote waiting for its author is not anonymous yet
+	if q.Enrich && q.Origin == origin.Anonymous && q.Author == "" && !origin.IsProverb(q.Text, q.Lang) {
+		q.Origin = origin.Unknown
+	}
+	if origin.IsAnonymousName(q.Author) {
+		q.Author = ""
+	}
+	return q
+}
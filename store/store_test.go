@@ -0,0 +1,278 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"quotesparser/origin"
+)
+
+// TestStores runs the same checks against every backend. PostgreSQL needs a
+// scratch database: QUOTES_TEST_POSTGRES=postgres://user@localhost/quotes_test
+func TestStores(t *testing.T) {
+	backends := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store {
+			return NewMemory()
+		},
+		"sqlite": func(t *testing.T) Store {
+			s, err := Open("sqlite:" + filepath.Join(t.TempDir(), "database.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+		"postgres": func(t *testing.T) Store {
+			dsn := os.Getenv("QUOTES_TEST_POSTGRES")
+			if dsn == "" {
+				t.Skip("QUOTES_TEST_POSTGRES not set")
+			}
+			s, err := Open(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, table := range []string{"schedule", "quotes", "authors", "trivia"} {
+				if _, err := s.(*sqlStore).db.Exec("TRUNCATE " + table); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return s
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+			testStore(t, s)
+		})
+	}
+}
+
+func testStore(t *testing.T, s Store) {
+	quotes := []Quote{
+		{Text: "Hayatında ilk kez kendini normal hissetti.", Author: "Sally Rooney", Book: "Normal İnsanlar", Lang: "tr"},
+		{Text: "The only way out is through.", Author: "Robert Frost", Lang: "en", Source: "goodreads", Likes: 12, Confidence: 0.7,
+			Provenance: Provenance{URL: "https://www.goodreads.com/quotes?page=1", File: "pages/page1.html", FetchedAt: "2024-03-01T10:00:00Z", Parser: "goodreads@1a2b3c4"}},
+		{Text: "Niño, la vida es una canción.", Lang: "es"},
+	}
+	n, err := s.SaveQuotes(quotes)
+	if err != nil || n != 3 {
+		t.Fatalf("SaveQuotes = %d, %v; want 3 new", n, err)
+	}
+
+	// Saving again only fills what was missing
+	again := []Quote{
+		{Text: "  Hayatında ilk kez kendini normal hissetti. ", Author: "Someone Else", Lang: "tr"},
+		{Text: "Niño, la vida es una canción.", Author: "Amos Oz", Lang: "es", Provenance: Provenance{File: "frases.json", Parser: "frases@1a2b3c4"}},
+		{Text: "The only way out is through.", Lang: "en", Source: "wikiquote", Likes: 40, Confidence: 0.9, Provenance: Provenance{URL: "https://en.wikiquote.org/wiki/Robert_Frost", Parser: "wikiquote@5d6e7f8"}},
+	}
+	if n, err := s.SaveQuotes(again); err != nil || n != 0 {
+		t.Fatalf("SaveQuotes again = %d, %v; want 0 new", n, err)
+	}
+
+	got, err := s.Quotes(Filter{Lang: "tr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) > 0 && got[0].ID == 0 {
+		t.Errorf("Quotes returned a quote without an ID")
+	}
+	want := []Quote{quotes[0]}
+	want[0].ID, want[0].Origin = got[0].ID, origin.Book
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Quotes(tr) = %+v, want %+v", got, want)
+	}
+
+	got, err = s.Quotes(Filter{Author: "Amos Oz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Lang != "es" || got[0].Provenance != again[1].Provenance {
+		t.Errorf("Quotes(Amos Oz) = %+v, want the filled-in Spanish quote", got)
+	}
+
+	got, err = s.Quotes(Filter{Lang: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Source != "goodreads" || got[0].Likes != 40 || got[0].Confidence != 0.9 || got[0].Provenance != quotes[1].Provenance {
+		t.Errorf("Quotes(en) = %+v, want the first source and provenance, the latest likes and the higher confidence", got)
+	}
+	// Quotes never scored are not confident ones
+	if got, _ := s.Quotes(Filter{MinConfidence: 0.8}); len(got) != 1 || got[0].Lang != "en" {
+		t.Errorf("Quotes(confidence 0.8) = %+v, want the English quote", got)
+	}
+
+	got, err = s.Quotes(Filter{Origin: origin.Unknown})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("Quotes(unknown origin) = %+v, want the English quote and the Spanish one once its author was filled in", got)
+	}
+
+	if got, _ := s.Quotes(Filter{Limit: 2}); len(got) != 2 {
+		t.Errorf("Quotes(limit 2) returned %d quotes", len(got))
+	}
+	// Characters, not bytes: the Spanish quote is 29 of them in 31 bytes
+	if got, _ := s.Quotes(Filter{MaxChars: 29}); len(got) != 2 {
+		t.Errorf("Quotes(max 29 characters) = %+v, want the English and Spanish quotes", got)
+	}
+
+	// A quote pinned to a day is its quote, for filters it matches
+	sch := s.(Scheduler)
+	rooney, _ := s.Quotes(Filter{Lang: "tr"})
+	if err := sch.Pin("2026-02-20", rooney[0].ID, "birthday"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sch.Pin("2026-02-20", rooney[0].ID, "Sally Rooney's birthday"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sch.Pin("2026-02-20", 9999, ""); err != ErrNotFound {
+		t.Errorf("Pin(no such quote) = %v, want ErrNotFound", err)
+	}
+	day := time.Date(2026, 2, 20, 9, 0, 0, 0, time.UTC)
+	if got, err := DailyQuote(s, Filter{}, day); err != nil || got.ID != rooney[0].ID {
+		t.Errorf("DailyQuote(pinned day) = %+v, %v; want the pinned quote", got, err)
+	}
+	if got, _ := DailyQuote(s, Filter{Lang: "en"}, day); got.Lang != "en" {
+		t.Errorf("DailyQuote(en) on the pinned day = %+v, want the English quote", got)
+	}
+	pins, err := sch.Schedule("2026-02-01", "2026-02-28")
+	if err != nil || len(pins) != 1 || pins[0].Quote.ID != rooney[0].ID || pins[0].Note != "Sally Rooney's birthday" || pins[0].Date != "2026-02-20" {
+		t.Errorf("Schedule = %+v, %v; want the pin with its new note", pins, err)
+	}
+	if n, err := sch.Unpin("2026-02-20", 0); err != nil || n != 1 {
+		t.Errorf("Unpin = %d, %v; want 1", n, err)
+	}
+
+	// Every quote comes up once before any comes up twice
+	seen := make(map[int64]bool)
+	for i := 0; i < 3; i++ {
+		q, err := s.RandomQuote(Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[q.ID] || q.ViewCount != 1 {
+			t.Errorf("RandomQuote #%d = %+v, want an unseen quote now shown once", i+1, q)
+		}
+		seen[q.ID] = true
+	}
+	if q, err := s.RandomQuote(Filter{Lang: "en"}); err != nil || q.Text != quotes[1].Text || q.ViewCount != 2 {
+		t.Errorf("RandomQuote(en) = %+v, %v", q, err)
+	}
+	if _, err := s.RandomQuote(Filter{Lang: "fr"}); err != ErrNotFound {
+		t.Errorf("RandomQuote(fr) = %v, want ErrNotFound", err)
+	}
+
+	if n, err := s.SaveAuthors([]Author{{Name: "Sally Rooney", Link: "https://1000kitap.com/yazar/sally-rooney"}, {Name: "Amos Oz"}}); err != nil || n != 2 {
+		t.Fatalf("SaveAuthors = %d, %v; want 2 new", n, err)
+	}
+	if n, err := s.SaveAuthors([]Author{{Name: "Sally Rooney"}}); err != nil || n != 0 {
+		t.Fatalf("SaveAuthors again = %d, %v; want 0 new", n, err)
+	}
+
+	trivia := []Trivia{
+		{Category: "science", Question: "What is H2O?", Answer: "Water", WrongAnswers: []string{"Salt", "Hydrogen peroxide"}, Hint: "It covers most of the planet"},
+		{Category: "history", Question: "Who was the first Roman emperor?", Answer: "Augustus", ImageURL: "https://example.com/augustus.jpg"},
+	}
+	if n, err := s.SaveTrivia(trivia); err != nil || n != 2 {
+		t.Fatalf("SaveTrivia = %d, %v; want 2 new", n, err)
+	}
+	if n, err := s.SaveTrivia([]Trivia{{Category: "chemistry", Question: "What is H2O?", Answer: "Water"}}); err != nil || n != 0 {
+		t.Fatalf("SaveTrivia again = %d, %v; want 0 new", n, err)
+	}
+	if n, err := s.SaveTrivia([]Trivia{{Category: "history", Question: "Who was the first Roman emperor?", Answer: "Augustus", AudioURL: "https://example.com/augustus.mp3"}}); err != nil || n != 0 {
+		t.Fatalf("SaveTrivia with audio = %d, %v; want 0 new", n, err)
+	}
+	if got, err := s.Trivia("history", 0); err != nil || len(got) != 1 || got[0].Category != "History & Holidays" || got[0].ImageURL != "https://example.com/augustus.jpg" || got[0].AudioURL != "https://example.com/augustus.mp3" {
+		t.Errorf("Trivia(history) = %+v, %v; want the image kept and the audio added under the canonical category", got, err)
+	}
+	gotTrivia, err := s.Trivia("chemistry", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotTrivia) != 1 || gotTrivia[0].Question != "What is H2O?" || len(gotTrivia[0].WrongAnswers) != 2 || gotTrivia[0].Hint == "" {
+		t.Errorf("Trivia(chemistry) = %+v, want the recategorized question with its choices and hint kept", gotTrivia)
+	}
+}
+
+func TestBatchRepeatedKeys(t *testing.T) {
+	s, err := OpenWith("sqlite:"+filepath.Join(t.TempDir(), "database.db"), Options{BatchSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Later rows win for trivia, as they would inserted one at a time,
+	// including when the repeat lands in the same statement
+	trivia := []Trivia{
+		{Category: "a", Question: "Q1", Answer: "first"},
+		{Category: "a", Question: "Q1", Answer: "second"},
+		{Category: "a", Question: "Q2", Answer: "x"},
+		{Category: "a", Question: "Q3", Answer: "y"},
+		{Category: "a", Question: "Q2", Answer: "z"},
+	}
+	n, err := s.SaveTrivia(trivia)
+	if err != nil || n != 3 {
+		t.Fatalf("SaveTrivia = %d, %v; want 3 new", n, err)
+	}
+	got, err := s.Trivia("a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answers := make(map[string]string)
+	for _, q := range got {
+		answers[q.Question] = q.Answer
+	}
+	if answers["Q1"] != "second" || answers["Q2"] != "z" || answers["Q3"] != "y" {
+		t.Errorf("answers = %v", answers)
+	}
+}
+
+func TestDailyQuote(t *testing.T) {
+	s := NewMemory()
+	var quotes []Quote
+	for _, text := range []string{"Bir.", "İki.", "Üç.", "Dört.", "Beş.", "Altı.", "Yedi.", "Sekiz."} {
+		quotes = append(quotes, Quote{Text: text, Lang: "tr"})
+	}
+	if _, err := s.SaveQuotes(quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	// Midnight in Istanbul is still the day before in New York
+	istanbul, err := Day("2024-05-02", "Europe/Istanbul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := istanbul.In(time.UTC).Format(time.DateOnly); d != "2024-05-01" {
+		t.Fatalf("Day(2024-05-02, Istanbul) starts on %s in UTC", d)
+	}
+	morning := istanbul.Add(9 * time.Hour)
+	first, err := DailyQuote(s, Filter{}, istanbul)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if later, _ := DailyQuote(s, Filter{}, morning); later.Text != first.Text {
+		t.Errorf("quote changed during the day: %q, then %q", first.Text, later.Text)
+	}
+
+	picked := make(map[string]bool)
+	for d := 0; d < 30; d++ {
+		q, _ := DailyQuote(s, Filter{}, istanbul.AddDate(0, 0, d))
+		picked[q.Text] = true
+	}
+	if len(picked) < 4 {
+		t.Errorf("30 days used only %d of 8 quotes", len(picked))
+	}
+
+	if _, err := DailyQuote(s, Filter{Lang: "en"}, istanbul); err != ErrNotFound {
+		t.Errorf("DailyQuote(en) = %v, want ErrNotFound", err)
+	}
+	if _, err := Day("", "Mars/Olympus"); err == nil {
+		t.Errorf("Day accepted an unknown time zone")
+	}
+}
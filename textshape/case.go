@@ -0,0 +1,70 @@
+// Package textshape prepares quote text for rendering: casing that follows
+// the quote's language, and line breaking by the Unicode line breaking
+// algorithm (UAX #14) instead of at spaces, so inverted Spanish punctuation,
+// dashes, numbers and CJK text wrap where readers expect.
+package textshape
+
+import (
+	"strings"
+	"unicode"
+)
+
+// special returns the casing rules of lang: Turkish and Azerbaijani pair
+// dotted İ with i and dotless I with ı
+func special(lang string) unicode.SpecialCase {
+	switch lang {
+	case "tr", "az":
+		return unicode.TurkishCase
+	}
+	return nil
+}
+
+// Upper uppercases s by the rules of lang, e.g. "istanbul" becomes
+// "İSTANBUL" in Turkish
+func Upper(s, lang string) string {
+	if c := special(lang); c != nil {
+		return strings.ToUpperSpecial(c, s)
+	}
+	return strings.ToUpper(s)
+}
+
+// Lower lowercases s by the rules of lang, e.g. "ISPARTA" becomes "ısparta"
+// in Turkish. A decomposed İ (I and a combining dot) lowers to i there too.
+func Lower(s, lang string) string {
+	if c := special(lang); c != nil {
+		return strings.ToLowerSpecial(c, strings.ReplaceAll(s, "I\u0307", "\u0130"))
+	}
+	return strings.ToLower(s)
+}
+
+// Title capitalizes the first letter of every word of s and lowercases the
+// rest by the rules of lang, for names shouted in all caps by a source: "ALİ"
+// becomes "Ali" in Turkish. Letters after an apostrophe inside a word, as in
+// "don't", are not word starts.
+func Title(s, lang string) string {
+	c := special(lang)
+	runes := []rune(Lower(s, lang))
+	for i, r := range runes {
+		if !unicode.IsLetter(r) || !wordStart(runes, i) {
+			continue
+		}
+		if c != nil {
+			runes[i] = c.ToTitle(r)
+		} else {
+			runes[i] = unicode.ToTitle(r)
+		}
+	}
+	return string(runes)
+}
+
+// wordStart reports whether the letter at i begins a word
+func wordStart(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := runes[i-1]
+	if prev == '\'' || prev == '’' {
+		return i < 2 || !unicode.IsLetter(runes[i-2])
+	}
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && !unicode.Is(unicode.M, prev)
+}
@@ -0,0 +1,203 @@
+package textshape
+
+import (
+	"strings"
+	"unicode"
+)
+
+// softHyphen marks where Wrap may break a word, showing a hyphen if it does
+const softHyphen = '\u00ad'
+
+// Hyphenate inserts soft hyphens where the words of text may be split by the
+// rules of lang, so Wrap can break long words on narrow displays:
+//
+//	textshape.Wrap(textshape.Hyphenate(q.Text, q.Lang), width, measure)
+//
+// Turkish and Spanish are split into syllables by their spelling rules.
+// English spelling does not give its syllables away, so English words are
+// only split before a few unambiguous suffixes (-tion, -ment, -ness...) and
+// after a few prefixes. Other languages are left alone.
+func Hyphenate(text, lang string) string {
+	split, ok := splitters[lang]
+	if !ok {
+		return text
+	}
+
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.Is(unicode.M, runes[j])) {
+			j++
+		}
+		word := runes[i:j]
+		points := split.points(lower(word, lang))
+		next := 0
+		for k, r := range word {
+			if next < len(points) && points[next] == k {
+				if k >= split.left && len(word)-k >= split.right {
+					b.WriteRune(softHyphen)
+				}
+				next++
+			}
+			b.WriteRune(r)
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// lower lowercases a word rune by rune, keeping its length so points index
+// the original
+func lower(word []rune, lang string) []rune {
+	c := special(lang)
+	out := make([]rune, len(word))
+	for i, r := range word {
+		if c != nil {
+			out[i] = c.ToLower(r)
+		} else {
+			out[i] = unicode.ToLower(r)
+		}
+	}
+	return out
+}
+
+// splitter finds where a lowercase word may be hyphenated: the indexes of
+// the runes that may start a new line. left and right are the fewest letters
+// to leave on either side.
+type splitter struct {
+	points      func(word []rune) []int
+	left, right int
+}
+
+var splitters = map[string]splitter{
+	"tr": {points: turkishPoints, left: 2, right: 2},
+	"es": {points: spanishPoints, left: 2, right: 2},
+	"en": {points: englishPoints, left: 2, right: 3},
+}
+
+// turkishPoints splits by the Turkish rule: every syllable has one vowel,
+// and of the consonants between two vowels only the last starts the next
+// syllable, as in kork-mak, Türk-çe, kont-rol. Vowels next to each other are
+// split too: sa-at, şi-ir.
+func turkishPoints(word []rune) []int {
+	var points []int
+	last := -1
+	for i, r := range word {
+		if !strings.ContainsRune("aeıioöuüâîû", r) {
+			continue
+		}
+		if last >= 0 {
+			if i == last+1 {
+				points = append(points, i)
+			} else {
+				points = append(points, i-1)
+			}
+		}
+		last = i
+	}
+	return points
+}
+
+// spanishPoints splits by the Spanish syllable rules. Vowels next to each
+// other share a syllable (a diphthong) unless both are strong, a, e, o or an
+// accented í or ú: cau-sa but le-er and dí-a. ch, ll and rr are one sound,
+// and a consonant followed by l or r (pl, br, tr...) starts a syllable
+// together: ha-blar, o-tro. Otherwise one consonant between vowels goes to
+// the next syllable, two are split, three keep the last two together if
+// they can start a syllable and four are split down the middle.
+func spanishPoints(word []rune) []int {
+	const vowels = "aeiouáéíóúü"
+	strong := func(r rune) bool { return strings.ContainsRune("aeoáéíóú", r) }
+
+	// Letters grouped into sounds: ch, ll and rr are one
+	type sound struct {
+		at    int
+		text  string
+		vowel bool
+	}
+	var sounds []sound
+	for i := 0; i < len(word); i++ {
+		if i+1 < len(word) {
+			if pair := string(word[i : i+2]); pair == "ch" || pair == "ll" || pair == "rr" {
+				sounds = append(sounds, sound{at: i, text: pair})
+				i++
+				continue
+			}
+		}
+		sounds = append(sounds, sound{at: i, text: string(word[i]), vowel: strings.ContainsRune(vowels, word[i])})
+	}
+
+	onset := func(a, b string) bool {
+		return (b == "l" || b == "r") && strings.Contains("pbfcgkdt", a) && len(a) == 1 && a+b != "dl"
+	}
+
+	var points []int
+	lastVowel := -1
+	for i, s := range sounds {
+		if !s.vowel {
+			continue
+		}
+		if lastVowel >= 0 {
+			between := sounds[lastVowel+1 : i]
+			switch n := len(between); {
+			case n == 0:
+				if strong([]rune(sounds[lastVowel].text)[0]) && strong([]rune(s.text)[0]) {
+					points = append(points, s.at)
+				}
+			case n == 1:
+				points = append(points, between[0].at)
+			case n == 2:
+				if onset(between[0].text, between[1].text) {
+					points = append(points, between[0].at)
+				} else {
+					points = append(points, between[1].at)
+				}
+			case n == 3:
+				if onset(between[1].text, between[2].text) {
+					points = append(points, between[1].at)
+				} else {
+					points = append(points, between[2].at)
+				}
+			default:
+				points = append(points, between[n/2].at)
+			}
+		}
+		lastVowel = i
+	}
+	return points
+}
+
+var (
+	englishSuffixes = []string{"tion", "sion", "ment", "ness", "less", "ful"}
+	englishPrefixes = []string{"counter", "under", "over"}
+)
+
+// englishPoints splits before the suffixes and after the prefixes above,
+// when what is left has a vowel
+func englishPoints(word []rune) []int {
+	hasVowel := func(rs []rune) bool {
+		return strings.ContainsFunc(string(rs), func(r rune) bool { return strings.ContainsRune("aeiouy", r) })
+	}
+	var points []int
+	for _, p := range englishPrefixes {
+		if n := len([]rune(p)); strings.HasPrefix(string(word), p) && hasVowel(word[n:]) {
+			points = append(points, n)
+			break
+		}
+	}
+	for _, s := range englishSuffixes {
+		if n := len(word) - len([]rune(s)); strings.HasSuffix(string(word), s) && n > 0 && hasVowel(word[:n]) {
+			if len(points) == 0 || points[len(points)-1] < n {
+				points = append(points, n)
+			}
+			break
+		}
+	}
+	return points
+}
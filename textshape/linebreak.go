@@ -0,0 +1,275 @@
+package textshape
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// class is a UAX #14 line breaking class. Classes a quote card never meets,
+// such as the Hangul jamo and Hebrew letter ones, are folded into the closest
+// common one.
+type class uint8
+
+const (
+	clsAL  class = iota // alphabetic and most symbols, the default
+	clsBK               // mandatory break
+	clsCR               // carriage return
+	clsLF               // line feed
+	clsNL               // next line
+	clsSP               // space
+	clsZW               // zero width space
+	clsWJ               // word joiner
+	clsGL               // non-breaking ("glue")
+	clsZWJ              // zero width joiner
+	clsCM               // combining mark
+	clsOP               // opening punctuation, including ¿ and ¡
+	clsCL               // closing punctuation
+	clsCP               // closing parenthesis
+	clsQU               // quotation mark
+	clsEX               // exclamation and question marks
+	clsIS               // infix numeric separator
+	clsSY               // slash
+	clsNS               // non-starter
+	clsHY               // hyphen-minus
+	clsBA               // break after
+	clsBB               // break before
+	clsB2               // em dash, breakable on either side
+	clsIN               // ellipsis
+	clsPR               // prefix, such as currency signs
+	clsPO               // postfix, such as %
+	clsNU               // digit
+	clsID               // ideograph, breakable on either side
+	clsRI               // regional indicator, flags come in pairs
+)
+
+// classOf returns the line breaking class of r
+func classOf(r rune) class {
+	switch r {
+	case '\n':
+		return clsLF
+	case '\r':
+		return clsCR
+	case 0x0B, 0x0C, 0x2028, 0x2029:
+		return clsBK
+	case 0x85:
+		return clsNL
+	case ' ':
+		return clsSP
+	case 0x200B:
+		return clsZW
+	case 0x2060, 0xFEFF:
+		return clsWJ
+	case 0xA0, 0x202F, 0x2007, 0x2011, 0x034F, 0x180E, 0x0F0C:
+		return clsGL
+	case 0x200D:
+		return clsZWJ
+	case '(', '[', 0xA1, 0xBF, 0x2E18:
+		return clsOP
+	case ')', ']':
+		return clsCP
+	case '!', '?', 0x061F, 0xFF01, 0xFF1F:
+		return clsEX
+	case ',', '.', ':', ';', 0x037E, 0x0589, 0x060C, 0x060D, 0x2044, 0xFE10, 0xFE13, 0xFE14:
+		return clsIS
+	case '/':
+		return clsSY
+	case '-':
+		return clsHY
+	case '\t', '|', 0xAD, 0x058A, 0x2010, 0x2012, 0x2013, 0x2027, 0x1680, 0x205F, 0x3000:
+		return clsBA
+	case 0x2014, 0x2E3A, 0x2E3B:
+		return clsB2
+	case 0xB4, 0x02C8, 0x02CC, 0x02DF, 0x1FFD:
+		return clsBB
+	case 0x2024, 0x2025, 0x2026, 0x22EF, 0xFE19:
+		return clsIN
+	case '"', '\'':
+		return clsQU
+	case '%', 0xA2, 0xB0, 0x2030, 0x2031, 0x2103, 0x2109, 0xFF05, 0xFFE0:
+		return clsPO
+	case '$', '+', '\\', 0xB1, 0x2116, 0x2212, 0x2213:
+		return clsPR
+	case 0x203C, 0x203D, 0x2047, 0x2048, 0x2049, 0x3005, 0x303B, 0x309D, 0x309E, 0x30FB, 0x30FC, 0x30FD, 0x30FE:
+		return clsNS
+	case 0x3001, 0x3002, 0xFE11, 0xFE12, 0xFF0C, 0xFF0E, 0xFF61, 0xFF64:
+		return clsCL
+	}
+
+	switch {
+	case r >= 0x2000 && r <= 0x200A && r != 0x2007:
+		return clsBA // the typographic spaces
+	case r >= 0x2032 && r <= 0x2037:
+		return clsPO // primes
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return clsRI
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		return clsCM // skin tone modifiers stay with their emoji
+	case r >= 0x1F000 && r <= 0x1FAFF, r >= 0x20000 && r <= 0x3FFFD:
+		return clsID
+	case unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me, unicode.Cc):
+		return clsCM
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		return clsID
+	case unicode.Is(unicode.Ps, r):
+		return clsOP
+	case unicode.Is(unicode.Pe, r):
+		return clsCL
+	case unicode.In(r, unicode.Pi, unicode.Pf):
+		return clsQU
+	case unicode.Is(unicode.Sc, r):
+		return clsPR
+	case unicode.Is(unicode.Nd, r):
+		return clsNU
+	}
+	return clsAL
+}
+
+// Break is a place text may be broken: the next line starts at Pos. A
+// Mandatory break follows a newline and must be taken.
+type Break struct {
+	Pos       int // byte offset
+	Mandatory bool
+}
+
+// Breaks returns where text may be broken into lines, by the rules of UAX #14
+// (LB4 to LB31) on the classes above. The end of the text, where a break is
+// always taken, is not included.
+func Breaks(text string) []Break {
+	type unit struct {
+		pos      int
+		raw, cls class // cls resolves combining marks to their base (LB9, LB10)
+		attached bool  // a combining mark or joiner taking its base's class
+	}
+	var units []unit
+	for pos, r := range text {
+		c := classOf(r)
+		u := unit{pos: pos, raw: c, cls: c}
+		if c == clsCM || c == clsZWJ {
+			u.cls = clsAL
+			if n := len(units); n > 0 {
+				switch base := units[n-1].cls; base {
+				case clsBK, clsCR, clsLF, clsNL, clsSP, clsZW:
+				default:
+					u.cls, u.attached = base, true
+				}
+			}
+		}
+		units = append(units, u)
+	}
+
+	var breaks []Break
+	lastBase := clsAL // class before the current run of spaces
+	ri := 0           // regional indicators in a row
+	for i := 1; i < len(units); i++ {
+		a, b := units[i-1].cls, units[i].cls
+		if a != clsSP {
+			lastBase = a
+		}
+		if a == clsRI {
+			ri++
+		} else {
+			ri = 0
+		}
+
+		if br, ok := pairBreak(a, b, units[i-1].raw == clsZWJ, units[i].attached, lastBase, ri); ok {
+			breaks = append(breaks, Break{Pos: units[i].pos, Mandatory: br})
+		}
+	}
+	return breaks
+}
+
+// pairBreak decides the boundary between a unit of class a and one of class
+// b: whether there is a break opportunity (ok) and whether it is mandatory.
+// joined is set after a zero width joiner and attached when b is a mark
+// taking a's class; before is the class of the last unit before the spaces a
+// may end, ri how many regional indicators end at a.
+func pairBreak(a, b class, joined, attached bool, before class, ri int) (mandatory, ok bool) {
+	switch {
+	case a == clsBK: // LB4
+		return true, true
+	case a == clsCR && b == clsLF: // LB5
+		return false, false
+	case a == clsCR, a == clsLF, a == clsNL:
+		return true, true
+	case b == clsBK, b == clsCR, b == clsLF, b == clsNL: // LB6
+		return false, false
+	case b == clsSP, b == clsZW: // LB7
+		return false, false
+	case before == clsZW: // LB8
+		return false, true
+	case joined, attached: // LB8a, LB9
+		return false, false
+	case a == clsWJ, b == clsWJ: // LB11
+		return false, false
+	case a == clsGL: // LB12
+		return false, false
+	case b == clsGL && a != clsSP && a != clsBA && a != clsHY: // LB12a
+		return false, false
+	case b == clsCL, b == clsCP, b == clsEX, b == clsIS, b == clsSY: // LB13
+		return false, false
+	case before == clsOP: // LB14: ¿ and ( never end a line
+		return false, false
+	case before == clsQU && b == clsOP: // LB15
+		return false, false
+	case (before == clsCL || before == clsCP) && b == clsNS: // LB16
+		return false, false
+	case before == clsB2 && b == clsB2: // LB17
+		return false, false
+	case a == clsSP: // LB18
+		return false, true
+	case a == clsQU, b == clsQU: // LB19
+		return false, false
+	case b == clsBA, b == clsHY, b == clsNS, a == clsBB: // LB21
+		return false, false
+	case b == clsIN: // LB22
+		return false, false
+	case a == clsAL && b == clsNU, a == clsNU && b == clsAL: // LB23
+		return false, false
+	case a == clsPR && b == clsID, a == clsID && b == clsPO: // LB23a
+		return false, false
+	case (a == clsPR || a == clsPO) && b == clsAL, a == clsAL && (b == clsPR || b == clsPO): // LB24
+		return false, false
+	case numeric(a, b): // LB25
+		return false, false
+	case a == clsAL && b == clsAL: // LB28
+		return false, false
+	case a == clsIS && b == clsAL: // LB29
+		return false, false
+	case (a == clsAL || a == clsNU) && b == clsOP, a == clsCP && (b == clsAL || b == clsNU): // LB30
+		return false, false
+	case a == clsRI && b == clsRI && ri%2 == 1: // LB30a
+		return false, false
+	}
+	return false, true // LB31
+}
+
+// numeric reports whether a and b are parts of a number that LB25 keeps
+// together, such as $1,000.50 or 25%
+func numeric(a, b class) bool {
+	switch {
+	case (a == clsCL || a == clsCP) && (b == clsPO || b == clsPR):
+		return true
+	case a == clsNU && (b == clsPO || b == clsPR || b == clsNU):
+		return true
+	case (a == clsPO || a == clsPR) && (b == clsOP || b == clsNU):
+		return true
+	case (a == clsHY || a == clsIS || a == clsSY) && b == clsNU:
+		return true
+	}
+	return false
+}
+
+// lineEnd trims what a line may end with but not display: spaces and the
+// newline a mandatory break follows
+func lineEnd(line string) string {
+	for len(line) > 0 {
+		r, size := utf8.DecodeLastRuneInString(line)
+		switch classOf(r) {
+		case clsSP, clsBK, clsCR, clsLF, clsNL, clsZW:
+			line = line[:len(line)-size]
+			continue
+		}
+		break
+	}
+	return line
+}
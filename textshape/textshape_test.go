@@ -0,0 +1,135 @@
+package textshape
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCase(t *testing.T) {
+	for _, tc := range []struct {
+		fn         func(s, lang string) string
+		in, lang   string
+		want       string
+		whatItDoes string
+	}{
+		{Upper, "istanbul ılık", "tr", "İSTANBUL ILIK", "Turkish dotted and dotless i"},
+		{Upper, "istanbul", "en", "ISTANBUL", "English i"},
+		{Lower, "ISPARTA İZMİR", "tr", "ısparta izmir", "Turkish dotless I"},
+		{Lower, "İZMİR", "tr", "izmir", "decomposed Turkish İ"},
+		{Title, "SABAHATTİN ALİ", "tr", "Sabahattin Ali", "Turkish name in capitals"},
+		{Title, "DON'T LOOK BACK in anger", "en", "Don't Look Back In Anger", "apostrophe inside a word"},
+		{Title, "gabriel garcía márquez", "es", "Gabriel García Márquez", "accented letters"},
+	} {
+		if got := tc.fn(tc.in, tc.lang); got != tc.want {
+			t.Errorf("%s: %q in %s = %q, want %q", tc.whatItDoes, tc.in, tc.lang, got, tc.want)
+		}
+	}
+}
+
+// lines splits text at every break opportunity, marking mandatory ones
+func lines(text string) []string {
+	var out []string
+	start := 0
+	for _, br := range Breaks(text) {
+		piece := text[start:br.Pos]
+		if br.Mandatory {
+			piece += "!"
+		}
+		out = append(out, piece)
+		start = br.Pos
+	}
+	return append(out, text[start:])
+}
+
+func TestBreaks(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []string
+	}{
+		// Inverted marks stay with their word, even with a space after them
+		{"¿Qué tal? ¡Muy bien!", []string{"¿Qué ", "tal? ", "¡Muy ", "bien!"}},
+		// ...and an opening guillemet with them; French puts no-break spaces
+		// inside guillemets because a plain one may break before »
+		{"« ¿ Por qué ? »", []string{"« ¿ Por ", "qué ? ", "»"}},
+		{"well-known (really) 1,000.50 $ 25%", []string{"well-", "known ", "(really) ", "1,000.50 ", "$ ", "25%"}},
+		{"wait—what", []string{"wait", "—", "what"}},
+		{"a\u00a0b c", []string{"a\u00a0b ", "c"}},
+		{"“Quote,” he said.", []string{"“Quote,” ", "he ", "said."}},
+		{"line\nnext", []string{"line\n!", "next"}},
+		{"e\u0301 x", []string{"e\u0301 ", "x"}},
+		{"日本語の文", []string{"日", "本", "語", "の", "文"}},
+		{"🇹🇷🇪🇸", []string{"🇹🇷", "🇪🇸"}},
+	} {
+		if got := lines(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Breaks(%q) split into %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	got := Wrap("¿Dónde está la biblioteca? ¡No lo sé!", 12, nil)
+	want := []string{"¿Dónde está", "la", "biblioteca?", "¡No lo sé!"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrap = %q, want %q", got, want)
+	}
+
+	got = Wrap("first\n\nthird", 40, nil)
+	if want := []string{"first", "", "third"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrap kept %q of the blank line, want %q", got, want)
+	}
+
+	got = Wrap("an incom\u00adprehensible word", 12, nil)
+	if want := []string{"an incom-", "prehensible", "word"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrap at a soft hyphen = %q, want %q", got, want)
+	}
+
+	// Nothing fits in 3 columns, so every piece gets its own line
+	got = Wrap("abcdef ghi", 3, nil)
+	if want := []string{"abcdef", "ghi"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrap of overlong words = %q, want %q", got, want)
+	}
+
+	for _, line := range Wrap(strings.Repeat("söz ", 50), 20, nil) {
+		if Columns(line) > 20 {
+			t.Errorf("line %q is over 20 columns", line)
+		}
+	}
+}
+
+func TestHyphenate(t *testing.T) {
+	for _, tc := range []struct {
+		lang, word, want string
+	}{
+		{"tr", "Çekoslovakyalılaştıramadıklarımızdanmışsınız", "Çe-kos-lo-vak-ya-lı-laş-tı-ra-ma-dık-la-rı-mız-dan-mış-sı-nız"},
+		{"tr", "korkmak", "kork-mak"},
+		{"tr", "kontrol", "kont-rol"},
+		{"tr", "saat", "sa-at"},
+		{"tr", "İstanbul'da", "İs-tan-bul'da"},
+		{"tr", "ada", "ada"}, // one letter may not stand alone
+		{"es", "biblioteca", "bi-blio-te-ca"},
+		{"es", "perro", "pe-rro"},
+		{"es", "muchacho", "mu-cha-cho"},
+		{"es", "instante", "ins-tan-te"},
+		{"es", "abstracto", "abs-trac-to"},
+		{"es", "poesía", "po-e-sía"}, // a lone final letter stays
+		{"es", "causa", "cau-sa"},
+		{"en", "nation", "na-tion"},
+		{"en", "happiness", "happi-ness"},
+		{"en", "understanding", "under-standing"},
+		{"en", "bless", "bless"},
+		{"de", "Donaudampfschiff", "Donaudampfschiff"},
+	} {
+		got := strings.ReplaceAll(Hyphenate(tc.word, tc.lang), "\u00ad", "-")
+		if got != tc.want {
+			t.Errorf("Hyphenate(%q, %s) = %q, want %q", tc.word, tc.lang, got, tc.want)
+		}
+	}
+
+	lines := Wrap(Hyphenate("Çekoslovakyalılaştıramadıklarımızdanmışsınız efendim", "tr"), 16, nil)
+	for _, line := range lines {
+		if Columns(line) > 16 {
+			t.Errorf("hyphenated line %q is over 16 columns (%q)", line, lines)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package textshape
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Measure returns how wide text is drawn, in whatever unit the wrap width
+// is given in: pixels for a font, columns for a terminal
+type Measure func(text string) float64
+
+// Columns measures text in characters, for terminals and fixed-width fonts
+func Columns(text string) float64 {
+	return float64(utf8.RuneCountInString(text))
+}
+
+// Wrap breaks text into lines no wider than width, filling each line as far
+// as it goes and breaking only where Breaks allows. A piece too wide to fit
+// any line is put on a line of its own. Lines do not end with the spaces or
+// newline they were broken at; a line broken at a soft hyphen ends with a
+// hyphen, and soft hyphens elsewhere are dropped. measure may be nil to
+// measure in Columns.
+func Wrap(text string, width float64, measure Measure) []string {
+	if measure == nil {
+		measure = Columns
+	}
+
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		lines = append(lines, finish(line.String()))
+		line.Reset()
+	}
+
+	start := 0
+	for _, br := range append(Breaks(text), Break{Pos: len(text)}) {
+		piece := text[start:br.Pos]
+		start = br.Pos
+		if line.Len() > 0 && measure(finish(line.String()+piece)) > width {
+			flush()
+		}
+		line.WriteString(piece)
+		if br.Mandatory {
+			flush()
+		}
+	}
+	if line.Len() > 0 || len(lines) == 0 {
+		flush()
+	}
+	return lines
+}
+
+// finish makes a line as drawn: trailing spaces go, a final soft hyphen shows
+// and the others vanish
+func finish(line string) string {
+	line = lineEnd(line)
+	hyphen := strings.HasSuffix(line, "\u00ad")
+	line = strings.ReplaceAll(line, "\u00ad", "")
+	if hyphen {
+		line += "-"
+	}
+	return line
+}
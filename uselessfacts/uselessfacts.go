@@ -0,0 +1,87 @@
+// Package uselessfacts downloads fun facts from the uselessfacts API, one
+// random fact a request.
+package uselessfacts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"quotesparser/fetch"
+	"quotesparser/source"
+)
+
+// BaseURL is the root of the uselessfacts API
+const BaseURL = "https://uselessfacts.jsph.pl"
+
+// Fact is a fact as the API answers it
+type Fact struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Source    string `json:"source"`
+	SourceURL string `json:"source_url"`
+	Language  string `json:"language"`
+	Permalink string `json:"permalink"`
+}
+
+// IDRe keeps file names safe whatever the API sends as an ID
+var IDRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// RandomURL returns the address answering a random fact in lang, e.g. en
+func RandomURL(lang string) string {
+	return BaseURL + "/api/v2/facts/random?language=" + lang
+}
+
+// Decode reads a fact, failing on anything else the API may answer
+func Decode(body []byte) (Fact, error) {
+	var fact Fact
+	if err := json.Unmarshal(body, &fact); err != nil {
+		return fact, fmt.Errorf("failed to parse fact: %v", err)
+	}
+	if strings.TrimSpace(fact.Text) == "" || !IDRe.MatchString(fact.ID) {
+		return fact, fmt.Errorf("unexpected fact %q", body)
+	}
+	return fact, nil
+}
+
+func init() {
+	source.RegisterAdapter(Adapter{})
+}
+
+// Adapter is uselessfacts for quotes download: a target is a language, and
+// its pages are random facts, as many as the download allows
+type Adapter struct{}
+
+func (Adapter) Name() string { return "uselessfacts" }
+
+// Discover visits the random fact address until visit stops it
+func (Adapter) Discover(ctx context.Context, c *fetch.Client, target string, visit func(string) (int, error)) error {
+	lang := strings.ToLower(strings.TrimSpace(target))
+	for ctx.Err() == nil {
+		if _, err := visit(RandomURL(lang)); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// Fetch downloads a fact, retrying answers that are not one
+func (Adapter) Fetch(ctx context.Context, c *fetch.Client, url string) ([]byte, error) {
+	facts := *c
+	facts.Validate = func(body []byte) error {
+		_, err := Decode(body)
+		return err
+	}
+	return facts.GetContext(ctx, url)
+}
+
+// Parse reads a saved fact as a quote without an author
+func (Adapter) Parse(url string, page []byte) ([]source.Quote, error) {
+	fact, err := Decode(page)
+	if err != nil {
+		return nil, err
+	}
+	return []source.Quote{{Text: strings.TrimSpace(fact.Text), Lang: fact.Language}}, nil
+}
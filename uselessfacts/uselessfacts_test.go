@@ -0,0 +1,25 @@
+package uselessfacts
+
+import (
+	"reflect"
+	"testing"
+
+	"quotesparser/source"
+)
+
+func TestParse(t *testing.T) {
+	page := []byte(`{"id":"abc-1","text":" Bananas are berries. ","source":"djtech.net","language":"en"}`)
+	got, err := Adapter{}.Parse(RandomURL("en"), page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []source.Quote{{Text: "Bananas are berries.", Lang: "en"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{`<html></html>`, `{"id":"../x","text":"t"}`, `{"id":"x","text":" "}`} {
+		if _, err := Decode([]byte(bad)); err == nil {
+			t.Errorf("Decode(%s) succeeded", bad)
+		}
+	}
+}
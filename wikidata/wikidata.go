@@ -0,0 +1,320 @@
+// Package wikidata looks authors up on Wikidata: when they were born and
+// died, the countries they were citizens of, their picture, and a short bio
+// from the opening of their Wikipedia article. quotes authors enrich saves
+// what it finds with the authors.
+package wikidata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"quotesparser/fetch"
+)
+
+// DefaultAPI is the Wikibase API of Wikidata
+const DefaultAPI = "https://www.wikidata.org/w/api.php"
+
+// DefaultWikipediaAPI is the MediaWiki API of each language's Wikipedia; %s
+// is the language code
+const DefaultWikipediaAPI = "https://%s.wikipedia.org/w/api.php"
+
+// FilePath is the address of Commons files by name, scaled to a width that
+// covers the sizes images are cached at
+const FilePath = "https://commons.wikimedia.org/wiki/Special:FilePath/%s?width=1024"
+
+// DefaultUserAgent identifies the crawler, as the Wikimedia API asks clients to
+const DefaultUserAgent = "quotesparser-wikidata/1.0 (author metadata for a quotes database)"
+
+// ErrNotFound is returned when no Wikipedia tried has an article on a person
+// of that name
+var ErrNotFound = errors.New("no person on Wikidata")
+
+// Properties and items of Wikidata
+const (
+	instanceOf  = "P31"
+	human       = "Q5"
+	dateOfBirth = "P569"
+	dateOfDeath = "P570"
+	citizenship = "P27"
+	picture     = "P18"
+)
+
+// Person is what Wikidata knows of an author
+type Person struct {
+	ID          string // item, e.g. Q312739
+	Page        string // Wikipedia article the author was found by
+	BirthYear   int    // 0 when unknown, negative before the common era
+	DeathYear   int    // 0 when unknown or alive
+	Nationality string // countries of citizenship in the article's language, e.g. Türkiye
+	ImageURL    string // of their picture on Commons, empty when there is none
+	Bio         string // the first sentences of the article, else the item's description
+}
+
+// Finder looks authors up by the title of their Wikipedia article
+type Finder struct {
+	Client       *fetch.Client
+	API          string   // Wikibase api.php address
+	WikipediaAPI string   // MediaWiki api.php address with %s for the language
+	Wikis        []string // languages tried in order, e.g. en, tr, es
+	Sentences    int      // of the article kept as the bio
+
+	labels map[string]string // of countries, by language and item
+}
+
+// NewFinder returns a Finder trying English, Turkish and Spanish Wikipedia,
+// the languages of the quote sources
+func NewFinder() *Finder {
+	c := fetch.NewClient()
+	c.UserAgent = DefaultUserAgent
+	return &Finder{
+		Client:       c,
+		API:          DefaultAPI,
+		WikipediaAPI: DefaultWikipediaAPI,
+		Wikis:        []string{"en", "tr", "es"},
+		Sentences:    2,
+	}
+}
+
+// Find returns the person the article titled name is about, on the first
+// Wikipedia that has one. Articles about anything but a person, such as a
+// band or a book named like the author, do not count.
+func (f *Finder) Find(name string) (Person, error) {
+	for _, lang := range f.Wikis {
+		p, err := f.find(lang, name)
+		if err == nil {
+			return p, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return p, err
+		}
+	}
+	return Person{}, fmt.Errorf("%w for %s", ErrNotFound, name)
+}
+
+// claim is a statement of an item
+type claim struct {
+	Rank     string `json:"rank"`
+	MainSnak struct {
+		DataValue struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"datavalue"`
+	} `json:"mainsnak"`
+}
+
+type entity struct {
+	ID           string             `json:"id"` // empty for a title with no item
+	Claims       map[string][]claim `json:"claims"`
+	Descriptions map[string]struct {
+		Value string `json:"value"`
+	} `json:"descriptions"`
+	Labels map[string]struct {
+		Value string `json:"value"`
+	} `json:"labels"`
+	Sitelinks map[string]struct {
+		Title string `json:"title"`
+	} `json:"sitelinks"`
+}
+
+func (f *Finder) find(lang, name string) (Person, error) {
+	var answer struct {
+		Entities map[string]entity `json:"entities"`
+	}
+	err := f.query(f.API, "Wikidata", url.Values{
+		"action":    {"wbgetentities"},
+		"sites":     {lang + "wiki"},
+		"titles":    {name},
+		"normalize": {"1"},
+		"props":     {"claims|descriptions|sitelinks"},
+		"languages": {lang},
+	}, &answer)
+	if err != nil {
+		return Person{}, err
+	}
+	var e entity
+	for _, found := range answer.Entities {
+		if found.ID != "" {
+			e = found
+		}
+	}
+	if e.ID == "" || !e.is(instanceOf, human) {
+		return Person{}, ErrNotFound
+	}
+
+	p := Person{
+		ID:        e.ID,
+		Page:      e.Sitelinks[lang+"wiki"].Title,
+		BirthYear: year(e.best(dateOfBirth)),
+		DeathYear: year(e.best(dateOfDeath)),
+		ImageURL:  file(e.best(picture)),
+	}
+	var countries []string
+	for _, c := range e.best(citizenship) {
+		if id := itemID(c); id != "" {
+			countries = append(countries, id)
+		}
+	}
+	if p.Nationality, err = f.names(lang, countries); err != nil {
+		return Person{}, err
+	}
+	if p.Page != "" {
+		if p.Bio, err = f.extract(lang, p.Page); err != nil {
+			return Person{}, err
+		}
+	}
+	if p.Bio == "" {
+		p.Bio = e.Descriptions[lang].Value
+	}
+	return p, nil
+}
+
+// is reports whether e has a statement of property with the item value
+func (e entity) is(property, value string) bool {
+	for _, c := range e.Claims[property] {
+		if itemID(c) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// best returns the statements of property Wikidata ranks preferred, or the
+// normal ones when none is; deprecated ones never count
+func (e entity) best(property string) []claim {
+	var preferred, normal []claim
+	for _, c := range e.Claims[property] {
+		switch c.Rank {
+		case "preferred":
+			preferred = append(preferred, c)
+		case "normal":
+			normal = append(normal, c)
+		}
+	}
+	if len(preferred) > 0 {
+		return preferred
+	}
+	return normal
+}
+
+// itemID is the item a statement points to, empty for other values
+func itemID(c claim) string {
+	var v struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(c.MainSnak.DataValue.Value, &v)
+	return v.ID
+}
+
+// year reads the year of the first of dates, 0 if there is none. Wikidata
+// writes times as +1907-02-25T00:00:00Z, and years before the common era
+// with a minus.
+func year(dates []claim) int {
+	for _, c := range dates {
+		var v struct {
+			Time string `json:"time"`
+		}
+		if json.Unmarshal(c.MainSnak.DataValue.Value, &v) != nil || len(v.Time) < 2 {
+			continue
+		}
+		end := strings.Index(v.Time[1:], "-") + 1
+		if end <= 0 {
+			continue
+		}
+		y, err := strconv.Atoi(v.Time[:end])
+		if err == nil && y != 0 {
+			return y
+		}
+	}
+	return 0
+}
+
+// file returns the FilePath of the first of files, empty if there is none.
+// Commons file names are written with spaces and read with underscores.
+func file(files []claim) string {
+	for _, c := range files {
+		var name string
+		if json.Unmarshal(c.MainSnak.DataValue.Value, &name) == nil && name != "" {
+			return fmt.Sprintf(FilePath, url.PathEscape(strings.ReplaceAll(name, " ", "_")))
+		}
+	}
+	return ""
+}
+
+// names returns the labels of items in lang joined by commas, asking
+// Wikidata only for those not asked before
+func (f *Finder) names(lang string, items []string) (string, error) {
+	if f.labels == nil {
+		f.labels = make(map[string]string)
+	}
+	var unknown []string
+	for _, id := range items {
+		if _, ok := f.labels[lang+"/"+id]; !ok {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		var answer struct {
+			Entities map[string]entity `json:"entities"`
+		}
+		err := f.query(f.API, "Wikidata", url.Values{
+			"action":           {"wbgetentities"},
+			"ids":              {strings.Join(unknown, "|")},
+			"props":            {"labels"},
+			"languages":        {lang},
+			"languagefallback": {"1"},
+		}, &answer)
+		if err != nil {
+			return "", err
+		}
+		for _, id := range unknown {
+			f.labels[lang+"/"+id] = answer.Entities[id].Labels[lang].Value
+		}
+	}
+	var names []string
+	for _, id := range items {
+		if name := f.labels[lang+"/"+id]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", "), nil
+}
+
+// extract returns the first Sentences of the article titled page, as text
+func (f *Finder) extract(lang, page string) (string, error) {
+	var answer struct {
+		Query struct {
+			Pages []struct {
+				Extract string `json:"extract"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	err := f.query(fmt.Sprintf(f.WikipediaAPI, lang), lang+" Wikipedia", url.Values{
+		"action":      {"query"},
+		"titles":      {page},
+		"prop":        {"extracts"},
+		"exintro":     {"1"},
+		"explaintext": {"1"},
+		"exsentences": {strconv.Itoa(f.Sentences)},
+	}, &answer)
+	if err != nil || len(answer.Query.Pages) == 0 {
+		return "", err
+	}
+	return strings.Join(strings.Fields(answer.Query.Pages[0].Extract), " "), nil
+}
+
+// query calls the API at api, named site in errors, and decodes the answer
+func (f *Finder) query(api, site string, params url.Values, v interface{}) error {
+	params.Set("format", "json")
+	params.Set("formatversion", "2")
+	body, err := f.Client.Get(api + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("bad answer from %s: %v", site, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package wikiquote
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// IsDump reports whether the file at path is an XML dump, compressed or not
+func IsDump(path string) bool {
+	if strings.HasSuffix(path, ".bz2") {
+		return true
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return bytes.Contains(head[:n], []byte("<mediawiki"))
+}
+
+// ReadDumpFile streams the articles of the dump at path through visit,
+// decompressing it when its name ends in .bz2
+func ReadDumpFile(path string, visit func(Page) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = bufio.NewReader(f)
+	if strings.HasSuffix(path, ".bz2") {
+		r = bzip2.NewReader(r)
+	}
+	if err := ReadDump(r, visit); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// dumpPage is a <page> of a dump
+type dumpPage struct {
+	Title    string    `xml:"title"`
+	NS       int       `xml:"ns"`
+	Redirect *struct{} `xml:"redirect"`
+	Text     string    `xml:"revision>text"`
+}
+
+// ReadDump streams the articles of a pages-articles dump through visit, one
+// page at a time, so a whole wiki is never held in memory. Their language
+// is the wiki's, from the dump's <dbname> such as trwikiquote; redirects
+// and pages outside the main namespace are skipped. It returns the first
+// error visit returns.
+func ReadDump(r io.Reader, visit func(Page) error) error {
+	dec := xml.NewDecoder(r)
+	lang := ""
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read dump: %v", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "dbname":
+			var dbname string
+			if err := dec.DecodeElement(&dbname, &start); err != nil {
+				return fmt.Errorf("failed to read dump: %v", err)
+			}
+			lang = strings.TrimSuffix(strings.TrimSpace(dbname), "wikiquote")
+		case "page":
+			var p dumpPage
+			if err := dec.DecodeElement(&p, &start); err != nil {
+				return fmt.Errorf("failed to read dump: %v", err)
+			}
+			if p.NS != 0 || p.Redirect != nil {
+				continue
+			}
+			if err := visit(Page{Title: p.Title, Lang: lang, Text: p.Text}); err != nil {
+				return err
+			}
+		}
+	}
+}
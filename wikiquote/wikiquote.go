@@ -0,0 +1,147 @@
+// Package wikiquote imports quotes from Wikiquote, live through the
+// MediaWiki API of each language's wiki or offline from its XML dumps.
+package wikiquote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"quotesparser/fetch"
+	"quotesparser/source"
+)
+
+// Languages are the wikis whose pages the extraction knows
+var Languages = []string{"en", "tr", "es"}
+
+func init() {
+	source.RegisterAdapter(Adapter{})
+}
+
+// Adapter is Wikiquote for quotes download: a target is a page, by title
+// with an optional language prefix (en:Albert Einstein, tr:Nâzım Hikmet) or
+// by URL. Its one page is the page's wikitext as the API answers it.
+type Adapter struct{}
+
+func (Adapter) Name() string { return "wikiquote" }
+
+// Discover visits the API address of the target's page
+func (Adapter) Discover(ctx context.Context, c *fetch.Client, target string, visit func(string) (int, error)) error {
+	lang, title, err := ParseTarget(target)
+	if err != nil {
+		return err
+	}
+	_, err = visit(APIURL(lang, title))
+	return err
+}
+
+// Fetch downloads a page, retrying answers cut off in transit
+func (Adapter) Fetch(ctx context.Context, c *fetch.Client, url string) ([]byte, error) {
+	api := *c
+	api.Validate = func(body []byte) error {
+		if !json.Valid(body) {
+			return errors.New("answer is not JSON")
+		}
+		return nil
+	}
+	return api.GetContext(ctx, url)
+}
+
+// Parse reads a page the API answered, in the language of the wiki at url
+func (Adapter) Parse(url string, page []byte) ([]source.Quote, error) {
+	var answer struct {
+		Parse struct {
+			Title    string `json:"title"`
+			Wikitext string `json:"wikitext"`
+		} `json:"parse"`
+		Error *struct {
+			Code string `json:"code"`
+			Info string `json:"info"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(page, &answer); err != nil {
+		return nil, fmt.Errorf("failed to parse API answer: %v", err)
+	}
+	if answer.Error != nil {
+		return nil, fmt.Errorf("wikiquote: %s: %s", answer.Error.Code, answer.Error.Info)
+	}
+	return Page{Title: answer.Parse.Title, Lang: urlLang(url), Text: answer.Parse.Wikitext}.Quotes(), nil
+}
+
+// IsDump reports whether path is a pages-articles dump rather than a page
+// the API answered
+func (Adapter) IsDump(path string) bool {
+	return IsDump(path)
+}
+
+// ParseDump reads the articles of a dump, as downloaded from
+// dumps.wikimedia.org with or without its .bz2 compression
+func (Adapter) ParseDump(path string) ([]source.Quote, error) {
+	var quotes []source.Quote
+	err := ReadDumpFile(path, func(p Page) error {
+		quotes = append(quotes, p.Quotes()...)
+		return nil
+	})
+	return quotes, err
+}
+
+// ParseTarget reads a target into the language of its wiki and the title
+// of its page. Titles without a language prefix are English ones.
+func ParseTarget(target string) (lang, title string, err error) {
+	target = strings.TrimSpace(target)
+	if u, err := url.Parse(target); err == nil && strings.HasSuffix(u.Host, ".wikiquote.org") {
+		lang = strings.TrimSuffix(u.Host, ".wikiquote.org")
+		title = strings.TrimPrefix(u.Path, "/wiki/")
+		if t := u.Query().Get("title"); t != "" {
+			title = t
+		}
+	} else if l, t, ok := strings.Cut(target, ":"); ok && len(l) <= 3 {
+		lang, title = strings.ToLower(l), t
+	} else {
+		lang, title = "en", target
+	}
+	title = strings.TrimSpace(strings.ReplaceAll(title, "_", " "))
+	if !known(lang) {
+		return "", "", fmt.Errorf("unsupported Wikiquote language %q (languages: %s)", lang, strings.Join(Languages, ", "))
+	}
+	if title == "" {
+		return "", "", fmt.Errorf("no page title in %q", target)
+	}
+	return lang, title, nil
+}
+
+// APIURL returns the address answering the wikitext of a page, following
+// redirects
+func APIURL(lang, title string) string {
+	q := url.Values{
+		"action":        {"parse"},
+		"format":        {"json"},
+		"formatversion": {"2"},
+		"prop":          {"wikitext"},
+		"redirects":     {"1"},
+		"page":          {title},
+	}
+	return "https://" + lang + ".wikiquote.org/w/api.php?" + q.Encode()
+}
+
+// urlLang returns the language of the wiki at address, or "" when address
+// is not a Wikiquote one
+func urlLang(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || !strings.HasSuffix(u.Host, ".wikiquote.org") {
+		return ""
+	}
+	return strings.TrimSuffix(u.Host, ".wikiquote.org")
+}
+
+func known(lang string) bool {
+	for _, l := range Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package wikiquote
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"quotesparser/source"
+)
+
+const einstein = `{{Wikipedia}}
+'''[[w:Albert Einstein|Albert Einstein]]''' (1879–1955) was a physicist.
+
+== Quotes ==
+=== 1900s ===
+* Imagination is more important than knowledge.<ref>{{cite book|title=On Science}}</ref>
+** ''[[Cosmic Religion]]'' (1931), p. 97
+* The only source of knowledge is [[experience]].
+
+=== The World as I See It (1934) ===
+* A happy man is too satisfied with the present to dwell too much on the future.
+** Written at 17.
+
+== Disputed ==
+* God does not play dice.
+=== Letters ===
+* Not his either.
+
+== Quotes about Einstein ==
+* He was a genius. — Someone
+
+== External links ==
+* [https://example.com Official site]
+`
+
+func TestQuotes(t *testing.T) {
+	got := Page{Title: "Albert Einstein", Lang: "en", Text: einstein}.Quotes()
+	want := []source.Quote{
+		{Text: "Imagination is more important than knowledge.", Author: "Albert Einstein", Book: "Cosmic Religion", Lang: "en"},
+		{Text: "The only source of knowledge is experience.", Author: "Albert Einstein", Lang: "en"},
+		{Text: "A happy man is too satisfied with the present to dwell too much on the future.", Author: "Albert Einstein", Book: "The World as I See It", Lang: "en"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Quotes = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	page := []byte(`{"parse":{"title":"Nâzım Hikmet","pageid":1,"wikitext":"== Sözler ==\n* Yaşamak bir ağaç gibi tek ve hür.\n== Hakkında ==\n* Büyük şair."}}`)
+	got, err := Adapter{}.Parse(APIURL("tr", "Nâzım Hikmet"), page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []source.Quote{{Text: "Yaşamak bir ağaç gibi tek ve hür.", Author: "Nâzım Hikmet", Lang: "tr"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v, want %+v", got, want)
+	}
+	if _, err := (Adapter{}).Parse("", []byte(`{"error":{"code":"missingtitle","info":"The page you specified doesn't exist."}}`)); err == nil {
+		t.Error("Parse of a missing page succeeded")
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for _, tc := range []struct{ target, lang, title string }{
+		{"Albert Einstein", "en", "Albert Einstein"},
+		{"tr:Nâzım Hikmet", "tr", "Nâzım Hikmet"},
+		{"https://es.wikiquote.org/wiki/Miguel_de_Cervantes", "es", "Miguel de Cervantes"},
+	} {
+		lang, title, err := ParseTarget(tc.target)
+		if err != nil || lang != tc.lang || title != tc.title {
+			t.Errorf("ParseTarget(%q) = %q, %q, %v; want %q, %q", tc.target, lang, title, err, tc.lang, tc.title)
+		}
+	}
+	if _, _, err := ParseTarget("de:Goethe"); err == nil {
+		t.Error("ParseTarget accepted an unsupported language")
+	}
+}
+
+func TestReadDump(t *testing.T) {
+	dump := `<mediawiki xmlns="http://www.mediawiki.org/xml/export-0.11/" xml:lang="es">
+  <siteinfo><sitename>Wikiquote</sitename><dbname>eswikiquote</dbname></siteinfo>
+  <page><title>Miguel de Cervantes</title><ns>0</ns><id>1</id>
+    <revision><id>2</id><text bytes="80" xml:space="preserve">== Citas ==
+* La pluma es la lengua del alma.
+** ''Don Quijote''</text></revision></page>
+  <page><title>Cervantes</title><ns>0</ns><id>3</id><redirect title="Miguel de Cervantes" />
+    <revision><id>4</id><text xml:space="preserve">#REDIRECT [[Miguel de Cervantes]]</text></revision></page>
+  <page><title>Plantilla:Cita</title><ns>10</ns><id>5</id>
+    <revision><id>6</id><text xml:space="preserve">* not an article</text></revision></page>
+</mediawiki>`
+	var pages []Page
+	if err := ReadDump(strings.NewReader(dump), func(p Page) error {
+		pages = append(pages, p)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || pages[0].Title != "Miguel de Cervantes" || pages[0].Lang != "es" {
+		t.Fatalf("pages = %+v, want Miguel de Cervantes in es", pages)
+	}
+	want := []source.Quote{{Text: "La pluma es la lengua del alma.", Author: "Miguel de Cervantes", Book: "Don Quijote", Lang: "es"}}
+	if got := pages[0].Quotes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Quotes = %+v, want %+v", got, want)
+	}
+}
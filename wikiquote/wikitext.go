@@ -0,0 +1,132 @@
+package wikiquote
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"quotesparser/source"
+)
+
+// Page is a Wikiquote article: its title, the language of its wiki and its
+// wikitext
+type Page struct {
+	Title string
+	Lang  string
+	Text  string
+}
+
+// skippedSections are words in the headings of sections that hold no quotes
+// by the page's subject: quotes about them, disputed or misattributed ones
+// and the links at the end. Sections under them are skipped too.
+var skippedSections = map[string]*regexp.Regexp{
+	"en": headings(`about|disputed|misattributed|see also|external links|references|notes|sources|cast`),
+	"tr": headings(`hakkında|hakkinda|tartışmalı|yanlış atfedilen|yanlış atfedilenler|ayrıca bakınız|dış bağlantılar|kaynakça|kaynaklar|notlar`),
+	"es": headings(`sobre|dudosas|erróneamente atribuidas|atribuidas erróneamente|mal atribuidas|véase también|enlaces externos|referencias|fuentes|notas|reparto`),
+}
+
+// headings matches any of the alternatives as a whole word of a heading,
+// case-insensitively
+func headings(alternatives string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(?:` + alternatives + `)(?:$|[^\p{L}\p{N}])`)
+}
+
+var (
+	headingRe = regexp.MustCompile(`^(=+)\s*(.*?)\s*=+\s*$`)
+	// yearRe matches headings that date quotes rather than name a work,
+	// e.g. 1905, 1930s or 1940–1945
+	yearRe = regexp.MustCompile(`^[\d\s–-]+s?$`)
+	// workYearRe matches the year after a work's title, as in
+	// The World as I See It (1934)
+	workYearRe = regexp.MustCompile(`\s*\(\d{3,4}\)$`)
+	italicRe   = regexp.MustCompile(`''([^']+)''`)
+)
+
+// Quotes extracts the quotes of the page. A quote is a top-level bullet;
+// the line under it cites its source, whose italic title is the quote's
+// book, or else the heading of a work it is listed under. The page's title
+// is the author.
+func (p Page) Quotes() []source.Quote {
+	author := disambiguationRe.ReplaceAllString(p.Title, "")
+	skip := skippedSections[p.Lang]
+	var quotes []source.Quote
+	skipping := false
+	skipLevel := 0
+	work := ""
+	last := -1 // the quote a source line cites
+	for _, line := range strings.Split(p.Text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			level, title := len(m[1]), Clean(m[2])
+			if skipping && level > skipLevel {
+				continue
+			}
+			skipping = skip != nil && skip.MatchString(title)
+			skipLevel = level
+			work = ""
+			if level > 2 && !yearRe.MatchString(title) {
+				work = workYearRe.ReplaceAllString(title, "")
+			}
+			last = -1
+			continue
+		}
+		if skipping {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "**"), strings.HasPrefix(line, "*:"):
+			if last >= 0 && quotes[last].Book == "" {
+				if m := italicRe.FindStringSubmatch(line); m != nil {
+					quotes[last].Book = Clean(m[1])
+				}
+			}
+		case strings.HasPrefix(line, "*"):
+			last = -1
+			text := Clean(line[1:])
+			if text == "" {
+				continue
+			}
+			quotes = append(quotes, source.Quote{Text: text, Author: author, Book: work, Lang: p.Lang})
+			last = len(quotes) - 1
+		default:
+			if strings.TrimSpace(line) != "" {
+				last = -1
+			}
+		}
+	}
+	return quotes
+}
+
+// disambiguationRe matches what sets apart pages of the same name, as in
+// Homer (poet)
+var disambiguationRe = regexp.MustCompile(`\s*\([^)]*\)$`)
+
+var (
+	refRe      = regexp.MustCompile(`(?s)<ref[^>/]*/>|<ref[^>]*>.*?</ref>`)
+	commentRe  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	templateRe = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+	fileLinkRe = regexp.MustCompile(`(?i)\[\[(?:file|image|category|dosya|resim|kategori|archivo|imagen|categoría):[^\]]*\]\]`)
+	linkRe     = regexp.MustCompile(`\[\[(?:[^\]|]*\|)?([^\]]*)\]\]`)
+	extLinkRe  = regexp.MustCompile(`\[(?:https?:)?//[^\s\]]+\s*([^\]]*)\]`)
+	tagRe      = regexp.MustCompile(`<[^>]+>`)
+	spaceRe    = regexp.MustCompile(`\s+`)
+)
+
+// Clean turns wikitext into plain text: references, comments, templates and
+// tags are dropped, links keep the text they show and bold and italic marks
+// go
+func Clean(s string) string {
+	s = refRe.ReplaceAllString(s, "")
+	s = commentRe.ReplaceAllString(s, "")
+	// Templates nest, so innermost ones go first until none is left
+	for prev := ""; prev != s; {
+		prev, s = s, templateRe.ReplaceAllString(s, "")
+	}
+	s = fileLinkRe.ReplaceAllString(s, "")
+	s = linkRe.ReplaceAllString(s, "$1")
+	s = extLinkRe.ReplaceAllString(s, "$1")
+	s = tagRe.ReplaceAllString(s, "")
+	s = strings.NewReplacer("'''", "", "''", "").Replace(s)
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(spaceRe.ReplaceAllString(s, " "))
+}